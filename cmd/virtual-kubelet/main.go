@@ -6,6 +6,7 @@ import (
 	"errors"
 	"flag"
 	"fmt"
+	"net"
 	"net/http"
 	"os"
 	"os/signal"
@@ -14,9 +15,15 @@ import (
 	"strings"
 	"time"
 
+	"github.com/agoda-com/macOS-vz-kubelet/internal/netutil"
+	"github.com/agoda-com/macOS-vz-kubelet/internal/utils"
 	"github.com/agoda-com/macOS-vz-kubelet/pkg/client"
+	"github.com/agoda-com/macOS-vz-kubelet/pkg/downloader"
 	"github.com/agoda-com/macOS-vz-kubelet/pkg/event"
 	"github.com/agoda-com/macOS-vz-kubelet/pkg/provider"
+	rm "github.com/agoda-com/macOS-vz-kubelet/pkg/resourcemanager"
+	"github.com/agoda-com/macOS-vz-kubelet/pkg/vm"
+	"github.com/agoda-com/macOS-vz-kubelet/pkg/vm/config"
 
 	"github.com/virtual-kubelet/virtual-kubelet/errdefs"
 	"github.com/virtual-kubelet/virtual-kubelet/log"
@@ -33,6 +40,7 @@ import (
 	"github.com/spf13/cobra"
 	"go.opentelemetry.io/otel/attribute"
 	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/util/validation"
 	"k8s.io/apiserver/pkg/server/dynamiccertificates"
 	"k8s.io/apiserver/pkg/server/options"
 	"k8s.io/client-go/kubernetes"
@@ -43,7 +51,7 @@ import (
 )
 
 var (
-	appIdentifier = "com.agoda.fleet.virtualization"
+	appIdentifier = envOrDefault("VZ_APP_IDENTIFIER", "com.agoda.fleet.virtualization")
 	buildVersion  = "N/A"
 	k8sVersion    = "v1.33.1" // This should follow the version of k8s.io we are importing
 
@@ -51,13 +59,20 @@ var (
 	taintEffect = envOrDefault("VKUBELET_TAINT_EFFECT", string(corev1.TaintEffectNoSchedule))
 	taintValue  = envOrDefault("VKUBELET_TAINT_VALUE", "macos-vz")
 
-	logLevel        = "info"
-	traceSampleRate string
+	configFile          = os.Getenv("VKUBELET_CONFIG_FILE")
+	cacheDir            = os.Getenv("VZ_CACHE_DIR")
+	logLevel            = "info"
+	traceSampleRate     string
+	traceServiceName    = os.Getenv("OTEL_SERVICE_NAME")
+	traceAttributes     []string
+	tracePodAnnotations []string
 
 	// k8s
 	kubeConfigPath  = os.Getenv("KUBECONFIG")
 	startupTimeout  time.Duration
 	disableTaint    bool
+	startupTaint    bool
+	taintOSVersion  bool
 	numberOfWorkers               = 10
 	resync          time.Duration = 1 * time.Minute
 	providerID      string
@@ -72,7 +87,54 @@ var (
 	webhookAuthzUnauthedCacheTTL time.Duration
 	webhookAuthzAuthedCacheTTL   time.Duration
 	nodeName                     = "vk-macos-vz-test"
+	sanitizeNodeName             = true
 	listenPort                   = 10250
+	listenAddress                string
+	nodeIP                       = os.Getenv("VKUBELET_POD_IP")
+	nodeIPInterface              string
+	excludeFromLoadBalancers     = true
+	nodeLabels                   []string
+	orphanDeleteGracePeriod      = time.Duration(provider.DefaultDeleteVZGroupGracePeriodSeconds) * time.Second
+	retainFailedVMs              bool
+	nodeStatusUpdateInterval     = provider.DefaultNodeStatusUpdateInterval
+	containerInspectCacheTTL     = rm.DefaultInspectCacheTTL
+
+	// macOS virtual machines
+	shareCheckInterval   time.Duration
+	namespaceQuota       int
+	namespaceQuotaByName map[string]int
+	maxVMLifetime        time.Duration
+	vmStartAttempts      = rm.DefaultStartAttempts
+	vmStartBackoff       = rm.DefaultStartBackoff
+	vmStatsTimeout       = rm.DefaultStatsTimeout
+	disableVMAudio       bool
+	disableVMInput       bool
+	enableVMBalloon      bool
+	vmDiskMode           = string(config.DiskModeOverlay)
+	vmCPUQoS             = string(config.CPUQoSDefault)
+	vmExtendedCPU        []string
+	vmExtendedMemory     []string
+	syncVMClock          = true
+	vmReadinessTimeout   = rm.DefaultReadinessTimeout
+	vmStopConfirmTimeout = rm.DefaultStopConfirmationTimeout
+	enablePreemption     bool
+	evictOnStorageLimit  bool
+	ipDiscovery          = vm.DefaultIPDiscovery
+	dhcpLeasesPath       = netutil.DefaultDHCPLeasesPath
+	vmStaticIP           string
+	bridgeFallbackToNAT  bool
+
+	// image downloads
+	imagePullBandwidthLimit int64
+	pinImageDigests         bool
+	streamImageLayers       bool
+	deleteImageOnLastPod    bool
+	registryMirrors         map[string]string
+	registryConfigFile      string
+	defaultMacOSImage       string
+	maxConcurrentDownloads  int
+	maxImageSize            int64
+	imageStoreLayout        = string(downloader.LayoutReference)
 )
 
 func main() {
@@ -88,11 +150,6 @@ func main() {
 			kubeConfigPath = filepath.Join(home, ".kube", "config")
 		}
 	}
-	k8sClient, err := nodeutil.ClientsetFromEnv(kubeConfigPath)
-	if err != nil {
-		log.G(ctx).Fatal(err)
-	}
-
 	cmd := &cobra.Command{
 		Use:   binaryName,
 		Short: desc,
@@ -109,7 +166,23 @@ func main() {
 
 			// Set the default logger
 			ctx := log.WithLogger(cmd.Context(), log.L)
-			if err := run(ctx, k8sClient); err != nil {
+
+			reload := newReloader(configFile, logger)
+			if configFile != "" {
+				if err := reload.reload(ctx); err != nil {
+					log.L.Fatal(err)
+				}
+			}
+
+			// the client is created by the node command only, the other commands run without a cluster
+			k8sClient, err := nodeutil.ClientsetFromEnv(kubeConfigPath)
+			if err != nil {
+				log.L.Fatal(err)
+			}
+			if err := configureNodeName(ctx); err != nil {
+				log.L.Fatal(err)
+			}
+			if err := run(ctx, k8sClient, reload); err != nil {
 				if !errors.Is(err, context.Canceled) {
 					log.L.Fatal(err)
 				}
@@ -117,6 +190,7 @@ func main() {
 			}
 		},
 	}
+	cmd.AddCommand(newValidateImageCommand())
 	flags := cmd.Flags()
 
 	klogFlags := flag.NewFlagSet("klog", flag.ContinueOnError)
@@ -130,14 +204,29 @@ func main() {
 	if err != nil {
 		log.G(ctx).Fatal(err)
 	}
-	// lowercase RFC 1123 subdomain
+	// lowercase RFC 1123 subdomain, further sanitized on startup (see --sanitize-nodename)
 	hostName = strings.ToLower(hostName)
 
 	flags.StringVar(&nodeName, "nodename", hostName, "kubernetes node name")
+	flags.BoolVar(&sanitizeNodeName, "sanitize-nodename", sanitizeNodeName, "convert the node name into a valid RFC 1123 subdomain instead of rejecting invalid names")
+	flags.StringVar(&nodeIP, "node-ip", nodeIP, "internal IP address of the node, takes precedence over --node-ip-interface (defaults to VKUBELET_POD_IP env)")
+	flags.StringVar(&nodeIPInterface, "node-ip-interface", nodeIPInterface, "network interface whose IP address is the internal IP of the node, e.g. en1 (defaults to the active interface)")
+	flags.StringVar(&listenAddress, "listen-address", listenAddress, "IP address the kubelet server binds to, e.g. the private node IP (defaults to all interfaces)")
 	flags.StringVar(&providerID, "provider-id", providerID, "provider ID to report to the Kubernetes API server")
 	flags.DurationVar(&startupTimeout, "startup-timeout", startupTimeout, "How long to wait for the virtual-kubelet to start")
 	flags.BoolVar(&disableTaint, "disable-taint", disableTaint, "disable the node taint")
+	flags.BoolVar(&excludeFromLoadBalancers, "exclude-from-load-balancers", excludeFromLoadBalancers, "label the node to be excluded from external load balancers")
+	flags.StringArrayVar(&nodeLabels, "node-label", nodeLabels, "add a key=value label to the node, can be repeated")
+	flags.DurationVar(&orphanDeleteGracePeriod, "orphan-delete-grace-period", orphanDeleteGracePeriod, "grace period for stopping the virtual machines and containers of pods that are gone or terminal")
+	flags.BoolVar(&retainFailedVMs, "retain-failed-vms", retainFailedVMs, "keep the virtual machines of failed pods for debugging until the pods are deleted")
+	flags.DurationVar(&nodeStatusUpdateInterval, "node-status-update-interval", nodeStatusUpdateInterval, "how often to recompute and report the node status and conditions (1s to 5m), jittered by up to 10%")
+	flags.DurationVar(&containerInspectCacheTTL, "container-inspect-cache-ttl", containerInspectCacheTTL, "how long the inspected states of regular containers are reused before inspecting them again (0 disables reusing them)")
+	flags.BoolVar(&taintOSVersion, "taint-macos-version", taintOSVersion, "taint the node with the major macOS version of the host")
+	flags.BoolVar(&startupTaint, "startup-taint", startupTaint, "keep a startup taint on the node until docker and the image cache are ready")
 	flags.StringVar(&logLevel, "log-level", logLevel, "log level.")
+	flags.StringVar(&appIdentifier, "app-identifier", appIdentifier, "application identifier, used as the name of the default cache directory")
+	flags.StringVar(&cacheDir, "cache-dir", cacheDir, "directory for the image cache and pod volumes (defaults to the user cache directory)")
+	flags.StringVar(&configFile, "config-file", configFile, "path to a config file with settings that are reloaded on SIGHUP (log level, trace sample rate, share check interval)")
 	flags.IntVar(&numberOfWorkers, "pod-sync-workers", numberOfWorkers, `set the number of pod synchronization workers`)
 	flags.DurationVar(&resync, "full-resync-period", resync, "how often to perform a full resync of pods between kubernetes and the provider")
 
@@ -153,6 +242,43 @@ func main() {
 		"The duration to cache 'unauthorized' responses from the webhook authorizer.")
 
 	flags.StringVar(&traceSampleRate, "trace-sample-rate", traceSampleRate, "set probability of tracing samples")
+	flags.StringVar(&traceServiceName, "trace-service-name", traceServiceName, "set the service name reported in traces (defaults to the node name)")
+	flags.StringArrayVar(&traceAttributes, "trace-attr", traceAttributes, "add a key=value resource attribute to traces, can be repeated")
+	flags.StringSliceVar(&tracePodAnnotations, "trace-pod-annotations", tracePodAnnotations, "comma-separated keys of pod annotations set as attributes on the spans of the pod creation, e.g. a CI build ID")
+
+	flags.IntVar(&namespaceQuota, "namespace-vm-quota", namespaceQuota, "maximum number of virtual machines running concurrently within a namespace (0 means unlimited)")
+	flags.StringToIntVar(&namespaceQuotaByName, "namespace-vm-quotas", namespaceQuotaByName, "per-namespace overrides of --namespace-vm-quota as namespace=quota pairs")
+	flags.DurationVar(&maxVMLifetime, "max-vm-lifetime", maxVMLifetime, "maximum lifetime of a macOS virtual machine after which it is stopped and its pod failed (0 means unlimited)")
+	flags.IntVar(&vmStartAttempts, "vm-start-attempts", vmStartAttempts, "maximum number of attempts to start a macOS virtual machine failing with transient errors")
+	flags.DurationVar(&vmStartBackoff, "vm-start-backoff", vmStartBackoff, "delay before retrying a failed macOS virtual machine start, doubled after every retry")
+	flags.BoolVar(&enablePreemption, "enable-preemption", enablePreemption, "preempt the macOS virtual machines of lower priority pods when a higher priority pod is pending at VM capacity")
+	flags.BoolVar(&evictOnStorageLimit, "ephemeral-storage-eviction", evictOnStorageLimit, "evict the pods whose macOS virtual machines exceed their ephemeral-storage limit instead of only recording a warning event")
+	flags.DurationVar(&vmStatsTimeout, "vm-stats-timeout", vmStatsTimeout, "timeout for collecting the stats inside a macOS virtual machine, after which empty stats are reported")
+	flags.BoolVar(&disableVMAudio, "disable-vm-audio", disableVMAudio, "skip the audio device of macOS virtual machines unless their pods enable it with an annotation")
+	flags.BoolVar(&disableVMInput, "disable-vm-input", disableVMInput, "skip the keyboard and pointing devices of macOS virtual machines unless their pods enable them with an annotation")
+	flags.BoolVar(&enableVMBalloon, "enable-vm-memory-balloon", enableVMBalloon, "attach the memory balloon device to macOS virtual machines unless their pods skip it with an annotation")
+	flags.DurationVar(&vmReadinessTimeout, "vm-readiness-timeout", vmReadinessTimeout, "time a started macOS virtual machine may take to accept SSH connections before its pod is failed (0 disables the check)")
+	flags.DurationVar(&vmStopConfirmTimeout, "vm-stop-confirmation-timeout", vmStopConfirmTimeout, "time the deletion of a macOS virtual machine waits for it to be reported stopped and its overlays removed before its slot is released (0 disables the wait)")
+	flags.BoolVar(&syncVMClock, "sync-vm-clock", syncVMClock, "synchronize the clock of macOS virtual machines with network time once they have booted")
+	flags.StringVar(&vmDiskMode, "disk-mode", vmDiskMode, "boot disk of macOS virtual machines unless their pods select one with an annotation: overlay (discarded when the VM stops) or copy (kept until the pod is deleted)")
+	flags.StringVar(&vmCPUQoS, "vm-cpu-qos", vmCPUQoS, "QoS class macOS virtual machines are started with: default, or performance to hint the host to run them on performance cores")
+	flags.StringSliceVar(&vmExtendedCPU, "cpu-extended-resources", vmExtendedCPU, "comma-separated extended resources the CPU count of macOS containers is read from, in order, when they do not request cpu")
+	flags.StringSliceVar(&vmExtendedMemory, "memory-extended-resources", vmExtendedMemory, "comma-separated extended resources the memory of macOS containers is read from, in order, when they do not request memory")
+	flags.Int64Var(&imagePullBandwidthLimit, "image-pull-bandwidth-limit", imagePullBandwidthLimit, "maximum combined bandwidth of macOS image downloads in bytes per second (0 means unlimited)")
+	flags.BoolVar(&pinImageDigests, "pin-image-digests", pinImageDigests, "pin macOS image tags to the digest they were first resolved to, until the image is pulled with the Always pull policy")
+	flags.StringSliceVar(&ipDiscovery, "ip-discovery", ipDiscovery, "methods discovering the IP address of macOS virtual machines, tried in order (arp, tcpdump, dhcp-lease, static)")
+	flags.StringVar(&dhcpLeasesPath, "dhcp-leases-path", dhcpLeasesPath, "leases file of the host DHCP server, used by the dhcp-lease IP discovery method")
+	flags.BoolVar(&bridgeFallbackToNAT, "bridge-fallback-to-nat", bridgeFallbackToNAT, "attach macOS virtual machines to NAT with a warning when the VZ_BRIDGE_INTERFACE network interface is missing, instead of failing their pods")
+	flags.StringVar(&vmStaticIP, "vm-static-ip", vmStaticIP, "IP address of the macOS virtual machines, used by the static IP discovery method (e.g. a single VM with a DHCP reservation)")
+	flags.BoolVar(&streamImageLayers, "stream-image-decompression", streamImageLayers, "decompress macOS image layers while they are downloaded instead of saving the compressed layers first, halving the disk space needed by pulls")
+	flags.StringToStringVar(&registryMirrors, "registry-mirror", registryMirrors, "mirrors of the registries of macOS images as registry=mirror pairs, failed pulls are retried against the mirror")
+	flags.StringVar(&registryConfigFile, "registry-config", registryConfigFile, "path to a YAML file configuring the clients of the registries of macOS images by registry host, with custom headers and client certificates")
+	flags.StringVar(&imageStoreLayout, "image-store-layout", imageStoreLayout, "layout of the cached content of macOS images: reference (a directory per image) or sharded (content stored once by digest and shared by the images, moving the content laid out by reference as it is pulled)")
+	flags.IntVar(&maxConcurrentDownloads, "max-concurrent-downloads", maxConcurrentDownloads, "maximum number of macOS image downloads running at once, further downloads are queued (0 means unlimited)")
+	flags.Int64Var(&maxImageSize, "max-image-size", maxImageSize, "maximum size in bytes of the content of a macOS image, uncompressed where annotated, larger images are rejected before they are downloaded (0 means unlimited)")
+	flags.StringVar(&defaultMacOSImage, "default-macos-image", defaultMacOSImage, "image of the macOS containers that do not set one")
+	flags.BoolVar(&deleteImageOnLastPod, "delete-image-on-last-pod", deleteImageOnLastPod, "remove the cached content of a macOS image once the last pod using it is deleted")
+	flags.DurationVar(&shareCheckInterval, "share-check-interval", shareCheckInterval, "how often to verify that shared directories are accessible inside macOS virtual machines (0 disables the verification)")
 
 	if err := cmd.ExecuteContext(ctx); err != nil {
 		if !errors.Is(err, context.Canceled) {
@@ -161,7 +287,58 @@ func main() {
 	}
 }
 
+// configureNodeName ensures the node name is a valid RFC 1123 subdomain, sanitizing it if enabled.
+func configureNodeName(ctx context.Context) error {
+	if !sanitizeNodeName {
+		return utils.ValidateNodeName(nodeName)
+	}
+
+	sanitized, err := utils.SanitizeNodeName(nodeName)
+	if err != nil {
+		return err
+	}
+	if sanitized != nodeName {
+		log.G(ctx).Infof("Node name %q was sanitized to %q", nodeName, sanitized)
+		nodeName = sanitized
+	}
+	return nil
+}
+
+// namespaceQuotas validates and returns the namespace quotas of virtual machines.
+func namespaceQuotas() (rm.NamespaceQuotas, error) {
+	if namespaceQuota < 0 {
+		return rm.NamespaceQuotas{}, errdefs.InvalidInputf("namespace quota must not be negative: %d", namespaceQuota)
+	}
+	for namespace, quota := range namespaceQuotaByName {
+		if quota < 0 {
+			return rm.NamespaceQuotas{}, errdefs.InvalidInputf("quota of namespace %s must not be negative: %d", namespace, quota)
+		}
+	}
+	return rm.NamespaceQuotas{Default: namespaceQuota, Namespaces: namespaceQuotaByName}, nil
+}
+
+// MacOSVersionTaintKey is the key of the node taint holding the major macOS version of the host.
+const MacOSVersionTaintKey = "macosvz.agoda.com/macos-version"
+
+// platformVersion returns the version of the host operating system, e.g. "14.5".
+var platformVersion = func() (string, error) {
+	_, _, version, err := host.PlatformInformation()
+	return version, err
+}
+
 func withTaint(cfg *nodeutil.NodeConfig) error {
+	if taintOSVersion {
+		version, err := platformVersion()
+		if err != nil {
+			return fmt.Errorf("failed to get the macOS version: %w", err)
+		}
+		taint, err := macOSVersionTaint(version)
+		if err != nil {
+			return err
+		}
+		cfg.NodeSpec.Spec.Taints = append(cfg.NodeSpec.Spec.Taints, taint)
+	}
+
 	if disableTaint {
 		return nil
 	}
@@ -184,6 +361,114 @@ func withTaint(cfg *nodeutil.NodeConfig) error {
 	return nil
 }
 
+// macOSVersionTaint returns the taint holding the major version of the given macOS version.
+func macOSVersionTaint(version string) (corev1.Taint, error) {
+	major, _, _ := strings.Cut(version, ".")
+	if _, err := strconv.ParseUint(major, 10, 32); err != nil {
+		return corev1.Taint{}, errdefs.InvalidInputf("macOS version %q has no major version", version)
+	}
+	return corev1.Taint{
+		Key:    MacOSVersionTaintKey,
+		Value:  major,
+		Effect: corev1.TaintEffectNoSchedule,
+	}, nil
+}
+
+// parseNodeLabels parses the key=value pairs of --node-label, validating the syntax of the label keys and values.
+func parseNodeLabels(pairs []string) (map[string]string, error) {
+	labels := make(map[string]string, len(pairs))
+	for _, pair := range pairs {
+		key, value, ok := strings.Cut(pair, "=")
+		if !ok {
+			return nil, errdefs.InvalidInputf("node label %q is not a key=value pair", pair)
+		}
+		if errs := validation.IsQualifiedName(key); len(errs) > 0 {
+			return nil, errdefs.InvalidInputf("invalid node label key %q: %s", key, strings.Join(errs, "; "))
+		}
+		if errs := validation.IsValidLabelValue(value); len(errs) > 0 {
+			return nil, errdefs.InvalidInputf("invalid node label value %q of %s: %s", value, key, strings.Join(errs, "; "))
+		}
+		labels[key] = value
+	}
+	return labels, nil
+}
+
+// withNodeLabels adds the labels to the node, overriding the labels set by the provider.
+func withNodeLabels(n *corev1.Node, labels map[string]string) {
+	if len(labels) == 0 {
+		return
+	}
+	if n.Labels == nil {
+		n.Labels = make(map[string]string, len(labels))
+	}
+	for key, value := range labels {
+		n.Labels[key] = value
+	}
+}
+
+func withStartupTaint(st *provider.StartupTaint) nodeutil.NodeOpt {
+	return func(cfg *nodeutil.NodeConfig) error {
+		if st != nil {
+			cfg.NodeSpec.Spec.Taints = append(cfg.NodeSpec.Spec.Taints, st.Taint())
+		}
+		return nil
+	}
+}
+
+// cacheHealthCheck verifies that the cache directory is writable.
+func cacheHealthCheck(cachePath string) provider.HealthCheck {
+	return func(context.Context) error {
+		return ensureWritableDir(cachePath)
+	}
+}
+
+// resolveCachePath returns the cache directory, which is either set by --cache-dir
+// or derived from the user cache directory, and ensures it is writable.
+func resolveCachePath() (string, error) {
+	var cachePath string
+	if cacheDir != "" {
+		abs, err := filepath.Abs(cacheDir)
+		if err != nil {
+			return "", errdefs.AsInvalidInput(fmt.Errorf("invalid cache directory %s: %w", cacheDir, err))
+		}
+		cachePath = abs
+	} else {
+		if err := validateAppIdentifier(appIdentifier); err != nil {
+			return "", err
+		}
+		userCacheDir, err := os.UserCacheDir()
+		if err != nil {
+			return "", err
+		}
+		cachePath = filepath.Join(userCacheDir, appIdentifier)
+	}
+
+	if err := ensureWritableDir(cachePath); err != nil {
+		return "", fmt.Errorf("cache directory %s is not writable: %w", cachePath, err)
+	}
+	return cachePath, nil
+}
+
+// validateAppIdentifier ensures the application identifier can be used as a directory name.
+func validateAppIdentifier(id string) error {
+	if id == "" || id == "." || id == ".." || strings.ContainsAny(id, `/\`) {
+		return errdefs.InvalidInputf("invalid app identifier %q", id)
+	}
+	return nil
+}
+
+// ensureWritableDir creates the directory if needed and verifies that files can be written into it.
+func ensureWritableDir(path string) error {
+	if err := os.MkdirAll(path, 0o755); err != nil {
+		return err
+	}
+	f, err := os.CreateTemp(path, ".health-*")
+	if err != nil {
+		return err
+	}
+	return errors.Join(f.Close(), os.Remove(f.Name()))
+}
+
 func withProviderID(cfg *nodeutil.NodeConfig) error {
 	cfg.NodeSpec.Spec.ProviderID = providerID
 	return nil
@@ -194,10 +479,11 @@ func withVersion(cfg *nodeutil.NodeConfig) error {
 	return nil
 }
 
-func configureRoutes(cfg *nodeutil.NodeConfig) error {
-	mux := http.NewServeMux()
-	cfg.Handler = mux
-	return nodeutil.AttachProviderRoutes(mux)(cfg)
+func configureRoutes(mux *http.ServeMux) nodeutil.NodeOpt {
+	return func(cfg *nodeutil.NodeConfig) error {
+		cfg.Handler = mux
+		return nodeutil.AttachProviderRoutes(mux)(cfg)
+	}
 }
 
 func withWebhookAuth(ctx context.Context, cfg *nodeutil.NodeConfig) error {
@@ -240,6 +526,51 @@ func withWebhookAuth(ctx context.Context, cfg *nodeutil.NodeConfig) error {
 	return nil
 }
 
+// minResyncPeriod is the shortest full resync period, more frequent resyncs would flood the provider with pod updates.
+const minResyncPeriod = 10 * time.Second
+
+// validatePodSync checks the pod synchronization flags, since the pod controller silently stops syncing pods without workers.
+func validatePodSync(workers int, resync time.Duration) error {
+	if workers < 1 {
+		return errdefs.InvalidInputf("pod sync workers must be at least 1: %d", workers)
+	}
+	if resync < minResyncPeriod {
+		return errdefs.InvalidInputf("full resync period must be at least %s: %s", minResyncPeriod, resync)
+	}
+	return nil
+}
+
+// validateListenAddress checks that the listen address is empty, to bind all interfaces, or an IP address.
+func validateListenAddress(address string) error {
+	if address != "" && net.ParseIP(address) == nil {
+		return errdefs.InvalidInputf("listen address must be an IP address: %q", address)
+	}
+	return nil
+}
+
+// validateNodeIP checks that the node IP is empty or an IP address, and that the node IP interface is empty or exists.
+// The node IP takes precedence over the interface, whose IP address is used otherwise.
+func validateNodeIP(ip, iface string) error {
+	if ip != "" && net.ParseIP(ip) == nil {
+		return errdefs.InvalidInputf("node IP must be an IP address: %q", ip)
+	}
+	if iface == "" {
+		return nil
+	}
+	if _, err := net.InterfaceByName(iface); err != nil {
+		return errdefs.InvalidInputf("node IP interface %q not found: %v", iface, err)
+	}
+	return nil
+}
+
+// withHTTPListenAddr binds the kubelet server to the address and port, all interfaces if the address is empty.
+func withHTTPListenAddr(address string, port int) nodeutil.NodeOpt {
+	return func(cfg *nodeutil.NodeConfig) error {
+		cfg.HTTPListenAddr = net.JoinHostPort(address, strconv.Itoa(port))
+		return nil
+	}
+}
+
 func withClient(c kubernetes.Interface, cfg *nodeutil.NodeConfig) error {
 	return nodeutil.WithClient(c)(cfg)
 }
@@ -257,24 +588,144 @@ func withCA(cfg *tls.Config) error {
 	return nil
 }
 
-func run(ctx context.Context, c kubernetes.Interface) error {
+func run(ctx context.Context, c kubernetes.Interface, reload *reloader) error {
 	service := nodeName
-	if serviceName := os.Getenv("OTEL_SERVICE_NAME"); serviceName != "" {
-		service = serviceName
+	if traceServiceName != "" {
+		service = traceServiceName
 	}
 
-	if err := configureTracing(
-		ctx,
-		service,
-		traceSampleRate,
+	quotas, err := namespaceQuotas()
+	if err != nil {
+		return err
+	}
+	labels, err := parseNodeLabels(nodeLabels)
+	if err != nil {
+		return err
+	}
+	if err := validatePodSync(numberOfWorkers, resync); err != nil {
+		return err
+	}
+	if err := validateListenAddress(listenAddress); err != nil {
+		return err
+	}
+	if err := validateNodeIP(nodeIP, nodeIPInterface); err != nil {
+		return err
+	}
+	if nodeIP != "" && nodeIPInterface != "" {
+		log.G(ctx).Warnf("Node IP %s takes precedence over node IP interface %s", nodeIP, nodeIPInterface)
+	}
+	if maxVMLifetime < 0 {
+		return errdefs.InvalidInputf("max VM lifetime must not be negative: %s", maxVMLifetime)
+	}
+	if nodeStatusUpdateInterval < time.Second || nodeStatusUpdateInterval > provider.MaxNodeStatusUpdateInterval {
+		return errdefs.InvalidInputf("node status update interval must be between 1s and %s: %s", provider.MaxNodeStatusUpdateInterval, nodeStatusUpdateInterval)
+	}
+	if orphanDeleteGracePeriod < time.Second {
+		return errdefs.InvalidInputf("orphan delete grace period must be at least 1s: %s", orphanDeleteGracePeriod)
+	}
+	if vmStartAttempts < 1 {
+		return errdefs.InvalidInputf("VM start attempts must be at least 1: %d", vmStartAttempts)
+	}
+	if vmStartBackoff <= 0 {
+		return errdefs.InvalidInputf("VM start backoff must be positive: %s", vmStartBackoff)
+	}
+	if vmReadinessTimeout < 0 {
+		return errdefs.InvalidInputf("VM readiness timeout must not be negative: %s", vmReadinessTimeout)
+	}
+	if vmStopConfirmTimeout < 0 {
+		return errdefs.InvalidInputf("VM stop confirmation timeout must not be negative: %s", vmStopConfirmTimeout)
+	}
+	if containerInspectCacheTTL < 0 {
+		return errdefs.InvalidInputf("container inspect cache TTL must not be negative: %s", containerInspectCacheTTL)
+	}
+	if vmStatsTimeout <= 0 {
+		return errdefs.InvalidInputf("VM stats timeout must be positive: %s", vmStatsTimeout)
+	}
+	if imagePullBandwidthLimit < 0 {
+		return errdefs.InvalidInputf("image pull bandwidth limit must not be negative: %d", imagePullBandwidthLimit)
+	}
+	if err := downloader.ValidateRegistryMirrors(registryMirrors); err != nil {
+		return err
+	}
+	var registryClients downloader.RegistryClients
+	if registryConfigFile != "" {
+		configs, err := downloader.LoadRegistryConfigs(registryConfigFile)
+		if err != nil {
+			return err
+		}
+		if registryClients, err = downloader.NewRegistryClients(configs); err != nil {
+			return err
+		}
+	}
+	if maxConcurrentDownloads < 0 {
+		return errdefs.InvalidInputf("max concurrent downloads must not be negative: %d", maxConcurrentDownloads)
+	}
+	if maxImageSize < 0 {
+		return errdefs.InvalidInputf("max image size must not be negative: %d", maxImageSize)
+	}
+	diskMode, err := config.ParseDiskMode(vmDiskMode)
+	if err != nil {
+		return errdefs.AsInvalidInput(err)
+	}
+	cpuQoS, err := config.ParseCPUQoS(vmCPUQoS)
+	if err != nil {
+		return errdefs.AsInvalidInput(err)
+	}
+	var extendedResources rm.ExtendedResourceNames
+	for _, name := range vmExtendedCPU {
+		if err := utils.ValidateExtendedResourceName(corev1.ResourceName(name)); err != nil {
+			return errdefs.AsInvalidInput(err)
+		}
+		extendedResources.CPU = append(extendedResources.CPU, corev1.ResourceName(name))
+	}
+	for _, name := range vmExtendedMemory {
+		if err := utils.ValidateExtendedResourceName(corev1.ResourceName(name)); err != nil {
+			return errdefs.AsInvalidInput(err)
+		}
+		extendedResources.Memory = append(extendedResources.Memory, corev1.ResourceName(name))
+	}
+	storeLayout, err := downloader.ParseLayout(imageStoreLayout)
+	if err != nil {
+		return err
+	}
+	if defaultMacOSImage != "" {
+		if _, err := downloader.ParseReference(defaultMacOSImage); err != nil {
+			return err
+		}
+	}
+	ipResolverConfig := vm.IPResolverConfig{DHCPLeasesPath: dhcpLeasesPath, StaticIP: vmStaticIP}
+	if err := vm.ValidateIPDiscovery(ipDiscovery, ipResolverConfig); err != nil {
+		return errdefs.AsInvalidInput(err)
+	}
+	if _, err := rm.SSHPort(); err != nil {
+		return err
+	}
+	cachePath, err := resolveCachePath()
+	if err != nil {
+		return err
+	}
+
+	customAttributes, err := utils.ParseTraceAttributes(traceAttributes)
+	if err != nil {
+		return errdefs.AsInvalidInput(err)
+	}
+	attributes := append([]attribute.KeyValue{
 		attribute.String("node.name", nodeName),
 		attribute.String("taint.key", taintKey),
 		attribute.String("taint.effect", taintEffect),
 		attribute.String("taint.value", taintValue),
-	); err != nil {
+	}, customAttributes...)
+
+	if err := configureTracing(ctx, service, reload.TraceSampleRate(), attributes...); err != nil {
 		return err
 	}
 
+	mux := http.NewServeMux()
+	var vzProvider *provider.MacOSVZProvider
+	var st *provider.StartupTaint
+	if startupTaint {
+		st = &provider.StartupTaint{NodeName: nodeName, Client: c}
+	}
 	node, err := nodeutil.NewNode(nodeName,
 		func(cfg nodeutil.ProviderConfig) (nodeutil.Provider, node.NodeProvider, error) {
 			if port := os.Getenv("KUBELET_PORT"); port != "" {
@@ -303,26 +754,74 @@ func run(ctx context.Context, c kubernetes.Interface) error {
 			)
 
 			// Create a containerd client to manage non-macOS containers
-			// If unavailable - ignore, but warn the user that some features will be unavailable
-			dockerCl, err := createDockerClient(ctx)
+			// If unavailable - ignore, but warn the user that some features will be unavailable until docker is up
+			containersClient, err := createContainersClient(ctx, eventRecorder)
 			if err != nil {
-				log.G(ctx).Warnf("failed to create docker client: %v; some features (like non-macOS containers) will be unavailable", err)
+				log.G(ctx).Warnf("failed to create docker client: %v; some features (like non-macOS containers) will be unavailable until it is created", err)
 			}
 
-			cachePath, err := os.UserCacheDir()
-			if err != nil {
-				return nil, nil, err
-			}
-			cachePath = filepath.Join(cachePath, appIdentifier)
+			sshCredentials := &provider.SSHCredentialsCache{Client: c, PodLister: cfg.Pods}
 
 			networkInterfaceIdentifier := os.Getenv("VZ_BRIDGE_INTERFACE")
-			vzClient := client.NewVzClientAPIs(ctx, eventRecorder, networkInterfaceIdentifier, cachePath, dockerCl)
+			vzClient := client.NewVzClientAPIs(ctx, eventRecorder, networkInterfaceIdentifier, cachePath, containersClient,
+				rm.WithShareCheckInterval(reload.ShareCheckInterval()),
+				rm.WithNamespaceQuotas(quotas),
+				rm.WithMaxLifetime(maxVMLifetime),
+				rm.WithStartRetry(vmStartAttempts, vmStartBackoff),
+				rm.WithStatsTimeout(vmStatsTimeout),
+				rm.WithPreemption(enablePreemption),
+				rm.WithEphemeralStorageEviction(evictOnStorageLimit),
+				rm.WithDefaultDevices(config.DeviceOptions{DisableAudio: disableVMAudio, DisableInput: disableVMInput, EnableMemoryBalloon: enableVMBalloon}),
+				rm.WithDiskMode(diskMode),
+				rm.WithCPUQoS(cpuQoS),
+				rm.WithExtendedResourceNames(extendedResources),
+				rm.WithClockSync(syncVMClock),
+				rm.WithReadinessTimeout(vmReadinessTimeout),
+				rm.WithStopConfirmationTimeout(vmStopConfirmTimeout),
+				rm.WithImagePullBandwidthLimit(imagePullBandwidthLimit),
+				rm.WithImageDigestPinning(pinImageDigests),
+				rm.WithStreamingDecompression(streamImageLayers),
+				rm.WithImageCleanup(deleteImageOnLastPod),
+				rm.WithRegistryMirrors(registryMirrors),
+				rm.WithRegistryClients(registryClients),
+				rm.WithDefaultImage(defaultMacOSImage),
+				rm.WithMaxConcurrentDownloads(maxConcurrentDownloads),
+				rm.WithMaxImageSize(maxImageSize),
+				rm.WithImageStoreLayout(storeLayout),
+				rm.WithSSHCredentials(sshCredentials.Credentials),
+				rm.WithIPDiscovery(ipDiscovery, ipResolverConfig),
+				rm.WithBridgeFallbackToNAT(bridgeFallbackToNAT),
+			)
+			reload.OnShareCheckInterval(vzClient.MacOSClient.SetShareCheckInterval)
+			if st != nil {
+				st.Checks = map[string]provider.HealthCheck{
+					"docker": func(context.Context) error {
+						if vzClient.ContainerClient() == nil {
+							return errors.New("container client is not initialized")
+						}
+						return nil
+					},
+					"cache": cacheHealthCheck(cachePath),
+				}
+			}
+			if vzClient.ContainerClient() == nil {
+				// Keep retrying in the background, so that regular containers are supported once docker is up
+				go vzClient.InitContainerClient(ctx, client.ContainerClientRetryInterval, func(ctx context.Context) (rm.ContainersClient, error) {
+					return createContainersClient(ctx, eventRecorder)
+				})
+			}
 
 			providerConfig := provider.MacOSVZProviderConfig{
-				NodeName:           nodeName,
-				Platform:           platform,
-				InternalIP:         os.Getenv("VKUBELET_POD_IP"),
-				DaemonEndpointPort: int32(listenPort),
+				NodeName:            nodeName,
+				Platform:            platform,
+				InternalIP:          nodeIP,
+				InternalIPInterface: nodeIPInterface,
+				DaemonEndpointPort:  int32(listenPort),
+
+				ExcludeFromLoadBalancers: excludeFromLoadBalancers,
+				OrphanDeleteGracePeriod:  orphanDeleteGracePeriod,
+				RetainFailedVMs:          retainFailedVMs,
+				TracePodAnnotations:      tracePodAnnotations,
 
 				K8sClient:     c,
 				EventRecorder: eventRecorder,
@@ -336,29 +835,56 @@ func run(ctx context.Context, c kubernetes.Interface) error {
 			if err != nil {
 				return nil, nil, err
 			}
-			return p, nil, nil
+			withNodeLabels(cfg.Node, labels)
+			vzProvider = p
+			return p, &provider.NodeStatusUpdater{
+				Node:     cfg.Node,
+				Interval: nodeStatusUpdateInterval,
+				VMSlots:  vzClient.MacOSClient.VirtualMachineSlots,
+			}, nil
 		},
 		func(cfg *nodeutil.NodeConfig) error {
 			return withClient(c, cfg)
 		},
 		withTaint,
+		withStartupTaint(st),
 		withProviderID,
 		withVersion,
 		nodeutil.WithTLSConfig(nodeutil.WithKeyPairFromPath(certPath, keyPath), withCA),
+		// routes must be configured first, so that they are all wrapped by the authentication
+		configureRoutes(mux),
 		func(cfg *nodeutil.NodeConfig) error {
 			return withWebhookAuth(ctx, cfg)
 		},
-		configureRoutes,
 		func(cfg *nodeutil.NodeConfig) error {
 			cfg.InformerResyncPeriod = resync
 			cfg.NumWorkers = numberOfWorkers
-			cfg.HTTPListenAddr = fmt.Sprintf(":%d", listenPort)
 			return nil
 		},
+		withHTTPListenAddr(listenAddress, listenPort),
 	)
 	if err != nil {
 		return err
 	}
+	mux.Handle(provider.DebugVirtualMachinesPath, vzProvider.DebugVirtualMachinesHandler())
+	mux.Handle(provider.ValidatePodPath, vzProvider.ValidatePodHandler())
+	mux.Handle(provider.ExportVirtualMachinePath, vzProvider.ExportVirtualMachineHandler())
+
+	if st != nil {
+		// the node is created with the taint, but an already registered node only gets its status updated
+		if err := st.Apply(ctx); err != nil {
+			return fmt.Errorf("error applying the startup taint: %w", err)
+		}
+		go func() {
+			if err := st.Run(ctx); err != nil && !errors.Is(err, context.Canceled) {
+				log.G(ctx).WithError(err).Error("Failed to remove the startup taint")
+			}
+		}()
+	}
+
+	if configFile != "" {
+		go reload.run(ctx)
+	}
 
 	errCh := make(chan error, 1)
 	go func() {
@@ -382,6 +908,20 @@ func run(ctx context.Context, c kubernetes.Interface) error {
 	return node.Err()
 }
 
+// createContainersClient creates the client managing the regular containers, both on startup and when retrying
+// after Docker becomes available.
+func createContainersClient(ctx context.Context, eventRecorder event.EventRecorder) (rm.ContainersClient, error) {
+	dockerCl, err := createDockerClient(ctx)
+	if err != nil {
+		return nil, err
+	}
+	containersClient, err := rm.NewDockerClient(ctx, dockerCl, eventRecorder, rm.WithInspectCacheTTL(containerInspectCacheTTL))
+	if err != nil {
+		return nil, err
+	}
+	return containersClient, nil
+}
+
 func createDockerClient(ctx context.Context) (dockerCl *docker.Client, err error) {
 	// Check if DOCKER_HOST environment variable is set
 	if host := os.Getenv("DOCKER_HOST"); host != "" {
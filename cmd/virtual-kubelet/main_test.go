@@ -0,0 +1,199 @@
+package main
+
+import (
+	"context"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/agoda-com/macOS-vz-kubelet/pkg/client"
+	"github.com/agoda-com/macOS-vz-kubelet/pkg/event/mocks"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/virtual-kubelet/virtual-kubelet/errdefs"
+	"github.com/virtual-kubelet/virtual-kubelet/node/nodeutil"
+	corev1 "k8s.io/api/core/v1"
+)
+
+func TestResolveCachePath(t *testing.T) {
+	defer func(orig string) { cacheDir = orig }(cacheDir)
+
+	cacheDir = filepath.Join(t.TempDir(), "cache")
+	cachePath, err := resolveCachePath()
+	require.NoError(t, err)
+	assert.Equal(t, cacheDir, cachePath)
+	assert.DirExists(t, cachePath)
+
+	vzClient := client.NewVzClientAPIs(context.Background(), mocks.NewEventRecorder(t), "", cachePath, nil)
+	assert.Equal(t, cacheDir, vzClient.CachePath())
+}
+
+func TestResolveCachePathNotWritable(t *testing.T) {
+	defer func(orig string) { cacheDir = orig }(cacheDir)
+
+	// a regular file cannot be used as the cache directory
+	cacheDir = filepath.Join(t.TempDir(), "file")
+	require.NoError(t, os.WriteFile(cacheDir, nil, 0o600))
+
+	_, err := resolveCachePath()
+	assert.Error(t, err)
+}
+
+func TestResolveCachePathAppIdentifier(t *testing.T) {
+	defer func(origDir, origID string) { cacheDir, appIdentifier = origDir, origID }(cacheDir, appIdentifier)
+
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("XDG_CACHE_HOME", filepath.Join(home, ".cache"))
+	userCacheDir, err := os.UserCacheDir()
+	require.NoError(t, err)
+
+	cacheDir = ""
+	appIdentifier = "com.example.virtualization"
+	cachePath, err := resolveCachePath()
+	require.NoError(t, err)
+	assert.Equal(t, filepath.Join(userCacheDir, "com.example.virtualization"), cachePath)
+	assert.DirExists(t, cachePath)
+
+	for _, id := range []string{"", "..", "com/example"} {
+		appIdentifier = id
+		_, err := resolveCachePath()
+		assert.Error(t, err, id)
+	}
+}
+
+func TestWithTaintMacOSVersion(t *testing.T) {
+	defer func(origEnabled, origDisable bool, origVersion func() (string, error)) {
+		taintOSVersion, disableTaint, platformVersion = origEnabled, origDisable, origVersion
+	}(taintOSVersion, disableTaint, platformVersion)
+	platformVersion = func() (string, error) { return "14.5", nil }
+
+	versionTaint := corev1.Taint{Key: MacOSVersionTaintKey, Value: "14", Effect: corev1.TaintEffectNoSchedule}
+	tests := []struct {
+		name          string
+		enabled       bool
+		disableTaint  bool
+		expectVersion bool
+		expectedCount int
+	}{
+		{name: "disabled", expectedCount: 1},
+		{name: "enabled", enabled: true, expectVersion: true, expectedCount: 2},
+		{name: "enabled without provider taint", enabled: true, disableTaint: true, expectVersion: true, expectedCount: 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			taintOSVersion, disableTaint = tt.enabled, tt.disableTaint
+
+			cfg := &nodeutil.NodeConfig{}
+			require.NoError(t, withTaint(cfg))
+			assert.Len(t, cfg.NodeSpec.Spec.Taints, tt.expectedCount)
+			if tt.expectVersion {
+				assert.Contains(t, cfg.NodeSpec.Spec.Taints, versionTaint)
+			} else {
+				assert.NotContains(t, cfg.NodeSpec.Spec.Taints, versionTaint)
+			}
+		})
+	}
+}
+
+func TestMacOSVersionTaint(t *testing.T) {
+	for version, major := range map[string]string{"14.5": "14", "15": "15", "13.6.7": "13"} {
+		taint, err := macOSVersionTaint(version)
+		require.NoError(t, err, version)
+		assert.Equal(t, major, taint.Value, version)
+	}
+
+	for _, version := range []string{"", "Sonoma", ".5"} {
+		_, err := macOSVersionTaint(version)
+		assert.Error(t, err, version)
+	}
+}
+
+func TestParseNodeLabels(t *testing.T) {
+	labels, err := parseNodeLabels([]string{"pool=m2-ultra", "example.com/rack=r12", "empty="})
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"pool": "m2-ultra", "example.com/rack": "r12", "empty": ""}, labels)
+
+	for _, pair := range []string{"pool", "=value", "-pool=x", "a/b/c=x", "pool=not valid", "pool=-x"} {
+		_, err := parseNodeLabels([]string{pair})
+		assert.True(t, errdefs.IsInvalidInput(err), pair)
+	}
+}
+
+func TestWithNodeLabels(t *testing.T) {
+	labels, err := parseNodeLabels([]string{"pool=m2-ultra", corev1.LabelArchStable + "=arm64e"})
+	require.NoError(t, err)
+
+	n := &corev1.Node{}
+	n.Labels = map[string]string{corev1.LabelOSStable: "darwin", corev1.LabelArchStable: "arm64"}
+	withNodeLabels(n, labels)
+	assert.Equal(t, map[string]string{
+		corev1.LabelOSStable:   "darwin",
+		corev1.LabelArchStable: "arm64e",
+		"pool":                 "m2-ultra",
+	}, n.Labels)
+
+	n = &corev1.Node{}
+	withNodeLabels(n, labels)
+	assert.Equal(t, labels, n.Labels)
+}
+
+func TestValidatePodSync(t *testing.T) {
+	assert.NoError(t, validatePodSync(10, time.Minute))
+	assert.NoError(t, validatePodSync(1, minResyncPeriod))
+
+	for _, workers := range []int{0, -1} {
+		err := validatePodSync(workers, time.Minute)
+		assert.True(t, errdefs.IsInvalidInput(err), "workers %d: %v", workers, err)
+	}
+	for _, resync := range []time.Duration{0, -time.Minute, time.Second} {
+		err := validatePodSync(10, resync)
+		assert.True(t, errdefs.IsInvalidInput(err), "resync %s: %v", resync, err)
+	}
+}
+
+func TestWithHTTPListenAddr(t *testing.T) {
+	tests := []struct {
+		address  string
+		expected string
+	}{
+		{address: "", expected: ":10250"},
+		{address: "10.0.0.5", expected: "10.0.0.5:10250"},
+		{address: "fd00::5", expected: "[fd00::5]:10250"},
+	}
+	for _, tt := range tests {
+		cfg := &nodeutil.NodeConfig{}
+		require.NoError(t, withHTTPListenAddr(tt.address, 10250)(cfg))
+		assert.Equal(t, tt.expected, cfg.HTTPListenAddr)
+	}
+}
+
+func TestValidateListenAddress(t *testing.T) {
+	assert.NoError(t, validateListenAddress(""))
+	assert.NoError(t, validateListenAddress("10.0.0.5"))
+	assert.NoError(t, validateListenAddress("fd00::5"))
+
+	for _, address := range []string{"node.local", "10.0.0.5:10250", "10.0.0.256"} {
+		err := validateListenAddress(address)
+		assert.True(t, errdefs.IsInvalidInput(err), "address %q: %v", address, err)
+	}
+}
+
+func TestValidateNodeIP(t *testing.T) {
+	ifaces, err := net.Interfaces()
+	require.NoError(t, err)
+	require.NotEmpty(t, ifaces)
+
+	assert.NoError(t, validateNodeIP("", ""))
+	assert.NoError(t, validateNodeIP("10.0.0.5", ""))
+	assert.NoError(t, validateNodeIP("", ifaces[0].Name))
+
+	err = validateNodeIP("node.local", "")
+	assert.True(t, errdefs.IsInvalidInput(err), err)
+
+	err = validateNodeIP("", "does-not-exist0")
+	assert.True(t, errdefs.IsInvalidInput(err), err)
+}
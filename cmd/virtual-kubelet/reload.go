@@ -0,0 +1,161 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/virtual-kubelet/virtual-kubelet/errdefs"
+	"github.com/virtual-kubelet/virtual-kubelet/log"
+
+	"github.com/sirupsen/logrus"
+	"sigs.k8s.io/yaml"
+)
+
+// reloadableConfig contains the settings that can be changed without a restart by sending SIGHUP to the process.
+// Empty values leave the corresponding setting unchanged.
+type reloadableConfig struct {
+	LogLevel           string `json:"logLevel,omitempty"`
+	TraceSampleRate    string `json:"traceSampleRate,omitempty"`
+	ShareCheckInterval string `json:"shareCheckInterval,omitempty"`
+}
+
+// reloader applies the reloadable configuration to the running process.
+// The current values are guarded by the mutex, since they are replaced on SIGHUP while other goroutines read them.
+type reloader struct {
+	path   string
+	logger *logrus.Logger
+
+	mu                 sync.RWMutex
+	traceSampleRate    string
+	shareCheckInterval time.Duration
+	// setShareCheckInterval applies the shared directories verification interval, nil until the provider is created.
+	setShareCheckInterval func(time.Duration)
+}
+
+// newReloader creates a reloader for the given config file, starting from the values set by the flags.
+func newReloader(path string, logger *logrus.Logger) *reloader {
+	return &reloader{
+		path:               path,
+		logger:             logger,
+		traceSampleRate:    traceSampleRate,
+		shareCheckInterval: shareCheckInterval,
+	}
+}
+
+// TraceSampleRate returns the current trace sample rate.
+func (r *reloader) TraceSampleRate() string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.traceSampleRate
+}
+
+// ShareCheckInterval returns the current shared directories verification interval.
+func (r *reloader) ShareCheckInterval() time.Duration {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.shareCheckInterval
+}
+
+// OnShareCheckInterval registers the function applying the shared directories verification interval on reload.
+func (r *reloader) OnShareCheckInterval(fn func(time.Duration)) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.setShareCheckInterval = fn
+}
+
+// load reads the reloadable configuration from the config file.
+func (r *reloader) load() (cfg reloadableConfig, err error) {
+	data, err := os.ReadFile(r.path)
+	if err != nil {
+		return cfg, fmt.Errorf("failed to read config file %s: %w", r.path, err)
+	}
+	if err := yaml.UnmarshalStrict(data, &cfg); err != nil {
+		return cfg, errdefs.AsInvalidInput(fmt.Errorf("failed to parse config file %s: %w", r.path, err))
+	}
+	return cfg, nil
+}
+
+// apply validates the configuration and applies it, leaving the settings unchanged if any of them is invalid.
+func (r *reloader) apply(ctx context.Context, cfg reloadableConfig) error {
+	lvl := r.logger.GetLevel()
+	if cfg.LogLevel != "" {
+		parsed, err := logrus.ParseLevel(cfg.LogLevel)
+		if err != nil {
+			return errdefs.AsInvalidInput(fmt.Errorf("parsing log level: %w", err))
+		}
+		lvl = parsed
+	}
+
+	if cfg.TraceSampleRate != "" {
+		if _, err := determineSampler(cfg.TraceSampleRate); err != nil {
+			return err
+		}
+	}
+
+	var interval time.Duration
+	if cfg.ShareCheckInterval != "" {
+		parsed, err := time.ParseDuration(cfg.ShareCheckInterval)
+		if err != nil {
+			return errdefs.AsInvalidInput(fmt.Errorf("parsing share check interval: %w", err))
+		}
+		interval = parsed
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.logger.SetLevel(lvl)
+
+	if cfg.TraceSampleRate != "" {
+		s, _ := determineSampler(cfg.TraceSampleRate)
+		sampler.Set(s)
+		r.traceSampleRate = cfg.TraceSampleRate
+	}
+
+	if cfg.ShareCheckInterval != "" {
+		r.shareCheckInterval = interval
+		if r.setShareCheckInterval != nil {
+			r.setShareCheckInterval(interval)
+		}
+	}
+
+	log.G(ctx).WithFields(log.Fields{
+		"logLevel":           lvl.String(),
+		"traceSampleRate":    r.traceSampleRate,
+		"shareCheckInterval": r.shareCheckInterval,
+	}).Info("Configuration applied")
+	return nil
+}
+
+// reload reads the config file and applies it.
+func (r *reloader) reload(ctx context.Context) error {
+	cfg, err := r.load()
+	if err != nil {
+		return err
+	}
+	return r.apply(ctx, cfg)
+}
+
+// run reloads the configuration every time SIGHUP is received until the context is done.
+func (r *reloader) run(ctx context.Context) {
+	sigCh := make(chan os.Signal, 1)
+	signal.Notify(sigCh, syscall.SIGHUP)
+	defer signal.Stop(sigCh)
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-sigCh:
+			log.G(ctx).Infof("Received SIGHUP, reloading configuration from %s", r.path)
+			if err := r.reload(ctx); err != nil {
+				log.G(ctx).WithError(err).Error("Failed to reload configuration")
+			}
+		}
+	}
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReloaderAppliesLogLevel(t *testing.T) {
+	logger := logrus.New()
+	logger.SetLevel(logrus.InfoLevel)
+
+	var applied time.Duration
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	require.NoError(t, os.WriteFile(path, []byte("logLevel: debug\nshareCheckInterval: 5m\n"), 0o600))
+
+	r := newReloader(path, logger)
+	r.OnShareCheckInterval(func(d time.Duration) { applied = d })
+	require.NoError(t, r.reload(context.Background()))
+
+	assert.Equal(t, logrus.DebugLevel, logger.GetLevel())
+	assert.Equal(t, 5*time.Minute, applied)
+	assert.Equal(t, 5*time.Minute, r.ShareCheckInterval())
+}
+
+func TestReloaderRejectsInvalidConfig(t *testing.T) {
+	tests := []struct {
+		name string
+		cfg  reloadableConfig
+	}{
+		{name: "Invalid log level", cfg: reloadableConfig{LogLevel: "verbose"}},
+		{name: "Invalid trace sample rate", cfg: reloadableConfig{LogLevel: "debug", TraceSampleRate: "200"}},
+		{name: "Invalid share check interval", cfg: reloadableConfig{LogLevel: "debug", ShareCheckInterval: "soon"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			logger := logrus.New()
+			logger.SetLevel(logrus.InfoLevel)
+
+			r := &reloader{logger: logger}
+			assert.Error(t, r.apply(context.Background(), tt.cfg))
+			assert.Equal(t, logrus.InfoLevel, logger.GetLevel())
+		})
+	}
+}
+
+func TestReloaderRejectsUnknownFields(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	require.NoError(t, os.WriteFile(path, []byte("logLevl: debug\n"), 0o600))
+
+	r := &reloader{path: path, logger: logrus.New()}
+	assert.Error(t, r.reload(context.Background()))
+}
@@ -6,6 +6,7 @@ import (
 	"os"
 	"strconv"
 	"strings"
+	"sync/atomic"
 
 	"github.com/virtual-kubelet/virtual-kubelet/errdefs"
 	"github.com/virtual-kubelet/virtual-kubelet/trace"
@@ -20,6 +21,37 @@ import (
 	semconv "go.opentelemetry.io/otel/semconv/v1.7.0"
 )
 
+// reloadableSampler is a sampler which delegates to a sampler that can be replaced at runtime.
+type reloadableSampler struct {
+	sampler atomic.Pointer[sdktrace.Sampler]
+}
+
+// sampler is the sampler of the tracer provider, replaced when the trace sample rate is reloaded.
+var sampler = &reloadableSampler{}
+
+// Set replaces the sampler used for the new spans.
+func (s *reloadableSampler) Set(sampler sdktrace.Sampler) {
+	s.sampler.Store(&sampler)
+}
+
+// nolint: ireturn
+func (s *reloadableSampler) current() sdktrace.Sampler {
+	if current := s.sampler.Load(); current != nil {
+		return *current
+	}
+	return sdktrace.AlwaysSample()
+}
+
+// ShouldSample returns the sampling decision of the current sampler.
+func (s *reloadableSampler) ShouldSample(parameters sdktrace.SamplingParameters) sdktrace.SamplingResult {
+	return s.current().ShouldSample(parameters)
+}
+
+// Description returns the description of the current sampler.
+func (s *reloadableSampler) Description() string {
+	return s.current().Description()
+}
+
 func initTracerProvider(ctx context.Context, service string, sampler sdktrace.Sampler, attributes ...attribute.KeyValue) error {
 	endpoint := os.Getenv("OTEL_EXPORTER_OTLP_ENDPOINT")
 	if endpoint == "" {
@@ -84,10 +116,11 @@ func determineSampler(rate string) (sdktrace.Sampler, error) {
 }
 
 func configureTracing(ctx context.Context, service string, rate string, attributes ...attribute.KeyValue) error {
-	sampler, err := determineSampler(rate)
+	s, err := determineSampler(rate)
 	if err != nil {
 		return fmt.Errorf("determining sampler: %w", err)
 	}
+	sampler.Set(s)
 
 	if err := initTracerProvider(ctx, service, sampler, attributes...); err != nil {
 		return fmt.Errorf("initializing tracer provider: %w", err)
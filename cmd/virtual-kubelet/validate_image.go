@@ -0,0 +1,51 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/agoda-com/macOS-vz-kubelet/pkg/event"
+	"github.com/agoda-com/macOS-vz-kubelet/pkg/oci"
+
+	"github.com/spf13/cobra"
+)
+
+// newValidateImageCommand creates the command validating a local image bundle before it is pushed.
+func newValidateImageCommand() *cobra.Command {
+	var path string
+	cmd := &cobra.Command{
+		Use:   "validate-image",
+		Short: "Validate the files of a local image bundle against their digests",
+		Long: "Validate the files of a local image bundle, laid out as the cached images: the config and the storage it declares, " +
+			"each along with a digest file. Every file is verified against its digest and the mismatches are reported.",
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cmd.SilenceUsage = true
+			if err := validateImage(cmd.Context(), path); err != nil {
+				return err
+			}
+			fmt.Fprintf(cmd.OutOrStdout(), "Image bundle %s is valid\n", path)
+			return nil
+		},
+	}
+	cmd.Flags().StringVar(&path, "path", path, "directory of the image bundle")
+	_ = cmd.MarkFlagRequired("path")
+	return cmd
+}
+
+// validateImage validates the image bundle in the directory.
+func validateImage(ctx context.Context, path string) (err error) {
+	store, err := oci.New(path, false, event.LogEventRecorder{})
+	if err != nil {
+		return fmt.Errorf("failed to open image bundle %s: %w", path, err)
+	}
+	defer func() {
+		err = errors.Join(err, store.Close(ctx))
+	}()
+
+	if err := store.ValidateBundle(ctx); err != nil {
+		return fmt.Errorf("image bundle %s is invalid:\n%w", path, err)
+	}
+	return nil
+}
@@ -0,0 +1,52 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/agoda-com/macOS-vz-kubelet/internal/disk"
+	"github.com/agoda-com/macOS-vz-kubelet/pkg/oci"
+
+	"github.com/opencontainers/go-digest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestValidateImageCommand(t *testing.T) {
+	dir := t.TempDir()
+	cfg := oci.NewMacOSConfig("hardware-model", "machine-id")
+	cfgData, err := json.Marshal(&cfg)
+	require.NoError(t, err)
+	for name, data := range map[string][]byte{
+		oci.MediaTypeConfigV1.Title():  cfgData,
+		oci.MediaTypeAuxImage.Title():  []byte("aux"),
+		oci.MediaTypeDiskImage.Title(): []byte("disk"),
+	} {
+		path := filepath.Join(dir, name)
+		require.NoError(t, os.WriteFile(path, data, 0o600))
+		require.NoError(t, disk.ComputeAndVerifyFileDigest(path, digest.FromBytes(data)))
+	}
+
+	execute := func() (string, error) {
+		cmd := newValidateImageCommand()
+		var out bytes.Buffer
+		cmd.SetOut(&out)
+		cmd.SetErr(&out)
+		cmd.SetArgs([]string{"--path", dir})
+		err := cmd.ExecuteContext(context.Background())
+		return out.String(), err
+	}
+
+	out, err := execute()
+	require.NoError(t, err)
+	assert.Equal(t, "Image bundle "+dir+" is valid\n", out)
+
+	require.NoError(t, os.WriteFile(filepath.Join(dir, oci.MediaTypeAuxImage.Title()), []byte("tampered"), 0o600))
+	_, err = execute()
+	require.Error(t, err)
+	assert.Equal(t, "image bundle "+dir+" is invalid:\naux.img does not match its digest "+digest.FromBytes([]byte("aux")).String()+": digest verification failed", err.Error())
+}
@@ -43,6 +43,27 @@ func (d *ContainerData) SetContainerInfo(podNamespace, podName, containerName st
 	val.(*sync.Map).Store(containerName, &info)
 }
 
+// UpdateContainerInfo atomically replaces the ContainerInfo of an existing container within a specific pod with the result of update.
+// It returns the updated ContainerInfo and a boolean indicating whether the container information was found.
+func (d *ContainerData) UpdateContainerInfo(podNamespace, podName, containerName string, update func(ContainerInfo) ContainerInfo) (ContainerInfo, bool) {
+	key := types.NamespacedName{Namespace: podNamespace, Name: podName}
+	val, ok := d.data.Load(key)
+	if !ok {
+		return ContainerInfo{}, false
+	}
+	containers := val.(*sync.Map)
+	for {
+		infoval, ok := containers.Load(containerName)
+		if !ok {
+			return ContainerInfo{}, false
+		}
+		info := update(*infoval.(*ContainerInfo))
+		if containers.CompareAndSwap(containerName, infoval, &info) {
+			return info, true
+		}
+	}
+}
+
 // RemoveAllContainerInfo removes all container information for a specific pod.
 // It returns a map of container names to ContainerInfo and a boolean indicating whether the pod information was found.
 func (d *ContainerData) RemoveAllContainerInfo(podNamespace, podName string) (map[string]ContainerInfo, bool) {
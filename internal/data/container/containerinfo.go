@@ -1,9 +1,15 @@
 package container
 
+import "github.com/agoda-com/macOS-vz-kubelet/pkg/resource"
+
 // ContainerInfo holds information about a Docker container.
 type ContainerInfo struct {
 	ID    string // ID of the container after it was created
 	Error error  // Error encountered during container pull or creation
+
+	State        resource.ContainerState  // State of the container when it was last inspected
+	RestartCount int                      // Number of restarts of the container when it was last inspected
+	LastState    *resource.ContainerState // Terminated state of the previous run of the container, if it was restarted
 }
 
 // WithID sets the ID of the ContainerInfo and returns the updated ContainerInfo.
@@ -17,3 +23,25 @@ func (i ContainerInfo) WithError(err error) ContainerInfo {
 	i.Error = err
 	return i
 }
+
+// WithObservedState records the state and restart count of the container found by an inspection and returns the updated ContainerInfo.
+// If the container was restarted since the previous inspection, the previously recorded state becomes the terminated LastState.
+func (i ContainerInfo) WithObservedState(state resource.ContainerState, restartCount int) ContainerInfo {
+	if restartCount > i.RestartCount && !i.State.StartedAt.IsZero() {
+		last := i.State
+		switch last.Status {
+		case resource.ContainerStatusOOMKilled, resource.ContainerStatusDead:
+		default:
+			// the exit of the previous run was not observed
+			last.Status = resource.ContainerStatusDead
+		}
+		if last.FinishedAt.Before(last.StartedAt) {
+			// the finish time of the previous run is kept by the new run
+			last.FinishedAt = state.FinishedAt
+		}
+		i.LastState = &last
+	}
+	i.State = state
+	i.RestartCount = restartCount
+	return i
+}
@@ -3,9 +3,12 @@ package container_test
 import (
 	"errors"
 	"testing"
+	"time"
 
 	"github.com/agoda-com/macOS-vz-kubelet/internal/data/container"
+	"github.com/agoda-com/macOS-vz-kubelet/pkg/resource"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestContainerInfo_WithID(t *testing.T) {
@@ -53,3 +56,41 @@ func TestContainerInfo_WithIDAndError(t *testing.T) {
 	assert.Equal(t, initialID, containerInfo.ID, "The original ContainerInfo should remain unchanged")
 	assert.Equal(t, initialError, containerInfo.Error, "The original ContainerInfo should remain unchanged")
 }
+
+func TestContainerInfo_WithObservedState(t *testing.T) {
+	startedAt := time.Date(2012, 12, 12, 12, 12, 12, 0, time.UTC)
+	exitedAt := startedAt.Add(time.Minute)
+	restartedAt := exitedAt.Add(time.Second)
+	running := resource.ContainerState{Status: resource.ContainerStatusRunning, StartedAt: startedAt}
+	restarted := resource.ContainerState{Status: resource.ContainerStatusRunning, StartedAt: restartedAt, FinishedAt: exitedAt}
+
+	t.Run("not restarted", func(t *testing.T) {
+		info := container.ContainerInfo{ID: "123"}.WithObservedState(running, 0).WithObservedState(running, 0)
+		assert.Equal(t, running, info.State)
+		assert.Nil(t, info.LastState)
+	})
+
+	t.Run("restarted after an observed exit", func(t *testing.T) {
+		exited := resource.ContainerState{Status: resource.ContainerStatusUnknown, StartedAt: startedAt, FinishedAt: exitedAt, ExitCode: 2}
+		info := container.ContainerInfo{ID: "123"}.WithObservedState(running, 0).WithObservedState(exited, 0).WithObservedState(restarted, 1)
+
+		assert.Equal(t, "123", info.ID)
+		assert.Equal(t, restarted, info.State)
+		assert.Equal(t, 1, info.RestartCount)
+		require.NotNil(t, info.LastState)
+		assert.Equal(t, resource.ContainerState{Status: resource.ContainerStatusDead, StartedAt: startedAt, FinishedAt: exitedAt, ExitCode: 2}, *info.LastState)
+	})
+
+	t.Run("restarted without an observed exit", func(t *testing.T) {
+		info := container.ContainerInfo{}.WithObservedState(running, 0).WithObservedState(restarted, 1)
+
+		require.NotNil(t, info.LastState)
+		assert.Equal(t, resource.ContainerState{Status: resource.ContainerStatusDead, StartedAt: startedAt, FinishedAt: exitedAt}, *info.LastState)
+	})
+
+	t.Run("restarted before the first inspection", func(t *testing.T) {
+		info := container.ContainerInfo{}.WithObservedState(restarted, 1)
+		assert.Nil(t, info.LastState)
+		assert.Equal(t, 1, info.RestartCount)
+	})
+}
@@ -9,8 +9,9 @@ import (
 
 // VirtualMachineData stores the information about macOS virtual machines
 type VirtualMachineData struct {
-	data    sync.Map // map[types.NamespacedName]VirtualMachineInfo (podNamespace/podName -> VirtualMachineInfo)
-	counter int32    // number of virtual machines stored
+	mu      sync.Mutex // serializes the modifications, so that concurrent updates and removals are never lost
+	data    sync.Map   // map[types.NamespacedName]VirtualMachineInfo (podNamespace/podName -> VirtualMachineInfo)
+	counter int32      // number of virtual machines stored
 }
 
 // GetVirtualMachineInfo retrieves the VirtualMachineInfo for a specific pod.
@@ -27,6 +28,9 @@ func (d *VirtualMachineData) GetVirtualMachineInfo(podNamespace, podName string)
 // UpdateVirtualMachineInfo updates the VirtualMachineInfo for a specific pod.
 // It returns the VirtualMachineInfo and a boolean indicating whether the virtual machine information was found.
 func (d *VirtualMachineData) UpdateVirtualMachineInfo(podNamespace, podName string, updateFunc func(VirtualMachineInfo) VirtualMachineInfo) (VirtualMachineInfo, bool) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
 	key := types.NamespacedName{Namespace: podNamespace, Name: podName}
 	val, ok := d.data.Load(key)
 	if !ok {
@@ -41,6 +45,9 @@ func (d *VirtualMachineData) UpdateVirtualMachineInfo(podNamespace, podName stri
 // or creates and stores the provided VirtualMachineInfo if it doesn't already exist.
 // It returns the VirtualMachineInfo and a boolean indicating whether the virtual machine information was already present.
 func (d *VirtualMachineData) GetOrCreateVirtualMachineInfo(podNamespace, podName string, info VirtualMachineInfo) (VirtualMachineInfo, bool) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
 	key := types.NamespacedName{Namespace: podNamespace, Name: podName}
 	val, loaded := d.data.LoadOrStore(key, &info)
 	if !loaded {
@@ -51,6 +58,9 @@ func (d *VirtualMachineData) GetOrCreateVirtualMachineInfo(podNamespace, podName
 
 // RemoveVirtualMachineInfo removes the VirtualMachineInfo for a specific pod.
 func (d *VirtualMachineData) RemoveVirtualMachineInfo(podNamespace, podName string) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
 	key := types.NamespacedName{Namespace: podNamespace, Name: podName}
 	_, loaded := d.data.LoadAndDelete(key)
 	if loaded {
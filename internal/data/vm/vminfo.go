@@ -9,6 +9,9 @@ import (
 // VirtualMachineInfo stores the information about macOS virtual machine
 type VirtualMachineInfo struct {
 	Ref                string
+	ContainerName      string // container of the pod the virtual machine runs
+	Generation         uint64 // distinguishes the creations of virtual machines reusing the same name
+	Priority           int32  // priority of the pod the virtual machine belongs to
 	Resource           resource.MacOSVirtualMachine
 	DownloadCancelFunc context.CancelFunc
 }
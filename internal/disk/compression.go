@@ -104,6 +104,18 @@ func DecompressFileWithPath(ctx context.Context, inputFilePath, outputFilePath s
 		err = errors.Join(err, inputFile.Close())
 	}()
 
+	return Decompress(ctx, inputFile, outputFilePath, uncompressedSize)
+}
+
+// Decompress uncompresses the gzip content read from r and writes the uncompressed content to the output file,
+// skipping zero chunks.
+func Decompress(ctx context.Context, r io.Reader, outputFilePath string, uncompressedSize int64) (d digest.Digest, err error) {
+	ctx, span := trace.StartSpan(ctx, "OCI.Decompress")
+	defer func() {
+		span.SetStatus(err)
+		span.End()
+	}()
+
 	// Create the output file for writing
 	outputFile, err := os.Create(outputFilePath)
 	if err != nil {
@@ -118,10 +130,14 @@ func DecompressFileWithPath(ctx context.Context, inputFilePath, outputFilePath s
 		return "", err
 	}
 
-	r, err := pgzip.NewReader(inputFile)
+	zr, err := pgzip.NewReader(r)
 	if err != nil {
 		return "", err
 	}
+	defer func() {
+		// stops the read ahead, its errors are returned by Read
+		_ = zr.Close()
+	}()
 
 	digester := digest.Canonical.Digester()
 	h := digester.Hash()
@@ -139,7 +155,7 @@ func DecompressFileWithPath(ctx context.Context, inputFilePath, outputFilePath s
 		}
 
 		// Read a chunk
-		n, err := r.Read(buf)
+		n, err := zr.Read(buf)
 		if err == io.EOF {
 			break // End of file
 		}
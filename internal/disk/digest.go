@@ -2,6 +2,7 @@ package disk
 
 import (
 	"errors"
+	"fmt"
 	"os"
 
 	"github.com/opencontainers/go-digest"
@@ -27,6 +28,19 @@ func writeDigestFile(filePath string, d digest.Digest) error {
 	return digestFile.Close()
 }
 
+// ReadDigestFile reads the digest recorded in the digest file of the file at the given path.
+func ReadDigestFile(filePath string) (digest.Digest, error) {
+	data, err := os.ReadFile(digestFilePath(filePath))
+	if err != nil {
+		return "", err
+	}
+	d, err := digest.Parse(string(data))
+	if err != nil {
+		return "", fmt.Errorf("invalid digest file: %w", err)
+	}
+	return d, nil
+}
+
 // digestFilePath returns the path to the digest file.
 func digestFilePath(filePath string) string {
 	return filePath + DigestFileSuffix
@@ -0,0 +1,34 @@
+package disk
+
+import (
+	"io/fs"
+	"os"
+	"path/filepath"
+	"syscall"
+)
+
+// AllocatedSize returns the number of bytes allocated on disk for the file, or for all the files within the directory.
+// Sparse files, such as the storage of the virtual machines, only count the blocks actually written.
+func AllocatedSize(path string) (int64, error) {
+	var size int64
+	err := filepath.WalkDir(path, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		size += allocatedSize(info)
+		return nil
+	})
+	return size, err
+}
+
+// allocatedSize returns the number of bytes allocated on disk for the file, its size if unknown.
+func allocatedSize(info os.FileInfo) int64 {
+	if st, ok := info.Sys().(*syscall.Stat_t); ok {
+		return st.Blocks * 512
+	}
+	return info.Size()
+}
@@ -0,0 +1,39 @@
+package disk_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/agoda-com/macOS-vz-kubelet/internal/disk"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAllocatedSize(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.MkdirAll(filepath.Join(dir, "nested"), 0o755))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "file"), make([]byte, 64<<10), 0o600))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "nested", "file"), make([]byte, 64<<10), 0o600))
+
+	file, err := disk.AllocatedSize(filepath.Join(dir, "file"))
+	require.NoError(t, err)
+	assert.GreaterOrEqual(t, file, int64(64<<10))
+
+	total, err := disk.AllocatedSize(dir)
+	require.NoError(t, err)
+	assert.GreaterOrEqual(t, total, 2*file)
+
+	// a sparse file only counts the blocks written
+	sparse, err := os.Create(filepath.Join(dir, "sparse"))
+	require.NoError(t, err)
+	require.NoError(t, sparse.Truncate(1<<30))
+	require.NoError(t, sparse.Close())
+	size, err := disk.AllocatedSize(sparse.Name())
+	require.NoError(t, err)
+	assert.Less(t, size, int64(1<<20))
+
+	_, err = disk.AllocatedSize(filepath.Join(dir, "missing"))
+	assert.ErrorIs(t, err, os.ErrNotExist)
+}
@@ -46,6 +46,20 @@ func ValidateFileWithDigest(ctx context.Context, filePath string, expectedDigest
 	return nil
 }
 
+// IsDigestUpToDate reports whether the file exists along with a digest file written after the file was last
+// modified, i.e. the file was verified against its digest and has not changed since.
+func IsDigestUpToDate(filePath string) bool {
+	fileInfo, err := os.Stat(filePath)
+	if err != nil {
+		return false
+	}
+	digestFileInfo, err := os.Stat(digestFilePath(filePath))
+	if err != nil {
+		return false
+	}
+	return digestFileInfo.ModTime().After(fileInfo.ModTime())
+}
+
 // ComputeAndVerifyFileDigest computes the digest of the file at the given path and verifies it against the expected digest.
 func ComputeAndVerifyFileDigest(filePath string, expectedDigest digest.Digest) error {
 	rd, err := os.Open(filePath)
@@ -119,3 +119,21 @@ func prepareFileAndDigest(t *testing.T, filePath, digestPath string, content []b
 	}
 	return d
 }
+
+func TestIsDigestUpToDate(t *testing.T) {
+	dir := t.TempDir()
+	filePath := filepath.Join(dir, "disk.img")
+	digestPath := filePath + disk.DigestFileSuffix
+	assert.False(t, disk.IsDigestUpToDate(filePath))
+
+	prepareFileAndDigest(t, filePath, digestPath, []byte("content"), false)
+	assert.False(t, disk.IsDigestUpToDate(filePath))
+
+	require.NoError(t, os.WriteFile(digestPath, []byte("sha256:abc"), 0644))
+	require.NoError(t, os.Chtimes(digestPath, time.Now(), time.Now().Add(time.Second)))
+	assert.True(t, disk.IsDigestUpToDate(filePath))
+
+	// the file changed after it was verified
+	require.NoError(t, os.Chtimes(filePath, time.Now(), time.Now().Add(2*time.Second)))
+	assert.False(t, disk.IsDigestUpToDate(filePath))
+}
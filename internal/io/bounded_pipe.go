@@ -0,0 +1,125 @@
+package io
+
+import (
+	"bytes"
+	"io"
+	"sync"
+)
+
+// boundedPipe is the buffer shared by the ends of a bounded pipe.
+type boundedPipe struct {
+	mu   sync.Mutex
+	cond *sync.Cond
+	buf  bytes.Buffer
+	size int
+
+	readErr  error // set once the reader is closed
+	writeErr error // set once the writer is closed
+}
+
+// PipeReader is the read end of a bounded pipe.
+type PipeReader struct {
+	p *boundedPipe
+}
+
+// PipeWriter is the write end of a bounded pipe.
+type PipeWriter struct {
+	p *boundedPipe
+}
+
+// NewBoundedPipe creates an in-memory pipe like io.Pipe, except that up to size bytes written to it are buffered
+// until they are read. Writes only block once the reader falls behind by size bytes, and fail once the reader
+// is closed, so that a slow or gone reader holds back the writer without the buffer growing unbounded.
+func NewBoundedPipe(size int) (*PipeReader, *PipeWriter) {
+	p := &boundedPipe{size: max(size, 1)}
+	p.cond = sync.NewCond(&p.mu)
+	return &PipeReader{p: p}, &PipeWriter{p: p}
+}
+
+// Read reads the buffered data, blocking until data is written or the writer is closed.
+// Once the writer is closed and the buffer is drained, it returns the error the writer was closed with.
+func (r *PipeReader) Read(b []byte) (int, error) {
+	p := r.p
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	for p.buf.Len() == 0 && p.readErr == nil && p.writeErr == nil {
+		p.cond.Wait()
+	}
+	if p.readErr != nil {
+		return 0, io.ErrClosedPipe
+	}
+	if p.buf.Len() == 0 {
+		return 0, p.writeErr
+	}
+	n, _ := p.buf.Read(b)
+	p.cond.Broadcast()
+	return n, nil
+}
+
+// Close closes the reader, subsequent writes fail with io.ErrClosedPipe.
+func (r *PipeReader) Close() error {
+	return r.CloseWithError(nil)
+}
+
+// CloseWithError closes the reader, subsequent writes fail with the error, io.ErrClosedPipe if nil.
+// The buffered data is discarded.
+func (r *PipeReader) CloseWithError(err error) error {
+	if err == nil {
+		err = io.ErrClosedPipe
+	}
+	p := r.p
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.readErr == nil {
+		p.readErr = err
+		p.buf.Reset()
+	}
+	p.cond.Broadcast()
+	return nil
+}
+
+// Write buffers the data, blocking while the buffer is full. It fails once the reader is closed.
+func (w *PipeWriter) Write(b []byte) (n int, err error) {
+	p := w.p
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	for len(b) > 0 {
+		for p.buf.Len() >= p.size && p.readErr == nil && p.writeErr == nil {
+			p.cond.Wait()
+		}
+		if p.readErr != nil {
+			return n, p.readErr
+		}
+		if p.writeErr != nil {
+			return n, io.ErrClosedPipe
+		}
+		chunk := min(len(b), p.size-p.buf.Len())
+		p.buf.Write(b[:chunk])
+		n += chunk
+		b = b[chunk:]
+		p.cond.Broadcast()
+	}
+	return n, nil
+}
+
+// Close closes the writer, the reader returns io.EOF once it drained the buffer.
+func (w *PipeWriter) Close() error {
+	return w.CloseWithError(nil)
+}
+
+// CloseWithError closes the writer, the reader returns the error, io.EOF if nil, once it drained the buffer.
+func (w *PipeWriter) CloseWithError(err error) error {
+	if err == nil {
+		err = io.EOF
+	}
+	p := w.p
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.writeErr == nil {
+		p.writeErr = err
+	}
+	p.cond.Broadcast()
+	return nil
+}
@@ -0,0 +1,73 @@
+package io_test
+
+import (
+	"errors"
+	stdio "io"
+	"testing"
+	"time"
+
+	"github.com/agoda-com/macOS-vz-kubelet/internal/io"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBoundedPipe(t *testing.T) {
+	t.Run("Writes block once the buffer is full", func(t *testing.T) {
+		r, w := io.NewBoundedPipe(4)
+
+		n, err := w.Write([]byte("abcd"))
+		require.NoError(t, err)
+		assert.Equal(t, 4, n)
+
+		written := make(chan struct{})
+		go func() {
+			defer close(written)
+			_, _ = w.Write([]byte("ef"))
+		}()
+		select {
+		case <-written:
+			t.Fatal("write did not block on the full buffer")
+		case <-time.After(50 * time.Millisecond):
+		}
+
+		buf := make([]byte, 2)
+		n, err = r.Read(buf)
+		require.NoError(t, err)
+		assert.Equal(t, "ab", string(buf[:n]))
+		<-written
+
+		require.NoError(t, w.Close())
+		data, err := stdio.ReadAll(r)
+		require.NoError(t, err)
+		assert.Equal(t, "cdef", string(data))
+	})
+
+	t.Run("Closing the reader unblocks the writer", func(t *testing.T) {
+		r, w := io.NewBoundedPipe(1)
+
+		errs := make(chan error, 1)
+		go func() {
+			_, err := w.Write([]byte("abc"))
+			errs <- err
+		}()
+		require.NoError(t, r.Close())
+		assert.ErrorIs(t, <-errs, stdio.ErrClosedPipe)
+
+		_, err := r.Read(make([]byte, 1))
+		assert.ErrorIs(t, err, stdio.ErrClosedPipe)
+	})
+
+	t.Run("Writer error is returned after the buffered data", func(t *testing.T) {
+		r, w := io.NewBoundedPipe(8)
+		writeErr := errors.New("stream failed")
+
+		_, err := w.Write([]byte("abc"))
+		require.NoError(t, err)
+		require.NoError(t, w.CloseWithError(writeErr))
+
+		data, err := stdio.ReadAll(r)
+		assert.ErrorIs(t, err, writeErr)
+		assert.Equal(t, "abc", string(data))
+	})
+}
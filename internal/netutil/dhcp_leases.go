@@ -0,0 +1,65 @@
+package netutil
+
+import (
+	"bufio"
+	"errors"
+	"io/fs"
+	"os"
+	"strings"
+)
+
+// DefaultDHCPLeasesPath is the path of the leases granted by the macOS DHCP server to the NAT virtual machines.
+const DefaultDHCPLeasesPath = "/var/db/dhcpd_leases"
+
+// LookupIPInDHCPLeases looks up the IP address leased to the device with the specified MAC address in the leases file.
+// The function returns an empty string if the device has no lease or no lease was granted yet.
+func LookupIPInDHCPLeases(path, macAddr string) (string, error) {
+	content, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		// the file is created along with the first lease
+		return "", nil
+	}
+	if err != nil {
+		return "", err
+	}
+	return FindIPInDHCPLeases(string(content), macAddr), nil
+}
+
+// FindIPInDHCPLeases scans the content of the leases file and returns the IP address
+// leased to the device with the specified MAC address or an empty string if it is not present.
+func FindIPInDHCPLeases(content, macAddr string) string {
+	// Example lease:
+	// {
+	//	name=vm
+	//	ip_address=192.168.64.5
+	//	hw_address=1,0:1a:2b:3c:4d:5e
+	//	identifier=1,0:1a:2b:3c:4d:5e
+	//	lease=0x66f1d2a4
+	// }
+	macAddr = NormalizeMACAddress(strings.ToLower(macAddr))
+
+	var ip, hwAddr string
+	scanner := bufio.NewScanner(strings.NewReader(content))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		switch {
+		case line == "{":
+			ip, hwAddr = "", ""
+		case line == "}":
+			if ip != "" && hwAddr == macAddr {
+				return ip
+			}
+		default:
+			key, value, _ := strings.Cut(line, "=")
+			switch key {
+			case "ip_address":
+				ip = value
+			case "hw_address":
+				// the address is prefixed with its hardware type
+				_, addr, _ := strings.Cut(value, ",")
+				hwAddr = NormalizeMACAddress(strings.ToLower(addr))
+			}
+		}
+	}
+	return ""
+}
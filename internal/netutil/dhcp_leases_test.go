@@ -0,0 +1,49 @@
+package netutil_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/agoda-com/macOS-vz-kubelet/internal/netutil"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const dhcpLeases = `{
+	name=macos-vm
+	ip_address=192.168.64.4
+	hw_address=1,3e:22:fb:b4:5d:64
+	identifier=1,3e:22:fb:b4:5d:64
+	lease=0x66f1d2a4
+}
+{
+	name=macos-vm
+	ip_address=192.168.64.5
+	hw_address=1,0:1a:2b:3c:4d:5e
+	identifier=1,0:1a:2b:3c:4d:5e
+	lease=0x66f1d2b8
+}
+`
+
+func TestFindIPInDHCPLeases(t *testing.T) {
+	assert.Equal(t, "192.168.64.5", netutil.FindIPInDHCPLeases(dhcpLeases, "00:1A:2B:3C:4D:5E"))
+	assert.Equal(t, "192.168.64.4", netutil.FindIPInDHCPLeases(dhcpLeases, "3e:22:fb:b4:5d:64"))
+	assert.Empty(t, netutil.FindIPInDHCPLeases(dhcpLeases, "0:1a:2b:3c:4d:ff"))
+	assert.Empty(t, netutil.FindIPInDHCPLeases("", "0:1a:2b:3c:4d:5e"))
+}
+
+func TestLookupIPInDHCPLeases(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "dhcpd_leases")
+
+	// no lease granted yet
+	ip, err := netutil.LookupIPInDHCPLeases(path, "0:1a:2b:3c:4d:5e")
+	require.NoError(t, err)
+	assert.Empty(t, ip)
+
+	require.NoError(t, os.WriteFile(path, []byte(dhcpLeases), 0o600))
+	ip, err = netutil.LookupIPInDHCPLeases(path, "0:1a:2b:3c:4d:5e")
+	require.NoError(t, err)
+	assert.Equal(t, "192.168.64.5", ip)
+}
@@ -11,11 +11,17 @@ import (
 
 // GetActiveInterface returns the IP address of the active network interface.
 func GetActiveInterface(ifs psnet.InterfaceStatList) (string, error) {
+	_, ip, err := GetActiveInterfaceAddress(ifs)
+	return ip, err
+}
+
+// GetActiveInterfaceAddress returns the name and IP address of the active network interface.
+func GetActiveInterfaceAddress(ifs psnet.InterfaceStatList) (name, ip string, err error) {
 	// First pass: Prefer Ethernet interfaces
 	for _, i := range ifs {
 		if isEthernet(i.Name) {
 			if ip, ok := findValidIP(i); ok {
-				return ip, nil
+				return i.Name, ip, nil
 			}
 		}
 	}
@@ -24,12 +30,26 @@ func GetActiveInterface(ifs psnet.InterfaceStatList) (string, error) {
 	for _, i := range ifs {
 		if !isEthernet(i.Name) {
 			if ip, ok := findValidIP(i); ok {
-				return ip, nil
+				return i.Name, ip, nil
 			}
 		}
 	}
 
-	return "", errdefs.NotFound("no valid IP address found")
+	return "", "", errdefs.NotFound("no valid IP address found")
+}
+
+// GetInterfaceIP returns the IP address of the network interface with the given name.
+func GetInterfaceIP(ifs psnet.InterfaceStatList, name string) (string, error) {
+	for _, i := range ifs {
+		if i.Name != name {
+			continue
+		}
+		if ip, ok := findValidIP(i); ok {
+			return ip, nil
+		}
+		return "", errdefs.NotFoundf("no valid IP address found on interface %s", name)
+	}
+	return "", errdefs.NotFoundf("network interface %s not found", name)
 }
 
 // isEthernet returns true if the interface name starts with "en"
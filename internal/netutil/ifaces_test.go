@@ -101,3 +101,60 @@ func TestGetActiveInterface(t *testing.T) {
 		})
 	}
 }
+
+func TestGetInterfaceIP(t *testing.T) {
+	interfaces := psnet.InterfaceStatList{
+		{
+			Name:  "en0",
+			Addrs: []psnet.InterfaceAddr{{Addr: "192.168.1.10/24"}},
+		},
+		{
+			Name:  "en1",
+			Addrs: []psnet.InterfaceAddr{{Addr: "fe80::1/64"}, {Addr: "10.0.0.5/24"}},
+		},
+		{
+			Name:  "lo0",
+			Addrs: []psnet.InterfaceAddr{{Addr: "127.0.0.1/8"}},
+		},
+	}
+
+	ip, err := netutil.GetInterfaceIP(interfaces, "en1")
+	assert.NoError(t, err)
+	assert.Equal(t, "10.0.0.5", ip)
+
+	_, err = netutil.GetInterfaceIP(interfaces, "lo0")
+	assert.True(t, errdefs.IsNotFound(err), err)
+
+	_, err = netutil.GetInterfaceIP(interfaces, "en2")
+	assert.True(t, errdefs.IsNotFound(err), err)
+}
+
+func TestGetActiveInterfaceAddress(t *testing.T) {
+	interfaces := psnet.InterfaceStatList{
+		{
+			Name:  "lo0",
+			Addrs: []psnet.InterfaceAddr{{Addr: "127.0.0.1/8"}},
+		},
+		{
+			Name:  "bridge0",
+			Addrs: []psnet.InterfaceAddr{{Addr: "192.168.64.1/24"}},
+		},
+		{
+			Name:  "en1",
+			Addrs: []psnet.InterfaceAddr{{Addr: "fe80::1/64"}, {Addr: "10.0.0.5/24"}},
+		},
+	}
+
+	name, ip, err := netutil.GetActiveInterfaceAddress(interfaces)
+	assert.NoError(t, err)
+	assert.Equal(t, "en1", name)
+	assert.Equal(t, "10.0.0.5", ip)
+
+	name, ip, err = netutil.GetActiveInterfaceAddress(interfaces[:2])
+	assert.NoError(t, err)
+	assert.Equal(t, "bridge0", name)
+	assert.Equal(t, "192.168.64.1", ip)
+
+	_, _, err = netutil.GetActiveInterfaceAddress(interfaces[:1])
+	assert.True(t, errdefs.IsNotFound(err), err)
+}
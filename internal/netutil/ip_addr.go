@@ -3,6 +3,7 @@ package netutil
 import (
 	"bufio"
 	"context"
+	"errors"
 	"fmt"
 	"os/exec"
 	"strings"
@@ -10,6 +11,7 @@ import (
 
 	"github.com/google/gopacket"
 	"github.com/google/gopacket/pcap"
+	"github.com/virtual-kubelet/virtual-kubelet/log"
 )
 
 // CaptureIPWithTcpDump captures the IP address of the device with the specified MAC address using tcpdump.
@@ -44,42 +46,98 @@ func CaptureIPWithTcpDump(ctx context.Context, interfaceName, macAddr string) (s
 	}
 }
 
-// RetrieveIPFromARPTable retrieves the IP address of the device with the specified MAC address from the ARP table.
-// The function executes the arp command and scans the output to find the IP address of the device.
-// The function returns the IP address of the device or an error if the operation fails.
-// The function blocks until it finds the IP address or the context is canceled.
-func RetrieveIPFromARPTable(ctx context.Context, macAddr string) (string, error) {
-	for {
+// IPLookupFunc performs a single attempt to look up an IP address.
+// It returns an empty string and no error if the IP address is not known yet.
+type IPLookupFunc func(ctx context.Context) (string, error)
+
+// PollIPAddress runs the lookup functions in order on every interval until one of them returns
+// an IP address or the context is done. Lookup errors are treated as misses and retried.
+func PollIPAddress(ctx context.Context, interval time.Duration, lookups ...IPLookupFunc) (string, error) {
+	logger := log.G(ctx)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	var lastErr error
+	for attempt := 1; ; attempt++ {
+		for _, lookup := range lookups {
+			ip, err := lookup(ctx)
+			if err != nil {
+				lastErr = err
+				logger.WithError(err).Debugf("IP address lookup attempt %d failed", attempt)
+				continue
+			}
+			if ip != "" {
+				logger.Debugf("IP address %s found after %d attempt(s)", ip, attempt)
+				return ip, nil
+			}
+		}
+		logger.Debugf("IP address not found after %d attempt(s), retrying in %s", attempt, interval)
+
 		select {
 		case <-ctx.Done():
-			return "", ctx.Err()
-		default:
-			// Execute the arp command
-			cmd := exec.Command("arp", "-an")
-			cmdOutput, err := cmd.Output()
-			if err != nil {
-				return "", err
+			if lastErr != nil {
+				return "", errors.Join(ctx.Err(), lastErr)
 			}
+			return "", ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
 
-			// Scan the command output line by line
-			scanner := bufio.NewScanner(strings.NewReader(string(cmdOutput)))
-			for scanner.Scan() {
-				line := scanner.Text()
+// BackgroundLookup runs the blocking lookup once in the background until it returns or the context is done,
+// e.g. a packet capture that would miss packets sent while it is restarted on every attempt of PollIPAddress.
+// The returned lookup reports an empty string and no error until the background lookup returns, then its result.
+func BackgroundLookup(ctx context.Context, lookup IPLookupFunc) IPLookupFunc {
+	type result struct {
+		ip  string
+		err error
+	}
+	done := make(chan result, 1)
+	go func() {
+		ip, err := lookup(ctx)
+		done <- result{ip: ip, err: err}
+	}()
 
-				// Check if the line contains the target MAC address
-				if strings.Contains(strings.ToLower(line), strings.ToLower(macAddr)) {
-					// Example arp output line: "? (192.168.1.2) at 0:1a:2b:3c:4d:5e on en0 ifscope [ethernet]"
-					// Split the line into fields and extract the IP address (field 1)
-					fields := strings.Fields(line)
-					if len(fields) > 1 {
-						ipAddress := strings.Trim(fields[1], "()")
-						return ipAddress, nil
-					}
-				}
+	var res *result
+	return func(context.Context) (string, error) {
+		if res == nil {
+			select {
+			case r := <-done:
+				res = &r
+			default:
+				return "", nil
 			}
+		}
+		return res.ip, res.err
+	}
+}
 
-			// Wait for a second before retrying
-			time.Sleep(time.Second)
+// LookupIPInARPTable looks up the IP address of the device with the specified MAC address in the ARP table.
+// The function executes the arp command once and returns an empty string if the device is not present.
+func LookupIPInARPTable(ctx context.Context, macAddr string) (string, error) {
+	cmdOutput, err := exec.CommandContext(ctx, "arp", "-an").Output()
+	if err != nil {
+		return "", err
+	}
+	return FindIPInARPOutput(string(cmdOutput), macAddr), nil
+}
+
+// FindIPInARPOutput scans the output of the arp command and returns the IP address
+// of the device with the specified MAC address or an empty string if it is not present.
+func FindIPInARPOutput(output, macAddr string) string {
+	scanner := bufio.NewScanner(strings.NewReader(output))
+	for scanner.Scan() {
+		line := scanner.Text()
+
+		// Check if the line contains the target MAC address
+		if strings.Contains(strings.ToLower(line), strings.ToLower(macAddr)) {
+			// Example arp output line: "? (192.168.1.2) at 0:1a:2b:3c:4d:5e on en0 ifscope [ethernet]"
+			// Split the line into fields and extract the IP address (field 1)
+			fields := strings.Fields(line)
+			if len(fields) > 1 {
+				return strings.Trim(fields[1], "()")
+			}
 		}
 	}
+	return ""
 }
@@ -0,0 +1,100 @@
+package netutil_test
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/agoda-com/macOS-vz-kubelet/internal/netutil"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const arpOutput = `? (192.168.64.1) at 3e:22:fb:b4:5d:64 on bridge100 ifscope permanent [bridge]
+? (192.168.64.5) at 0:1a:2b:3c:4d:5e on bridge100 ifscope [bridge]
+`
+
+func TestFindIPInARPOutput(t *testing.T) {
+	assert.Equal(t, "192.168.64.5", netutil.FindIPInARPOutput(arpOutput, "0:1A:2B:3C:4D:5E"))
+	assert.Empty(t, netutil.FindIPInARPOutput(arpOutput, "0:1a:2b:3c:4d:ff"))
+	assert.Empty(t, netutil.FindIPInARPOutput("", "0:1a:2b:3c:4d:5e"))
+}
+
+func TestPollIPAddress(t *testing.T) {
+	t.Run("ARP table populated after two empty lookups", func(t *testing.T) {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+
+		outputs := []string{"", "", arpOutput}
+		attempts := 0
+		arpLookup := func(context.Context) (string, error) {
+			output := outputs[attempts]
+			attempts++
+			return netutil.FindIPInARPOutput(output, "0:1a:2b:3c:4d:5e"), nil
+		}
+
+		ip, err := netutil.PollIPAddress(ctx, 10*time.Millisecond, arpLookup)
+		require.NoError(t, err)
+		assert.Equal(t, "192.168.64.5", ip)
+		assert.Equal(t, 3, attempts)
+	})
+
+	t.Run("First successful lookup wins", func(t *testing.T) {
+		failing := func(context.Context) (string, error) {
+			return "", errors.New("tcpdump unavailable")
+		}
+		succeeding := func(context.Context) (string, error) {
+			return "10.0.0.2", nil
+		}
+
+		ip, err := netutil.PollIPAddress(context.Background(), 10*time.Millisecond, failing, succeeding)
+		require.NoError(t, err)
+		assert.Equal(t, "10.0.0.2", ip)
+	})
+
+	t.Run("Deadline exceeded", func(t *testing.T) {
+		ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+		defer cancel()
+
+		lookupErr := errors.New("arp failed")
+		ip, err := netutil.PollIPAddress(ctx, 10*time.Millisecond, func(context.Context) (string, error) {
+			return "", lookupErr
+		})
+		assert.Empty(t, ip)
+		assert.ErrorIs(t, err, context.DeadlineExceeded)
+		assert.ErrorIs(t, err, lookupErr)
+	})
+}
+
+func TestBackgroundLookup(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	captured := make(chan string)
+	calls := atomic.Int32{}
+	capture := netutil.BackgroundLookup(ctx, func(ctx context.Context) (string, error) {
+		calls.Add(1)
+		select {
+		case ip := <-captured:
+			return ip, nil
+		case <-ctx.Done():
+			return "", ctx.Err()
+		}
+	})
+	attempts := atomic.Int32{}
+	arpLookup := func(context.Context) (string, error) {
+		if attempts.Add(1) == 3 {
+			// the packet arrives between two attempts, while the capture keeps running
+			go func() { captured <- "192.168.64.5" }()
+		}
+		return "", nil
+	}
+
+	ip, err := netutil.PollIPAddress(ctx, 10*time.Millisecond, capture, arpLookup)
+	require.NoError(t, err)
+	assert.Equal(t, "192.168.64.5", ip)
+	assert.Equal(t, int32(1), calls.Load())
+}
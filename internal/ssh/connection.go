@@ -0,0 +1,146 @@
+package ssh
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"sync/atomic"
+	"time"
+
+	"golang.org/x/crypto/ssh"
+
+	"github.com/virtual-kubelet/virtual-kubelet/log"
+	"github.com/virtual-kubelet/virtual-kubelet/node/api"
+	corev1 "k8s.io/api/core/v1"
+)
+
+// lostDetectionTimeout is how long a failed session waits for the connection to end,
+// before the failure is considered unrelated to the connection.
+const lostDetectionTimeout = time.Second
+
+// ConnectionLostError is returned when the SSH connection to the virtual machine drops in the middle of a session.
+type ConnectionLostError struct {
+	// Started is whether the command had been started when the connection dropped.
+	Started bool
+	// Err is the error of the session.
+	Err error
+}
+
+// Error implements the error interface.
+func (e *ConnectionLostError) Error() string {
+	return fmt.Sprintf("lost connection to the virtual machine: %v", e.Err)
+}
+
+// Unwrap returns the error of the session.
+func (e *ConnectionLostError) Unwrap() error {
+	return e.Err
+}
+
+// Connection is an SSH client connection whose liveness is monitored with keepalive requests.
+// A connection that ends without being closed, or stops answering keepalive requests, is lost.
+type Connection struct {
+	*ssh.Client
+
+	closed atomic.Bool   // closed by Close
+	lost   atomic.Bool   // ended without being closed by Close
+	done   chan struct{} // closed once the connection has ended
+}
+
+// NewConnection starts monitoring the client connection until it ends.
+func NewConnection(ctx context.Context, client *ssh.Client) *Connection {
+	c := &Connection{Client: client, done: make(chan struct{})}
+
+	go func() {
+		_ = client.Wait()
+		c.lost.Store(!c.closed.Load())
+		close(c.done)
+	}()
+
+	go func() {
+		if err := SendKeepalive(ctx, client, c.done, KeepaliveInterval, KeepaliveTimeout); err != nil {
+			log.G(ctx).WithError(err).Warn("SSH server stopped answering, closing the connection")
+			_ = client.Close()
+		}
+	}()
+
+	return c
+}
+
+// Close closes the connection.
+func (c *Connection) Close() error {
+	c.closed.Store(true)
+	return c.Client.Close()
+}
+
+// Done returns a channel that is closed once the connection has ended, closed or lost.
+func (c *Connection) Done() <-chan struct{} {
+	return c.done
+}
+
+// Execute runs the command in a new session of the connection, wired to the attached IO.
+// A *ConnectionLostError is returned if the connection drops before the command completes.
+func (c *Connection) Execute(ctx context.Context, attach api.AttachIO, env []corev1.EnvVar, cmd []string) error {
+	session, err := c.NewSession()
+	if err != nil {
+		return c.lostError(err, false)
+	}
+	defer func() {
+		_ = session.Close()
+	}()
+
+	// We establish stdinPipe here instead of directly assigning attach.Stdin() to the session
+	// because we need to monitor any interruptions to stdin in order to properly close the session.
+	// For example, if the interactive terminal is closed without exiting the session, the session
+	// would be left hanging.
+	stdinPipe, err := session.StdinPipe()
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = stdinPipe.Close()
+	}()
+
+	macOSSession := NewMacOSSession(session, attach, stdinPipe)
+	if err = macOSSession.SetupSessionIO(ctx); err != nil {
+		return fmt.Errorf("failed to setup session IO: %w", err)
+	}
+
+	err = macOSSession.ExecuteCommand(ctx, env, cmd)
+	return c.lostError(err, macOSSession.started)
+}
+
+// lostError returns a *ConnectionLostError wrapping err if the session failed because the connection was lost, err otherwise.
+func (c *Connection) lostError(err error, started bool) error {
+	var exitMissing *ssh.ExitMissingError
+	var netErr net.Error
+	if !errors.Is(err, io.EOF) && !errors.As(err, &exitMissing) && !errors.As(err, &netErr) {
+		// the command completed, or failed for reasons unrelated to the connection
+		return err
+	}
+
+	// the session may notice the connection end slightly before the connection itself
+	select {
+	case <-c.done:
+	case <-time.After(lostDetectionTimeout):
+		return err
+	}
+	if !c.lost.Load() {
+		return err
+	}
+	return &ConnectionLostError{Started: started, Err: err}
+}
+
+// ReportConnectionLost tells the user that the session was interrupted on the attached stderr,
+// or on stdout for TTY sessions without a separate stderr.
+func ReportConnectionLost(attach api.AttachIO) {
+	w := attach.Stderr()
+	if w == nil {
+		w = attach.Stdout()
+	}
+	if w == nil {
+		return
+	}
+	_, _ = io.WriteString(w, "\r\nerror: lost connection to the virtual machine, the session was interrupted\r\n")
+}
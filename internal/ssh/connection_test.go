@@ -0,0 +1,195 @@
+package ssh_test
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net"
+	"runtime"
+	"sync"
+	"testing"
+	"time"
+
+	vzssh "github.com/agoda-com/macOS-vz-kubelet/internal/ssh"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/virtual-kubelet/virtual-kubelet/node/api"
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/testdata"
+)
+
+// bufferCloser is a goroutine safe io.WriteCloser collecting the written bytes.
+type bufferCloser struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *bufferCloser) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *bufferCloser) Close() error { return nil }
+
+func (b *bufferCloser) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.String()
+}
+
+// execAttachIO is a non-interactive api.AttachIO.
+type execAttachIO struct {
+	stdout, stderr *bufferCloser
+}
+
+func (a *execAttachIO) Stdin() io.Reader            { return nil }
+func (a *execAttachIO) Stdout() io.WriteCloser      { return a.stdout }
+func (a *execAttachIO) Stderr() io.WriteCloser      { return a.stderr }
+func (a *execAttachIO) TTY() bool                   { return false }
+func (a *execAttachIO) Resize() <-chan api.TermSize { return nil }
+
+// startDroppingSSHServer starts an SSH server that writes some output for every command,
+// then drops the TCP connection without completing the command.
+func startDroppingSSHServer(t *testing.T) net.Listener {
+	t.Helper()
+
+	private, err := ssh.ParsePrivateKey(testdata.PEMBytes["rsa"])
+	require.NoError(t, err)
+	config := &ssh.ServerConfig{NoClientAuth: true}
+	config.AddHostKey(private)
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				_, chans, reqs, err := ssh.NewServerConn(conn, config)
+				if err != nil {
+					return
+				}
+				go ssh.DiscardRequests(reqs)
+				for newChannel := range chans {
+					channel, requests, err := newChannel.Accept()
+					if err != nil {
+						return
+					}
+					go func() {
+						for req := range requests {
+							_ = req.Reply(true, nil)
+							if req.Type == "exec" || req.Type == "shell" {
+								_, _ = channel.Write([]byte("partial output\n"))
+								time.Sleep(50 * time.Millisecond)
+								// simulate the virtual machine going away mid-session
+								_ = conn.Close()
+							}
+						}
+					}()
+				}
+			}()
+		}
+	}()
+	return listener
+}
+
+func dial(t *testing.T, addr string) *ssh.Client {
+	t.Helper()
+	client, err := vzssh.DialContext(context.Background(), "tcp", addr, &ssh.ClientConfig{
+		User:            "testuser",
+		HostKeyCallback: ssh.InsecureIgnoreHostKey(),
+		Timeout:         5 * time.Second,
+	})
+	require.NoError(t, err)
+	return client
+}
+
+func TestConnectionLostMidSession(t *testing.T) {
+	listener := startDroppingSSHServer(t)
+	baseline := runtime.NumGoroutine()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	conn := vzssh.NewConnection(ctx, dial(t, listener.Addr().String()))
+
+	attach := &execAttachIO{stdout: &bufferCloser{}, stderr: &bufferCloser{}}
+	err := conn.Execute(ctx, attach, nil, []string{"sh", "-c", "sleep 10"})
+
+	var lostErr *vzssh.ConnectionLostError
+	require.ErrorAs(t, err, &lostErr)
+	assert.True(t, lostErr.Started)
+	assert.Contains(t, err.Error(), "lost connection to the virtual machine")
+	assert.Equal(t, "partial output\n", attach.stdout.String())
+
+	vzssh.ReportConnectionLost(attach)
+	assert.Contains(t, attach.stderr.String(), "error: lost connection to the virtual machine")
+
+	// closing the lost connection is harmless, and every goroutine of the connection ends
+	_ = conn.Close()
+	require.NoError(t, listener.Close())
+	assert.Eventually(t, func() bool {
+		return runtime.NumGoroutine() <= baseline
+	}, 5*time.Second, 10*time.Millisecond, "goroutines linger after the connection was lost")
+}
+
+func TestConnectionClosed(t *testing.T) {
+	listener := startMockSSHServer(t, "127.0.0.1:0")
+	defer func() {
+		assert.NoError(t, listener.Close())
+	}()
+
+	conn := vzssh.NewConnection(context.Background(), dial(t, listener.Addr().String()))
+	require.NoError(t, conn.Close())
+
+	select {
+	case <-conn.Done():
+	case <-time.After(5 * time.Second):
+		t.Fatal("connection did not end after being closed")
+	}
+
+	// a connection closed on purpose is not lost
+	err := conn.Execute(context.Background(), &execAttachIO{stdout: &bufferCloser{}, stderr: &bufferCloser{}}, nil, []string{"true"})
+	require.Error(t, err)
+	var lostErr *vzssh.ConnectionLostError
+	assert.NotErrorAs(t, err, &lostErr)
+}
+
+func TestSendKeepaliveNotAnswered(t *testing.T) {
+	// the server never answers global requests, as a frozen guest would
+	private, err := ssh.ParsePrivateKey(testdata.PEMBytes["rsa"])
+	require.NoError(t, err)
+	config := &ssh.ServerConfig{NoClientAuth: true}
+	config.AddHostKey(private)
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer func() {
+		assert.NoError(t, listener.Close())
+	}()
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		_, _, reqs, err := ssh.NewServerConn(conn, config)
+		if err != nil {
+			return
+		}
+		for range reqs {
+			// never reply
+		}
+	}()
+
+	client := dial(t, listener.Addr().String())
+	defer func() {
+		_ = client.Close()
+	}()
+
+	err = vzssh.SendKeepalive(context.Background(), client, nil, 10*time.Millisecond, 50*time.Millisecond)
+	assert.ErrorContains(t, err, "keepalive not answered")
+}
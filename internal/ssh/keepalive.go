@@ -2,27 +2,49 @@ package ssh
 
 import (
 	"context"
+	"fmt"
 	"time"
 
-	"github.com/virtual-kubelet/virtual-kubelet/log"
 	"golang.org/x/crypto/ssh"
 )
 
-// SendKeepalive sends keepalive messages to the SSH server.
-func SendKeepalive(ctx context.Context, conn ssh.Conn) {
-	logger := log.G(ctx)
-	ticker := time.NewTicker(30 * time.Second)
+const (
+	// KeepaliveInterval is the interval between the keepalive requests sent to the SSH server.
+	KeepaliveInterval = 30 * time.Second
+	// KeepaliveTimeout is how long the SSH server has to answer a keepalive request before the connection is considered lost.
+	KeepaliveTimeout = 15 * time.Second
+)
+
+// SendKeepalive sends keepalive requests to the SSH server every interval, until ctx is done or done is closed.
+// It returns an error as soon as the server fails to answer a request within timeout.
+func SendKeepalive(ctx context.Context, conn ssh.Conn, done <-chan struct{}, interval, timeout time.Duration) error {
+	ticker := time.NewTicker(interval)
 	defer ticker.Stop()
 
 	for {
 		select {
 		case <-ticker.C:
-			_, _, err := conn.SendRequest("keepalive@openssh.com", true, nil)
-			if err != nil {
-				logger.Errorf("Failed to send keep-alive: %s", err)
+			replied := make(chan error, 1)
+			go func() {
+				// the request only returns once the server replies or the connection ends
+				_, _, err := conn.SendRequest("keepalive@openssh.com", true, nil)
+				replied <- err
+			}()
+
+			select {
+			case err := <-replied:
+				if err != nil {
+					return fmt.Errorf("failed to send keepalive: %w", err)
+				}
+			case <-time.After(timeout):
+				return fmt.Errorf("keepalive not answered within %s", timeout)
+			case <-done:
+				return nil
 			}
 		case <-ctx.Done():
-			return
+			return nil
+		case <-done:
+			return nil
 		}
 	}
 }
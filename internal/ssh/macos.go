@@ -16,16 +16,31 @@ import (
 	corev1 "k8s.io/api/core/v1"
 )
 
+// SignalSource is implemented by the attached IO of commands that accept signals, e.g. to terminate them gracefully.
+// The signals are sent to the command once it is started, until it exits.
+type SignalSource interface {
+	Signals() <-chan ssh.Signal
+}
+
 type MacOSSession struct {
 	attach    api.AttachIO
 	stdinPipe io.WriteCloser
+	started   bool // whether the command was started
 
 	*ssh.Session
 }
 
+// NewMacOSSession wraps the SSH session to execute commands with the given IO.
+// Without a TTY, the stdout and stderr of the command are written to the respective writers of attach,
+// so that programmatic execs can read a clean stdout. With a TTY, the terminal merges both into stdout.
 func NewMacOSSession(session *ssh.Session, attach api.AttachIO, stdinPipe io.WriteCloser) *MacOSSession {
-	session.Stdout = attach.Stdout()
-	session.Stderr = attach.Stderr()
+	// nil writers discard the output of their stream, rather than mixing it into the other stream
+	if stdout := attach.Stdout(); stdout != nil {
+		session.Stdout = stdout
+	}
+	if stderr := attach.Stderr(); stderr != nil {
+		session.Stderr = stderr
+	}
 
 	return &MacOSSession{
 		attach:    attach,
@@ -54,6 +69,7 @@ func (s *MacOSSession) ExecuteCommand(ctx context.Context, env []corev1.EnvVar,
 		if err := s.Session.Start(cmdStr); err != nil {
 			return err
 		}
+		s.started = true
 	} else {
 		// If TTY is not enabled, start a shell session
 		// and write the command to the stdinPipe
@@ -61,6 +77,7 @@ func (s *MacOSSession) ExecuteCommand(ctx context.Context, env []corev1.EnvVar,
 		if err := s.Session.Shell(); err != nil {
 			return err
 		}
+		s.started = true
 
 		// Prepare environment variables
 		for _, e := range env {
@@ -79,6 +96,12 @@ func (s *MacOSSession) ExecuteCommand(ctx context.Context, env []corev1.EnvVar,
 		}
 	}
 
+	if src, ok := s.attach.(SignalSource); ok {
+		stop := make(chan struct{})
+		defer close(stop)
+		go s.forwardSignals(ctx, src.Signals(), stop)
+	}
+
 	if s.attach.TTY() {
 		return s.Session.Wait()
 	}
@@ -98,6 +121,20 @@ func (s *MacOSSession) ExecuteCommand(ctx context.Context, env []corev1.EnvVar,
 	return s.Session.Wait()
 }
 
+// forwardSignals sends the signals to the command until stopped.
+func (s *MacOSSession) forwardSignals(ctx context.Context, signals <-chan ssh.Signal, stop <-chan struct{}) {
+	for {
+		select {
+		case sig := <-signals:
+			if err := s.Session.Signal(sig); err != nil {
+				log.G(ctx).WithError(err).Warnf("Failed to send signal %s to the command", sig)
+			}
+		case <-stop:
+			return
+		}
+	}
+}
+
 // setupTTYSession sets up TTY for the SSH session.
 func setupTTYSession(ctx context.Context, session *ssh.Session, stdinPipe io.WriteCloser, attach api.AttachIO, consoleSize *[2]uint) error {
 	modes := ssh.TerminalModes{
@@ -0,0 +1,151 @@
+package ssh_test
+
+import (
+	"context"
+	"net"
+	"testing"
+
+	vzssh "github.com/agoda-com/macOS-vz-kubelet/internal/ssh"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/testdata"
+)
+
+// startOutputSSHServer starts an SSH server answering every command with the given stdout and stderr.
+func startOutputSSHServer(t *testing.T, stdout, stderr string) net.Listener {
+	t.Helper()
+
+	private, err := ssh.ParsePrivateKey(testdata.PEMBytes["rsa"])
+	require.NoError(t, err)
+	config := &ssh.ServerConfig{NoClientAuth: true}
+	config.AddHostKey(private)
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				_, chans, reqs, err := ssh.NewServerConn(conn, config)
+				if err != nil {
+					return
+				}
+				go ssh.DiscardRequests(reqs)
+				for newChannel := range chans {
+					channel, requests, err := newChannel.Accept()
+					if err != nil {
+						return
+					}
+					go func() {
+						for req := range requests {
+							_ = req.Reply(true, nil)
+							if req.Type == "exec" {
+								_, _ = channel.Write([]byte(stdout))
+								_, _ = channel.Stderr().Write([]byte(stderr))
+								_, _ = channel.SendRequest("exit-status", false, ssh.Marshal(struct{ Status uint32 }{0}))
+								_ = channel.Close()
+							}
+						}
+					}()
+				}
+			}()
+		}
+	}()
+	return listener
+}
+
+func TestExecuteSeparatesStderr(t *testing.T) {
+	listener := startOutputSSHServer(t, `{"cpuUsageNanoCores": 1500}`, "warning: noise\n")
+	defer listener.Close()
+
+	conn := vzssh.NewConnection(context.Background(), dial(t, listener.Addr().String()))
+	defer conn.Close()
+
+	attach := &execAttachIO{stdout: &bufferCloser{}, stderr: &bufferCloser{}}
+	require.NoError(t, conn.Execute(context.Background(), attach, nil, []string{"sh", "-c", "stats"}))
+	assert.Equal(t, `{"cpuUsageNanoCores": 1500}`, attach.stdout.String())
+	assert.Equal(t, "warning: noise\n", attach.stderr.String())
+}
+
+// startSignalSSHServer starts an SSH server whose commands run until they receive a signal,
+// which is sent on the returned channel before the command exits with it.
+func startSignalSSHServer(t *testing.T) (net.Listener, <-chan string) {
+	t.Helper()
+
+	private, err := ssh.ParsePrivateKey(testdata.PEMBytes["rsa"])
+	require.NoError(t, err)
+	config := &ssh.ServerConfig{NoClientAuth: true}
+	config.AddHostKey(private)
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+
+	received := make(chan string, 1)
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		_, chans, reqs, err := ssh.NewServerConn(conn, config)
+		if err != nil {
+			return
+		}
+		go ssh.DiscardRequests(reqs)
+		for newChannel := range chans {
+			channel, requests, err := newChannel.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				for req := range requests {
+					_ = req.Reply(true, nil)
+					if req.Type != "signal" {
+						continue
+					}
+					var payload struct{ Signal string }
+					_ = ssh.Unmarshal(req.Payload, &payload)
+					received <- payload.Signal
+					_, _ = channel.SendRequest("exit-signal", false, ssh.Marshal(struct {
+						Signal     string
+						CoreDumped bool
+						Error      string
+						Lang       string
+					}{Signal: payload.Signal}))
+					_ = channel.Close()
+				}
+			}()
+		}
+	}()
+	return listener, received
+}
+
+// signalingAttachIO is an execAttachIO sending signals to the command.
+type signalingAttachIO struct {
+	execAttachIO
+	signals chan ssh.Signal
+}
+
+func (a *signalingAttachIO) Signals() <-chan ssh.Signal { return a.signals }
+
+func TestExecuteForwardsSignals(t *testing.T) {
+	listener, received := startSignalSSHServer(t)
+	defer listener.Close()
+
+	conn := vzssh.NewConnection(context.Background(), dial(t, listener.Addr().String()))
+	defer conn.Close()
+
+	attach := &signalingAttachIO{execAttachIO: execAttachIO{stdout: &bufferCloser{}}, signals: make(chan ssh.Signal, 1)}
+	attach.signals <- ssh.SIGTERM
+	err := conn.Execute(context.Background(), attach, nil, []string{"sh", "-c", "sleep infinity"})
+
+	var exitErr *ssh.ExitError
+	require.ErrorAs(t, err, &exitErr)
+	assert.Equal(t, "TERM", exitErr.Signal())
+	assert.Equal(t, "TERM", <-received)
+}
@@ -2,6 +2,7 @@ package utils
 
 import (
 	"fmt"
+	"io"
 	"os"
 	"path/filepath"
 
@@ -26,7 +27,7 @@ func NewFileCloner() *FileCloner {
 
 // Clonefile clones the file at the given path and returns the path of the cloned file.
 func (fc *FileCloner) Clonefile(path, pattern string) (string, error) {
-	clonedPath := filepath.Join(fc.TempDir, fc.FilenamePrefix+filepath.Base(path)+"."+pattern)
+	clonedPath := fc.path(path, pattern)
 
 	// remove the overlay storage file if it already exists
 	_ = os.Remove(clonedPath)
@@ -36,3 +37,44 @@ func (fc *FileCloner) Clonefile(path, pattern string) (string, error) {
 
 	return clonedPath, nil
 }
+
+// Copyfile copies the file at the given path in full and returns the path of the copy.
+// Unlike a clone, the copy shares no blocks with the original.
+func (fc *FileCloner) Copyfile(path, pattern string) (copiedPath string, err error) {
+	copiedPath = fc.path(path, pattern)
+
+	src, err := os.Open(path)
+	if err != nil {
+		return "", fmt.Errorf("failed to open storage file: %w", err)
+	}
+	defer src.Close()
+
+	// copy next to the destination first, so that an interrupted copy is never mistaken for a complete one
+	dst, err := os.CreateTemp(fc.TempDir, filepath.Base(copiedPath)+".*")
+	if err != nil {
+		return "", fmt.Errorf("failed to create storage copy: %w", err)
+	}
+	defer func() {
+		if err != nil {
+			_ = os.Remove(dst.Name())
+		}
+	}()
+
+	_, err = io.Copy(dst, src)
+	if closeErr := dst.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to copy storage file: %w", err)
+	}
+	if err = os.Rename(dst.Name(), copiedPath); err != nil {
+		return "", fmt.Errorf("failed to copy storage file: %w", err)
+	}
+
+	return copiedPath, nil
+}
+
+// path returns the path of the clone or copy of the file at the given path.
+func (fc *FileCloner) path(path, pattern string) string {
+	return filepath.Join(fc.TempDir, fc.FilenamePrefix+filepath.Base(path)+"."+pattern)
+}
@@ -62,6 +62,39 @@ func TestClonefile_FileExists(t *testing.T) {
 	assert.Equal(t, expectedClonedPath, clonedPath)
 }
 
+func TestCopyfile(t *testing.T) {
+	cloner := getTestFileCloner(t)
+
+	srcPath := filepath.Join(t.TempDir(), "disk.img")
+	require.NoError(t, os.WriteFile(srcPath, []byte("disk data"), 0644))
+
+	pattern := "test-pattern"
+	expectedCopiedPath := filepath.Join(cloner.TempDir, cloner.FilenamePrefix+"disk.img."+pattern)
+
+	// a previous copy is replaced
+	require.NoError(t, os.WriteFile(expectedCopiedPath, []byte("stale"), 0644))
+
+	copiedPath, err := cloner.Copyfile(srcPath, pattern)
+	require.NoError(t, err)
+	assert.Equal(t, expectedCopiedPath, copiedPath)
+
+	data, err := os.ReadFile(copiedPath)
+	require.NoError(t, err)
+	assert.Equal(t, "disk data", string(data))
+
+	entries, err := os.ReadDir(cloner.TempDir)
+	require.NoError(t, err)
+	assert.Len(t, entries, 1, "no temporary file is left behind")
+}
+
+func TestCopyfile_Failure(t *testing.T) {
+	cloner := getTestFileCloner(t)
+
+	copiedPath, err := cloner.Copyfile("/invalid/source/file", "test-pattern")
+	assert.ErrorContains(t, err, "failed to open storage file")
+	assert.Equal(t, "", copiedPath)
+}
+
 func getTestFileCloner(t *testing.T) *utils.FileCloner {
 	t.Helper()
 
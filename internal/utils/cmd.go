@@ -2,6 +2,7 @@ package utils
 
 import (
 	"fmt"
+	"slices"
 	"strconv"
 	"strings"
 
@@ -22,11 +23,14 @@ func BuildExportEnvCommand(env corev1.EnvVar) string {
 	return fmt.Sprintf("export %s=%s\n", env.Name, value)
 }
 
+// ansiCQuoteReplacer escapes the characters with a special meaning within an ANSI-C quoted $'...' string.
+var ansiCQuoteReplacer = strings.NewReplacer(`\`, `\\`, `'`, `\'`)
+
 // BuildExecCommandString returns a shell command that executes the given command in a shell.
-// The command is formatted as "sh -c $'COMMAND'" where COMMAND is the given command string.
-// If the command has arguments, they are appended to the command string.
+// The command is formatted as "sh -c $'COMMAND'" where COMMAND is the given command string,
+// or "sh -lc $'COMMAND'" for a login shell. If the command has arguments, they are appended to the command string.
 func BuildExecCommandString(cmd []string, env []corev1.EnvVar) (string, error) {
-	if len(cmd) < 3 || cmd[1] != "-c" {
+	if len(cmd) < 3 || (cmd[1] != "-c" && cmd[1] != "-lc") {
 		return "", fmt.Errorf("command is not a shell exec command")
 	}
 
@@ -37,7 +41,8 @@ func BuildExecCommandString(cmd []string, env []corev1.EnvVar) (string, error) {
 
 	// If the -c option is present, then commands are read from string.
 	cmdStr += cmd[0] + " " + cmd[1] // e.g. "sh -c"
-	cmdStr += fmt.Sprintf(" $'%s'", cmd[2])
+	// backslashes and single quotes are escaped, so that the shell receives the command string as is
+	cmdStr += fmt.Sprintf(" $'%s'", ansiCQuoteReplacer.Replace(cmd[2]))
 
 	// If there are arguments after the string, they are assigned to the positional parameters, starting with $0.
 	for i := 3; i < len(cmd); i++ {
@@ -47,3 +52,26 @@ func BuildExecCommandString(cmd []string, env []corev1.EnvVar) (string, error) {
 
 	return cmdStr, nil
 }
+
+// LoginShells are the shells exec commands can be wrapped in with WrapInLoginShell.
+var LoginShells = []string{"bash", "zsh"}
+
+// WrapInLoginShell returns a command running the given command in a login shell, e.g. "zsh -lc 'COMMAND'",
+// so that the command picks up the PATH and the environment set up by the profile of the user.
+// Every word of the command is quoted, so that it reaches the command as is. A nil command is returned as is.
+func WrapInLoginShell(shell string, cmd []string) []string {
+	if len(cmd) == 0 {
+		return cmd
+	}
+	return []string{shell, "-lc", BuildEntrypointCommand(cmd, nil)[0]}
+}
+
+// BuildEntrypointCommand returns a shell command that runs the given command followed by its arguments.
+// Every word is single-quoted, so that it reaches the command as is, without any shell expansion.
+func BuildEntrypointCommand(command, args []string) []string {
+	words := make([]string, 0, len(command)+len(args))
+	for _, word := range append(slices.Clone(command), args...) {
+		words = append(words, "'"+strings.ReplaceAll(word, "'", `'\''`)+"'")
+	}
+	return []string{strings.Join(words, " ")}
+}
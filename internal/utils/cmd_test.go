@@ -103,6 +103,20 @@ func TestBuildExecCommandString(t *testing.T) {
 			expected:    "sh -c $'echo Hello'",
 			expectError: false,
 		},
+		{
+			name:        "Command with backslashes and quotes",
+			cmd:         []string{"sh", "-c", `printf 'a\nb'`},
+			env:         []corev1.EnvVar{},
+			expected:    `sh -c $'printf \'a\\nb\''`,
+			expectError: false,
+		},
+		{
+			name:        "Login shell command with quotes",
+			cmd:         utils.WrapInLoginShell("zsh", []string{"echo", "it's"}),
+			env:         []corev1.EnvVar{},
+			expected:    `zsh -lc $'\'echo\' \'it\'\\\'\'s\''`,
+			expectError: false,
+		},
 	}
 
 	for _, tt := range tests {
@@ -118,3 +132,41 @@ func TestBuildExecCommandString(t *testing.T) {
 		})
 	}
 }
+
+func TestBuildEntrypointCommand(t *testing.T) {
+	tests := []struct {
+		name     string
+		command  []string
+		args     []string
+		expected string
+	}{
+		{
+			name:     "Command only",
+			command:  []string{"/usr/bin/true"},
+			expected: "'/usr/bin/true'",
+		},
+		{
+			name:     "Command with arguments",
+			command:  []string{"/bin/echo"},
+			args:     []string{"hello world", "$HOME"},
+			expected: "'/bin/echo' 'hello world' '$HOME'",
+		},
+		{
+			name:     "Arguments only",
+			args:     []string{"/bin/echo", "it's"},
+			expected: `'/bin/echo' 'it'\''s'`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, []string{tt.expected}, utils.BuildEntrypointCommand(tt.command, tt.args))
+		})
+	}
+}
+
+func TestWrapInLoginShell(t *testing.T) {
+	assert.Equal(t, []string{"zsh", "-lc", "'swift' '--version'"}, utils.WrapInLoginShell("zsh", []string{"swift", "--version"}))
+	assert.Equal(t, []string{"bash", "-lc", "'sh' '-c' 'echo $PATH'"}, utils.WrapInLoginShell("bash", []string{"sh", "-c", "echo $PATH"}))
+	assert.Nil(t, utils.WrapInLoginShell("zsh", nil), "attaching to the shell is not wrapped")
+}
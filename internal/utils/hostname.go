@@ -0,0 +1,44 @@
+package utils
+
+import (
+	"fmt"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+)
+
+// maxHostnameLength is the maximum length of a hostname label.
+const maxHostnameLength = 63
+
+// PodHostname returns the hostname of the pod: its spec hostname if set, its name otherwise,
+// truncated to a valid hostname label like the kubelet does.
+func PodHostname(pod *corev1.Pod) string {
+	hostname := pod.Spec.Hostname
+	if hostname == "" {
+		hostname = pod.Name
+	}
+	if len(hostname) > maxHostnameLength {
+		hostname = strings.TrimRight(hostname[:maxHostnameLength], "-.")
+	}
+	return hostname
+}
+
+// PodDomain returns the domain of the pod within the cluster, <subdomain>.<namespace>.svc,
+// or an empty string if the pod has no subdomain.
+func PodDomain(pod *corev1.Pod) string {
+	if pod.Spec.Subdomain == "" {
+		return ""
+	}
+	return fmt.Sprintf("%s.%s.svc", pod.Spec.Subdomain, pod.Namespace)
+}
+
+// BuildHostnameCommand returns a shell command that sets the hostname of the guest, fully qualified
+// with the domain if there is one, and its local (Bonjour) hostname. This will not work if sudo requires a password.
+func BuildHostnameCommand(hostname, domain string) []string {
+	fqdn := hostname
+	if domain != "" {
+		fqdn += "." + domain
+	}
+	script := fmt.Sprintf("sudo -n scutil --set HostName '%s' && sudo -n scutil --set LocalHostName '%s'", fqdn, hostname)
+	return []string{"sh", "-c", script}
+}
@@ -0,0 +1,49 @@
+package utils_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/agoda-com/macOS-vz-kubelet/internal/utils"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestPodHostname(t *testing.T) {
+	pod := &corev1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "macos-pod", Namespace: "default"}}
+	assert.Equal(t, "macos-pod", utils.PodHostname(pod))
+	assert.Empty(t, utils.PodDomain(pod))
+
+	pod.Spec.Hostname = "builder-0"
+	pod.Spec.Subdomain = "builders"
+	assert.Equal(t, "builder-0", utils.PodHostname(pod))
+	assert.Equal(t, "builders.default.svc", utils.PodDomain(pod))
+
+	pod.Spec.Hostname = ""
+	pod.Name = strings.Repeat("a", 62) + "-b"
+	assert.Equal(t, strings.Repeat("a", 62), utils.PodHostname(pod))
+}
+
+func TestBuildHostnameCommand(t *testing.T) {
+	pod := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: "macos-pod", Namespace: "default"},
+		Spec:       corev1.PodSpec{Hostname: "builder-0", Subdomain: "builders"},
+	}
+
+	cmd := utils.BuildHostnameCommand(utils.PodHostname(pod), utils.PodDomain(pod))
+	require.Len(t, cmd, 3)
+	assert.Equal(t, []string{"sh", "-c"}, cmd[:2])
+	assert.Contains(t, cmd[2], "scutil --set HostName 'builder-0.builders.default.svc'")
+	assert.Contains(t, cmd[2], "scutil --set LocalHostName 'builder-0'")
+	assert.NotContains(t, cmd[2], "macos-pod")
+
+	// must be usable as a shell exec command
+	_, err := utils.BuildExecCommandString(cmd, nil)
+	assert.NoError(t, err)
+
+	cmd = utils.BuildHostnameCommand("macos-pod", "")
+	assert.Contains(t, cmd[2], "scutil --set HostName 'macos-pod'")
+}
@@ -0,0 +1,43 @@
+package utils
+
+import (
+	"fmt"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+)
+
+const (
+	// HostAliasesBeginMarker marks the beginning of the host aliases block in the guest hosts file.
+	HostAliasesBeginMarker = "# BEGIN macOS-vz-kubelet host aliases"
+	// HostAliasesEndMarker marks the end of the host aliases block in the guest hosts file.
+	HostAliasesEndMarker = "# END macOS-vz-kubelet host aliases"
+
+	hostsFilePath = "/etc/hosts"
+)
+
+// BuildHostsFileFragment returns the hosts file block for the given host aliases, enclosed in marker comments.
+func BuildHostsFileFragment(hostAliases []corev1.HostAlias) string {
+	var sb strings.Builder
+	sb.WriteString(HostAliasesBeginMarker + "\n")
+	for _, alias := range hostAliases {
+		if alias.IP == "" || len(alias.Hostnames) == 0 {
+			continue
+		}
+		sb.WriteString(alias.IP + "\t" + strings.Join(alias.Hostnames, "\t") + "\n")
+	}
+	sb.WriteString(HostAliasesEndMarker + "\n")
+	return sb.String()
+}
+
+// BuildHostAliasesCommand returns a shell command that replaces the host aliases block in the guest hosts file.
+// The command is idempotent, any previously added block is removed before the new one is appended.
+// The heredoc delimiter is quoted so that the host aliases are written verbatim and never expanded by the shell.
+// This will not work if sudo requires a password.
+func BuildHostAliasesCommand(hostAliases []corev1.HostAlias) []string {
+	script := fmt.Sprintf(
+		"sudo -n sed -i \"\" -e \"/^%s$/,/^%s$/d\" %s && sudo -n tee -a %s > /dev/null <<'HOSTS_EOF'\n%sHOSTS_EOF",
+		HostAliasesBeginMarker, HostAliasesEndMarker, hostsFilePath, hostsFilePath, BuildHostsFileFragment(hostAliases),
+	)
+	return []string{"sh", "-c", script}
+}
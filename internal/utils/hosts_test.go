@@ -0,0 +1,82 @@
+package utils_test
+
+import (
+	"os/exec"
+	"strings"
+	"testing"
+
+	"github.com/agoda-com/macOS-vz-kubelet/internal/utils"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+)
+
+func TestBuildHostsFileFragment(t *testing.T) {
+	tests := []struct {
+		name        string
+		hostAliases []corev1.HostAlias
+		expected    string
+	}{
+		{
+			name: "Multiple aliases",
+			hostAliases: []corev1.HostAlias{
+				{IP: "127.0.0.1", Hostnames: []string{"foo.local", "bar.local"}},
+				{IP: "10.1.2.3", Hostnames: []string{"mock-api.example.com"}},
+			},
+			expected: "# BEGIN macOS-vz-kubelet host aliases\n" +
+				"127.0.0.1\tfoo.local\tbar.local\n" +
+				"10.1.2.3\tmock-api.example.com\n" +
+				"# END macOS-vz-kubelet host aliases\n",
+		},
+		{
+			name: "Incomplete aliases are skipped",
+			hostAliases: []corev1.HostAlias{
+				{IP: "127.0.0.1"},
+				{Hostnames: []string{"foo.local"}},
+			},
+			expected: "# BEGIN macOS-vz-kubelet host aliases\n" +
+				"# END macOS-vz-kubelet host aliases\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, utils.BuildHostsFileFragment(tt.hostAliases))
+		})
+	}
+}
+
+func TestBuildHostAliasesCommand(t *testing.T) {
+	hostAliases := []corev1.HostAlias{
+		{IP: "127.0.0.1", Hostnames: []string{"foo.local"}},
+	}
+
+	cmd := utils.BuildHostAliasesCommand(hostAliases)
+	require.Len(t, cmd, 3)
+	assert.Equal(t, []string{"sh", "-c"}, cmd[:2])
+	// previous block must be removed before appending the new one to keep the command idempotent
+	assert.Contains(t, cmd[2], `sed -i "" -e "/^# BEGIN macOS-vz-kubelet host aliases$/,/^# END macOS-vz-kubelet host aliases$/d" /etc/hosts`)
+	assert.Contains(t, cmd[2], utils.BuildHostsFileFragment(hostAliases))
+
+	// must be usable as a shell exec command
+	_, err := utils.BuildExecCommandString(cmd, nil)
+	assert.NoError(t, err)
+}
+
+func TestBuildHostAliasesCommand_NoShellExpansion(t *testing.T) {
+	hostAliases := []corev1.HostAlias{
+		{IP: "127.0.0.1", Hostnames: []string{"$(touch /tmp/pwned)", "`id`", "$HOME"}},
+	}
+
+	cmd := utils.BuildHostAliasesCommand(hostAliases)
+	require.Len(t, cmd, 3)
+	assert.Contains(t, cmd[2], "<<'HOSTS_EOF'\n")
+
+	// the heredoc body must be passed through verbatim
+	_, heredoc, found := strings.Cut(cmd[2], "> /dev/null ")
+	require.True(t, found)
+	out, err := exec.Command("sh", "-c", "cat "+heredoc).Output()
+	require.NoError(t, err)
+	assert.Equal(t, utils.BuildHostsFileFragment(hostAliases), string(out))
+}
@@ -0,0 +1,46 @@
+package utils
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/util/validation"
+)
+
+// invalidNodeNameChars matches the characters not allowed in node names (anything not lowercase alphanumeric, '-' or '.').
+var invalidNodeNameChars = regexp.MustCompile(`[^a-z0-9.-]+`)
+
+// ValidateNodeName checks that the name is a valid RFC 1123 subdomain, as required for k8s node names.
+func ValidateNodeName(name string) error {
+	if errs := validation.IsDNS1123Subdomain(name); len(errs) > 0 {
+		return fmt.Errorf("invalid node name %q: %s", name, strings.Join(errs, "; "))
+	}
+	return nil
+}
+
+// SanitizeNodeName converts the name (usually a hostname) into a valid RFC 1123 subdomain.
+// An error is returned if the name cannot be converted into a valid node name.
+func SanitizeNodeName(name string) (string, error) {
+	// Replace invalid characters with dashes
+	sanitized := invalidNodeNameChars.ReplaceAllString(strings.ToLower(name), "-")
+
+	// Every dot-separated label must start and end with an alphanumeric character
+	labels := strings.Split(sanitized, ".")
+	valid := labels[:0]
+	for _, label := range labels {
+		if label = strings.Trim(label, "-"); label != "" {
+			valid = append(valid, label)
+		}
+	}
+	sanitized = strings.Join(valid, ".")
+
+	if len(sanitized) > validation.DNS1123SubdomainMaxLength {
+		sanitized = strings.TrimRight(sanitized[:validation.DNS1123SubdomainMaxLength], "-.")
+	}
+
+	if err := ValidateNodeName(sanitized); err != nil {
+		return "", fmt.Errorf("unable to sanitize node name %q: %w", name, err)
+	}
+	return sanitized, nil
+}
@@ -0,0 +1,88 @@
+package utils_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/agoda-com/macOS-vz-kubelet/internal/utils"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestValidateNodeName(t *testing.T) {
+	tests := []struct {
+		name        string
+		input       string
+		expectError bool
+	}{
+		{name: "Valid hostname", input: "mac-mini-01"},
+		{name: "Valid FQDN", input: "mac-mini-01.example.com"},
+		{name: "Uppercase", input: "Mac-Mini", expectError: true},
+		{name: "Underscore", input: "mac_mini", expectError: true},
+		{name: "Empty label", input: "mac..local", expectError: true},
+		{name: "Empty", input: "", expectError: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := utils.ValidateNodeName(tt.input)
+			if tt.expectError {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestSanitizeNodeName(t *testing.T) {
+	tests := []struct {
+		name           string
+		input          string
+		expectedOutput string
+		expectError    bool
+	}{
+		{
+			name:           "Valid name is unchanged",
+			input:          "mac-mini-01.local",
+			expectedOutput: "mac-mini-01.local",
+		},
+		{
+			name:           "Uppercase is lowercased",
+			input:          "Johns-MacBook-Pro.local",
+			expectedOutput: "johns-macbook-pro.local",
+		},
+		{
+			name:           "Underscores and spaces are replaced",
+			input:          "mac_mini 01",
+			expectedOutput: "mac-mini-01",
+		},
+		{
+			name:           "Empty labels and dangling dashes are removed",
+			input:          "-mac..mini_.local.",
+			expectedOutput: "mac.mini.local",
+		},
+		{
+			name:           "Too long name is truncated",
+			input:          strings.Repeat("a", 300),
+			expectedOutput: strings.Repeat("a", 253),
+		},
+		{
+			name:        "Name without valid characters is rejected",
+			input:       "___",
+			expectError: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			output, err := utils.SanitizeNodeName(tt.input)
+			if tt.expectError {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.expectedOutput, output)
+		})
+	}
+}
@@ -2,26 +2,60 @@ package utils
 
 import (
 	"fmt"
+	"strings"
 
 	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	"k8s.io/apimachinery/pkg/util/validation"
 )
 
-func ExtractCPURequest(rl corev1.ResourceList) (uint, error) {
-	cpuSpec := rl[corev1.ResourceCPU]
+// ExtractCPURequest returns the number of CPUs requested by the resource list. The standard cpu resource
+// takes precedence, the extended resources are consulted in order only when it is not requested.
+func ExtractCPURequest(rl corev1.ResourceList, extended ...corev1.ResourceName) (uint, error) {
+	name, cpuSpec := requestedResource(rl, corev1.ResourceCPU, extended)
 	cpu, ok := cpuSpec.AsInt64()
-	if !ok {
-		return 0, fmt.Errorf("failed to parse CPU request")
+	if !ok || cpu < 0 {
+		return 0, fmt.Errorf("failed to parse CPU request %s", name)
 	}
 
 	return uint(cpu), nil
 }
 
-func ExtractMemoryRequest(rl corev1.ResourceList) (uint64, error) {
-	memorySpec := rl[corev1.ResourceMemory]
+// ExtractMemoryRequest returns the memory in bytes requested by the resource list. The standard memory resource
+// takes precedence, the extended resources are consulted in order only when it is not requested.
+func ExtractMemoryRequest(rl corev1.ResourceList, extended ...corev1.ResourceName) (uint64, error) {
+	name, memorySpec := requestedResource(rl, corev1.ResourceMemory, extended)
 	memory, ok := memorySpec.AsInt64()
-	if !ok {
-		return 0, fmt.Errorf("failed to parse memory request")
+	if !ok || memory < 0 {
+		return 0, fmt.Errorf("failed to parse memory request %s", name)
 	}
 
 	return uint64(memory), nil
 }
+
+// requestedResource returns the standard resource if it is requested, the first requested extended resource
+// otherwise. The quantity is zero if none of them is requested.
+func requestedResource(rl corev1.ResourceList, standard corev1.ResourceName, extended []corev1.ResourceName) (corev1.ResourceName, resource.Quantity) {
+	if quantity, ok := rl[standard]; ok {
+		return standard, quantity
+	}
+	for _, name := range extended {
+		if quantity, ok := rl[name]; ok {
+			return name, quantity
+		}
+	}
+	return standard, resource.Quantity{}
+}
+
+// ValidateExtendedResourceName returns an error unless the name is a valid extended resource name,
+// i.e. a domain-prefixed name outside of the kubernetes.io domain.
+func ValidateExtendedResourceName(name corev1.ResourceName) error {
+	domain, _, ok := strings.Cut(string(name), "/")
+	if !ok || domain == "kubernetes.io" || strings.HasSuffix(domain, ".kubernetes.io") {
+		return fmt.Errorf("invalid extended resource name %q: expected a domain-prefixed name outside of kubernetes.io", name)
+	}
+	if errs := validation.IsQualifiedName(string(name)); len(errs) > 0 {
+		return fmt.Errorf("invalid extended resource name %q: %s", name, strings.Join(errs, ", "))
+	}
+	return nil
+}
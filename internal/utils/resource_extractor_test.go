@@ -88,3 +88,48 @@ func TestExtractMemoryRequest(t *testing.T) {
 		})
 	}
 }
+
+func TestExtractExtendedResourceRequests(t *testing.T) {
+	const (
+		vcpu   corev1.ResourceName = "macos.agoda.com/vcpu"
+		memory corev1.ResourceName = "macos.agoda.com/memory"
+	)
+	extended := corev1.ResourceList{
+		vcpu:   resource.MustParse("4"),
+		memory: resource.MustParse("8Gi"),
+	}
+
+	// the extended resources are honored when the standard ones are absent
+	cpu, err := utils.ExtractCPURequest(extended, vcpu)
+	require.NoError(t, err)
+	assert.Equal(t, uint(4), cpu)
+	memorySize, err := utils.ExtractMemoryRequest(extended, "other.example.com/memory", memory)
+	require.NoError(t, err)
+	assert.Equal(t, uint64(8*1024*1024*1024), memorySize)
+
+	// but only when configured
+	cpu, err = utils.ExtractCPURequest(extended)
+	require.NoError(t, err)
+	assert.Zero(t, cpu)
+
+	// the standard resources take precedence
+	both := extended.DeepCopy()
+	both[corev1.ResourceCPU] = resource.MustParse("2")
+	both[corev1.ResourceMemory] = resource.MustParse("4Gi")
+	cpu, err = utils.ExtractCPURequest(both, vcpu)
+	require.NoError(t, err)
+	assert.Equal(t, uint(2), cpu)
+	memorySize, err = utils.ExtractMemoryRequest(both, memory)
+	require.NoError(t, err)
+	assert.Equal(t, uint64(4*1024*1024*1024), memorySize)
+
+	_, err = utils.ExtractCPURequest(corev1.ResourceList{vcpu: resource.MustParse("1.5")}, vcpu)
+	assert.ErrorContains(t, err, string(vcpu))
+}
+
+func TestValidateExtendedResourceName(t *testing.T) {
+	assert.NoError(t, utils.ValidateExtendedResourceName("macos.agoda.com/vcpu"))
+	for _, name := range []corev1.ResourceName{"cpu", "kubernetes.io/vcpu", "node.kubernetes.io/vcpu", "macos.agoda.com/v cpu"} {
+		assert.Error(t, utils.ValidateExtendedResourceName(name), name)
+	}
+}
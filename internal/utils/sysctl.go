@@ -0,0 +1,55 @@
+package utils
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+)
+
+// allowedSysctls are the sysctls of the guest pods may set, tuning the network stack and the file limits.
+var allowedSysctls = map[string]bool{
+	"kern.ipc.maxsockbuf":           true,
+	"kern.ipc.somaxconn":            true,
+	"kern.maxfiles":                 true,
+	"kern.maxfilesperproc":          true,
+	"net.inet.ip.portrange.first":   true,
+	"net.inet.ip.portrange.last":    true,
+	"net.inet.tcp.autorcvbufmax":    true,
+	"net.inet.tcp.autosndbufmax":    true,
+	"net.inet.tcp.delayed_ack":      true,
+	"net.inet.tcp.keepidle":         true,
+	"net.inet.tcp.keepintvl":        true,
+	"net.inet.tcp.mssdflt":          true,
+	"net.inet.tcp.msl":              true,
+	"net.inet.tcp.recvspace":        true,
+	"net.inet.tcp.sendspace":        true,
+	"net.inet.tcp.win_scale_factor": true,
+	"net.inet.udp.maxdgram":         true,
+	"net.inet.udp.recvspace":        true,
+}
+
+var sysctlValuePattern = regexp.MustCompile(`^[0-9]+$`)
+
+// ValidateSysctl checks that the sysctl may be set inside the guest, i.e. that it is allowlisted and its value is a number.
+func ValidateSysctl(sysctl corev1.Sysctl) error {
+	if !allowedSysctls[sysctl.Name] {
+		return fmt.Errorf("sysctl %s is not allowed", sysctl.Name)
+	}
+	if !sysctlValuePattern.MatchString(sysctl.Value) {
+		return fmt.Errorf("invalid value %q of sysctl %s, expected a number", sysctl.Value, sysctl.Name)
+	}
+	return nil
+}
+
+// BuildSysctlCommand returns a shell command that sets the sysctls inside the guest.
+// The sysctls must be valid, see ValidateSysctl. This will not work if sudo requires a password.
+func BuildSysctlCommand(sysctls []corev1.Sysctl) []string {
+	settings := make([]string, 0, len(sysctls))
+	for _, s := range sysctls {
+		settings = append(settings, fmt.Sprintf("'%s=%s'", s.Name, s.Value))
+	}
+	script := fmt.Sprintf("sudo -n sysctl -w %s > /dev/null", strings.Join(settings, " "))
+	return []string{"sh", "-c", script}
+}
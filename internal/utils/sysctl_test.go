@@ -0,0 +1,35 @@
+package utils_test
+
+import (
+	"testing"
+
+	"github.com/agoda-com/macOS-vz-kubelet/internal/utils"
+
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+)
+
+func TestValidateSysctl(t *testing.T) {
+	for _, sysctl := range []corev1.Sysctl{
+		{Name: "net.inet.tcp.delayed_ack", Value: "0"},
+		{Name: "kern.ipc.somaxconn", Value: "1024"},
+	} {
+		assert.NoError(t, utils.ValidateSysctl(sysctl), sysctl.Name)
+	}
+	for _, sysctl := range []corev1.Sysctl{
+		{Name: "kern.securelevel", Value: "0"},
+		{Name: "net.ipv4.tcp_syncookies", Value: "1"},
+		{Name: "net.inet.tcp.delayed_ack", Value: ""},
+		{Name: "net.inet.tcp.delayed_ack", Value: "0'; reboot; '"},
+	} {
+		assert.Error(t, utils.ValidateSysctl(sysctl), sysctl.Name)
+	}
+}
+
+func TestBuildSysctlCommand(t *testing.T) {
+	cmd := utils.BuildSysctlCommand([]corev1.Sysctl{
+		{Name: "net.inet.tcp.delayed_ack", Value: "0"},
+		{Name: "kern.ipc.somaxconn", Value: "1024"},
+	})
+	assert.Equal(t, []string{"sh", "-c", "sudo -n sysctl -w 'net.inet.tcp.delayed_ack=0' 'kern.ipc.somaxconn=1024' > /dev/null"}, cmd)
+}
@@ -0,0 +1,28 @@
+package utils
+
+import (
+	"fmt"
+	"regexp"
+	"time"
+)
+
+// timezonePattern matches the characters of the names in the tz database.
+var timezonePattern = regexp.MustCompile(`^[A-Za-z0-9_+\-]+(/[A-Za-z0-9_+\-]+)*$`)
+
+// ValidateTimezone checks that the timezone is a name of the tz database, e.g. Asia/Bangkok.
+func ValidateTimezone(timezone string) error {
+	if !timezonePattern.MatchString(timezone) || timezone == "Local" {
+		return fmt.Errorf("invalid timezone %q, expected a name of the tz database", timezone)
+	}
+	if _, err := time.LoadLocation(timezone); err != nil {
+		return fmt.Errorf("unknown timezone %q: %w", timezone, err)
+	}
+	return nil
+}
+
+// BuildTimezoneCommand returns a shell command that sets the timezone of the guest.
+// The timezone must be valid, see ValidateTimezone. This will not work if sudo requires a password.
+func BuildTimezoneCommand(timezone string) []string {
+	script := fmt.Sprintf("sudo -n systemsetup -settimezone '%s' > /dev/null", timezone)
+	return []string{"sh", "-c", script}
+}
@@ -0,0 +1,23 @@
+package utils_test
+
+import (
+	"testing"
+
+	"github.com/agoda-com/macOS-vz-kubelet/internal/utils"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidateTimezone(t *testing.T) {
+	for _, timezone := range []string{"Asia/Bangkok", "America/Argentina/Buenos_Aires", "UTC", "Etc/GMT+7"} {
+		assert.NoError(t, utils.ValidateTimezone(timezone), timezone)
+	}
+	for _, timezone := range []string{"", "Local", "Mars/Olympus_Mons", "Asia/Bangkok'; reboot; '", "../etc/passwd", "/Asia/Bangkok"} {
+		assert.Error(t, utils.ValidateTimezone(timezone), timezone)
+	}
+}
+
+func TestBuildTimezoneCommand(t *testing.T) {
+	cmd := utils.BuildTimezoneCommand("Asia/Bangkok")
+	assert.Equal(t, []string{"sh", "-c", "sudo -n systemsetup -settimezone 'Asia/Bangkok' > /dev/null"}, cmd)
+}
@@ -0,0 +1,63 @@
+package utils
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"strings"
+
+	"github.com/virtual-kubelet/virtual-kubelet/log"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// PodAnnotationFieldPrefix prefixes the span fields of the pod annotations propagated into the traces.
+const PodAnnotationFieldPrefix = "pod.annotation."
+
+// traceFieldsKey is the context key of the fields set on the spans of a request.
+type traceFieldsKey struct{}
+
+// ParseTraceAttributes parses the list of key=value pairs into trace resource attributes.
+func ParseTraceAttributes(pairs []string) ([]attribute.KeyValue, error) {
+	attributes := make([]attribute.KeyValue, 0, len(pairs))
+	for _, pair := range pairs {
+		key, value, ok := strings.Cut(pair, "=")
+		key = strings.TrimSpace(key)
+		if !ok || key == "" {
+			return nil, fmt.Errorf("invalid trace attribute %q: expected key=value format", pair)
+		}
+		attributes = append(attributes, attribute.String(key, value))
+	}
+	return attributes, nil
+}
+
+// PodAnnotationFields returns the annotations with the keys as span fields named with PodAnnotationFieldPrefix,
+// skipping the keys the annotations do not have.
+func PodAnnotationFields(annotations map[string]string, keys []string) log.Fields {
+	fields := log.Fields{}
+	for _, key := range keys {
+		if value, ok := annotations[key]; ok {
+			fields[PodAnnotationFieldPrefix+key] = value
+		}
+	}
+	return fields
+}
+
+// WithTraceFields returns a context carrying the fields along with those the context already carries,
+// so that the spans started from it can be tagged with them for correlation.
+func WithTraceFields(ctx context.Context, fields log.Fields) context.Context {
+	if len(fields) == 0 {
+		return ctx
+	}
+	carried := maps.Clone(TraceFields(ctx))
+	if carried == nil {
+		carried = log.Fields{}
+	}
+	maps.Copy(carried, fields)
+	return context.WithValue(ctx, traceFieldsKey{}, carried)
+}
+
+// TraceFields returns the fields the context carries for the spans started from it.
+func TraceFields(ctx context.Context) log.Fields {
+	fields, _ := ctx.Value(traceFieldsKey{}).(log.Fields)
+	return fields
+}
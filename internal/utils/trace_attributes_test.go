@@ -0,0 +1,74 @@
+package utils_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/agoda-com/macOS-vz-kubelet/internal/utils"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/virtual-kubelet/virtual-kubelet/log"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+func TestParseTraceAttributes(t *testing.T) {
+	tests := []struct {
+		name        string
+		pairs       []string
+		expected    []attribute.KeyValue
+		expectError bool
+	}{
+		{
+			name:     "No attributes",
+			expected: []attribute.KeyValue{},
+		},
+		{
+			name:  "Multiple attributes",
+			pairs: []string{"cluster=mac-fleet", "region=ap-southeast-1", "note=a=b", "empty="},
+			expected: []attribute.KeyValue{
+				attribute.String("cluster", "mac-fleet"),
+				attribute.String("region", "ap-southeast-1"),
+				attribute.String("note", "a=b"),
+				attribute.String("empty", ""),
+			},
+		},
+		{
+			name:        "Missing separator",
+			pairs:       []string{"cluster"},
+			expectError: true,
+		},
+		{
+			name:        "Missing key",
+			pairs:       []string{"=value"},
+			expectError: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			attributes, err := utils.ParseTraceAttributes(tt.pairs)
+			if tt.expectError {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, attributes)
+		})
+	}
+}
+
+func TestTraceFields(t *testing.T) {
+	annotations := map[string]string{"ci.example.com/build-id": "1234", "other": "value"}
+	fields := utils.PodAnnotationFields(annotations, []string{"ci.example.com/build-id", "ci.example.com/job"})
+	assert.Equal(t, log.Fields{"pod.annotation.ci.example.com/build-id": "1234"}, fields)
+
+	ctx := context.Background()
+	assert.Empty(t, utils.TraceFields(ctx))
+	assert.Equal(t, ctx, utils.WithTraceFields(ctx, log.Fields{}))
+
+	ctx = utils.WithTraceFields(ctx, fields)
+	nested := utils.WithTraceFields(ctx, log.Fields{"step": "download"})
+	assert.Equal(t, fields, utils.TraceFields(ctx))
+	assert.Equal(t, log.Fields{"pod.annotation.ci.example.com/build-id": "1234", "step": "download"}, utils.TraceFields(nested))
+}
@@ -0,0 +1,124 @@
+package volumes
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/virtual-kubelet/virtual-kubelet/log"
+	corev1 "k8s.io/api/core/v1"
+)
+
+const (
+	// DefaultMemoryVolumeSize is the size of the memory-backed emptyDir volumes without a size limit,
+	// matching the default shared memory size of docker containers.
+	DefaultMemoryVolumeSize int64 = 64 << 20
+
+	// ramDiskSectorSize is the size of the sectors of the RAM disks.
+	ramDiskSectorSize = 512
+	// ramDiskSuffix is the suffix of the files next to the volumes, holding the device of their RAM disk.
+	ramDiskSuffix = ".ramdisk"
+)
+
+// IsMemoryVolume reports whether the volume is an emptyDir backed by memory.
+func IsMemoryVolume(source *corev1.VolumeSource) bool {
+	return source.EmptyDir != nil && source.EmptyDir.Medium == corev1.StorageMediumMemory
+}
+
+// MemoryVolumeSize returns the size of the RAM disk of the memory-backed emptyDir, its size limit if set.
+func MemoryVolumeSize(emptyDir *corev1.EmptyDirVolumeSource) int64 {
+	if emptyDir.SizeLimit != nil && !emptyDir.SizeLimit.IsZero() {
+		return emptyDir.SizeLimit.Value()
+	}
+	return DefaultMemoryVolumeSize
+}
+
+// CreateMemoryVolumes mounts a RAM disk on the host for every memory-backed emptyDir volume of the pod,
+// sized after the size limit of the volume, so that the volumes are shared with all the containers of the pod.
+// The RAM disks must be removed with RemoveMemoryVolumes before the pod volume root is removed.
+func CreateMemoryVolumes(ctx context.Context, podVolRoot string, pod *corev1.Pod) error {
+	for _, volume := range pod.Spec.Volumes {
+		if !IsMemoryVolume(&volume.VolumeSource) {
+			continue
+		}
+		path := filepath.Join(podVolRoot, volume.Name)
+		if err := os.MkdirAll(path, PodVolPerms); err != nil {
+			return fmt.Errorf("error making emptyDir for path %s: %w", path, err)
+		}
+		if err := attachRAMDisk(ctx, path, MemoryVolumeSize(volume.EmptyDir)); err != nil {
+			return fmt.Errorf("error mounting memory-backed emptyDir %s: %w", volume.Name, err)
+		}
+	}
+	return nil
+}
+
+// RemoveMemoryVolumes unmounts and releases the RAM disks of the memory-backed emptyDir volumes within the pod volume root.
+func RemoveMemoryVolumes(ctx context.Context, podVolRoot string) error {
+	entries, err := os.ReadDir(podVolRoot)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	var errs []error
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.HasSuffix(entry.Name(), ramDiskSuffix) {
+			continue
+		}
+		marker := filepath.Join(podVolRoot, entry.Name())
+		device, err := os.ReadFile(marker)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		if err := detachRAMDisk(ctx, strings.TrimSpace(string(device))); err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		if err := os.Remove(marker); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// attachRAMDisk creates a RAM disk of the given size, formats it and mounts it at path.
+// The device of the RAM disk is recorded next to path, so that it can be released once unused.
+func attachRAMDisk(ctx context.Context, path string, size int64) (err error) {
+	sectors := (size + ramDiskSectorSize - 1) / ramDiskSectorSize
+	out, err := exec.CommandContext(ctx, "hdiutil", "attach", "-nomount", "ram://"+strconv.FormatInt(sectors, 10)).Output()
+	if err != nil {
+		return fmt.Errorf("failed to create RAM disk: %w", err)
+	}
+	device := strings.TrimSpace(string(out))
+	defer func() {
+		if err != nil {
+			_ = detachRAMDisk(context.WithoutCancel(ctx), device)
+		}
+	}()
+
+	if out, err := exec.CommandContext(ctx, "newfs_hfs", "-v", filepath.Base(path), device).CombinedOutput(); err != nil {
+		return fmt.Errorf("failed to format RAM disk %s: %w: %s", device, err, out)
+	}
+	if out, err := exec.CommandContext(ctx, "diskutil", "mount", "-mountPoint", path, device).CombinedOutput(); err != nil {
+		return fmt.Errorf("failed to mount RAM disk %s: %w: %s", device, err, out)
+	}
+	log.G(ctx).Debugf("Mounted RAM disk %s of %d bytes at %s", device, size, path)
+
+	return os.WriteFile(path+ramDiskSuffix, []byte(device), PodVolPerms)
+}
+
+// detachRAMDisk unmounts the RAM disk and releases its memory.
+func detachRAMDisk(ctx context.Context, device string) error {
+	if out, err := exec.CommandContext(ctx, "hdiutil", "detach", "-force", device).CombinedOutput(); err != nil {
+		return fmt.Errorf("failed to detach RAM disk %s: %w: %s", device, err, out)
+	}
+	return nil
+}
@@ -21,6 +21,10 @@ type Mount struct {
 	HostPath      string
 	ContainerPath string
 	ReadOnly      bool
+	// EmptyDir is set for the emptyDir volumes, the disk-backed ones count towards the ephemeral storage of the pod.
+	EmptyDir bool
+	// Memory is set for the memory-backed emptyDir volumes, whose host path is a RAM disk, see CreateMemoryVolumes.
+	Memory bool
 }
 
 // CreateContainerMounts creates the mounts for a container based on the pod spec.
@@ -48,13 +52,19 @@ func CreateContainerMounts(ctx context.Context, podVolRoot string, container cor
 			}
 			newMount.HostPath = podVolSpec.HostPath.Path
 		} else if podVolSpec.EmptyDir != nil {
-			// TODO: Currently ignores the SizeLimit
+			// TODO: Currently ignores the SizeLimit of disk-backed volumes
+			newMount.EmptyDir = true
+			newMount.Memory = IsMemoryVolume(podVolSpec)
 			newMount.HostPath = filepath.Join(podVolRoot, mountSpec.Name)
 			err := os.MkdirAll(newMount.HostPath, PodVolPerms)
 			if err != nil {
 				return nil, fmt.Errorf("error making emptyDir for path %s: %w", newMount.HostPath, err)
 			}
 		} else if podVolSpec.Projected != nil {
+			// Projected content (service account tokens, config maps, downward API) originates
+			// from the host, so the guest must never be able to overwrite it regardless of
+			// the readOnly setting on the volume mount.
+			newMount.ReadOnly = true
 			newMount.HostPath = filepath.Join(podVolRoot, mountSpec.Name)
 			err := os.MkdirAll(newMount.HostPath, PodVolPerms)
 			if err != nil {
@@ -73,6 +83,12 @@ func CreateContainerMounts(ctx context.Context, podVolRoot string, container cor
 						return nil, fmt.Errorf("config map %s not found", source.ConfigMap.Name)
 					}
 
+					if len(source.ConfigMap.Items) == 0 {
+						// When no items are specified, every key is projected into its own file
+						if err := writeConfigMapKeys(newMount.HostPath, configMap); err != nil {
+							return nil, err
+						}
+					}
 					for _, keyToPath := range source.ConfigMap.Items {
 						value := configMap.Data[keyToPath.Key]
 						mode := PodVolPerms
@@ -102,6 +118,9 @@ func CreateContainerMounts(ctx context.Context, podVolRoot string, container cor
 				}
 			}
 		} else {
+			if IsSkippedVolume(podVolSpec) {
+				log.G(ctx).Warnf("Skipping volume %s of container %s, configMap, secret and downwardAPI volumes are not supported", mountSpec.Name, container.Name)
+			}
 			continue
 		}
 		mounts = append(mounts, newMount)
@@ -110,6 +129,27 @@ func CreateContainerMounts(ctx context.Context, podVolRoot string, container cor
 	return mounts, nil
 }
 
+// IsSkippedVolume reports whether the volume is a configMap, secret or downwardAPI volume,
+// which is not mounted into the container but does not prevent the pod from running.
+func IsSkippedVolume(source *corev1.VolumeSource) bool {
+	return source.ConfigMap != nil || source.Secret != nil || source.DownwardAPI != nil
+}
+
+// writeConfigMapKeys writes all data and binary data keys of the config map to the given directory.
+func writeConfigMapKeys(dir string, configMap *corev1.ConfigMap) error {
+	for key, value := range configMap.Data {
+		if err := os.WriteFile(filepath.Join(dir, key), []byte(value), PodVolPerms); err != nil {
+			return fmt.Errorf("error writing config map: %w", err)
+		}
+	}
+	for key, value := range configMap.BinaryData {
+		if err := os.WriteFile(filepath.Join(dir, key), value, PodVolPerms); err != nil {
+			return fmt.Errorf("error writing config map: %w", err)
+		}
+	}
+	return nil
+}
+
 // findPodVolumeSpec searches for a particular volume spec by name in the Pod spec
 func findPodVolumeSpec(pod *corev1.Pod, name string) *corev1.VolumeSource {
 	for _, volume := range pod.Spec.Volumes {
@@ -2,6 +2,7 @@ package volumes_test
 
 import (
 	"context"
+	"os"
 	"path/filepath"
 	"testing"
 
@@ -10,6 +11,7 @@ import (
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
 
@@ -85,10 +87,44 @@ func TestCreateContainerMounts(t *testing.T) {
 					Name:          "emptydir-volume",
 					HostPath:      filepath.Join(tempDir, "emptydir-volume"),
 					ContainerPath: "/mnt/emptydir",
+					EmptyDir:      true,
 					ReadOnly:      false,
 				},
 			},
 		},
+		{
+			name: "Memory-backed EmptyDir volume",
+			container: corev1.Container{
+				VolumeMounts: []corev1.VolumeMount{
+					{
+						Name:      "shm",
+						MountPath: "/dev/shm",
+					},
+				},
+			},
+			pod: &corev1.Pod{
+				Spec: corev1.PodSpec{
+					Volumes: []corev1.Volume{
+						{
+							Name: "shm",
+							VolumeSource: corev1.VolumeSource{
+								EmptyDir: &corev1.EmptyDirVolumeSource{Medium: corev1.StorageMediumMemory},
+							},
+						},
+					},
+				},
+			},
+			expectedMounts: []volumes.Mount{
+				{
+					Name:          "shm",
+					HostPath:      filepath.Join(tempDir, "shm"),
+					ContainerPath: "/dev/shm",
+					EmptyDir:      true,
+					ReadOnly:      false,
+					Memory:        true,
+				},
+			},
+		},
 		{
 			name: "Projected volume with ServiceAccountToken",
 			container: corev1.Container{
@@ -128,7 +164,7 @@ func TestCreateContainerMounts(t *testing.T) {
 					Name:          "projected-volume",
 					HostPath:      filepath.Join(tempDir, "projected-volume"),
 					ContainerPath: "/mnt/projected",
-					ReadOnly:      false,
+					ReadOnly:      true,
 				},
 			},
 		},
@@ -182,7 +218,7 @@ func TestCreateContainerMounts(t *testing.T) {
 					Name:          "configmap-volume",
 					HostPath:      filepath.Join(tempDir, "configmap-volume"),
 					ContainerPath: "/mnt/configmap",
-					ReadOnly:      false,
+					ReadOnly:      true,
 				},
 			},
 		},
@@ -234,7 +270,7 @@ func TestCreateContainerMounts(t *testing.T) {
 					Name:          "downwardapi-volume",
 					HostPath:      filepath.Join(tempDir, "downwardapi-volume"),
 					ContainerPath: "/mnt/downwardapi",
-					ReadOnly:      false,
+					ReadOnly:      true,
 				},
 			},
 		},
@@ -296,3 +332,111 @@ func TestCreateContainerMounts(t *testing.T) {
 		})
 	}
 }
+
+func TestCreateContainerMountsProjectedReadOnly(t *testing.T) {
+	tempDir := t.TempDir()
+
+	container := corev1.Container{
+		VolumeMounts: []corev1.VolumeMount{
+			{Name: "token", MountPath: "/var/run/secrets/kubernetes.io/serviceaccount", ReadOnly: false},
+			{Name: "data", MountPath: "/mnt/data", ReadOnly: false},
+		},
+	}
+	pod := &corev1.Pod{
+		Spec: corev1.PodSpec{
+			Volumes: []corev1.Volume{
+				{
+					Name: "token",
+					VolumeSource: corev1.VolumeSource{
+						Projected: &corev1.ProjectedVolumeSource{
+							Sources: []corev1.VolumeProjection{
+								{ServiceAccountToken: &corev1.ServiceAccountTokenProjection{Path: "token"}},
+							},
+						},
+					},
+				},
+				{
+					Name:         "data",
+					VolumeSource: corev1.VolumeSource{EmptyDir: &corev1.EmptyDirVolumeSource{}},
+				},
+			},
+		},
+	}
+
+	mounts, err := volumes.CreateContainerMounts(context.Background(), tempDir, container, pod, "test-token", nil)
+	require.NoError(t, err)
+	require.Len(t, mounts, 2)
+
+	assert.True(t, mounts[0].ReadOnly, "token share must be read-only even if the volume mount is not")
+	assert.False(t, mounts[1].ReadOnly, "emptyDir share should follow the volume mount setting")
+
+	token, err := os.ReadFile(filepath.Join(mounts[0].HostPath, "token"))
+	require.NoError(t, err)
+	assert.Equal(t, "test-token", string(token))
+}
+
+func TestCreateContainerMountsConfigMapWithoutItems(t *testing.T) {
+	tempDir := t.TempDir()
+
+	container := corev1.Container{
+		VolumeMounts: []corev1.VolumeMount{
+			{Name: "configmap-volume", MountPath: "/mnt/configmap"},
+		},
+	}
+	pod := &corev1.Pod{
+		Spec: corev1.PodSpec{
+			Volumes: []corev1.Volume{
+				{
+					Name: "configmap-volume",
+					VolumeSource: corev1.VolumeSource{
+						Projected: &corev1.ProjectedVolumeSource{
+							Sources: []corev1.VolumeProjection{
+								{
+									ConfigMap: &corev1.ConfigMapProjection{
+										LocalObjectReference: corev1.LocalObjectReference{Name: "test-configmap"},
+									},
+								},
+							},
+						},
+					},
+				},
+			},
+		},
+	}
+	configMaps := map[string]*corev1.ConfigMap{
+		"test-configmap": {
+			Data: map[string]string{
+				"first-key":  "first-value",
+				"second-key": "second-value",
+			},
+			BinaryData: map[string][]byte{
+				"binary-key": {0x00, 0x01},
+			},
+		},
+	}
+
+	mounts, err := volumes.CreateContainerMounts(context.Background(), tempDir, container, pod, "", configMaps)
+	require.NoError(t, err)
+	require.Len(t, mounts, 1)
+
+	for key, expected := range map[string][]byte{
+		"first-key":  []byte("first-value"),
+		"second-key": []byte("second-value"),
+		"binary-key": {0x00, 0x01},
+	} {
+		path := filepath.Join(mounts[0].HostPath, key)
+		content, err := os.ReadFile(path)
+		require.NoError(t, err)
+		assert.Equal(t, expected, content)
+
+		info, err := os.Stat(path)
+		require.NoError(t, err)
+		assert.Equal(t, volumes.PodVolPerms, info.Mode().Perm())
+	}
+}
+
+func TestMemoryVolumeSize(t *testing.T) {
+	limit := resource.MustParse("256Mi")
+	assert.Equal(t, int64(256<<20), volumes.MemoryVolumeSize(&corev1.EmptyDirVolumeSource{Medium: corev1.StorageMediumMemory, SizeLimit: &limit}))
+	assert.Equal(t, volumes.DefaultMemoryVolumeSize, volumes.MemoryVolumeSize(&corev1.EmptyDirVolumeSource{Medium: corev1.StorageMediumMemory}))
+}
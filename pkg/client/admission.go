@@ -0,0 +1,156 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/agoda-com/macOS-vz-kubelet/internal/utils"
+	"github.com/agoda-com/macOS-vz-kubelet/internal/volumes"
+	"github.com/agoda-com/macOS-vz-kubelet/pkg/downloader"
+	rm "github.com/agoda-com/macOS-vz-kubelet/pkg/resourcemanager"
+	"github.com/agoda-com/macOS-vz-kubelet/pkg/vm"
+	"github.com/agoda-com/macOS-vz-kubelet/pkg/vm/config"
+
+	"github.com/distribution/reference"
+	"github.com/virtual-kubelet/virtual-kubelet/errdefs"
+	"github.com/virtual-kubelet/virtual-kubelet/trace"
+	corev1 "k8s.io/api/core/v1"
+)
+
+// ValidatePod checks whether the pod would be admitted, without creating anything.
+// It returns an invalid input error listing every problem found, or nil if the pod is admittable.
+func (c *VzClientAPIs) ValidatePod(ctx context.Context, pod *corev1.Pod) (err error) {
+	_, span := trace.StartSpan(ctx, "VZClient.ValidatePod")
+	defer func() {
+		span.SetStatus(err)
+		span.End()
+	}()
+
+	if macOSContainers, err := ParseMacOSContainers(pod); err == nil {
+		pod = ApplyDefaultImage(pod, macOSContainers, c.MacOSClient.DefaultImage())
+	}
+	problems := AdmissionProblems(pod, c.ContainerClient() != nil, c.MacOSClient.ExtendedResourceNames())
+	if len(problems) == 0 {
+		return nil
+	}
+	return errdefs.InvalidInputf("pod %s/%s cannot be admitted: %s", pod.Namespace, pod.Name, strings.Join(problems, "; "))
+}
+
+// AdmissionProblems returns the reasons the pod cannot be admitted, none if it can.
+// The default image must already be applied to the macOS containers. Regular containers are only admitted if the container runtime is available.
+// The CPU and memory of the macOS containers are read from the extended resources when the standard resources are not requested.
+func AdmissionProblems(pod *corev1.Pod, containerRuntimeAvailable bool, extended rm.ExtendedResourceNames) []string {
+	var problems []string
+	add := func(format string, args ...any) {
+		problems = append(problems, fmt.Sprintf(format, args...))
+	}
+
+	if len(pod.Spec.Containers) == 0 {
+		return []string{"pod has no containers"}
+	}
+
+	macOSContainers, err := ParseMacOSContainers(pod)
+	if err != nil {
+		add("%s", err)
+		// the first container always runs as a macOS virtual machine
+		macOSContainers = []string{pod.Spec.Containers[0].Name}
+	}
+	if _, err := ParseStopOrder(pod); err != nil {
+		add("%s", err)
+	}
+	if _, err := ParseDeviceOptions(pod, config.DeviceOptions{}); err != nil {
+		add("%s", err)
+	}
+	if _, err := ParseDiskMode(pod, config.DiskModeOverlay); err != nil {
+		add("%s", err)
+	}
+	if _, err := ParseTimezone(pod); err != nil {
+		add("%s", err)
+	}
+	if _, err := ParseExecShell(pod); err != nil {
+		add("%s", err)
+	}
+	if _, err := ParseSnapshot(pod); err != nil {
+		add("%s", err)
+	}
+	if _, err := ParseScratchDiskSize(pod); err != nil {
+		add("%s", err)
+	}
+	if _, err := ParseAgentHealth(pod); err != nil {
+		add("%s", err)
+	}
+	if _, err := ParseRetainFailedVMs(pod, false); err != nil {
+		add("%s", err)
+	}
+	if len(pod.Spec.Containers) > len(macOSContainers) && !containerRuntimeAvailable {
+		add("regular containers are not supported")
+	}
+
+	// an invalid scratch disk size is reported above
+	_, scratchDisk := pod.Annotations[ScratchDiskSizeAnnotation]
+	for _, container := range pod.Spec.Containers {
+		if slices.Contains(macOSContainers, container.Name) {
+			problems = append(problems, macOSContainerProblems(container, scratchDisk, extended)...)
+		} else if _, err := reference.ParseNormalizedNamed(container.Image); err != nil {
+			add("container %s: invalid image reference %q: %s", container.Name, container.Image, err)
+		}
+		problems = append(problems, volumeMountProblems(pod, container)...)
+	}
+	return problems
+}
+
+// macOSContainerProblems returns the reasons the container cannot run as a macOS virtual machine,
+// whose pod sets the size of a scratch disk if scratchDisk is set.
+func macOSContainerProblems(container corev1.Container, scratchDisk bool, extended rm.ExtendedResourceNames) []string {
+	var problems []string
+	add := func(err error) {
+		problems = append(problems, fmt.Sprintf("container %s: %s", container.Name, err))
+	}
+
+	if container.Image == "" {
+		add(errNoImage)
+	} else if _, err := downloader.ParseReference(container.Image); err != nil {
+		add(err)
+	}
+	if readOnlyRootFilesystem(container) && !scratchDisk {
+		add(errNoScratchDisk)
+	}
+
+	rl := container.Resources.Requests
+	if cpu, err := utils.ExtractCPURequest(rl, extended.CPU...); err != nil {
+		add(err)
+	} else if _, err := vm.ValidateCPUCount(cpu); err != nil {
+		add(err)
+	}
+	if memorySize, err := utils.ExtractMemoryRequest(rl, extended.Memory...); err != nil {
+		add(err)
+	} else if _, err := vm.ValidateMemorySize(memorySize); err != nil {
+		add(err)
+	}
+	return problems
+}
+
+// volumeMountProblems returns the reasons the volumes of the container cannot be mounted.
+func volumeMountProblems(pod *corev1.Pod, container corev1.Container) []string {
+	var problems []string
+	for _, mount := range container.VolumeMounts {
+		var source *corev1.VolumeSource
+		for i := range pod.Spec.Volumes {
+			if pod.Spec.Volumes[i].Name == mount.Name {
+				source = &pod.Spec.Volumes[i].VolumeSource
+				break
+			}
+		}
+		switch {
+		case source == nil:
+			problems = append(problems, fmt.Sprintf("container %s: volume %s not found", container.Name, mount.Name))
+		case volumes.IsSkippedVolume(source):
+			// not mounted, the volume is skipped with a warning when the container is created
+		case source.HostPath == nil && source.EmptyDir == nil && source.Projected == nil:
+			problems = append(problems, fmt.Sprintf("container %s: volume %s has an unsupported type, only hostPath, emptyDir and projected volumes are supported", container.Name, mount.Name))
+		}
+	}
+	return problems
+}
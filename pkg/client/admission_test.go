@@ -0,0 +1,210 @@
+package client_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/agoda-com/macOS-vz-kubelet/pkg/client"
+	"github.com/agoda-com/macOS-vz-kubelet/pkg/event"
+	rm "github.com/agoda-com/macOS-vz-kubelet/pkg/resourcemanager"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/virtual-kubelet/virtual-kubelet/errdefs"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/utils/ptr"
+)
+
+func admissionTestPod() *corev1.Pod {
+	return &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: "test-pod", Namespace: "default"},
+		Spec: corev1.PodSpec{
+			Containers: []corev1.Container{
+				{
+					Name:  "macos",
+					Image: "localhost:5000/macos:latest",
+					Resources: corev1.ResourceRequirements{Requests: corev1.ResourceList{
+						corev1.ResourceCPU:    resource.MustParse("2"),
+						corev1.ResourceMemory: resource.MustParse("4Gi"),
+					}},
+					VolumeMounts: []corev1.VolumeMount{{Name: "cache", MountPath: "/cache"}},
+				},
+			},
+			Volumes: []corev1.Volume{
+				{Name: "cache", VolumeSource: corev1.VolumeSource{EmptyDir: &corev1.EmptyDirVolumeSource{}}},
+			},
+		},
+	}
+}
+
+func TestValidatePod(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	vzClient := client.NewVzClientAPIs(ctx, event.LogEventRecorder{}, "", t.TempDir(), nil)
+
+	require.NoError(t, vzClient.ValidatePod(ctx, admissionTestPod()))
+}
+
+func TestValidatePodDefaultImage(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	pod := admissionTestPod()
+	pod.Spec.Containers[0].Image = ""
+
+	vzClient := client.NewVzClientAPIs(ctx, event.LogEventRecorder{}, "", t.TempDir(), nil)
+	err := vzClient.ValidatePod(ctx, pod)
+	assert.True(t, errdefs.IsInvalidInput(err), err)
+
+	vzClient = client.NewVzClientAPIs(ctx, event.LogEventRecorder{}, "", t.TempDir(), nil, rm.WithDefaultImage("localhost:5000/macos:default"))
+	require.NoError(t, vzClient.ValidatePod(ctx, pod))
+	assert.Empty(t, pod.Spec.Containers[0].Image, "the pod is not changed")
+}
+
+func TestValidatePodReportsAllProblems(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	vzClient := client.NewVzClientAPIs(ctx, event.LogEventRecorder{}, "", t.TempDir(), nil)
+
+	pod := admissionTestPod()
+	pod.Spec.Containers[0].Resources.Requests[corev1.ResourceCPU] = resource.MustParse("1000")
+	pod.Spec.Volumes[0].VolumeSource = corev1.VolumeSource{
+		PersistentVolumeClaim: &corev1.PersistentVolumeClaimVolumeSource{ClaimName: "cache"},
+	}
+
+	err := vzClient.ValidatePod(ctx, pod)
+	require.Error(t, err)
+	assert.True(t, errdefs.IsInvalidInput(err))
+	assert.ErrorContains(t, err, "cpu count 1000 is greater than the maximum allowed cpu count")
+	assert.ErrorContains(t, err, "volume cache has an unsupported type")
+
+	assert.Len(t, client.AdmissionProblems(pod, false, rm.ExtendedResourceNames{}), 2)
+}
+
+func TestAdmissionProblems(t *testing.T) {
+	tests := []struct {
+		name     string
+		modify   func(pod *corev1.Pod)
+		runtime  bool
+		expected []string
+	}{
+		{
+			name:   "Admittable pod",
+			modify: func(*corev1.Pod) {},
+		},
+		{
+			name: "Invalid image reference",
+			modify: func(pod *corev1.Pod) {
+				pod.Spec.Containers[0].Image = "not a reference"
+			},
+			expected: []string{`container macos: invalid image reference "not a reference"`},
+		},
+		{
+			name: "Missing image",
+			modify: func(pod *corev1.Pod) {
+				pod.Spec.Containers[0].Image = ""
+			},
+			expected: []string{"container macos: no image set and no default macOS image configured"},
+		},
+		{
+			name: "Regular containers without container runtime",
+			modify: func(pod *corev1.Pod) {
+				pod.Spec.Containers = append(pod.Spec.Containers, corev1.Container{Name: "sidecar", Image: "nginx"})
+			},
+			expected: []string{"regular containers are not supported"},
+		},
+		{
+			name: "Regular containers with container runtime",
+			modify: func(pod *corev1.Pod) {
+				pod.Spec.Containers = append(pod.Spec.Containers, corev1.Container{Name: "sidecar", Image: "nginx"})
+			},
+			runtime: true,
+		},
+		{
+			name: "Invalid regular container image",
+			modify: func(pod *corev1.Pod) {
+				pod.Spec.Containers = append(pod.Spec.Containers, corev1.Container{Name: "sidecar", Image: "NGINX"})
+			},
+			runtime:  true,
+			expected: []string{`container sidecar: invalid image reference "NGINX"`},
+		},
+		{
+			name: "Missing volume",
+			modify: func(pod *corev1.Pod) {
+				pod.Spec.Volumes = nil
+			},
+			expected: []string{"container macos: volume cache not found"},
+		},
+		{
+			name: "Skipped volume",
+			modify: func(pod *corev1.Pod) {
+				pod.Spec.Volumes[0].VolumeSource = corev1.VolumeSource{
+					ConfigMap: &corev1.ConfigMapVolumeSource{LocalObjectReference: corev1.LocalObjectReference{Name: "cache"}},
+				}
+			},
+		},
+		{
+			name: "Memory below the minimum",
+			modify: func(pod *corev1.Pod) {
+				pod.Spec.Containers[0].Resources.Requests[corev1.ResourceMemory] = resource.MustParse("1Mi")
+			},
+			expected: []string{"container macos: memory size 1048576 is less than the minimum allowed memory size"},
+		},
+		{
+			name: "Read-only root filesystem without scratch disk",
+			modify: func(pod *corev1.Pod) {
+				pod.Spec.Containers[0].SecurityContext = &corev1.SecurityContext{ReadOnlyRootFilesystem: ptr.To(true)}
+			},
+			expected: []string{"container macos: readOnlyRootFilesystem requires the macosvz.agoda.com/scratch-disk-size annotation"},
+		},
+		{
+			name: "Read-only root filesystem with scratch disk",
+			modify: func(pod *corev1.Pod) {
+				pod.Annotations = map[string]string{client.ScratchDiskSizeAnnotation: "20Gi"}
+				pod.Spec.Containers[0].SecurityContext = &corev1.SecurityContext{ReadOnlyRootFilesystem: ptr.To(true)}
+			},
+		},
+		{
+			name: "Invalid scratch disk size",
+			modify: func(pod *corev1.Pod) {
+				pod.Annotations = map[string]string{client.ScratchDiskSizeAnnotation: "big"}
+				pod.Spec.Containers[0].SecurityContext = &corev1.SecurityContext{ReadOnlyRootFilesystem: ptr.To(true)}
+			},
+			expected: []string{`macosvz.agoda.com/scratch-disk-size annotation must be a positive quantity, got "big"`},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pod := admissionTestPod()
+			tt.modify(pod)
+			problems := client.AdmissionProblems(pod, tt.runtime, rm.ExtendedResourceNames{})
+			require.Len(t, problems, len(tt.expected), "%v", problems)
+			for i, expected := range tt.expected {
+				assert.Contains(t, problems[i], expected)
+			}
+		})
+	}
+}
+
+func TestValidatePodExtendedResources(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	pod := admissionTestPod()
+	pod.Spec.Containers[0].Resources.Requests = corev1.ResourceList{
+		"macos.agoda.com/vcpu":   resource.MustParse("2"),
+		"macos.agoda.com/memory": resource.MustParse("4Gi"),
+	}
+
+	vzClient := client.NewVzClientAPIs(ctx, event.LogEventRecorder{}, "", t.TempDir(), nil)
+	assert.True(t, errdefs.IsInvalidInput(vzClient.ValidatePod(ctx, pod)))
+
+	vzClient = client.NewVzClientAPIs(ctx, event.LogEventRecorder{}, "", t.TempDir(), nil, rm.WithExtendedResourceNames(rm.ExtendedResourceNames{
+		CPU:    []corev1.ResourceName{"macos.agoda.com/vcpu"},
+		Memory: []corev1.ResourceName{"macos.agoda.com/memory"},
+	}))
+	require.NoError(t, vzClient.ValidatePod(ctx, pod))
+}
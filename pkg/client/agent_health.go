@@ -0,0 +1,41 @@
+package client
+
+import (
+	"strconv"
+	"strings"
+
+	rm "github.com/agoda-com/macOS-vz-kubelet/pkg/resourcemanager"
+
+	"github.com/virtual-kubelet/virtual-kubelet/errdefs"
+	corev1 "k8s.io/api/core/v1"
+)
+
+const (
+	// AgentHealthPortAnnotation is the port of the HTTP health endpoint of a guest agent running inside the pod's
+	// macOS VMs. Once set, the VMs are ready only while the endpoint responds with a 2xx status, instead of as
+	// soon as they run.
+	AgentHealthPortAnnotation = "macosvz.agoda.com/agent-health-port"
+
+	// AgentHealthPathAnnotation is the path of the health endpoint of the guest agent, rm.DefaultAgentHealthPath if not set.
+	AgentHealthPathAnnotation = "macosvz.agoda.com/agent-health-path"
+)
+
+// ParseAgentHealth returns the probe of the guest agent of the pod's macOS VMs, nil if the pod does not set one.
+func ParseAgentHealth(pod *corev1.Pod) (*rm.AgentHealthProbe, error) {
+	path, hasPath := pod.Annotations[AgentHealthPathAnnotation]
+	value, ok := pod.Annotations[AgentHealthPortAnnotation]
+	if !ok {
+		if hasPath {
+			return nil, errdefs.InvalidInputf("%s annotation requires the %s annotation", AgentHealthPathAnnotation, AgentHealthPortAnnotation)
+		}
+		return nil, nil
+	}
+	port, err := strconv.Atoi(value)
+	if err != nil || port < 1 || port > 65535 {
+		return nil, errdefs.InvalidInputf("%s annotation: invalid port %q", AgentHealthPortAnnotation, value)
+	}
+	if hasPath && !strings.HasPrefix(path, "/") {
+		return nil, errdefs.InvalidInputf("%s annotation: path %q must start with /", AgentHealthPathAnnotation, path)
+	}
+	return &rm.AgentHealthProbe{Port: port, Path: path}, nil
+}
@@ -0,0 +1,44 @@
+package client_test
+
+import (
+	"testing"
+
+	"github.com/agoda-com/macOS-vz-kubelet/pkg/client"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/virtual-kubelet/virtual-kubelet/errdefs"
+	corev1 "k8s.io/api/core/v1"
+)
+
+func TestParseAgentHealth(t *testing.T) {
+	pod := &corev1.Pod{}
+	probe, err := client.ParseAgentHealth(pod)
+	require.NoError(t, err)
+	assert.Nil(t, probe)
+
+	pod.Annotations = map[string]string{client.AgentHealthPortAnnotation: "8080"}
+	probe, err = client.ParseAgentHealth(pod)
+	require.NoError(t, err)
+	require.NotNil(t, probe)
+	assert.Equal(t, 8080, probe.Port)
+	assert.Equal(t, "http://192.168.64.2:8080/healthz", probe.URL("192.168.64.2"))
+
+	pod.Annotations[client.AgentHealthPathAnnotation] = "/ready"
+	probe, err = client.ParseAgentHealth(pod)
+	require.NoError(t, err)
+	assert.Equal(t, "http://192.168.64.2:8080/ready", probe.URL("192.168.64.2"))
+
+	invalid := []map[string]string{
+		{client.AgentHealthPortAnnotation: "0"},
+		{client.AgentHealthPortAnnotation: "65536"},
+		{client.AgentHealthPortAnnotation: "http"},
+		{client.AgentHealthPortAnnotation: "8080", client.AgentHealthPathAnnotation: "ready"},
+		{client.AgentHealthPathAnnotation: "/ready"},
+	}
+	for _, annotations := range invalid {
+		pod.Annotations = annotations
+		_, err = client.ParseAgentHealth(pod)
+		assert.True(t, errdefs.IsInvalidInput(err), annotations)
+	}
+}
@@ -6,6 +6,7 @@ import (
 
 	"github.com/agoda-com/macOS-vz-kubelet/pkg/resource"
 
+	"github.com/opencontainers/go-digest"
 	"github.com/virtual-kubelet/virtual-kubelet/node/api"
 	stats "k8s.io/kubelet/pkg/apis/stats/v1alpha1"
 
@@ -16,17 +17,22 @@ import (
 // VirtualizationGroup represents a group of macOS virtual machines and containers.
 type VirtualizationGroup struct {
 	MacOSVirtualMachine resource.VirtualMachine
-	Containers          []resource.Container
+	// AdditionalVirtualMachines are the virtual machines of the macOS containers
+	// other than the first one, keyed by container name.
+	AdditionalVirtualMachines map[string]resource.VirtualMachine
+	Containers                []resource.Container
 }
 
 // VzClientInterface defines the methods that a VzClient implementation should provide.
 type VzClientInterface interface {
-	CreateVirtualizationGroup(ctx context.Context, pod *corev1.Pod, serviceAccountToken string, configMaps map[string]*corev1.ConfigMap) error
+	ValidatePod(ctx context.Context, pod *corev1.Pod) error
+	CreateVirtualizationGroup(ctx context.Context, pod *corev1.Pod, serviceAccountToken string, configMaps map[string]*corev1.ConfigMap, secrets map[string]*corev1.Secret) error
 	DeleteVirtualizationGroup(ctx context.Context, namespace, name string, gracePeriod int64) error
 	GetVirtualizationGroup(ctx context.Context, namespace, name string) (*VirtualizationGroup, error)
 	GetVirtualizationGroupListResult(ctx context.Context) (map[types.NamespacedName]*VirtualizationGroup, error)
 	GetContainerLogs(ctx context.Context, namespace, podName, containerName string, opts api.ContainerLogOpts) (io.ReadCloser, error)
 	ExecuteContainerCommand(ctx context.Context, namespace, podName, containerName string, cmd []string, attach api.AttachIO) error
 	AttachToContainer(ctx context.Context, namespace, podName, containerName string, attach api.AttachIO) error
+	ExportVirtualMachine(ctx context.Context, namespace, podName, containerName, targetRef string) (digest.Digest, error)
 	GetVirtualizationGroupStats(ctx context.Context, namespace, name string, containers []corev1.Container) ([]stats.ContainerStats, error)
 }
@@ -0,0 +1,59 @@
+package client
+
+import (
+	"strconv"
+
+	"github.com/agoda-com/macOS-vz-kubelet/pkg/vm/config"
+
+	"github.com/virtual-kubelet/virtual-kubelet/errdefs"
+	corev1 "k8s.io/api/core/v1"
+)
+
+const (
+	// DisableAudioAnnotation disables the audio device of the pod's macOS VM when "true",
+	// or enables it when "false" regardless of the node default.
+	DisableAudioAnnotation = "macosvz.agoda.com/disable-audio"
+	// DisableInputAnnotation disables the keyboard and pointing devices of the pod's macOS VM when "true",
+	// or enables them when "false" regardless of the node default.
+	DisableInputAnnotation = "macosvz.agoda.com/disable-input"
+	// MemoryBalloonAnnotation attaches the memory balloon device to the pod's macOS VM when "true",
+	// or skips it when "false" regardless of the node default.
+	MemoryBalloonAnnotation = "macosvz.agoda.com/memory-balloon"
+	// DiskModeAnnotation selects whether the boot disk of the pod's macOS VM is an overlay discarded when the VM
+	// stops ("overlay") or a full copy kept until the pod is deleted ("copy"), regardless of the node default.
+	DiskModeAnnotation = "macosvz.agoda.com/disk-mode"
+)
+
+// ParseDeviceOptions applies the device annotations of the pod on top of the defaults.
+func ParseDeviceOptions(pod *corev1.Pod, defaults config.DeviceOptions) (config.DeviceOptions, error) {
+	devices := defaults
+	for annotation, option := range map[string]*bool{
+		DisableAudioAnnotation:  &devices.DisableAudio,
+		DisableInputAnnotation:  &devices.DisableInput,
+		MemoryBalloonAnnotation: &devices.EnableMemoryBalloon,
+	} {
+		value, ok := pod.Annotations[annotation]
+		if !ok {
+			continue
+		}
+		parsed, err := strconv.ParseBool(value)
+		if err != nil {
+			return defaults, errdefs.InvalidInputf("%s annotation must be a boolean, got %q", annotation, value)
+		}
+		*option = parsed
+	}
+	return devices, nil
+}
+
+// ParseDiskMode returns the disk mode of the pod's macOS VM, the default unless the pod selects one.
+func ParseDiskMode(pod *corev1.Pod, defaultMode config.DiskMode) (config.DiskMode, error) {
+	value, ok := pod.Annotations[DiskModeAnnotation]
+	if !ok {
+		return defaultMode, nil
+	}
+	mode, err := config.ParseDiskMode(value)
+	if err != nil || value == "" {
+		return defaultMode, errdefs.InvalidInputf("%s annotation must be %s or %s, got %q", DiskModeAnnotation, config.DiskModeOverlay, config.DiskModeCopy, value)
+	}
+	return mode, nil
+}
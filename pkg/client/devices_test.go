@@ -0,0 +1,104 @@
+package client_test
+
+import (
+	"testing"
+
+	"github.com/agoda-com/macOS-vz-kubelet/pkg/client"
+	"github.com/agoda-com/macOS-vz-kubelet/pkg/vm/config"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/virtual-kubelet/virtual-kubelet/errdefs"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestParseDeviceOptions(t *testing.T) {
+	tests := []struct {
+		name        string
+		annotations map[string]string
+		defaults    config.DeviceOptions
+		expected    config.DeviceOptions
+		expectError bool
+	}{
+		{
+			name: "No annotations attach every device",
+		},
+		{
+			name:     "No annotations keep the defaults",
+			defaults: config.DeviceOptions{DisableAudio: true},
+			expected: config.DeviceOptions{DisableAudio: true},
+		},
+		{
+			name:        "Disable audio",
+			annotations: map[string]string{client.DisableAudioAnnotation: "true"},
+			expected:    config.DeviceOptions{DisableAudio: true},
+		},
+		{
+			name:        "Disable input",
+			annotations: map[string]string{client.DisableInputAnnotation: "true"},
+			expected:    config.DeviceOptions{DisableInput: true},
+		},
+		{
+			name: "Disable both",
+			annotations: map[string]string{
+				client.DisableAudioAnnotation: "true",
+				client.DisableInputAnnotation: "true",
+			},
+			expected: config.DeviceOptions{DisableAudio: true, DisableInput: true},
+		},
+		{
+			name:        "Annotation overrides the default",
+			annotations: map[string]string{client.DisableInputAnnotation: "false"},
+			defaults:    config.DeviceOptions{DisableAudio: true, DisableInput: true},
+			expected:    config.DeviceOptions{DisableAudio: true},
+		},
+		{
+			name:        "Memory balloon",
+			annotations: map[string]string{client.MemoryBalloonAnnotation: "true"},
+			expected:    config.DeviceOptions{EnableMemoryBalloon: true},
+		},
+		{
+			name:        "Invalid value",
+			annotations: map[string]string{client.DisableAudioAnnotation: "yes please"},
+			expectError: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pod := &corev1.Pod{ObjectMeta: metav1.ObjectMeta{Annotations: tt.annotations}}
+			devices, err := client.ParseDeviceOptions(pod, tt.defaults)
+			if tt.expectError {
+				require.Error(t, err)
+				assert.True(t, errdefs.IsInvalidInput(err))
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, devices)
+		})
+	}
+}
+
+func TestParseDiskMode(t *testing.T) {
+	pod := &corev1.Pod{}
+	mode, err := client.ParseDiskMode(pod, config.DiskModeCopy)
+	require.NoError(t, err)
+	assert.Equal(t, config.DiskModeCopy, mode)
+
+	pod.Annotations = map[string]string{client.DiskModeAnnotation: "overlay"}
+	mode, err = client.ParseDiskMode(pod, config.DiskModeCopy)
+	require.NoError(t, err)
+	assert.Equal(t, config.DiskModeOverlay, mode)
+
+	pod.Annotations[client.DiskModeAnnotation] = "copy"
+	mode, err = client.ParseDiskMode(pod, config.DiskModeOverlay)
+	require.NoError(t, err)
+	assert.Equal(t, config.DiskModeCopy, mode)
+
+	for _, value := range []string{"", "snapshot"} {
+		pod.Annotations[client.DiskModeAnnotation] = value
+		_, err = client.ParseDiskMode(pod, config.DiskModeOverlay)
+		assert.True(t, errdefs.IsInvalidInput(err), value)
+	}
+}
@@ -0,0 +1,223 @@
+package client
+
+import (
+	"fmt"
+	"math"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/virtual-kubelet/virtual-kubelet/errdefs"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"k8s.io/kubernetes/pkg/fieldpath"
+	"k8s.io/kubernetes/third_party/forked/golang/expansion"
+)
+
+// ResolveEnv resolves the environment variables of the container, the same way kubelet does.
+// Variables from `envFrom` sources come first and are overridden by the `env` variables of the same name.
+// Values from `valueFrom` sources are looked up in the given config maps and secrets (keyed by name)
+// or taken from the pod fields and container resources, and `$(VAR)` references are expanded using the previously defined variables.
+// Missing optional keys are skipped, while missing required keys result in an error.
+func ResolveEnv(pod *corev1.Pod, container corev1.Container, configMaps map[string]*corev1.ConfigMap, secrets map[string]*corev1.Secret) ([]corev1.EnvVar, error) {
+	if len(container.EnvFrom) == 0 && len(container.Env) == 0 {
+		return nil, nil
+	}
+
+	env := make([]corev1.EnvVar, 0, len(container.Env))
+	indexes := make(map[string]int, len(container.Env))
+	values := make(map[string]string, len(container.Env))
+	set := func(name, value string) {
+		values[name] = value
+		if i, ok := indexes[name]; ok {
+			env[i].Value = value
+			return
+		}
+		indexes[name] = len(env)
+		env = append(env, corev1.EnvVar{Name: name, Value: value})
+	}
+
+	for _, source := range container.EnvFrom {
+		data, err := resolveEnvFromSource(source, configMaps, secrets)
+		if err != nil {
+			return nil, errdefs.AsInvalidInput(fmt.Errorf("container %s: envFrom: %w", container.Name, err))
+		}
+
+		keys := make([]string, 0, len(data))
+		for k := range data {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			name := source.Prefix + k
+			if errs := validation.IsEnvVarName(name); len(errs) > 0 {
+				// kubelet skips the keys that are not valid variable names as well
+				continue
+			}
+			set(name, data[k])
+		}
+	}
+
+	mapping := expansion.MappingFuncFor(values)
+	for _, e := range container.Env {
+		value := e.Value
+		if e.ValueFrom == nil {
+			value = expansion.Expand(value, mapping)
+		} else {
+			var ok bool
+			var err error
+			value, ok, err = resolveEnvSource(pod, container, e.ValueFrom, configMaps, secrets)
+			if err != nil {
+				return nil, errdefs.AsInvalidInput(fmt.Errorf("container %s: env %s: %w", container.Name, e.Name, err))
+			}
+			if !ok {
+				continue
+			}
+		}
+
+		set(e.Name, value)
+	}
+	return env, nil
+}
+
+// resolveEnvFromSource returns all key-value pairs of the `envFrom` source.
+// Nil is returned if an optional source is not present.
+func resolveEnvFromSource(source corev1.EnvFromSource, configMaps map[string]*corev1.ConfigMap, secrets map[string]*corev1.Secret) (map[string]string, error) {
+	switch {
+	case source.ConfigMapRef != nil:
+		ref := source.ConfigMapRef
+		configMap, found := configMaps[ref.Name]
+		if !found {
+			if ref.Optional != nil && *ref.Optional {
+				return nil, nil
+			}
+			return nil, fmt.Errorf("config map %s not found", ref.Name)
+		}
+		return configMap.Data, nil
+	case source.SecretRef != nil:
+		ref := source.SecretRef
+		secret, found := secrets[ref.Name]
+		if !found {
+			if ref.Optional != nil && *ref.Optional {
+				return nil, nil
+			}
+			return nil, fmt.Errorf("secret %s not found", ref.Name)
+		}
+		data := make(map[string]string, len(secret.Data))
+		for k, v := range secret.Data {
+			data[k] = string(v)
+		}
+		return data, nil
+	}
+	return nil, fmt.Errorf("unsupported envFrom source")
+}
+
+// resolveEnvSource returns the value of the environment variable source.
+// The returned flag is false if an optional value is not present.
+func resolveEnvSource(pod *corev1.Pod, container corev1.Container, source *corev1.EnvVarSource, configMaps map[string]*corev1.ConfigMap, secrets map[string]*corev1.Secret) (string, bool, error) {
+	switch {
+	case source.ConfigMapKeyRef != nil:
+		ref := source.ConfigMapKeyRef
+		optional := ref.Optional != nil && *ref.Optional
+		configMap, found := configMaps[ref.Name]
+		if !found {
+			if optional {
+				return "", false, nil
+			}
+			return "", false, fmt.Errorf("config map %s not found", ref.Name)
+		}
+		value, found := configMap.Data[ref.Key]
+		if !found {
+			if optional {
+				return "", false, nil
+			}
+			return "", false, fmt.Errorf("key %s not found in config map %s", ref.Key, ref.Name)
+		}
+		return value, true, nil
+	case source.SecretKeyRef != nil:
+		ref := source.SecretKeyRef
+		optional := ref.Optional != nil && *ref.Optional
+		secret, found := secrets[ref.Name]
+		if !found {
+			if optional {
+				return "", false, nil
+			}
+			return "", false, fmt.Errorf("secret %s not found", ref.Name)
+		}
+		value, found := secret.Data[ref.Key]
+		if !found {
+			if optional {
+				return "", false, nil
+			}
+			return "", false, fmt.Errorf("key %s not found in secret %s", ref.Key, ref.Name)
+		}
+		return string(value), true, nil
+	case source.FieldRef != nil:
+		value, err := podFieldValue(pod, source.FieldRef.FieldPath)
+		return value, err == nil, err
+	case source.ResourceFieldRef != nil:
+		value, err := containerResourceValue(pod, container, source.ResourceFieldRef)
+		return value, err == nil, err
+	}
+	return "", false, fmt.Errorf("unsupported value source")
+}
+
+// podFieldValue returns the value of the pod field referenced by the field path.
+func podFieldValue(pod *corev1.Pod, fieldPath string) (string, error) {
+	switch fieldPath {
+	case "spec.nodeName":
+		return pod.Spec.NodeName, nil
+	case "spec.serviceAccountName":
+		return pod.Spec.ServiceAccountName, nil
+	case "status.hostIP", "status.hostIPs", "status.podIP", "status.podIPs":
+		// pod addresses are not known until the virtual machine has started
+		return "", fmt.Errorf("field path %s is not supported", fieldPath)
+	}
+	return fieldpath.ExtractFieldPathAsString(pod, fieldPath)
+}
+
+// containerResourceValue returns the container resource referenced by the selector divided by its divisor
+// and rounded up, the same way kubelet does. Unset limits fall back to the requests, since the node
+// allocatable that kubelet would use instead does not reflect the resources of a virtual machine.
+func containerResourceValue(pod *corev1.Pod, container corev1.Container, selector *corev1.ResourceFieldSelector) (string, error) {
+	if selector.ContainerName != "" && selector.ContainerName != container.Name {
+		i := slices.IndexFunc(pod.Spec.Containers, func(c corev1.Container) bool { return c.Name == selector.ContainerName })
+		if i < 0 {
+			return "", fmt.Errorf("container %s not found", selector.ContainerName)
+		}
+		container = pod.Spec.Containers[i]
+	}
+
+	kind, name, _ := strings.Cut(selector.Resource, ".")
+	resourceName := corev1.ResourceName(name)
+	if resourceName != corev1.ResourceCPU && resourceName != corev1.ResourceMemory && resourceName != corev1.ResourceEphemeralStorage {
+		return "", fmt.Errorf("resource %s is not supported", selector.Resource)
+	}
+
+	var quantity resource.Quantity
+	switch kind {
+	case "requests":
+		quantity = container.Resources.Requests[resourceName]
+	case "limits":
+		var found bool
+		if quantity, found = container.Resources.Limits[resourceName]; !found {
+			quantity = container.Resources.Requests[resourceName]
+		}
+	default:
+		return "", fmt.Errorf("resource %s is not supported", selector.Resource)
+	}
+
+	divisor := selector.Divisor
+	if divisor.IsZero() {
+		divisor = resource.MustParse("1")
+	}
+	var value float64
+	if resourceName == corev1.ResourceCPU {
+		value = float64(quantity.MilliValue()) / float64(divisor.MilliValue())
+	} else {
+		value = float64(quantity.Value()) / float64(divisor.Value())
+	}
+	return strconv.FormatInt(int64(math.Ceil(value)), 10), nil
+}
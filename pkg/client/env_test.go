@@ -0,0 +1,280 @@
+package client_test
+
+import (
+	"testing"
+
+	"github.com/agoda-com/macOS-vz-kubelet/pkg/client"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/virtual-kubelet/virtual-kubelet/errdefs"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/utils/ptr"
+)
+
+func TestResolveEnv(t *testing.T) {
+	pod := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "test-pod",
+			Namespace: "default",
+			Labels:    map[string]string{"app": "macos"},
+		},
+		Spec: corev1.PodSpec{NodeName: "test-node"},
+	}
+	configMaps := map[string]*corev1.ConfigMap{
+		"config":   {Data: map[string]string{"host": "example.com"}},
+		"settings": {Data: map[string]string{"LOG_LEVEL": "info", "REGION": "eu", "invalid name": "skipped"}},
+	}
+	secrets := map[string]*corev1.Secret{
+		"credentials": {Data: map[string][]byte{"token": []byte("s3cr3t")}},
+	}
+
+	configMapRef := func(name, key string, optional bool) *corev1.EnvVarSource {
+		return &corev1.EnvVarSource{ConfigMapKeyRef: &corev1.ConfigMapKeySelector{
+			LocalObjectReference: corev1.LocalObjectReference{Name: name},
+			Key:                  key,
+			Optional:             ptr.To(optional),
+		}}
+	}
+	secretRef := func(name, key string, optional bool) *corev1.EnvVarSource {
+		return &corev1.EnvVarSource{SecretKeyRef: &corev1.SecretKeySelector{
+			LocalObjectReference: corev1.LocalObjectReference{Name: name},
+			Key:                  key,
+			Optional:             ptr.To(optional),
+		}}
+	}
+	configMapEnvFrom := func(name, prefix string, optional bool) corev1.EnvFromSource {
+		return corev1.EnvFromSource{Prefix: prefix, ConfigMapRef: &corev1.ConfigMapEnvSource{
+			LocalObjectReference: corev1.LocalObjectReference{Name: name},
+			Optional:             ptr.To(optional),
+		}}
+	}
+	secretEnvFrom := func(name, prefix string, optional bool) corev1.EnvFromSource {
+		return corev1.EnvFromSource{Prefix: prefix, SecretRef: &corev1.SecretEnvSource{
+			LocalObjectReference: corev1.LocalObjectReference{Name: name},
+			Optional:             ptr.To(optional),
+		}}
+	}
+	fieldRef := func(fieldPath string) *corev1.EnvVarSource {
+		return &corev1.EnvVarSource{FieldRef: &corev1.ObjectFieldSelector{FieldPath: fieldPath}}
+	}
+
+	tests := []struct {
+		name        string
+		envFrom     []corev1.EnvFromSource
+		env         []corev1.EnvVar
+		expected    []corev1.EnvVar
+		expectError bool
+	}{
+		{
+			name:     "Literal values",
+			env:      []corev1.EnvVar{{Name: "A", Value: "a"}, {Name: "B", Value: "$(A)-b"}},
+			expected: []corev1.EnvVar{{Name: "A", Value: "a"}, {Name: "B", Value: "a-b"}},
+		},
+		{
+			name:     "Config map key",
+			env:      []corev1.EnvVar{{Name: "HOST", ValueFrom: configMapRef("config", "host", false)}},
+			expected: []corev1.EnvVar{{Name: "HOST", Value: "example.com"}},
+		},
+		{
+			name:     "Secret key",
+			env:      []corev1.EnvVar{{Name: "TOKEN", ValueFrom: secretRef("credentials", "token", false)}},
+			expected: []corev1.EnvVar{{Name: "TOKEN", Value: "s3cr3t"}},
+		},
+		{
+			name: "Pod fields",
+			env: []corev1.EnvVar{
+				{Name: "POD_NAME", ValueFrom: fieldRef("metadata.name")},
+				{Name: "POD_NAMESPACE", ValueFrom: fieldRef("metadata.namespace")},
+				{Name: "APP", ValueFrom: fieldRef("metadata.labels['app']")},
+				{Name: "NODE_NAME", ValueFrom: fieldRef("spec.nodeName")},
+				{Name: "URL", Value: "https://$(HOST_NAME)/$(POD_NAME)"},
+			},
+			expected: []corev1.EnvVar{
+				{Name: "POD_NAME", Value: "test-pod"},
+				{Name: "POD_NAMESPACE", Value: "default"},
+				{Name: "APP", Value: "macos"},
+				{Name: "NODE_NAME", Value: "test-node"},
+				{Name: "URL", Value: "https://$(HOST_NAME)/test-pod"},
+			},
+		},
+		{
+			name: "Missing optional keys are skipped",
+			env: []corev1.EnvVar{
+				{Name: "MISSING_KEY", ValueFrom: configMapRef("config", "missing", true)},
+				{Name: "MISSING_SECRET", ValueFrom: secretRef("missing", "token", true)},
+			},
+			expected: []corev1.EnvVar{},
+		},
+		{
+			name:        "Missing required config map key",
+			env:         []corev1.EnvVar{{Name: "MISSING", ValueFrom: configMapRef("config", "missing", false)}},
+			expectError: true,
+		},
+		{
+			name:        "Missing required secret",
+			env:         []corev1.EnvVar{{Name: "MISSING", ValueFrom: secretRef("missing", "token", false)}},
+			expectError: true,
+		},
+		{
+			name:    "Config map envFrom",
+			envFrom: []corev1.EnvFromSource{configMapEnvFrom("settings", "", false)},
+			expected: []corev1.EnvVar{
+				{Name: "LOG_LEVEL", Value: "info"},
+				{Name: "REGION", Value: "eu"},
+			},
+		},
+		{
+			name:    "Explicit env overrides envFrom",
+			envFrom: []corev1.EnvFromSource{configMapEnvFrom("settings", "", false)},
+			env: []corev1.EnvVar{
+				{Name: "LOG_LEVEL", Value: "debug"},
+				{Name: "URL", Value: "https://$(REGION).example.com"},
+			},
+			expected: []corev1.EnvVar{
+				{Name: "LOG_LEVEL", Value: "debug"},
+				{Name: "REGION", Value: "eu"},
+				{Name: "URL", Value: "https://eu.example.com"},
+			},
+		},
+		{
+			name: "Prefixed envFrom sources",
+			envFrom: []corev1.EnvFromSource{
+				configMapEnvFrom("settings", "APP_", false),
+				secretEnvFrom("credentials", "SECRET_", false),
+			},
+			expected: []corev1.EnvVar{
+				{Name: "APP_LOG_LEVEL", Value: "info"},
+				{Name: "APP_REGION", Value: "eu"},
+				{Name: "SECRET_token", Value: "s3cr3t"},
+			},
+		},
+		{
+			name: "Missing optional envFrom sources are skipped",
+			envFrom: []corev1.EnvFromSource{
+				configMapEnvFrom("missing", "", true),
+				secretEnvFrom("missing", "", true),
+			},
+			expected: []corev1.EnvVar{},
+		},
+		{
+			name:        "Missing required envFrom config map",
+			envFrom:     []corev1.EnvFromSource{configMapEnvFrom("missing", "", false)},
+			expectError: true,
+		},
+		{
+			name:        "Unsupported field path",
+			env:         []corev1.EnvVar{{Name: "POD_IP", ValueFrom: fieldRef("status.podIP")}},
+			expectError: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			container := corev1.Container{Name: "macos", EnvFrom: tt.envFrom, Env: tt.env}
+			env, err := client.ResolveEnv(pod, container, configMaps, secrets)
+			if tt.expectError {
+				require.Error(t, err)
+				assert.True(t, errdefs.IsInvalidInput(err))
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, env)
+		})
+	}
+}
+
+func TestResolveEnv_ResourceFieldRef(t *testing.T) {
+	resourceRef := func(containerName, res, divisor string) *corev1.EnvVarSource {
+		selector := &corev1.ResourceFieldSelector{ContainerName: containerName, Resource: res}
+		if divisor != "" {
+			selector.Divisor = resource.MustParse(divisor)
+		}
+		return &corev1.EnvVarSource{ResourceFieldRef: selector}
+	}
+
+	macOS := corev1.Container{
+		Name: "macos",
+		Resources: corev1.ResourceRequirements{
+			Requests: corev1.ResourceList{
+				corev1.ResourceCPU:    resource.MustParse("2500m"),
+				corev1.ResourceMemory: resource.MustParse("8Gi"),
+			},
+			Limits: corev1.ResourceList{
+				corev1.ResourceCPU: resource.MustParse("4"),
+			},
+		},
+	}
+	sidecar := corev1.Container{
+		Name: "sidecar",
+		Resources: corev1.ResourceRequirements{
+			Requests: corev1.ResourceList{corev1.ResourceMemory: resource.MustParse("256Mi")},
+		},
+	}
+	pod := &corev1.Pod{Spec: corev1.PodSpec{Containers: []corev1.Container{macOS, sidecar}}}
+
+	tests := []struct {
+		name        string
+		env         []corev1.EnvVar
+		expected    []corev1.EnvVar
+		expectError bool
+	}{
+		{
+			name:     "CPU limit with 1 divisor",
+			env:      []corev1.EnvVar{{Name: "CPU_LIMIT", ValueFrom: resourceRef("", "limits.cpu", "1")}},
+			expected: []corev1.EnvVar{{Name: "CPU_LIMIT", Value: "4"}},
+		},
+		{
+			name:     "Memory request with 1Mi divisor",
+			env:      []corev1.EnvVar{{Name: "MEMORY_REQUEST", ValueFrom: resourceRef("", "requests.memory", "1Mi")}},
+			expected: []corev1.EnvVar{{Name: "MEMORY_REQUEST", Value: "8192"}},
+		},
+		{
+			name:     "CPU request is rounded up",
+			env:      []corev1.EnvVar{{Name: "CPU_REQUEST", ValueFrom: resourceRef("", "requests.cpu", "")}},
+			expected: []corev1.EnvVar{{Name: "CPU_REQUEST", Value: "3"}},
+		},
+		{
+			name:     "CPU request in millicores",
+			env:      []corev1.EnvVar{{Name: "CPU_REQUEST", ValueFrom: resourceRef("", "requests.cpu", "1m")}},
+			expected: []corev1.EnvVar{{Name: "CPU_REQUEST", Value: "2500"}},
+		},
+		{
+			name:     "Unset limit falls back to the request",
+			env:      []corev1.EnvVar{{Name: "MEMORY_LIMIT", ValueFrom: resourceRef("", "limits.memory", "1Gi")}},
+			expected: []corev1.EnvVar{{Name: "MEMORY_LIMIT", Value: "8"}},
+		},
+		{
+			name:     "Other container",
+			env:      []corev1.EnvVar{{Name: "SIDECAR_MEMORY", ValueFrom: resourceRef("sidecar", "requests.memory", "1Mi")}},
+			expected: []corev1.EnvVar{{Name: "SIDECAR_MEMORY", Value: "256"}},
+		},
+		{
+			name:        "Unknown container",
+			env:         []corev1.EnvVar{{Name: "MISSING", ValueFrom: resourceRef("missing", "requests.memory", "")}},
+			expectError: true,
+		},
+		{
+			name:        "Unsupported resource",
+			env:         []corev1.EnvVar{{Name: "GPU", ValueFrom: resourceRef("", "limits.nvidia.com/gpu", "")}},
+			expectError: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			container := macOS
+			container.Env = tt.env
+			env, err := client.ResolveEnv(pod, container, nil, nil)
+			if tt.expectError {
+				require.Error(t, err)
+				assert.True(t, errdefs.IsInvalidInput(err))
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, env)
+		})
+	}
+}
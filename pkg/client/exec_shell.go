@@ -0,0 +1,28 @@
+package client
+
+import (
+	"slices"
+	"strings"
+
+	"github.com/agoda-com/macOS-vz-kubelet/internal/utils"
+
+	"github.com/virtual-kubelet/virtual-kubelet/errdefs"
+	corev1 "k8s.io/api/core/v1"
+)
+
+// ExecShellAnnotation is the login shell the commands executed in the pod's macOS containers are wrapped in,
+// bash or zsh, so that tooling installed on the PATH set up by the profile of the user is found.
+// Without it, the commands are executed as is. Probes, lifecycle hooks and stats are never wrapped.
+const ExecShellAnnotation = "macosvz.agoda.com/exec-shell"
+
+// ParseExecShell returns the login shell wrapping the commands executed in the pod, empty if the pod does not set one.
+func ParseExecShell(pod *corev1.Pod) (string, error) {
+	value, ok := pod.Annotations[ExecShellAnnotation]
+	if !ok {
+		return "", nil
+	}
+	if !slices.Contains(utils.LoginShells, value) {
+		return "", errdefs.InvalidInputf("%s annotation must be one of %s, got %q", ExecShellAnnotation, strings.Join(utils.LoginShells, ", "), value)
+	}
+	return value, nil
+}
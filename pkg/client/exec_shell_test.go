@@ -0,0 +1,42 @@
+package client_test
+
+import (
+	"testing"
+
+	"github.com/agoda-com/macOS-vz-kubelet/pkg/client"
+	rm "github.com/agoda-com/macOS-vz-kubelet/pkg/resourcemanager"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/virtual-kubelet/virtual-kubelet/errdefs"
+	corev1 "k8s.io/api/core/v1"
+)
+
+func TestParseExecShell(t *testing.T) {
+	pod := &corev1.Pod{}
+	shell, err := client.ParseExecShell(pod)
+	require.NoError(t, err)
+	assert.Empty(t, shell)
+
+	for _, value := range []string{"bash", "zsh"} {
+		pod.Annotations = map[string]string{client.ExecShellAnnotation: value}
+		shell, err = client.ParseExecShell(pod)
+		require.NoError(t, err)
+		assert.Equal(t, value, shell)
+	}
+
+	for _, value := range []string{"", "sh", "/bin/zsh"} {
+		pod.Annotations[client.ExecShellAnnotation] = value
+		_, err = client.ParseExecShell(pod)
+		assert.True(t, errdefs.IsInvalidInput(err), value)
+	}
+}
+
+func TestAdmissionProblemsExecShell(t *testing.T) {
+	pod := admissionTestPod()
+	pod.Annotations = map[string]string{client.ExecShellAnnotation: "fish"}
+
+	problems := client.AdmissionProblems(pod, true, rm.ExtendedResourceNames{})
+	require.Len(t, problems, 1)
+	assert.Contains(t, problems[0], client.ExecShellAnnotation)
+}
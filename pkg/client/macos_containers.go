@@ -0,0 +1,79 @@
+package client
+
+import (
+	"slices"
+	"strings"
+
+	rm "github.com/agoda-com/macOS-vz-kubelet/pkg/resourcemanager"
+
+	"github.com/virtual-kubelet/virtual-kubelet/errdefs"
+	corev1 "k8s.io/api/core/v1"
+)
+
+// MacOSContainersAnnotation defines the containers of a pod that run as macOS virtual machines
+// as a comma-separated list of container names, every one of them getting its own virtual machine.
+// The first container of the pod always runs as a macOS virtual machine, whether it is listed or not,
+// and the remaining containers run as regular containers.
+const MacOSContainersAnnotation = "macosvz.agoda.com/macos-containers"
+
+// ApplyDefaultImage returns the pod with the default image set on the macOS containers without an image.
+// The pod is copied before being changed, and returned as is if there is nothing to change.
+func ApplyDefaultImage(pod *corev1.Pod, macOSContainers []string, image string) *corev1.Pod {
+	if image == "" {
+		return pod
+	}
+
+	var copied *corev1.Pod
+	for i, container := range pod.Spec.Containers {
+		if container.Image != "" || !slices.Contains(macOSContainers, container.Name) {
+			continue
+		}
+		if copied == nil {
+			copied = pod.DeepCopy()
+		}
+		copied.Spec.Containers[i].Image = image
+	}
+	if copied == nil {
+		return pod
+	}
+	return copied
+}
+
+// ParseMacOSContainers returns the names of the pod containers that run as macOS virtual machines
+// in the order of the pod spec, so the first one is always the first container of the pod.
+func ParseMacOSContainers(pod *corev1.Pod) ([]string, error) {
+	if len(pod.Spec.Containers) == 0 {
+		return nil, errdefs.InvalidInput("pod has no containers")
+	}
+
+	listed := []string{pod.Spec.Containers[0].Name}
+	if value, ok := pod.Annotations[MacOSContainersAnnotation]; ok && strings.TrimSpace(value) != "" {
+		var seen []string
+		for _, name := range strings.Split(value, ",") {
+			name = strings.TrimSpace(name)
+			if !slices.ContainsFunc(pod.Spec.Containers, func(c corev1.Container) bool { return c.Name == name }) {
+				return nil, errdefs.InvalidInputf("%s annotation references unknown container %q", MacOSContainersAnnotation, name)
+			}
+			if slices.Contains(seen, name) {
+				return nil, errdefs.InvalidInputf("%s annotation references container %q more than once", MacOSContainersAnnotation, name)
+			}
+			seen = append(seen, name)
+			if !slices.Contains(listed, name) {
+				listed = append(listed, name)
+			}
+		}
+	}
+
+	// keep the order of the pod spec
+	var macOSContainers []string
+	for _, container := range pod.Spec.Containers {
+		if slices.Contains(listed, container.Name) {
+			macOSContainers = append(macOSContainers, container.Name)
+		}
+	}
+	if len(macOSContainers) > rm.MaxVirtualMachines {
+		return nil, errdefs.InvalidInputf("%s annotation requests %d macOS virtual machines, at most %d are supported",
+			MacOSContainersAnnotation, len(macOSContainers), rm.MaxVirtualMachines)
+	}
+	return macOSContainers, nil
+}
@@ -0,0 +1,79 @@
+package client_test
+
+import (
+	"testing"
+
+	"github.com/agoda-com/macOS-vz-kubelet/pkg/client"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/virtual-kubelet/virtual-kubelet/errdefs"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func newMacOSContainersPod(annotation string, containerNames ...string) *corev1.Pod {
+	pod := &corev1.Pod{}
+	if annotation != "" {
+		pod.ObjectMeta = metav1.ObjectMeta{
+			Annotations: map[string]string{client.MacOSContainersAnnotation: annotation},
+		}
+	}
+	for _, name := range containerNames {
+		pod.Spec.Containers = append(pod.Spec.Containers, corev1.Container{Name: name})
+	}
+	return pod
+}
+
+func TestParseMacOSContainers(t *testing.T) {
+	tests := []struct {
+		name        string
+		pod         *corev1.Pod
+		expected    []string
+		expectError bool
+	}{
+		{
+			name:     "No annotation",
+			pod:      newMacOSContainersPod("", "macos", "sidecar"),
+			expected: []string{"macos"},
+		},
+		{
+			name:     "Additional macOS container",
+			pod:      newMacOSContainersPod("worker, macos", "macos", "sidecar", "worker"),
+			expected: []string{"macos", "worker"},
+		},
+		{
+			name:     "First container is implied",
+			pod:      newMacOSContainersPod("worker", "macos", "worker"),
+			expected: []string{"macos", "worker"},
+		},
+		{
+			name:        "Unknown container",
+			pod:         newMacOSContainersPod("macos,unknown", "macos", "sidecar"),
+			expectError: true,
+		},
+		{
+			name:        "Duplicate container",
+			pod:         newMacOSContainersPod("worker,worker", "macos", "worker"),
+			expectError: true,
+		},
+		{
+			name:        "Too many virtual machines",
+			pod:         newMacOSContainersPod("worker-0,worker-1", "macos", "worker-0", "worker-1"),
+			expectError: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			macOSContainers, err := client.ParseMacOSContainers(tt.pod)
+			if tt.expectError {
+				require.Error(t, err)
+				assert.True(t, errdefs.IsInvalidInput(err))
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, macOSContainers)
+		})
+	}
+}
@@ -8,6 +8,8 @@ import (
 
 	context "context"
 
+	digest "github.com/opencontainers/go-digest"
+
 	io "io"
 
 	mock "github.com/stretchr/testify/mock"
@@ -42,17 +44,17 @@ func (_m *VzClientInterface) AttachToContainer(ctx context.Context, namespace st
 	return r0
 }
 
-// CreateVirtualizationGroup provides a mock function with given fields: ctx, pod, serviceAccountToken, configMaps
-func (_m *VzClientInterface) CreateVirtualizationGroup(ctx context.Context, pod *v1.Pod, serviceAccountToken string, configMaps map[string]*v1.ConfigMap) error {
-	ret := _m.Called(ctx, pod, serviceAccountToken, configMaps)
+// CreateVirtualizationGroup provides a mock function with given fields: ctx, pod, serviceAccountToken, configMaps, secrets
+func (_m *VzClientInterface) CreateVirtualizationGroup(ctx context.Context, pod *v1.Pod, serviceAccountToken string, configMaps map[string]*v1.ConfigMap, secrets map[string]*v1.Secret) error {
+	ret := _m.Called(ctx, pod, serviceAccountToken, configMaps, secrets)
 
 	if len(ret) == 0 {
 		panic("no return value specified for CreateVirtualizationGroup")
 	}
 
 	var r0 error
-	if rf, ok := ret.Get(0).(func(context.Context, *v1.Pod, string, map[string]*v1.ConfigMap) error); ok {
-		r0 = rf(ctx, pod, serviceAccountToken, configMaps)
+	if rf, ok := ret.Get(0).(func(context.Context, *v1.Pod, string, map[string]*v1.ConfigMap, map[string]*v1.Secret) error); ok {
+		r0 = rf(ctx, pod, serviceAccountToken, configMaps, secrets)
 	} else {
 		r0 = ret.Error(0)
 	}
@@ -96,6 +98,34 @@ func (_m *VzClientInterface) ExecuteContainerCommand(ctx context.Context, namesp
 	return r0
 }
 
+// ExportVirtualMachine provides a mock function with given fields: ctx, namespace, podName, containerName, targetRef
+func (_m *VzClientInterface) ExportVirtualMachine(ctx context.Context, namespace string, podName string, containerName string, targetRef string) (digest.Digest, error) {
+	ret := _m.Called(ctx, namespace, podName, containerName, targetRef)
+
+	if len(ret) == 0 {
+		panic("no return value specified for ExportVirtualMachine")
+	}
+
+	var r0 digest.Digest
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, string, string, string, string) (digest.Digest, error)); ok {
+		return rf(ctx, namespace, podName, containerName, targetRef)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, string, string, string, string) digest.Digest); ok {
+		r0 = rf(ctx, namespace, podName, containerName, targetRef)
+	} else {
+		r0 = ret.Get(0).(digest.Digest)
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, string, string, string, string) error); ok {
+		r1 = rf(ctx, namespace, podName, containerName, targetRef)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
 // GetContainerLogs provides a mock function with given fields: ctx, namespace, podName, containerName, opts
 func (_m *VzClientInterface) GetContainerLogs(ctx context.Context, namespace string, podName string, containerName string, opts api.ContainerLogOpts) (io.ReadCloser, error) {
 	ret := _m.Called(ctx, namespace, podName, containerName, opts)
@@ -216,6 +246,24 @@ func (_m *VzClientInterface) GetVirtualizationGroupStats(ctx context.Context, na
 	return r0, r1
 }
 
+// ValidatePod provides a mock function with given fields: ctx, pod
+func (_m *VzClientInterface) ValidatePod(ctx context.Context, pod *v1.Pod) error {
+	ret := _m.Called(ctx, pod)
+
+	if len(ret) == 0 {
+		panic("no return value specified for ValidatePod")
+	}
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, *v1.Pod) error); ok {
+		r0 = rf(ctx, pod)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
 // NewVzClientInterface creates a new instance of VzClientInterface. It also registers a testing interface on the mock and a cleanup function to assert the mocks expectations.
 // The first argument is typically a *testing.T value.
 func NewVzClientInterface(t interface {
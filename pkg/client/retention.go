@@ -0,0 +1,26 @@
+package client
+
+import (
+	"strconv"
+
+	"github.com/virtual-kubelet/virtual-kubelet/errdefs"
+	corev1 "k8s.io/api/core/v1"
+)
+
+// RetainFailedVMsAnnotation keeps the macOS VMs of the pod after it fails when "true",
+// or deletes them when "false" regardless of the node default.
+const RetainFailedVMsAnnotation = "macosvz.agoda.com/retain-failed-vms"
+
+// ParseRetainFailedVMs returns whether the macOS VMs of the pod are kept after it fails,
+// applying the annotation of the pod on top of the node default.
+func ParseRetainFailedVMs(pod *corev1.Pod, retain bool) (bool, error) {
+	value, ok := pod.Annotations[RetainFailedVMsAnnotation]
+	if !ok {
+		return retain, nil
+	}
+	parsed, err := strconv.ParseBool(value)
+	if err != nil {
+		return retain, errdefs.InvalidInputf("%s annotation must be a boolean, got %q", RetainFailedVMsAnnotation, value)
+	}
+	return parsed, nil
+}
@@ -0,0 +1,41 @@
+package client_test
+
+import (
+	"testing"
+
+	"github.com/agoda-com/macOS-vz-kubelet/pkg/client"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/virtual-kubelet/virtual-kubelet/errdefs"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestParseRetainFailedVMs(t *testing.T) {
+	tests := []struct {
+		name        string
+		annotations map[string]string
+		retain      bool
+		expected    bool
+		expectError bool
+	}{
+		{name: "node default off", expected: false},
+		{name: "node default on", retain: true, expected: true},
+		{name: "annotation enables", annotations: map[string]string{client.RetainFailedVMsAnnotation: "true"}, expected: true},
+		{name: "annotation disables", annotations: map[string]string{client.RetainFailedVMsAnnotation: "false"}, retain: true, expected: false},
+		{name: "invalid annotation", annotations: map[string]string{client.RetainFailedVMsAnnotation: "forever"}, retain: true, expected: true, expectError: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pod := &corev1.Pod{ObjectMeta: metav1.ObjectMeta{Annotations: tt.annotations}}
+			retain, err := client.ParseRetainFailedVMs(pod, tt.retain)
+			if tt.expectError {
+				assert.True(t, errdefs.IsInvalidInput(err))
+			} else {
+				assert.NoError(t, err)
+			}
+			assert.Equal(t, tt.expected, retain)
+		})
+	}
+}
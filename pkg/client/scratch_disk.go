@@ -0,0 +1,30 @@
+package client
+
+import (
+	"github.com/virtual-kubelet/virtual-kubelet/errdefs"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+)
+
+// ScratchDiskSizeAnnotation is the size of the scratch disk of the pod's macOS VMs whose container sets
+// readOnlyRootFilesystem, as a quantity (e.g. "20Gi"). Their boot disk is attached read-only and the blank
+// scratch disk writable, for the guest to format and mount for its writes. The scratch disk is removed when
+// the VM stops.
+const ScratchDiskSizeAnnotation = "macosvz.agoda.com/scratch-disk-size"
+
+// errNoScratchDisk is returned when a macOS container sets readOnlyRootFilesystem without a scratch disk to write to.
+var errNoScratchDisk = errdefs.InvalidInputf("readOnlyRootFilesystem requires the %s annotation", ScratchDiskSizeAnnotation)
+
+// ParseScratchDiskSize returns the size in bytes of the scratch disk of the pod's macOS VMs, zero if the pod
+// does not set one.
+func ParseScratchDiskSize(pod *corev1.Pod) (uint64, error) {
+	value, ok := pod.Annotations[ScratchDiskSizeAnnotation]
+	if !ok {
+		return 0, nil
+	}
+	quantity, err := resource.ParseQuantity(value)
+	if err != nil || quantity.Sign() <= 0 {
+		return 0, errdefs.InvalidInputf("%s annotation must be a positive quantity, got %q", ScratchDiskSizeAnnotation, value)
+	}
+	return uint64(quantity.Value()), nil
+}
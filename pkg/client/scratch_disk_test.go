@@ -0,0 +1,30 @@
+package client_test
+
+import (
+	"testing"
+
+	"github.com/agoda-com/macOS-vz-kubelet/pkg/client"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/virtual-kubelet/virtual-kubelet/errdefs"
+	corev1 "k8s.io/api/core/v1"
+)
+
+func TestParseScratchDiskSize(t *testing.T) {
+	pod := &corev1.Pod{}
+	size, err := client.ParseScratchDiskSize(pod)
+	require.NoError(t, err)
+	assert.Zero(t, size)
+
+	pod.Annotations = map[string]string{client.ScratchDiskSizeAnnotation: "20Gi"}
+	size, err = client.ParseScratchDiskSize(pod)
+	require.NoError(t, err)
+	assert.Equal(t, uint64(20<<30), size)
+
+	for _, value := range []string{"", "0", "-1Gi", "big"} {
+		pod.Annotations[client.ScratchDiskSizeAnnotation] = value
+		_, err = client.ParseScratchDiskSize(pod)
+		assert.True(t, errdefs.IsInvalidInput(err), value)
+	}
+}
@@ -0,0 +1,26 @@
+package client
+
+import (
+	"strings"
+
+	"github.com/virtual-kubelet/virtual-kubelet/errdefs"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/util/validation"
+)
+
+// SnapshotAnnotation is the name of the snapshot the pod's macOS VMs are restored from instead of booting,
+// see MacOSClient.SaveState. VMs whose snapshot cannot be restored are booted from the disk of the snapshot.
+const SnapshotAnnotation = "macosvz.agoda.com/snapshot"
+
+// ParseSnapshot returns the name of the snapshot of the pod's macOS VMs, empty if the pod does not set one.
+func ParseSnapshot(pod *corev1.Pod) (string, error) {
+	value, ok := pod.Annotations[SnapshotAnnotation]
+	if !ok {
+		return "", nil
+	}
+	// the name selects a directory of the node
+	if errs := validation.IsDNS1123Subdomain(value); len(errs) > 0 {
+		return "", errdefs.InvalidInputf("%s annotation: invalid snapshot name %q: %s", SnapshotAnnotation, value, strings.Join(errs, "; "))
+	}
+	return value, nil
+}
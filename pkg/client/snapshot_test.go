@@ -0,0 +1,30 @@
+package client_test
+
+import (
+	"testing"
+
+	"github.com/agoda-com/macOS-vz-kubelet/pkg/client"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/virtual-kubelet/virtual-kubelet/errdefs"
+	corev1 "k8s.io/api/core/v1"
+)
+
+func TestParseSnapshot(t *testing.T) {
+	pod := &corev1.Pod{}
+	snapshot, err := client.ParseSnapshot(pod)
+	require.NoError(t, err)
+	assert.Empty(t, snapshot)
+
+	pod.Annotations = map[string]string{client.SnapshotAnnotation: "warm-xcode-15.4"}
+	snapshot, err = client.ParseSnapshot(pod)
+	require.NoError(t, err)
+	assert.Equal(t, "warm-xcode-15.4", snapshot)
+
+	for _, value := range []string{"", "..", "../../etc", "Warm"} {
+		pod.Annotations[client.SnapshotAnnotation] = value
+		_, err = client.ParseSnapshot(pod)
+		assert.True(t, errdefs.IsInvalidInput(err), value)
+	}
+}
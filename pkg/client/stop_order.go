@@ -0,0 +1,61 @@
+package client
+
+import (
+	"slices"
+	"strings"
+
+	"github.com/virtual-kubelet/virtual-kubelet/errdefs"
+	corev1 "k8s.io/api/core/v1"
+)
+
+// StopOrderAnnotation defines the order in which the containers of a pod are stopped on deletion
+// as a comma-separated list of container names. Listed containers are stopped one after another,
+// followed by all the remaining containers which are stopped concurrently.
+//
+// @note: Regular (non-macOS) containers are removed together, at the first stage that lists any of them.
+const StopOrderAnnotation = "macosvz.agoda.com/stop-order"
+
+// ParseStopOrder parses and validates the stop order annotation of the pod.
+func ParseStopOrder(pod *corev1.Pod) ([]string, error) {
+	value, ok := pod.Annotations[StopOrderAnnotation]
+	if !ok || strings.TrimSpace(value) == "" {
+		return nil, nil
+	}
+
+	var stopOrder []string
+	for _, name := range strings.Split(value, ",") {
+		name = strings.TrimSpace(name)
+		if !slices.ContainsFunc(pod.Spec.Containers, func(c corev1.Container) bool { return c.Name == name }) {
+			return nil, errdefs.InvalidInputf("%s annotation references unknown container %q", StopOrderAnnotation, name)
+		}
+		if slices.Contains(stopOrder, name) {
+			return nil, errdefs.InvalidInputf("%s annotation references container %q more than once", StopOrderAnnotation, name)
+		}
+		stopOrder = append(stopOrder, name)
+	}
+	return stopOrder, nil
+}
+
+// StopStages groups the container names into stages that are stopped one after another.
+// Every container listed in the stop order gets its own stage, while the remaining
+// containers are put together in the last stage.
+func StopStages(containerNames, stopOrder []string) [][]string {
+	var stages [][]string
+	for _, name := range stopOrder {
+		if slices.Contains(containerNames, name) {
+			stages = append(stages, []string{name})
+		}
+	}
+
+	var rest []string
+	for _, name := range containerNames {
+		if !slices.Contains(stopOrder, name) {
+			rest = append(rest, name)
+		}
+	}
+	if len(rest) > 0 {
+		stages = append(stages, rest)
+	}
+
+	return stages
+}
@@ -0,0 +1,107 @@
+package client_test
+
+import (
+	"testing"
+
+	"github.com/agoda-com/macOS-vz-kubelet/pkg/client"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/virtual-kubelet/virtual-kubelet/errdefs"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func newStopOrderPod(stopOrder string, containerNames ...string) *corev1.Pod {
+	pod := &corev1.Pod{}
+	if stopOrder != "" {
+		pod.ObjectMeta = metav1.ObjectMeta{
+			Annotations: map[string]string{client.StopOrderAnnotation: stopOrder},
+		}
+	}
+	for _, name := range containerNames {
+		pod.Spec.Containers = append(pod.Spec.Containers, corev1.Container{Name: name})
+	}
+	return pod
+}
+
+func TestParseStopOrder(t *testing.T) {
+	tests := []struct {
+		name          string
+		pod           *corev1.Pod
+		expectedOrder []string
+		expectError   bool
+	}{
+		{
+			name: "No annotation",
+			pod:  newStopOrderPod("", "macos", "sidecar"),
+		},
+		{
+			name:          "Valid stop order",
+			pod:           newStopOrderPod("sidecar, macos", "macos", "sidecar"),
+			expectedOrder: []string{"sidecar", "macos"},
+		},
+		{
+			name:        "Unknown container",
+			pod:         newStopOrderPod("macos,unknown", "macos", "sidecar"),
+			expectError: true,
+		},
+		{
+			name:        "Duplicate container",
+			pod:         newStopOrderPod("macos,macos", "macos", "sidecar"),
+			expectError: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			stopOrder, err := client.ParseStopOrder(tt.pod)
+			if tt.expectError {
+				require.Error(t, err)
+				assert.True(t, errdefs.IsInvalidInput(err))
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.expectedOrder, stopOrder)
+		})
+	}
+}
+
+func TestStopStages(t *testing.T) {
+	tests := []struct {
+		name           string
+		containerNames []string
+		stopOrder      []string
+		expectedStages [][]string
+	}{
+		{
+			name:           "No stop order stops everything concurrently",
+			containerNames: []string{"macos-a", "macos-b", "sidecar"},
+			expectedStages: [][]string{{"macos-a", "macos-b", "sidecar"}},
+		},
+		{
+			name:           "Listed containers are stopped in the specified order",
+			containerNames: []string{"macos-a", "macos-b", "sidecar"},
+			stopOrder:      []string{"macos-b", "macos-a"},
+			expectedStages: [][]string{{"macos-b"}, {"macos-a"}, {"sidecar"}},
+		},
+		{
+			name:           "All containers listed",
+			containerNames: []string{"macos-a", "macos-b"},
+			stopOrder:      []string{"macos-b", "macos-a"},
+			expectedStages: [][]string{{"macos-b"}, {"macos-a"}},
+		},
+		{
+			name:           "Unknown containers are ignored",
+			containerNames: []string{"macos-a"},
+			stopOrder:      []string{"unknown", "macos-a"},
+			expectedStages: [][]string{{"macos-a"}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expectedStages, client.StopStages(tt.containerNames, tt.stopOrder))
+		})
+	}
+}
@@ -0,0 +1,24 @@
+package client
+
+import (
+	"github.com/agoda-com/macOS-vz-kubelet/internal/utils"
+
+	"github.com/virtual-kubelet/virtual-kubelet/errdefs"
+	corev1 "k8s.io/api/core/v1"
+)
+
+// TimezoneAnnotation is the timezone set inside the pod's macOS VMs once booted, as a name of the tz database
+// (e.g. "Asia/Bangkok"). Without it, the VMs keep the timezone of their image.
+const TimezoneAnnotation = "macosvz.agoda.com/timezone"
+
+// ParseTimezone returns the timezone of the pod's macOS VMs, empty if the pod does not set one.
+func ParseTimezone(pod *corev1.Pod) (string, error) {
+	value, ok := pod.Annotations[TimezoneAnnotation]
+	if !ok {
+		return "", nil
+	}
+	if err := utils.ValidateTimezone(value); err != nil {
+		return "", errdefs.InvalidInputf("%s annotation: %v", TimezoneAnnotation, err)
+	}
+	return value, nil
+}
@@ -0,0 +1,40 @@
+package client_test
+
+import (
+	"testing"
+
+	"github.com/agoda-com/macOS-vz-kubelet/pkg/client"
+	rm "github.com/agoda-com/macOS-vz-kubelet/pkg/resourcemanager"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/virtual-kubelet/virtual-kubelet/errdefs"
+	corev1 "k8s.io/api/core/v1"
+)
+
+func TestParseTimezone(t *testing.T) {
+	pod := &corev1.Pod{}
+	timezone, err := client.ParseTimezone(pod)
+	require.NoError(t, err)
+	assert.Empty(t, timezone)
+
+	pod.Annotations = map[string]string{client.TimezoneAnnotation: "Asia/Bangkok"}
+	timezone, err = client.ParseTimezone(pod)
+	require.NoError(t, err)
+	assert.Equal(t, "Asia/Bangkok", timezone)
+
+	for _, value := range []string{"", "Asia/Atlantis", "UTC' && reboot '"} {
+		pod.Annotations[client.TimezoneAnnotation] = value
+		_, err = client.ParseTimezone(pod)
+		assert.True(t, errdefs.IsInvalidInput(err), value)
+	}
+}
+
+func TestAdmissionProblemsTimezone(t *testing.T) {
+	pod := admissionTestPod()
+	pod.Annotations = map[string]string{client.TimezoneAnnotation: "Asia/Atlantis"}
+
+	problems := client.AdmissionProblems(pod, true, rm.ExtendedResourceNames{})
+	require.Len(t, problems, 1)
+	assert.Contains(t, problems[0], client.TimezoneAnnotation)
+}
@@ -6,6 +6,7 @@ import (
 	"io"
 	"os"
 	"path/filepath"
+	"slices"
 	"sync"
 	"time"
 
@@ -17,6 +18,7 @@ import (
 	"github.com/agoda-com/macOS-vz-kubelet/pkg/resource"
 	rm "github.com/agoda-com/macOS-vz-kubelet/pkg/resourcemanager"
 	"github.com/agoda-com/macOS-vz-kubelet/pkg/vm"
+	"github.com/agoda-com/macOS-vz-kubelet/pkg/vm/config"
 
 	"github.com/virtual-kubelet/virtual-kubelet/errdefs"
 	"github.com/virtual-kubelet/virtual-kubelet/log"
@@ -24,7 +26,7 @@ import (
 	"github.com/virtual-kubelet/virtual-kubelet/trace"
 	stats "k8s.io/kubelet/pkg/apis/stats/v1alpha1"
 
-	docker "github.com/moby/moby/client"
+	"github.com/opencontainers/go-digest"
 	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/types"
 )
@@ -40,149 +42,223 @@ const (
 	// as of now k8s does not support setting custom timeout for post-start command at all.
 	// Setting this constant as default for now (usually post-start should be something lite anyway).
 	PostStartCommandTimeout = 10 * time.Second
+
+	// ContainerClientRetryInterval is the interval between attempts to create the container client
+	// when the container runtime is not available on startup.
+	ContainerClientRetryInterval = 30 * time.Second
+
+	// ImageRemovalTimeout bounds how long the deletion of a pod waits for the downloads of its unused images to end,
+	// e.g. the canceled download of the pod itself, before keeping the images.
+	ImageRemovalTimeout = 30 * time.Second
 )
 
 var (
 	// errVirtualizationGroupNotFound is returned when a virtualization group is not found.
 	errVirtualizationGroupNotFound = errdefs.NotFound("virtualization group not found")
+
+	// errNoImage is returned when a macOS container has no image and no default macOS image is configured.
+	errNoImage = errdefs.InvalidInput("no image set and no default macOS image configured")
 )
 
 // virtualizationGroupExtras contains additional information for a virtualization group.
 type virtualizationGroupExtras struct {
+	uid        types.UID          // UID of the pod the virtualization group was created for
 	rootDir    string             // root directory for the volumes of the pod
 	cancelFunc context.CancelFunc // context cancellation function for the virtualization group
 
+	containerNames  []string // names of the pod containers, the first one is the macOS container
+	macOSContainers []string // names of the containers running as macOS virtual machines, the first container first
+	stopOrder       []string // order in which the containers are stopped on deletion
+	images          []string // images of the macOS containers
+
 	deleteOnce sync.Once  // ensures that the virtualization group is deleted only once
 	deleteDone chan error // signals that the virtualization group has been deleted
 }
 
-// VzClientAPIs is a concrete implementation of VzClientInterface, using MacOSClient and ContainerClient.
+// virtualMachineName returns the name of the virtual machine of the macOS container of the pod.
+func (e *virtualizationGroupExtras) virtualMachineName(podName, containerName string) string {
+	if len(e.macOSContainers) == 0 || containerName == e.macOSContainers[0] {
+		return podName
+	}
+	return rm.AdditionalVirtualMachineName(podName, containerName)
+}
+
+// isMacOSContainer reports whether the container runs as a macOS virtual machine.
+func (e *virtualizationGroupExtras) isMacOSContainer(containerName string) bool {
+	return slices.Contains(e.macOSContainers, containerName)
+}
+
+// ContainersClientFactory creates the client managing the regular containers.
+type ContainersClientFactory func(ctx context.Context) (rm.ContainersClient, error)
+
+// VzClientAPIs is a concrete implementation of VzClientInterface, using MacOSClient and ContainersClient.
 type VzClientAPIs struct {
-	MacOSClient     *rm.MacOSClient
-	ContainerClient rm.ContainersClient // Optional
+	MacOSClient *rm.MacOSClient
+
+	containerClientMu sync.RWMutex
+	containerClient   rm.ContainersClient // Optional, may become available after startup
 
 	cachePath string
 	extras    sync.Map // map[types.NamespacedName]*virtualizationGroupExtras
 }
 
 // NewVzClientAPIs initializes and returns a new VzClientAPIs instance.
-func NewVzClientAPIs(ctx context.Context, eventRecorder event.EventRecorder, networkInterfaceIdentifier, cachePath string, dockerCl *docker.Client) (client *VzClientAPIs) {
+func NewVzClientAPIs(ctx context.Context, eventRecorder event.EventRecorder, networkInterfaceIdentifier, cachePath string, containerClient rm.ContainersClient, macOSOpts ...rm.MacOSClientOption) (client *VzClientAPIs) {
 	ctx, span := trace.StartSpan(ctx, "VZClient.NewVzClientAPIs")
 	defer span.End()
 
 	// force remove dangling mounts
-	_ = os.RemoveAll(filepath.Join(cachePath, PodMountsDir))
-
-	client = &VzClientAPIs{
-		MacOSClient: rm.NewMacOSClient(ctx, eventRecorder, networkInterfaceIdentifier, cachePath),
-		cachePath:   cachePath,
+	mountsDir := filepath.Join(cachePath, PodMountsDir)
+	if entries, err := os.ReadDir(mountsDir); err == nil {
+		for _, entry := range entries {
+			_ = volumes.RemoveMemoryVolumes(ctx, filepath.Join(mountsDir, entry.Name()))
+		}
 	}
+	_ = os.RemoveAll(mountsDir)
 
-	containerClient, err := rm.NewDockerClient(ctx, dockerCl, eventRecorder)
-	if err != nil {
-		log.G(ctx).WithError(err).Warn("Failed to create container client")
-	}
-	if containerClient != nil {
-		client.ContainerClient = containerClient
+	return &VzClientAPIs{
+		MacOSClient:     rm.NewMacOSClient(ctx, eventRecorder, networkInterfaceIdentifier, cachePath, macOSOpts...),
+		containerClient: containerClient,
+		cachePath:       cachePath,
 	}
+}
+
+// CachePath returns the directory of the image cache and pod volumes.
+func (c *VzClientAPIs) CachePath() string {
+	return c.cachePath
+}
+
+// ContainerClient returns the client managing the regular containers, or nil if it is not available.
+func (c *VzClientAPIs) ContainerClient() rm.ContainersClient {
+	c.containerClientMu.RLock()
+	defer c.containerClientMu.RUnlock()
+	return c.containerClient
+}
+
+// InitContainerClient periodically attempts to create the container client using the factory until it succeeds
+// or the context is done. It returns immediately if the container client is already available.
+// This allows regular containers to be supported once the container runtime becomes available after startup.
+func (c *VzClientAPIs) InitContainerClient(ctx context.Context, interval time.Duration, factory ContainersClientFactory) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		if c.ContainerClient() != nil {
+			return
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
 
-	return client
+		containerClient, err := factory(ctx)
+		if err != nil {
+			log.G(ctx).WithError(err).Debug("Container client is still unavailable")
+			continue
+		}
+
+		c.containerClientMu.Lock()
+		if c.containerClient == nil {
+			c.containerClient = containerClient
+		}
+		c.containerClientMu.Unlock()
+		log.G(ctx).Info("Container client is available, regular containers are now supported")
+	}
 }
 
 // CreateVirtualizationGroup creates a new virtualization group based on the provided Kubernetes pod.
-func (c *VzClientAPIs) CreateVirtualizationGroup(ctx context.Context, pod *corev1.Pod, serviceAccountToken string, configMaps map[string]*corev1.ConfigMap) (err error) {
+// Config maps and secrets referenced by the pod are expected to be fetched by the caller, keyed by name.
+func (c *VzClientAPIs) CreateVirtualizationGroup(ctx context.Context, pod *corev1.Pod, serviceAccountToken string, configMaps map[string]*corev1.ConfigMap, secrets map[string]*corev1.Secret) (err error) {
 	ctx, span := trace.StartSpan(ctx, "VZClient.CreateVirtualizationGroup")
+	ctx = span.WithFields(ctx, utils.TraceFields(ctx))
 	key := types.NamespacedName{Namespace: pod.Namespace, Name: pod.Name}
+	defer func() {
+		span.SetStatus(err)
+		span.End()
+	}()
+
+	// virtual-kubelet may deliver the creation of a pod again, the existing group is left undisturbed
+	if existing, ok := c.getExtras(key); ok {
+		return c.duplicateVirtualizationGroup(ctx, pod, existing)
+	}
+
 	extras := &virtualizationGroupExtras{
+		uid:        pod.UID,
 		rootDir:    c.getPodVolumeRoot(pod),
 		deleteDone: make(chan error, 1),
 	}
+	stored := false
 	defer func() {
-		span.SetStatus(err)
-		span.End()
-
-		// cleanup if an error occurred
-		if err != nil {
-			c.extras.Delete(key)
-			if err := os.RemoveAll(extras.rootDir); err != nil {
-				log.G(ctx).WithError(err).Warn("Failed to clean up pod volume root")
-			}
-			if extras.cancelFunc != nil {
-				extras.cancelFunc()
-			}
+		// cleanup if an error occurred, unless the group was created by another call
+		if err != nil && stored {
+			c.extras.CompareAndDelete(key, extras)
+			removePodVolumeRoot(ctx, extras.rootDir)
+		}
+		if err != nil && extras.cancelFunc != nil {
+			extras.cancelFunc()
 		}
 	}()
 
+	extras.macOSContainers, err = ParseMacOSContainers(pod)
+	if err != nil {
+		return err
+	}
+	pod = ApplyDefaultImage(pod, extras.macOSContainers, c.MacOSClient.DefaultImage())
+
 	// If the pod has regular containers, the ContainerClient must be available.
-	if len(pod.Spec.Containers) > 1 && c.ContainerClient == nil {
+	containerClient := c.ContainerClient()
+	if len(pod.Spec.Containers) > len(extras.macOSContainers) && containerClient == nil {
 		return errdefs.InvalidInput("regular containers are not supported")
 	}
 
+	extras.stopOrder, err = ParseStopOrder(pod)
+	if err != nil {
+		return err
+	}
+	devices, err := ParseDeviceOptions(pod, c.MacOSClient.DefaultDevices())
+	if err != nil {
+		return err
+	}
+	diskMode, err := ParseDiskMode(pod, c.MacOSClient.DiskMode())
+	if err != nil {
+		return err
+	}
+	env := make([][]corev1.EnvVar, len(pod.Spec.Containers))
+	for i, container := range pod.Spec.Containers {
+		extras.containerNames = append(extras.containerNames, container.Name)
+		if extras.isMacOSContainer(container.Name) && !slices.Contains(extras.images, container.Image) {
+			extras.images = append(extras.images, container.Image)
+		}
+		if env[i], err = ResolveEnv(pod, container, configMaps, secrets); err != nil {
+			return err
+		}
+	}
+
 	// Due to the nature of virtual kubelet CreatePod context,
 	// we need to handle the context cancellation on demand ourselves
 	ctx, extras.cancelFunc = context.WithCancel(ctx)
 
 	// Store the extras for the virtualization group before doing any async work
-	c.extras.Store(key, extras)
-
-	g := errgroup.Group{}
-	g.Go(func() error {
-		// vz: always assume that first container is macOS container
-		macOSContainer := pod.Spec.Containers[0]
-
-		// Extract and validate CPU and memory requests
-		rl := macOSContainer.Resources.Requests
-		cpu, err := utils.ExtractCPURequest(rl)
-		if err != nil {
-			return errdefs.AsInvalidInput(err)
-		}
-		_, err = vm.ValidateCPUCount(cpu)
-		if err != nil {
-			return errdefs.AsInvalidInput(err)
-		}
-		memorySize, err := utils.ExtractMemoryRequest(rl)
-		if err != nil {
-			return errdefs.AsInvalidInput(err)
-		}
-		_, err = vm.ValidateMemorySize(memorySize)
-		if err != nil {
-			return errdefs.AsInvalidInput(err)
-		}
-
-		mounts, err := volumes.CreateContainerMounts(ctx, extras.rootDir, macOSContainer, pod, serviceAccountToken, configMaps)
-		if err != nil {
-			return err
-		}
-
-		image := macOSContainer.Image
-		pullPolicy := macOSContainer.ImagePullPolicy
+	if existing, loaded := c.extras.LoadOrStore(key, extras); loaded {
+		extras.cancelFunc()
+		existingExtras, _ := existing.(*virtualizationGroupExtras)
+		return c.duplicateVirtualizationGroup(ctx, pod, existingExtras)
+	}
+	stored = true
 
-		var postStartAction *resource.ExecAction
-		if lifecycle := macOSContainer.Lifecycle; lifecycle != nil && lifecycle.PostStart != nil && lifecycle.PostStart.Exec != nil {
-			postStartAction = &resource.ExecAction{
-				Command:         lifecycle.PostStart.Exec.Command,
-				TimeoutDuration: PostStartCommandTimeout,
-			}
-		}
+	// Memory-backed volumes are shared by all the containers, so they are mounted once for the pod
+	if err = volumes.CreateMemoryVolumes(ctx, extras.rootDir, pod); err != nil {
+		return err
+	}
 
-		return c.MacOSClient.CreateVirtualMachine(ctx, rm.VirtualMachineParams{
-			UID:              string(pod.UID),
-			Image:            image,
-			Namespace:        pod.Namespace,
-			Name:             pod.Name,
-			ContainerName:    macOSContainer.Name,
-			CPU:              cpu,
-			MemorySize:       memorySize,
-			Mounts:           mounts,
-			Env:              macOSContainer.Env,
-			PostStartAction:  postStartAction,
-			IgnoreImageCache: pullPolicy == corev1.PullAlways,
-		})
-	})
+	// the containers created so far are removed if the creation of another one fails
+	created := make([]bool, len(pod.Spec.Containers))
+	g := errgroup.Group{}
+	for i, container := range pod.Spec.Containers {
+		g.Go(func() (err error) {
+			defer func() { created[i] = err == nil }()
 
-	for i := 1; i < len(pod.Spec.Containers); i++ {
-		container := pod.Spec.Containers[i]
-		g.Go(func() error {
 			mounts, err := volumes.CreateContainerMounts(ctx, extras.rootDir, container, pod, serviceAccountToken, configMaps)
 			if err != nil {
 				return err
@@ -196,7 +272,11 @@ func (c *VzClientAPIs) CreateVirtualizationGroup(ctx context.Context, pod *corev
 				}
 			}
 
-			return c.ContainerClient.CreateContainer(
+			if extras.isMacOSContainer(container.Name) {
+				return c.createVirtualMachine(ctx, pod, container, i == 0, mounts, env[i], postStartAction, devices, diskMode)
+			}
+
+			return containerClient.CreateContainer(
 				ctx,
 				rm.ContainerParams{
 					PodNamespace:    pod.Namespace,
@@ -205,7 +285,7 @@ func (c *VzClientAPIs) CreateVirtualizationGroup(ctx context.Context, pod *corev
 					Image:           container.Image,
 					ImagePullPolicy: container.ImagePullPolicy,
 					Mounts:          mounts,
-					Env:             container.Env,
+					Env:             env[i],
 					Command:         container.Command,
 					Args:            container.Args,
 					WorkingDir:      container.WorkingDir,
@@ -213,12 +293,157 @@ func (c *VzClientAPIs) CreateVirtualizationGroup(ctx context.Context, pod *corev
 					Stdin:           container.Stdin,
 					StdinOnce:       container.StdinOnce,
 					PostStartAction: postStartAction,
+
+					ReadOnlyRootFilesystem: readOnlyRootFilesystem(container),
+					Hostname:               utils.PodHostname(pod),
+					Domainname:             utils.PodDomain(pod),
 				},
 			)
 		})
 	}
 
-	return g.Wait()
+	if err = g.Wait(); err != nil {
+		c.removeCreatedContainers(ctx, pod, extras, containerClient, created)
+	}
+	return err
+}
+
+// removeCreatedContainers removes the virtual machines and regular containers created for the pod, before
+// the creation of another container failed. Otherwise they would keep their slots with no pod left to delete them.
+func (c *VzClientAPIs) removeCreatedContainers(ctx context.Context, pod *corev1.Pod, extras *virtualizationGroupExtras, containerClient rm.ContainersClient, created []bool) {
+	containersCreated := false
+	for i, container := range pod.Spec.Containers {
+		switch {
+		case !created[i]:
+		case extras.isMacOSContainer(container.Name):
+			name := extras.virtualMachineName(pod.Name, container.Name)
+			if err := c.MacOSClient.DeleteVirtualMachine(ctx, pod.Namespace, name, 0); err != nil {
+				log.G(ctx).WithError(err).Warnf("Failed to delete virtual machine %s of the failed virtualization group", name)
+			}
+		default:
+			containersCreated = true
+		}
+	}
+	if containersCreated {
+		if err := containerClient.RemoveContainers(ctx, pod.Namespace, pod.Name, 0); err != nil {
+			log.G(ctx).WithError(err).Warn("Failed to remove containers of the failed virtualization group")
+		}
+	}
+}
+
+// duplicateVirtualizationGroup handles the creation of a virtualization group that already exists. The creation
+// of the same pod succeeds without touching the group, while another pod of the same name has to wait until
+// the group of the previous one is deleted.
+func (c *VzClientAPIs) duplicateVirtualizationGroup(ctx context.Context, pod *corev1.Pod, existing *virtualizationGroupExtras) error {
+	if existing != nil && existing.uid != pod.UID {
+		return errdefs.InvalidInputf("virtualization group of pod %s/%s already exists for pod UID %s", pod.Namespace, pod.Name, existing.uid)
+	}
+	log.G(ctx).Debug("Virtualization group already exists, ignoring the repeated creation")
+	return nil
+}
+
+// createVirtualMachine creates the virtual machine of a macOS container of the pod. The virtual machine
+// of the first container is named after the pod, additional ones are named after the pod and the container.
+func (c *VzClientAPIs) createVirtualMachine(ctx context.Context, pod *corev1.Pod, container corev1.Container, primary bool, mounts []volumes.Mount, env []corev1.EnvVar, postStartAction *resource.ExecAction, devices config.DeviceOptions, diskMode config.DiskMode) error {
+	// A read-only boot disk needs a scratch disk for the writes of the guest
+	if readOnlyRootFilesystem(container) {
+		scratchDiskSize, err := ParseScratchDiskSize(pod)
+		if err != nil {
+			return err
+		}
+		if scratchDiskSize == 0 {
+			return errdefs.InvalidInputf("container %s: %s", container.Name, errNoScratchDisk)
+		}
+		devices.ScratchDiskSize = scratchDiskSize
+	}
+	if container.Image == "" {
+		return errdefs.InvalidInputf("container %s: %s", container.Name, errNoImage)
+	}
+	timezone, err := ParseTimezone(pod)
+	if err != nil {
+		return err
+	}
+	snapshot, err := ParseSnapshot(pod)
+	if err != nil {
+		return err
+	}
+	agentHealth, err := ParseAgentHealth(pod)
+	if err != nil {
+		return err
+	}
+
+	// Extract and validate CPU and memory requests
+	rl := container.Resources.Requests
+	extended := c.MacOSClient.ExtendedResourceNames()
+	cpu, err := utils.ExtractCPURequest(rl, extended.CPU...)
+	if err != nil {
+		return errdefs.AsInvalidInput(err)
+	}
+	_, err = vm.ValidateCPUCount(cpu)
+	if err != nil {
+		return errdefs.AsInvalidInput(err)
+	}
+	memorySize, err := utils.ExtractMemoryRequest(rl, extended.Memory...)
+	if err != nil {
+		return errdefs.AsInvalidInput(err)
+	}
+	_, err = vm.ValidateMemorySize(memorySize)
+	if err != nil {
+		return errdefs.AsInvalidInput(err)
+	}
+
+	// additional virtual machines need their own disk clones, which are named after the UID
+	uid, name := string(pod.UID), pod.Name
+	if !primary {
+		uid += "-" + container.Name
+		name = rm.AdditionalVirtualMachineName(pod.Name, container.Name)
+	}
+
+	// the command and arguments of the container run inside the virtual machine once started
+	var command []string
+	if len(container.Command) > 0 || len(container.Args) > 0 {
+		command = utils.BuildEntrypointCommand(container.Command, container.Args)
+	}
+
+	return c.MacOSClient.CreateVirtualMachine(ctx, rm.VirtualMachineParams{
+		UID:              uid,
+		Image:            container.Image,
+		Namespace:        pod.Namespace,
+		Name:             name,
+		ContainerName:    container.Name,
+		CPU:              cpu,
+		MemorySize:       memorySize,
+		Mounts:           mounts,
+		Env:              env,
+		HostAliases:      pod.Spec.HostAliases,
+		Hostname:         utils.PodHostname(pod),
+		Domain:           utils.PodDomain(pod),
+		Timezone:         timezone,
+		Sysctls:          podSysctls(pod),
+		PostStartAction:  postStartAction,
+		IgnoreImageCache: container.ImagePullPolicy == corev1.PullAlways,
+		ImagePullPolicy:  container.ImagePullPolicy,
+		Command:          command,
+		ActiveDeadline:   activeDeadline(pod),
+		Devices:          devices,
+		DiskMode:         diskMode,
+		Snapshot:         snapshot,
+		AgentHealth:      agentHealth,
+		Priority:         podPriority(pod),
+
+		EphemeralStorageLimit: ephemeralStorageLimit(container),
+	})
+}
+
+// removeUnusedImages removes the cached content of the images no longer used by any virtual machine.
+func (c *VzClientAPIs) removeUnusedImages(ctx context.Context, images []string) {
+	ctx, cancel := context.WithTimeout(ctx, ImageRemovalTimeout)
+	defer cancel()
+	for _, image := range images {
+		if _, err := c.MacOSClient.RemoveUnusedImage(ctx, image); err != nil {
+			log.G(ctx).WithError(err).Warnf("Failed to remove unused image %q", image)
+		}
+	}
 }
 
 // DeleteVirtualizationGroup deletes an existing virtualization group specified by namespace and name.
@@ -230,12 +455,7 @@ func (c *VzClientAPIs) DeleteVirtualizationGroup(ctx context.Context, namespace,
 	}()
 
 	key := types.NamespacedName{Namespace: namespace, Name: name}
-	extrasValue, loaded := c.extras.Load(key)
-	if !loaded {
-		return errVirtualizationGroupNotFound
-	}
-
-	extras, ok := extrasValue.(*virtualizationGroupExtras)
+	extras, ok := c.getExtras(key)
 	if !ok {
 		return errVirtualizationGroupNotFound
 	}
@@ -254,33 +474,49 @@ func (c *VzClientAPIs) DeleteVirtualizationGroup(ctx context.Context, namespace,
 			}
 
 			if extras.rootDir != "" {
-				if err := os.RemoveAll(extras.rootDir); err != nil {
-					log.G(ctx).WithError(err).Warn("Failed to clean up pod volume root")
-				}
+				removePodVolumeRoot(ctx, extras.rootDir)
 			}
 		}()
 
-		var wg sync.WaitGroup
-		var vmErr, containerErr error
-
-		// Delete virtual machine
-		wg.Add(1)
-		go func() {
-			defer wg.Done()
-			vmErr = c.MacOSClient.DeleteVirtualMachine(ctx, namespace, name, gracePeriod)
-		}()
-
-		// Delete containers
-		if c.ContainerClient != nil {
-			wg.Add(1)
-			go func() {
-				defer wg.Done()
-				containerErr = c.ContainerClient.RemoveContainers(ctx, namespace, name, gracePeriod)
-			}()
+		var containerErr error
+		vmErrs := make([]error, len(extras.macOSContainers))
+		containerClient := c.ContainerClient()
+		containersRemoved := false
+
+		// Stop the group stage by stage, everything within a single stage is stopped concurrently
+		for _, stage := range StopStages(extras.containerNames, extras.stopOrder) {
+			var wg sync.WaitGroup
+			for _, containerName := range stage {
+				switch i := slices.Index(extras.macOSContainers, containerName); {
+				case i >= 0:
+					// Delete virtual machine
+					wg.Add(1)
+					go func() {
+						defer wg.Done()
+						vmErrs[i] = c.MacOSClient.DeleteVirtualMachine(ctx, namespace, extras.virtualMachineName(name, containerName), gracePeriod)
+					}()
+				case containerClient != nil && !containersRemoved:
+					// Delete containers, all of them are removed at once
+					containersRemoved = true
+					wg.Add(1)
+					go func() {
+						defer wg.Done()
+						containerErr = containerClient.RemoveContainers(ctx, namespace, name, gracePeriod)
+					}()
+				}
+			}
+			wg.Wait() // Wait for the stage to complete
+		}
+		if containerClient != nil && !containersRemoved {
+			// Make sure no containers are left behind even if the pod spec didn't list any
+			containerErr = containerClient.RemoveContainers(ctx, namespace, name, gracePeriod)
 		}
 
-		wg.Wait() // Wait for both operations to complete
+		if c.MacOSClient.ImageCleanup() {
+			c.removeUnusedImages(ctx, extras.images)
+		}
 
+		vmErr := errors.Join(vmErrs...)
 		switch {
 		case vmErr != nil && containerErr != nil:
 			if errdefs.IsNotFound(vmErr) && errdefs.IsNotFound(containerErr) {
@@ -314,55 +550,75 @@ func (c *VzClientAPIs) DeleteVirtualizationGroup(ctx context.Context, namespace,
 }
 
 // GetVirtualizationGroup retrieves the details of a specified virtualization group.
+// It returns a not found error if neither the virtual machine nor the containers exist, and the group
+// with the parts found if only some of them do. Any other failure is returned without the group.
 func (c *VzClientAPIs) GetVirtualizationGroup(ctx context.Context, namespace, name string) (vg *VirtualizationGroup, err error) {
 	ctx, span := trace.StartSpan(ctx, "VZClient.GetVirtualizationGroup")
 	defer func() {
 		span.SetStatus(err)
 		span.End()
 	}()
+	logger := log.G(ctx)
 
 	var containers []resource.Container
 	var vm resource.MacOSVirtualMachine
 	var containerErr, vmErr error
 
 	// Fetch containers
-	if c.ContainerClient != nil {
-		containers, containerErr = c.ContainerClient.GetContainers(ctx, namespace, name)
-		if containerErr != nil && !errdefs.IsNotFound(containerErr) {
-			err = containerErr
-		}
+	if containerClient := c.ContainerClient(); containerClient != nil {
+		containers, containerErr = containerClient.GetContainers(ctx, namespace, name)
 	} else {
 		containerErr = errdefs.NotFound("container client not available")
 	}
 
 	// Fetch virtual machine
 	vm, vmErr = c.MacOSClient.GetVirtualMachine(ctx, namespace, name)
-	if vmErr != nil && !errdefs.IsNotFound(vmErr) {
-		if err != nil {
-			err = errors.Join(err, vmErr)
-		} else {
-			err = vmErr
+
+	// Only hard failures are returned, missing parts of the group are not errors on their own
+	for _, e := range []error{containerErr, vmErr} {
+		if e != nil && !errdefs.IsNotFound(e) {
+			err = errors.Join(err, e)
 		}
 	}
-
-	// If both clients return not found errors, return a combined not found error
-	if errdefs.IsNotFound(containerErr) && errdefs.IsNotFound(vmErr) {
-		return nil, errVirtualizationGroupNotFound
+	if err != nil {
+		return nil, err
 	}
 
-	// If both clients return errors, combine them
+	// If both clients return not found errors, the group does not exist
 	if containerErr != nil && vmErr != nil {
-		return &VirtualizationGroup{
-			Containers:          containers,
-			MacOSVirtualMachine: &vm,
-		}, errors.Join(containerErr, vmErr)
+		return nil, errVirtualizationGroupNotFound
 	}
 
-	// Return the virtualization group with any existing values
-	return &VirtualizationGroup{
+	vg = &VirtualizationGroup{
 		Containers:          containers,
 		MacOSVirtualMachine: &vm,
-	}, err
+	}
+
+	extras, _ := c.getExtras(types.NamespacedName{Namespace: namespace, Name: name})
+	if vmErr != nil {
+		logger.WithError(vmErr).Warnf("Virtual machine of virtualization group %s/%s not found", namespace, name)
+	}
+	if containerErr != nil && extras != nil && len(extras.containerNames) > len(extras.macOSContainers) {
+		logger.WithError(containerErr).Warnf("Containers of virtualization group %s/%s not found", namespace, name)
+	}
+
+	// Fetch additional virtual machines
+	if extras != nil && len(extras.macOSContainers) > 1 {
+		vg.AdditionalVirtualMachines = make(map[string]resource.VirtualMachine, len(extras.macOSContainers)-1)
+		for _, containerName := range extras.macOSContainers[1:] {
+			additionalVM, additionalErr := c.MacOSClient.GetVirtualMachine(ctx, namespace, extras.virtualMachineName(name, containerName))
+			if additionalErr != nil {
+				if !errdefs.IsNotFound(additionalErr) {
+					return nil, additionalErr
+				}
+				logger.WithError(additionalErr).Warnf("Virtual machine of container %s of virtualization group %s/%s not found", containerName, namespace, name)
+				continue
+			}
+			vg.AdditionalVirtualMachines[containerName] = &additionalVM
+		}
+	}
+
+	return vg, nil
 }
 
 // GetVirtualizationGroupListResult retrieves a list of all virtualization groups.
@@ -390,11 +646,11 @@ func (c *VzClientAPIs) GetVirtualizationGroupListResult(ctx context.Context) (l
 	}()
 
 	// Fetch containers
-	if c.ContainerClient != nil {
+	if containerClient := c.ContainerClient(); containerClient != nil {
 		wg.Add(1)
 		go func() {
 			defer wg.Done()
-			containers, containerErr = c.ContainerClient.GetContainersListResult(ctx)
+			containers, containerErr = containerClient.GetContainersListResult(ctx)
 			if containerErr != nil {
 				logger.WithError(containerErr).Warn("Error getting container list")
 			}
@@ -417,11 +673,32 @@ func (c *VzClientAPIs) GetVirtualizationGroupListResult(ctx context.Context) (l
 
 	// Combine the results
 	for k, v := range vms {
+		if _, containerName := rm.SplitVirtualMachineName(k.Name); containerName != "" {
+			continue
+		}
 		l[k] = &VirtualizationGroup{
 			MacOSVirtualMachine: &v,
 		}
 	}
 
+	// Group additional virtual machines with the virtual machine of their pod
+	for k, v := range vms {
+		podName, containerName := rm.SplitVirtualMachineName(k.Name)
+		if containerName == "" {
+			continue
+		}
+		key := types.NamespacedName{Namespace: k.Namespace, Name: podName}
+		vg, exists := l[key]
+		if !exists {
+			vg = &VirtualizationGroup{}
+			l[key] = vg
+		}
+		if vg.AdditionalVirtualMachines == nil {
+			vg.AdditionalVirtualMachines = make(map[string]resource.VirtualMachine)
+		}
+		vg.AdditionalVirtualMachines[containerName] = &v
+	}
+
 	for k, c := range containers {
 		if vg, exists := l[k]; exists {
 			vg.Containers = c
@@ -443,8 +720,8 @@ func (c *VzClientAPIs) GetContainerLogs(ctx context.Context, namespace, podName,
 		span.End()
 	}()
 
-	if c.ContainerClient != nil && c.ContainerClient.IsContainerPresent(ctx, namespace, podName, containerName) {
-		return c.ContainerClient.GetContainerLogs(ctx, namespace, podName, containerName, opts)
+	if containerClient := c.ContainerClient(); containerClient != nil && containerClient.IsContainerPresent(ctx, namespace, podName, containerName) {
+		return containerClient.GetContainerLogs(ctx, namespace, podName, containerName, opts)
 	}
 
 	return nil, errdefs.InvalidInput("container logs are not supported for macOS virtual machines")
@@ -458,11 +735,11 @@ func (c *VzClientAPIs) ExecuteContainerCommand(ctx context.Context, namespace, p
 		span.End()
 	}()
 
-	if c.ContainerClient != nil && c.ContainerClient.IsContainerPresent(ctx, namespace, podName, containerName) {
-		return c.ContainerClient.ExecInContainer(ctx, namespace, podName, containerName, cmd, attach)
+	if containerClient := c.ContainerClient(); containerClient != nil && containerClient.IsContainerPresent(ctx, namespace, podName, containerName) {
+		return containerClient.ExecInContainer(ctx, namespace, podName, containerName, cmd, attach)
 	}
 
-	return c.MacOSClient.ExecInVirtualMachine(ctx, namespace, podName, cmd, attach)
+	return c.MacOSClient.ExecInVirtualMachine(ctx, namespace, c.virtualMachineName(namespace, podName, containerName), cmd, attach)
 }
 
 // AttachToContainer attaches to a specified container.
@@ -473,11 +750,26 @@ func (c *VzClientAPIs) AttachToContainer(ctx context.Context, namespace, podName
 		span.End()
 	}()
 
-	if c.ContainerClient != nil && c.ContainerClient.IsContainerPresent(ctx, namespace, podName, containerName) {
-		return c.ContainerClient.AttachToContainer(ctx, namespace, podName, containerName, attach)
+	if containerClient := c.ContainerClient(); containerClient != nil && containerClient.IsContainerPresent(ctx, namespace, podName, containerName) {
+		return containerClient.AttachToContainer(ctx, namespace, podName, containerName, attach)
+	}
+
+	return c.MacOSClient.ExecInVirtualMachine(ctx, namespace, c.virtualMachineName(namespace, podName, containerName), nil, attach)
+}
+
+// ExportVirtualMachine pushes the storage of the virtual machine of the container to the target image reference.
+func (c *VzClientAPIs) ExportVirtualMachine(ctx context.Context, namespace, podName, containerName, targetRef string) (d digest.Digest, err error) {
+	ctx, span := trace.StartSpan(ctx, "VZClient.ExportVirtualMachine")
+	defer func() {
+		span.SetStatus(err)
+		span.End()
+	}()
+
+	if containerClient := c.ContainerClient(); containerClient != nil && containerClient.IsContainerPresent(ctx, namespace, podName, containerName) {
+		return "", errdefs.InvalidInput("only macOS containers can be exported")
 	}
 
-	return c.MacOSClient.ExecInVirtualMachine(ctx, namespace, podName, nil, attach)
+	return c.MacOSClient.ExportVirtualMachine(ctx, namespace, c.virtualMachineName(namespace, podName, containerName), targetRef)
 }
 
 func (c *VzClientAPIs) GetVirtualizationGroupStats(ctx context.Context, namespace, name string, containers []corev1.Container) (cs []stats.ContainerStats, err error) {
@@ -496,8 +788,22 @@ func (c *VzClientAPIs) GetVirtualizationGroupStats(ctx context.Context, namespac
 	vmStats.Name = containers[0].Name
 	cs = append(cs, vmStats)
 
+	extras, _ := c.getExtras(types.NamespacedName{Namespace: namespace, Name: name})
+	containerClient := c.ContainerClient()
 	for _, container := range containers[1:] {
-		containerStats, err := c.ContainerClient.GetContainerStats(ctx, namespace, name, container.Name)
+		if extras != nil && extras.isMacOSContainer(container.Name) {
+			additionalStats, err := c.MacOSClient.GetVirtualMachineStats(ctx, namespace, extras.virtualMachineName(name, container.Name))
+			if err != nil {
+				return nil, err
+			}
+			additionalStats.Name = container.Name
+			cs = append(cs, additionalStats)
+			continue
+		}
+		if containerClient == nil {
+			return nil, errdefs.InvalidInput("regular containers are not supported")
+		}
+		containerStats, err := containerClient.GetContainerStats(ctx, namespace, name, container.Name)
 		if err != nil {
 			return nil, err
 		}
@@ -507,7 +813,79 @@ func (c *VzClientAPIs) GetVirtualizationGroupStats(ctx context.Context, namespac
 	return cs, nil
 }
 
+// getExtras returns the extras of the virtualization group, if it exists.
+func (c *VzClientAPIs) getExtras(key types.NamespacedName) (*virtualizationGroupExtras, bool) {
+	value, loaded := c.extras.Load(key)
+	if !loaded {
+		return nil, false
+	}
+	extras, ok := value.(*virtualizationGroupExtras)
+	return extras, ok
+}
+
+// virtualMachineName returns the name of the virtual machine of the macOS container of the pod,
+// defaulting to the virtual machine of the first container if the pod is unknown.
+func (c *VzClientAPIs) virtualMachineName(namespace, podName, containerName string) string {
+	if extras, ok := c.getExtras(types.NamespacedName{Namespace: namespace, Name: podName}); ok && extras.isMacOSContainer(containerName) {
+		return extras.virtualMachineName(podName, containerName)
+	}
+	return podName
+}
+
+// removePodVolumeRoot releases the memory-backed volumes of a pod and removes its volume root directory.
+func removePodVolumeRoot(ctx context.Context, rootDir string) {
+	ctx = context.WithoutCancel(ctx)
+	if err := volumes.RemoveMemoryVolumes(ctx, rootDir); err != nil {
+		log.G(ctx).WithError(err).Warn("Failed to release memory-backed pod volumes")
+	}
+	if err := os.RemoveAll(rootDir); err != nil {
+		log.G(ctx).WithError(err).Warn("Failed to clean up pod volume root")
+	}
+}
+
 // getPodVolumeRoot returns the root path for the volumes of a pod
 func (c *VzClientAPIs) getPodVolumeRoot(pod *corev1.Pod) string {
 	return filepath.Join(c.cachePath, PodMountsDir, string(pod.UID))
 }
+
+// podPriority returns the priority of the pod, zero if the pod has none.
+func podPriority(pod *corev1.Pod) int32 {
+	if pod.Spec.Priority == nil {
+		return 0
+	}
+	return *pod.Spec.Priority
+}
+
+// ephemeralStorageLimit returns the ephemeral-storage limit of the container in bytes, its request if it has no limit,
+// and zero if it has neither.
+func ephemeralStorageLimit(container corev1.Container) int64 {
+	if limit, ok := container.Resources.Limits[corev1.ResourceEphemeralStorage]; ok {
+		return limit.Value()
+	}
+	if request, ok := container.Resources.Requests[corev1.ResourceEphemeralStorage]; ok {
+		return request.Value()
+	}
+	return 0
+}
+
+// activeDeadline returns the active deadline of the pod, zero if the pod has none.
+func activeDeadline(pod *corev1.Pod) time.Duration {
+	if pod.Spec.ActiveDeadlineSeconds == nil || *pod.Spec.ActiveDeadlineSeconds <= 0 {
+		return 0
+	}
+	return time.Duration(*pod.Spec.ActiveDeadlineSeconds) * time.Second
+}
+
+// readOnlyRootFilesystem reports whether the security context of the container requests a read-only root filesystem.
+func readOnlyRootFilesystem(container corev1.Container) bool {
+	sc := container.SecurityContext
+	return sc != nil && sc.ReadOnlyRootFilesystem != nil && *sc.ReadOnlyRootFilesystem
+}
+
+// podSysctls returns the sysctls of the pod security context, nil if the pod has none.
+func podSysctls(pod *corev1.Pod) []corev1.Sysctl {
+	if pod.Spec.SecurityContext == nil {
+		return nil
+	}
+	return pod.Spec.SecurityContext.Sysctls
+}
@@ -1,8 +1,392 @@
 package client_test
 
 import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
 	"github.com/agoda-com/macOS-vz-kubelet/pkg/client"
+	"github.com/agoda-com/macOS-vz-kubelet/pkg/event"
+	"github.com/agoda-com/macOS-vz-kubelet/pkg/event/mocks"
+	vzresource "github.com/agoda-com/macOS-vz-kubelet/pkg/resource"
+	rm "github.com/agoda-com/macOS-vz-kubelet/pkg/resourcemanager"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/virtual-kubelet/virtual-kubelet/errdefs"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
 )
 
 // check that VzClientAPIs implements the VzClientInterface interface
 var _ client.VzClientInterface = &client.VzClientAPIs{}
+
+// fakeContainersClient records the containers it was asked to create and remove.
+type fakeContainersClient struct {
+	rm.ContainersClient
+	created atomic.Int32
+	removed atomic.Int32
+}
+
+func (f *fakeContainersClient) CreateContainer(context.Context, rm.ContainerParams) error {
+	f.created.Add(1)
+	return nil
+}
+
+func (f *fakeContainersClient) RemoveContainers(context.Context, string, string, int64) error {
+	f.removed.Add(1)
+	return nil
+}
+
+func TestInitContainerClient(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	vzClient := client.NewVzClientAPIs(ctx, mocks.NewEventRecorder(t), "", t.TempDir(), nil)
+	require.Nil(t, vzClient.ContainerClient())
+
+	pod := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: "test-pod", Namespace: "default"},
+		Spec: corev1.PodSpec{
+			Containers: []corev1.Container{
+				{
+					Name:  "macos",
+					Image: "localhost:5000/macos:latest",
+					Resources: corev1.ResourceRequirements{
+						// fractional CPU is rejected, so that no virtual machine is created
+						Requests: corev1.ResourceList{corev1.ResourceCPU: resource.MustParse("500m")},
+					},
+				},
+				{Name: "sidecar", Image: "localhost:5000/sidecar:latest"},
+			},
+		},
+	}
+
+	// Regular containers are rejected while the container client is not available
+	err := vzClient.CreateVirtualizationGroup(ctx, pod, "", nil, nil)
+	require.Error(t, err)
+	assert.True(t, errdefs.IsInvalidInput(err))
+	assert.ErrorContains(t, err, "regular containers are not supported")
+
+	containerClient := &fakeContainersClient{}
+	attempts := atomic.Int32{}
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		vzClient.InitContainerClient(ctx, time.Millisecond, func(context.Context) (rm.ContainersClient, error) {
+			if attempts.Add(1) < 3 {
+				return nil, errors.New("docker is not running")
+			}
+			return containerClient, nil
+		})
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("container client was not initialized")
+	}
+	assert.Equal(t, int32(3), attempts.Load())
+	assert.Equal(t, containerClient, vzClient.ContainerClient())
+
+	// Regular containers are accepted once the container client is available
+	err = vzClient.CreateVirtualizationGroup(ctx, pod, "", nil, nil)
+	require.Error(t, err) // fractional CPU
+	assert.NotContains(t, err.Error(), "regular containers are not supported")
+	assert.Equal(t, int32(1), containerClient.created.Load())
+}
+
+func TestMultipleMacOSContainers(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	vzClient := client.NewVzClientAPIs(ctx, event.LogEventRecorder{}, "", t.TempDir(), nil)
+
+	requests := corev1.ResourceList{
+		corev1.ResourceCPU:    resource.MustParse("2"),
+		corev1.ResourceMemory: resource.MustParse("4Gi"),
+	}
+	pod := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        "test-pod",
+			Namespace:   "default",
+			UID:         "test-uid",
+			Annotations: map[string]string{client.MacOSContainersAnnotation: "macos-0,macos-1"},
+		},
+		Spec: corev1.PodSpec{
+			Containers: []corev1.Container{
+				// the registry is unreachable, so that the virtual machines never leave the preparing state
+				{Name: "macos-0", Image: "localhost:1/macos:latest", Resources: corev1.ResourceRequirements{Requests: requests}},
+				{Name: "macos-1", Image: "localhost:1/macos:latest", Resources: corev1.ResourceRequirements{Requests: requests}},
+			},
+		},
+	}
+
+	// No container client is required, since all the containers run as macOS virtual machines
+	require.NoError(t, vzClient.CreateVirtualizationGroup(ctx, pod, "", nil, nil))
+
+	vms, err := vzClient.MacOSClient.GetVirtualMachineListResult(ctx)
+	require.NoError(t, err)
+	assert.Len(t, vms, 2)
+	assert.Contains(t, vms, types.NamespacedName{Namespace: "default", Name: "test-pod"})
+	assert.Contains(t, vms, types.NamespacedName{Namespace: "default", Name: rm.AdditionalVirtualMachineName("test-pod", "macos-1")})
+
+	vg, err := vzClient.GetVirtualizationGroup(ctx, pod.Namespace, pod.Name)
+	require.NoError(t, err)
+	assert.NotNil(t, vg.MacOSVirtualMachine)
+	assert.Len(t, vg.AdditionalVirtualMachines, 1)
+	assert.Contains(t, vg.AdditionalVirtualMachines, "macos-1")
+
+	vgs, err := vzClient.GetVirtualizationGroupListResult(ctx)
+	require.NoError(t, err)
+	require.Len(t, vgs, 1)
+	assert.Contains(t, vgs[types.NamespacedName{Namespace: "default", Name: "test-pod"}].AdditionalVirtualMachines, "macos-1")
+
+	require.NoError(t, vzClient.DeleteVirtualizationGroup(ctx, pod.Namespace, pod.Name, 0))
+	vms, err = vzClient.MacOSClient.GetVirtualMachineListResult(ctx)
+	require.NoError(t, err)
+	assert.Empty(t, vms)
+}
+
+func TestCreateVirtualizationGroupFailureRemovesCreatedContainers(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	containers := &fakeContainersClient{}
+	vzClient := newVzClientWithContainers(ctx, t, containers)
+
+	requests := corev1.ResourceList{
+		corev1.ResourceCPU:    resource.MustParse("2"),
+		corev1.ResourceMemory: resource.MustParse("4Gi"),
+	}
+	pod := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        "test-pod",
+			Namespace:   "default",
+			UID:         "test-uid",
+			Annotations: map[string]string{client.MacOSContainersAnnotation: "macos-0,macos-1"},
+		},
+		Spec: corev1.PodSpec{
+			Containers: []corev1.Container{
+				// the registry is unreachable, so that the virtual machine never leaves the preparing state
+				{Name: "macos-0", Image: "localhost:1/macos:latest", Resources: corev1.ResourceRequirements{Requests: requests}},
+				// a fractional CPU request fails the creation of the second virtual machine
+				{
+					Name: "macos-1", Image: "localhost:1/macos:latest", Resources: corev1.ResourceRequirements{Requests: corev1.ResourceList{
+						corev1.ResourceCPU:    resource.MustParse("500m"),
+						corev1.ResourceMemory: resource.MustParse("4Gi"),
+					}},
+				},
+				{Name: "sidecar", Image: "busybox"},
+			},
+		},
+	}
+
+	err := vzClient.CreateVirtualizationGroup(ctx, pod, "", nil, nil)
+	require.Error(t, err)
+	assert.True(t, errdefs.IsInvalidInput(err), err)
+
+	// the virtual machine of the first container and the regular containers are removed along with their slots
+	vms, err := vzClient.MacOSClient.GetVirtualMachineListResult(ctx)
+	require.NoError(t, err)
+	assert.Empty(t, vms)
+	available, total := vzClient.MacOSClient.VirtualMachineSlots()
+	assert.Equal(t, total, available)
+	assert.Equal(t, int32(1), containers.created.Load())
+	assert.Equal(t, int32(1), containers.removed.Load())
+}
+
+func TestCreateVirtualizationGroupDuplicate(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	containers := &fakeContainersClient{}
+	vzClient := newVzClientWithContainers(ctx, t, containers)
+
+	pod := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: "test-pod", Namespace: "default", UID: "test-uid"},
+		Spec: corev1.PodSpec{
+			Containers: []corev1.Container{
+				// the registry is unreachable, so that the virtual machine never leaves the preparing state
+				{Name: "macos", Image: "localhost:1/macos:latest", Resources: corev1.ResourceRequirements{Requests: corev1.ResourceList{
+					corev1.ResourceCPU:    resource.MustParse("2"),
+					corev1.ResourceMemory: resource.MustParse("4Gi"),
+				}}},
+				{Name: "sidecar", Image: "busybox"},
+			},
+		},
+	}
+	require.NoError(t, vzClient.CreateVirtualizationGroup(ctx, pod, "", nil, nil))
+	first, err := vzClient.MacOSClient.GetVirtualMachine(ctx, pod.Namespace, pod.Name)
+	require.NoError(t, err)
+
+	// the redelivered creation succeeds without creating or cancelling anything
+	require.NoError(t, vzClient.CreateVirtualizationGroup(ctx, pod, "", nil, nil))
+	assert.Equal(t, int32(1), containers.created.Load())
+	vms, err := vzClient.MacOSClient.GetVirtualMachineListResult(ctx)
+	require.NoError(t, err)
+	assert.Len(t, vms, 1)
+	current, err := vzClient.MacOSClient.GetVirtualMachine(ctx, pod.Namespace, pod.Name)
+	require.NoError(t, err)
+	assert.Equal(t, first.CreatedAt(), current.CreatedAt())
+	assert.Equal(t, vzresource.VirtualMachineStatePreparing, current.State())
+
+	// another pod of the same name waits for the group to be deleted
+	recreated := pod.DeepCopy()
+	recreated.UID = "other-uid"
+	err = vzClient.CreateVirtualizationGroup(ctx, recreated, "", nil, nil)
+	assert.True(t, errdefs.IsInvalidInput(err), err)
+	current, err = vzClient.MacOSClient.GetVirtualMachine(ctx, pod.Namespace, pod.Name)
+	require.NoError(t, err)
+	assert.Equal(t, first.CreatedAt(), current.CreatedAt())
+
+	require.NoError(t, vzClient.DeleteVirtualizationGroup(ctx, pod.Namespace, pod.Name, 0))
+}
+
+// pullingImageRecorder records the images pulled for the containers.
+type pullingImageRecorder struct {
+	event.LogEventRecorder
+	mu     sync.Mutex
+	images map[string]string
+}
+
+func (r *pullingImageRecorder) PullingImage(_ context.Context, image, containerName string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.images[containerName] = image
+}
+
+func TestCreateVirtualizationGroupDefaultImage(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	newPod := func() *corev1.Pod {
+		return &corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{Name: "test-pod", Namespace: "default", UID: "test-uid"},
+			Spec: corev1.PodSpec{
+				Containers: []corev1.Container{{Name: "macos", Resources: corev1.ResourceRequirements{Requests: corev1.ResourceList{
+					corev1.ResourceCPU:    resource.MustParse("2"),
+					corev1.ResourceMemory: resource.MustParse("4Gi"),
+				}}}},
+			},
+		}
+	}
+
+	t.Run("Default image", func(t *testing.T) {
+		// the registry is unreachable, so that the virtual machine never leaves the preparing state
+		const defaultImage = "localhost:1/macos:default"
+		recorder := &pullingImageRecorder{images: map[string]string{}}
+		vzClient := client.NewVzClientAPIs(ctx, recorder, "", t.TempDir(), nil, rm.WithDefaultImage(defaultImage))
+
+		pod := newPod()
+		require.NoError(t, vzClient.CreateVirtualizationGroup(ctx, pod, "", nil, nil))
+		recorder.mu.Lock()
+		assert.Equal(t, map[string]string{"macos": defaultImage}, recorder.images)
+		recorder.mu.Unlock()
+		assert.Empty(t, pod.Spec.Containers[0].Image, "the pod is not changed")
+
+		require.NoError(t, vzClient.DeleteVirtualizationGroup(ctx, pod.Namespace, pod.Name, 0))
+	})
+
+	t.Run("No default image", func(t *testing.T) {
+		vzClient := client.NewVzClientAPIs(ctx, event.LogEventRecorder{}, "", t.TempDir(), nil)
+
+		err := vzClient.CreateVirtualizationGroup(ctx, newPod(), "", nil, nil)
+		assert.True(t, errdefs.IsInvalidInput(err), err)
+		assert.ErrorContains(t, err, "no default macOS image configured")
+	})
+}
+
+// fakeContainersLister returns the containers of a virtualization group.
+type fakeContainersLister struct {
+	rm.ContainersClient
+	containers []vzresource.Container
+	err        error
+}
+
+func (f *fakeContainersLister) GetContainers(context.Context, string, string) ([]vzresource.Container, error) {
+	return f.containers, f.err
+}
+
+func newVzClientWithContainers(ctx context.Context, t *testing.T, containerClient rm.ContainersClient) *client.VzClientAPIs {
+	t.Helper()
+	vzClient := client.NewVzClientAPIs(ctx, event.LogEventRecorder{}, "", t.TempDir(), nil)
+	vzClient.InitContainerClient(ctx, time.Millisecond, func(context.Context) (rm.ContainersClient, error) {
+		return containerClient, nil
+	})
+	return vzClient
+}
+
+func TestGetVirtualizationGroupNotFound(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	// without container client
+	vzClient := client.NewVzClientAPIs(ctx, event.LogEventRecorder{}, "", t.TempDir(), nil)
+	vg, err := vzClient.GetVirtualizationGroup(ctx, "default", "missing")
+	assert.Nil(t, vg)
+	assert.True(t, errdefs.IsNotFound(err))
+
+	// with both the virtual machine and the containers missing
+	vzClient = newVzClientWithContainers(ctx, t, &fakeContainersLister{err: errdefs.NotFound("containers not found")})
+	vg, err = vzClient.GetVirtualizationGroup(ctx, "default", "missing")
+	assert.Nil(t, vg)
+	assert.True(t, errdefs.IsNotFound(err))
+}
+
+func TestGetVirtualizationGroupContainersMissing(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	vzClient := newVzClientWithContainers(ctx, t, &fakeContainersLister{err: errdefs.NotFound("containers not found")})
+	pod := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: "test-pod", Namespace: "default", UID: "test-uid"},
+		Spec: corev1.PodSpec{
+			Containers: []corev1.Container{
+				// the registry is unreachable, so that the virtual machine never leaves the preparing state
+				{Name: "macos", Image: "localhost:1/macos:latest", Resources: corev1.ResourceRequirements{Requests: corev1.ResourceList{
+					corev1.ResourceCPU:    resource.MustParse("2"),
+					corev1.ResourceMemory: resource.MustParse("4Gi"),
+				}}},
+			},
+		},
+	}
+	require.NoError(t, vzClient.CreateVirtualizationGroup(ctx, pod, "", nil, nil))
+
+	vg, err := vzClient.GetVirtualizationGroup(ctx, pod.Namespace, pod.Name)
+	require.NoError(t, err)
+	require.NotNil(t, vg)
+	assert.NotNil(t, vg.MacOSVirtualMachine)
+	assert.Empty(t, vg.Containers)
+}
+
+func TestGetVirtualizationGroupVirtualMachineMissing(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	containers := []vzresource.Container{{Name: "sidecar"}}
+	vzClient := newVzClientWithContainers(ctx, t, &fakeContainersLister{containers: containers})
+
+	vg, err := vzClient.GetVirtualizationGroup(ctx, "default", "test-pod")
+	require.NoError(t, err)
+	require.NotNil(t, vg)
+	assert.Equal(t, containers, vg.Containers)
+}
+
+func TestGetVirtualizationGroupContainerError(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	vzClient := newVzClientWithContainers(ctx, t, &fakeContainersLister{err: errors.New("docker is not responding")})
+
+	vg, err := vzClient.GetVirtualizationGroup(ctx, "default", "test-pod")
+	assert.Nil(t, vg)
+	require.Error(t, err)
+	assert.False(t, errdefs.IsNotFound(err))
+	assert.ErrorContains(t, err, "docker is not responding")
+}
@@ -2,21 +2,27 @@ package downloader
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"net"
+	"os"
 	"path/filepath"
 	"strings"
 	"time"
 
+	"github.com/agoda-com/macOS-vz-kubelet/internal/disk"
 	"github.com/agoda-com/macOS-vz-kubelet/pkg/event"
 	"github.com/agoda-com/macOS-vz-kubelet/pkg/oci"
 	"github.com/agoda-com/macOS-vz-kubelet/pkg/vm/config"
 	"github.com/virtual-kubelet/virtual-kubelet/trace"
 
 	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+	"golang.org/x/time/rate"
 	"k8s.io/apimachinery/pkg/util/wait"
 	"oras.land/oras-go/v2"
+	"oras.land/oras-go/v2/content"
+	"oras.land/oras-go/v2/registry"
 	"oras.land/oras-go/v2/registry/remote"
 	"oras.land/oras-go/v2/registry/remote/auth"
 )
@@ -27,6 +33,9 @@ const (
 	DefaultMaxAttempts   = 5                // Default maximum number of retry attempts.
 	DefaultFactor        = 1.6              // Default factor to increase the delay between retries.
 	DefaultJitter        = 0.2              // Default jitter to add to delays.
+
+	// StoreCloseTimeout bounds the removal of the temporary files of the store after the download.
+	StoreCloseTimeout = 30 * time.Second
 )
 
 // Params contains the parameters for downloading an OCI image.
@@ -38,6 +47,30 @@ type Params struct {
 	MinRetryDelay time.Duration
 	MaxDelay      time.Duration
 	MaxAttempts   int
+
+	// Progress, if set, is updated with the number of bytes transferred.
+	Progress *Progress
+	// Limiter, if set, throttles the bandwidth of the downloaded content.
+	// It may be shared between downloads to limit their combined bandwidth.
+	Limiter *rate.Limiter
+	// PinDigest pins tags to the digest they were first resolved to, so that cached images
+	// do not change when the tag is moved in the registry. IgnoreExisiting resolves the tag again.
+	PinDigest bool
+	// StreamDecompression decompresses the compressed layers while they are downloaded, instead of
+	// saving them to temporary files first. It needs less disk space, but a failed decompression
+	// downloads the layer again.
+	StreamDecompression bool
+	// Mirrors maps the hosts of registries to the hosts of their mirrors. A failed pull is retried
+	// against the mirror of the registry, alternating between the two until the attempts run out.
+	Mirrors map[string]string
+	// Layout selects how the content is laid out within the store path, LayoutReference if empty.
+	Layout Layout
+	// Clients maps the hosts of registries to the clients pulling from them, the default client
+	// pulls from the other registries.
+	Clients RegistryClients
+	// MaxImageSize rejects the images whose content, uncompressed where annotated, is declared larger than
+	// the given number of bytes before the content is downloaded. Zero means unlimited.
+	MaxImageSize int64
 }
 
 // Download downloads an OCI image and returns a Config.
@@ -60,25 +93,55 @@ func Download(ctx context.Context, params Params, eventRecorder event.EventRecor
 		params.MaxAttempts = DefaultMaxAttempts
 	}
 
-	store, err := oci.New(filepath.Join(params.StorePath, "blobs", convertToPath(params.Ref)), params.IgnoreExisiting, eventRecorder)
+	ref, err := ParseReference(params.Ref)
+	if err != nil {
+		return cfg, err
+	}
+	pullRef := ref
+	pinTag := params.PinDigest && isTag(ref)
+	if pinTag && !params.IgnoreExisiting {
+		pinned, ok, err := loadPin(params.StorePath, ref)
+		if err != nil {
+			return cfg, fmt.Errorf("failed to load pinned digest: %w", err)
+		}
+		if ok {
+			pullRef.Reference = pinned.String()
+		}
+	}
+
+	store, err := oci.New(filepath.Join(params.StorePath, blobsDir, CachePath(ref)), params.IgnoreExisiting, eventRecorder)
 	if err != nil {
 		return cfg, fmt.Errorf("failed to initialize store: %w", err)
 	}
+	store.SetStreamDecompression(params.StreamDecompression)
+	if params.Layout == LayoutSharded {
+		store.SetShardedLayout(filepath.Join(params.StorePath, blobsDir))
+	}
 	defer func() {
-		err = errors.Join(err, store.Close(ctx))
+		// clean up the temporary files of canceled downloads as well
+		closeCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), StoreCloseTimeout)
+		defer cancel()
+		err = errors.Join(err, store.Close(closeCtx))
 	}()
 
+	var desc *ocispec.Descriptor
+	attemptRef := pullRef
 	err = wait.ExponentialBackoffWithContext(ctx, wait.Backoff{
 		Duration: params.MinRetryDelay, // Base delay to start with
 		Factor:   DefaultFactor,        // Factor to increase the delay between retries
 		Jitter:   DefaultJitter,        // Randomization factor to avoid thundering herd problem
 		Steps:    params.MaxAttempts,   // Maximum number of retry attempts
 		Cap:      params.MaxDelay,      // Maximum delay between retries
-	}, func(ctx context.Context) (done bool, _ error) { // never use condition error
-		_, err = pull(ctx, params.Ref, store)
+	}, func(ctx context.Context) (done bool, _ error) { // the condition error stops the retries
+		desc, err = pull(ctx, attemptRef, store, params.Clients[attemptRef.Registry], params.Progress, params.Limiter, params.MaxImageSize)
 		if err != nil {
-			// log error, but do not return it to continue retrying
 			eventRecorder.FailedToPullImage(ctx, params.Ref, "", err)
+			if errors.Is(err, ErrImageTooLarge) {
+				// retrying does not make the image any smaller
+				return false, err
+			}
+			// do not return the other errors to continue retrying
+			attemptRef = nextPullReference(pullRef, attemptRef, params.Mirrors)
 		}
 		return err == nil, nil
 	})
@@ -86,45 +149,138 @@ func Download(ctx context.Context, params Params, eventRecorder event.EventRecor
 	if err != nil {
 		return cfg, err
 	}
-
-	blkStoragePath, err := store.GetFilePathForMediaType(ctx, oci.MediaTypeDiskImage)
-	if err != nil {
-		return cfg, fmt.Errorf("failed to get file path for media type %s: %w", oci.MediaTypeDiskImage, err)
-	}
-	auxStoragePath, err := store.GetFilePathForMediaType(ctx, oci.MediaTypeAuxImage)
-	if err != nil {
-		return cfg, fmt.Errorf("failed to get file path for media type %s: %w", oci.MediaTypeAuxImage, err)
+	if pinTag && isTag(pullRef) {
+		if err := savePin(params.StorePath, ref, desc.Digest); err != nil {
+			return cfg, fmt.Errorf("failed to pin digest: %w", err)
+		}
 	}
+
 	c, err := store.GetConfig(ctx)
 	if err != nil {
 		return cfg, fmt.Errorf("failed to get config: %w", err)
 	}
-	return config.MacPlatformConfigurationOptions{
-		BlockStoragePath:      blkStoragePath,
-		AuxiliaryStoragePath:  auxStoragePath,
+	files, err := store.StorageFiles(ctx, c)
+	if err != nil {
+		return cfg, fmt.Errorf("failed to resolve storage: %w", err)
+	}
+	return platformOptions(c, files)
+}
+
+// platformOptions builds the platform configuration options from the config and its storage files.
+// The storage files are processed in the order declared by the config, every media type may appear only once.
+func platformOptions(c oci.Config, files []oci.StorageFile) (cfg config.MacPlatformConfigurationOptions, err error) {
+	cfg = config.MacPlatformConfigurationOptions{
 		HardwareModelData:     c.HardwareModelData,
 		MachineIdentifierData: c.MachineIdData,
-	}, nil
+	}
+
+	for _, file := range files {
+		var path *string
+		switch file.MediaType {
+		case oci.MediaTypeDiskImage:
+			path = &cfg.BlockStoragePath
+		case oci.MediaTypeAuxImage:
+			path = &cfg.AuxiliaryStoragePath
+		default:
+			continue
+		}
+		if *path != "" {
+			return cfg, fmt.Errorf("storage %s is declared more than once", file.MediaType)
+		}
+		*path = file.Path
+	}
+
+	if cfg.BlockStoragePath == "" {
+		return cfg, fmt.Errorf("storage %s is missing", oci.MediaTypeDiskImage)
+	}
+	if cfg.AuxiliaryStoragePath == "" {
+		return cfg, fmt.Errorf("storage %s is missing", oci.MediaTypeAuxImage)
+	}
+	return cfg, nil
+}
+
+// cachedPlatformOptions builds the platform configuration options from the content of an image in the store
+// directory, without contacting the registry. Every file has to be present and verified against its digest,
+// images with disk image layers are not supported since their composition needs the manifest.
+func cachedPlatformOptions(dir string) (cfg config.MacPlatformConfigurationOptions, err error) {
+	configPath := filepath.Join(dir, oci.MediaTypeConfigV1.Title())
+	if !disk.IsDigestUpToDate(configPath) {
+		return cfg, fmt.Errorf("%s is missing or not verified", oci.MediaTypeConfigV1.Title())
+	}
+	data, err := os.ReadFile(configPath)
+	if err != nil {
+		return cfg, err
+	}
+	var c oci.Config
+	if err = json.Unmarshal(data, &c); err != nil {
+		return cfg, fmt.Errorf("failed to decode config: %w", err)
+	}
+
+	storage := c.Storage
+	if len(storage) == 0 {
+		storage = oci.DefaultStorage
+	}
+	files := make([]oci.StorageFile, 0, len(storage))
+	for _, mediaType := range storage {
+		if mediaType == oci.MediaTypeDiskImageLayer {
+			return cfg, fmt.Errorf("storage %s has to be composed", mediaType)
+		}
+		if mediaType == oci.MediaTypeConfigV1 || !oci.IsMediaTypeSupported(string(mediaType)) {
+			continue
+		}
+		path := filepath.Join(dir, mediaType.Title())
+		if !disk.IsDigestUpToDate(path) {
+			return cfg, fmt.Errorf("%s is missing or not verified", mediaType.Title())
+		}
+		files = append(files, oci.StorageFile{MediaType: mediaType, Path: path})
+	}
+	return platformOptions(c, files)
 }
 
 // pull pulls an OCI image from a remote repository and stores it in the local store.
 // It returns the descriptor of the downloaded content.
-func pull(ctx context.Context, ref string, store *oci.Store) (desc *ocispec.Descriptor, err error) {
+// If client is not nil, it sends the requests to the registry instead of the default client.
+// If progress is not nil, it is reset and updated with the number of bytes transferred.
+// If limiter is not nil, the content is read no faster than the limiter allows.
+// If maxSize is positive, images declaring more bytes of content fail with ErrImageTooLarge before the content is fetched.
+func pull(ctx context.Context, ref registry.Reference, store *oci.Store, client remote.Client, progress *Progress, limiter *rate.Limiter, maxSize int64) (desc *ocispec.Descriptor, err error) {
 	ctx, span := trace.StartSpan(ctx, "OCI.pull")
 	defer func() {
 		span.SetStatus(err)
 		span.End()
 	}()
 
-	repo, err := remote.NewRepository(ref)
+	repo, err := remote.NewRepository(ref.String())
 	if err != nil {
 		return nil, fmt.Errorf("failed to create repository from reference %s: %w", ref, err)
 	}
 	// Determine if the repository is using plain HTTP based on if it's localhost or a local IP
 	repo.PlainHTTP = isLocalhostOrLocalIP(repo.Reference.Registry)
+	if client != nil {
+		repo.Client = client
+	}
 
 	ctx = auth.AppendRepositoryScope(ctx, repo.Reference, auth.ActionPull)
-	descOras, err := oras.Copy(ctx, repo, repo.Reference.Reference, store, repo.Reference.Reference, oras.DefaultCopyOptions)
+	opts := oras.DefaultCopyOptions
+	var dst oras.Target = store
+	if progress != nil {
+		progress.reset()
+		opts.FindSuccessors = progress.findSuccessors
+		opts.OnCopySkipped = progress.onCopySkipped
+		dst = &progressStore{Store: store, progress: progress}
+	}
+	if limiter != nil {
+		dst = &throttledStore{Target: dst, limiter: limiter}
+	}
+	if maxSize > 0 {
+		find := opts.FindSuccessors
+		if find == nil {
+			find = content.Successors
+		}
+		guard := &sizeGuard{ref: ref.String(), limit: maxSize}
+		opts.FindSuccessors = guard.findSuccessors(find)
+	}
+	descOras, err := oras.Copy(ctx, repo, repo.Reference.Reference, dst, repo.Reference.Reference, opts)
 	if err != nil {
 		return nil, err
 	}
@@ -132,15 +288,6 @@ func pull(ctx context.Context, ref string, store *oci.Store) (desc *ocispec.Desc
 	return &descOras, nil
 }
 
-// convertToPath converts an OCI image reference to a path format by replacing the colon with a slash.
-func convertToPath(s string) string {
-	parts := strings.Split(s, ":")
-	if len(parts) < 2 {
-		return s
-	}
-	return strings.Join(parts, "/")
-}
-
 // isLocalhostOrLocalIP returns true if the host is localhost or a local IP address.
 func isLocalhostOrLocalIP(host string) bool {
 	host = strings.Split(host, ":")[0] // stripping port if present
@@ -3,11 +3,15 @@ package downloader
 import (
 	"context"
 	"fmt"
+	"os"
+	"path/filepath"
 	"sync"
 	"sync/atomic"
 	"time"
 
+	"github.com/agoda-com/macOS-vz-kubelet/internal/utils"
 	"github.com/agoda-com/macOS-vz-kubelet/pkg/event"
+	"github.com/agoda-com/macOS-vz-kubelet/pkg/oci"
 	"github.com/agoda-com/macOS-vz-kubelet/pkg/vm/config"
 
 	"github.com/virtual-kubelet/virtual-kubelet/log"
@@ -16,23 +20,40 @@ import (
 	"go.opentelemetry.io/otel/attribute"
 	"go.opentelemetry.io/otel/codes"
 	oteltrace "go.opentelemetry.io/otel/trace"
+	"golang.org/x/time/rate"
 )
 
+// imageLockPollInterval is the interval at which RemoveImage tries to lock the image while it is downloaded.
+const imageLockPollInterval = 100 * time.Millisecond
+
 // Manager manages the download of OCI images.
 type Manager struct {
-	eventRecorder event.EventRecorder
-	cachePath     string
+	eventRecorder       event.EventRecorder
+	cachePath           string
+	limiter             atomic.Pointer[rate.Limiter]
+	pinDigests          atomic.Bool
+	streamDecompression atomic.Bool
+	mirrors             atomic.Pointer[map[string]string]
+	clients             atomic.Pointer[RegistryClients]
+	layout              atomic.Value                  // Layout
+	slots               atomic.Pointer[chan struct{}] // nil if the concurrent downloads are unlimited
+	maxImageSize        atomic.Int64
 
-	downloads sync.Map // map[string]*state (ref -> state)
+	mu         sync.Mutex // guards the subscriptions to the downloads
+	downloads  sync.Map   // map[string]*state (ref -> state)
+	imageLocks sync.Map   // map[string]*sync.RWMutex (ref -> lock held while the image is downloaded or removed)
 }
 
 // state contains the state of a download operation.
 type state struct {
-	subscribers atomic.Int32
-	once        sync.Once
-	done        chan struct{}
-	span        oteltrace.Span
-	cancelFunc  context.CancelFunc
+	subscribers    int  // guarded by Manager.mu
+	canceled       bool // guarded by Manager.mu, set once the last subscriber left before the download ended
+	ignoreExisting bool
+	once           sync.Once
+	done           chan struct{}
+	span           oteltrace.Span
+	cancelFunc     context.CancelFunc
+	progress       Progress
 
 	config   config.MacPlatformConfigurationOptions
 	duration time.Duration
@@ -47,6 +68,88 @@ func NewManager(eventRecorder event.EventRecorder, cachePath string) *Manager {
 	}
 }
 
+// SetBandwidthLimit limits the combined bandwidth of the downloads to 'limit' bytes per second.
+// Zero means unlimited. The new limit applies to downloads started afterwards.
+func (m *Manager) SetBandwidthLimit(limit int64) {
+	m.limiter.Store(NewBandwidthLimiter(limit))
+}
+
+// SetPinDigests pins the image tags to the digest they were first resolved to, so that cached images
+// do not change when the tags are moved in the registry. Downloads ignoring the cache resolve the tags again.
+func (m *Manager) SetPinDigests(pin bool) {
+	m.pinDigests.Store(pin)
+}
+
+// SetStreamDecompression decompresses the compressed layers while they are downloaded, instead of
+// saving them to temporary files first. The setting applies to downloads started afterwards.
+func (m *Manager) SetStreamDecompression(enabled bool) {
+	m.streamDecompression.Store(enabled)
+}
+
+// SetLayout selects how the content of the images is laid out within the cache path, LayoutReference by default.
+// Content laid out by reference is moved to the sharded layout once it is downloaded with the sharded layout.
+// The layout applies to downloads started afterwards.
+func (m *Manager) SetLayout(layout Layout) {
+	m.layout.Store(layout)
+}
+
+// currentLayout returns the layout of the content of the images.
+func (m *Manager) currentLayout() Layout {
+	layout, _ := m.layout.Load().(Layout)
+	return layout
+}
+
+// SetRegistryMirrors sets the mirrors of the registries, keyed by the host of the registry they mirror,
+// which failed pulls are retried against. The mirrors apply to downloads started afterwards.
+func (m *Manager) SetRegistryMirrors(mirrors map[string]string) {
+	m.mirrors.Store(&mirrors)
+}
+
+// SetRegistryClients sets the clients pulling from the registries, keyed by the host of the registry.
+// The other registries are pulled from with the default client. The clients apply to downloads started afterwards.
+func (m *Manager) SetRegistryClients(clients RegistryClients) {
+	m.clients.Store(&clients)
+}
+
+// SetMaxConcurrentDownloads limits the number of downloads running at once, the other downloads
+// wait for a running download to end. Zero means unlimited. The limit applies to downloads started afterwards.
+func (m *Manager) SetMaxConcurrentDownloads(limit int) {
+	if limit <= 0 {
+		m.slots.Store(nil)
+		return
+	}
+	slots := make(chan struct{}, limit)
+	m.slots.Store(&slots)
+}
+
+// SetMaxImageSize rejects the images declaring more than 'size' bytes of content before they are downloaded.
+// Zero means unlimited. The limit applies to downloads started afterwards.
+func (m *Manager) SetMaxImageSize(size int64) {
+	m.maxImageSize.Store(size)
+}
+
+// acquireSlot waits for a download slot, until the context is done.
+// It returns the function releasing the slot.
+func (m *Manager) acquireSlot(ctx context.Context, ref string) (release func(), err error) {
+	p := m.slots.Load()
+	if p == nil {
+		return func() {}, nil
+	}
+	slots := *p
+
+	select {
+	case slots <- struct{}{}:
+	default:
+		log.G(ctx).Infof("Waiting for a download slot to download %q", ref)
+		select {
+		case slots <- struct{}{}:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+	return func() { <-slots }, nil
+}
+
 // Download ensures that a download operation identified by 'ref' is only initiated once,
 // regardless of how many subscribers request it. It uses sync.Once to ensure the job runs
 // only once, and manages multiple subscribers using a sync.WaitGroup-like approach.
@@ -69,6 +172,7 @@ func (m *Manager) Download(ctx context.Context, ref string, ignoreExisting bool)
 		"ref":            ref,
 		"ignoreExisting": ignoreExisting,
 	})
+	ctx = span.WithFields(ctx, utils.TraceFields(ctx))
 	defer func() {
 		_ = span.WithField(ctx, "duration", d)
 		span.SetStatus(err)
@@ -77,17 +181,15 @@ func (m *Manager) Download(ctx context.Context, ref string, ignoreExisting bool)
 	logger := log.G(ctx)
 	logger.Infof("Requesting to subscribe to download %q", ref)
 
-	value, _ := m.downloads.LoadOrStore(ref, &state{done: make(chan struct{}, 1)})
-	state, ok := value.(*state)
-	if !ok {
-		return cfg, d, fmt.Errorf("invalid state")
-	}
+	// deduplicate the downloads of equivalent references, e.g. with and without the default tag
+	ref = NormalizeReference(ref)
 
-	state.subscribers.Add(1) // Increment the number of subscribers
+	state, err := m.subscribe(ctx, ref, ignoreExisting)
+	if err != nil {
+		return cfg, d, err
+	}
 	defer func() {
-		if state.subscribers.Add(-1) == 0 {
-			m.downloads.Delete(ref) // Delete reference first so that the state is not accessed after it's closed
-			state.cancelFunc()      // Cancel download when last subscriber is removed
+		if m.unsubscribe(state) {
 			logger.Infof("No more subscribers left for %q, cleaning up...", ref)
 		}
 	}()
@@ -116,7 +218,7 @@ func (m *Manager) Download(ctx context.Context, ref string, ignoreExisting bool)
 		// Performing download in a go routine to keep listening for context cancellation.
 		// Start Download manages its own background context and cancels it when the download is done.
 		// nolint: contextcheck
-		go m.startDownload(downloadCtx, state, ref, ignoreExisting)
+		go m.startDownload(downloadCtx, state, ref)
 	})
 
 	// Link the download span to the subscriber's span
@@ -130,9 +232,176 @@ func (m *Manager) Download(ctx context.Context, ref string, ignoreExisting bool)
 	}
 }
 
+// Cached returns the platform configuration options of the image identified by 'ref' if its content is
+// already present in the cache and verified against its digests, without contacting the registry.
+// Images that are being downloaded or removed are not considered cached.
+func (m *Manager) Cached(ctx context.Context, ref string) (cfg config.MacPlatformConfigurationOptions, ok bool) {
+	ctx, span := trace.StartSpan(ctx, "Manager.Cached")
+	ctx = span.WithField(ctx, "ref", ref)
+	defer span.End()
+
+	parsed, err := ParseReference(ref)
+	if err != nil {
+		return cfg, false
+	}
+	ref = parsed.String()
+	if _, downloading := m.downloads.Load(ref); downloading {
+		return cfg, false
+	}
+
+	lock := m.imageLock(ref)
+	if !lock.TryRLock() {
+		// the image is being removed
+		return cfg, false
+	}
+	defer lock.RUnlock()
+
+	cfg, err = cachedPlatformOptions(filepath.Join(m.cachePath, blobsDir, CachePath(parsed)))
+	if err != nil {
+		log.G(ctx).WithError(err).Debugf("Image %q is not cached", ref)
+		return cfg, false
+	}
+	log.G(ctx).Infof("Image %q is already present in the cache", ref)
+	return cfg, true
+}
+
+// subscribe subscribes to the download of 'ref' able to serve the request, creating it if there is none.
+// The running download of 'ref' is waited for if it cannot serve the request, until the context is done.
+func (m *Manager) subscribe(ctx context.Context, ref string, ignoreExisting bool) (*state, error) {
+	for {
+		m.mu.Lock()
+		value, _ := m.downloads.LoadOrStore(ref, &state{ignoreExisting: ignoreExisting, done: make(chan struct{}, 1)})
+		state, ok := value.(*state)
+		if !ok {
+			m.mu.Unlock()
+			return nil, fmt.Errorf("invalid state")
+		}
+		if !state.canceled && (state.ignoreExisting || !ignoreExisting) {
+			state.subscribers++
+			m.mu.Unlock()
+			return state, nil
+		}
+		m.mu.Unlock()
+
+		log.G(ctx).Infof("Waiting for the running download of %q to end", ref)
+		select {
+		case <-ctx.Done():
+			return nil, context.Canceled
+		case <-state.done:
+		}
+	}
+}
+
+// unsubscribe removes a subscriber from the download, canceling the download when the last subscriber is removed.
+// It reports whether the download was canceled.
+func (m *Manager) unsubscribe(state *state) bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	state.subscribers--
+	if state.subscribers > 0 {
+		return false
+	}
+	select {
+	case <-state.done:
+		return false
+	default:
+	}
+	// the download is removed once it has ended, so that no other download of the image starts meanwhile
+	state.canceled = true
+	state.cancelFunc()
+	return true
+}
+
+// RemoveImage removes the cached content of the image identified by 'ref', unless inUse reports that the image
+// is still used. The removal waits for the running downloads of the image to end, until the context is done,
+// and the downloads of the image started meanwhile wait for the removal to end, so that inUse is checked again
+// while nothing else accesses the image. The returned flag reports whether the content was removed.
+func (m *Manager) RemoveImage(ctx context.Context, ref string, inUse func() bool) (removed bool, err error) {
+	ctx, span := trace.StartSpan(ctx, "Manager.RemoveImage")
+	ctx = span.WithField(ctx, "ref", ref)
+	defer func() {
+		span.SetStatus(err)
+		span.End()
+	}()
+
+	parsed, err := ParseReference(ref)
+	if err != nil {
+		return false, err
+	}
+	ref = parsed.String()
+
+	if inUse() {
+		log.G(ctx).Debugf("Image %q is still in use, keeping it", ref)
+		return false, nil
+	}
+
+	// e.g. the canceled download of the virtual machine that used the image
+	lock := m.imageLock(ref)
+	if err = lockWithContext(ctx, lock); err != nil {
+		return false, fmt.Errorf("failed to wait for the downloads of image %q: %w", ref, err)
+	}
+	defer lock.Unlock()
+
+	if inUse() {
+		log.G(ctx).Debugf("Image %q is still in use, keeping it", ref)
+		return false, nil
+	}
+
+	log.G(ctx).Infof("Removing the cached content of image %q", ref)
+	if err = os.RemoveAll(filepath.Join(m.cachePath, blobsDir, CachePath(parsed))); err != nil {
+		return false, fmt.Errorf("failed to remove the cached content of image %q: %w", ref, err)
+	}
+	if m.currentLayout() == LayoutSharded {
+		// the content is removed once no image links to it anymore
+		pruned, err := oci.PruneShardedLayout(ctx, filepath.Join(m.cachePath, blobsDir))
+		if err != nil {
+			return true, fmt.Errorf("failed to prune the content no image uses anymore: %w", err)
+		}
+		log.G(ctx).Debugf("Pruned %d files no image uses anymore", pruned)
+	}
+	return true, nil
+}
+
+// lockWithContext locks the lock, unless the context is done first.
+func lockWithContext(ctx context.Context, lock *sync.RWMutex) error {
+	ticker := time.NewTicker(imageLockPollInterval)
+	defer ticker.Stop()
+	for !lock.TryLock() {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+	return nil
+}
+
+// imageLock returns the lock of the image identified by the normalized 'ref'.
+func (m *Manager) imageLock(ref string) *sync.RWMutex {
+	value, _ := m.imageLocks.LoadOrStore(ref, &sync.RWMutex{})
+	return value.(*sync.RWMutex)
+}
+
+// Progress returns the progress of the download identified by 'ref'.
+// The returned flag is false if there is no download in progress for the reference.
+func (m *Manager) Progress(ref string) (completed, total int64, ok bool) {
+	value, exists := m.downloads.Load(NormalizeReference(ref))
+	if !exists {
+		return 0, 0, false
+	}
+	state, ok := value.(*state)
+	if !ok {
+		return 0, 0, false
+	}
+	completed, total = state.progress.Snapshot()
+	return completed, total, true
+}
+
 // startDownload starts the download operation and manages the state of the download.
-func (m *Manager) startDownload(ctx context.Context, state *state, ref string, ignoreExisting bool) {
+func (m *Manager) startDownload(ctx context.Context, state *state, ref string) {
+	ignoreExisting := state.ignoreExisting
 	defer func() {
+		m.downloads.CompareAndDelete(ref, state)
 		close(state.done)
 		state.cancelFunc()
 
@@ -147,12 +416,42 @@ func (m *Manager) startDownload(ctx context.Context, state *state, ref string, i
 	state.span.SetAttributes(attribute.String("ref", ref), attribute.Bool("ignoreExisting", ignoreExisting))
 	logger := log.G(ctx)
 
+	// the subscribers joining the download meanwhile wait for the same slot
+	release, err := m.acquireSlot(ctx, ref)
+	if err != nil {
+		state.err = err
+		return
+	}
+	defer release()
+
+	// the cached content of the image is not removed while it is downloaded
+	lock := m.imageLock(ref)
+	lock.RLock()
+	defer lock.RUnlock()
+
+	var mirrors map[string]string
+	if p := m.mirrors.Load(); p != nil {
+		mirrors = *p
+	}
+	var clients RegistryClients
+	if p := m.clients.Load(); p != nil {
+		clients = *p
+	}
+
 	logger.Infof("Starting download for %q", ref)
 	startTime := time.Now()
 	state.config, state.err = Download(ctx, Params{
-		Ref:             ref,
-		StorePath:       m.cachePath,
-		IgnoreExisiting: ignoreExisting,
+		Ref:                 ref,
+		StorePath:           m.cachePath,
+		IgnoreExisiting:     ignoreExisting,
+		Progress:            &state.progress,
+		Limiter:             m.limiter.Load(),
+		PinDigest:           m.pinDigests.Load(),
+		StreamDecompression: m.streamDecompression.Load(),
+		Mirrors:             mirrors,
+		Layout:              m.currentLayout(),
+		Clients:             clients,
+		MaxImageSize:        m.maxImageSize.Load(),
 	}, m.eventRecorder)
 
 	state.duration = time.Since(startTime)
@@ -0,0 +1,245 @@
+package downloader_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/agoda-com/macOS-vz-kubelet/internal/disk"
+	"github.com/agoda-com/macOS-vz-kubelet/pkg/downloader"
+	"github.com/agoda-com/macOS-vz-kubelet/pkg/event"
+
+	"github.com/opencontainers/go-digest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestManagerRemoveImageWaitsForDownload(t *testing.T) {
+	// the registry never responds, so the download stays in progress until it is canceled
+	var inFlight atomic.Int32
+	registry := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		inFlight.Add(1)
+		<-r.Context().Done()
+	}))
+	t.Cleanup(registry.Close)
+
+	cachePath := t.TempDir()
+	m := downloader.NewManager(event.LogEventRecorder{}, cachePath)
+	image := strings.TrimPrefix(registry.URL, "http://") + "/macos:latest"
+	ref, err := downloader.ParseReference(image)
+	require.NoError(t, err)
+	blobs := filepath.Join(cachePath, "blobs", downloader.CachePath(ref))
+	require.NoError(t, os.MkdirAll(blobs, 0o755))
+
+	downloadCtx, cancelDownload := context.WithCancel(context.Background())
+	downloaded := make(chan struct{})
+	go func() {
+		defer close(downloaded)
+		_, _, _ = m.Download(downloadCtx, image, false)
+	}()
+	require.Eventually(t, func() bool { return inFlight.Load() > 0 }, 5*time.Second, 10*time.Millisecond)
+
+	notInUse := func() bool { return false }
+
+	// the content is kept while the image is downloaded
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+	removed, err := m.RemoveImage(ctx, image, notInUse)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.False(t, removed)
+	assert.DirExists(t, blobs)
+
+	// once the download ends, the content is removed
+	cancelDownload()
+	<-downloaded
+	removed, err = m.RemoveImage(context.Background(), image, notInUse)
+	require.NoError(t, err)
+	assert.True(t, removed)
+	assert.NoDirExists(t, blobs)
+
+	// images still in use are kept
+	require.NoError(t, os.MkdirAll(blobs, 0o755))
+	removed, err = m.RemoveImage(context.Background(), image, func() bool { return true })
+	require.NoError(t, err)
+	assert.False(t, removed)
+	assert.DirExists(t, blobs)
+}
+
+func TestManagerDownloadIgnoreExistingWhileInFlight(t *testing.T) {
+	// the registry never responds, so every download stays in progress until it is canceled
+	var mu sync.Mutex
+	var requests, inFlight, maxInFlight int
+	registry := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		requests++
+		inFlight++
+		maxInFlight = max(maxInFlight, inFlight)
+		mu.Unlock()
+		<-r.Context().Done()
+		mu.Lock()
+		inFlight--
+		mu.Unlock()
+	}))
+	t.Cleanup(registry.Close)
+	counts := func() (int, int) {
+		mu.Lock()
+		defer mu.Unlock()
+		return requests, inFlight
+	}
+
+	m := downloader.NewManager(event.LogEventRecorder{}, t.TempDir())
+	image := strings.TrimPrefix(registry.URL, "http://") + "/macos:latest"
+	download := func(ignoreExisting bool) (context.CancelFunc, <-chan error) {
+		ctx, cancel := context.WithCancel(context.Background())
+		done := make(chan error, 1)
+		go func() {
+			_, _, err := m.Download(ctx, image, ignoreExisting)
+			done <- err
+		}()
+		return cancel, done
+	}
+
+	cancelCached, cachedDone := download(false)
+	require.Eventually(t, func() bool { _, n := counts(); return n == 1 }, 5*time.Second, 10*time.Millisecond)
+
+	// the download ignoring the cache waits for the download reading the cache, which joins the running download
+	cancelFresh, freshDone := download(true)
+	cancelJoined, joinedDone := download(false)
+	time.Sleep(200 * time.Millisecond)
+	n, _ := counts()
+	assert.Equal(t, 1, n, "no other pull may start while the image is downloaded")
+
+	// once the running download is canceled, the download ignoring the cache starts and is joined by later requests
+	cancelCached()
+	cancelJoined()
+	assert.ErrorIs(t, <-cachedDone, context.Canceled)
+	assert.ErrorIs(t, <-joinedDone, context.Canceled)
+	require.Eventually(t, func() bool { n, inFlight := counts(); return n == 2 && inFlight == 1 }, 5*time.Second, 10*time.Millisecond)
+
+	cancelLate, lateDone := download(false)
+	time.Sleep(200 * time.Millisecond)
+	n, _ = counts()
+	assert.Equal(t, 2, n, "requests reading the cache join the download ignoring it")
+
+	cancelFresh()
+	cancelLate()
+	assert.ErrorIs(t, <-freshDone, context.Canceled)
+	assert.ErrorIs(t, <-lateDone, context.Canceled)
+
+	mu.Lock()
+	defer mu.Unlock()
+	assert.Equal(t, 1, maxInFlight, "the image must never be pulled twice at once")
+}
+
+func TestManagerMaxConcurrentDownloads(t *testing.T) {
+	// the registry never responds, so every download stays in progress until it is canceled
+	var mu sync.Mutex
+	requests := map[string]int{}
+	registry := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		for _, repo := range []string{"macos-a", "macos-b"} {
+			if strings.Contains(r.URL.Path, "/"+repo+"/") {
+				requests[repo]++
+			}
+		}
+		mu.Unlock()
+		<-r.Context().Done()
+	}))
+	t.Cleanup(registry.Close)
+	count := func(repo string) int {
+		mu.Lock()
+		defer mu.Unlock()
+		return requests[repo]
+	}
+
+	m := downloader.NewManager(event.LogEventRecorder{}, t.TempDir())
+	m.SetMaxConcurrentDownloads(1)
+	host := strings.TrimPrefix(registry.URL, "http://")
+	download := func(image string) (context.CancelFunc, <-chan error) {
+		ctx, cancel := context.WithCancel(context.Background())
+		done := make(chan error, 1)
+		go func() {
+			_, _, err := m.Download(ctx, host+"/"+image, false)
+			done <- err
+		}()
+		return cancel, done
+	}
+
+	cancelFirst, firstDone := download("macos-a:latest")
+	require.Eventually(t, func() bool { return count("macos-a") > 0 }, 5*time.Second, 10*time.Millisecond)
+
+	// the download of another image waits for the first one, which subscribers still join
+	cancelSecond, secondDone := download("macos-b:latest")
+	cancelJoined, joinedDone := download("macos-a:latest")
+	time.Sleep(200 * time.Millisecond)
+	assert.Zero(t, count("macos-b"), "the second download may not start while the first one runs")
+	assert.Equal(t, 1, count("macos-a"), "subscribers join the running download")
+
+	// once the first download ends, the second one starts
+	cancelFirst()
+	cancelJoined()
+	assert.ErrorIs(t, <-firstDone, context.Canceled)
+	assert.ErrorIs(t, <-joinedDone, context.Canceled)
+	require.Eventually(t, func() bool { return count("macos-b") > 0 }, 5*time.Second, 10*time.Millisecond)
+
+	cancelSecond()
+	assert.ErrorIs(t, <-secondDone, context.Canceled)
+}
+
+// writeCachedImage writes the content of an image to its directory in the cache, verified against its digests.
+func writeCachedImage(t *testing.T, cachePath, image, config string) string {
+	t.Helper()
+	ref, err := downloader.ParseReference(image)
+	require.NoError(t, err)
+	blobs := filepath.Join(cachePath, "blobs", downloader.CachePath(ref))
+	require.NoError(t, os.MkdirAll(blobs, 0o755))
+
+	verified := time.Now().Add(time.Second)
+	for name, content := range map[string]string{"config.json": config, "disk.img": "disk", "aux.img": "aux"} {
+		path := filepath.Join(blobs, name)
+		require.NoError(t, os.WriteFile(path, []byte(content), 0o600))
+		require.NoError(t, os.WriteFile(path+disk.DigestFileSuffix, []byte(digest.FromString(content)), 0o600))
+		require.NoError(t, os.Chtimes(path+disk.DigestFileSuffix, verified, verified))
+	}
+	return blobs
+}
+
+func TestManagerCached(t *testing.T) {
+	ctx := context.Background()
+	const image = "registry.example.com/macos:latest"
+	const config = `{"os":"darwin","hardwareModelData":"aGFyZHdhcmU=","machineIdData":"bWFjaGluZQ==",` +
+		`"storage":[{"mediatype":"application/vnd.agoda.macosvz.aux.image.v1","file":"aux.img"},` +
+		`{"mediatype":"application/vnd.agoda.macosvz.disk.image.v1","file":"disk.img"}]}`
+
+	cachePath := t.TempDir()
+	m := downloader.NewManager(event.LogEventRecorder{}, cachePath)
+	_, ok := m.Cached(ctx, image)
+	assert.False(t, ok, "nothing is cached yet")
+
+	blobs := writeCachedImage(t, cachePath, image, config)
+	cfg, ok := m.Cached(ctx, "registry.example.com/macos")
+	require.True(t, ok)
+	assert.Equal(t, filepath.Join(blobs, "disk.img"), cfg.BlockStoragePath)
+	assert.Equal(t, filepath.Join(blobs, "aux.img"), cfg.AuxiliaryStoragePath)
+	assert.Equal(t, "aGFyZHdhcmU=", cfg.HardwareModelData)
+	assert.Equal(t, "bWFjaGluZQ==", cfg.MachineIdentifierData)
+
+	// a file modified after its verification is not trusted
+	modified := time.Now().Add(2 * time.Second)
+	require.NoError(t, os.Chtimes(filepath.Join(blobs, "disk.img"), modified, modified))
+	_, ok = m.Cached(ctx, image)
+	assert.False(t, ok)
+
+	// disk image layers are composed by the download
+	layered := strings.Replace(config, `]}`, `,{"mediatype":"application/vnd.agoda.macosvz.disk.image.layer.v1","file":""}]}`, 1)
+	writeCachedImage(t, cachePath, image, layered)
+	_, ok = m.Cached(ctx, image)
+	assert.False(t, ok)
+}
@@ -0,0 +1,33 @@
+package downloader
+
+import (
+	"fmt"
+
+	"github.com/virtual-kubelet/virtual-kubelet/errdefs"
+	"oras.land/oras-go/v2/registry"
+)
+
+// ValidateRegistryMirrors validates the mirrors of the registries, keyed by the host of the registry they mirror.
+func ValidateRegistryMirrors(mirrors map[string]string) error {
+	for host, mirror := range mirrors {
+		if err := (registry.Reference{Registry: host}).ValidateRegistry(); err != nil {
+			return errdefs.AsInvalidInput(fmt.Errorf("invalid mirrored registry %q: %w", host, err))
+		}
+		if err := (registry.Reference{Registry: mirror}).ValidateRegistry(); err != nil {
+			return errdefs.AsInvalidInput(fmt.Errorf("invalid mirror %q of registry %s: %w", mirror, host, err))
+		}
+	}
+	return nil
+}
+
+// nextPullReference returns the reference to pull after a failed pull of 'last'.
+// Pulls of a registry with a mirror alternate between the registry and its mirror,
+// so that a failing registry is backed by the mirror and the other way around.
+func nextPullReference(ref, last registry.Reference, mirrors map[string]string) registry.Reference {
+	mirror, ok := mirrors[ref.Registry]
+	if !ok || last.Registry != ref.Registry {
+		return ref
+	}
+	last.Registry = mirror
+	return last
+}
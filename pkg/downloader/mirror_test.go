@@ -0,0 +1,57 @@
+package downloader_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/agoda-com/macOS-vz-kubelet/pkg/downloader"
+	"github.com/agoda-com/macOS-vz-kubelet/pkg/event"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDownloadRetriesAgainstMirror(t *testing.T) {
+	var primaryRequests, mirrorRequests atomic.Int32
+	var mirrorPaths []string
+	primary := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		primaryRequests.Add(1)
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	t.Cleanup(primary.Close)
+	mirror := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if mirrorRequests.Add(1) == 1 {
+			mirrorPaths = append(mirrorPaths, r.URL.Path)
+		}
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	t.Cleanup(mirror.Close)
+
+	primaryHost := strings.TrimPrefix(primary.URL, "http://")
+	mirrorHost := strings.TrimPrefix(mirror.URL, "http://")
+	_, err := downloader.Download(context.Background(), downloader.Params{
+		Ref:           primaryHost + "/macos:latest",
+		StorePath:     t.TempDir(),
+		MinRetryDelay: time.Millisecond,
+		MaxDelay:      time.Second,
+		MaxAttempts:   2,
+		Mirrors:       map[string]string{primaryHost: mirrorHost},
+	}, event.LogEventRecorder{})
+	require.Error(t, err)
+
+	assert.Positive(t, primaryRequests.Load())
+	require.Positive(t, mirrorRequests.Load(), "the pull must be retried against the mirror")
+	assert.Equal(t, []string{"/v2/macos/manifests/latest"}, mirrorPaths)
+}
+
+func TestValidateRegistryMirrors(t *testing.T) {
+	assert.NoError(t, downloader.ValidateRegistryMirrors(nil))
+	assert.NoError(t, downloader.ValidateRegistryMirrors(map[string]string{"ghcr.io": "mirror.local:5000"}))
+	assert.Error(t, downloader.ValidateRegistryMirrors(map[string]string{"ghcr.io": ""}))
+	assert.Error(t, downloader.ValidateRegistryMirrors(map[string]string{"ghcr.io/org": "mirror.local"}))
+}
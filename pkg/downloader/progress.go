@@ -0,0 +1,73 @@
+package downloader
+
+import (
+	"context"
+	"io"
+	"sync/atomic"
+
+	"github.com/agoda-com/macOS-vz-kubelet/pkg/oci"
+
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+	"oras.land/oras-go/v2/content"
+)
+
+// Progress tracks the number of bytes transferred by a download operation.
+// It is safe for concurrent use.
+type Progress struct {
+	completed atomic.Int64
+	total     atomic.Int64
+}
+
+// Snapshot returns the number of bytes completed so far and the total number of bytes expected.
+// The total is zero until the image manifest has been resolved.
+func (p *Progress) Snapshot() (completed, total int64) {
+	return p.completed.Load(), p.total.Load()
+}
+
+// reset clears the progress, e.g. before a download attempt is retried.
+func (p *Progress) reset() {
+	p.completed.Store(0)
+	p.total.Store(0)
+}
+
+// findSuccessors returns the successors of the descriptor and accounts their sizes in the total.
+func (p *Progress) findSuccessors(ctx context.Context, fetcher content.Fetcher, desc ocispec.Descriptor) ([]ocispec.Descriptor, error) {
+	successors, err := content.Successors(ctx, fetcher, desc)
+	if err != nil {
+		return nil, err
+	}
+	for _, s := range successors {
+		p.total.Add(s.Size)
+	}
+	return successors, nil
+}
+
+// onCopySkipped accounts content that already exists in the store as completed.
+func (p *Progress) onCopySkipped(_ context.Context, desc ocispec.Descriptor) error {
+	p.completed.Add(desc.Size)
+	return nil
+}
+
+// progressStore wraps the OCI store to account the bytes of the pushed content.
+type progressStore struct {
+	*oci.Store
+	progress *Progress
+}
+
+// Push saves the content to the underlying store while counting the bytes read.
+func (s *progressStore) Push(ctx context.Context, expected ocispec.Descriptor, r io.Reader) error {
+	return s.Store.Push(ctx, expected, &progressReader{Reader: r, progress: s.progress})
+}
+
+// progressReader counts the bytes read from the underlying reader.
+type progressReader struct {
+	io.Reader
+	progress *Progress
+}
+
+// Read reads from the underlying reader and accounts the bytes read as completed.
+func (r *progressReader) Read(p []byte) (int, error) {
+	n, err := r.Reader.Read(p)
+	r.progress.completed.Add(int64(n))
+	return n, err
+}
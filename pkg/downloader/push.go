@@ -0,0 +1,103 @@
+package downloader
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path/filepath"
+
+	"github.com/agoda-com/macOS-vz-kubelet/pkg/event"
+	"github.com/agoda-com/macOS-vz-kubelet/pkg/oci"
+	"github.com/agoda-com/macOS-vz-kubelet/pkg/vm/config"
+	"github.com/virtual-kubelet/virtual-kubelet/errdefs"
+	"github.com/virtual-kubelet/virtual-kubelet/log"
+	"github.com/virtual-kubelet/virtual-kubelet/trace"
+
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+	"oras.land/oras-go/v2"
+	"oras.land/oras-go/v2/registry/remote"
+	"oras.land/oras-go/v2/registry/remote/auth"
+)
+
+// Push packs the storage and the platform configuration of the options into an OCI image
+// and pushes it to the tag of the remote repository. The storage is compressed to temporary files,
+// which are removed once the push is done. It returns the descriptor of the pushed manifest.
+func Push(ctx context.Context, ref string, opts config.MacPlatformConfigurationOptions, eventRecorder event.EventRecorder) (desc ocispec.Descriptor, err error) {
+	ctx, span := trace.StartSpan(ctx, "Downloader.Push")
+	ctx = span.WithFields(ctx, log.Fields{
+		"ref":                  ref,
+		"blockStoragePath":     opts.BlockStoragePath,
+		"auxiliaryStoragePath": opts.AuxiliaryStoragePath,
+	})
+	defer func() {
+		span.SetStatus(err)
+		span.End()
+	}()
+
+	target, err := ParseReference(ref)
+	if err != nil {
+		return desc, err
+	}
+	if !isTag(target) {
+		return desc, errdefs.InvalidInputf("image reference %q must be a tag to push to", ref)
+	}
+
+	// the store only reads the storage, the compressed content is written to temporary files
+	store, err := oci.New(filepath.Dir(opts.BlockStoragePath), true, eventRecorder)
+	if err != nil {
+		return desc, fmt.Errorf("failed to initialize store: %w", err)
+	}
+	defer func() {
+		closeCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), StoreCloseTimeout)
+		defer cancel()
+		err = errors.Join(err, store.Close(closeCtx))
+	}()
+
+	layers := make([]ocispec.Descriptor, 0, len(oci.DefaultStorage)+1)
+	configDesc, err := store.Set(ctx, oci.NewMacOSConfig(opts.HardwareModelData, opts.MachineIdentifierData))
+	if err != nil {
+		return desc, fmt.Errorf("failed to add config: %w", err)
+	}
+	layers = append(layers, configDesc)
+	for _, storage := range []oci.StorageFile{
+		{MediaType: oci.MediaTypeAuxImage, Path: opts.AuxiliaryStoragePath},
+		{MediaType: oci.MediaTypeDiskImage, Path: opts.BlockStoragePath},
+	} {
+		layer, err := store.Add(ctx, string(storage.MediaType), storage.Path)
+		if err != nil {
+			return desc, fmt.Errorf("failed to add %s: %w", storage.MediaType.Title(), err)
+		}
+		layers = append(layers, layer)
+	}
+
+	manifestConfig, err := store.GetManifestConfigDescriptor(ctx)
+	if err != nil {
+		return desc, fmt.Errorf("failed to get manifest config: %w", err)
+	}
+	manifest, err := oras.PackManifest(ctx, store, oras.PackManifestVersion1_0, "", oras.PackManifestOptions{
+		Layers:           layers,
+		ConfigDescriptor: &manifestConfig,
+	})
+	if err != nil {
+		return desc, fmt.Errorf("failed to pack manifest: %w", err)
+	}
+	if err := store.Tag(ctx, manifest, target.Reference); err != nil {
+		return desc, fmt.Errorf("failed to tag manifest: %w", err)
+	}
+
+	repo, err := remote.NewRepository(target.String())
+	if err != nil {
+		return desc, fmt.Errorf("failed to create repository from reference %s: %w", target, err)
+	}
+	// Determine if the repository is using plain HTTP based on if it's localhost or a local IP
+	repo.PlainHTTP = isLocalhostOrLocalIP(repo.Reference.Registry)
+
+	ctx = auth.AppendRepositoryScope(ctx, repo.Reference, auth.ActionPull, auth.ActionPush)
+	desc, err = oras.Copy(ctx, store, target.Reference, repo, target.Reference, oras.DefaultCopyOptions)
+	if err != nil {
+		return desc, fmt.Errorf("failed to push %s: %w", target, err)
+	}
+
+	log.G(ctx).Infof("Pushed %s with digest %s", target, desc.Digest)
+	return desc, nil
+}
@@ -0,0 +1,153 @@
+package downloader_test
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"github.com/agoda-com/macOS-vz-kubelet/pkg/downloader"
+	"github.com/agoda-com/macOS-vz-kubelet/pkg/event"
+	"github.com/agoda-com/macOS-vz-kubelet/pkg/vm/config"
+
+	"github.com/opencontainers/go-digest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// testRegistry is an in-memory registry implementing the parts of the distribution API used to push and pull.
+type testRegistry struct {
+	mu        sync.Mutex
+	blobs     map[digest.Digest][]byte
+	manifests map[string][]byte // by tag and digest
+	types     map[string]string // media types of the manifests
+	uploads   atomic.Int32
+}
+
+func newTestRegistry(t *testing.T) string {
+	r := &testRegistry{
+		blobs:     map[digest.Digest][]byte{},
+		manifests: map[string][]byte{},
+		types:     map[string]string{},
+	}
+	server := httptest.NewServer(r)
+	t.Cleanup(server.Close)
+	return strings.TrimPrefix(server.URL, "http://")
+}
+
+func (r *testRegistry) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	path := strings.TrimPrefix(req.URL.Path, "/v2/")
+	switch {
+	case strings.Contains(path, "/blobs/uploads/"):
+		if req.Method == http.MethodPost {
+			w.Header().Set("Location", fmt.Sprintf("/v2/%s%d", path, r.uploads.Add(1)))
+			w.WriteHeader(http.StatusAccepted)
+			return
+		}
+		data, _ := io.ReadAll(req.Body)
+		d := digest.Digest(req.URL.Query().Get("digest"))
+		if d.Validate() != nil || digest.FromBytes(data) != d {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		r.blobs[d] = data
+		w.WriteHeader(http.StatusCreated)
+	case strings.Contains(path, "/blobs/"):
+		data, ok := r.blobs[digest.Digest(path[strings.LastIndex(path, "/")+1:])]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Header().Set("Content-Length", fmt.Sprint(len(data)))
+		if req.Method == http.MethodGet {
+			_, _ = w.Write(data)
+		}
+	case strings.Contains(path, "/manifests/"):
+		ref := path[strings.LastIndex(path, "/")+1:]
+		if req.Method == http.MethodPut {
+			data, _ := io.ReadAll(req.Body)
+			d := digest.FromBytes(data)
+			for _, key := range []string{ref, d.String()} {
+				r.manifests[key] = data
+				r.types[key] = req.Header.Get("Content-Type")
+			}
+			w.Header().Set("Docker-Content-Digest", d.String())
+			w.WriteHeader(http.StatusCreated)
+			return
+		}
+		data, ok := r.manifests[ref]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Header().Set("Content-Type", r.types[ref])
+		w.Header().Set("Docker-Content-Digest", digest.FromBytes(data).String())
+		w.Header().Set("Content-Length", fmt.Sprint(len(data)))
+		if req.Method == http.MethodGet {
+			_, _ = w.Write(data)
+		}
+	default:
+		w.WriteHeader(http.StatusNotFound)
+	}
+}
+
+func TestPushIsPullable(t *testing.T) {
+	host := newTestRegistry(t)
+	dir := t.TempDir()
+	disk := bytes.Repeat([]byte("disk"), 4096)
+	aux := []byte("nvram")
+	opts := config.MacPlatformConfigurationOptions{
+		BlockStoragePath:      filepath.Join(dir, "disk.img"),
+		AuxiliaryStoragePath:  filepath.Join(dir, "aux.img"),
+		HardwareModelData:     "aGFyZHdhcmU=",
+		MachineIdentifierData: "bWFjaGluZQ==",
+	}
+	require.NoError(t, os.WriteFile(opts.BlockStoragePath, disk, 0o644))
+	require.NoError(t, os.WriteFile(opts.AuxiliaryStoragePath, aux, 0o644))
+
+	ref := host + "/exported/macos:snapshot"
+	desc, err := downloader.Push(context.Background(), ref, opts, event.LogEventRecorder{})
+	require.NoError(t, err)
+	assert.NotEmpty(t, desc.Digest)
+
+	// pulling the pushed image validates its content against the digests of the manifest
+	cfg, err := downloader.Download(context.Background(), downloader.Params{
+		Ref:         ref,
+		StorePath:   t.TempDir(),
+		MaxAttempts: 1,
+	}, event.LogEventRecorder{})
+	require.NoError(t, err)
+	assert.Equal(t, opts.HardwareModelData, cfg.HardwareModelData)
+	assert.Equal(t, opts.MachineIdentifierData, cfg.MachineIdentifierData)
+
+	pulledDisk, err := os.ReadFile(cfg.BlockStoragePath)
+	require.NoError(t, err)
+	assert.Equal(t, disk, pulledDisk)
+	pulledAux, err := os.ReadFile(cfg.AuxiliaryStoragePath)
+	require.NoError(t, err)
+	assert.Equal(t, aux, pulledAux)
+
+	// the pushed manifest is the one pulled by digest
+	_, err = downloader.Download(context.Background(), downloader.Params{
+		Ref:         host + "/exported/macos@" + desc.Digest.String(),
+		StorePath:   t.TempDir(),
+		MaxAttempts: 1,
+	}, event.LogEventRecorder{})
+	assert.NoError(t, err)
+}
+
+func TestPushRequiresTag(t *testing.T) {
+	_, err := downloader.Push(context.Background(), "localhost:5000/macos@sha256:"+strings.Repeat("0", 64), config.MacPlatformConfigurationOptions{}, event.LogEventRecorder{})
+	assert.Error(t, err)
+}
@@ -0,0 +1,61 @@
+package downloader
+
+import (
+	"context"
+	"io"
+
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+	"golang.org/x/time/rate"
+	"oras.land/oras-go/v2"
+)
+
+// maxBandwidthBurst is the maximum number of bytes read at once from a rate limited reader.
+const maxBandwidthBurst = 32 * 1024
+
+// NewBandwidthLimiter returns a limiter allowing 'limit' bytes per second.
+// The burst is one second worth of bytes, capped at 32KiB, so that the limit is enforced smoothly.
+// Zero or negative limit means unlimited, in which case nil is returned.
+func NewBandwidthLimiter(limit int64) *rate.Limiter {
+	if limit <= 0 {
+		return nil
+	}
+	return rate.NewLimiter(rate.Limit(limit), int(min(limit, maxBandwidthBurst)))
+}
+
+// NewRateLimitedReader returns a reader that throttles reads from 'r' according to the limiter.
+// Reads fail with the context error once the context is done while waiting for the limiter.
+func NewRateLimitedReader(ctx context.Context, r io.Reader, limiter *rate.Limiter) io.Reader {
+	return &rateLimitedReader{ctx: ctx, Reader: r, limiter: limiter}
+}
+
+// rateLimitedReader throttles the reads from the underlying reader.
+type rateLimitedReader struct {
+	io.Reader
+	ctx     context.Context
+	limiter *rate.Limiter
+}
+
+// Read reads at most a burst of bytes from the underlying reader and waits until the limiter allows them.
+func (r *rateLimitedReader) Read(p []byte) (int, error) {
+	if burst := r.limiter.Burst(); len(p) > burst {
+		p = p[:burst]
+	}
+	n, err := r.Reader.Read(p)
+	if n > 0 {
+		if werr := r.limiter.WaitN(r.ctx, n); werr != nil {
+			return n, werr
+		}
+	}
+	return n, err
+}
+
+// throttledStore wraps the target to limit the bandwidth of the pushed content.
+type throttledStore struct {
+	oras.Target
+	limiter *rate.Limiter
+}
+
+// Push saves the content to the underlying target while throttling the reads.
+func (s *throttledStore) Push(ctx context.Context, expected ocispec.Descriptor, r io.Reader) error {
+	return s.Target.Push(ctx, expected, NewRateLimitedReader(ctx, r, s.limiter))
+}
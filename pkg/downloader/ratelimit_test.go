@@ -0,0 +1,53 @@
+package downloader_test
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/agoda-com/macOS-vz-kubelet/pkg/downloader"
+)
+
+func TestNewBandwidthLimiterUnlimited(t *testing.T) {
+	assert.Nil(t, downloader.NewBandwidthLimiter(0))
+	assert.Nil(t, downloader.NewBandwidthLimiter(-1))
+}
+
+func TestRateLimitedReader(t *testing.T) {
+	const (
+		limit = 16 * 1024 // bytes per second, below the maximum burst so that the burst equals the limit
+		size  = 40 * 1024
+	)
+	limiter := downloader.NewBandwidthLimiter(limit)
+	require.NotNil(t, limiter)
+	require.Equal(t, limit, limiter.Burst())
+
+	payload := bytes.Repeat([]byte{0xAB}, size)
+	r := downloader.NewRateLimitedReader(context.Background(), bytes.NewReader(payload), limiter)
+
+	start := time.Now()
+	got, err := io.ReadAll(r)
+	elapsed := time.Since(start)
+	require.NoError(t, err)
+	assert.Equal(t, payload, got)
+
+	// the initial burst is available immediately, the rest must be read no faster than the limit
+	throughput := float64(size-limiter.Burst()) / elapsed.Seconds()
+	assert.LessOrEqual(t, throughput, float64(limit))
+}
+
+func TestRateLimitedReaderCanceled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	limiter := downloader.NewBandwidthLimiter(1)
+	r := downloader.NewRateLimitedReader(ctx, bytes.NewReader([]byte("payload")), limiter)
+
+	_, err := io.ReadAll(r)
+	assert.ErrorIs(t, err, context.Canceled)
+}
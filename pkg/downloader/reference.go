@@ -0,0 +1,121 @@
+package downloader
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/opencontainers/go-digest"
+	"github.com/virtual-kubelet/virtual-kubelet/errdefs"
+	"oras.land/oras-go/v2/registry"
+)
+
+const (
+	// DefaultTag is the tag of image references that specify neither a tag nor a digest.
+	DefaultTag = "latest"
+
+	// tagsDir and digestsDir separate the references of a repository in the cache paths.
+	// Repository path components cannot start with an underscore, so they never collide with a repository.
+	tagsDir    = "_tags"
+	digestsDir = "_digests"
+
+	// pinsDir is the directory within the store path holding the digests the tags were resolved to.
+	pinsDir = "pins"
+	// blobsDir is the directory within the store path holding the content of the images.
+	blobsDir = "blobs"
+)
+
+// Layout selects how the content of the images is laid out within the store path.
+type Layout string
+
+const (
+	// LayoutReference keeps the content of every image in the directory of its reference.
+	LayoutReference Layout = "reference"
+	// LayoutSharded keeps the content once under blobs/<algorithm>/<shard>/<digest>, shared by the images
+	// with the same content, and links it into the directories of their references.
+	LayoutSharded Layout = "sharded"
+)
+
+// ParseLayout parses the layout of the content of the images.
+func ParseLayout(s string) (Layout, error) {
+	switch l := Layout(s); l {
+	case LayoutReference, LayoutSharded:
+		return l, nil
+	}
+	return "", errdefs.InvalidInputf("invalid image store layout %q, expected %s or %s", s, LayoutReference, LayoutSharded)
+}
+
+// ParseReference parses and normalizes the image reference, defaulting to DefaultTag
+// if the reference specifies neither a tag nor a digest.
+func ParseReference(ref string) (registry.Reference, error) {
+	r, err := registry.ParseReference(ref)
+	if err != nil {
+		return registry.Reference{}, errdefs.AsInvalidInput(fmt.Errorf("invalid image reference %q: %w", ref, err))
+	}
+	r.Reference = r.ReferenceOrDefault()
+	return r, nil
+}
+
+// NormalizeReference returns the normalized form of the image reference,
+// or the reference as is if it cannot be parsed.
+func NormalizeReference(ref string) string {
+	r, err := ParseReference(ref)
+	if err != nil {
+		return ref
+	}
+	return r.String()
+}
+
+// CachePath returns the path of the normalized reference relative to the cache directory.
+// Distinct references always result in distinct paths, e.g. the port of the registry
+// is never mistaken for a tag.
+func CachePath(ref registry.Reference) string {
+	// ports are the only thing that may follow a colon within the registry,
+	// and registry host names cannot contain underscores
+	parts := []string{strings.ReplaceAll(ref.Registry, ":", "_")}
+	parts = append(parts, strings.Split(ref.Repository, "/")...)
+	if d, err := ref.Digest(); err == nil {
+		parts = append(parts, digestsDir, d.Algorithm().String(), d.Encoded())
+	} else {
+		parts = append(parts, tagsDir, ref.ReferenceOrDefault())
+	}
+	return filepath.Join(parts...)
+}
+
+// isTag reports whether the reference points to a tag rather than a digest.
+func isTag(ref registry.Reference) bool {
+	_, err := ref.Digest()
+	return err != nil
+}
+
+// pinPath returns the path of the file holding the digest the tag reference was resolved to.
+func pinPath(storePath string, ref registry.Reference) string {
+	return filepath.Join(storePath, pinsDir, CachePath(ref))
+}
+
+// loadPin returns the digest the tag reference was last resolved to, if any.
+func loadPin(storePath string, ref registry.Reference) (digest.Digest, bool, error) {
+	data, err := os.ReadFile(pinPath(storePath, ref))
+	if errors.Is(err, os.ErrNotExist) {
+		return "", false, nil
+	}
+	if err != nil {
+		return "", false, err
+	}
+	d, err := digest.Parse(strings.TrimSpace(string(data)))
+	if err != nil {
+		return "", false, fmt.Errorf("invalid digest pinned for %s: %w", ref, err)
+	}
+	return d, true, nil
+}
+
+// savePin records the digest the tag reference was resolved to.
+func savePin(storePath string, ref registry.Reference, d digest.Digest) error {
+	path := pinPath(storePath, ref)
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	return os.WriteFile(path, []byte(d.String()+"\n"), 0o644)
+}
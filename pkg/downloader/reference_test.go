@@ -0,0 +1,107 @@
+package downloader_test
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/virtual-kubelet/virtual-kubelet/errdefs"
+
+	"github.com/agoda-com/macOS-vz-kubelet/pkg/downloader"
+)
+
+const testDigest = "sha256:9f86d081884c7d659a2feaa0c55ad015a3bf4f1b2b0b822cd15d6c15b0f00a08"
+
+func TestCachePath(t *testing.T) {
+	tests := []struct {
+		ref        string
+		normalized string
+		path       string
+	}{
+		{
+			ref:        "localhost:5000/macos:14.5",
+			normalized: "localhost:5000/macos:14.5",
+			path:       filepath.Join("localhost_5000", "macos", "_tags", "14.5"),
+		},
+		{
+			ref:        "localhost/5000/macos:14.5",
+			normalized: "localhost/5000/macos:14.5",
+			path:       filepath.Join("localhost", "5000", "macos", "_tags", "14.5"),
+		},
+		{
+			ref:        "registry.example.com/team/macos",
+			normalized: "registry.example.com/team/macos:latest",
+			path:       filepath.Join("registry.example.com", "team", "macos", "_tags", "latest"),
+		},
+		{
+			ref:        "localhost:5000/macos@" + testDigest,
+			normalized: "localhost:5000/macos@" + testDigest,
+			path:       filepath.Join("localhost_5000", "macos", "_digests", "sha256", testDigest[len("sha256:"):]),
+		},
+		{
+			ref:        "localhost:5000/macos:14.5@" + testDigest,
+			normalized: "localhost:5000/macos@" + testDigest,
+			path:       filepath.Join("localhost_5000", "macos", "_digests", "sha256", testDigest[len("sha256:"):]),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.ref, func(t *testing.T) {
+			ref, err := downloader.ParseReference(tt.ref)
+			require.NoError(t, err)
+			assert.Equal(t, tt.normalized, ref.String())
+			assert.Equal(t, tt.normalized, downloader.NormalizeReference(tt.ref))
+			assert.Equal(t, tt.path, downloader.CachePath(ref))
+		})
+	}
+}
+
+func TestCachePathDistinct(t *testing.T) {
+	refs := []string{
+		"localhost:5000/macos:14.5",
+		"localhost/5000/macos:14.5",
+		"localhost:5000/macos/14.5",
+		"localhost:5000/macos:latest",
+		"localhost:5001/macos:latest",
+		"localhost:5000/macos@" + testDigest,
+	}
+
+	paths := map[string]string{}
+	for _, raw := range refs {
+		ref, err := downloader.ParseReference(raw)
+		require.NoError(t, err)
+		path := downloader.CachePath(ref)
+		if other, ok := paths[path]; ok {
+			t.Errorf("%q and %q share the cache path %q", other, raw, path)
+		}
+		paths[path] = raw
+	}
+
+	// the default tag is normalized to the same path
+	explicit, err := downloader.ParseReference("localhost:5000/macos:latest")
+	require.NoError(t, err)
+	implicit, err := downloader.ParseReference("localhost:5000/macos")
+	require.NoError(t, err)
+	assert.Equal(t, downloader.CachePath(explicit), downloader.CachePath(implicit))
+}
+
+func TestParseReferenceInvalid(t *testing.T) {
+	for _, ref := range []string{"macos", "localhost:5000/MacOS:latest", "localhost:5000/macos@sha256:invalid"} {
+		_, err := downloader.ParseReference(ref)
+		require.Error(t, err, ref)
+		assert.True(t, errdefs.IsInvalidInput(err), ref)
+		assert.Equal(t, ref, downloader.NormalizeReference(ref))
+	}
+}
+
+func TestParseLayout(t *testing.T) {
+	for _, layout := range []downloader.Layout{downloader.LayoutReference, downloader.LayoutSharded} {
+		parsed, err := downloader.ParseLayout(string(layout))
+		require.NoError(t, err)
+		assert.Equal(t, layout, parsed)
+	}
+
+	_, err := downloader.ParseLayout("flat")
+	assert.True(t, errdefs.IsInvalidInput(err))
+}
@@ -0,0 +1,88 @@
+package downloader
+
+import (
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+
+	"github.com/virtual-kubelet/virtual-kubelet/errdefs"
+	"oras.land/oras-go/v2/registry"
+	"oras.land/oras-go/v2/registry/remote"
+	"oras.land/oras-go/v2/registry/remote/auth"
+	"oras.land/oras-go/v2/registry/remote/retry"
+	"sigs.k8s.io/yaml"
+)
+
+// RegistryConfig configures the HTTP client of a registry, for registries requiring more than
+// the default client, e.g. the token of a proxy in a header or a client certificate.
+type RegistryConfig struct {
+	// Headers are added to every request to the registry.
+	Headers map[string]string `json:"headers,omitempty"`
+	// CertFile and KeyFile are the PEM encoded client certificate and its key, presented to the registry.
+	CertFile string `json:"certFile,omitempty"`
+	KeyFile  string `json:"keyFile,omitempty"`
+}
+
+// RegistryClients maps the hosts of registries to the clients sending the requests to them.
+type RegistryClients map[string]remote.Client
+
+// LoadRegistryConfigs reads the configurations of the registries from a YAML or JSON file,
+// keyed by the host of the registry they apply to.
+func LoadRegistryConfigs(path string) (map[string]RegistryConfig, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read registry config file %s: %w", path, err)
+	}
+	var configs map[string]RegistryConfig
+	if err := yaml.UnmarshalStrict(data, &configs); err != nil {
+		return nil, errdefs.AsInvalidInput(fmt.Errorf("failed to parse registry config file %s: %w", path, err))
+	}
+	return configs, nil
+}
+
+// NewRegistryClients creates the clients of the registries from their configurations, keyed by the host of the registry.
+func NewRegistryClients(configs map[string]RegistryConfig) (RegistryClients, error) {
+	clients := make(RegistryClients, len(configs))
+	for host, cfg := range configs {
+		if err := (registry.Reference{Registry: host}).ValidateRegistry(); err != nil {
+			return nil, errdefs.AsInvalidInput(fmt.Errorf("invalid configured registry %q: %w", host, err))
+		}
+		client, err := newRegistryClient(cfg)
+		if err != nil {
+			return nil, errdefs.AsInvalidInput(fmt.Errorf("invalid configuration of registry %s: %w", host, err))
+		}
+		clients[host] = client
+	}
+	return clients, nil
+}
+
+// newRegistryClient creates a registry client with the headers and the client certificate of the configuration.
+func newRegistryClient(cfg RegistryConfig) (*auth.Client, error) {
+	header := auth.DefaultClient.Header.Clone()
+	for key, value := range cfg.Headers {
+		header.Set(key, value)
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	if cfg.CertFile != "" || cfg.KeyFile != "" {
+		if cfg.CertFile == "" || cfg.KeyFile == "" {
+			return nil, errors.New("client certificate requires both a certificate and a key file")
+		}
+		cert, err := tls.LoadX509KeyPair(cfg.CertFile, cfg.KeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load client certificate: %w", err)
+		}
+		transport.TLSClientConfig = &tls.Config{
+			Certificates: []tls.Certificate{cert},
+			MinVersion:   tls.VersionTLS12,
+		}
+	}
+
+	return &auth.Client{
+		Client: &http.Client{Transport: retry.NewTransport(transport)},
+		Header: header,
+		Cache:  auth.NewCache(),
+	}, nil
+}
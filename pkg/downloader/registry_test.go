@@ -0,0 +1,103 @@
+package downloader_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/agoda-com/macOS-vz-kubelet/pkg/downloader"
+	"github.com/agoda-com/macOS-vz-kubelet/pkg/event"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/virtual-kubelet/virtual-kubelet/errdefs"
+)
+
+func TestDownloadSendsRegistryHeaders(t *testing.T) {
+	newRegistry := func(tokens *[]string) string {
+		var mu sync.Mutex
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			mu.Lock()
+			*tokens = append(*tokens, r.Header.Get("X-Proxy-Token"))
+			mu.Unlock()
+			w.WriteHeader(http.StatusNotFound)
+		}))
+		t.Cleanup(server.Close)
+		return strings.TrimPrefix(server.URL, "http://")
+	}
+	var configuredTokens, otherTokens []string
+	configuredHost := newRegistry(&configuredTokens)
+	otherHost := newRegistry(&otherTokens)
+
+	clients, err := downloader.NewRegistryClients(map[string]downloader.RegistryConfig{
+		configuredHost: {Headers: map[string]string{"X-Proxy-Token": "secret"}},
+	})
+	require.NoError(t, err)
+
+	for _, host := range []string{configuredHost, otherHost} {
+		_, err := downloader.Download(context.Background(), downloader.Params{
+			Ref:           host + "/macos:latest",
+			StorePath:     t.TempDir(),
+			MinRetryDelay: time.Millisecond,
+			MaxAttempts:   1,
+			Clients:       clients,
+		}, event.LogEventRecorder{})
+		require.Error(t, err)
+	}
+
+	require.NotEmpty(t, configuredTokens)
+	for _, token := range configuredTokens {
+		assert.Equal(t, "secret", token, "the configured header must be sent to the registry")
+	}
+	require.NotEmpty(t, otherTokens)
+	for _, token := range otherTokens {
+		assert.Empty(t, token, "the header must not be sent to other registries")
+	}
+}
+
+func TestLoadRegistryConfigs(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "registries.yaml")
+	require.NoError(t, os.WriteFile(path, []byte(`
+ghcr.io:
+  headers:
+    X-Proxy-Token: secret
+registry.local:5000:
+  certFile: /etc/certs/client.pem
+  keyFile: /etc/certs/client-key.pem
+`), 0o600))
+
+	configs, err := downloader.LoadRegistryConfigs(path)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]downloader.RegistryConfig{
+		"ghcr.io":             {Headers: map[string]string{"X-Proxy-Token": "secret"}},
+		"registry.local:5000": {CertFile: "/etc/certs/client.pem", KeyFile: "/etc/certs/client-key.pem"},
+	}, configs)
+
+	require.NoError(t, os.WriteFile(path, []byte("ghcr.io:\n  header: {}\n"), 0o600))
+	_, err = downloader.LoadRegistryConfigs(path)
+	assert.True(t, errdefs.IsInvalidInput(err), err)
+
+	_, err = downloader.LoadRegistryConfigs(filepath.Join(t.TempDir(), "missing.yaml"))
+	assert.Error(t, err)
+}
+
+func TestNewRegistryClientsInvalid(t *testing.T) {
+	tests := map[string]map[string]downloader.RegistryConfig{
+		"invalid host":          {"ghcr.io/org": {}},
+		"missing key file":      {"ghcr.io": {CertFile: "client.pem"}},
+		"missing certificate":   {"ghcr.io": {CertFile: "missing.pem", KeyFile: "missing-key.pem"}},
+		"missing cert of a key": {"ghcr.io": {KeyFile: "client-key.pem"}},
+	}
+	for name, configs := range tests {
+		t.Run(name, func(t *testing.T) {
+			_, err := downloader.NewRegistryClients(configs)
+			assert.True(t, errdefs.IsInvalidInput(err), err)
+		})
+	}
+}
@@ -0,0 +1,57 @@
+package downloader
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"sync/atomic"
+
+	"github.com/agoda-com/macOS-vz-kubelet/pkg/oci"
+
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+	"oras.land/oras-go/v2/content"
+)
+
+// ErrImageTooLarge is returned for images declaring more content than the maximum image size.
+var ErrImageTooLarge = errors.New("image exceeds the maximum image size")
+
+// findSuccessorsFunc finds the successors of the content of a descriptor, see oras.CopyGraphOptions.FindSuccessors.
+type findSuccessorsFunc func(ctx context.Context, fetcher content.Fetcher, desc ocispec.Descriptor) ([]ocispec.Descriptor, error)
+
+// sizeGuard fails the copy of an image once its manifests declare more content than the limit,
+// before the content itself is fetched.
+type sizeGuard struct {
+	ref      string
+	limit    int64
+	declared atomic.Int64
+}
+
+// findSuccessors wraps the function finding the successors of the descriptors to account their declared sizes.
+func (g *sizeGuard) findSuccessors(find findSuccessorsFunc) findSuccessorsFunc {
+	return func(ctx context.Context, fetcher content.Fetcher, desc ocispec.Descriptor) ([]ocispec.Descriptor, error) {
+		successors, err := find(ctx, fetcher, desc)
+		if err != nil {
+			return nil, err
+		}
+		var size int64
+		for _, s := range successors {
+			size += declaredSize(s)
+		}
+		if declared := g.declared.Add(size); declared > g.limit {
+			return nil, fmt.Errorf("%w: %s declares %d bytes, the maximum is %d bytes", ErrImageTooLarge, g.ref, declared, g.limit)
+		}
+		return successors, nil
+	}
+}
+
+// declaredSize returns the size of the content of the descriptor, or its uncompressed size if annotated and larger.
+func declaredSize(desc ocispec.Descriptor) int64 {
+	size := desc.Size
+	if annotated, ok := desc.Annotations[oci.AnnotationUncompressedSize]; ok {
+		if uncompressed, err := strconv.ParseInt(annotated, 10, 64); err == nil && uncompressed > size {
+			size = uncompressed
+		}
+	}
+	return size
+}
@@ -0,0 +1,74 @@
+package downloader_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/agoda-com/macOS-vz-kubelet/pkg/downloader"
+	"github.com/agoda-com/macOS-vz-kubelet/pkg/event"
+	"github.com/agoda-com/macOS-vz-kubelet/pkg/oci"
+
+	"github.com/opencontainers/go-digest"
+	"github.com/opencontainers/image-spec/specs-go"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDownloadRejectsImageOverMaxSize(t *testing.T) {
+	// the disk image is small once compressed, but declares a large uncompressed size
+	manifest, err := json.Marshal(ocispec.Manifest{
+		Versioned: specs.Versioned{SchemaVersion: 2},
+		MediaType: ocispec.MediaTypeImageManifest,
+		Config: ocispec.Descriptor{
+			MediaType: string(oci.MediaTypeConfigV1),
+			Digest:    digest.FromString("config"),
+			Size:      6,
+		},
+		Layers: []ocispec.Descriptor{
+			{
+				MediaType: string(oci.MediaTypeAuxImage),
+				Digest:    digest.FromString("aux"),
+				Size:      3,
+			},
+			{
+				MediaType:   string(oci.MediaTypeDiskImage),
+				Digest:      digest.FromString("disk"),
+				Size:        4,
+				Annotations: map[string]string{oci.AnnotationUncompressedSize: "68719476736"},
+			},
+		},
+	})
+	require.NoError(t, err)
+
+	registry := &testRegistry{
+		blobs:     map[digest.Digest][]byte{},
+		manifests: map[string][]byte{"latest": manifest},
+		types:     map[string]string{"latest": ocispec.MediaTypeImageManifest},
+	}
+	var blobRequests atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.Contains(r.URL.Path, "/blobs/") {
+			blobRequests.Add(1)
+		}
+		registry.ServeHTTP(w, r)
+	}))
+	t.Cleanup(server.Close)
+
+	_, err = downloader.Download(context.Background(), downloader.Params{
+		Ref:           strings.TrimPrefix(server.URL, "http://") + "/macos:latest",
+		StorePath:     t.TempDir(),
+		MinRetryDelay: time.Millisecond,
+		MaxAttempts:   3,
+		MaxImageSize:  64 << 20,
+	}, event.LogEventRecorder{})
+	require.ErrorIs(t, err, downloader.ErrImageTooLarge)
+	assert.Contains(t, err.Error(), "declares 68719476745 bytes, the maximum is 67108864 bytes")
+	assert.Zero(t, blobRequests.Load(), "no content is fetched")
+}
@@ -4,7 +4,9 @@ import (
 	"context"
 	"fmt"
 	"strings"
+	"time"
 
+	"github.com/docker/go-units"
 	corev1 "k8s.io/api/core/v1"
 	"k8s.io/client-go/tools/record"
 	"k8s.io/kubernetes/pkg/kubelet/events"
@@ -12,6 +14,54 @@ import (
 
 const (
 	UIDField = "uid"
+
+	// NamespaceQuotaReachedReason is the event reason for pods waiting for the namespace quota of virtual machines.
+	NamespaceQuotaReachedReason = "NamespaceQuotaReached"
+
+	// RetainedFailedVirtualMachineReason is the event reason for failed pods whose virtual machine is kept for debugging.
+	RetainedFailedVirtualMachineReason = "RetainedFailedVirtualMachine"
+
+	// VirtualMachineCrashedReason is the event reason for virtual machines stopped by Virtualization.framework because of an error.
+	VirtualMachineCrashedReason = "VirtualMachineCrashed"
+
+	// VirtualMachineReadyReason is the event reason for virtual machines accepting SSH connections, summarizing how long their startup took.
+	VirtualMachineReadyReason = "VirtualMachineReady"
+
+	// EphemeralStorageExceededReason is the event reason for virtual machines consuming more ephemeral storage than the limit of their pods.
+	EphemeralStorageExceededReason = "EphemeralStorageExceeded"
+
+	// FailedToRestoreStateReason is the event reason for virtual machines booted from scratch because their snapshot could not be restored.
+	FailedToRestoreStateReason = "FailedToRestoreState"
+
+	// FailedToSetHostnameReason is the event reason for virtual machines whose hostname could not be set to the one of the pod.
+	FailedToSetHostnameReason = "FailedToSetHostname"
+
+	// FailedToSetSysctlsReason is the event reason for virtual machines whose sysctls were rejected or could not be set to those of the pod.
+	FailedToSetSysctlsReason = "FailedToSetSysctls"
+
+	// FailedToSetTimezoneReason is the event reason for virtual machines whose timezone could not be set to the one of the pod.
+	FailedToSetTimezoneReason = "FailedToSetTimezone"
+
+	// FailedToSyncClockReason is the event reason for virtual machines whose clock could not be synchronized after the boot.
+	FailedToSyncClockReason = "FailedToSyncClock"
+
+	// ForceStoppedReason is the event reason for virtual machines force-stopped because they did not shut down gracefully.
+	ForceStoppedReason = "ForceStopped"
+
+	// ReadinessTimeoutReason is the event reason for virtual machines that did not accept SSH connections within the readiness timeout.
+	ReadinessTimeoutReason = "ReadinessTimeout"
+
+	// OCICacheHitReason is the event reason for image content found valid in the local cache.
+	OCICacheHitReason = "OCICacheHit"
+
+	// OCICacheMissReason is the event reason for image content that has to be pulled from the registry.
+	OCICacheMissReason = "OCICacheMiss"
+
+	// OCIRepairedReason is the event reason for cached image content that failed validation and was pulled again.
+	OCIRepairedReason = "OCIRepaired"
+
+	// UnsupportedMediaTypeReason is the event reason for image content with a media type the store does not support.
+	UnsupportedMediaTypeReason = "UnsupportedMediaType"
 )
 
 type objectRefKeyType struct{}
@@ -48,6 +98,10 @@ func (r *KubeEventRecorder) PullingImage(ctx context.Context, image, containerNa
 	r.recordEvent(ctx, containerName, corev1.EventTypeNormal, events.PullingImage, "Pulling image \"%s\"", image)
 }
 
+func (r *KubeEventRecorder) PullingImageProgress(ctx context.Context, image, containerName, progress string) {
+	r.recordEvent(ctx, containerName, corev1.EventTypeNormal, events.PullingImage, "Pulling image \"%s\": %s", image, progress)
+}
+
 func (r *KubeEventRecorder) PulledImage(ctx context.Context, image, containerName, duration string) {
 	r.recordEvent(ctx, containerName, corev1.EventTypeNormal, events.PulledImage, "Successfully pulled image \"%s\" in %s", image, duration)
 }
@@ -56,6 +110,22 @@ func (r *KubeEventRecorder) FailedToValidateOCI(ctx context.Context, content str
 	r.recordEvent(ctx, "", corev1.EventTypeWarning, events.FailedToInspectImage, "Failed to validate OCI content: %s", content)
 }
 
+func (r *KubeEventRecorder) OCICacheHit(ctx context.Context, content, digest string) {
+	r.recordEvent(ctx, "", corev1.EventTypeNormal, OCICacheHitReason, "OCI content %s is present in the cache with digest %s", content, digest)
+}
+
+func (r *KubeEventRecorder) OCICacheMiss(ctx context.Context, content, digest string) {
+	r.recordEvent(ctx, "", corev1.EventTypeNormal, OCICacheMissReason, "OCI content %s with digest %s is not in the cache, pulling it", content, digest)
+}
+
+func (r *KubeEventRecorder) OCIRepaired(ctx context.Context, content, digest string) {
+	r.recordEvent(ctx, "", corev1.EventTypeNormal, OCIRepairedReason, "Corrupt OCI content %s was removed from the cache and pulled again with digest %s", content, digest)
+}
+
+func (r *KubeEventRecorder) UnsupportedMediaType(ctx context.Context, mediaType string, supported []string) {
+	r.recordEvent(ctx, "", corev1.EventTypeWarning, UnsupportedMediaTypeReason, "Unsupported OCI media type %s, supported media types are: %s", mediaType, strings.Join(supported, ", "))
+}
+
 func (r *KubeEventRecorder) FailedToPullImage(ctx context.Context, image, containerName string, err error) {
 	r.recordEvent(ctx, containerName, corev1.EventTypeWarning, events.FailedToPullImage, "Failed to pull image \"%s\": %v", image, err)
 }
@@ -80,6 +150,10 @@ func (r *KubeEventRecorder) FailedToStartContainer(ctx context.Context, containe
 	r.recordEvent(ctx, containerName, corev1.EventTypeWarning, events.FailedToStartContainer, "Failed to start container %s: %v", containerName, err)
 }
 
+func (r *KubeEventRecorder) BackOffStartContainer(ctx context.Context, containerName string, attempt int, err error) {
+	r.recordEvent(ctx, containerName, corev1.EventTypeWarning, events.BackOffStartContainer, "Back-off starting container %s after attempt %d: %v", containerName, attempt, err)
+}
+
 func (r *KubeEventRecorder) FailedPostStartHook(ctx context.Context, containerName string, cmd []string, err error) {
 	cmdStr := fmt.Sprintf("[%s]", strings.Join(cmd, ", "))
 	r.recordEvent(ctx, containerName, corev1.EventTypeWarning, events.FailedPostStartHook, "Exec lifecycle hook (%s) for Container \"%s\" failed - error: %v", cmdStr, containerName, err)
@@ -90,6 +164,58 @@ func (r *KubeEventRecorder) FailedPreStopHook(ctx context.Context, containerName
 	r.recordEvent(ctx, containerName, corev1.EventTypeWarning, events.FailedPreStopHook, "Exec lifecycle hook (%s) for Container \"%s\" failed - error: %v", cmdStr, containerName, err)
 }
 
+func (r *KubeEventRecorder) FailedToMountSharedDirectories(ctx context.Context, containerName string, paths []string) {
+	r.recordEvent(ctx, containerName, corev1.EventTypeWarning, events.FailedMountVolume, "Shared directories are not accessible in the guest: %s", strings.Join(paths, ", "))
+}
+
+func (r *KubeEventRecorder) FailedToRestoreState(ctx context.Context, containerName, snapshot string, err error) {
+	r.recordEvent(ctx, containerName, corev1.EventTypeWarning, FailedToRestoreStateReason, "Failed to restore the virtual machine from snapshot %s, booting it instead: %v", snapshot, err)
+}
+
+func (r *KubeEventRecorder) FailedToSetHostname(ctx context.Context, containerName, hostname string, err error) {
+	r.recordEvent(ctx, containerName, corev1.EventTypeWarning, FailedToSetHostnameReason, "Failed to set the hostname of the virtual machine to %s: %v", hostname, err)
+}
+
+func (r *KubeEventRecorder) FailedToSetSysctls(ctx context.Context, containerName string, sysctls []string, err error) {
+	r.recordEvent(ctx, containerName, corev1.EventTypeWarning, FailedToSetSysctlsReason, "Failed to set the sysctls %s of the virtual machine: %v", strings.Join(sysctls, ", "), err)
+}
+
+func (r *KubeEventRecorder) FailedToSetTimezone(ctx context.Context, containerName, timezone string, err error) {
+	r.recordEvent(ctx, containerName, corev1.EventTypeWarning, FailedToSetTimezoneReason, "Failed to set the timezone of the virtual machine to %s: %v", timezone, err)
+}
+
+func (r *KubeEventRecorder) FailedToSyncClock(ctx context.Context, containerName string, err error) {
+	r.recordEvent(ctx, containerName, corev1.EventTypeWarning, FailedToSyncClockReason, "Failed to synchronize the clock of the virtual machine: %v", err)
+}
+
+func (r *KubeEventRecorder) ForceStopped(ctx context.Context, containerName string, err error) {
+	r.recordEvent(ctx, containerName, corev1.EventTypeWarning, ForceStoppedReason, "Virtual machine did not shut down gracefully and was force-stopped, unsaved data may be lost: %v", err)
+}
+
+func (r *KubeEventRecorder) ReadinessTimeout(ctx context.Context, containerName string, timeout time.Duration, err error) {
+	r.recordEvent(ctx, containerName, corev1.EventTypeWarning, ReadinessTimeoutReason, "Virtual machine did not accept SSH connections within %s, failing it: %v", timeout, err)
+}
+
+func (r *KubeEventRecorder) NamespaceQuotaReached(ctx context.Context, containerName, namespace string, quota int) {
+	r.recordEvent(ctx, containerName, corev1.EventTypeWarning, NamespaceQuotaReachedReason, "Namespace %s has reached its quota of %d virtual machines, waiting for a slot", namespace, quota)
+}
+
+func (r *KubeEventRecorder) RetainedFailedVirtualMachine(ctx context.Context, containerName string) {
+	r.recordEvent(ctx, containerName, corev1.EventTypeWarning, RetainedFailedVirtualMachineReason, "Virtual machine of the failed pod is retained for debugging and occupies a slot until the pod is deleted")
+}
+
+func (r *KubeEventRecorder) VirtualMachineCrashed(ctx context.Context, containerName string, err error) {
+	r.recordEvent(ctx, containerName, corev1.EventTypeWarning, VirtualMachineCrashedReason, "Virtual machine of container %s crashed: %v", containerName, err)
+}
+
+func (r *KubeEventRecorder) EphemeralStorageExceeded(ctx context.Context, containerName string, usage, limit int64) {
+	r.recordEvent(ctx, containerName, corev1.EventTypeWarning, EphemeralStorageExceededReason, "Virtual machine of container %s uses %s of ephemeral storage, exceeding the limit of %s", containerName, units.HumanSize(float64(usage)), units.HumanSize(float64(limit)))
+}
+
+func (r *KubeEventRecorder) VirtualMachineReady(ctx context.Context, containerName string, startup string) {
+	r.recordEvent(ctx, containerName, corev1.EventTypeNormal, VirtualMachineReadyReason, "Virtual machine of container %s is ready after %s", containerName, startup)
+}
+
 func (r *KubeEventRecorder) recordEvent(ctx context.Context, containerName, eventType, reason, messageFmt string, args ...interface{}) {
 	objectRef, ok := GetObjectRef(ctx)
 	if !ok {
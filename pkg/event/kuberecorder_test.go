@@ -4,6 +4,7 @@ import (
 	"context"
 	"errors"
 	"testing"
+	"time"
 
 	"github.com/agoda-com/macOS-vz-kubelet/pkg/event"
 	corev1 "k8s.io/api/core/v1"
@@ -21,6 +22,12 @@ func TestKubeEventRecorder(t *testing.T) {
 				recorder.PullingImage(ctx, "nginx:latest", "nginx-container")
 			},
 		},
+		{
+			name: "PullingImageProgress",
+			action: func(ctx context.Context, recorder *event.KubeEventRecorder) {
+				recorder.PullingImageProgress(ctx, "nginx:latest", "nginx-container", "1 of 2 layers complete, 50% downloaded")
+			},
+		},
 		{
 			name: "PulledImage",
 			action: func(ctx context.Context, recorder *event.KubeEventRecorder) {
@@ -69,6 +76,12 @@ func TestKubeEventRecorder(t *testing.T) {
 				recorder.FailedToStartContainer(ctx, "nginx-container", errors.New("container failed"))
 			},
 		},
+		{
+			name: "BackOffStartContainer",
+			action: func(ctx context.Context, recorder *event.KubeEventRecorder) {
+				recorder.BackOffStartContainer(ctx, "nginx-container", 1, errors.New("internal error"))
+			},
+		},
 		{
 			name: "FailedPostStartHook",
 			action: func(ctx context.Context, recorder *event.KubeEventRecorder) {
@@ -81,6 +94,108 @@ func TestKubeEventRecorder(t *testing.T) {
 				recorder.FailedPreStopHook(ctx, "nginx-container", []string{"echo", "hello"}, errors.New("hook failed"))
 			},
 		},
+		{
+			name: "FailedToMountSharedDirectories",
+			action: func(ctx context.Context, recorder *event.KubeEventRecorder) {
+				recorder.FailedToMountSharedDirectories(ctx, "nginx-container", []string{"/Volumes/My Shared Files/data"})
+			},
+		},
+		{
+			name: "NamespaceQuotaReached",
+			action: func(ctx context.Context, recorder *event.KubeEventRecorder) {
+				recorder.NamespaceQuotaReached(ctx, "nginx-container", "default", 1)
+			},
+		},
+		{
+			name: "RetainedFailedVirtualMachine",
+			action: func(ctx context.Context, recorder *event.KubeEventRecorder) {
+				recorder.RetainedFailedVirtualMachine(ctx, "macos-container")
+			},
+		},
+		{
+			name: "VirtualMachineCrashed",
+			action: func(ctx context.Context, recorder *event.KubeEventRecorder) {
+				recorder.VirtualMachineCrashed(ctx, "macos-container", errors.New("virtual machine stopped with an error"))
+			},
+		},
+		{
+			name: "EphemeralStorageExceeded",
+			action: func(ctx context.Context, recorder *event.KubeEventRecorder) {
+				recorder.EphemeralStorageExceeded(ctx, "macos-container", 12<<30, 10<<30)
+			},
+		},
+		{
+			name: "VirtualMachineReady",
+			action: func(ctx context.Context, recorder *event.KubeEventRecorder) {
+				recorder.VirtualMachineReady(ctx, "macos-container", "1m5s (download 30s, wait 0s, boot 20s, IP 5s, SSH 10s)")
+			},
+		},
+		{
+			name: "FailedToSetHostname",
+			action: func(ctx context.Context, recorder *event.KubeEventRecorder) {
+				recorder.FailedToSetHostname(ctx, "macos-container", "builder-0", errors.New("sudo: a password is required"))
+			},
+		},
+		{
+			name: "ForceStopped",
+			action: func(ctx context.Context, recorder *event.KubeEventRecorder) {
+				recorder.ForceStopped(ctx, "macos-container", context.DeadlineExceeded)
+			},
+		},
+		{
+			name: "FailedToRestoreState",
+			action: func(ctx context.Context, recorder *event.KubeEventRecorder) {
+				recorder.FailedToRestoreState(ctx, "macos-container", "warm-xcode", errors.New("invalid hardware model"))
+			},
+		},
+		{
+			name: "FailedToSetSysctls",
+			action: func(ctx context.Context, recorder *event.KubeEventRecorder) {
+				recorder.FailedToSetSysctls(ctx, "macos-container", []string{"kern.securelevel"}, errors.New("sysctl kern.securelevel is not allowed"))
+			},
+		},
+		{
+			name: "FailedToSetTimezone",
+			action: func(ctx context.Context, recorder *event.KubeEventRecorder) {
+				recorder.FailedToSetTimezone(ctx, "macos-container", "Asia/Bangkok", errors.New("sudo: a password is required"))
+			},
+		},
+		{
+			name: "FailedToSyncClock",
+			action: func(ctx context.Context, recorder *event.KubeEventRecorder) {
+				recorder.FailedToSyncClock(ctx, "macos-container", errors.New("sntp: no reply"))
+			},
+		},
+		{
+			name: "ReadinessTimeout",
+			action: func(ctx context.Context, recorder *event.KubeEventRecorder) {
+				recorder.ReadinessTimeout(ctx, "macos-container", 5*time.Minute, errors.New("connection refused"))
+			},
+		},
+		{
+			name: "OCICacheHit",
+			action: func(ctx context.Context, recorder *event.KubeEventRecorder) {
+				recorder.OCICacheHit(ctx, "disk.img", "sha256:abc")
+			},
+		},
+		{
+			name: "OCICacheMiss",
+			action: func(ctx context.Context, recorder *event.KubeEventRecorder) {
+				recorder.OCICacheMiss(ctx, "disk.img", "sha256:abc")
+			},
+		},
+		{
+			name: "OCIRepaired",
+			action: func(ctx context.Context, recorder *event.KubeEventRecorder) {
+				recorder.OCIRepaired(ctx, "disk.img", "sha256:abc")
+			},
+		},
+		{
+			name: "UnsupportedMediaType",
+			action: func(ctx context.Context, recorder *event.KubeEventRecorder) {
+				recorder.UnsupportedMediaType(ctx, "application/octet-stream", []string{"application/vnd.agoda.macosvz.disk.image.v1"})
+			},
+		},
 	}
 
 	for _, tt := range tests {
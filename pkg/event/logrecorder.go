@@ -4,6 +4,7 @@ import (
 	"context"
 	"fmt"
 	"strings"
+	"time"
 
 	"github.com/virtual-kubelet/virtual-kubelet/log"
 )
@@ -14,6 +15,10 @@ func (r LogEventRecorder) PullingImage(ctx context.Context, image, _ string) {
 	log.G(ctx).Infof("Pulling image \"%s\"", image)
 }
 
+func (r LogEventRecorder) PullingImageProgress(ctx context.Context, image, _, progress string) {
+	log.G(ctx).Infof("Pulling image \"%s\": %s", image, progress)
+}
+
 func (r LogEventRecorder) PulledImage(ctx context.Context, image, _, duration string) {
 	log.G(ctx).Infof("Successfully pulled image \"%s\" in %s", image, duration)
 }
@@ -22,6 +27,22 @@ func (r LogEventRecorder) FailedToValidateOCI(ctx context.Context, content strin
 	log.G(ctx).Warnf("Failed to validate OCI content: %s", content)
 }
 
+func (r LogEventRecorder) OCICacheHit(ctx context.Context, content, digest string) {
+	log.G(ctx).Infof("OCI content %s is present in the cache with digest %s", content, digest)
+}
+
+func (r LogEventRecorder) OCICacheMiss(ctx context.Context, content, digest string) {
+	log.G(ctx).Infof("OCI content %s with digest %s is not in the cache, pulling it", content, digest)
+}
+
+func (r LogEventRecorder) OCIRepaired(ctx context.Context, content, digest string) {
+	log.G(ctx).Infof("Corrupt OCI content %s was removed from the cache and pulled again with digest %s", content, digest)
+}
+
+func (r LogEventRecorder) UnsupportedMediaType(ctx context.Context, mediaType string, supported []string) {
+	log.G(ctx).Warnf("Unsupported OCI media type %s, supported media types are: %s", mediaType, strings.Join(supported, ", "))
+}
+
 func (r LogEventRecorder) FailedToPullImage(ctx context.Context, image, _ string, err error) {
 	log.G(ctx).WithError(err).Warnf("Failed to pull image \"%s\"", image)
 }
@@ -46,6 +67,10 @@ func (r LogEventRecorder) FailedToStartContainer(ctx context.Context, containerN
 	log.G(ctx).WithError(err).Errorf("Failed to start container %s", containerName)
 }
 
+func (r LogEventRecorder) BackOffStartContainer(ctx context.Context, containerName string, attempt int, err error) {
+	log.G(ctx).WithError(err).Warnf("Back-off starting container %s after attempt %d", containerName, attempt)
+}
+
 func (r LogEventRecorder) FailedPostStartHook(ctx context.Context, containerName string, cmd []string, err error) {
 	cmdStr := fmt.Sprintf("[%s]", strings.Join(cmd, ", "))
 	log.G(ctx).WithError(err).Errorf("Exec lifecycle hook (%s) for Container \"%s\" failed - error: %v", cmdStr, containerName, err)
@@ -55,3 +80,55 @@ func (r LogEventRecorder) FailedPreStopHook(ctx context.Context, containerName s
 	cmdStr := fmt.Sprintf("[%s]", strings.Join(cmd, ", "))
 	log.G(ctx).WithError(err).Errorf("Exec lifecycle hook (%s) for Container \"%s\" failed - error: %v", cmdStr, containerName, err)
 }
+
+func (r LogEventRecorder) FailedToMountSharedDirectories(ctx context.Context, _ string, paths []string) {
+	log.G(ctx).Warnf("Shared directories are not accessible in the guest: %s", strings.Join(paths, ", "))
+}
+
+func (r LogEventRecorder) FailedToRestoreState(ctx context.Context, _, snapshot string, err error) {
+	log.G(ctx).WithError(err).Warnf("Failed to restore the virtual machine from snapshot %s, booting it instead", snapshot)
+}
+
+func (r LogEventRecorder) FailedToSetHostname(ctx context.Context, _, hostname string, err error) {
+	log.G(ctx).WithError(err).Warnf("Failed to set the hostname of the virtual machine to %s", hostname)
+}
+
+func (r LogEventRecorder) FailedToSetSysctls(ctx context.Context, _ string, sysctls []string, err error) {
+	log.G(ctx).WithError(err).Warnf("Failed to set the sysctls %s of the virtual machine", strings.Join(sysctls, ", "))
+}
+
+func (r LogEventRecorder) FailedToSetTimezone(ctx context.Context, _, timezone string, err error) {
+	log.G(ctx).WithError(err).Warnf("Failed to set the timezone of the virtual machine to %s", timezone)
+}
+
+func (r LogEventRecorder) FailedToSyncClock(ctx context.Context, _ string, err error) {
+	log.G(ctx).WithError(err).Warn("Failed to synchronize the clock of the virtual machine")
+}
+
+func (r LogEventRecorder) ForceStopped(ctx context.Context, containerName string, err error) {
+	log.G(ctx).WithError(err).Warnf("Virtual machine of container %s did not shut down gracefully and was force-stopped", containerName)
+}
+
+func (r LogEventRecorder) ReadinessTimeout(ctx context.Context, _ string, timeout time.Duration, err error) {
+	log.G(ctx).WithError(err).Warnf("Virtual machine did not accept SSH connections within %s, failing it", timeout)
+}
+
+func (r LogEventRecorder) NamespaceQuotaReached(ctx context.Context, _, namespace string, quota int) {
+	log.G(ctx).Warnf("Namespace %s has reached its quota of %d virtual machines, waiting for a slot", namespace, quota)
+}
+
+func (r LogEventRecorder) RetainedFailedVirtualMachine(ctx context.Context, _ string) {
+	log.G(ctx).Warn("Virtual machine of the failed pod is retained for debugging and occupies a slot until the pod is deleted")
+}
+
+func (r LogEventRecorder) VirtualMachineCrashed(ctx context.Context, containerName string, err error) {
+	log.G(ctx).WithError(err).Errorf("Virtual machine of container %s crashed", containerName)
+}
+
+func (r LogEventRecorder) EphemeralStorageExceeded(ctx context.Context, containerName string, usage, limit int64) {
+	log.G(ctx).Warnf("Virtual machine of container %s uses %d bytes of ephemeral storage, exceeding the limit of %d bytes", containerName, usage, limit)
+}
+
+func (r LogEventRecorder) VirtualMachineReady(ctx context.Context, containerName string, startup string) {
+	log.G(ctx).Infof("Virtual machine of container %s is ready after %s", containerName, startup)
+}
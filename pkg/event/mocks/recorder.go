@@ -6,6 +6,8 @@ import (
 	context "context"
 
 	mock "github.com/stretchr/testify/mock"
+
+	time "time"
 )
 
 // EventRecorder is an autogenerated mock type for the EventRecorder type
@@ -18,11 +20,21 @@ func (_m *EventRecorder) BackOffPullImage(ctx context.Context, image string, con
 	_m.Called(ctx, image, containerName, err)
 }
 
+// BackOffStartContainer provides a mock function with given fields: ctx, containerName, attempt, err
+func (_m *EventRecorder) BackOffStartContainer(ctx context.Context, containerName string, attempt int, err error) {
+	_m.Called(ctx, containerName, attempt, err)
+}
+
 // CreatedContainer provides a mock function with given fields: ctx, containerName
 func (_m *EventRecorder) CreatedContainer(ctx context.Context, containerName string) {
 	_m.Called(ctx, containerName)
 }
 
+// EphemeralStorageExceeded provides a mock function with given fields: ctx, containerName, usage, limit
+func (_m *EventRecorder) EphemeralStorageExceeded(ctx context.Context, containerName string, usage int64, limit int64) {
+	_m.Called(ctx, containerName, usage, limit)
+}
+
 // FailedPostStartHook provides a mock function with given fields: ctx, containerName, cmd, err
 func (_m *EventRecorder) FailedPostStartHook(ctx context.Context, containerName string, cmd []string, err error) {
 	_m.Called(ctx, containerName, cmd, err)
@@ -38,11 +50,41 @@ func (_m *EventRecorder) FailedToCreateContainer(ctx context.Context, containerN
 	_m.Called(ctx, containerName, err)
 }
 
+// FailedToMountSharedDirectories provides a mock function with given fields: ctx, containerName, paths
+func (_m *EventRecorder) FailedToMountSharedDirectories(ctx context.Context, containerName string, paths []string) {
+	_m.Called(ctx, containerName, paths)
+}
+
 // FailedToPullImage provides a mock function with given fields: ctx, image, containerName, err
 func (_m *EventRecorder) FailedToPullImage(ctx context.Context, image string, containerName string, err error) {
 	_m.Called(ctx, image, containerName, err)
 }
 
+// FailedToRestoreState provides a mock function with given fields: ctx, containerName, snapshot, err
+func (_m *EventRecorder) FailedToRestoreState(ctx context.Context, containerName string, snapshot string, err error) {
+	_m.Called(ctx, containerName, snapshot, err)
+}
+
+// FailedToSetHostname provides a mock function with given fields: ctx, containerName, hostname, err
+func (_m *EventRecorder) FailedToSetHostname(ctx context.Context, containerName string, hostname string, err error) {
+	_m.Called(ctx, containerName, hostname, err)
+}
+
+// FailedToSetSysctls provides a mock function with given fields: ctx, containerName, sysctls, err
+func (_m *EventRecorder) FailedToSetSysctls(ctx context.Context, containerName string, sysctls []string, err error) {
+	_m.Called(ctx, containerName, sysctls, err)
+}
+
+// FailedToSetTimezone provides a mock function with given fields: ctx, containerName, timezone, err
+func (_m *EventRecorder) FailedToSetTimezone(ctx context.Context, containerName string, timezone string, err error) {
+	_m.Called(ctx, containerName, timezone, err)
+}
+
+// FailedToSyncClock provides a mock function with given fields: ctx, containerName, err
+func (_m *EventRecorder) FailedToSyncClock(ctx context.Context, containerName string, err error) {
+	_m.Called(ctx, containerName, err)
+}
+
 // FailedToStartContainer provides a mock function with given fields: ctx, containerName, err
 func (_m *EventRecorder) FailedToStartContainer(ctx context.Context, containerName string, err error) {
 	_m.Called(ctx, containerName, err)
@@ -53,6 +95,31 @@ func (_m *EventRecorder) FailedToValidateOCI(ctx context.Context, content string
 	_m.Called(ctx, content)
 }
 
+// ForceStopped provides a mock function with given fields: ctx, containerName, err
+func (_m *EventRecorder) ForceStopped(ctx context.Context, containerName string, err error) {
+	_m.Called(ctx, containerName, err)
+}
+
+// NamespaceQuotaReached provides a mock function with given fields: ctx, containerName, namespace, quota
+func (_m *EventRecorder) NamespaceQuotaReached(ctx context.Context, containerName string, namespace string, quota int) {
+	_m.Called(ctx, containerName, namespace, quota)
+}
+
+// OCICacheHit provides a mock function with given fields: ctx, content, digest
+func (_m *EventRecorder) OCICacheHit(ctx context.Context, content string, digest string) {
+	_m.Called(ctx, content, digest)
+}
+
+// OCICacheMiss provides a mock function with given fields: ctx, content, digest
+func (_m *EventRecorder) OCICacheMiss(ctx context.Context, content string, digest string) {
+	_m.Called(ctx, content, digest)
+}
+
+// OCIRepaired provides a mock function with given fields: ctx, content, digest
+func (_m *EventRecorder) OCIRepaired(ctx context.Context, content string, digest string) {
+	_m.Called(ctx, content, digest)
+}
+
 // PulledImage provides a mock function with given fields: ctx, image, containerName, duration
 func (_m *EventRecorder) PulledImage(ctx context.Context, image string, containerName string, duration string) {
 	_m.Called(ctx, image, containerName, duration)
@@ -63,11 +130,41 @@ func (_m *EventRecorder) PullingImage(ctx context.Context, image string, contain
 	_m.Called(ctx, image, containerName)
 }
 
+// PullingImageProgress provides a mock function with given fields: ctx, image, containerName, progress
+func (_m *EventRecorder) PullingImageProgress(ctx context.Context, image string, containerName string, progress string) {
+	_m.Called(ctx, image, containerName, progress)
+}
+
+// ReadinessTimeout provides a mock function with given fields: ctx, containerName, timeout, err
+func (_m *EventRecorder) ReadinessTimeout(ctx context.Context, containerName string, timeout time.Duration, err error) {
+	_m.Called(ctx, containerName, timeout, err)
+}
+
+// RetainedFailedVirtualMachine provides a mock function with given fields: ctx, containerName
+func (_m *EventRecorder) RetainedFailedVirtualMachine(ctx context.Context, containerName string) {
+	_m.Called(ctx, containerName)
+}
+
 // StartedContainer provides a mock function with given fields: ctx, containerName
 func (_m *EventRecorder) StartedContainer(ctx context.Context, containerName string) {
 	_m.Called(ctx, containerName)
 }
 
+// UnsupportedMediaType provides a mock function with given fields: ctx, mediaType, supported
+func (_m *EventRecorder) UnsupportedMediaType(ctx context.Context, mediaType string, supported []string) {
+	_m.Called(ctx, mediaType, supported)
+}
+
+// VirtualMachineCrashed provides a mock function with given fields: ctx, containerName, err
+func (_m *EventRecorder) VirtualMachineCrashed(ctx context.Context, containerName string, err error) {
+	_m.Called(ctx, containerName, err)
+}
+
+// VirtualMachineReady provides a mock function with given fields: ctx, containerName, startup
+func (_m *EventRecorder) VirtualMachineReady(ctx context.Context, containerName string, startup string) {
+	_m.Called(ctx, containerName, startup)
+}
+
 // NewEventRecorder creates a new instance of EventRecorder. It also registers a testing interface on the mock and a cleanup function to assert the mocks expectations.
 // The first argument is typically a *testing.T value.
 func NewEventRecorder(t interface {
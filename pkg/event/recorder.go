@@ -1,11 +1,19 @@
 package event
 
-import "context"
+import (
+	"context"
+	"time"
+)
 
 type EventRecorder interface {
 	PullingImage(ctx context.Context, image, containerName string)
+	PullingImageProgress(ctx context.Context, image, containerName string, progress string)
 	PulledImage(ctx context.Context, image, containerName string, duration string)
 	FailedToValidateOCI(ctx context.Context, content string)
+	OCICacheHit(ctx context.Context, content, digest string)
+	OCICacheMiss(ctx context.Context, content, digest string)
+	OCIRepaired(ctx context.Context, content, digest string)
+	UnsupportedMediaType(ctx context.Context, mediaType string, supported []string)
 	FailedToPullImage(ctx context.Context, image, containerName string, err error)
 	BackOffPullImage(ctx context.Context, image, containerName string, err error)
 
@@ -13,6 +21,20 @@ type EventRecorder interface {
 	StartedContainer(ctx context.Context, containerName string)
 	FailedToCreateContainer(ctx context.Context, containerName string, err error)
 	FailedToStartContainer(ctx context.Context, containerName string, err error)
+	BackOffStartContainer(ctx context.Context, containerName string, attempt int, err error)
 	FailedPostStartHook(ctx context.Context, containerName string, cmd []string, err error)
 	FailedPreStopHook(ctx context.Context, containerName string, cmd []string, err error)
+	FailedToMountSharedDirectories(ctx context.Context, containerName string, paths []string)
+	FailedToRestoreState(ctx context.Context, containerName, snapshot string, err error)
+	FailedToSetHostname(ctx context.Context, containerName, hostname string, err error)
+	FailedToSetSysctls(ctx context.Context, containerName string, sysctls []string, err error)
+	FailedToSetTimezone(ctx context.Context, containerName, timezone string, err error)
+	FailedToSyncClock(ctx context.Context, containerName string, err error)
+	ForceStopped(ctx context.Context, containerName string, err error)
+	ReadinessTimeout(ctx context.Context, containerName string, timeout time.Duration, err error)
+	NamespaceQuotaReached(ctx context.Context, containerName, namespace string, quota int)
+	RetainedFailedVirtualMachine(ctx context.Context, containerName string)
+	VirtualMachineCrashed(ctx context.Context, containerName string, err error)
+	EphemeralStorageExceeded(ctx context.Context, containerName string, usage, limit int64)
+	VirtualMachineReady(ctx context.Context, containerName string, startup string)
 }
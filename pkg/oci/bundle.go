@@ -0,0 +1,80 @@
+package oci
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/agoda-com/macOS-vz-kubelet/internal/disk"
+
+	"github.com/virtual-kubelet/virtual-kubelet/log"
+	"github.com/virtual-kubelet/virtual-kubelet/trace"
+)
+
+// ValidateBundle validates the bundle in the working directory of the store, laid out as the files of an image:
+// the config and the storage it declares, each along with the digest file recording the digest of its content.
+// Every file is verified against its digest, the returned error lists all the files failing the validation.
+func (s *Store) ValidateBundle(ctx context.Context) (err error) {
+	ctx, span := trace.StartSpan(ctx, "OCI.ValidateBundle")
+	ctx = span.WithField(ctx, "workingDir", s.workingDir)
+	defer func() {
+		span.SetStatus(err)
+		span.End()
+	}()
+
+	if s.isClosedSet() {
+		return ErrStoreClosed
+	}
+
+	configPath := filepath.Join(s.workingDir, MediaTypeConfigV1.Title())
+	if err := validateBundleFile(configPath); err != nil {
+		return err
+	}
+	data, err := os.ReadFile(configPath)
+	if err != nil {
+		return fmt.Errorf("failed to read %s: %w", MediaTypeConfigV1.Title(), err)
+	}
+	var cfg Config
+	if err := json.Unmarshal(data, &cfg); err != nil {
+		return fmt.Errorf("failed to decode %s: %w", MediaTypeConfigV1.Title(), err)
+	}
+
+	storage := cfg.Storage
+	if len(storage) == 0 {
+		storage = DefaultStorage
+	}
+	var errs []error
+	for _, mediaType := range storage {
+		if mediaType == MediaTypeConfigV1 || !IsMediaTypeSupported(string(mediaType)) {
+			errs = append(errs, fmt.Errorf("storage %s declared in %s is not supported", mediaType, MediaTypeConfigV1.Title()))
+			continue
+		}
+		if mediaType == MediaTypeDiskImageLayer {
+			log.G(ctx).Warnf("Skipping storage %s, layers are validated when the image is pulled", mediaType)
+			continue
+		}
+		if err := validateBundleFile(filepath.Join(s.workingDir, mediaType.Title())); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// validateBundleFile verifies the file of a bundle against the digest recorded in its digest file.
+func validateBundleFile(path string) error {
+	name := filepath.Base(path)
+	if _, err := os.Stat(path); err != nil {
+		return fmt.Errorf("%s is missing: %w", name, err)
+	}
+	expected, err := disk.ReadDigestFile(path)
+	if err != nil {
+		return fmt.Errorf("%s has no valid digest file: %w", name, err)
+	}
+	if err := disk.ComputeAndVerifyFileDigest(path, expected); err != nil {
+		return fmt.Errorf("%s does not match its digest %s: %w", name, expected, err)
+	}
+	return nil
+}
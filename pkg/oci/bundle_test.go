@@ -0,0 +1,86 @@
+package oci_test
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/agoda-com/macOS-vz-kubelet/internal/disk"
+	"github.com/agoda-com/macOS-vz-kubelet/pkg/event"
+	"github.com/agoda-com/macOS-vz-kubelet/pkg/oci"
+
+	"github.com/opencontainers/go-digest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// writeBundleFile writes a file of a bundle along with its digest file.
+func writeBundleFile(t *testing.T, dir, name string, data []byte) digest.Digest {
+	t.Helper()
+	path := filepath.Join(dir, name)
+	require.NoError(t, os.WriteFile(path, data, 0o600))
+	d := digest.FromBytes(data)
+	require.NoError(t, disk.ComputeAndVerifyFileDigest(path, d))
+	return d
+}
+
+// writeBundle writes a bundle of a macOS image to the directory.
+func writeBundle(t *testing.T, dir string) {
+	t.Helper()
+	cfg := oci.NewMacOSConfig("hardware-model", "machine-id")
+	data, err := json.Marshal(&cfg)
+	require.NoError(t, err)
+	writeBundleFile(t, dir, oci.MediaTypeConfigV1.Title(), data)
+	writeBundleFile(t, dir, oci.MediaTypeAuxImage.Title(), []byte("aux"))
+	writeBundleFile(t, dir, oci.MediaTypeDiskImage.Title(), []byte("disk"))
+}
+
+func validateBundle(t *testing.T, dir string) error {
+	t.Helper()
+	store, err := oci.New(dir, false, event.LogEventRecorder{})
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = store.Close(context.Background()) })
+	return store.ValidateBundle(context.Background())
+}
+
+func TestValidateBundle(t *testing.T) {
+	dir := t.TempDir()
+	writeBundle(t, dir)
+	assert.NoError(t, validateBundle(t, dir))
+}
+
+func TestValidateBundleTampered(t *testing.T) {
+	dir := t.TempDir()
+	writeBundle(t, dir)
+	d := digest.FromBytes([]byte("disk"))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, oci.MediaTypeDiskImage.Title()), []byte("tampered"), 0o600))
+
+	err := validateBundle(t, dir)
+	require.Error(t, err)
+	assert.Equal(t, "disk.img does not match its digest "+d.String()+": digest verification failed", err.Error())
+}
+
+func TestValidateBundleIncomplete(t *testing.T) {
+	t.Run("Missing config", func(t *testing.T) {
+		dir := t.TempDir()
+		writeBundleFile(t, dir, oci.MediaTypeDiskImage.Title(), []byte("disk"))
+
+		err := validateBundle(t, dir)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "config.json is missing")
+	})
+
+	t.Run("Missing storage and digest file", func(t *testing.T) {
+		dir := t.TempDir()
+		writeBundle(t, dir)
+		require.NoError(t, os.Remove(filepath.Join(dir, oci.MediaTypeAuxImage.Title())))
+		require.NoError(t, os.Remove(filepath.Join(dir, oci.MediaTypeDiskImage.Title()+disk.DigestFileSuffix)))
+
+		err := validateBundle(t, dir)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "aux.img is missing")
+		assert.Contains(t, err.Error(), "disk.img has no valid digest file")
+	})
+}
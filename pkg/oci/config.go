@@ -1,6 +1,9 @@
 package oci
 
-import "encoding/json"
+import (
+	"encoding/json"
+	"slices"
+)
 
 // Config represents an OCI bundle.
 type Config struct {
@@ -18,13 +21,22 @@ func NewMacOSConfig(hardwareModelData, machineIdData string) Config {
 		OS:                "darwin",
 		HardwareModelData: hardwareModelData,
 		MachineIdData:     machineIdData,
-		Storage: []MediaType{
-			MediaTypeAuxImage,
-			MediaTypeDiskImage,
-		},
+		Storage:           slices.Clone(DefaultStorage),
 	}
 }
 
+// DefaultStorage is the storage of macOS images, an auxiliary (nvram) image and a disk image.
+var DefaultStorage = []MediaType{
+	MediaTypeAuxImage,
+	MediaTypeDiskImage,
+}
+
+// StorageFile is a storage declared by the Config, resolved to its file within the store.
+type StorageFile struct {
+	MediaType MediaType
+	Path      string
+}
+
 // StorageItem represents the structure for storage when marshaled.
 type storageItem struct {
 	MediaType MediaType `json:"mediatype"`
@@ -0,0 +1,237 @@
+package oci
+
+import (
+	"bufio"
+	"cmp"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/opencontainers/go-digest"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+
+	"github.com/virtual-kubelet/virtual-kubelet/log"
+	"github.com/virtual-kubelet/virtual-kubelet/trace"
+)
+
+const (
+	// AnnotationDiskImageLayerIndex is the annotation key for the position of a disk image layer,
+	// layers are applied on top of the disk image in increasing order starting at 1.
+	AnnotationDiskImageLayerIndex = "com.agoda.macosvz.disk.layer.index"
+
+	// composedDiskImageTitle is the name of the disk image composed from the disk image and its layers.
+	composedDiskImageTitle = "disk.composed.img"
+
+	// composedLayersSuffix is the suffix of the file recording the layers the disk image was composed from.
+	composedLayersSuffix = ".layers"
+)
+
+// DiskImageLayerExtent is a range of the disk image replaced by a disk image layer.
+type DiskImageLayerExtent struct {
+	Offset int64
+	Data   []byte
+}
+
+// WriteDiskImageLayer writes the extents in the disk image layer format, a sequence of extents
+// each made of a big-endian uint64 offset and uint64 length header followed by the data.
+func WriteDiskImageLayer(w io.Writer, extents []DiskImageLayerExtent) error {
+	for _, e := range extents {
+		if e.Offset < 0 {
+			return fmt.Errorf("invalid extent offset: %d", e.Offset)
+		}
+		var header [16]byte
+		binary.BigEndian.PutUint64(header[:8], uint64(e.Offset))
+		binary.BigEndian.PutUint64(header[8:], uint64(len(e.Data)))
+		if _, err := w.Write(header[:]); err != nil {
+			return err
+		}
+		if _, err := w.Write(e.Data); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// applyDiskImageLayer writes the extents of the disk image layer to the disk image.
+func applyDiskImageLayer(ctx context.Context, disk *os.File, layer io.Reader) error {
+	var header [16]byte
+	for {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		if _, err := io.ReadFull(layer, header[:]); err != nil {
+			if errors.Is(err, io.EOF) {
+				return nil
+			}
+			return fmt.Errorf("failed to read extent header: %w", err)
+		}
+		offset, length := binary.BigEndian.Uint64(header[:8]), binary.BigEndian.Uint64(header[8:])
+		if int64(offset) < 0 || int64(length) < 0 {
+			return fmt.Errorf("invalid extent at offset %d with length %d", offset, length)
+		}
+
+		if _, err := io.CopyN(io.NewOffsetWriter(disk, int64(offset)), layer, int64(length)); err != nil {
+			return fmt.Errorf("failed to apply extent at offset %d: %w", offset, err)
+		}
+	}
+}
+
+// diskImageLayerIndex returns the position of the disk image layer.
+func diskImageLayerIndex(desc ocispec.Descriptor) (int, error) {
+	index, err := strconv.Atoi(desc.Annotations[AnnotationDiskImageLayerIndex])
+	if err != nil || index < 1 {
+		return 0, fmt.Errorf("disk image layer %s has an invalid %s annotation", desc.Digest, AnnotationDiskImageLayerIndex)
+	}
+	return index, nil
+}
+
+// storageKey returns the key of the content within the store. Disk image layers share their media type,
+// so they are keyed by their index as well.
+func storageKey(desc ocispec.Descriptor) string {
+	if desc.MediaType == string(MediaTypeDiskImageLayer) {
+		return desc.MediaType + "#" + desc.Annotations[AnnotationDiskImageLayerIndex]
+	}
+	return desc.MediaType
+}
+
+// storeContent records the path and the digest of the uncompressed content.
+func (s *Store) storeContent(desc ocispec.Descriptor, path string, d digest.Digest) {
+	s.contentDigests.Store(storageKey(desc), d)
+	s.mediaTypeToPath.Store(storageKey(desc), path)
+}
+
+// diskImageLayer is a disk image layer within the store.
+type diskImageLayer struct {
+	index  int
+	path   string
+	digest digest.Digest
+}
+
+// diskImageLayers returns the disk image layers within the store, in the order they are applied.
+func (s *Store) diskImageLayers() []diskImageLayer {
+	prefix := string(MediaTypeDiskImageLayer) + "#"
+	var layers []diskImageLayer
+	s.mediaTypeToPath.Range(func(key, value any) bool {
+		k, _ := key.(string)
+		index, ok := strings.CutPrefix(k, prefix)
+		if !ok {
+			return true
+		}
+		l := diskImageLayer{}
+		l.index, _ = strconv.Atoi(index)
+		l.path, _ = value.(string)
+		if d, ok := s.contentDigests.Load(k); ok {
+			l.digest, _ = d.(digest.Digest)
+		}
+		layers = append(layers, l)
+		return true
+	})
+	slices.SortFunc(layers, func(a, b diskImageLayer) int { return cmp.Compare(a.index, b.index) })
+	return layers
+}
+
+// composedDiskImage returns the path of the disk image with its layers applied, or the path of the disk image
+// if it has none. The composed disk image is reused for as long as the disk image and its layers are the same.
+func (s *Store) composedDiskImage(ctx context.Context) (path string, err error) {
+	ctx, span := trace.StartSpan(ctx, "OCI.composedDiskImage")
+	defer func() {
+		span.SetStatus(err)
+		span.End()
+	}()
+
+	s.composeMu.Lock()
+	defer s.composeMu.Unlock()
+
+	val, ok := s.mediaTypeToPath.Load(string(MediaTypeDiskImage))
+	if !ok {
+		return "", fmt.Errorf("media type %s not found", MediaTypeDiskImage)
+	}
+	basePath, _ := val.(string)
+	layers := s.diskImageLayers()
+	if len(layers) == 0 {
+		return basePath, nil
+	}
+
+	// the layers the disk image is composed from, identified by their digests
+	chain := []string{}
+	if d, ok := s.contentDigests.Load(string(MediaTypeDiskImage)); ok {
+		chain = append(chain, fmt.Sprint(d))
+	}
+	for _, l := range layers {
+		chain = append(chain, fmt.Sprintf("%d %s", l.index, l.digest))
+	}
+	composed := strings.Join(chain, "\n")
+
+	path = filepath.Join(s.workingDir, composedDiskImageTitle)
+	layersPath := path + composedLayersSuffix
+	ctx = span.WithFields(ctx, log.Fields{
+		"path":   path,
+		"layers": len(layers),
+	})
+	if data, err := os.ReadFile(layersPath); err == nil && !s.ignoreExisting && string(data) == composed {
+		if _, err := os.Stat(path); err == nil {
+			return path, nil
+		}
+	}
+
+	// invalidate the previous composition before modifying the disk image
+	if err := os.Remove(layersPath); err != nil && !os.IsNotExist(err) {
+		return "", err
+	}
+	log.G(ctx).Infof("Composing disk image from %d layers", len(layers))
+	if err := composeDiskImage(ctx, s.cloneFile, basePath, layers, path); err != nil {
+		return "", fmt.Errorf("failed to compose disk image: %w", err)
+	}
+	if err := os.WriteFile(layersPath, []byte(composed), 0o644); err != nil {
+		return "", err
+	}
+	return path, nil
+}
+
+// composeDiskImage clones the disk image to the output path and applies the layers on top of it, so that the
+// composed disk image shares its blocks with the disk image except for the extents of the layers.
+func composeDiskImage(ctx context.Context, cloneFile func(src, dst string, flags int) error, basePath string, layers []diskImageLayer, outputPath string) (err error) {
+	// the clone cannot replace an existing file
+	if err := os.Remove(outputPath); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	if err := cloneFile(basePath, outputPath, 0); err != nil {
+		return fmt.Errorf("failed to clone disk image: %w", err)
+	}
+
+	out, err := os.OpenFile(outputPath, os.O_WRONLY, 0)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		err = errors.Join(err, out.Close())
+	}()
+
+	for _, l := range layers {
+		if err := applyDiskImageLayerFile(ctx, out, l.path); err != nil {
+			return fmt.Errorf("failed to apply disk image layer %d: %w", l.index, err)
+		}
+	}
+	return out.Sync()
+}
+
+// applyDiskImageLayerFile applies the disk image layer at the path to the disk image.
+func applyDiskImageLayerFile(ctx context.Context, disk *os.File, path string) (err error) {
+	layer, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		err = errors.Join(err, layer.Close())
+	}()
+
+	return applyDiskImageLayer(ctx, disk, bufio.NewReader(layer))
+}
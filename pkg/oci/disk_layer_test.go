@@ -0,0 +1,139 @@
+package oci_test
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+
+	"github.com/agoda-com/macOS-vz-kubelet/pkg/event/mocks"
+	"github.com/agoda-com/macOS-vz-kubelet/pkg/oci"
+	"github.com/opencontainers/go-digest"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func pushContent(t *testing.T, store *oci.Store, mediaType oci.MediaType, annotations map[string]string, content []byte) ocispec.Descriptor {
+	t.Helper()
+	desc := ocispec.Descriptor{
+		MediaType:   string(mediaType),
+		Digest:      digest.FromBytes(content),
+		Size:        int64(len(content)),
+		Annotations: annotations,
+	}
+	require.NoError(t, store.Push(context.Background(), desc, bytes.NewReader(content)))
+	return desc
+}
+
+func diskImageLayer(t *testing.T, extents ...oci.DiskImageLayerExtent) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	require.NoError(t, oci.WriteDiskImageLayer(&buf, extents))
+	return buf.Bytes()
+}
+
+// copyingClone clones files by copying them, recording the files cloned.
+type copyingClone struct {
+	sources []string
+}
+
+func (c *copyingClone) clonefile(src, dst string, _ int) error {
+	c.sources = append(c.sources, src)
+	content, err := os.ReadFile(src)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(dst, content, 0o644)
+}
+
+func TestComposedDiskImage(t *testing.T) {
+	ctx := context.Background()
+	tempDir := t.TempDir()
+	store, err := oci.New(tempDir, false, mocks.NewEventRecorder(t))
+	require.NoError(t, err)
+	defer handleCloseError(t, store.Close)
+	clone := &copyingClone{}
+	store.SetCloneFile(clone.clonefile)
+
+	base := []byte("0123456789abcdefghij")
+	pushContent(t, store, oci.MediaTypeDiskImage, map[string]string{ocispec.AnnotationTitle: "disk.img"}, base)
+
+	// layers are pushed out of order, the second one overrides part of the first one and extends the disk
+	layers := [][]byte{
+		diskImageLayer(t,
+			oci.DiskImageLayerExtent{Offset: 2, Data: []byte("XXXX")},
+			oci.DiskImageLayerExtent{Offset: 10, Data: []byte("YY")},
+		),
+		diskImageLayer(t,
+			oci.DiskImageLayerExtent{Offset: 4, Data: []byte("ZZ")},
+			oci.DiskImageLayerExtent{Offset: 20, Data: []byte("tail")},
+		),
+	}
+	for _, i := range []int{2, 1} {
+		pushContent(t, store, oci.MediaTypeDiskImageLayer, map[string]string{
+			ocispec.AnnotationTitle:           "disk.layer" + strconv.Itoa(i) + ".img",
+			oci.AnnotationDiskImageLayerIndex: strconv.Itoa(i),
+		}, layers[i-1])
+	}
+
+	path, err := store.GetFilePathForMediaType(ctx, oci.MediaTypeDiskImage)
+	require.NoError(t, err)
+	composed, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, "01XXZZ6789YYcdefghijtail", string(composed))
+	// the layers are applied to a clone of the base disk image
+	assert.Equal(t, []string{filepath.Join(tempDir, "disk.img")}, clone.sources)
+
+	// the base disk image is left untouched
+	content, err := os.ReadFile(filepath.Join(tempDir, "disk.img"))
+	require.NoError(t, err)
+	assert.Equal(t, base, content)
+
+	// the composed disk image is reused
+	info, err := os.Stat(path)
+	require.NoError(t, err)
+	reusedPath, err := store.GetFilePathForMediaType(ctx, oci.MediaTypeDiskImage)
+	require.NoError(t, err)
+	reused, err := os.Stat(reusedPath)
+	require.NoError(t, err)
+	assert.Equal(t, path, reusedPath)
+	assert.Equal(t, info.ModTime(), reused.ModTime())
+	assert.Len(t, clone.sources, 1)
+}
+
+func TestComposedDiskImageWithoutLayers(t *testing.T) {
+	store, err := oci.New(t.TempDir(), false, mocks.NewEventRecorder(t))
+	require.NoError(t, err)
+	defer handleCloseError(t, store.Close)
+
+	pushContent(t, store, oci.MediaTypeDiskImage, map[string]string{ocispec.AnnotationTitle: "disk.img"}, []byte("disk"))
+
+	path, err := store.GetFilePathForMediaType(context.Background(), oci.MediaTypeDiskImage)
+	require.NoError(t, err)
+	content, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, "disk", string(content))
+}
+
+func TestDiskImageLayerInvalidIndex(t *testing.T) {
+	store, err := oci.New(t.TempDir(), false, mocks.NewEventRecorder(t))
+	require.NoError(t, err)
+	defer handleCloseError(t, store.Close)
+
+	for _, index := range []string{"", "0", "first"} {
+		content := diskImageLayer(t, oci.DiskImageLayerExtent{Offset: 0, Data: []byte("X")})
+		desc := ocispec.Descriptor{
+			MediaType: string(oci.MediaTypeDiskImageLayer),
+			Digest:    digest.FromBytes(content),
+			Size:      int64(len(content)),
+			Annotations: map[string]string{
+				ocispec.AnnotationTitle:           "disk.layer.img",
+				oci.AnnotationDiskImageLayerIndex: index,
+			},
+		}
+		assert.Error(t, store.Push(context.Background(), desc, bytes.NewReader(content)), "index %q", index)
+	}
+}
@@ -0,0 +1,6 @@
+package oci
+
+// SetCloneFile replaces the clonefile system call the disk image is cloned with before its layers are applied.
+func (s *Store) SetCloneFile(cloneFile func(src, dst string, flags int) error) {
+	s.cloneFile = cloneFile
+}
@@ -0,0 +1,208 @@
+package oci
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"syscall"
+
+	"github.com/agoda-com/macOS-vz-kubelet/internal/disk"
+
+	"github.com/opencontainers/go-digest"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+	"github.com/virtual-kubelet/virtual-kubelet/log"
+)
+
+// shardPrefixLength is the number of characters of the encoded digest naming the shard of the content.
+const shardPrefixLength = 2
+
+// shardedAlgorithms are the digest algorithms the sharded layout holds content of.
+var shardedAlgorithms = []digest.Algorithm{digest.SHA256, digest.SHA384, digest.SHA512}
+
+// shardedPathLocks serializes the writes and the removals of the same content in a sharded layout.
+var shardedPathLocks sync.Map // map[string]*sync.Mutex (sharded path -> lock)
+
+// ShardedPath returns the path of the content with the digest in the sharded layout under root,
+// i.e. <root>/<algorithm>/<first two characters of the encoded digest>/<encoded digest>.
+func ShardedPath(root string, d digest.Digest) string {
+	encoded := d.Encoded()
+	shard := encoded
+	if len(shard) > shardPrefixLength {
+		shard = shard[:shardPrefixLength]
+	}
+	return filepath.Join(root, d.Algorithm().String(), shard, encoded)
+}
+
+// SetShardedLayout keeps the files of the content at their sharded path under root, shared by the stores of
+// all the images, instead of in the working directory. The working directory holds hard links to the files
+// named by their title, so that the content of an image is still found by its reference and removing the
+// working directory releases the content. Files laid out by title only, as stored without the sharded layout,
+// are linked into the sharded layout when they are found valid.
+func (s *Store) SetShardedLayout(root string) {
+	s.shardedRoot = root
+}
+
+// contentDigest returns the digest of the uncompressed content of the descriptor.
+func contentDigest(desc ocispec.Descriptor) digest.Digest {
+	if uncompressedDigest := desc.Annotations[AnnotationUncompressedDigest]; uncompressedDigest != "" {
+		return digest.Digest(uncompressedDigest)
+	}
+	return desc.Digest
+}
+
+// contentPath returns the path of the file of the content named by its title.
+func (s *Store) contentPath(desc ocispec.Descriptor, name string) string {
+	if s.shardedRoot == "" {
+		return filepath.Join(s.workingDir, name)
+	}
+	return ShardedPath(s.shardedRoot, contentDigest(desc))
+}
+
+// lockContentPath locks the path the content is written to, if it may be shared with other stores.
+func (s *Store) lockContentPath(path string) (unlock func()) {
+	if s.shardedRoot == "" {
+		return func() {}
+	}
+	return lockShardedPath(path)
+}
+
+// lockShardedPath locks the path of the content in a sharded layout.
+func lockShardedPath(path string) (unlock func()) {
+	v, _ := shardedPathLocks.LoadOrStore(path, &sync.Mutex{})
+	mu, _ := v.(*sync.Mutex)
+	mu.Lock()
+	return mu.Unlock
+}
+
+// prepareContentPath ensures the directory of the path exists. In the sharded layout the existing file is
+// removed rather than overwritten, since the working directories of other images may link to it.
+func (s *Store) prepareContentPath(path string) error {
+	if err := os.MkdirAll(filepath.Dir(path), os.ModePerm); err != nil {
+		return fmt.Errorf("failed to ensure the directory of %s exists: %w", path, err)
+	}
+	if s.shardedRoot == "" {
+		return nil
+	}
+	return removeWithDigestFile(path)
+}
+
+// migrateContent links the file of the content laid out by title in the working directory into the
+// sharded layout, if the sharded layout does not hold it yet.
+func (s *Store) migrateContent(ctx context.Context, name, path string) error {
+	if s.shardedRoot == "" || name == "" {
+		return nil
+	}
+	if _, err := os.Stat(path); err == nil || !os.IsNotExist(err) {
+		return err
+	}
+	legacyPath := filepath.Join(s.workingDir, name)
+	if _, err := os.Stat(legacyPath); err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), os.ModePerm); err != nil {
+		return err
+	}
+	log.G(ctx).Debugf("Linking %s into the sharded layout", name)
+	return linkWithDigestFile(legacyPath, path)
+}
+
+// linkContent links the file of the content in the sharded layout into the working directory, named by its title.
+func (s *Store) linkContent(name, path string) error {
+	if s.shardedRoot == "" || name == "" {
+		return nil
+	}
+	titlePath := filepath.Join(s.workingDir, name)
+	if dst, err := os.Stat(titlePath); err == nil {
+		if src, err := os.Stat(path); err == nil && os.SameFile(src, dst) {
+			return nil
+		}
+	}
+	if err := os.MkdirAll(s.workingDir, os.ModePerm); err != nil {
+		return fmt.Errorf("failed to ensure the working directory exists: %w", err)
+	}
+	if err := removeWithDigestFile(titlePath); err != nil {
+		return err
+	}
+	return linkWithDigestFile(path, titlePath)
+}
+
+// linkWithDigestFile hard links dst to the file at src, along with its digest file if it has one.
+func linkWithDigestFile(src, dst string) error {
+	if err := os.Link(src, dst); err != nil {
+		return err
+	}
+	err := os.Link(src+disk.DigestFileSuffix, dst+disk.DigestFileSuffix)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+// removeWithDigestFile removes the file at path along with its digest file, if they exist.
+func removeWithDigestFile(path string) error {
+	var errs []error
+	for _, p := range []string{path, path + disk.DigestFileSuffix} {
+		if err := os.Remove(p); err != nil && !os.IsNotExist(err) {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// PruneShardedLayout removes the files of the sharded layout under root that no working directory
+// links to anymore, i.e. the content of images that were all removed. It returns the number of removed files.
+// Only the directories of the digest algorithms are walked, root may hold the working directories as well.
+func PruneShardedLayout(ctx context.Context, root string) (removed int, err error) {
+	for _, algorithm := range shardedAlgorithms {
+		err = filepath.WalkDir(filepath.Join(root, algorithm.String()), func(path string, d fs.DirEntry, err error) error {
+			if err != nil {
+				if os.IsNotExist(err) {
+					return nil
+				}
+				return err
+			}
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+			if d.IsDir() || strings.HasSuffix(path, disk.DigestFileSuffix) {
+				return nil
+			}
+			pruned, err := pruneShardedFile(path)
+			if pruned {
+				removed++
+			}
+			return err
+		})
+		if err != nil {
+			return removed, err
+		}
+	}
+	return removed, nil
+}
+
+// pruneShardedFile removes the file of the content in a sharded layout if it is not linked to anymore.
+func pruneShardedFile(path string) (bool, error) {
+	unlock := lockShardedPath(path)
+	defer unlock()
+
+	info, err := os.Stat(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return false, nil
+		}
+		return false, err
+	}
+	stat, ok := info.Sys().(*syscall.Stat_t)
+	if !ok || uint64(stat.Nlink) > 1 {
+		return false, nil
+	}
+	return true, removeWithDigestFile(path)
+}
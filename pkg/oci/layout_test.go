@@ -0,0 +1,124 @@
+package oci_test
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/agoda-com/macOS-vz-kubelet/pkg/event/mocks"
+	"github.com/agoda-com/macOS-vz-kubelet/pkg/oci"
+	"github.com/opencontainers/go-digest"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestShardedPath(t *testing.T) {
+	d := digest.FromString("disk")
+	assert.Equal(t, filepath.Join("/cache/blobs", "sha256", d.Encoded()[:2], d.Encoded()), oci.ShardedPath("/cache/blobs", d))
+}
+
+// newShardedStore returns a store with the working directory laid out within the sharded layout under root.
+func newShardedStore(t *testing.T, root, workingDir string) (*oci.Store, *mocks.EventRecorder) {
+	t.Helper()
+	recorder := mocks.NewEventRecorder(t)
+	store, err := oci.New(filepath.Join(root, workingDir), false, recorder)
+	require.NoError(t, err)
+	store.SetShardedLayout(root)
+	t.Cleanup(func() { handleCloseError(t, store.Close) })
+	return store, recorder
+}
+
+func TestShardedLayoutPushAndFetch(t *testing.T) {
+	ctx := context.Background()
+	root := t.TempDir()
+	content := []byte("test content")
+	desc := ocispec.Descriptor{
+		MediaType:   string(oci.MediaTypeDiskImage),
+		Digest:      digest.FromBytes(content),
+		Size:        int64(len(content)),
+		Annotations: map[string]string{ocispec.AnnotationTitle: "disk.img"},
+	}
+	shardedPath := oci.ShardedPath(root, desc.Digest)
+
+	first, _ := newShardedStore(t, root, "first")
+	require.NoError(t, first.Push(ctx, desc, bytes.NewReader(content)))
+
+	// the content is stored by digest and linked into the working directory by title
+	stored, err := os.ReadFile(shardedPath)
+	require.NoError(t, err)
+	assert.Equal(t, content, stored)
+	assertSameFile(t, shardedPath, filepath.Join(root, "first", "disk.img"))
+
+	reader, err := first.Fetch(ctx, desc)
+	require.NoError(t, err)
+	fetched, err := io.ReadAll(reader)
+	require.NoError(t, err)
+	require.NoError(t, reader.Close())
+	assert.Equal(t, content, fetched)
+
+	path, err := first.GetFilePathForMediaType(ctx, oci.MediaTypeDiskImage)
+	require.NoError(t, err)
+	assert.Equal(t, shardedPath, path)
+
+	// another image with the same content reuses it
+	second, recorder := newShardedStore(t, root, "second")
+	recorder.On("OCICacheHit", mock.Anything, "disk.img", desc.Digest.String()).Return().Once()
+	exists, err := second.Exists(ctx, desc)
+	require.NoError(t, err)
+	assert.True(t, exists)
+	assertSameFile(t, shardedPath, filepath.Join(root, "second", "disk.img"))
+
+	// the content is pruned once no image links to it
+	require.NoError(t, os.RemoveAll(filepath.Join(root, "first")))
+	removed, err := oci.PruneShardedLayout(ctx, root)
+	require.NoError(t, err)
+	assert.Zero(t, removed)
+	assert.FileExists(t, shardedPath)
+
+	require.NoError(t, os.RemoveAll(filepath.Join(root, "second")))
+	removed, err = oci.PruneShardedLayout(ctx, root)
+	require.NoError(t, err)
+	assert.Equal(t, 1, removed)
+	assert.NoFileExists(t, shardedPath)
+}
+
+func TestShardedLayoutMigratesReferenceLayout(t *testing.T) {
+	ctx := context.Background()
+	root := t.TempDir()
+	content := []byte("test content")
+	desc := ocispec.Descriptor{
+		MediaType:   string(oci.MediaTypeDiskImage),
+		Digest:      digest.FromBytes(content),
+		Size:        int64(len(content)),
+		Annotations: map[string]string{ocispec.AnnotationTitle: "disk.img"},
+	}
+
+	// the content pulled with the reference layout
+	legacy, err := oci.New(filepath.Join(root, "image"), false, mocks.NewEventRecorder(t))
+	require.NoError(t, err)
+	require.NoError(t, legacy.Push(ctx, desc, bytes.NewReader(content)))
+	handleCloseError(t, legacy.Close)
+	assert.NoFileExists(t, oci.ShardedPath(root, desc.Digest))
+
+	store, recorder := newShardedStore(t, root, "image")
+	recorder.On("OCICacheHit", mock.Anything, "disk.img", desc.Digest.String()).Return().Once()
+	exists, err := store.Exists(ctx, desc)
+	require.NoError(t, err)
+	assert.True(t, exists)
+	assertSameFile(t, oci.ShardedPath(root, desc.Digest), filepath.Join(root, "image", "disk.img"))
+}
+
+// assertSameFile asserts that both paths link to the same file.
+func assertSameFile(t *testing.T, expected, actual string) {
+	t.Helper()
+	expectedInfo, err := os.Stat(expected)
+	require.NoError(t, err)
+	actualInfo, err := os.Stat(actual)
+	require.NoError(t, err)
+	assert.True(t, os.SameFile(expectedInfo, actualInfo), "%s is not linked to %s", actual, expected)
+}
@@ -1,9 +1,14 @@
 package oci
 
 import (
+	"errors"
+
 	"k8s.io/apimachinery/pkg/util/sets"
 )
 
+// ErrUnsupportedMediaType is returned when content of a media type the store does not support is added or pushed.
+var ErrUnsupportedMediaType = errors.New("unsupported media type")
+
 // MediaType represents a media type.
 type MediaType string
 
@@ -11,6 +16,11 @@ const (
 	// MediaTypeDiskImage specifies the media type for a disk image.
 	MediaTypeDiskImage MediaType = "application/vnd.agoda.macosvz.disk.image.v1"
 
+	// MediaTypeDiskImageLayer specifies the media type for a layer applied on top of the disk image.
+	// Layers are applied in the order of their AnnotationDiskImageLayerIndex annotation,
+	// see WriteDiskImageLayer for their format.
+	MediaTypeDiskImageLayer MediaType = "application/vnd.agoda.macosvz.disk.image.layer.v1"
+
 	// MediaTypeAuxImage specifies the media type for an auxiliary (nvram) image.
 	MediaTypeAuxImage MediaType = "application/vnd.agoda.macosvz.aux.image.v1"
 
@@ -35,6 +45,7 @@ func (mt MediaType) Title() string {
 var supportedMediaTypes = sets.NewString(
 	string(MediaTypeConfigV1),
 	string(MediaTypeDiskImage),
+	string(MediaTypeDiskImageLayer),
 	string(MediaTypeAuxImage),
 )
 
@@ -42,3 +53,8 @@ var supportedMediaTypes = sets.NewString(
 func IsMediaTypeSupported(mediaType string) bool {
 	return supportedMediaTypes.Has(mediaType)
 }
+
+// SupportedMediaTypes returns the supported media types, sorted.
+func SupportedMediaTypes() []string {
+	return supportedMediaTypes.List()
+}
@@ -9,10 +9,12 @@ import (
 	"io"
 	"os"
 	"path/filepath"
+	"strings"
 	"sync"
 	"sync/atomic"
 
 	"github.com/agoda-com/macOS-vz-kubelet/internal/disk"
+	"github.com/agoda-com/macOS-vz-kubelet/internal/utils"
 	"github.com/agoda-com/macOS-vz-kubelet/pkg/event"
 
 	"github.com/opencontainers/go-digest"
@@ -21,6 +23,7 @@ import (
 	"github.com/virtual-kubelet/virtual-kubelet/log"
 	"github.com/virtual-kubelet/virtual-kubelet/trace"
 
+	"golang.org/x/sync/errgroup"
 	"oras.land/oras-go/v2/content/memory"
 	"oras.land/oras-go/v2/errdef"
 )
@@ -36,6 +39,9 @@ const (
 	AnnotationUncompressedDigest = "com.agoda.macosvz.content.uncompressed-digest"
 )
 
+// closeConcurrency is the maximum number of temporary files removed concurrently on Close.
+const closeConcurrency = 8
+
 var (
 	// ErrStoreClosed is returned when the store is already closed.
 	ErrStoreClosed = errors.New("store already closed")
@@ -46,16 +52,23 @@ var (
 
 // Store holds information about bundled content and provides functionalities to manage OCI images.
 type Store struct {
-	workingDir     string
-	ignoreExisting bool
-	eventRecorder  event.EventRecorder
+	workingDir          string
+	ignoreExisting      bool
+	eventRecorder       event.EventRecorder
+	streamDecompression bool
+	shardedRoot         string // root of the sharded layout, empty if the content is laid out by title
 
 	closed          int32    // if the store is closed - 0: false, 1: true.
 	digestToPath    sync.Map // map[digest.Digest]string
-	mediaTypeToPath sync.Map // map[string]string
+	mediaTypeToPath sync.Map // map[string]string (storage key -> path)
+	contentDigests  sync.Map // map[string]digest.Digest (storage key -> digest of the uncompressed content)
+	composeMu       sync.Mutex
 	nameToStatus    sync.Map // map[string]*nameStatus
+	corruptNames    sync.Map // map[string]bool (name of content removed as corrupt -> true)
 	tmpFiles        sync.Map // map[string]bool
 
+	cloneFile func(src, dst string, flags int) error // clones the disk image before its layers are applied, replaceable in tests
+
 	memoryStore *memory.Store
 }
 
@@ -78,11 +91,22 @@ func New(workingDir string, ignoreExisting bool, eventRecorder event.EventRecord
 		ignoreExisting: ignoreExisting,
 		eventRecorder:  eventRecorder,
 
+		cloneFile: utils.NewFileCloner().SysClonefileFunc,
+
 		memoryStore: memory.New(),
 	}, nil
 }
 
+// SetStreamDecompression decompresses the compressed content while it is pushed when enabled,
+// instead of saving the compressed content to a temporary file first. This halves the disk space
+// needed by the pull, but the compressed content is not kept for Fetch.
+func (s *Store) SetStreamDecompression(enabled bool) {
+	s.streamDecompression = enabled
+}
+
 // Close closes the Store, removing any temporary files and marking the store as closed.
+// The files are removed concurrently. Once the context is done the remaining removals are skipped
+// and the context error is returned along with the removal errors.
 func (s *Store) Close(ctx context.Context) (err error) {
 	ctx, span := trace.StartSpan(ctx, "OCI.Close")
 	ctx = span.WithField(ctx, "workingDir", s.workingDir)
@@ -96,21 +120,41 @@ func (s *Store) Close(ctx context.Context) (err error) {
 	}
 	s.setClosed()
 
-	var errs []error
 	var files []string
 	s.tmpFiles.Range(func(name, _ any) bool {
-		path, ok := name.(string)
-		if !ok {
-			return true
-		}
-		files = append(files, path)
-		if err := os.Remove(path); err != nil {
-			errs = append(errs, err)
+		if path, ok := name.(string); ok {
+			files = append(files, path)
 		}
 		return true
 	})
 	span.WithField(ctx, "files", files)
 
+	var (
+		mu      sync.Mutex
+		errs    []error
+		aborted atomic.Bool
+	)
+	g := errgroup.Group{}
+	g.SetLimit(closeConcurrency)
+	for _, path := range files {
+		g.Go(func() error {
+			if ctx.Err() != nil {
+				aborted.Store(true)
+				return nil
+			}
+			if err := os.Remove(path); err != nil {
+				mu.Lock()
+				errs = append(errs, err)
+				mu.Unlock()
+			}
+			return nil
+		})
+	}
+	_ = g.Wait()
+
+	if aborted.Load() {
+		errs = append(errs, fmt.Errorf("aborted removing temporary files: %w", ctx.Err()))
+	}
 	return errors.Join(errs...)
 }
 
@@ -186,17 +230,33 @@ func (s *Store) Push(ctx context.Context, expected ocispec.Descriptor, content i
 		return fmt.Errorf("%s: %w", name, ErrDuplicateName)
 	}
 
-	if !IsMediaTypeSupported(expected.MediaType) {
-		return fmt.Errorf("unsupported media type: %s", expected.MediaType)
+	if err := s.checkMediaType(ctx, expected.MediaType); err != nil {
+		return fmt.Errorf("%s: %w", name, err)
+	}
+	if expected.MediaType == string(MediaTypeDiskImageLayer) {
+		if _, err := diskImageLayerIndex(expected); err != nil {
+			return err
+		}
 	}
 
 	logger.Debugf("Pulling OCI content: %s", name)
-	outputFilePath := filepath.Join(s.workingDir, name)
+	outputFilePath := s.contentPath(expected, name)
+	unlock := s.lockContentPath(outputFilePath)
+	defer unlock()
+	if err = s.prepareContentPath(outputFilePath); err != nil {
+		return err
+	}
 	if err = s.processContentByType(ctx, expected, content, outputFilePath); err != nil {
 		logger.WithError(err).Debugf("Failed to process content: %s", name)
 		return err
 	}
+	if err = s.linkContent(name, outputFilePath); err != nil {
+		return fmt.Errorf("failed to link %s into the working directory: %w", name, err)
+	}
 	logger.Debugf("Successfully pulled OCI content: %s", name)
+	if _, corrupt := s.corruptNames.LoadAndDelete(name); corrupt {
+		s.eventRecorder.OCIRepaired(ctx, name, contentDigest(expected).String())
+	}
 
 	// update the name status as existed
 	status.exists = true
@@ -222,23 +282,23 @@ func (s *Store) Exists(ctx context.Context, target ocispec.Descriptor) (ok bool,
 	}
 
 	// check if the content exists in the store
-	_, exists := s.mediaTypeToPath.Load(target.MediaType)
+	_, exists := s.mediaTypeToPath.Load(storageKey(target))
 	if exists {
 		return true, nil
 	}
 
 	// check if the content exists on the disk and validate if it does
 	name := target.Annotations[ocispec.AnnotationTitle]
-	filePath := filepath.Join(s.workingDir, name)
+	filePath := s.contentPath(target, name)
+	if err := s.migrateContent(ctx, name, filePath); err != nil {
+		log.G(ctx).WithError(err).Warnf("Failed to link %s into the sharded layout", name)
+	}
+
+	d := contentDigest(target)
+	isCompressed := target.Annotations[AnnotationUncompressedDigest] != ""
 
 	// if the content exists on the disk and is not ignored, validate it
 	if _, err := os.Stat(filePath); err == nil && !s.ignoreExisting && name != "" {
-		var isCompressed bool
-		d := target.Digest
-		if uncompressedDigest := target.Annotations[AnnotationUncompressedDigest]; uncompressedDigest != "" {
-			d = digest.Digest(uncompressedDigest)
-			isCompressed = true
-		}
 
 		ctx = span.WithFields(ctx, log.Fields{
 			"name":         name,
@@ -250,10 +310,21 @@ func (s *Store) Exists(ctx context.Context, target ocispec.Descriptor) (ok bool,
 		// Validate local file with output path with digest
 		err = disk.ValidateFileWithDigest(ctx, filePath, d)
 		if err == nil {
-			s.mediaTypeToPath.Store(target.MediaType, filePath)
+			if err := s.linkContent(name, filePath); err != nil {
+				return false, fmt.Errorf("failed to link %s into the working directory: %w", name, err)
+			}
+			s.storeContent(target, filePath, d)
+			s.eventRecorder.OCICacheHit(ctx, name, d.String())
 			return true, nil
 		}
 		s.eventRecorder.FailedToValidateOCI(ctx, name)
+		if err := s.removeCorruptContent(name, filePath); err != nil {
+			return false, fmt.Errorf("failed to remove corrupt %s: %w", name, err)
+		}
+	}
+	if name != "" {
+		// the content is a file of the image that has to be pulled
+		s.eventRecorder.OCICacheMiss(ctx, name, d.String())
 	}
 
 	// if the content does not exist in the store,
@@ -330,8 +401,11 @@ func (s *Store) Add(ctx context.Context, mediaType string, path string) (ocispec
 		return ocispec.Descriptor{}, ErrStoreClosed
 	}
 
-	if !IsMediaTypeSupported(mediaType) {
-		return ocispec.Descriptor{}, fmt.Errorf("unsupported media type: %s", mediaType)
+	if err := s.checkMediaType(ctx, mediaType); err != nil {
+		return ocispec.Descriptor{}, err
+	}
+	if mediaType == string(MediaTypeDiskImageLayer) {
+		return ocispec.Descriptor{}, fmt.Errorf("adding %s is not supported", mediaType)
 	}
 
 	// check the status of the name
@@ -360,7 +434,7 @@ func (s *Store) Add(ctx context.Context, mediaType string, path string) (ocispec
 		return ocispec.Descriptor{}, fmt.Errorf("failed to create descriptor for %s: %w", name, err)
 	}
 
-	s.mediaTypeToPath.Store(mediaType, path)
+	s.storeContent(desc, path, digest.Digest(desc.Annotations[AnnotationUncompressedDigest]))
 	// update the name status as existed
 	status.exists = true
 
@@ -414,8 +488,9 @@ func (s *Store) GetManifestConfigDescriptor(ctx context.Context) (ocispec.Descri
 }
 
 // GetFilePathForMediaType returns the file path for the given media type.
+// The path of the disk image is the one of the disk image composed with its layers, if it has any.
 func (s *Store) GetFilePathForMediaType(ctx context.Context, mediaType MediaType) (path string, err error) {
-	_, span := trace.StartSpan(ctx, "OCI.GetFilePathForMediaType")
+	ctx, span := trace.StartSpan(ctx, "OCI.GetFilePathForMediaType")
 	defer func() {
 		span.SetStatus(err)
 		span.End()
@@ -425,6 +500,13 @@ func (s *Store) GetFilePathForMediaType(ctx context.Context, mediaType MediaType
 		return path, ErrStoreClosed
 	}
 
+	switch mediaType {
+	case MediaTypeDiskImage:
+		return s.composedDiskImage(ctx)
+	case MediaTypeDiskImageLayer:
+		return path, fmt.Errorf("media type %s has no single file path", mediaType)
+	}
+
 	val, ok := s.mediaTypeToPath.Load(string(mediaType))
 	if !ok {
 		return path, fmt.Errorf("media type %s not found", mediaType)
@@ -471,6 +553,53 @@ func (s *Store) GetConfig(ctx context.Context) (cfg Config, err error) {
 	return *config, nil
 }
 
+// StorageFiles resolves the storage declared by the configuration to the files in the store,
+// following the order of the configuration's storage list. Storage of unsupported media types is skipped,
+// while a supported storage missing from the store results in an error.
+// Configurations without a storage list are assumed to declare the default macOS storage.
+func (s *Store) StorageFiles(ctx context.Context, cfg Config) (files []StorageFile, err error) {
+	ctx, span := trace.StartSpan(ctx, "OCI.StorageFiles")
+	defer func() {
+		span.SetStatus(err)
+		span.End()
+	}()
+
+	storage := cfg.Storage
+	if len(storage) == 0 {
+		storage = DefaultStorage
+	}
+
+	files = make([]StorageFile, 0, len(storage))
+	for _, mediaType := range storage {
+		if mediaType == MediaTypeConfigV1 || !IsMediaTypeSupported(string(mediaType)) {
+			log.G(ctx).Warnf("Skipping storage of unsupported media type %s", mediaType)
+			continue
+		}
+		if mediaType == MediaTypeDiskImageLayer {
+			// layers are composed into the disk image
+			continue
+		}
+
+		path, err := s.GetFilePathForMediaType(ctx, mediaType)
+		if err != nil {
+			return nil, fmt.Errorf("storage %s declared in config is missing: %w", mediaType, err)
+		}
+		files = append(files, StorageFile{MediaType: mediaType, Path: path})
+	}
+	return files, nil
+}
+
+// checkMediaType returns an error listing the supported media types and records an event
+// if the media type is not supported.
+func (s *Store) checkMediaType(ctx context.Context, mediaType string) error {
+	if IsMediaTypeSupported(mediaType) {
+		return nil
+	}
+	supported := SupportedMediaTypes()
+	s.eventRecorder.UnsupportedMediaType(ctx, mediaType, supported)
+	return fmt.Errorf("%w %s, supported media types are: %s", ErrUnsupportedMediaType, mediaType, strings.Join(supported, ", "))
+}
+
 // absPath returns the absolute path of the path.
 func (s *Store) absPath(path string) string {
 	if filepath.IsAbs(path) {
@@ -491,6 +620,33 @@ func (s *Store) tempFile() (*os.File, error) {
 	return tmp, nil
 }
 
+// removeCorruptContent removes the file of the content that failed validation, along with its digest file and
+// its link in the working directory, and resets the status of its name, so that the content is pulled into
+// a clean path again.
+func (s *Store) removeCorruptContent(name, path string) error {
+	unlock := s.lockContentPath(path)
+	defer unlock()
+
+	paths := []string{path}
+	if titlePath := filepath.Join(s.workingDir, name); titlePath != path {
+		paths = append(paths, titlePath)
+	}
+	var errs []error
+	for _, p := range paths {
+		errs = append(errs, removeWithDigestFile(p))
+	}
+	if err := errors.Join(errs...); err != nil {
+		return err
+	}
+
+	status := s.status(name)
+	status.Lock()
+	status.exists = false
+	status.Unlock()
+	s.corruptNames.Store(name, true)
+	return nil
+}
+
 // status returns the nameStatus for the given name.
 func (s *Store) status(name string) *nameStatus {
 	v, _ := s.nameToStatus.LoadOrStore(name, &nameStatus{sync.RWMutex{}, false})
@@ -62,6 +62,10 @@ func (s *Store) processCompressedContent(ctx context.Context, expected ocispec.D
 		return fmt.Errorf("invalid uncompressed size: %w", err)
 	}
 
+	if s.streamDecompression {
+		return s.streamCompressedContent(ctx, expected, content, outputFilePath, size, digest.Digest(uncompressedDigest))
+	}
+
 	fp, err := s.tempFile()
 	if err != nil {
 		return err
@@ -89,7 +93,40 @@ func (s *Store) processCompressedContent(ctx context.Context, expected ocispec.D
 	if d != digest.Digest(uncompressedDigest) {
 		return fmt.Errorf("digest mismatch: expected %s, got %s", uncompressedDigest, d)
 	}
-	s.mediaTypeToPath.Store(expected.MediaType, outputFilePath)
+	s.storeContent(expected, outputFilePath, d)
+
+	return nil
+}
+
+// streamCompressedContent decompresses the compressed content directly to the output file,
+// verifying the compressed content while it is read.
+func (s *Store) streamCompressedContent(ctx context.Context, expected ocispec.Descriptor, content io.Reader, outputFilePath string, size int64, uncompressedDigest digest.Digest) (err error) {
+	ctx, span := trace.StartSpan(ctx, "OCI.streamCompressedContent")
+	defer func() {
+		span.SetStatus(err)
+		span.End()
+	}()
+
+	// verify while decompressing
+	vr := contentpkg.NewVerifyReader(content, expected)
+
+	d, err := disk.Decompress(ctx, vr, outputFilePath, size)
+	if err != nil {
+		return fmt.Errorf("failed to decompress content: %w", err)
+	}
+
+	// the compressed content must be read to the end to be verified
+	if _, err = io.Copy(io.Discard, vr); err != nil {
+		return fmt.Errorf("failed to read content: %w", err)
+	}
+	if err = vr.Verify(); err != nil {
+		return fmt.Errorf("failed to verify content: %w", err)
+	}
+
+	if d != uncompressedDigest {
+		return fmt.Errorf("digest mismatch: expected %s, got %s", uncompressedDigest, d)
+	}
+	s.storeContent(expected, outputFilePath, d)
 
 	return nil
 }
@@ -121,7 +158,7 @@ func (s *Store) processRegularContent(ctx context.Context, expected ocispec.Desc
 		return fmt.Errorf("failed to verify file digest: %w", err)
 	}
 
-	s.mediaTypeToPath.Store(expected.MediaType, outputFilePath)
+	s.storeContent(expected, outputFilePath, expected.Digest)
 
 	return nil
 }
@@ -3,12 +3,16 @@ package oci_test
 import (
 	"bytes"
 	"context"
+	"crypto/rand"
 	"encoding/json"
 	"io"
 	"os"
 	"path/filepath"
+	"strconv"
+	"sync/atomic"
 	"testing"
 
+	"github.com/agoda-com/macOS-vz-kubelet/internal/disk"
 	"github.com/agoda-com/macOS-vz-kubelet/pkg/event/mocks"
 	"github.com/agoda-com/macOS-vz-kubelet/pkg/oci"
 	"github.com/opencontainers/go-digest"
@@ -109,6 +113,7 @@ func TestExists(t *testing.T) {
 	}
 
 	// Test non-existence
+	mockEventRecorder.On("OCICacheMiss", mock.Anything, "test-file", testDigest.String()).Return().Once()
 	exists, err := store.Exists(context.Background(), desc)
 	assert.NoError(t, err)
 	assert.False(t, exists)
@@ -123,6 +128,45 @@ func TestExists(t *testing.T) {
 	assert.True(t, exists)
 }
 
+func TestExistsCacheHitAndMiss(t *testing.T) {
+	testContent := []byte("test content")
+	testDigest := digest.FromBytes(testContent)
+	desc := ocispec.Descriptor{
+		MediaType: string(oci.MediaTypeDiskImage),
+		Digest:    testDigest,
+		Size:      int64(len(testContent)),
+		Annotations: map[string]string{
+			ocispec.AnnotationTitle: "test-file",
+		},
+	}
+
+	t.Run("Valid cache entry", func(t *testing.T) {
+		tempDir := t.TempDir()
+		require.NoError(t, os.WriteFile(filepath.Join(tempDir, "test-file"), testContent, 0644))
+		mockEventRecorder := mocks.NewEventRecorder(t)
+		mockEventRecorder.On("OCICacheHit", mock.Anything, "test-file", testDigest.String()).Return().Once()
+		store, err := oci.New(tempDir, false, mockEventRecorder)
+		require.NoError(t, err)
+		defer handleCloseError(t, store.Close)
+
+		exists, err := store.Exists(context.Background(), desc)
+		require.NoError(t, err)
+		assert.True(t, exists)
+	})
+
+	t.Run("Absent cache entry", func(t *testing.T) {
+		mockEventRecorder := mocks.NewEventRecorder(t)
+		mockEventRecorder.On("OCICacheMiss", mock.Anything, "test-file", testDigest.String()).Return().Once()
+		store, err := oci.New(t.TempDir(), false, mockEventRecorder)
+		require.NoError(t, err)
+		defer handleCloseError(t, store.Close)
+
+		exists, err := store.Exists(context.Background(), desc)
+		require.NoError(t, err)
+		assert.False(t, exists)
+	})
+}
+
 func TestTag(t *testing.T) {
 	// Setup
 	tempDir := t.TempDir()
@@ -249,6 +293,52 @@ func TestDuplicateName(t *testing.T) {
 	assert.ErrorIs(t, err, oci.ErrDuplicateName)
 }
 
+func TestUnsupportedMediaType(t *testing.T) {
+	const mediaType = "application/vnd.oci.image.layer.v1.tar"
+	testContent := []byte("test content")
+
+	t.Run("Push", func(t *testing.T) {
+		mockEventRecorder := mocks.NewEventRecorder(t)
+		mockEventRecorder.On("UnsupportedMediaType", mock.Anything, mediaType, oci.SupportedMediaTypes()).Return().Once()
+		store, err := oci.New(t.TempDir(), false, mockEventRecorder)
+		require.NoError(t, err)
+		defer handleCloseError(t, store.Close)
+
+		desc := ocispec.Descriptor{
+			MediaType: mediaType,
+			Digest:    digest.FromBytes(testContent),
+			Size:      int64(len(testContent)),
+			Annotations: map[string]string{
+				ocispec.AnnotationTitle: "layer.tar",
+			},
+		}
+		err = store.Push(context.Background(), desc, bytes.NewReader(testContent))
+		assert.ErrorIs(t, err, oci.ErrUnsupportedMediaType)
+		assert.ErrorContains(t, err, "layer.tar: unsupported media type "+mediaType)
+		for _, supported := range oci.SupportedMediaTypes() {
+			assert.ErrorContains(t, err, supported)
+		}
+	})
+
+	t.Run("Add", func(t *testing.T) {
+		tempDir := t.TempDir()
+		mockEventRecorder := mocks.NewEventRecorder(t)
+		mockEventRecorder.On("UnsupportedMediaType", mock.Anything, mediaType, oci.SupportedMediaTypes()).Return().Once()
+		store, err := oci.New(tempDir, false, mockEventRecorder)
+		require.NoError(t, err)
+		defer handleCloseError(t, store.Close)
+
+		testFileName := filepath.Join(tempDir, "layer.tar")
+		require.NoError(t, os.WriteFile(testFileName, testContent, 0644))
+
+		_, err = store.Add(context.Background(), mediaType, testFileName)
+		assert.ErrorIs(t, err, oci.ErrUnsupportedMediaType)
+		for _, supported := range oci.SupportedMediaTypes() {
+			assert.ErrorContains(t, err, supported)
+		}
+	})
+}
+
 func TestEventRecorderIntegration(t *testing.T) {
 	// Setup
 	tempDir := t.TempDir()
@@ -256,6 +346,7 @@ func TestEventRecorderIntegration(t *testing.T) {
 
 	// Setup mock expectations - use mock.Anything for context to avoid context matching issues
 	mockEventRecorder.On("FailedToValidateOCI", mock.Anything, "test-file").Return()
+	mockEventRecorder.On("OCICacheMiss", mock.Anything, "test-file", mock.Anything).Return()
 
 	// Create invalid file for validation failure
 	testFileName := filepath.Join(tempDir, "test-file")
@@ -286,6 +377,45 @@ func TestEventRecorderIntegration(t *testing.T) {
 	mockEventRecorder.AssertExpectations(t)
 }
 
+func TestCorruptContentRepaired(t *testing.T) {
+	ctx := context.Background()
+	tempDir := t.TempDir()
+	content := []byte("correct content")
+	desc := ocispec.Descriptor{
+		MediaType:   string(oci.MediaTypeDiskImage),
+		Digest:      digest.FromBytes(content),
+		Size:        int64(len(content)),
+		Annotations: map[string]string{ocispec.AnnotationTitle: "disk.img"},
+	}
+	filePath := filepath.Join(tempDir, "disk.img")
+	require.NoError(t, os.WriteFile(filePath, []byte("corrupt content"), 0644))
+
+	mockEventRecorder := mocks.NewEventRecorder(t)
+	mockEventRecorder.On("FailedToValidateOCI", mock.Anything, "disk.img").Return().Once()
+	mockEventRecorder.On("OCICacheMiss", mock.Anything, "disk.img", desc.Digest.String()).Return().Once()
+	mockEventRecorder.On("OCIRepaired", mock.Anything, "disk.img", desc.Digest.String()).Return().Once()
+
+	store, err := oci.New(tempDir, false, mockEventRecorder)
+	require.NoError(t, err)
+	defer handleCloseError(t, store.Close)
+
+	// the corrupt file is detected and removed before the pull
+	exists, err := store.Exists(ctx, desc)
+	require.NoError(t, err)
+	assert.False(t, exists)
+	assert.NoFileExists(t, filePath)
+
+	// the fresh pull replaces it
+	require.NoError(t, store.Push(ctx, desc, bytes.NewReader(content)))
+	stored, err := os.ReadFile(filePath)
+	require.NoError(t, err)
+	assert.Equal(t, content, stored)
+
+	exists, err = store.Exists(ctx, desc)
+	require.NoError(t, err)
+	assert.True(t, exists)
+}
+
 func TestPredecessors(t *testing.T) {
 	// Setup
 	tempDir := t.TempDir()
@@ -346,3 +476,218 @@ func TestSet(t *testing.T) {
 	assert.Greater(t, desc.Size, int64(0))
 	assert.Contains(t, desc.Annotations, ocispec.AnnotationTitle)
 }
+
+func TestStorageFiles(t *testing.T) {
+	const extraMediaType = oci.MediaType("application/vnd.example.macosvz.extra.v1")
+
+	tests := []struct {
+		name     string
+		storage  []oci.MediaType
+		expected []oci.MediaType
+	}{
+		{
+			name:     "Storage is processed in the declared order",
+			storage:  []oci.MediaType{oci.MediaTypeDiskImage, oci.MediaTypeAuxImage},
+			expected: []oci.MediaType{oci.MediaTypeDiskImage, oci.MediaTypeAuxImage},
+		},
+		{
+			name:     "Extra storage entries are skipped",
+			storage:  []oci.MediaType{extraMediaType, oci.MediaTypeAuxImage, extraMediaType, oci.MediaTypeDiskImage},
+			expected: []oci.MediaType{oci.MediaTypeAuxImage, oci.MediaTypeDiskImage},
+		},
+		{
+			name:     "Missing storage list defaults to macOS storage",
+			expected: []oci.MediaType{oci.MediaTypeAuxImage, oci.MediaTypeDiskImage},
+		},
+		{
+			name:     "Storage subset",
+			storage:  []oci.MediaType{oci.MediaTypeDiskImage},
+			expected: []oci.MediaType{oci.MediaTypeDiskImage},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tempDir := t.TempDir()
+			store, err := oci.New(tempDir, false, mocks.NewEventRecorder(t))
+			require.NoError(t, err)
+			defer handleCloseError(t, store.Close)
+
+			paths := map[oci.MediaType]string{}
+			for _, mediaType := range []oci.MediaType{oci.MediaTypeDiskImage, oci.MediaTypeAuxImage} {
+				path := filepath.Join(tempDir, mediaType.Title())
+				require.NoError(t, os.WriteFile(path, []byte("test content"), 0644))
+				_, err := store.Add(context.Background(), string(mediaType), path)
+				require.NoError(t, err)
+				paths[mediaType] = path
+			}
+
+			files, err := store.StorageFiles(context.Background(), oci.Config{OS: "darwin", Storage: tt.storage})
+			require.NoError(t, err)
+
+			expected := make([]oci.StorageFile, 0, len(tt.expected))
+			for _, mediaType := range tt.expected {
+				expected = append(expected, oci.StorageFile{MediaType: mediaType, Path: paths[mediaType]})
+			}
+			assert.Equal(t, expected, files)
+		})
+	}
+}
+
+func TestStorageFilesMissing(t *testing.T) {
+	tempDir := t.TempDir()
+	store, err := oci.New(tempDir, false, mocks.NewEventRecorder(t))
+	require.NoError(t, err)
+	defer handleCloseError(t, store.Close)
+
+	path := filepath.Join(tempDir, oci.MediaTypeAuxImage.Title())
+	require.NoError(t, os.WriteFile(path, []byte("test content"), 0644))
+	_, err = store.Add(context.Background(), string(oci.MediaTypeAuxImage), path)
+	require.NoError(t, err)
+
+	_, err = store.StorageFiles(context.Background(), oci.NewMacOSConfig("", ""))
+	assert.ErrorContains(t, err, string(oci.MediaTypeDiskImage))
+}
+
+// cancelAfterContext reports cancellation once its error has been checked the given number of times.
+type cancelAfterContext struct {
+	context.Context
+	checks atomic.Int32
+}
+
+func (c *cancelAfterContext) Err() error {
+	if c.checks.Add(-1) < 0 {
+		return context.Canceled
+	}
+	return nil
+}
+
+func TestCloseCanceled(t *testing.T) {
+	tmpDir := t.TempDir()
+	t.Setenv("TMPDIR", tmpDir)
+
+	tempDir := t.TempDir()
+	store, err := oci.New(tempDir, false, mocks.NewEventRecorder(t))
+	require.NoError(t, err)
+
+	for _, mediaType := range []oci.MediaType{oci.MediaTypeDiskImage, oci.MediaTypeAuxImage} {
+		path := filepath.Join(tempDir, mediaType.Title())
+		require.NoError(t, os.WriteFile(path, []byte("test content"), 0644))
+		_, err = store.Add(context.Background(), string(mediaType), path)
+		require.NoError(t, err)
+	}
+	tmpFiles, err := os.ReadDir(tmpDir)
+	require.NoError(t, err)
+	require.Len(t, tmpFiles, 2)
+
+	// the context is canceled after the first removal
+	ctx := &cancelAfterContext{Context: context.Background()}
+	ctx.checks.Store(1)
+	err = store.Close(ctx)
+	assert.ErrorIs(t, err, context.Canceled)
+
+	tmpFiles, err = os.ReadDir(tmpDir)
+	require.NoError(t, err)
+	assert.Len(t, tmpFiles, 1, "remaining removals must be skipped")
+
+	assert.ErrorIs(t, store.Close(context.Background()), oci.ErrStoreClosed)
+}
+
+// peakDiskUsageReader records the peak size of the files within dir while the content is read.
+type peakDiskUsageReader struct {
+	t    *testing.T
+	r    io.Reader
+	dir  string
+	peak int64
+}
+
+func (r *peakDiskUsageReader) Read(p []byte) (int, error) {
+	var size int64
+	entries, err := os.ReadDir(r.dir)
+	require.NoError(r.t, err)
+	for _, entry := range entries {
+		info, err := entry.Info()
+		if err == nil {
+			size += info.Size()
+		}
+	}
+	r.peak = max(r.peak, size)
+	return r.r.Read(p)
+}
+
+func TestPushCompressedContentStreaming(t *testing.T) {
+	// compress some incompressible content
+	uncompressed := make([]byte, 1<<20)
+	_, err := rand.Read(uncompressed)
+	require.NoError(t, err)
+	inputPath := filepath.Join(t.TempDir(), "disk.img")
+	require.NoError(t, os.WriteFile(inputPath, uncompressed, 0o600))
+	compressedFile, err := os.Create(filepath.Join(t.TempDir(), "disk.img.gz"))
+	require.NoError(t, err)
+	res, err := disk.CompressFileWithPath(context.Background(), inputPath, compressedFile)
+	require.NoError(t, err)
+	require.NoError(t, compressedFile.Close())
+	compressed, err := os.ReadFile(res.OutputFilePath)
+	require.NoError(t, err)
+
+	desc := ocispec.Descriptor{
+		MediaType: string(oci.MediaTypeDiskImage),
+		Digest:    res.GzDigest,
+		Size:      res.CompressedSize,
+		Annotations: map[string]string{
+			ocispec.AnnotationTitle:          "disk.img",
+			oci.AnnotationUncompressedSize:   strconv.FormatInt(res.UncompressedSize, 10),
+			oci.AnnotationUncompressedDigest: res.UncompressedDigest.String(),
+		},
+	}
+
+	pull := func(t *testing.T, stream bool) (digest.Digest, int64) {
+		// the temporary files are created within TMPDIR
+		tmpDir := t.TempDir()
+		t.Setenv("TMPDIR", tmpDir)
+
+		store, err := oci.New(t.TempDir(), false, mocks.NewEventRecorder(t))
+		require.NoError(t, err)
+		defer handleCloseError(t, store.Close)
+		store.SetStreamDecompression(stream)
+
+		r := &peakDiskUsageReader{t: t, r: bytes.NewReader(compressed), dir: tmpDir}
+		require.NoError(t, store.Push(context.Background(), desc, r))
+
+		path, err := store.GetFilePathForMediaType(context.Background(), oci.MediaTypeDiskImage)
+		require.NoError(t, err)
+		content, err := os.ReadFile(path)
+		require.NoError(t, err)
+		return digest.FromBytes(content), r.peak
+	}
+
+	buffered, bufferedPeak := pull(t, false)
+	streamed, streamedPeak := pull(t, true)
+
+	assert.Equal(t, res.UncompressedDigest, buffered)
+	assert.Equal(t, buffered, streamed)
+	assert.Zero(t, streamedPeak, "the compressed content was saved to a temporary file")
+	assert.Less(t, streamedPeak, bufferedPeak)
+}
+
+func TestPushCompressedContentStreamingCorrupted(t *testing.T) {
+	content := []byte("not gzip content")
+	desc := ocispec.Descriptor{
+		MediaType: string(oci.MediaTypeDiskImage),
+		Digest:    digest.FromBytes(content),
+		Size:      int64(len(content)),
+		Annotations: map[string]string{
+			ocispec.AnnotationTitle:          "disk.img",
+			oci.AnnotationUncompressedSize:   "16",
+			oci.AnnotationUncompressedDigest: digest.FromString("disk").String(),
+		},
+	}
+
+	store, err := oci.New(t.TempDir(), false, mocks.NewEventRecorder(t))
+	require.NoError(t, err)
+	defer handleCloseError(t, store.Close)
+	store.SetStreamDecompression(true)
+
+	err = store.Push(context.Background(), desc, bytes.NewReader(content))
+	assert.ErrorContains(t, err, "failed to decompress content")
+}
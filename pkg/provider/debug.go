@@ -0,0 +1,166 @@
+package provider
+
+import (
+	"encoding/json"
+	"maps"
+	"net/http"
+	"slices"
+	"sort"
+	"time"
+
+	"github.com/agoda-com/macOS-vz-kubelet/pkg/client"
+	"github.com/agoda-com/macOS-vz-kubelet/pkg/resource"
+	"github.com/agoda-com/macOS-vz-kubelet/pkg/resourcemanager"
+
+	"github.com/virtual-kubelet/virtual-kubelet/log"
+	"github.com/virtual-kubelet/virtual-kubelet/trace"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// DebugVirtualMachinesPath is the path of the route that lists the virtual machines and containers run by the provider.
+const DebugVirtualMachinesPath = "/debug/vms"
+
+// DebugVirtualMachine is the debug representation of a macOS virtual machine.
+// It intentionally omits the environment variables, which may contain secrets.
+type DebugVirtualMachine struct {
+	Namespace  string     `json:"namespace"`
+	Name       string     `json:"name"`
+	Image      string     `json:"image,omitempty"`
+	State      string     `json:"state"`
+	IPAddress  string     `json:"ipAddress,omitempty"`
+	CreatedAt  *time.Time `json:"createdAt,omitempty"`
+	StartedAt  *time.Time `json:"startedAt,omitempty"`
+	FinishedAt *time.Time `json:"finishedAt,omitempty"`
+	Error      string     `json:"error,omitempty"`
+}
+
+// DebugContainer is the debug representation of a regular container running alongside a macOS virtual machine.
+type DebugContainer struct {
+	Namespace  string     `json:"namespace"`
+	PodName    string     `json:"podName"`
+	Name       string     `json:"name"`
+	ID         string     `json:"id,omitempty"`
+	Image      string     `json:"image,omitempty"`
+	State      string     `json:"state"`
+	StartedAt  *time.Time `json:"startedAt,omitempty"`
+	FinishedAt *time.Time `json:"finishedAt,omitempty"`
+	ExitCode   int        `json:"exitCode"`
+	Error      string     `json:"error,omitempty"`
+}
+
+// DebugVirtualizationGroups is the response of the debug route listing the virtual machines and containers.
+type DebugVirtualizationGroups struct {
+	VirtualMachines []DebugVirtualMachine `json:"virtualMachines"`
+	Containers      []DebugContainer      `json:"containers"`
+}
+
+// DebugVirtualMachinesHandler returns a handler that dumps the virtual machines and containers
+// currently tracked by the provider as JSON.
+func (p *MacOSVZProvider) DebugVirtualMachinesHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx, span := trace.StartSpan(r.Context(), "MacOSVZProvider.DebugVirtualMachines")
+		defer span.End()
+
+		vgs, err := p.vzClient.GetVirtualizationGroupListResult(ctx)
+		if err != nil {
+			span.SetStatus(err)
+			log.G(ctx).WithError(err).Error("Failed to list virtualization groups")
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(p.debugVirtualizationGroups(vgs)); err != nil {
+			log.G(ctx).WithError(err).Debug("Failed to write debug response")
+		}
+	})
+}
+
+// debugVirtualizationGroups converts the virtualization groups to their debug representation, sorted by pod.
+func (p *MacOSVZProvider) debugVirtualizationGroups(vgs map[types.NamespacedName]*client.VirtualizationGroup) DebugVirtualizationGroups {
+	keys := make([]types.NamespacedName, 0, len(vgs))
+	for key := range vgs {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].Namespace != keys[j].Namespace {
+			return keys[i].Namespace < keys[j].Namespace
+		}
+		return keys[i].Name < keys[j].Name
+	})
+
+	result := DebugVirtualizationGroups{
+		VirtualMachines: []DebugVirtualMachine{},
+		Containers:      []DebugContainer{},
+	}
+	for _, key := range keys {
+		vg := vgs[key]
+
+		// container images are taken from the pod spec, which might be gone already
+		images := map[string]string{}
+		if pod, err := p.podLister.Pods(key.Namespace).Get(key.Name); err == nil {
+			images = containerImages(pod)
+		}
+
+		if vm := vg.MacOSVirtualMachine; vm != nil {
+			result.VirtualMachines = append(result.VirtualMachines, debugVirtualMachine(key.Namespace, key.Name, vm))
+		}
+		for _, containerName := range slices.Sorted(maps.Keys(vg.AdditionalVirtualMachines)) {
+			name := resourcemanager.AdditionalVirtualMachineName(key.Name, containerName)
+			vm := vg.AdditionalVirtualMachines[containerName]
+			result.VirtualMachines = append(result.VirtualMachines, debugVirtualMachine(key.Namespace, name, vm))
+		}
+
+		for _, c := range vg.Containers {
+			result.Containers = append(result.Containers, DebugContainer{
+				Namespace:  key.Namespace,
+				PodName:    key.Name,
+				Name:       c.Name,
+				ID:         c.ID,
+				Image:      images[c.Name],
+				State:      c.State.Status.String(),
+				StartedAt:  optionalTime(c.State.StartedAt),
+				FinishedAt: optionalTime(c.State.FinishedAt),
+				ExitCode:   c.State.ExitCode,
+				Error:      c.State.Error,
+			})
+		}
+	}
+	return result
+}
+
+// debugVirtualMachine returns the debug representation of the macOS virtual machine.
+func debugVirtualMachine(namespace, name string, vm resource.VirtualMachine) DebugVirtualMachine {
+	entry := DebugVirtualMachine{
+		Namespace:  namespace,
+		Name:       name,
+		Image:      vm.Image(),
+		State:      vm.State().String(),
+		IPAddress:  vm.IPAddress(),
+		CreatedAt:  vm.CreatedAt(),
+		StartedAt:  vm.StartedAt(),
+		FinishedAt: vm.FinishedAt(),
+	}
+	if err := vm.Error(); err != nil {
+		entry.Error = err.Error()
+	}
+	return entry
+}
+
+// containerImages returns the images of the pod containers keyed by container name.
+func containerImages(pod *corev1.Pod) map[string]string {
+	images := make(map[string]string, len(pod.Spec.Containers))
+	for _, c := range pod.Spec.Containers {
+		images[c.Name] = c.Image
+	}
+	return images
+}
+
+// optionalTime returns nil for the zero time.
+func optionalTime(t time.Time) *time.Time {
+	if t.IsZero() {
+		return nil
+	}
+	return &t
+}
@@ -0,0 +1,136 @@
+package provider_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+
+	"github.com/agoda-com/macOS-vz-kubelet/pkg/client"
+	clientmocks "github.com/agoda-com/macOS-vz-kubelet/pkg/client/mocks"
+	"github.com/agoda-com/macOS-vz-kubelet/pkg/provider"
+	"github.com/agoda-com/macOS-vz-kubelet/pkg/resource"
+	vmmocks "github.com/agoda-com/macOS-vz-kubelet/pkg/resource/mocks"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+func TestDebugVirtualMachinesHandler(t *testing.T) {
+	ctx := context.Background()
+	createdAt := time.Date(2012, 12, 12, 12, 12, 12, 0, time.UTC)
+	startedAt := createdAt.Add(time.Minute)
+
+	pod := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: "running-pod", Namespace: "default"},
+		Spec: corev1.PodSpec{
+			Containers: []corev1.Container{
+				{
+					Name:  "macos",
+					Image: "localhost:5000/macos:latest",
+					Env:   []corev1.EnvVar{{Name: "TOKEN", Value: "s3cr3t"}},
+				},
+				{Name: "sidecar", Image: "localhost:5000/sidecar:1.27.1"},
+			},
+		},
+	}
+
+	// Environment is not expected to be queried, so that no secrets are leaked
+	running := vmmocks.NewVirtualMachine(t)
+	running.On("Image").Return("localhost:5000/macos:latest")
+	running.On("State").Return(resource.VirtualMachineStateRunning)
+	running.On("IPAddress").Return("10.0.0.3")
+	running.On("CreatedAt").Return(&createdAt)
+	running.On("StartedAt").Return(&startedAt)
+	running.On("FinishedAt").Return(nil)
+	running.On("Healthy").Return(true).Maybe()
+	running.On("Error").Return(nil)
+
+	// Pod of the failed virtual machine is already gone, its image is still known from the virtual machine
+	failed := vmmocks.NewVirtualMachine(t)
+	failed.On("Image").Return("localhost:5000/macos:13")
+	failed.On("State").Return(resource.VirtualMachineStateFailed)
+	failed.On("IPAddress").Return("")
+	failed.On("CreatedAt").Return(nil)
+	failed.On("StartedAt").Return(nil)
+	failed.On("FinishedAt").Return(nil)
+	failed.On("Error").Return(errors.New("download failed"))
+
+	vzClient := clientmocks.NewVzClientInterface(t)
+	vzClient.On("GetVirtualizationGroupListResult", mock.Anything).Return(map[types.NamespacedName]*client.VirtualizationGroup{
+		{Namespace: "default", Name: "running-pod"}: {
+			MacOSVirtualMachine: running,
+			Containers: []resource.Container{
+				{
+					ID:    "abc123",
+					Name:  "sidecar",
+					State: resource.ContainerState{Status: resource.ContainerStatusRunning, StartedAt: startedAt},
+				},
+			},
+		},
+		{Namespace: "ci", Name: "failed-pod"}: {
+			MacOSVirtualMachine: failed,
+		},
+	}, nil)
+
+	p := setupVZProviderWithPodInformer(t, ctx, vzClient, pod)
+
+	rec := httptest.NewRecorder()
+	p.DebugVirtualMachinesHandler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, provider.DebugVirtualMachinesPath, nil))
+
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "application/json", rec.Header().Get("Content-Type"))
+	assert.JSONEq(t, `{
+		"virtualMachines": [
+			{
+				"namespace": "ci",
+				"name": "failed-pod",
+				"image": "localhost:5000/macos:13",
+				"state": "Failed",
+				"error": "download failed"
+			},
+			{
+				"namespace": "default",
+				"name": "running-pod",
+				"image": "localhost:5000/macos:latest",
+				"state": "Running",
+				"ipAddress": "10.0.0.3",
+				"createdAt": "2012-12-12T12:12:12Z",
+				"startedAt": "2012-12-12T12:13:12Z"
+			}
+		],
+		"containers": [
+			{
+				"namespace": "default",
+				"podName": "running-pod",
+				"name": "sidecar",
+				"id": "abc123",
+				"image": "localhost:5000/sidecar:1.27.1",
+				"state": "Running",
+				"startedAt": "2012-12-12T12:13:12Z",
+				"exitCode": 0
+			}
+		]
+	}`, rec.Body.String())
+	assert.NotContains(t, rec.Body.String(), "s3cr3t")
+}
+
+func TestDebugVirtualMachinesHandler_Error(t *testing.T) {
+	ctx := context.Background()
+
+	vzClient := clientmocks.NewVzClientInterface(t)
+	vzClient.On("GetVirtualizationGroupListResult", mock.Anything).Return(nil, errors.New("list failed"))
+
+	p := setupVZProviderWithPodInformer(t, ctx, vzClient)
+
+	rec := httptest.NewRecorder()
+	p.DebugVirtualMachinesHandler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, provider.DebugVirtualMachinesPath, nil))
+
+	assert.Equal(t, http.StatusInternalServerError, rec.Code)
+}
@@ -0,0 +1,85 @@
+package provider
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/virtual-kubelet/virtual-kubelet/errdefs"
+	"github.com/virtual-kubelet/virtual-kubelet/log"
+	"github.com/virtual-kubelet/virtual-kubelet/trace"
+)
+
+// ExportVirtualMachinePath is the path of the route that pushes the disk of a macOS container to a registry.
+const ExportVirtualMachinePath = "/export"
+
+// ExportRequest is the request of the route exporting the virtual machine of a macOS container.
+type ExportRequest struct {
+	Namespace string `json:"namespace"`
+	Pod       string `json:"pod"`
+	// Container is the name of the macOS container, the first container of the pod if empty.
+	Container string `json:"container,omitempty"`
+	// Ref is the image reference the disk is pushed to, it must be a tag.
+	Ref string `json:"ref"`
+}
+
+// ExportResponse is the response of the route exporting the virtual machine of a macOS container.
+type ExportResponse struct {
+	Ref    string `json:"ref"`
+	Digest string `json:"digest"`
+}
+
+// ExportVirtualMachineHandler returns a handler that exports the virtual machine of the container posted as JSON.
+// It responds with the digest of the pushed image once the push completes, 404 if the pod or container
+// is not found, and 400 if the request is invalid.
+func (p *MacOSVZProvider) ExportVirtualMachineHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx, span := trace.StartSpan(r.Context(), "MacOSVZProvider.ExportVirtualMachine")
+		defer span.End()
+
+		if r.Method != http.MethodPost {
+			w.Header().Set("Allow", http.MethodPost)
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		var req ExportRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "invalid request: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		if req.Namespace == "" || req.Pod == "" || req.Ref == "" {
+			http.Error(w, "namespace, pod and ref are required", http.StatusBadRequest)
+			return
+		}
+
+		if req.Container == "" {
+			pod, err := p.podLister.Pods(req.Namespace).Get(req.Pod)
+			if err != nil || len(pod.Spec.Containers) == 0 {
+				http.Error(w, "pod not found", http.StatusNotFound)
+				return
+			}
+			// vz: always assume that first container is macOS container
+			req.Container = pod.Spec.Containers[0].Name
+		}
+
+		d, err := p.vzClient.ExportVirtualMachine(ctx, req.Namespace, req.Pod, req.Container, req.Ref)
+		if err != nil {
+			span.SetStatus(err)
+			switch {
+			case errdefs.IsNotFound(err):
+				http.Error(w, err.Error(), http.StatusNotFound)
+			case errdefs.IsInvalidInput(err):
+				http.Error(w, err.Error(), http.StatusBadRequest)
+			default:
+				log.G(ctx).WithError(err).Error("Failed to export virtual machine")
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+			}
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(ExportResponse{Ref: req.Ref, Digest: d.String()}); err != nil {
+			log.G(ctx).WithError(err).Debug("Failed to write export response")
+		}
+	})
+}
@@ -0,0 +1,104 @@
+package provider_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/opencontainers/go-digest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"github.com/virtual-kubelet/virtual-kubelet/errdefs"
+
+	clientmocks "github.com/agoda-com/macOS-vz-kubelet/pkg/client/mocks"
+	"github.com/agoda-com/macOS-vz-kubelet/pkg/provider"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestExportVirtualMachineHandler(t *testing.T) {
+	ctx := context.Background()
+	pod := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: "test-pod", Namespace: "default"},
+		Spec:       corev1.PodSpec{Containers: []corev1.Container{{Name: "macos"}}},
+	}
+	d := digest.FromString("manifest")
+
+	tests := []struct {
+		name      string
+		request   provider.ExportRequest
+		container string
+		exportErr error
+		status    int
+	}{
+		{
+			name:      "Exported with the first container",
+			request:   provider.ExportRequest{Namespace: "default", Pod: "test-pod", Ref: "localhost:5000/macos:snapshot"},
+			container: "macos",
+			status:    http.StatusOK,
+		},
+		{
+			name:      "Exported with the container",
+			request:   provider.ExportRequest{Namespace: "default", Pod: "test-pod", Container: "builder", Ref: "localhost:5000/macos:snapshot"},
+			container: "builder",
+			status:    http.StatusOK,
+		},
+		{
+			name:    "Missing reference",
+			request: provider.ExportRequest{Namespace: "default", Pod: "test-pod"},
+			status:  http.StatusBadRequest,
+		},
+		{
+			name:    "Unknown pod",
+			request: provider.ExportRequest{Namespace: "default", Pod: "unknown", Ref: "localhost:5000/macos:snapshot"},
+			status:  http.StatusNotFound,
+		},
+		{
+			name:      "Invalid reference",
+			request:   provider.ExportRequest{Namespace: "default", Pod: "test-pod", Ref: "localhost:5000/macos@sha256:0"},
+			container: "macos",
+			exportErr: errdefs.InvalidInput("image reference must be a tag to push to"),
+			status:    http.StatusBadRequest,
+		},
+		{
+			name:      "Failed push",
+			request:   provider.ExportRequest{Namespace: "default", Pod: "test-pod", Ref: "localhost:5000/macos:snapshot"},
+			container: "macos",
+			exportErr: errors.New("connection refused"),
+			status:    http.StatusInternalServerError,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			vzClient := clientmocks.NewVzClientInterface(t)
+			if tt.container != "" {
+				vzClient.On("ExportVirtualMachine", mock.Anything, "default", "test-pod", tt.container, tt.request.Ref).Return(d, tt.exportErr)
+			}
+			p := setupVZProviderWithPodInformer(t, ctx, vzClient, pod)
+
+			body, err := json.Marshal(tt.request)
+			require.NoError(t, err)
+			rec := httptest.NewRecorder()
+			p.ExportVirtualMachineHandler().ServeHTTP(rec, httptest.NewRequest(http.MethodPost, provider.ExportVirtualMachinePath, bytes.NewReader(body)))
+
+			assert.Equal(t, tt.status, rec.Code)
+			if tt.status == http.StatusOK {
+				var response provider.ExportResponse
+				require.NoError(t, json.NewDecoder(rec.Body).Decode(&response))
+				assert.Equal(t, provider.ExportResponse{Ref: tt.request.Ref, Digest: d.String()}, response)
+			}
+		})
+	}
+
+	rec := httptest.NewRecorder()
+	p := setupVZProviderWithPodInformer(t, ctx, clientmocks.NewVzClientInterface(t))
+	p.ExportVirtualMachineHandler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, provider.ExportVirtualMachinePath, nil))
+	assert.Equal(t, http.StatusMethodNotAllowed, rec.Code)
+}
@@ -4,11 +4,14 @@ import (
 	"context"
 	"fmt"
 	"io"
+	"slices"
 	"time"
 
 	"golang.org/x/sync/errgroup"
+	"golang.org/x/sync/singleflight"
 
 	"github.com/agoda-com/macOS-vz-kubelet/internal/node"
+	"github.com/agoda-com/macOS-vz-kubelet/internal/utils"
 	"github.com/agoda-com/macOS-vz-kubelet/pkg/client"
 	"github.com/agoda-com/macOS-vz-kubelet/pkg/event"
 	"github.com/agoda-com/macOS-vz-kubelet/pkg/metrics"
@@ -27,8 +30,26 @@ import (
 const (
 	ComponentName = "macos-vz-kubelet"
 
-	// Timeout for deleting VZ group on failure.
+	// Default timeout for deleting VZ group of a pod that is gone or terminal.
 	DefaultDeleteVZGroupGracePeriodSeconds int64 = 10
+
+	// MaxLifetimeExceededReason is the reason of pods failed after their VM exceeded the maximum lifetime.
+	MaxLifetimeExceededReason = "MaxLifetimeExceeded"
+
+	// DeadlineExceededReason is the reason of pods failed after running longer than their active deadline.
+	DeadlineExceededReason = "DeadlineExceeded"
+
+	// PreemptedReason is the reason of pods failed after their VM was preempted by a higher priority pod.
+	PreemptedReason = "Preempting"
+
+	// VirtualMachineCrashedReason is the reason of pods whose macOS VM was stopped by Virtualization.framework because of an error.
+	VirtualMachineCrashedReason = "VirtualMachineCrashed"
+
+	// ReadinessTimeoutReason is the reason of pods whose macOS VM did not accept SSH connections within the readiness timeout.
+	ReadinessTimeoutReason = "ReadinessTimeout"
+
+	// EvictedReason is the reason of pods whose macOS VM was evicted for exceeding the ephemeral storage limit.
+	EvictedReason = "Evicted"
 )
 
 type MacOSVZProviderConfig struct {
@@ -37,6 +58,25 @@ type MacOSVZProviderConfig struct {
 	InternalIP         string
 	DaemonEndpointPort int32
 
+	// InternalIPInterface is the network interface whose IP address is the internal IP of the node, unless InternalIP is set.
+	// The IP address of the active interface is used if both are empty.
+	InternalIPInterface string
+
+	// ExcludeFromLoadBalancers labels the node to be excluded from external load balancers.
+	ExcludeFromLoadBalancers bool
+
+	// OrphanDeleteGracePeriod is the grace period of the VZ groups deleted because their pod is gone or terminal.
+	// DefaultDeleteVZGroupGracePeriodSeconds if zero.
+	OrphanDeleteGracePeriod time.Duration
+
+	// RetainFailedVMs keeps the VZ groups of failed pods until the pods are deleted, so that they can be inspected.
+	// Pods may override it with the client.RetainFailedVMsAnnotation annotation.
+	RetainFailedVMs bool
+
+	// TracePodAnnotations are the keys of the pod annotations set on the spans of the pod creation,
+	// so that its traces can be correlated with e.g. the CI jobs of the pods.
+	TracePodAnnotations []string
+
 	K8sClient     kubernetes.Interface
 	EventRecorder event.EventRecorder
 	PodsLister    corev1listers.PodLister
@@ -51,9 +91,19 @@ type MacOSVZProvider struct {
 
 	nodeName           string
 	nodeIPAddress      string
+	nodeIPInterface    string
 	platform           string
 	daemonEndpointPort int32
 
+	excludeFromLoadBalancers bool
+
+	orphanDeleteGracePeriodSeconds int64
+	retainFailedVMs                bool
+	tracePodAnnotations            []string
+
+	// statusGroup coalesces the concurrent status requests of a pod into a single client call
+	statusGroup singleflight.Group
+
 	*metrics.MacOSVZPodMetricsProvider
 }
 
@@ -72,8 +122,21 @@ func NewMacOSVZProvider(ctx context.Context, vzClient client.VzClientInterface,
 	p.nodeName = config.NodeName
 	p.platform = config.Platform
 
+	// the static IP address takes precedence over the interface it would be resolved from
 	p.nodeIPAddress = config.InternalIP
+	if p.nodeIPAddress == "" {
+		p.nodeIPInterface = config.InternalIPInterface
+	}
 	p.daemonEndpointPort = config.DaemonEndpointPort
+	p.excludeFromLoadBalancers = config.ExcludeFromLoadBalancers
+
+	p.orphanDeleteGracePeriodSeconds = DefaultDeleteVZGroupGracePeriodSeconds
+	if config.OrphanDeleteGracePeriod > 0 {
+		p.orphanDeleteGracePeriodSeconds = int64(config.OrphanDeleteGracePeriod.Seconds())
+	}
+
+	p.retainFailedVMs = config.RetainFailedVMs
+	p.tracePodAnnotations = config.TracePodAnnotations
 
 	p.eventRecorder = config.EventRecorder
 
@@ -92,19 +155,26 @@ func (p *MacOSVZProvider) CreatePod(ctx context.Context, pod *corev1.Pod) (err e
 		Name:      pod.Name,
 		UID:       pod.UID,
 	})
+	ctx = utils.WithTraceFields(ctx, utils.PodAnnotationFields(pod.Annotations, p.tracePodAnnotations))
 	ctx, span := trace.StartSpan(ctx, "MacOSVZProvider.CreatePod")
+	ctx = span.WithFields(ctx, utils.TraceFields(ctx))
 	defer func() {
 		span.SetStatus(err)
 		span.End()
 	}()
 	log.G(ctx).Debug("Received CreatePod request")
 
-	configMaps, serviceAccountToken, err := p.extractPodCredentials(ctx, pod)
+	// Reject the pod with all its problems at once, before anything is fetched or created
+	if err := p.vzClient.ValidatePod(ctx, pod); err != nil {
+		return err
+	}
+
+	configMaps, secrets, serviceAccountToken, err := p.extractPodCredentials(ctx, pod)
 	if err != nil {
 		return err
 	}
 
-	return p.vzClient.CreateVirtualizationGroup(ctx, pod, serviceAccountToken, configMaps)
+	return p.vzClient.CreateVirtualizationGroup(ctx, pod, serviceAccountToken, configMaps, secrets)
 }
 
 // UpdatePod takes a Kubernetes Pod and updates it within the provider.
@@ -228,7 +298,7 @@ func (p *MacOSVZProvider) GetPod(ctx context.Context, namespace, name string) (p
 		// If error here occurs, usually its related to the pod being told to be forgotten,
 		// e.g. force deletion or specific deletion cases where kubelet doesnt have opportunity to respond.
 		// For now, we just delete the VM and return nil (providing client didn't fail).
-		return nil, p.vzClient.DeleteVirtualizationGroup(ctx, namespace, name, DefaultDeleteVZGroupGracePeriodSeconds)
+		return nil, p.vzClient.DeleteVirtualizationGroup(ctx, namespace, name, p.orphanDeleteGracePeriodSeconds)
 	}
 
 	return pod, nil
@@ -244,7 +314,7 @@ func (p *MacOSVZProvider) GetPodStatus(ctx context.Context, namespace, name stri
 	}()
 	logger.Debug("Received GetPodStatus request")
 
-	vg, err := p.vzClient.GetVirtualizationGroup(ctx, namespace, name)
+	vg, err := p.getVirtualizationGroupCoalesced(ctx, namespace, name)
 	if err != nil {
 		return nil, err
 	}
@@ -255,18 +325,68 @@ func (p *MacOSVZProvider) GetPodStatus(ctx context.Context, namespace, name stri
 	}
 
 	ps = p.buildPodStatus(ctx, vg, pod)
-	if pod.DeletionTimestamp == nil && (ps.Phase == corev1.PodFailed || ps.Phase == corev1.PodSucceeded) {
-		// If the pod is in a failed or succeeded state and is not scheduled for deletion,
-		// it will never be queried for status again by design. We should delete it from
-		// the provider to avoid any potential resource leaks.
-		if err := p.vzClient.DeleteVirtualizationGroup(ctx, namespace, name, DefaultDeleteVZGroupGracePeriodSeconds); err != nil {
-			logger.WithError(err).Debugf("Failed to force delete virtualization group for pod %s/%s", namespace, name)
-		}
+	if pod.DeletionTimestamp != nil || (ps.Phase != corev1.PodFailed && ps.Phase != corev1.PodSucceeded) {
+		return ps, nil
+	}
+
+	if ps.Phase == corev1.PodFailed && !slices.Contains(reclaimedReasons, ps.Reason) && p.retainsFailedVMs(ctx, pod) {
+		// The VZ group is kept for debugging until the pod is deleted, its VMs still occupy their slots.
+		ctx = event.WithObjectRef(ctx, corev1.ObjectReference{
+			Namespace: pod.Namespace,
+			Name:      pod.Name,
+			UID:       pod.UID,
+		})
+		p.eventRecorder.RetainedFailedVirtualMachine(ctx, pod.Spec.Containers[0].Name)
+		return ps, nil
+	}
+
+	// If the pod is in a failed or succeeded state and is not scheduled for deletion,
+	// it will never be queried for status again by design. We should delete it from
+	// the provider to avoid any potential resource leaks.
+	if err := p.vzClient.DeleteVirtualizationGroup(ctx, namespace, name, p.orphanDeleteGracePeriodSeconds); err != nil {
+		logger.WithError(err).Debugf("Failed to force delete virtualization group for pod %s/%s", namespace, name)
 	}
 
 	return ps, nil
 }
 
+// reclaimedReasons are the reasons of the pods whose VMs were failed on purpose to release their slots or storage.
+// Their VZ groups are never retained, so that preempted VMs, for instance, make room for the pods waiting for them.
+var reclaimedReasons = []string{PreemptedReason, DeadlineExceededReason, MaxLifetimeExceededReason, EvictedReason}
+
+// retainsFailedVMs returns whether the VZ group of the pod is kept after the pod fails.
+func (p *MacOSVZProvider) retainsFailedVMs(ctx context.Context, pod *corev1.Pod) bool {
+	retain, err := client.ParseRetainFailedVMs(pod, p.retainFailedVMs)
+	if err != nil {
+		log.G(ctx).WithError(err).Warn("Ignoring invalid retention annotation")
+	}
+	return retain
+}
+
+// getVirtualizationGroupCoalesced retrieves the virtualization group of the pod, sharing the result with the
+// concurrent callers for the same pod. Retrieving it may exec into the virtual machines and inspect the
+// containers, so tightly polled statuses would otherwise multiply the SSH and Docker load.
+func (p *MacOSVZProvider) getVirtualizationGroupCoalesced(ctx context.Context, namespace, name string) (*client.VirtualizationGroup, error) {
+	// the shared call outlives the caller that started it, should that caller give up
+	sharedCtx := context.WithoutCancel(ctx)
+	ch := p.statusGroup.DoChan(namespace+"/"+name, func() (any, error) {
+		return p.vzClient.GetVirtualizationGroup(sharedCtx, namespace, name)
+	})
+
+	select {
+	case result := <-ch:
+		if result.Err != nil {
+			return nil, result.Err
+		}
+		if result.Shared {
+			log.G(ctx).Debug("Shared virtualization group with concurrent status requests")
+		}
+		return result.Val.(*client.VirtualizationGroup), nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
 // GetPods retrieves a list of all pods running on the provider (can be cached).
 func (p *MacOSVZProvider) GetPods(ctx context.Context) (pods []*corev1.Pod, err error) {
 	ctx, span := trace.StartSpan(ctx, "MacOSVZProvider.GetPods")
@@ -317,6 +437,17 @@ func (p *MacOSVZProvider) RunInContainer(ctx context.Context, namespace, podName
 		span.End()
 	}()
 	log.G(ctx).Debug("Received RunInContainer request")
+
+	if pod, err := p.podLister.Pods(namespace).Get(podName); err == nil {
+		shell, err := client.ParseExecShell(pod)
+		if err != nil {
+			return err
+		}
+		if shell != "" {
+			cmd = utils.WrapInLoginShell(shell, cmd)
+			log.G(ctx).Debugf("Wrapping the command in a %s login shell", shell)
+		}
+	}
 	return p.vzClient.ExecuteContainerCommand(ctx, namespace, podName, containerName, cmd, attach)
 }
 
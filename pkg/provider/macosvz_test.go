@@ -3,32 +3,47 @@ package provider_test
 import (
 	"context"
 	"io"
+	goruntime "runtime"
 	"strings"
 	"sync"
 	"testing"
+	"time"
 
 	"github.com/agoda-com/macOS-vz-kubelet/internal/node"
+	"github.com/agoda-com/macOS-vz-kubelet/internal/utils"
 	"github.com/agoda-com/macOS-vz-kubelet/pkg/client"
 	clientmocks "github.com/agoda-com/macOS-vz-kubelet/pkg/client/mocks"
 	"github.com/agoda-com/macOS-vz-kubelet/pkg/event"
+	eventmocks "github.com/agoda-com/macOS-vz-kubelet/pkg/event/mocks"
 	"github.com/agoda-com/macOS-vz-kubelet/pkg/provider"
 	"github.com/agoda-com/macOS-vz-kubelet/pkg/resource"
+	vmmocks "github.com/agoda-com/macOS-vz-kubelet/pkg/resource/mocks"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
 	"github.com/stretchr/testify/require"
 
+	"github.com/virtual-kubelet/virtual-kubelet/errdefs"
 	"github.com/virtual-kubelet/virtual-kubelet/node/api"
 	"github.com/virtual-kubelet/virtual-kubelet/node/nodeutil"
+	"github.com/virtual-kubelet/virtual-kubelet/trace"
+	"github.com/virtual-kubelet/virtual-kubelet/trace/opentelemetry"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
 
 	authv1 "k8s.io/api/authentication/v1"
 	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/informers"
 	"k8s.io/client-go/kubernetes/fake"
 	k8stesting "k8s.io/client-go/testing"
+	"k8s.io/client-go/tools/cache"
 	"k8s.io/client-go/tools/record"
+	"k8s.io/utils/ptr"
 )
 
 // check that provider implements nodeutil.Provider
@@ -56,8 +71,10 @@ func TestCreatePod(t *testing.T) {
 		name               string
 		pod                *corev1.Pod
 		configMaps         []*corev1.ConfigMap
+		secrets            []*corev1.Secret
 		serviceAccountName string
 		expectedConfigMaps map[string]*corev1.ConfigMap
+		expectedSecrets    map[string]*corev1.Secret
 		expectedToken      string
 	}{
 		{
@@ -84,6 +101,7 @@ func TestCreatePod(t *testing.T) {
 			configMaps:         []*corev1.ConfigMap{},
 			serviceAccountName: "default",
 			expectedConfigMaps: map[string]*corev1.ConfigMap{},
+			expectedSecrets:    map[string]*corev1.Secret{},
 			expectedToken:      "",
 		},
 		{
@@ -147,7 +165,139 @@ func TestCreatePod(t *testing.T) {
 					},
 				},
 			},
-			expectedToken: "test-token",
+			expectedSecrets: map[string]*corev1.Secret{},
+			expectedToken:   "test-token",
+		},
+		{
+			name: "Pod with env from config map and secret",
+			pod: &corev1.Pod{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "test-pod",
+					Namespace: "default",
+				},
+				Spec: corev1.PodSpec{
+					AutomountServiceAccountToken: ptr.To(false),
+					Containers: []corev1.Container{
+						{
+							Name: "test-container",
+							Env: []corev1.EnvVar{
+								{
+									Name: "FROM_CONFIG_MAP",
+									ValueFrom: &corev1.EnvVarSource{
+										ConfigMapKeyRef: &corev1.ConfigMapKeySelector{
+											LocalObjectReference: corev1.LocalObjectReference{Name: "test-configmap"},
+											Key:                  "key",
+										},
+									},
+								},
+								{
+									Name: "FROM_SECRET",
+									ValueFrom: &corev1.EnvVarSource{
+										SecretKeyRef: &corev1.SecretKeySelector{
+											LocalObjectReference: corev1.LocalObjectReference{Name: "test-secret"},
+											Key:                  "key",
+										},
+									},
+								},
+								{
+									Name: "FROM_MISSING_SECRET",
+									ValueFrom: &corev1.EnvVarSource{
+										SecretKeyRef: &corev1.SecretKeySelector{
+											LocalObjectReference: corev1.LocalObjectReference{Name: "missing-secret"},
+											Key:                  "key",
+											Optional:             ptr.To(true),
+										},
+									},
+								},
+							},
+						},
+					},
+				},
+			},
+			configMaps: []*corev1.ConfigMap{
+				{
+					ObjectMeta: metav1.ObjectMeta{Name: "test-configmap", Namespace: "default"},
+					Data:       map[string]string{"key": "value"},
+				},
+			},
+			secrets: []*corev1.Secret{
+				{
+					ObjectMeta: metav1.ObjectMeta{Name: "test-secret", Namespace: "default"},
+					Data:       map[string][]byte{"key": []byte("secret")},
+				},
+			},
+			expectedConfigMaps: map[string]*corev1.ConfigMap{
+				"test-configmap": {
+					ObjectMeta: metav1.ObjectMeta{Name: "test-configmap", Namespace: "default"},
+					Data:       map[string]string{"key": "value"},
+				},
+			},
+			expectedSecrets: map[string]*corev1.Secret{
+				"test-secret": {
+					ObjectMeta: metav1.ObjectMeta{Name: "test-secret", Namespace: "default"},
+					Data:       map[string][]byte{"key": []byte("secret")},
+				},
+			},
+		},
+		{
+			name: "Pod with envFrom config map and secret",
+			pod: &corev1.Pod{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "test-pod",
+					Namespace: "default",
+				},
+				Spec: corev1.PodSpec{
+					AutomountServiceAccountToken: ptr.To(false),
+					Containers: []corev1.Container{
+						{
+							Name: "test-container",
+							EnvFrom: []corev1.EnvFromSource{
+								{
+									ConfigMapRef: &corev1.ConfigMapEnvSource{
+										LocalObjectReference: corev1.LocalObjectReference{Name: "test-configmap"},
+									},
+								},
+								{
+									Prefix: "SECRET_",
+									SecretRef: &corev1.SecretEnvSource{
+										LocalObjectReference: corev1.LocalObjectReference{Name: "test-secret"},
+									},
+								},
+								{
+									ConfigMapRef: &corev1.ConfigMapEnvSource{
+										LocalObjectReference: corev1.LocalObjectReference{Name: "missing-configmap"},
+										Optional:             ptr.To(true),
+									},
+								},
+							},
+						},
+					},
+				},
+			},
+			configMaps: []*corev1.ConfigMap{
+				{
+					ObjectMeta: metav1.ObjectMeta{Name: "test-configmap", Namespace: "default"},
+					Data:       map[string]string{"key": "value"},
+				},
+			},
+			secrets: []*corev1.Secret{
+				{
+					ObjectMeta: metav1.ObjectMeta{Name: "test-secret", Namespace: "default"},
+					Data:       map[string][]byte{"key": []byte("secret")},
+				},
+			},
+			expectedConfigMaps: map[string]*corev1.ConfigMap{
+				"test-configmap": {
+					ObjectMeta: metav1.ObjectMeta{Name: "test-configmap", Namespace: "default"},
+					Data:       map[string]string{"key": "value"},
+				},
+			},
+			expectedSecrets: map[string]*corev1.Secret{
+				"test-secret": {
+					ObjectMeta: metav1.ObjectMeta{Name: "test-secret", Namespace: "default"},
+					Data:       map[string][]byte{"key": []byte("secret")},
+				},
+			},
 		},
 	}
 
@@ -163,6 +313,10 @@ func TestCreatePod(t *testing.T) {
 				_, err := fakeClient.CoreV1().ConfigMaps(cm.Namespace).Create(ctx, cm, metav1.CreateOptions{})
 				require.NoError(t, err)
 			}
+			for _, secret := range tc.secrets {
+				_, err := fakeClient.CoreV1().Secrets(secret.Namespace).Create(ctx, secret, metav1.CreateOptions{})
+				require.NoError(t, err)
+			}
 
 			// Mock Virtualization Client
 			vzClient := clientmocks.NewVzClientInterface(t)
@@ -187,8 +341,9 @@ func TestCreatePod(t *testing.T) {
 			// Set up the expected pod
 			expectedPod := tc.pod.DeepCopy()
 
-			// Mock Virtualization Client's CreateVirtualizationGroup method
-			vzClient.On("CreateVirtualizationGroup", mock.Anything, expectedPod, tc.expectedToken, tc.expectedConfigMaps).Return(nil)
+			// Mock Virtualization Client's ValidatePod and CreateVirtualizationGroup methods
+			vzClient.On("ValidatePod", mock.Anything, expectedPod).Return(nil)
+			vzClient.On("CreateVirtualizationGroup", mock.Anything, expectedPod, tc.expectedToken, tc.expectedConfigMaps, tc.expectedSecrets).Return(nil)
 
 			// Call the provider's CreatePod function
 			err = p.CreatePod(ctx, tc.pod)
@@ -484,6 +639,36 @@ func TestGetPod(t *testing.T) {
 	vzClient.AssertExpectations(t)
 }
 
+func TestGetPod_OrphanDeleteGracePeriod(t *testing.T) {
+	ctx := context.Background()
+	vzClient := clientmocks.NewVzClientInterface(t)
+
+	vg := &client.VirtualizationGroup{
+		MacOSVirtualMachine: &resource.MacOSVirtualMachine{},
+	}
+	vzClient.On("GetVirtualizationGroup", mock.Anything, "default", "orphan").Return(vg, nil).Once()
+	// the orphaned virtualization group is deleted with the configured grace period
+	vzClient.On("DeleteVirtualizationGroup", mock.Anything, "default", "orphan", int64(30)).Return(nil).Once()
+
+	fakeClient := fake.NewSimpleClientset()
+	podInformerFactory := informers.NewSharedInformerFactoryWithOptions(fakeClient, 1)
+	podInformer := podInformerFactory.Core().V1().Pods().Informer()
+	podInformerFactory.Start(ctx.Done())
+	require.True(t, cache.WaitForCacheSync(ctx.Done(), podInformer.HasSynced))
+
+	p, err := provider.NewMacOSVZProvider(ctx, vzClient, provider.MacOSVZProviderConfig{
+		Platform:                defaultPlatform,
+		OrphanDeleteGracePeriod: 30 * time.Second,
+		K8sClient:               fakeClient,
+		PodsLister:              podInformerFactory.Core().V1().Pods().Lister(),
+	})
+	require.NoError(t, err)
+
+	pod, err := p.GetPod(ctx, "default", "orphan")
+	assert.NoError(t, err)
+	assert.Nil(t, pod)
+}
+
 func TestGetPodStatus_GetVirtualizationGroupError(t *testing.T) {
 	ctx := context.Background()
 	vzClient := clientmocks.NewVzClientInterface(t)
@@ -503,6 +688,177 @@ func TestGetPodStatus_GetVirtualizationGroupError(t *testing.T) {
 	vzClient.AssertExpectations(t)
 }
 
+func TestGetPodStatus_CoalescesConcurrentRequests(t *testing.T) {
+	ctx := context.Background()
+	pod := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: "test-pod", Namespace: "default"},
+		Spec:       corev1.PodSpec{Containers: []corev1.Container{{Name: "macos", Image: "localhost:5000/macos:latest"}}},
+	}
+
+	vm := vmmocks.NewVirtualMachine(t)
+	vm.On("State").Return(resource.VirtualMachineStateRunning)
+	vm.On("IPAddress").Return("10.0.0.3")
+	vm.On("StartedAt").Return((*time.Time)(nil))
+	vm.On("FinishedAt").Return((*time.Time)(nil))
+	vm.On("Healthy").Return(true).Maybe()
+
+	const requests = 10
+	release := make(chan struct{})
+	vzClient := clientmocks.NewVzClientInterface(t)
+	// later calls are not failed by the mock, which would leave the requests sharing them waiting
+	vzClient.On("GetVirtualizationGroup", mock.Anything, pod.Namespace, pod.Name).
+		Run(func(mock.Arguments) { <-release }).
+		Return(&client.VirtualizationGroup{MacOSVirtualMachine: vm}, nil)
+	p := setupVZProviderWithPodInformer(t, ctx, vzClient, pod)
+
+	var wg sync.WaitGroup
+	statuses := make([]*corev1.PodStatus, requests)
+	errs := make([]error, requests)
+	for i := range requests {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			statuses[i], errs[i] = p.GetPodStatus(ctx, pod.Namespace, pod.Name)
+		}()
+	}
+	// let all the other requests join the call in flight
+	require.Eventually(t, func() bool {
+		return waitingGoroutines("getVirtualizationGroupCoalesced") == requests
+	}, 10*time.Second, 10*time.Millisecond)
+	close(release)
+	wg.Wait()
+
+	for i := range requests {
+		require.NoError(t, errs[i])
+		assert.Equal(t, "10.0.0.3", statuses[i].PodIP)
+	}
+	vzClient.AssertNumberOfCalls(t, "GetVirtualizationGroup", 1)
+
+	// later requests are not served from the coalesced call
+	_, err := p.GetPodStatus(ctx, pod.Namespace, pod.Name)
+	require.NoError(t, err)
+	vzClient.AssertNumberOfCalls(t, "GetVirtualizationGroup", 2)
+}
+
+// waitingGoroutines returns the number of goroutines whose stack contains the function.
+func waitingGoroutines(function string) int {
+	buf := make([]byte, 1<<20)
+	for {
+		n := goruntime.Stack(buf, true)
+		if n < len(buf) {
+			return strings.Count(string(buf[:n]), "."+function+"(")
+		}
+		buf = make([]byte, 2*len(buf))
+	}
+}
+
+func TestGetPodStatus_RetainFailedVMs(t *testing.T) {
+	tests := []struct {
+		name            string
+		retainFailedVMs bool
+		annotations     map[string]string
+		vmState         resource.VirtualMachineState
+		vmErr           error
+		expectDelete    bool
+	}{
+		{
+			name:            "failed pod is retained",
+			retainFailedVMs: true,
+			vmState:         resource.VirtualMachineStateFailed,
+		},
+		{
+			name:        "failed pod is retained by annotation",
+			annotations: map[string]string{client.RetainFailedVMsAnnotation: "true"},
+			vmState:     resource.VirtualMachineStateFailed,
+		},
+		{
+			name:            "annotation opts out of retention",
+			retainFailedVMs: true,
+			annotations:     map[string]string{client.RetainFailedVMsAnnotation: "false"},
+			vmState:         resource.VirtualMachineStateFailed,
+			expectDelete:    true,
+		},
+		{
+			name:            "succeeded pod is not retained",
+			retainFailedVMs: true,
+			vmState:         resource.VirtualMachineStateTerminated,
+			expectDelete:    true,
+		},
+		{
+			name:            "preempted pod is not retained",
+			retainFailedVMs: true,
+			vmState:         resource.VirtualMachineStateFailed,
+			vmErr:           resource.ErrPreempted,
+			expectDelete:    true,
+		},
+		{
+			name:            "pod exceeding its deadline is not retained",
+			retainFailedVMs: true,
+			vmState:         resource.VirtualMachineStateFailed,
+			vmErr:           resource.ErrDeadlineExceeded,
+			expectDelete:    true,
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			ctx := context.Background()
+
+			vm := vmmocks.NewVirtualMachine(t)
+			vm.On("State").Return(tc.vmState)
+			vm.On("IPAddress").Return("10.0.0.3")
+			vm.On("StartedAt").Return((*time.Time)(nil))
+			vm.On("FinishedAt").Return((*time.Time)(nil))
+			vm.On("Healthy").Return(true).Maybe()
+			vmErr := tc.vmErr
+			if vmErr == nil {
+				vmErr = assert.AnError
+			}
+			vm.On("Error").Return(vmErr).Maybe()
+
+			pod := &corev1.Pod{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:        "test-pod",
+					Namespace:   "default",
+					Annotations: tc.annotations,
+				},
+				Spec: corev1.PodSpec{
+					Containers: []corev1.Container{{Name: "macos", Image: "localhost:5000/macos:latest"}},
+				},
+			}
+
+			vzClient := clientmocks.NewVzClientInterface(t)
+			vzClient.On("GetVirtualizationGroup", mock.Anything, pod.Namespace, pod.Name).Return(&client.VirtualizationGroup{MacOSVirtualMachine: vm}, nil).Once()
+			eventRecorder := eventmocks.NewEventRecorder(t)
+			if tc.expectDelete {
+				vzClient.On("DeleteVirtualizationGroup", mock.Anything, pod.Namespace, pod.Name, provider.DefaultDeleteVZGroupGracePeriodSeconds).Return(nil).Once()
+			} else {
+				eventRecorder.On("RetainedFailedVirtualMachine", mock.Anything, "macos").Once()
+			}
+
+			fakeClient := fake.NewSimpleClientset(pod)
+			podInformerFactory := informers.NewSharedInformerFactoryWithOptions(fakeClient, 1)
+			podInformer := podInformerFactory.Core().V1().Pods().Informer()
+			podInformerFactory.Start(ctx.Done())
+			require.True(t, cache.WaitForCacheSync(ctx.Done(), podInformer.HasSynced))
+
+			p, err := provider.NewMacOSVZProvider(ctx, vzClient, provider.MacOSVZProviderConfig{
+				Platform:        defaultPlatform,
+				RetainFailedVMs: tc.retainFailedVMs,
+				K8sClient:       fakeClient,
+				EventRecorder:   eventRecorder,
+				PodsLister:      podInformerFactory.Core().V1().Pods().Lister(),
+			})
+			require.NoError(t, err)
+
+			_, err = p.GetPodStatus(ctx, pod.Namespace, pod.Name)
+			require.NoError(t, err)
+			// unexpected DeleteVirtualizationGroup calls fail the mock
+			vzClient.AssertExpectations(t)
+			eventRecorder.AssertExpectations(t)
+		})
+	}
+}
+
 func TestGetPods(t *testing.T) {
 	ctx := context.Background()
 
@@ -603,11 +959,7 @@ func TestGetContainerLogs(t *testing.T) {
 func TestRunInContainer(t *testing.T) {
 	ctx := context.Background()
 	vzClient := clientmocks.NewVzClientInterface(t)
-	providerConfig := provider.MacOSVZProviderConfig{
-		Platform: defaultPlatform,
-	}
-	p, err := provider.NewMacOSVZProvider(ctx, vzClient, providerConfig)
-	require.NoError(t, err)
+	p := setupVZProviderWithPodInformer(t, ctx, vzClient)
 
 	namespace := "default"
 	podName := "test-pod"
@@ -617,11 +969,56 @@ func TestRunInContainer(t *testing.T) {
 
 	vzClient.On("ExecuteContainerCommand", mock.Anything, namespace, podName, containerName, command, exec).Return(assert.AnError)
 
-	err = p.RunInContainer(ctx, namespace, podName, containerName, command, exec)
+	err := p.RunInContainer(ctx, namespace, podName, containerName, command, exec)
 	assert.Equal(t, assert.AnError, err)
 	vzClient.AssertExpectations(t)
 }
 
+func TestRunInContainer_ExecShell(t *testing.T) {
+	ctx := context.Background()
+	command := []string{"xcodebuild", "-version"}
+	exec := node.DiscardingExecIO()
+
+	tests := []struct {
+		name        string
+		annotations map[string]string
+		expected    []string
+	}{
+		{
+			name:     "Raw command by default",
+			expected: command,
+		},
+		{
+			name:        "Wrapped in a zsh login shell",
+			annotations: map[string]string{client.ExecShellAnnotation: "zsh"},
+			expected:    []string{"zsh", "-lc", "'xcodebuild' '-version'"},
+		},
+		{
+			name:        "Wrapped in a bash login shell",
+			annotations: map[string]string{client.ExecShellAnnotation: "bash"},
+			expected:    []string{"bash", "-lc", "'xcodebuild' '-version'"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pod := &corev1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "test-pod", Namespace: "default", Annotations: tt.annotations}}
+			vzClient := clientmocks.NewVzClientInterface(t)
+			p := setupVZProviderWithPodInformer(t, ctx, vzClient, pod)
+
+			vzClient.On("ExecuteContainerCommand", mock.Anything, "default", "test-pod", "macos", tt.expected, exec).Return(nil)
+			assert.NoError(t, p.RunInContainer(ctx, "default", "test-pod", "macos", command, exec))
+		})
+	}
+
+	t.Run("Unsupported shell", func(t *testing.T) {
+		pod := &corev1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "test-pod", Namespace: "default", Annotations: map[string]string{client.ExecShellAnnotation: "fish"}}}
+		p := setupVZProviderWithPodInformer(t, ctx, clientmocks.NewVzClientInterface(t), pod)
+
+		err := p.RunInContainer(ctx, "default", "test-pod", "macos", command, exec)
+		assert.True(t, errdefs.IsInvalidInput(err), err)
+	})
+}
+
 func TestAttachToContainer(t *testing.T) {
 	ctx := context.Background()
 	vzClient := clientmocks.NewVzClientInterface(t)
@@ -690,3 +1087,54 @@ func TestPortForward(t *testing.T) {
 	assert.Error(t, err, "PortForward should return an error")
 	vzClient.AssertExpectations(t)
 }
+
+func TestCreatePod_TracePodAnnotations(t *testing.T) {
+	ctx := context.Background()
+
+	spans := tracetest.NewSpanRecorder()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(spans)))
+	previousTracer := trace.T
+	trace.T = opentelemetry.Adapter{}
+	t.Cleanup(func() { trace.T = previousTracer })
+
+	pod := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "test-pod",
+			Namespace: "default",
+			Annotations: map[string]string{
+				"ci.example.com/build-id": "1234",
+				"ci.example.com/owner":    "mobile",
+			},
+		},
+		Spec: corev1.PodSpec{
+			AutomountServiceAccountToken: ptr.To(false),
+			Containers:                   []corev1.Container{{Name: "macos", Image: "localhost:5000/macos:latest"}},
+		},
+	}
+
+	vzClient := clientmocks.NewVzClientInterface(t)
+	vzClient.On("ValidatePod", mock.Anything, pod).Return(nil)
+	// the annotation is propagated to the spans of the virtualization group creation and the image download
+	vzClient.On("CreateVirtualizationGroup", mock.MatchedBy(func(ctx context.Context) bool {
+		return utils.TraceFields(ctx)["pod.annotation.ci.example.com/build-id"] == "1234"
+	}), pod, "", mock.Anything, mock.Anything).Return(nil)
+
+	p, err := provider.NewMacOSVZProvider(ctx, vzClient, provider.MacOSVZProviderConfig{
+		Platform:            defaultPlatform,
+		K8sClient:           fake.NewSimpleClientset(),
+		TracePodAnnotations: []string{"ci.example.com/build-id"},
+	})
+	require.NoError(t, err)
+	require.NoError(t, p.CreatePod(ctx, pod))
+
+	var attributes []attribute.KeyValue
+	for _, span := range spans.Ended() {
+		if span.Name() == "MacOSVZProvider.CreatePod" {
+			attributes = span.Attributes()
+		}
+	}
+	assert.Contains(t, attributes, attribute.String("pod.annotation.ci.example.com/build-id", "1234"))
+	for _, attr := range attributes {
+		assert.NotEqual(t, "pod.annotation.ci.example.com/owner", string(attr.Key), "annotation not configured to be traced")
+	}
+}
@@ -0,0 +1,138 @@
+package provider
+
+import (
+	"context"
+	"strconv"
+	"time"
+
+	"github.com/shirou/gopsutil/v4/disk"
+	"github.com/shirou/gopsutil/v4/mem"
+
+	"github.com/virtual-kubelet/virtual-kubelet/log"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/wait"
+)
+
+const (
+	// VMSlotsAvailableAnnotation is the node annotation advertising the number of virtual machines that can still be created.
+	VMSlotsAvailableAnnotation = "macosvz.agoda.com/vm-slots-available"
+	// VMSlotsTotalAnnotation is the node annotation advertising the maximum number of virtual machines.
+	VMSlotsTotalAnnotation = "macosvz.agoda.com/vm-slots-total"
+
+	// DefaultNodeStatusUpdateInterval is the default interval of the node status updates.
+	DefaultNodeStatusUpdateInterval = time.Minute
+	// MaxNodeStatusUpdateInterval caps the interval of the node status updates, jitter included.
+	MaxNodeStatusUpdateInterval = 5 * time.Minute
+
+	// nodeStatusUpdateJitter spreads the node status updates of the nodes over time.
+	nodeStatusUpdateJitter = 0.1
+
+	// memoryPressureThreshold is the available memory under which the node reports memory pressure,
+	// matching the default hard eviction threshold of the kubelet.
+	memoryPressureThreshold = 100 << 20
+	// diskPressureThreshold is the ratio of available disk space under which the node reports disk pressure.
+	diskPressureThreshold = 0.1
+)
+
+// NodeStatusUpdater notifies virtual-kubelet about the node status at a jittered interval. The conditions
+// are recomputed on every update, so that the API server sees fresh heartbeats and the live pressure of the host.
+type NodeStatusUpdater struct {
+	// Node is the node configured by the provider, the updates are based on.
+	Node *corev1.Node
+	// Interval is the interval of the updates, DefaultNodeStatusUpdateInterval if zero.
+	Interval time.Duration
+	// VMSlots, if set, returns the virtual machine slots advertised with the node annotations on every update.
+	VMSlots func() (available, total int)
+}
+
+// Ping reports whether the provider is healthy.
+func (u *NodeStatusUpdater) Ping(ctx context.Context) error {
+	return ctx.Err()
+}
+
+// NotifyNodeStatus starts notifying cb about the node status until the context is done.
+func (u *NodeStatusUpdater) NotifyNodeStatus(ctx context.Context, cb func(*corev1.Node)) {
+	go u.run(ctx, cb)
+}
+
+func (u *NodeStatusUpdater) run(ctx context.Context, cb func(*corev1.Node)) {
+	interval := u.Interval
+	if interval <= 0 {
+		interval = DefaultNodeStatusUpdateInterval
+	}
+
+	node := u.Node.DeepCopy()
+	timer := time.NewTimer(min(wait.Jitter(interval, nodeStatusUpdateJitter), MaxNodeStatusUpdateInterval))
+	defer timer.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-timer.C:
+		}
+
+		node = node.DeepCopy()
+		node.Status.Conditions = getNodeConditions(ctx, node.Status.Conditions)
+		if u.VMSlots != nil {
+			setVMSlotsAnnotations(node, u.VMSlots)
+		}
+		cb(node)
+
+		timer.Reset(min(wait.Jitter(interval, nodeStatusUpdateJitter), MaxNodeStatusUpdateInterval))
+	}
+}
+
+// setVMSlotsAnnotations advertises the virtual machine slots on the node, for external schedulers and dashboards.
+func setVMSlotsAnnotations(node *corev1.Node, slots func() (available, total int)) {
+	available, total := slots()
+	if node.Annotations == nil {
+		node.Annotations = make(map[string]string)
+	}
+	node.Annotations[VMSlotsAvailableAnnotation] = strconv.Itoa(available)
+	node.Annotations[VMSlotsTotalAnnotation] = strconv.Itoa(total)
+}
+
+// getNodeConditions returns a list of conditions (Ready, OutOfDisk, etc), for updates to the node status within Kubernetes.
+// The transition times of the previous conditions are kept unless their status changes.
+func getNodeConditions(ctx context.Context, previous []corev1.NodeCondition) []corev1.NodeCondition {
+	now := metav1.Now()
+	condition := func(conditionType corev1.NodeConditionType, status corev1.ConditionStatus, reason, message string) corev1.NodeCondition {
+		c := corev1.NodeCondition{
+			Type:               conditionType,
+			Status:             status,
+			LastHeartbeatTime:  now,
+			LastTransitionTime: now,
+			Reason:             reason,
+			Message:            message,
+		}
+		for _, p := range previous {
+			if p.Type == conditionType && p.Status == status {
+				c.LastTransitionTime = p.LastTransitionTime
+			}
+		}
+		return c
+	}
+
+	memoryPressure := condition(corev1.NodeMemoryPressure, corev1.ConditionFalse, "KubeletHasSufficientMemory", "kubelet has sufficient memory available")
+	if v, err := mem.VirtualMemoryWithContext(ctx); err != nil {
+		log.G(ctx).WithError(err).Warn("Error getting memory usage, skipping memory pressure check")
+	} else if v.Available < memoryPressureThreshold {
+		memoryPressure = condition(corev1.NodeMemoryPressure, corev1.ConditionTrue, "KubeletHasInsufficientMemory", "kubelet has insufficient memory available")
+	}
+
+	diskPressure := condition(corev1.NodeDiskPressure, corev1.ConditionFalse, "KubeletHasNoDiskPressure", "kubelet has no disk pressure")
+	if d, err := disk.UsageWithContext(ctx, "/"); err != nil {
+		log.G(ctx).WithError(err).Warn("Error getting disk usage, skipping disk pressure check")
+	} else if d.Total > 0 && float64(d.Free) < float64(d.Total)*diskPressureThreshold {
+		diskPressure = condition(corev1.NodeDiskPressure, corev1.ConditionTrue, "KubeletHasDiskPressure", "kubelet has disk pressure")
+	}
+
+	return []corev1.NodeCondition{
+		condition(corev1.NodeReady, corev1.ConditionTrue, "KubeletReady", "kubelet is ready."),
+		memoryPressure,
+		diskPressure,
+		condition(corev1.NodeNetworkUnavailable, corev1.ConditionFalse, "RouteCreated", "RouteController created a route"),
+	}
+}
@@ -0,0 +1,117 @@
+package provider_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/agoda-com/macOS-vz-kubelet/pkg/event"
+	"github.com/agoda-com/macOS-vz-kubelet/pkg/provider"
+	"github.com/agoda-com/macOS-vz-kubelet/pkg/resourcemanager"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestNodeStatusUpdater(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	configured := &corev1.Node{ObjectMeta: metav1.ObjectMeta{Name: "test-node"}}
+	updater := &provider.NodeStatusUpdater{Node: configured, Interval: 10 * time.Millisecond}
+	require.NoError(t, updater.Ping(ctx))
+
+	updates := make(chan *corev1.Node, 2)
+	updater.NotifyNodeStatus(ctx, func(n *corev1.Node) {
+		select {
+		case updates <- n:
+		default:
+		}
+	})
+
+	var nodes []*corev1.Node
+	for range 2 {
+		select {
+		case n := <-updates:
+			nodes = append(nodes, n)
+		case <-time.After(5 * time.Second):
+			t.Fatal("node status was not updated")
+		}
+	}
+	first, second := nodes[0], nodes[1]
+	assert.Empty(t, configured.Status.Conditions, "configured node must not be modified")
+	assert.Equal(t, "test-node", second.Name)
+
+	require.Len(t, first.Status.Conditions, 4)
+	require.Len(t, second.Status.Conditions, 4)
+	for i, c := range second.Status.Conditions {
+		prev := first.Status.Conditions[i]
+		assert.Equal(t, prev.Type, c.Type)
+		assert.True(t, c.LastHeartbeatTime.After(prev.LastHeartbeatTime.Time), "%s heartbeat should be updated", c.Type)
+		if c.Status == prev.Status {
+			assert.Equal(t, prev.LastTransitionTime, c.LastTransitionTime, "%s transition time should be kept", c.Type)
+		}
+	}
+	assert.True(t, containsConditionWithStatus(second.Status.Conditions, corev1.NodeCondition{Type: corev1.NodeReady, Status: corev1.ConditionTrue}))
+}
+
+func TestNodeStatusUpdaterVMSlots(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	// the registry never responds, so the created virtual machine stays pulling its image
+	registry := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-r.Context().Done()
+	}))
+	t.Cleanup(registry.Close)
+	c := resourcemanager.NewMacOSClient(ctx, event.LogEventRecorder{}, "", t.TempDir())
+
+	updater := &provider.NodeStatusUpdater{
+		Node:     &corev1.Node{ObjectMeta: metav1.ObjectMeta{Name: "test-node"}},
+		Interval: 10 * time.Millisecond,
+		VMSlots:  c.VirtualMachineSlots,
+	}
+	var mu sync.Mutex
+	var latest *corev1.Node
+	updater.NotifyNodeStatus(ctx, func(n *corev1.Node) {
+		mu.Lock()
+		defer mu.Unlock()
+		latest = n
+	})
+	advertised := func() map[string]string {
+		mu.Lock()
+		defer mu.Unlock()
+		if latest == nil {
+			return nil
+		}
+		return latest.Annotations
+	}
+
+	require.Eventually(t, func() bool {
+		return advertised()[provider.VMSlotsAvailableAnnotation] == "2"
+	}, 5*time.Second, 10*time.Millisecond)
+	assert.Equal(t, "2", advertised()[provider.VMSlotsTotalAnnotation])
+
+	params := resourcemanager.VirtualMachineParams{
+		UID:           "uid",
+		Image:         strings.TrimPrefix(registry.URL, "http://") + "/macos:latest",
+		Namespace:     "default",
+		Name:          "pod",
+		ContainerName: "macos",
+	}
+	require.NoError(t, c.CreateVirtualMachine(ctx, params))
+	t.Cleanup(func() { _ = c.DeleteVirtualMachine(ctx, params.Namespace, params.Name, 0) })
+
+	// the created virtual machine takes a slot on the next update
+	require.Eventually(t, func() bool {
+		return advertised()[provider.VMSlotsAvailableAnnotation] == "1"
+	}, 5*time.Second, 10*time.Millisecond)
+	assert.Equal(t, "2", advertised()[provider.VMSlotsTotalAnnotation])
+}
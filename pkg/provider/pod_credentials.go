@@ -5,30 +5,104 @@ import (
 
 	authv1 "k8s.io/api/authentication/v1"
 	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
 
-// extractPodCredentials extracts the service account token and config maps required for the Pod.
-func (p *MacOSVZProvider) extractPodCredentials(ctx context.Context, pod *corev1.Pod) (map[string]*corev1.ConfigMap, string, error) {
+// extractPodCredentials extracts the service account token, config maps and secrets required for the Pod.
+func (p *MacOSVZProvider) extractPodCredentials(ctx context.Context, pod *corev1.Pod) (map[string]*corev1.ConfigMap, map[string]*corev1.Secret, string, error) {
 	var serviceAccountToken string
 	configMaps := map[string]*corev1.ConfigMap{}
+	secrets := map[string]*corev1.Secret{}
 
 	if pod.Spec.AutomountServiceAccountToken == nil || *pod.Spec.AutomountServiceAccountToken {
 		svcProj, cmProj := findProjections(pod)
 		if err := p.populateConfigMaps(ctx, pod.Namespace, cmProj, configMaps); err != nil {
-			return nil, "", err
+			return nil, nil, "", err
 		}
 
 		if svcProj != nil {
 			token, err := p.createServiceAccountToken(ctx, pod.Namespace, pod.Spec.ServiceAccountName, svcProj)
 			if err != nil {
-				return nil, "", err
+				return nil, nil, "", err
 			}
 			serviceAccountToken = token
 		}
 	}
 
-	return configMaps, serviceAccountToken, nil
+	if err := p.populateEnvSources(ctx, pod, configMaps, secrets); err != nil {
+		return nil, nil, "", err
+	}
+
+	return configMaps, secrets, serviceAccountToken, nil
+}
+
+// populateEnvSources fetches the config maps and secrets referenced by the environment variables of the Pod's containers,
+// both from `env` and `envFrom`. Missing optional references are skipped, they are resolved once the environment is built.
+func (p *MacOSVZProvider) populateEnvSources(ctx context.Context, pod *corev1.Pod, configMaps map[string]*corev1.ConfigMap, secrets map[string]*corev1.Secret) error {
+	for _, container := range pod.Spec.Containers {
+		for _, envFrom := range container.EnvFrom {
+			if ref := envFrom.ConfigMapRef; ref != nil {
+				if err := p.populateConfigMap(ctx, pod.Namespace, ref.Name, ref.Optional, configMaps); err != nil {
+					return err
+				}
+			}
+			if ref := envFrom.SecretRef; ref != nil {
+				if err := p.populateSecret(ctx, pod.Namespace, ref.Name, ref.Optional, secrets); err != nil {
+					return err
+				}
+			}
+		}
+
+		for _, env := range container.Env {
+			if env.ValueFrom == nil {
+				continue
+			}
+			if ref := env.ValueFrom.ConfigMapKeyRef; ref != nil {
+				if err := p.populateConfigMap(ctx, pod.Namespace, ref.Name, ref.Optional, configMaps); err != nil {
+					return err
+				}
+			}
+			if ref := env.ValueFrom.SecretKeyRef; ref != nil {
+				if err := p.populateSecret(ctx, pod.Namespace, ref.Name, ref.Optional, secrets); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// populateConfigMap fetches the config map unless it is already present, skipping it if optional and not found.
+func (p *MacOSVZProvider) populateConfigMap(ctx context.Context, namespace, name string, optional *bool, configMaps map[string]*corev1.ConfigMap) error {
+	if _, ok := configMaps[name]; ok {
+		return nil
+	}
+	configMap, err := p.k8sClient.CoreV1().ConfigMaps(namespace).Get(ctx, name, metav1.GetOptions{})
+	if err != nil {
+		if errors.IsNotFound(err) && optional != nil && *optional {
+			return nil
+		}
+		return err
+	}
+	configMaps[name] = configMap
+	return nil
+}
+
+// populateSecret fetches the secret unless it is already present, skipping it if optional and not found.
+func (p *MacOSVZProvider) populateSecret(ctx context.Context, namespace, name string, optional *bool, secrets map[string]*corev1.Secret) error {
+	if _, ok := secrets[name]; ok {
+		return nil
+	}
+	secret, err := p.k8sClient.CoreV1().Secrets(namespace).Get(ctx, name, metav1.GetOptions{})
+	if err != nil {
+		if errors.IsNotFound(err) && optional != nil && *optional {
+			return nil
+		}
+		return err
+	}
+	secrets[name] = secret
+	return nil
 }
 
 // populateConfigMaps fetches and populates the config maps based on the ConfigMapProjection.
@@ -0,0 +1,62 @@
+package provider
+
+import (
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+)
+
+// qosComputeResources are the resources that determine the QoS class of a pod.
+var qosComputeResources = []corev1.ResourceName{corev1.ResourceCPU, corev1.ResourceMemory}
+
+// PodQOSClass computes the QoS class of the pod from the requests and limits of its containers,
+// following the kubelet algorithm:
+//   - BestEffort if no container has any CPU or memory request or limit,
+//   - Guaranteed if every container has CPU and memory limits and the requests equal the limits,
+//   - Burstable otherwise.
+func PodQOSClass(pod *corev1.Pod) corev1.PodQOSClass {
+	requests := corev1.ResourceList{}
+	limits := corev1.ResourceList{}
+	guaranteed := true
+
+	containers := append(append([]corev1.Container{}, pod.Spec.Containers...), pod.Spec.InitContainers...)
+	for _, c := range containers {
+		addQOSResources(requests, c.Resources.Requests)
+		if found := addQOSResources(limits, c.Resources.Limits); found != len(qosComputeResources) {
+			guaranteed = false
+		}
+	}
+
+	if len(requests) == 0 && len(limits) == 0 {
+		return corev1.PodQOSBestEffort
+	}
+	if guaranteed {
+		for name, request := range requests {
+			if limit, ok := limits[name]; !ok || limit.Cmp(request) != 0 {
+				guaranteed = false
+				break
+			}
+		}
+	}
+	if guaranteed && len(requests) == len(limits) {
+		return corev1.PodQOSGuaranteed
+	}
+	return corev1.PodQOSBurstable
+}
+
+// addQOSResources adds the positive QoS compute resource quantities to the total
+// and returns the number of such resources found.
+func addQOSResources(total, list corev1.ResourceList) (found int) {
+	for _, name := range qosComputeResources {
+		quantity, ok := list[name]
+		if !ok || quantity.Cmp(resource.Quantity{}) <= 0 {
+			continue
+		}
+		found++
+		sum := quantity.DeepCopy()
+		if current, ok := total[name]; ok {
+			sum.Add(current)
+		}
+		total[name] = sum
+	}
+	return found
+}
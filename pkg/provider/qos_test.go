@@ -0,0 +1,97 @@
+package provider_test
+
+import (
+	"testing"
+
+	"github.com/agoda-com/macOS-vz-kubelet/pkg/provider"
+
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+)
+
+func qosResources(cpu, memory string) corev1.ResourceList {
+	list := corev1.ResourceList{}
+	if cpu != "" {
+		list[corev1.ResourceCPU] = resource.MustParse(cpu)
+	}
+	if memory != "" {
+		list[corev1.ResourceMemory] = resource.MustParse(memory)
+	}
+	return list
+}
+
+func TestPodQOSClass(t *testing.T) {
+	tests := []struct {
+		name       string
+		containers []corev1.ResourceRequirements
+		expected   corev1.PodQOSClass
+	}{
+		{
+			name:       "No resources",
+			containers: []corev1.ResourceRequirements{{}, {}},
+			expected:   corev1.PodQOSBestEffort,
+		},
+		{
+			name: "Zero requests",
+			containers: []corev1.ResourceRequirements{
+				{Requests: qosResources("0", "0")},
+			},
+			expected: corev1.PodQOSBestEffort,
+		},
+		{
+			name: "Only non-compute resources",
+			containers: []corev1.ResourceRequirements{
+				{Requests: corev1.ResourceList{corev1.ResourceEphemeralStorage: resource.MustParse("1Gi")}},
+			},
+			expected: corev1.PodQOSBestEffort,
+		},
+		{
+			name: "Requests equal limits",
+			containers: []corev1.ResourceRequirements{
+				{Requests: qosResources("4", "8Gi"), Limits: qosResources("4", "8Gi")},
+				{Requests: qosResources("100m", "128Mi"), Limits: qosResources("100m", "128Mi")},
+			},
+			expected: corev1.PodQOSGuaranteed,
+		},
+		{
+			name: "Requests below limits",
+			containers: []corev1.ResourceRequirements{
+				{Requests: qosResources("2", "8Gi"), Limits: qosResources("4", "8Gi")},
+			},
+			expected: corev1.PodQOSBurstable,
+		},
+		{
+			name: "Only requests",
+			containers: []corev1.ResourceRequirements{
+				{Requests: qosResources("4", "8Gi")},
+			},
+			expected: corev1.PodQOSBurstable,
+		},
+		{
+			name: "Memory limit missing",
+			containers: []corev1.ResourceRequirements{
+				{Requests: qosResources("4", "8Gi"), Limits: qosResources("4", "")},
+			},
+			expected: corev1.PodQOSBurstable,
+		},
+		{
+			name: "One container without resources",
+			containers: []corev1.ResourceRequirements{
+				{Requests: qosResources("4", "8Gi"), Limits: qosResources("4", "8Gi")},
+				{},
+			},
+			expected: corev1.PodQOSBurstable,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pod := &corev1.Pod{}
+			for _, r := range tt.containers {
+				pod.Spec.Containers = append(pod.Spec.Containers, corev1.Container{Resources: r})
+			}
+			assert.Equal(t, tt.expected, provider.PodQOSClass(pod))
+		})
+	}
+}
@@ -0,0 +1,101 @@
+package provider
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/agoda-com/macOS-vz-kubelet/pkg/resourcemanager"
+
+	"github.com/virtual-kubelet/virtual-kubelet/errdefs"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes"
+	corev1listers "k8s.io/client-go/listers/core/v1"
+)
+
+const (
+	// SSHCredentialsSecretAnnotation is the pod annotation referencing the secret with the SSH credentials
+	// of the pod's macOS VM. The secret is expected in the pod namespace.
+	SSHCredentialsSecretAnnotation = "macosvz.agoda.com/ssh-credentials-secret"
+
+	// SSHCredentialsUsernameKey is the secret key of the SSH username.
+	SSHCredentialsUsernameKey = "username"
+	// SSHCredentialsPasswordKey is the secret key of the SSH password.
+	SSHCredentialsPasswordKey = "password"
+	// SSHCredentialsPrivateKeyKey is the secret key of the PEM encoded SSH private key.
+	SSHCredentialsPrivateKeyKey = "privateKey"
+
+	// DefaultSSHCredentialsTTL is the default duration the SSH credentials secrets are cached for.
+	DefaultSSHCredentialsTTL = 30 * time.Second
+)
+
+// SSHCredentialsCache resolves the SSH credentials of the pods referencing a secret with the
+// SSHCredentialsSecretAnnotation. The secrets are cached briefly to avoid fetching them on every exec.
+type SSHCredentialsCache struct {
+	Client    kubernetes.Interface
+	PodLister corev1listers.PodLister
+	// TTL is the duration the secrets are cached for, DefaultSSHCredentialsTTL if zero.
+	TTL time.Duration
+
+	mu      sync.Mutex
+	entries map[types.NamespacedName]sshCredentialsEntry
+}
+
+// sshCredentialsEntry is a cached SSH credentials secret.
+type sshCredentialsEntry struct {
+	creds   resourcemanager.SSHCredentials
+	expires time.Time
+}
+
+// Credentials returns the SSH credentials of the pod's macOS VM with the given name,
+// or nil if the pod does not reference a credentials secret.
+func (c *SSHCredentialsCache) Credentials(ctx context.Context, namespace, name string) (*resourcemanager.SSHCredentials, error) {
+	podName, _ := resourcemanager.SplitVirtualMachineName(name)
+	pod, err := c.PodLister.Pods(namespace).Get(podName)
+	if err != nil {
+		return nil, err
+	}
+	secretName := pod.Annotations[SSHCredentialsSecretAnnotation]
+	if secretName == "" {
+		return nil, nil
+	}
+
+	key := types.NamespacedName{Namespace: namespace, Name: secretName}
+	now := time.Now()
+
+	c.mu.Lock()
+	entry, ok := c.entries[key]
+	c.mu.Unlock()
+	if ok && now.Before(entry.expires) {
+		return &entry.creds, nil
+	}
+
+	secret, err := c.Client.CoreV1().Secrets(namespace).Get(ctx, secretName, metav1.GetOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get SSH credentials secret %s: %w", key, err)
+	}
+	creds := resourcemanager.SSHCredentials{
+		Username:   string(secret.Data[SSHCredentialsUsernameKey]),
+		Password:   string(secret.Data[SSHCredentialsPasswordKey]),
+		PrivateKey: secret.Data[SSHCredentialsPrivateKeyKey],
+	}
+	if creds.Username == "" || creds.Password == "" && len(creds.PrivateKey) == 0 {
+		return nil, errdefs.InvalidInputf("SSH credentials secret %s must contain %q and either %q or %q",
+			key, SSHCredentialsUsernameKey, SSHCredentialsPasswordKey, SSHCredentialsPrivateKeyKey)
+	}
+
+	ttl := c.TTL
+	if ttl == 0 {
+		ttl = DefaultSSHCredentialsTTL
+	}
+	c.mu.Lock()
+	if c.entries == nil {
+		c.entries = make(map[types.NamespacedName]sshCredentialsEntry)
+	}
+	c.entries[key] = sshCredentialsEntry{creds: creds, expires: now.Add(ttl)}
+	c.mu.Unlock()
+
+	return &creds, nil
+}
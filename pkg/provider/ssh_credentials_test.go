@@ -0,0 +1,71 @@
+package provider_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/virtual-kubelet/virtual-kubelet/errdefs"
+
+	"github.com/agoda-com/macOS-vz-kubelet/pkg/provider"
+	"github.com/agoda-com/macOS-vz-kubelet/pkg/resourcemanager"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/informers"
+	"k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/tools/cache"
+)
+
+func TestSSHCredentialsCache(t *testing.T) {
+	ctx := context.Background()
+
+	withSecret := &corev1.Pod{ObjectMeta: metav1.ObjectMeta{
+		Name:        "with-secret",
+		Namespace:   "default",
+		Annotations: map[string]string{provider.SSHCredentialsSecretAnnotation: "ssh"},
+	}}
+	withoutSecret := &corev1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "without-secret", Namespace: "default"}}
+	invalidSecret := &corev1.Pod{ObjectMeta: metav1.ObjectMeta{
+		Name:        "invalid-secret",
+		Namespace:   "default",
+		Annotations: map[string]string{provider.SSHCredentialsSecretAnnotation: "invalid"},
+	}}
+	secret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: "ssh", Namespace: "default"},
+		Data: map[string][]byte{
+			provider.SSHCredentialsUsernameKey: []byte("admin"),
+			provider.SSHCredentialsPasswordKey: []byte("s3cr3t"),
+		},
+	}
+	invalid := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: "invalid", Namespace: "default"},
+		Data:       map[string][]byte{provider.SSHCredentialsUsernameKey: []byte("admin")},
+	}
+
+	fakeClient := fake.NewSimpleClientset(withSecret, withoutSecret, invalidSecret, secret, invalid)
+	factory := informers.NewSharedInformerFactory(fakeClient, 0)
+	podInformer := factory.Core().V1().Pods().Informer()
+	factory.Start(ctx.Done())
+	require.True(t, cache.WaitForCacheSync(ctx.Done(), podInformer.HasSynced))
+
+	c := &provider.SSHCredentialsCache{Client: fakeClient, PodLister: factory.Core().V1().Pods().Lister()}
+
+	creds, err := c.Credentials(ctx, "default", "with-secret")
+	require.NoError(t, err)
+	assert.Equal(t, &resourcemanager.SSHCredentials{Username: "admin", Password: "s3cr3t"}, creds)
+
+	// cached secrets are not fetched again
+	require.NoError(t, fakeClient.CoreV1().Secrets("default").Delete(ctx, "ssh", metav1.DeleteOptions{}))
+	creds, err = c.Credentials(ctx, "default", "with-secret")
+	require.NoError(t, err)
+	assert.Equal(t, &resourcemanager.SSHCredentials{Username: "admin", Password: "s3cr3t"}, creds)
+
+	creds, err = c.Credentials(ctx, "default", "without-secret")
+	require.NoError(t, err)
+	assert.Nil(t, creds)
+
+	_, err = c.Credentials(ctx, "default", "invalid-secret")
+	assert.True(t, errdefs.IsInvalidInput(err), "expected invalid input error, got %v", err)
+}
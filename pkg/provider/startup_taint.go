@@ -0,0 +1,154 @@
+package provider
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/virtual-kubelet/virtual-kubelet/log"
+	"github.com/virtual-kubelet/virtual-kubelet/trace"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/util/retry"
+)
+
+const (
+	// StartupTaintKey is the key of the taint that keeps pods off the node until the provider is ready.
+	StartupTaintKey = "virtualization.fleet.agoda.com/starting"
+
+	// DefaultStartupTaintCheckInterval is the default interval of the health checks gating the startup taint removal.
+	DefaultStartupTaintCheckInterval = 5 * time.Second
+)
+
+// HealthCheck reports whether a provider subsystem is ready to run workloads.
+type HealthCheck func(ctx context.Context) error
+
+// StartupTaint keeps a taint on the node until every provider subsystem reports healthy,
+// preventing pods from being scheduled onto the node before it is able to run them.
+type StartupTaint struct {
+	NodeName string
+	Client   kubernetes.Interface
+
+	// Checks are the health checks of the provider subsystems, keyed by subsystem name.
+	Checks map[string]HealthCheck
+	// Interval is the interval of the health checks, DefaultStartupTaintCheckInterval if zero.
+	Interval time.Duration
+}
+
+// Taint returns the startup taint.
+func (s *StartupTaint) Taint() corev1.Taint {
+	return corev1.Taint{
+		Key:    StartupTaintKey,
+		Effect: corev1.TaintEffectNoSchedule,
+	}
+}
+
+// Apply adds the startup taint to the node if it is registered already.
+// Nodes registered afterwards are expected to be created with the taint.
+func (s *StartupTaint) Apply(ctx context.Context) (err error) {
+	ctx, span := trace.StartSpan(ctx, "StartupTaint.Apply")
+	defer func() {
+		span.SetStatus(err)
+		span.End()
+	}()
+
+	err = s.updateTaints(ctx, func(taints []corev1.Taint) ([]corev1.Taint, bool) {
+		for _, t := range taints {
+			if t.Key == StartupTaintKey {
+				return taints, false
+			}
+		}
+		return append(taints, s.Taint()), true
+	})
+	if errors.IsNotFound(err) {
+		return nil
+	}
+	return err
+}
+
+// Healthy runs the health checks and returns the error of the first unhealthy subsystem, if any.
+func (s *StartupTaint) Healthy(ctx context.Context) error {
+	names := make([]string, 0, len(s.Checks))
+	for name := range s.Checks {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		if err := s.Checks[name](ctx); err != nil {
+			return fmt.Errorf("%s is not healthy: %w", name, err)
+		}
+	}
+	return nil
+}
+
+// Remove removes the startup taint from the node.
+func (s *StartupTaint) Remove(ctx context.Context) (err error) {
+	ctx, span := trace.StartSpan(ctx, "StartupTaint.Remove")
+	defer func() {
+		span.SetStatus(err)
+		span.End()
+	}()
+
+	return s.updateTaints(ctx, func(taints []corev1.Taint) ([]corev1.Taint, bool) {
+		filtered := make([]corev1.Taint, 0, len(taints))
+		for _, t := range taints {
+			if t.Key != StartupTaintKey {
+				filtered = append(filtered, t)
+			}
+		}
+		return filtered, len(filtered) != len(taints)
+	})
+}
+
+// Run waits until every subsystem reports healthy and removes the startup taint from the node.
+// It returns once the taint is removed or the context is done.
+func (s *StartupTaint) Run(ctx context.Context) error {
+	interval := s.Interval
+	if interval == 0 {
+		interval = DefaultStartupTaintCheckInterval
+	}
+	logger := log.G(ctx)
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		if err := s.Healthy(ctx); err != nil {
+			logger.WithError(err).Debug("Keeping the startup taint")
+		} else if err := s.Remove(ctx); err != nil {
+			logger.WithError(err).Warn("Failed to remove the startup taint")
+		} else {
+			logger.Info("Provider subsystems are healthy, removed the startup taint")
+			return nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+// updateTaints updates the taints of the node, retrying on conflicts.
+// The update function returns the new taints and whether they changed.
+func (s *StartupTaint) updateTaints(ctx context.Context, update func([]corev1.Taint) ([]corev1.Taint, bool)) error {
+	return retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		node, err := s.Client.CoreV1().Nodes().Get(ctx, s.NodeName, metav1.GetOptions{})
+		if err != nil {
+			return err
+		}
+
+		taints, changed := update(node.Spec.Taints)
+		if !changed {
+			return nil
+		}
+		node.Spec.Taints = taints
+		_, err = s.Client.CoreV1().Nodes().Update(ctx, node, metav1.UpdateOptions{})
+		return err
+	})
+}
@@ -0,0 +1,120 @@
+package provider_test
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/agoda-com/macOS-vz-kubelet/pkg/provider"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func nodeTaintKeys(t *testing.T, ctx context.Context, kcl *fake.Clientset, name string) []string {
+	t.Helper()
+
+	node, err := kcl.CoreV1().Nodes().Get(ctx, name, metav1.GetOptions{})
+	require.NoError(t, err)
+	keys := make([]string, 0, len(node.Spec.Taints))
+	for _, taint := range node.Spec.Taints {
+		keys = append(keys, taint.Key)
+	}
+	return keys
+}
+
+func TestStartupTaint(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	providerTaint := corev1.Taint{Key: "virtual-kubelet.io/provider", Value: "macos-vz", Effect: corev1.TaintEffectNoSchedule}
+	kcl := fake.NewSimpleClientset(&corev1.Node{
+		ObjectMeta: metav1.ObjectMeta{Name: "test-node"},
+		Spec:       corev1.NodeSpec{Taints: []corev1.Taint{providerTaint}},
+	})
+
+	dockerReady := atomic.Bool{}
+	cacheChecks := atomic.Int32{}
+	checked := make(chan struct{}, 1)
+	st := &provider.StartupTaint{
+		NodeName: "test-node",
+		Client:   kcl,
+		Checks: map[string]provider.HealthCheck{
+			"docker": func(context.Context) error {
+				defer func() {
+					select {
+					case checked <- struct{}{}:
+					default:
+					}
+				}()
+				if !dockerReady.Load() {
+					return errors.New("docker is not running")
+				}
+				return nil
+			},
+			"cache": func(context.Context) error {
+				cacheChecks.Add(1)
+				return nil
+			},
+		},
+		Interval: time.Millisecond,
+	}
+
+	// Taint is added to the already registered node, only once
+	require.NoError(t, st.Apply(ctx))
+	require.NoError(t, st.Apply(ctx))
+	assert.Equal(t, []string{providerTaint.Key, provider.StartupTaintKey}, nodeTaintKeys(t, ctx, kcl, "test-node"))
+
+	done := make(chan error, 1)
+	go func() {
+		done <- st.Run(ctx)
+	}()
+
+	// Taint is kept while a subsystem is not healthy
+	for i := 0; i < 3; i++ {
+		<-checked
+	}
+	assert.Contains(t, nodeTaintKeys(t, ctx, kcl, "test-node"), provider.StartupTaintKey)
+	assert.Positive(t, cacheChecks.Load())
+
+	// Taint is removed once all subsystems are healthy, other taints are preserved
+	dockerReady.Store(true)
+	require.NoError(t, <-done)
+	assert.Equal(t, []string{providerTaint.Key}, nodeTaintKeys(t, ctx, kcl, "test-node"))
+}
+
+func TestStartupTaint_NodeNotRegistered(t *testing.T) {
+	st := &provider.StartupTaint{
+		NodeName: "test-node",
+		Client:   fake.NewSimpleClientset(),
+	}
+
+	// Nodes registered later are created with the taint, so a missing node is not an error
+	assert.NoError(t, st.Apply(context.Background()))
+}
+
+func TestStartupTaint_ContextDone(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	kcl := fake.NewSimpleClientset(&corev1.Node{
+		ObjectMeta: metav1.ObjectMeta{Name: "test-node"},
+		Spec:       corev1.NodeSpec{Taints: []corev1.Taint{{Key: provider.StartupTaintKey, Effect: corev1.TaintEffectNoSchedule}}},
+	})
+	st := &provider.StartupTaint{
+		NodeName: "test-node",
+		Client:   kcl,
+		Checks: map[string]provider.HealthCheck{
+			"docker": func(context.Context) error { return errors.New("docker is not running") },
+		},
+	}
+
+	assert.ErrorIs(t, st.Run(ctx), context.Canceled)
+	assert.Equal(t, []string{provider.StartupTaintKey}, nodeTaintKeys(t, context.Background(), kcl, "test-node"))
+}
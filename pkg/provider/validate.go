@@ -0,0 +1,60 @@
+package provider
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/virtual-kubelet/virtual-kubelet/errdefs"
+	"github.com/virtual-kubelet/virtual-kubelet/log"
+	"github.com/virtual-kubelet/virtual-kubelet/trace"
+	corev1 "k8s.io/api/core/v1"
+)
+
+// ValidatePodPath is the path of the route that checks whether a pod would be admitted, without creating it.
+const ValidatePodPath = "/validate"
+
+// PodValidation is the response of the route validating a pod.
+type PodValidation struct {
+	Allowed bool   `json:"allowed"`
+	Message string `json:"message,omitempty"`
+}
+
+// ValidatePodHandler returns a handler that validates the pod posted as JSON. It responds with
+// 200 if the pod would be admitted, and 422 along with every problem found otherwise.
+func (p *MacOSVZProvider) ValidatePodHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx, span := trace.StartSpan(r.Context(), "MacOSVZProvider.ValidatePod")
+		defer span.End()
+
+		if r.Method != http.MethodPost {
+			w.Header().Set("Allow", http.MethodPost)
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		pod := &corev1.Pod{}
+		if err := json.NewDecoder(r.Body).Decode(pod); err != nil {
+			http.Error(w, "invalid pod: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		validation := PodValidation{Allowed: true}
+		status := http.StatusOK
+		if err := p.vzClient.ValidatePod(ctx, pod); err != nil {
+			span.SetStatus(err)
+			if !errdefs.IsInvalidInput(err) {
+				log.G(ctx).WithError(err).Error("Failed to validate pod")
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+			validation = PodValidation{Message: err.Error()}
+			status = http.StatusUnprocessableEntity
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(status)
+		if err := json.NewEncoder(w).Encode(validation); err != nil {
+			log.G(ctx).WithError(err).Debug("Failed to write validation response")
+		}
+	})
+}
@@ -0,0 +1,79 @@
+package provider_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"github.com/virtual-kubelet/virtual-kubelet/errdefs"
+
+	clientmocks "github.com/agoda-com/macOS-vz-kubelet/pkg/client/mocks"
+	"github.com/agoda-com/macOS-vz-kubelet/pkg/provider"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestValidatePodHandler(t *testing.T) {
+	ctx := context.Background()
+
+	pod := &corev1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "test-pod", Namespace: "default"}}
+	body, err := json.Marshal(pod)
+	require.NoError(t, err)
+
+	tests := []struct {
+		name        string
+		validateErr error
+		status      int
+		expected    provider.PodValidation
+	}{
+		{
+			name:     "Admittable pod",
+			status:   http.StatusOK,
+			expected: provider.PodValidation{Allowed: true},
+		},
+		{
+			name:        "Rejected pod",
+			validateErr: errdefs.InvalidInput("pod default/test-pod cannot be admitted: regular containers are not supported"),
+			status:      http.StatusUnprocessableEntity,
+			expected:    provider.PodValidation{Message: "pod default/test-pod cannot be admitted: regular containers are not supported"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			vzClient := clientmocks.NewVzClientInterface(t)
+			vzClient.On("ValidatePod", mock.Anything, mock.MatchedBy(func(p *corev1.Pod) bool {
+				return p.Namespace == "default" && p.Name == "test-pod"
+			})).Return(tt.validateErr)
+			p := setupVZProviderWithPodInformer(t, ctx, vzClient)
+
+			rec := httptest.NewRecorder()
+			p.ValidatePodHandler().ServeHTTP(rec, httptest.NewRequest(http.MethodPost, provider.ValidatePodPath, bytes.NewReader(body)))
+
+			assert.Equal(t, tt.status, rec.Code)
+			var validation provider.PodValidation
+			require.NoError(t, json.NewDecoder(rec.Body).Decode(&validation))
+			assert.Equal(t, tt.expected, validation)
+		})
+	}
+}
+
+func TestValidatePodHandler_BadRequest(t *testing.T) {
+	ctx := context.Background()
+	p := setupVZProviderWithPodInformer(t, ctx, clientmocks.NewVzClientInterface(t))
+
+	rec := httptest.NewRecorder()
+	p.ValidatePodHandler().ServeHTTP(rec, httptest.NewRequest(http.MethodPost, provider.ValidatePodPath, bytes.NewBufferString("{")))
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+
+	rec = httptest.NewRecorder()
+	p.ValidatePodHandler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, provider.ValidatePodPath, nil))
+	assert.Equal(t, http.StatusMethodNotAllowed, rec.Code)
+}
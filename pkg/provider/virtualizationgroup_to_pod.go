@@ -2,13 +2,17 @@ package provider
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"maps"
+	"slices"
 	"time"
 
 	"github.com/agoda-com/macOS-vz-kubelet/internal/utils"
 	"github.com/agoda-com/macOS-vz-kubelet/pkg/client"
 	"github.com/agoda-com/macOS-vz-kubelet/pkg/resource"
 
+	"github.com/docker/go-units"
 	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
@@ -39,32 +43,25 @@ func (p *MacOSVZProvider) buildPodStatus(_ context.Context, vg *client.Virtualiz
 
 	for i, c := range pod.Spec.Containers {
 		// vz: always assume that first container is macOS container
+		vm, isVM := vg.AdditionalVirtualMachines[c.Name]
 		if i == 0 {
-			state := macOSVM.State()
-			started := podIp != "" // TODO: this needs to indicate whether postStart hook has finished
-			ready := state == resource.VirtualMachineStateRunning
-
-			if startedAt := macOSVM.StartedAt(); startedAt != nil {
-				firstContainerStartTime = *startedAt
-				lastUpdateTime = firstContainerStartTime
+			vm, isVM = macOSVM, true
+		}
+		if isVM {
+			if startedAt := vm.StartedAt(); startedAt != nil {
+				if startedAt.Before(firstContainerStartTime) || firstContainerStartTime.IsZero() {
+					firstContainerStartTime = *startedAt
+				}
+				if startedAt.After(lastUpdateTime) {
+					lastUpdateTime = *startedAt
+				}
 			}
-			if finishedAt := macOSVM.FinishedAt(); finishedAt != nil {
+			if finishedAt := vm.FinishedAt(); finishedAt != nil && finishedAt.After(lastUpdateTime) {
 				lastUpdateTime = *finishedAt
 			}
 
-			containerStatus := corev1.ContainerStatus{
-				Name:         c.Name,
-				State:        vmToContainerState(macOSVM, pod.CreationTimestamp.Time),
-				Ready:        ready,
-				Started:      &started,
-				RestartCount: 0,
-				Image:        c.Image,
-				ImageID:      "",
-				ContainerID:  utils.GetContainerID(resource.MacOSRuntime, c.Name),
-			}
-
 			// Add the container status to the list.
-			containerStatuses = append(containerStatuses, containerStatus)
+			containerStatuses = append(containerStatuses, vmToContainerStatus(c, vm, pod.CreationTimestamp.Time))
 			continue
 		}
 
@@ -82,11 +79,15 @@ func (p *MacOSVZProvider) buildPodStatus(_ context.Context, vg *client.Virtualiz
 			State:        containerToContainerState(container, pod.CreationTimestamp.Time),
 			Ready:        ready,
 			Started:      &started,
-			RestartCount: 0,
+			RestartCount: int32(container.RestartCount),
 			Image:        c.Image,
 			ImageID:      "",
 			ContainerID:  utils.GetContainerID(resource.ContainerRuntime, c.Name),
 		}
+		if container.LastState != nil {
+			// the state of the previous run tells why the container was restarted
+			containerStatus.LastTerminationState = containerToContainerState(resource.Container{State: *container.LastState}, pod.CreationTimestamp.Time)
+		}
 
 		startedAt := container.State.StartedAt
 		finishedAt := container.State.FinishedAt
@@ -110,11 +111,13 @@ func (p *MacOSVZProvider) buildPodStatus(_ context.Context, vg *client.Virtualiz
 	if !firstContainerStartTime.IsZero() {
 		startTime = &metav1.Time{Time: firstContainerStartTime}
 	}
+	reason, message := groupFailureReason(vg)
 	return &corev1.PodStatus{
 		Phase:             getPodPhaseFromVirtualizationGroup(vg),
 		Conditions:        getPodConditionsFromVirtualizationGroup(vg, pod.CreationTimestamp.Time, firstContainerStartTime, lastUpdateTime),
-		Message:           "",
-		Reason:            "",
+		Message:           message,
+		Reason:            reason,
+		QOSClass:          PodQOSClass(pod),
 		HostIP:            p.nodeIPAddress,
 		PodIP:             podIp,
 		StartTime:         startTime,
@@ -122,6 +125,101 @@ func (p *MacOSVZProvider) buildPodStatus(_ context.Context, vg *client.Virtualiz
 	}
 }
 
+// vmToContainerStatus converts the state of the macOS VM to the Kubernetes status of its container.
+func vmToContainerStatus(c corev1.Container, vm resource.VirtualMachine, podCreationTime time.Time) corev1.ContainerStatus {
+	started := vm.IPAddress() != "" // TODO: this needs to indicate whether postStart hook has finished
+	ready := vm.State() == resource.VirtualMachineStateRunning && vm.Healthy()
+
+	return corev1.ContainerStatus{
+		Name:         c.Name,
+		State:        vmToContainerState(vm, podCreationTime),
+		Ready:        ready,
+		Started:      &started,
+		RestartCount: 0,
+		Image:        c.Image,
+		ImageID:      "",
+		ContainerID:  utils.GetContainerID(resource.MacOSRuntime, c.Name),
+	}
+}
+
+// groupVirtualMachines returns the macOS VMs of the virtualization group, the first container's VM first.
+func groupVirtualMachines(vg *client.VirtualizationGroup) []resource.VirtualMachine {
+	vms := []resource.VirtualMachine{vg.MacOSVirtualMachine}
+	for _, containerName := range slices.Sorted(maps.Keys(vg.AdditionalVirtualMachines)) {
+		vms = append(vms, vg.AdditionalVirtualMachines[containerName])
+	}
+	return vms
+}
+
+// virtualMachineStatePriority orders the VM states by how much they determine the state of the group,
+// so that a single failed VM fails the group and a single pending VM keeps the group pending.
+var virtualMachineStatePriority = map[resource.VirtualMachineState]int{
+	resource.VirtualMachineStateFailed:      0,
+	resource.VirtualMachineStatePreparing:   1,
+	resource.VirtualMachineStateStarting:    2,
+	resource.VirtualMachineStateTerminating: 3,
+	resource.VirtualMachineStateRunning:     4,
+	resource.VirtualMachineStateTerminated:  5,
+}
+
+// groupVirtualMachineState aggregates the states of the macOS VMs of the virtualization group,
+// and reports whether all of them have an IP address.
+func groupVirtualMachineState(vg *client.VirtualizationGroup) (state resource.VirtualMachineState, hasIP bool) {
+	vms := groupVirtualMachines(vg)
+	state, hasIP = vms[0].State(), true
+	for _, vm := range vms {
+		if s := vm.State(); virtualMachineStatePriority[s] < virtualMachineStatePriority[state] {
+			state = s
+		}
+		hasIP = hasIP && vm.IPAddress() != ""
+	}
+	return state, hasIP
+}
+
+// groupVirtualMachinesHealthy reports whether the guest agents of all the macOS VMs of the virtualization group
+// report them healthy, VMs without a guest agent are always healthy.
+func groupVirtualMachinesHealthy(vg *client.VirtualizationGroup) bool {
+	for _, vm := range groupVirtualMachines(vg) {
+		if !vm.Healthy() {
+			return false
+		}
+	}
+	return true
+}
+
+// groupFailureReason returns the reason and message of the first macOS VM of the group failed by the provider on purpose.
+func groupFailureReason(vg *client.VirtualizationGroup) (reason, message string) {
+	for _, vm := range groupVirtualMachines(vg) {
+		if reason, message = failureReason(vm); reason != "" {
+			return reason, message
+		}
+	}
+	return "", ""
+}
+
+// failureReason returns the reason and message of a macOS VM failed by the provider on purpose,
+// e.g. after exceeding its maximum lifetime, the active deadline of the pod or the readiness timeout, or crashed.
+func failureReason(vm resource.VirtualMachine) (reason, message string) {
+	if vm.State() != resource.VirtualMachineStateFailed {
+		return "", ""
+	}
+	switch err := vm.Error(); {
+	case errors.Is(err, resource.ErrMaxLifetimeExceeded):
+		return MaxLifetimeExceededReason, "VM was recycled after exceeding its maximum lifetime"
+	case errors.Is(err, resource.ErrDeadlineExceeded):
+		return DeadlineExceededReason, "Pod was active on the node longer than the specified deadline"
+	case errors.Is(err, resource.ErrPreempted):
+		return PreemptedReason, "Preempted in order to admit a higher priority pod"
+	case errors.Is(err, resource.ErrCrashed):
+		return VirtualMachineCrashedReason, "VM was stopped by Virtualization.framework because of an error"
+	case errors.Is(err, resource.ErrReadinessTimeout):
+		return ReadinessTimeoutReason, "VM did not accept SSH connections within the readiness timeout"
+	case errors.Is(err, resource.ErrEphemeralStorageExceeded):
+		return EvictedReason, "VM exceeded the ephemeral storage limit of its container"
+	}
+	return "", ""
+}
+
 // vmToContainerState converts the macOS VM state to a Kubernetes container state.
 func vmToContainerState(vm resource.VirtualMachine, podCreationTime time.Time) corev1.ContainerState {
 	startTime := podCreationTime
@@ -138,7 +236,7 @@ func vmToContainerState(vm resource.VirtualMachine, podCreationTime time.Time) c
 		return corev1.ContainerState{
 			Waiting: &corev1.ContainerStateWaiting{
 				Reason:  "Downloading",
-				Message: "VM is downloading image from the registry",
+				Message: downloadingMessage(vm.DownloadProgress()),
 			},
 		}
 	case resource.VirtualMachineStateStarting:
@@ -165,10 +263,19 @@ func vmToContainerState(vm resource.VirtualMachine, podCreationTime time.Time) c
 			},
 		}
 	case resource.VirtualMachineStateFailed:
+		reason := "Error"
+		if r, _ := failureReason(vm); r != "" {
+			reason = r
+		}
+		exitCode := int32(1)
+		var exitErr *resource.CommandExitError
+		if errors.As(vm.Error(), &exitErr) {
+			exitCode = int32(exitErr.ExitCode)
+		}
 		return corev1.ContainerState{
 			Terminated: &corev1.ContainerStateTerminated{
-				ExitCode:   1,
-				Reason:     "Error",
+				ExitCode:   exitCode,
+				Reason:     reason,
 				Message:    fmt.Sprintf("VM has failed: %v", vm.Error()),
 				StartedAt:  metav1.NewTime(startTime),
 				FinishedAt: metav1.NewTime(finishTime),
@@ -179,6 +286,25 @@ func vmToContainerState(vm resource.VirtualMachine, podCreationTime time.Time) c
 	return corev1.ContainerState{}
 }
 
+// downloadingMessage returns the waiting message of a VM that is downloading its image,
+// including the download progress when available.
+func downloadingMessage(progress *resource.DownloadProgress) string {
+	const message = "VM is downloading image from the registry"
+	switch {
+	case progress == nil || progress.Completed <= 0 && progress.Total <= 0:
+		return message
+	case progress.Total <= 0:
+		return fmt.Sprintf("%s (%s)", message, units.HumanSize(float64(progress.Completed)))
+	default:
+		completed := min(progress.Completed, progress.Total)
+		return fmt.Sprintf("%s (%d%%, %s/%s)", message,
+			completed*100/progress.Total,
+			units.HumanSize(float64(completed)),
+			units.HumanSize(float64(progress.Total)),
+		)
+	}
+}
+
 // containerToContainerState converts the container state to a Kubernetes container state.
 func containerToContainerState(container resource.Container, podCreationTime time.Time) corev1.ContainerState {
 	startTime := podCreationTime
@@ -280,13 +406,12 @@ func getContainerWithName(name string, list []resource.Container) (resource.Cont
 
 // getPodPhaseFromVirtualizationGroup determines the pod phase based on the state of the virtualization group.
 func getPodPhaseFromVirtualizationGroup(vg *client.VirtualizationGroup) corev1.PodPhase {
-	// Get the macOS VM and group containers
-	macOSVM := vg.MacOSVirtualMachine
+	// Get the macOS VMs state and group containers
+	vmState, hasIP := groupVirtualMachineState(vg)
 	groupContainers := vg.Containers
-	hasIP := macOSVM.IPAddress() != ""
 
-	// Determine the pod phase based on the macOS VM state
-	switch macOSVM.State() {
+	// Determine the pod phase based on the macOS VMs state
+	switch vmState {
 	case resource.VirtualMachineStatePreparing, resource.VirtualMachineStateStarting:
 		return corev1.PodPending
 	case resource.VirtualMachineStateTerminated:
@@ -331,8 +456,8 @@ func getPodPhaseFromVirtualizationGroup(vg *client.VirtualizationGroup) corev1.P
 
 // getPodConditionsFromVirtualizationGroup determines the pod conditions based on the state of the virtualization group.
 func getPodConditionsFromVirtualizationGroup(vg *client.VirtualizationGroup, podCreationTime, firstContainerStartTime, lastUpdateTime time.Time) []corev1.PodCondition {
-	// Get the macOS VM and group containers
-	macOSVM := vg.MacOSVirtualMachine
+	// Get the macOS VMs state and group containers
+	vmState, _ := groupVirtualMachineState(vg)
 	groupContainers := vg.Containers
 
 	// Initialize pod conditions
@@ -360,8 +485,8 @@ func getPodConditionsFromVirtualizationGroup(vg *client.VirtualizationGroup, pod
 		LastTransitionTime: metav1.Time{Time: lastUpdateTime},
 	}
 
-	// Check macOS VM state
-	switch macOSVM.State() {
+	// Check macOS VMs state
+	switch vmState {
 	case resource.VirtualMachineStatePreparing, resource.VirtualMachineStateStarting:
 		// Pod is not yet initialized or ready
 		initializedCondition.Status = corev1.ConditionFalse
@@ -396,9 +521,12 @@ func getPodConditionsFromVirtualizationGroup(vg *client.VirtualizationGroup, pod
 		}
 
 		if allContainersRunning {
-			// Pod is initialized and ready
+			// Pod is initialized and ready, unless a guest agent reports its VM unhealthy
 			initializedCondition.Status = corev1.ConditionTrue
 			readyCondition.Status = corev1.ConditionTrue
+			if !groupVirtualMachinesHealthy(vg) {
+				readyCondition.Status = corev1.ConditionFalse
+			}
 		}
 	}
 
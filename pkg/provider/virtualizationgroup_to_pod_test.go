@@ -46,6 +46,7 @@ func TestGetPodStatus(t *testing.T) {
 		vmStartedAt       time.Time
 		vmFinishedAt      time.Time
 		vmError           error
+		vmProgress        *resource.DownloadProgress
 		containerStates   []resource.ContainerState
 		expectForceDelete bool
 	}{
@@ -62,6 +63,18 @@ func TestGetPodStatus(t *testing.T) {
 				{Status: resource.ContainerStatusRunning, StartedAt: fakeTime},
 			},
 		},
+		{
+			name:       "VM preparing/download in progress",
+			containers: oneContainer,
+			vmState:    resource.VirtualMachineStatePreparing,
+			vmProgress: &resource.DownloadProgress{Completed: 1_200_000_000, Total: 4_800_000_000},
+		},
+		{
+			name:       "VM preparing/download size unknown",
+			containers: oneContainer,
+			vmState:    resource.VirtualMachineStatePreparing,
+			vmProgress: &resource.DownloadProgress{Completed: 512_000},
+		},
 		{
 			name:       "VM starting/no containers",
 			containers: oneContainer,
@@ -223,6 +236,61 @@ func TestGetPodStatus(t *testing.T) {
 			vmError:           assert.AnError,
 			expectForceDelete: true,
 		},
+		{
+			name:              "VM failed/max lifetime exceeded",
+			containers:        oneContainer,
+			vmState:           resource.VirtualMachineStateFailed,
+			vmIP:              "10.0.0.3",
+			vmStartedAt:       fakeTime,
+			vmError:           resource.ErrMaxLifetimeExceeded,
+			expectForceDelete: true,
+		},
+		{
+			name:              "VM failed/active deadline exceeded",
+			containers:        oneContainer,
+			vmState:           resource.VirtualMachineStateFailed,
+			vmIP:              "10.0.0.3",
+			vmStartedAt:       fakeTime,
+			vmError:           resource.ErrDeadlineExceeded,
+			expectForceDelete: true,
+		},
+		{
+			name:              "VM failed/preempted",
+			containers:        oneContainer,
+			vmState:           resource.VirtualMachineStateFailed,
+			vmIP:              "10.0.0.3",
+			vmStartedAt:       fakeTime,
+			vmError:           resource.ErrPreempted,
+			expectForceDelete: true,
+		},
+		{
+			name:              "VM failed/readiness timeout",
+			containers:        oneContainer,
+			vmState:           resource.VirtualMachineStateFailed,
+			vmIP:              "10.0.0.3",
+			vmStartedAt:       fakeTime,
+			vmError:           resource.ErrReadinessTimeout,
+			expectForceDelete: true,
+		},
+		{
+			name:              "VM failed/ephemeral storage exceeded",
+			containers:        oneContainer,
+			vmState:           resource.VirtualMachineStateFailed,
+			vmIP:              "10.0.0.3",
+			vmStartedAt:       fakeTime,
+			vmError:           resource.ErrEphemeralStorageExceeded,
+			expectForceDelete: true,
+		},
+		{
+			name:              "VM failed/crashed",
+			containers:        oneContainer,
+			vmState:           resource.VirtualMachineStateFailed,
+			vmIP:              "10.0.0.3",
+			vmStartedAt:       fakeTime,
+			vmFinishedAt:      fakeTime.Add(time.Minute),
+			vmError:           resource.ErrCrashed,
+			expectForceDelete: true,
+		},
 		{
 			name:         "VM lost/no containers",
 			containers:   oneContainer,
@@ -249,9 +317,13 @@ func TestGetPodStatus(t *testing.T) {
 			}
 			vm.On("StartedAt").Return(startedAt)
 			vm.On("FinishedAt").Return(finishedAt)
+			vm.On("Healthy").Return(true).Maybe()
 			if tc.vmError != nil {
 				vm.On("Error").Return(tc.vmError)
 			}
+			if tc.vmState == resource.VirtualMachineStatePreparing {
+				vm.On("DownloadProgress").Return(tc.vmProgress)
+			}
 
 			containers := make([]resource.Container, len(tc.containerStates))
 			for i, state := range tc.containerStates {
@@ -298,6 +370,211 @@ func TestGetPodStatus(t *testing.T) {
 	}
 }
 
+func TestGetPodStatus_MultipleVirtualMachines(t *testing.T) {
+	fakeTime := time.Date(2012, 12, 12, 12, 12, 12, 0, time.UTC)
+
+	newVM := func(t *testing.T, state resource.VirtualMachineState, ip string, startedAt *time.Time) *vmmocks.VirtualMachine {
+		vm := vmmocks.NewVirtualMachine(t)
+		vm.On("State").Return(state, nil)
+		vm.On("IPAddress").Return(ip, nil)
+		vm.On("StartedAt").Return(startedAt)
+		vm.On("FinishedAt").Return((*time.Time)(nil))
+		vm.On("Healthy").Return(true).Maybe()
+		return vm
+	}
+
+	tests := []struct {
+		name          string
+		additionalVM  func(t *testing.T) *vmmocks.VirtualMachine
+		expectedPhase corev1.PodPhase
+	}{
+		{
+			name: "all VMs running",
+			additionalVM: func(t *testing.T) *vmmocks.VirtualMachine {
+				startedAt := fakeTime.Add(time.Minute)
+				return newVM(t, resource.VirtualMachineStateRunning, "10.0.0.4", &startedAt)
+			},
+			expectedPhase: corev1.PodRunning,
+		},
+		{
+			name: "additional VM starting",
+			additionalVM: func(t *testing.T) *vmmocks.VirtualMachine {
+				return newVM(t, resource.VirtualMachineStateStarting, "", nil)
+			},
+			expectedPhase: corev1.PodPending,
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			ctx := context.Background()
+
+			vg := &client.VirtualizationGroup{
+				MacOSVirtualMachine: newVM(t, resource.VirtualMachineStateRunning, "10.0.0.3", &fakeTime),
+				AdditionalVirtualMachines: map[string]resource.VirtualMachine{
+					"macos-1": tc.additionalVM(t),
+				},
+				Containers: []resource.Container{
+					{Name: "sidecar", State: resource.ContainerState{Status: resource.ContainerStatusRunning, StartedAt: fakeTime}},
+				},
+			}
+
+			pod := &corev1.Pod{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:        "test-pod",
+					Namespace:   "default",
+					Annotations: map[string]string{client.MacOSContainersAnnotation: "macos-0,macos-1"},
+				},
+				Spec: corev1.PodSpec{
+					Containers: []corev1.Container{
+						{Name: "macos-0", Image: "localhost:5000/macos:latest"},
+						{Name: "macos-1", Image: "localhost:5000/macos:latest"},
+						{Name: "sidecar", Image: "localhost:5000/sidecar:1.27.1"},
+					},
+				},
+			}
+
+			vzClient := clientmocks.NewVzClientInterface(t)
+			vzClient.On("GetVirtualizationGroup", mock.Anything, pod.Namespace, pod.Name).Return(vg, nil).Once()
+
+			p := setupVZProviderWithPodInformer(t, ctx, vzClient, pod)
+
+			ps, err := p.GetPodStatus(ctx, pod.Namespace, pod.Name)
+			require.NoError(t, err)
+
+			assert.Equal(t, tc.expectedPhase, ps.Phase)
+			require.Len(t, ps.ContainerStatuses, 3)
+			golden.Assert(t, marshal(t, ps), t.Name()+".golden.yaml")
+		})
+	}
+}
+
+func TestGetPodStatus_Command(t *testing.T) {
+	startedAt := time.Date(2012, 12, 12, 12, 12, 12, 0, time.UTC)
+	finishedAt := startedAt.Add(time.Minute)
+
+	tests := []struct {
+		name             string
+		commandErr       error
+		vmState          resource.VirtualMachineState
+		expectedPhase    corev1.PodPhase
+		expectedExitCode int32
+	}{
+		{
+			name:             "command exited with zero",
+			vmState:          resource.VirtualMachineStateTerminated,
+			expectedPhase:    corev1.PodSucceeded,
+			expectedExitCode: 0,
+		},
+		{
+			name:             "command exited with non-zero",
+			commandErr:       &resource.CommandExitError{ExitCode: 3},
+			vmState:          resource.VirtualMachineStateFailed,
+			expectedPhase:    corev1.PodFailed,
+			expectedExitCode: 3,
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			ctx := context.Background()
+
+			vm := vmmocks.NewVirtualMachine(t)
+			vm.On("State").Return(tc.vmState)
+			vm.On("IPAddress").Return("10.0.0.3")
+			vm.On("StartedAt").Return(&startedAt)
+			vm.On("FinishedAt").Return(&finishedAt)
+			vm.On("Healthy").Return(true).Maybe()
+			vm.On("Error").Return(tc.commandErr).Maybe()
+
+			pod := &corev1.Pod{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "test-pod",
+					Namespace: "default",
+				},
+				Spec: corev1.PodSpec{
+					Containers: []corev1.Container{
+						{Name: "macos", Image: "localhost:5000/macos:latest", Command: []string{"/usr/bin/false"}},
+					},
+				},
+			}
+
+			vzClient := clientmocks.NewVzClientInterface(t)
+			vzClient.On("GetVirtualizationGroup", mock.Anything, pod.Namespace, pod.Name).Return(&client.VirtualizationGroup{MacOSVirtualMachine: vm}, nil).Once()
+			vzClient.On("DeleteVirtualizationGroup", mock.Anything, pod.Namespace, pod.Name, provider.DefaultDeleteVZGroupGracePeriodSeconds).Return(nil).Once()
+
+			p := setupVZProviderWithPodInformer(t, ctx, vzClient, pod)
+
+			ps, err := p.GetPodStatus(ctx, pod.Namespace, pod.Name)
+			require.NoError(t, err)
+
+			assert.Equal(t, tc.expectedPhase, ps.Phase)
+			require.Len(t, ps.ContainerStatuses, 1)
+			require.NotNil(t, ps.ContainerStatuses[0].State.Terminated)
+			assert.Equal(t, tc.expectedExitCode, ps.ContainerStatuses[0].State.Terminated.ExitCode)
+		})
+	}
+}
+
+func TestGetPodStatus_RestartedContainer(t *testing.T) {
+	ctx := context.Background()
+	startedAt := time.Date(2012, 12, 12, 12, 12, 12, 0, time.UTC)
+	exitedAt := startedAt.Add(time.Minute)
+	restartedAt := exitedAt.Add(time.Second)
+
+	vm := vmmocks.NewVirtualMachine(t)
+	vm.On("State").Return(resource.VirtualMachineStateRunning)
+	vm.On("IPAddress").Return("10.0.0.3")
+	vm.On("StartedAt").Return(&startedAt)
+	vm.On("FinishedAt").Return((*time.Time)(nil))
+	vm.On("Healthy").Return(true).Maybe()
+
+	vg := &client.VirtualizationGroup{
+		MacOSVirtualMachine: vm,
+		Containers: []resource.Container{
+			{
+				Name:         "sidecar",
+				State:        resource.ContainerState{Status: resource.ContainerStatusRunning, StartedAt: restartedAt},
+				RestartCount: 1,
+				LastState: &resource.ContainerState{
+					Status:     resource.ContainerStatusDead,
+					StartedAt:  startedAt,
+					FinishedAt: exitedAt,
+					ExitCode:   2,
+				},
+			},
+		},
+	}
+	pod := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "test-pod",
+			Namespace: "default",
+		},
+		Spec: corev1.PodSpec{
+			Containers: []corev1.Container{
+				{Name: "macos", Image: "localhost:5000/macos:latest"},
+				{Name: "sidecar", Image: "localhost:5000/sidecar:1.27.1"},
+			},
+		},
+	}
+
+	vzClient := clientmocks.NewVzClientInterface(t)
+	vzClient.On("GetVirtualizationGroup", mock.Anything, pod.Namespace, pod.Name).Return(vg, nil).Once()
+
+	p := setupVZProviderWithPodInformer(t, ctx, vzClient, pod)
+
+	ps, err := p.GetPodStatus(ctx, pod.Namespace, pod.Name)
+	require.NoError(t, err)
+
+	require.Len(t, ps.ContainerStatuses, 2)
+	status := ps.ContainerStatuses[1]
+	assert.Equal(t, int32(1), status.RestartCount)
+	require.NotNil(t, status.State.Running)
+	assert.Equal(t, metav1.NewTime(restartedAt), status.State.Running.StartedAt)
+	require.NotNil(t, status.LastTerminationState.Terminated)
+	assert.Equal(t, int32(2), status.LastTerminationState.Terminated.ExitCode)
+	assert.Equal(t, metav1.NewTime(startedAt), status.LastTerminationState.Terminated.StartedAt)
+	assert.Equal(t, metav1.NewTime(exitedAt), status.LastTerminationState.Terminated.FinishedAt)
+}
+
 func TestGetPodStatus_MissingPod(t *testing.T) {
 	ctx := context.Background()
 	vg := &client.VirtualizationGroup{
@@ -347,3 +624,63 @@ func marshal(tb testing.TB, v interface{}) string {
 
 	return string(data)
 }
+
+func TestGetPodStatus_AgentHealth(t *testing.T) {
+	startedAt := time.Date(2012, 12, 12, 12, 12, 12, 0, time.UTC)
+
+	tests := []struct {
+		name          string
+		healthy       bool
+		expectedReady corev1.ConditionStatus
+	}{
+		{name: "agent healthy", healthy: true, expectedReady: corev1.ConditionTrue},
+		{name: "agent unhealthy", healthy: false, expectedReady: corev1.ConditionFalse},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			ctx := context.Background()
+
+			// the VM is reachable over SSH either way, only the agent tells whether it is ready
+			vm := vmmocks.NewVirtualMachine(t)
+			vm.On("State").Return(resource.VirtualMachineStateRunning)
+			vm.On("IPAddress").Return("10.0.0.3")
+			vm.On("StartedAt").Return(&startedAt)
+			vm.On("FinishedAt").Return(nil).Maybe()
+			vm.On("Healthy").Return(tc.healthy)
+			vm.On("Error").Return(nil).Maybe()
+
+			pod := &corev1.Pod{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "test-pod",
+					Namespace: "default",
+				},
+				Spec: corev1.PodSpec{
+					Containers: []corev1.Container{
+						{Name: "macos", Image: "localhost:5000/macos:latest"},
+					},
+				},
+			}
+
+			vzClient := clientmocks.NewVzClientInterface(t)
+			vzClient.On("GetVirtualizationGroup", mock.Anything, pod.Namespace, pod.Name).Return(&client.VirtualizationGroup{MacOSVirtualMachine: vm}, nil).Once()
+
+			p := setupVZProviderWithPodInformer(t, ctx, vzClient, pod)
+
+			ps, err := p.GetPodStatus(ctx, pod.Namespace, pod.Name)
+			require.NoError(t, err)
+
+			assert.Equal(t, corev1.PodRunning, ps.Phase)
+			assert.Equal(t, "10.0.0.3", ps.PodIP)
+			var ready *corev1.PodCondition
+			for i := range ps.Conditions {
+				if ps.Conditions[i].Type == corev1.PodReady {
+					ready = &ps.Conditions[i]
+				}
+			}
+			require.NotNil(t, ready)
+			assert.Equal(t, tc.expectedReady, ready.Status)
+			require.Len(t, ps.ContainerStatuses, 1)
+			assert.Equal(t, tc.healthy, ps.ContainerStatuses[0].Ready)
+		})
+	}
+}
@@ -19,7 +19,6 @@ import (
 
 	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/api/resource"
-	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
 
 const (
@@ -29,6 +28,12 @@ const (
 
 	// LabelCPUModelName is the label name for the CPU model name
 	LabelCPUModelName = "feature.node.kubernetes.io/cpu-model.name"
+
+	// NodeIPAnnotation is the node annotation with the internal IP address of the node.
+	NodeIPAnnotation = "macosvz.agoda.com/node-ip"
+	// NodeIPInterfaceAnnotation is the node annotation with the network interface the internal IP address
+	// was resolved from. It is not set when the IP address is configured statically.
+	NodeIPInterfaceAnnotation = "macosvz.agoda.com/node-ip-interface"
 )
 
 // ConfigureNode takes a Kubernetes node object and applies provider specific configurations to the object.
@@ -40,7 +45,8 @@ func (p *MacOSVZProvider) ConfigureNode(ctx context.Context, n *corev1.Node) err
 	n.Status.Capacity = capacity
 	n.Status.Allocatable = capacity
 
-	n.Status.Conditions = getNodeConditions()
+	n.Status.Phase = corev1.NodeRunning
+	n.Status.Conditions = getNodeConditions(ctx, nil)
 
 	addr, err := p.nodeAddresses(ctx)
 	if err != nil {
@@ -48,6 +54,7 @@ func (p *MacOSVZProvider) ConfigureNode(ctx context.Context, n *corev1.Node) err
 	}
 	n.Status.Addresses = addr
 	n.Status.DaemonEndpoints = p.nodeDaemonEndpoints()
+	p.setNodeIPAnnotations(n)
 
 	hostInfo, err := host.InfoWithContext(ctx)
 	if err != nil {
@@ -60,7 +67,9 @@ func (p *MacOSVZProvider) ConfigureNode(ctx context.Context, n *corev1.Node) err
 	n.Status.NodeInfo.OperatingSystem = hostInfo.Platform
 	n.Status.NodeInfo.Architecture = hostInfo.KernelArch
 
-	n.ObjectMeta.Labels[corev1.LabelNodeExcludeBalancers] = "true"
+	if p.excludeFromLoadBalancers {
+		n.ObjectMeta.Labels[corev1.LabelNodeExcludeBalancers] = "true"
+	}
 
 	// report both old and new styles of OS and arch information
 	os := strings.ToLower(hostInfo.Platform)
@@ -80,10 +89,11 @@ func (p *MacOSVZProvider) ConfigureNode(ctx context.Context, n *corev1.Node) err
 
 func (p *MacOSVZProvider) nodeAddresses(ctx context.Context) (addr []corev1.NodeAddress, err error) {
 	if p.nodeIPAddress == "" {
-		p.nodeIPAddress, err = retrieveNodeIPAddress(ctx)
+		p.nodeIPInterface, p.nodeIPAddress, err = retrieveNodeIPAddress(ctx, p.nodeIPInterface)
 		if err != nil {
 			return nil, err
 		}
+		log.G(ctx).Infof("Using IP address %s of interface %s as the node internal IP", p.nodeIPAddress, p.nodeIPInterface)
 	}
 
 	return []corev1.NodeAddress{
@@ -98,6 +108,20 @@ func (p *MacOSVZProvider) nodeAddresses(ctx context.Context) (addr []corev1.Node
 	}, nil
 }
 
+// setNodeIPAnnotations annotates the node with its internal IP address and the interface it was resolved from,
+// to diagnose the interface selection on hosts with several network interfaces.
+func (p *MacOSVZProvider) setNodeIPAnnotations(n *corev1.Node) {
+	if n.Annotations == nil {
+		n.Annotations = make(map[string]string)
+	}
+	n.Annotations[NodeIPAnnotation] = p.nodeIPAddress
+	if p.nodeIPInterface != "" {
+		n.Annotations[NodeIPInterfaceAnnotation] = p.nodeIPInterface
+	} else {
+		delete(n.Annotations, NodeIPInterfaceAnnotation)
+	}
+}
+
 // nodeDaemonEndpoints returns NodeDaemonEndpoints for the node status within Kubernetes.
 func (p *MacOSVZProvider) nodeDaemonEndpoints() corev1.NodeDaemonEndpoints {
 	return corev1.NodeDaemonEndpoints{
@@ -137,51 +161,17 @@ func getNodeCapacity(ctx context.Context) (corev1.ResourceList, error) {
 	}, nil
 }
 
-// getNodeConditions returns a list of conditions (Ready, OutOfDisk, etc), for updates to the node status within Kubernetes.
-func getNodeConditions() []corev1.NodeCondition {
-	// TODO: Implement proper node conditions with memory and disk health checks
-	return []corev1.NodeCondition{
-		{
-			Type:               corev1.NodeReady,
-			Status:             corev1.ConditionTrue,
-			LastHeartbeatTime:  metav1.Now(),
-			LastTransitionTime: metav1.Now(),
-			Reason:             "KubeletReady",
-			Message:            "kubelet is ready.",
-		},
-		{
-			Type:               corev1.NodeMemoryPressure,
-			Status:             corev1.ConditionFalse,
-			LastHeartbeatTime:  metav1.Now(),
-			LastTransitionTime: metav1.Now(),
-			Reason:             "KubeletHasSufficientMemory",
-			Message:            "kubelet has sufficient memory available",
-		},
-		{
-			Type:               corev1.NodeDiskPressure,
-			Status:             corev1.ConditionFalse,
-			LastHeartbeatTime:  metav1.Now(),
-			LastTransitionTime: metav1.Now(),
-			Reason:             "KubeletHasNoDiskPressure",
-			Message:            "kubelet has no disk pressure",
-		},
-		{
-			Type:               corev1.NodeNetworkUnavailable,
-			Status:             corev1.ConditionFalse,
-			LastHeartbeatTime:  metav1.Now(),
-			LastTransitionTime: metav1.Now(),
-			Reason:             "RouteCreated",
-			Message:            "RouteController created a route",
-		},
-	}
-}
-
-// retrieveNodeIPAddress retrieves the IP address of the node.
-func retrieveNodeIPAddress(ctx context.Context) (string, error) {
+// retrieveNodeIPAddress retrieves the IP address of the node, the one of the named interface if not empty,
+// along with the name of the interface it belongs to.
+func retrieveNodeIPAddress(ctx context.Context, iface string) (string, string, error) {
 	ifs, err := psnet.InterfacesWithContext(ctx)
 	if err != nil {
-		return "", err
+		return "", "", err
 	}
 
-	return netutil.GetActiveInterface(ifs)
+	if iface != "" {
+		ip, err := netutil.GetInterfaceIP(ifs, iface)
+		return iface, ip, err
+	}
+	return netutil.GetActiveInterfaceAddress(ifs)
 }
@@ -2,6 +2,7 @@ package provider_test
 
 import (
 	"context"
+	"fmt"
 	"strings"
 	"testing"
 	"time"
@@ -99,6 +100,16 @@ func TestNodeConfiguration(t *testing.T) {
 		}, "node should have daemon endpoint")
 	})
 
+	t.Run("Annotations", func(t *testing.T) {
+		ifs, err := psnet.InterfacesWithContext(ctx)
+		require.NoError(t, err)
+		iface, ip, err := netutil.GetActiveInterfaceAddress(ifs)
+		require.NoError(t, err)
+
+		assert.Equal(t, ip, knode.Annotations[provider.NodeIPAnnotation], "node should be annotated with the internal IP address")
+		assert.Equal(t, iface, knode.Annotations[provider.NodeIPInterfaceAnnotation], "node should be annotated with the interface of the internal IP address")
+	})
+
 	t.Run("NodeInfo", func(t *testing.T) {
 		hostInfo, err := host.InfoWithContext(ctx)
 		require.NoError(t, err)
@@ -161,6 +172,8 @@ func TestNodeConfiguration_StaticIP(t *testing.T) {
 			Address: nodeName,
 		},
 	})
+	assert.Equal(t, nodeIPAddress, knode.Annotations[provider.NodeIPAnnotation])
+	assert.NotContains(t, knode.Annotations, provider.NodeIPInterfaceAnnotation, "static IP address is not resolved from an interface")
 
 	// Stop the node
 	cancel()
@@ -168,6 +181,54 @@ func TestNodeConfiguration_StaticIP(t *testing.T) {
 	assert.NoError(t, node.Err(), "node should shutdown without error")
 }
 
+func TestNodeConfiguration_LoadBalancerExclusion(t *testing.T) {
+	ctx := context.Background()
+
+	for _, exclude := range []bool{true, false} {
+		t.Run(fmt.Sprintf("exclude=%t", exclude), func(t *testing.T) {
+			p, err := provider.NewMacOSVZProvider(ctx, clientmock.NewVzClientInterface(t), provider.MacOSVZProviderConfig{
+				NodeName:                 "test-node",
+				Platform:                 "darwin",
+				InternalIP:               "10.0.0.4",
+				ExcludeFromLoadBalancers: exclude,
+			})
+			require.NoError(t, err)
+
+			n := &corev1.Node{ObjectMeta: metav1.ObjectMeta{Name: "test-node", Labels: map[string]string{}}}
+			require.NoError(t, p.ConfigureNode(ctx, n))
+
+			value, ok := n.Labels[corev1.LabelNodeExcludeBalancers]
+			assert.Equal(t, exclude, ok, "exclude from external load balancers label presence")
+			if exclude {
+				assert.Equal(t, "true", value)
+			}
+		})
+	}
+}
+
+func TestNodeConfiguration_IPInterface(t *testing.T) {
+	ctx := context.Background()
+
+	ifs, err := psnet.InterfacesWithContext(ctx)
+	require.NoError(t, err)
+	iface, ip, err := netutil.GetActiveInterfaceAddress(ifs)
+	require.NoError(t, err)
+
+	p, err := provider.NewMacOSVZProvider(ctx, clientmock.NewVzClientInterface(t), provider.MacOSVZProviderConfig{
+		NodeName:            "test-node",
+		Platform:            "darwin",
+		InternalIPInterface: iface,
+	})
+	require.NoError(t, err)
+
+	n := &corev1.Node{ObjectMeta: metav1.ObjectMeta{Name: "test-node", Labels: map[string]string{}}}
+	require.NoError(t, p.ConfigureNode(ctx, n))
+
+	assert.Contains(t, n.Status.Addresses, corev1.NodeAddress{Type: corev1.NodeInternalIP, Address: ip})
+	assert.Equal(t, ip, n.Annotations[provider.NodeIPAnnotation])
+	assert.Equal(t, iface, n.Annotations[provider.NodeIPInterfaceAnnotation])
+}
+
 // Helper function to setup Kubernetes client and node provider
 func setupNodeProvider(t *testing.T, nodeName string, nodeIPAddress string, daemonEndpointPort int32) (context.Context, context.CancelFunc, *nodeutil.Node, *kubernetes.Clientset) {
 	t.Helper()
@@ -195,6 +256,8 @@ func setupNodeProvider(t *testing.T, nodeName string, nodeIPAddress string, daem
 				Platform:           platform,
 				InternalIP:         nodeIPAddress,
 				DaemonEndpointPort: daemonEndpointPort,
+
+				ExcludeFromLoadBalancers: true,
 			}
 
 			p, err := provider.NewMacOSVZProvider(ctx, vzClient, providerConfig)
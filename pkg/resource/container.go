@@ -31,6 +31,27 @@ const (
 	ContainerStatusUnknown
 )
 
+// String returns the name of the container status.
+func (s ContainerStatus) String() string {
+	switch s {
+	case ContainerStatusWaiting:
+		return "Waiting"
+	case ContainerStatusCreated:
+		return "Created"
+	case ContainerStatusRunning:
+		return "Running"
+	case ContainerStatusPaused:
+		return "Paused"
+	case ContainerStatusRestarting:
+		return "Restarting"
+	case ContainerStatusOOMKilled:
+		return "OOMKilled"
+	case ContainerStatusDead:
+		return "Dead"
+	}
+	return "Unknown"
+}
+
 // ContainerState holds information about the current and past state of a container.
 type ContainerState struct {
 	Status     ContainerStatus
@@ -45,4 +66,9 @@ type Container struct {
 	ID    string
 	Name  string
 	State ContainerState
+
+	// RestartCount is the number of times the container was restarted.
+	RestartCount int
+	// LastState is the terminated state of the previous run of the container, nil unless it was restarted.
+	LastState *ContainerState
 }
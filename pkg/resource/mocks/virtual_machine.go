@@ -16,6 +16,46 @@ type VirtualMachine struct {
 	mock.Mock
 }
 
+// CreatedAt provides a mock function with given fields:
+func (_m *VirtualMachine) CreatedAt() *time.Time {
+	ret := _m.Called()
+
+	if len(ret) == 0 {
+		panic("no return value specified for CreatedAt")
+	}
+
+	var r0 *time.Time
+	if rf, ok := ret.Get(0).(func() *time.Time); ok {
+		r0 = rf()
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*time.Time)
+		}
+	}
+
+	return r0
+}
+
+// DownloadProgress provides a mock function with given fields:
+func (_m *VirtualMachine) DownloadProgress() *resource.DownloadProgress {
+	ret := _m.Called()
+
+	if len(ret) == 0 {
+		panic("no return value specified for DownloadProgress")
+	}
+
+	var r0 *resource.DownloadProgress
+	if rf, ok := ret.Get(0).(func() *resource.DownloadProgress); ok {
+		r0 = rf()
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*resource.DownloadProgress)
+		}
+	}
+
+	return r0
+}
+
 // Env provides a mock function with given fields:
 func (_m *VirtualMachine) Env() []v1.EnvVar {
 	ret := _m.Called()
@@ -74,6 +114,42 @@ func (_m *VirtualMachine) FinishedAt() *time.Time {
 	return r0
 }
 
+// Healthy provides a mock function with given fields:
+func (_m *VirtualMachine) Healthy() bool {
+	ret := _m.Called()
+
+	if len(ret) == 0 {
+		panic("no return value specified for Healthy")
+	}
+
+	var r0 bool
+	if rf, ok := ret.Get(0).(func() bool); ok {
+		r0 = rf()
+	} else {
+		r0 = ret.Get(0).(bool)
+	}
+
+	return r0
+}
+
+// Image provides a mock function with given fields:
+func (_m *VirtualMachine) Image() string {
+	ret := _m.Called()
+
+	if len(ret) == 0 {
+		panic("no return value specified for Image")
+	}
+
+	var r0 string
+	if rf, ok := ret.Get(0).(func() string); ok {
+		r0 = rf()
+	} else {
+		r0 = ret.Get(0).(string)
+	}
+
+	return r0
+}
+
 // IPAddress provides a mock function with given fields:
 func (_m *VirtualMachine) IPAddress() string {
 	ret := _m.Called()
@@ -0,0 +1,70 @@
+package resource
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/virtual-kubelet/virtual-kubelet/log"
+)
+
+// StartupTimings records when a virtual machine went through the phases of its creation,
+// breaking the cold start of its pod down.
+type StartupTimings struct {
+	// RequestedAt is when the creation of the virtual machine was requested.
+	RequestedAt time.Time
+	// PulledAt is when the image was downloaded, or found in the cache.
+	PulledAt time.Time
+	// StartingAt is when a slot was available and the virtual machine started to be created.
+	StartingAt time.Time
+	// BootedAt is when the virtual machine was running.
+	BootedAt time.Time
+	// IPAssignedAt is when the IP address of the guest was discovered.
+	IPAssignedAt time.Time
+	// ReadyAt is when the guest accepted SSH connections.
+	ReadyAt time.Time
+}
+
+// phase returns the duration between the two times, zero unless both are known.
+func phase(from, to time.Time) time.Duration {
+	if from.IsZero() || to.IsZero() {
+		return 0
+	}
+	return to.Sub(from)
+}
+
+// Download returns the duration of the image download.
+func (t StartupTimings) Download() time.Duration { return phase(t.RequestedAt, t.PulledAt) }
+
+// Wait returns the duration of the wait for a slot.
+func (t StartupTimings) Wait() time.Duration { return phase(t.PulledAt, t.StartingAt) }
+
+// Boot returns the duration of the creation and the boot of the virtual machine.
+func (t StartupTimings) Boot() time.Duration { return phase(t.StartingAt, t.BootedAt) }
+
+// IP returns the duration of the IP address discovery.
+func (t StartupTimings) IP() time.Duration { return phase(t.BootedAt, t.IPAssignedAt) }
+
+// SSH returns the duration of the wait for the guest to accept SSH connections.
+func (t StartupTimings) SSH() time.Duration { return phase(t.IPAssignedAt, t.ReadyAt) }
+
+// Total returns the duration of the whole creation.
+func (t StartupTimings) Total() time.Duration { return phase(t.RequestedAt, t.ReadyAt) }
+
+// Fields returns the durations of the phases in milliseconds as structured log fields.
+func (t StartupTimings) Fields() log.Fields {
+	return log.Fields{
+		"downloadMs": t.Download().Milliseconds(),
+		"waitMs":     t.Wait().Milliseconds(),
+		"bootMs":     t.Boot().Milliseconds(),
+		"ipMs":       t.IP().Milliseconds(),
+		"sshMs":      t.SSH().Milliseconds(),
+		"totalMs":    t.Total().Milliseconds(),
+	}
+}
+
+// String summarizes the durations of the phases.
+func (t StartupTimings) String() string {
+	return fmt.Sprintf("%s (download %s, wait %s, boot %s, IP %s, SSH %s)",
+		t.Total().Round(time.Millisecond), t.Download().Round(time.Millisecond), t.Wait().Round(time.Millisecond),
+		t.Boot().Round(time.Millisecond), t.IP().Round(time.Millisecond), t.SSH().Round(time.Millisecond))
+}
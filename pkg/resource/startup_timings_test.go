@@ -0,0 +1,49 @@
+package resource_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/agoda-com/macOS-vz-kubelet/pkg/resource"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/virtual-kubelet/virtual-kubelet/log"
+)
+
+func TestStartupTimings(t *testing.T) {
+	requested := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	vm := resource.NewMacOSVirtualMachine(nil)
+	vm.SetStartupTimings(resource.StartupTimings{
+		RequestedAt:  requested,
+		PulledAt:     requested.Add(30 * time.Second),
+		StartingAt:   requested.Add(32 * time.Second),
+		BootedAt:     requested.Add(50 * time.Second),
+		IPAssignedAt: requested.Add(55 * time.Second),
+		ReadyAt:      requested.Add(65*time.Second + 250*time.Millisecond),
+	})
+
+	timings := vm.StartupTimings()
+	assert.Equal(t, log.Fields{
+		"downloadMs": int64(30000),
+		"waitMs":     int64(2000),
+		"bootMs":     int64(18000),
+		"ipMs":       int64(5000),
+		"sshMs":      int64(10250),
+		"totalMs":    int64(65250),
+	}, timings.Fields())
+	assert.Equal(t, "1m5.25s (download 30s, wait 2s, boot 18s, IP 5s, SSH 10.25s)", timings.String())
+}
+
+func TestStartupTimingsMissingPhases(t *testing.T) {
+	requested := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	timings := resource.StartupTimings{
+		RequestedAt: requested,
+		PulledAt:    requested.Add(time.Second),
+	}
+
+	// phases not reached yet have no duration
+	assert.Equal(t, time.Second, timings.Download())
+	assert.Zero(t, timings.Wait())
+	assert.Zero(t, timings.Boot())
+	assert.Zero(t, timings.Total())
+}
@@ -1,6 +1,8 @@
 package resource
 
 import (
+	"errors"
+	"fmt"
 	"time"
 
 	"github.com/Code-Hex/vz/v3"
@@ -31,10 +33,69 @@ const (
 	VirtualMachineStateFailed
 )
 
+// String returns the name of the virtual machine state.
+func (s VirtualMachineState) String() string {
+	switch s {
+	case VirtualMachineStatePreparing:
+		return "Preparing"
+	case VirtualMachineStateStarting:
+		return "Starting"
+	case VirtualMachineStateRunning:
+		return "Running"
+	case VirtualMachineStateTerminating:
+		return "Terminating"
+	case VirtualMachineStateTerminated:
+		return "Terminated"
+	case VirtualMachineStateFailed:
+		return "Failed"
+	}
+	return "Unknown"
+}
+
+// ErrMaxLifetimeExceeded is the error state of a virtual machine that was recycled after running longer than the maximum lifetime.
+var ErrMaxLifetimeExceeded = errors.New("virtual machine exceeded its maximum lifetime")
+
+// ErrDeadlineExceeded is the error state of a virtual machine that was running longer than the active deadline of its pod.
+var ErrDeadlineExceeded = errors.New("virtual machine exceeded the active deadline of its pod")
+
+// ErrPreempted is the error state of a virtual machine that was preempted to make room for a higher priority pod.
+var ErrPreempted = errors.New("virtual machine was preempted by a higher priority pod")
+
+// ErrReadinessTimeout is the error state of a virtual machine that did not accept SSH connections within the readiness timeout.
+var ErrReadinessTimeout = errors.New("virtual machine did not become ready within the readiness timeout")
+
+// ErrEphemeralStorageExceeded is the error state of a virtual machine evicted for consuming more ephemeral storage than the limit of its pod.
+var ErrEphemeralStorageExceeded = errors.New("virtual machine exceeded the ephemeral storage limit of its pod")
+
+// ErrCrashed is the error state of a virtual machine that was stopped by Virtualization.framework because of an error.
+var ErrCrashed = vm.ErrCrashed
+
+// CommandExitError is the error state of a virtual machine whose command exited with a non-zero exit code.
+type CommandExitError struct {
+	// ExitCode is the exit code of the command.
+	ExitCode int
+}
+
+// Error implements the error interface.
+func (e *CommandExitError) Error() string {
+	return fmt.Sprintf("command exited with code %d", e.ExitCode)
+}
+
+// DownloadProgress represents the progress of the virtual machine image download.
+type DownloadProgress struct {
+	// Completed is the number of bytes downloaded so far.
+	Completed int64
+	// Total is the number of bytes expected, or zero if unknown yet.
+	Total int64
+}
+
 type VirtualMachine interface {
 	// Env returns the environment variables for the virtual machine.
 	Env() []corev1.EnvVar
 
+	// Image returns the reference of the image the virtual machine is created from.
+	Image() string
+
 	// State returns the current state of the virtual machine.
 	State() VirtualMachineState
 
@@ -47,18 +108,32 @@ type VirtualMachine interface {
 	// IPAddress returns the IP address of the virtual machine.
 	IPAddress() string
 
+	// CreatedAt returns the creation time of the virtual machine.
+	CreatedAt() *time.Time
+
 	// StartedAt returns the start time of the virtual machine.
 	StartedAt() *time.Time
 
 	// FinishedAt returns the finish time of the virtual machine.
 	FinishedAt() *time.Time
+
+	// DownloadProgress returns the progress of the image download, or nil if not available.
+	DownloadProgress() *DownloadProgress
+
+	// Healthy reports whether the guest agent of the virtual machine reports it healthy,
+	// always true if the virtual machine has no guest agent.
+	Healthy() bool
 }
 
 // MacOSVirtualMachine represents a macOS virtual machine instance along with its error state.
 type MacOSVirtualMachine struct {
 	env      []corev1.EnvVar            // Environment variables for the virtual machine.
+	image    string                     // Reference of the image the virtual machine is created from.
 	instance *vm.VirtualMachineInstance // The underlying virtual machine instance.
 	err      error                      // Error state of the virtual machine.
+	progress *DownloadProgress          // Progress of the image download.
+	health   *bool                      // Health reported by the guest agent, nil if there is none.
+	timings  StartupTimings             // Times the virtual machine went through the phases of its creation.
 }
 
 // NewMacOSVirtualMachine creates a new instance of MacOSVirtualMachine.
@@ -73,6 +148,16 @@ func (m *MacOSVirtualMachine) Env() []corev1.EnvVar {
 	return m.env
 }
 
+// Image returns the reference of the image the macOS virtual machine is created from.
+func (m *MacOSVirtualMachine) Image() string {
+	return m.image
+}
+
+// SetImage sets the reference of the image the macOS virtual machine is created from.
+func (m *MacOSVirtualMachine) SetImage(image string) {
+	m.image = image
+}
+
 // Instance returns the internal VirtualMachineInstance.
 func (m *MacOSVirtualMachine) Instance() *vm.VirtualMachineInstance {
 	return m.instance
@@ -83,6 +168,16 @@ func (m *MacOSVirtualMachine) SetInstance(instance *vm.VirtualMachineInstance) {
 	m.instance = instance
 }
 
+// StartupTimings returns when the macOS virtual machine went through the phases of its creation.
+func (m *MacOSVirtualMachine) StartupTimings() StartupTimings {
+	return m.timings
+}
+
+// SetStartupTimings sets when the macOS virtual machine went through the phases of its creation.
+func (m *MacOSVirtualMachine) SetStartupTimings(timings StartupTimings) {
+	m.timings = timings
+}
+
 // State returns the current state of the macOS virtual machine.
 func (m *MacOSVirtualMachine) State() VirtualMachineState {
 	if m.err != nil {
@@ -128,6 +223,15 @@ func (m *MacOSVirtualMachine) IPAddress() string {
 	return m.instance.IPAddress
 }
 
+// CreatedAt returns the creation time of the macOS virtual machine.
+func (m *MacOSVirtualMachine) CreatedAt() *time.Time {
+	if m.instance == nil {
+		return nil
+	}
+
+	return &m.instance.CreatedAt
+}
+
 // StartedAt returns the start time of the macOS virtual machine.
 func (m *MacOSVirtualMachine) StartedAt() *time.Time {
 	if m.instance == nil {
@@ -145,3 +249,24 @@ func (m *MacOSVirtualMachine) FinishedAt() *time.Time {
 
 	return m.instance.FinishedAt
 }
+
+// DownloadProgress returns the progress of the image download of the macOS virtual machine.
+func (m *MacOSVirtualMachine) DownloadProgress() *DownloadProgress {
+	return m.progress
+}
+
+// SetDownloadProgress sets the progress of the image download of the macOS virtual machine.
+func (m *MacOSVirtualMachine) SetDownloadProgress(progress *DownloadProgress) {
+	m.progress = progress
+}
+
+// Healthy reports whether the guest agent of the macOS virtual machine reports it healthy,
+// always true if the virtual machine has no guest agent.
+func (m *MacOSVirtualMachine) Healthy() bool {
+	return m.health == nil || *m.health
+}
+
+// SetHealthy sets the health reported by the guest agent of the macOS virtual machine.
+func (m *MacOSVirtualMachine) SetHealthy(healthy bool) {
+	m.health = &healthy
+}
@@ -0,0 +1,136 @@
+package resourcemanager
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+
+	vmdata "github.com/agoda-com/macOS-vz-kubelet/internal/data/vm"
+	"github.com/agoda-com/macOS-vz-kubelet/pkg/vm"
+
+	"github.com/virtual-kubelet/virtual-kubelet/log"
+)
+
+const (
+	// DefaultAgentHealthPath is the path of the health endpoint of the guest agent unless the pod sets one.
+	DefaultAgentHealthPath = "/healthz"
+
+	// AgentHealthProbeInterval is the interval between the requests to the health endpoint of the guest agent.
+	AgentHealthProbeInterval = 5 * time.Second
+
+	// agentHealthRequestTimeout bounds a single request to the health endpoint of the guest agent.
+	agentHealthRequestTimeout = 2 * time.Second
+)
+
+// AgentHealthProbe polls the HTTP health endpoint of a guest agent running inside the virtual machine.
+// Accepting SSH connections only tells that the guest booted, the agent tells whether the workload of the
+// guest is healthy, so a virtual machine with an agent is ready only while its agent reports it healthy.
+type AgentHealthProbe struct {
+	// Port is the port of the health endpoint inside the guest.
+	Port int
+	// Path is the path of the health endpoint, DefaultAgentHealthPath if empty.
+	Path string
+	// Interval is the interval between the requests, AgentHealthProbeInterval if zero.
+	Interval time.Duration
+}
+
+// URL returns the URL of the health endpoint of the guest agent of the virtual machine with the IP address.
+func (p *AgentHealthProbe) URL(ipAddr string) string {
+	path := p.Path
+	if path == "" {
+		path = DefaultAgentHealthPath
+	}
+	return "http://" + net.JoinHostPort(ipAddr, strconv.Itoa(p.Port)) + path
+}
+
+// Check returns an error unless the health endpoint of the guest agent responds with a 2xx status.
+func (p *AgentHealthProbe) Check(ctx context.Context, ipAddr string) error {
+	ctx, cancel := context.WithTimeout(ctx, agentHealthRequestTimeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, p.URL(ipAddr), nil)
+	if err != nil {
+		return err
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_, _ = io.Copy(io.Discard, resp.Body)
+		_ = resp.Body.Close()
+	}()
+
+	if resp.StatusCode < http.StatusOK || resp.StatusCode >= http.StatusMultipleChoices {
+		return fmt.Errorf("guest agent responded with status %s", resp.Status)
+	}
+	return nil
+}
+
+// Run checks the health endpoint of the guest agent until the context is done, and reports the health
+// whenever it changes. The virtual machine is considered unhealthy until the first successful check.
+func (p *AgentHealthProbe) Run(ctx context.Context, ipAddr string, report func(healthy bool)) {
+	interval := p.Interval
+	if interval <= 0 {
+		interval = AgentHealthProbeInterval
+	}
+
+	healthy := false
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		err := p.Check(ctx, ipAddr)
+		if ctx.Err() != nil {
+			return
+		}
+		if (err == nil) != healthy {
+			healthy = err == nil
+			if healthy {
+				log.G(ctx).Info("Guest agent reports the virtual machine healthy")
+			} else {
+				log.G(ctx).WithError(err).Warn("Guest agent reports the virtual machine unhealthy")
+			}
+			report(healthy)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// probeAgentHealth polls the guest agent of the started virtual machine until it stops, and keeps the health
+// of the virtual machine up to date for its readiness.
+func (c *MacOSClient) probeAgentHealth(ctx context.Context, params VirtualMachineParams) {
+	var instance *vm.VirtualMachineInstance
+	c.updateCreatedVirtualMachineInfo(params, func(i vmdata.VirtualMachineInfo) vmdata.VirtualMachineInfo {
+		instance = i.Resource.Instance()
+		return i
+	})
+	if instance == nil {
+		return
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	go func() {
+		select {
+		case <-ctx.Done():
+		case <-instance.Done():
+			cancel()
+		}
+	}()
+
+	params.AgentHealth.Run(ctx, instance.IPAddress, func(healthy bool) {
+		c.updateCreatedVirtualMachineInfo(params, func(i vmdata.VirtualMachineInfo) vmdata.VirtualMachineInfo {
+			i.Resource.SetHealthy(healthy)
+			return i
+		})
+	})
+}
@@ -0,0 +1,70 @@
+package resourcemanager_test
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/agoda-com/macOS-vz-kubelet/pkg/resourcemanager"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newAgentServer returns a probe of a fake guest agent whose health is toggled by healthy.
+func newAgentServer(t *testing.T, healthy *atomic.Bool) (*resourcemanager.AgentHealthProbe, string) {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/ready" || !healthy.Load() {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	t.Cleanup(server.Close)
+
+	host, port, err := net.SplitHostPort(server.Listener.Addr().String())
+	require.NoError(t, err)
+	portNumber, err := strconv.Atoi(port)
+	require.NoError(t, err)
+	return &resourcemanager.AgentHealthProbe{Port: portNumber, Path: "/ready", Interval: 10 * time.Millisecond}, host
+}
+
+func TestAgentHealthProbeCheck(t *testing.T) {
+	var healthy atomic.Bool
+	probe, host := newAgentServer(t, &healthy)
+
+	assert.Error(t, probe.Check(context.Background(), host))
+	healthy.Store(true)
+	assert.NoError(t, probe.Check(context.Background(), host))
+}
+
+func TestAgentHealthProbeRun(t *testing.T) {
+	var healthy atomic.Bool
+	probe, host := newAgentServer(t, &healthy)
+	healthy.Store(true)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	reports := make(chan bool, 10)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		probe.Run(ctx, host, func(h bool) { reports <- h })
+	}()
+
+	// only the changes of the health are reported
+	assert.True(t, <-reports)
+	healthy.Store(false)
+	assert.False(t, <-reports)
+	healthy.Store(true)
+	assert.True(t, <-reports)
+
+	cancel()
+	<-done
+	assert.Empty(t, reports)
+}
@@ -0,0 +1,58 @@
+package resourcemanager
+
+import (
+	"context"
+
+	"github.com/agoda-com/macOS-vz-kubelet/internal/node"
+	"github.com/agoda-com/macOS-vz-kubelet/pkg/event"
+
+	"github.com/virtual-kubelet/virtual-kubelet/log"
+	"github.com/virtual-kubelet/virtual-kubelet/trace"
+)
+
+// defaultNetworkTimeServer is the network time server of macOS, used if the guest has none configured.
+const defaultNetworkTimeServer = "time.apple.com"
+
+// ClockSyncCommand returns a shell command that enables network time inside the guest and steps its clock
+// to the network time server right away, instead of letting it slew slowly.
+// This will not work if sudo requires a password.
+func ClockSyncCommand() []string {
+	script := "sudo -n systemsetup -setusingnetworktime on > /dev/null" +
+		" && server=$(sudo -n systemsetup -getnetworktimeserver | awk '{print $NF}')" +
+		" && sudo -n sntp -sS \"${server:-" + defaultNetworkTimeServer + "}\" > /dev/null"
+	return []string{"sh", "-c", script}
+}
+
+// ClockSync resynchronizes the clock of the guest once booted. Virtual machines booted from long-cached
+// images or resumed from a saved state start with a skewed clock, which breaks TLS among others.
+type ClockSync struct {
+	ContainerName string
+	Enabled       bool
+
+	ExecFunc      ExecFunc
+	EventRecorder event.EventRecorder
+}
+
+// Run synchronizes the clock of the guest if enabled, recording an event if it fails.
+// It reports whether the synchronization was attempted.
+func (s *ClockSync) Run(ctx context.Context) (attempted bool, err error) {
+	if !s.Enabled {
+		return false, nil
+	}
+
+	ctx, span := trace.StartSpan(ctx, "ClockSync.Run")
+	defer func() {
+		span.SetStatus(err)
+		span.End()
+	}()
+
+	ctx, cancel := context.WithTimeout(ctx, GuestConfigurationTimeout)
+	defer cancel()
+
+	if err = s.ExecFunc(ctx, ClockSyncCommand(), node.DiscardingExecIO()); err != nil {
+		s.EventRecorder.FailedToSyncClock(ctx, s.ContainerName, err)
+		return true, err
+	}
+	log.G(ctx).Debug("Synchronized the clock of the virtual machine")
+	return true, nil
+}
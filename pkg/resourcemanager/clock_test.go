@@ -0,0 +1,80 @@
+package resourcemanager_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/agoda-com/macOS-vz-kubelet/pkg/event/mocks"
+	"github.com/agoda-com/macOS-vz-kubelet/pkg/resourcemanager"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"github.com/virtual-kubelet/virtual-kubelet/node/api"
+)
+
+func TestClockSyncCommand(t *testing.T) {
+	cmd := resourcemanager.ClockSyncCommand()
+	require.Len(t, cmd, 3)
+	assert.Equal(t, []string{"sh", "-c"}, cmd[:2])
+	assert.Contains(t, cmd[2], "sudo -n systemsetup -setusingnetworktime on")
+	assert.Contains(t, cmd[2], `sudo -n sntp -sS "${server:-time.apple.com}"`)
+}
+
+func TestClockSyncRun(t *testing.T) {
+	tests := []struct {
+		name          string
+		enabled       bool
+		execErr       error
+		expectExec    bool
+		expectFailure bool
+	}{
+		{
+			name:       "Enabled",
+			enabled:    true,
+			expectExec: true,
+		},
+		{
+			name:       "Disabled",
+			enabled:    false,
+			expectExec: false,
+		},
+		{
+			name:          "Failed",
+			enabled:       true,
+			execErr:       errors.New("sudo: a password is required"),
+			expectExec:    true,
+			expectFailure: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			recorder := mocks.NewEventRecorder(t)
+			if tt.expectFailure {
+				recorder.On("FailedToSyncClock", mock.Anything, "macos", tt.execErr).Return().Once()
+			}
+
+			var executed [][]string
+			clockSync := &resourcemanager.ClockSync{
+				ContainerName: "macos",
+				Enabled:       tt.enabled,
+				ExecFunc: func(_ context.Context, cmd []string, _ api.AttachIO) error {
+					executed = append(executed, cmd)
+					return tt.execErr
+				},
+				EventRecorder: recorder,
+			}
+
+			attempted, err := clockSync.Run(context.Background())
+			assert.Equal(t, tt.expectExec, attempted)
+			assert.Equal(t, tt.execErr, err)
+			if tt.expectExec {
+				assert.Equal(t, [][]string{resourcemanager.ClockSyncCommand()}, executed)
+			} else {
+				assert.Empty(t, executed)
+			}
+		})
+	}
+}
@@ -0,0 +1,168 @@
+package resourcemanager
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync/atomic"
+	"time"
+
+	"golang.org/x/crypto/ssh"
+
+	"github.com/Code-Hex/vz/v3"
+	vmdata "github.com/agoda-com/macOS-vz-kubelet/internal/data/vm"
+	"github.com/agoda-com/macOS-vz-kubelet/internal/node"
+	"github.com/agoda-com/macOS-vz-kubelet/pkg/resource"
+	"github.com/agoda-com/macOS-vz-kubelet/pkg/vm"
+
+	"github.com/virtual-kubelet/virtual-kubelet/log"
+	"github.com/virtual-kubelet/virtual-kubelet/trace"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// CommandKillTimeout bounds how long the termination of a command waits for it to exit after SIGKILL.
+const CommandKillTimeout = 5 * time.Second
+
+// Command is the command running inside a virtual machine. Like the process of a container,
+// it is terminated with SIGTERM, then SIGKILL once the grace period has elapsed.
+// The signals are sent over the SSH session running the command.
+type Command struct {
+	signals     chan ssh.Signal
+	done        chan struct{}
+	terminating atomic.Bool
+}
+
+// NewCommand creates a running command.
+func NewCommand() *Command {
+	return &Command{
+		signals: make(chan ssh.Signal, 2), // SIGTERM and SIGKILL never block
+		done:    make(chan struct{}),
+	}
+}
+
+// Signals returns the signals to send to the command.
+func (c *Command) Signals() <-chan ssh.Signal {
+	return c.signals
+}
+
+// Exited marks the command as exited, it must be called once.
+func (c *Command) Exited() {
+	close(c.done)
+}
+
+// Terminating reports whether the command is being terminated.
+func (c *Command) Terminating() bool {
+	return c.terminating.Load()
+}
+
+// Terminate sends SIGTERM to the command and waits the grace period for it to exit,
+// then sends SIGKILL and waits at most CommandKillTimeout. It reports whether the command exited.
+func (c *Command) Terminate(ctx context.Context, gracePeriod time.Duration) bool {
+	c.terminating.Store(true)
+	if c.signal(ctx, ssh.SIGTERM, gracePeriod) {
+		return true
+	}
+	log.G(ctx).Warnf("Command did not exit within the grace period of %s, killing it", gracePeriod)
+	return c.signal(ctx, ssh.SIGKILL, CommandKillTimeout)
+}
+
+// signal sends the signal to the command and reports whether it exits within the timeout.
+func (c *Command) signal(ctx context.Context, sig ssh.Signal, timeout time.Duration) bool {
+	select {
+	case <-c.done:
+		return true
+	default:
+		c.signals <- sig
+	}
+
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	select {
+	case <-c.done:
+		return true
+	case <-timer.C:
+		return false
+	case <-ctx.Done():
+		return false
+	}
+}
+
+// commandIO is the discarding IO of the command of a virtual machine, which forwards the signals of the command.
+type commandIO struct {
+	*node.ExecIO
+	*Command
+}
+
+// exitStatusError is implemented by the errors of commands that exited with a non-zero exit code, e.g. *ssh.ExitError.
+type exitStatusError interface {
+	error
+	ExitStatus() int
+}
+
+// CommandResult converts the error of the command of a virtual machine into its error state:
+// nil if the command succeeded, a *resource.CommandExitError if it exited with a non-zero exit code,
+// or the wrapped error if the command could not be run to completion.
+func CommandResult(err error) error {
+	if err == nil {
+		return nil
+	}
+	var exitErr exitStatusError
+	if errors.As(err, &exitErr) {
+		if code := exitErr.ExitStatus(); code != 0 {
+			return &resource.CommandExitError{ExitCode: code}
+		}
+		return nil
+	}
+	return fmt.Errorf("failed to run command: %w", err)
+}
+
+// runCommand runs the command of the virtual machine until it exits, then stops the virtual machine.
+// The virtual machine is failed if the command did not succeed, so that its pod fails as well.
+func (c *MacOSClient) runCommand(ctx context.Context, params VirtualMachineParams) {
+	var err error
+	ctx, span := trace.StartSpan(ctx, "MacOSClient.runCommand")
+	ctx = span.WithFields(ctx, log.Fields{
+		"namespace": params.Namespace,
+		"name":      params.Name,
+	})
+	defer func() {
+		span.SetStatus(err)
+		span.End()
+	}()
+	logger := log.G(ctx)
+	logger.Info("Virtual machine is running, executing command")
+
+	command := NewCommand()
+	key := types.NamespacedName{Namespace: params.Namespace, Name: params.Name}
+	c.commands.Store(key, command)
+	defer c.commands.CompareAndDelete(key, command)
+
+	err = CommandResult(c.ExecInVirtualMachine(ctx, params.Namespace, params.Name, params.Command, &commandIO{ExecIO: node.DiscardingExecIO(), Command: command}))
+	command.Exited()
+	if ctx.Err() != nil || command.Terminating() {
+		// the virtual machine is being deleted, its state no longer matters
+		logger.Debug("command interrupted by the virtual machine deletion")
+		return
+	}
+
+	var instance *vm.VirtualMachineInstance
+	updated := c.updateCreatedVirtualMachineInfo(params, func(i vmdata.VirtualMachineInfo) vmdata.VirtualMachineInfo {
+		if err != nil {
+			i.Resource.SetError(err)
+		}
+		instance = i.Resource.Instance()
+		return i
+	})
+	if !updated {
+		logger.Debug("virtual machine info expired")
+		return
+	}
+	logger.WithError(err).Info("Virtual machine command exited, stopping the virtual machine")
+
+	// overlays are kept until the pod is deleted, only the virtual machine itself is stopped
+	if instance != nil && instance.State() != vz.VirtualMachineStateStopped {
+		if stopErr := instance.VirtualMachine.Stop(); stopErr != nil {
+			logger.WithError(stopErr).Warn("Failed to stop the virtual machine after its command exited")
+		}
+	}
+}
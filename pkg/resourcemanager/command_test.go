@@ -0,0 +1,106 @@
+package resourcemanager_test
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/agoda-com/macOS-vz-kubelet/pkg/resource"
+	"github.com/agoda-com/macOS-vz-kubelet/pkg/resourcemanager"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/ssh"
+)
+
+// exitStatusError mimics *ssh.ExitError, which cannot be constructed outside of the ssh package.
+type exitStatusError int
+
+func (e exitStatusError) Error() string   { return fmt.Sprintf("exit status %d", int(e)) }
+func (e exitStatusError) ExitStatus() int { return int(e) }
+
+func TestCommandResult(t *testing.T) {
+	assert.NoError(t, resourcemanager.CommandResult(nil))
+	assert.NoError(t, resourcemanager.CommandResult(exitStatusError(0)))
+
+	err := resourcemanager.CommandResult(fmt.Errorf("wrapped: %w", exitStatusError(2)))
+	var exitErr *resource.CommandExitError
+	if assert.ErrorAs(t, err, &exitErr) {
+		assert.Equal(t, 2, exitErr.ExitCode)
+	}
+
+	err = resourcemanager.CommandResult(assert.AnError)
+	assert.ErrorIs(t, err, assert.AnError)
+	assert.NotErrorAs(t, err, &exitErr)
+}
+
+// guestCommand simulates the command inside the guest, recording the signals it receives.
+// It exits on the first signal found in exitOn.
+func guestCommand(command *resourcemanager.Command, exitOn ...ssh.Signal) (received func() []ssh.Signal, at func() []time.Time) {
+	var (
+		mu      sync.Mutex
+		signals []ssh.Signal
+		times   []time.Time
+	)
+	go func() {
+		for sig := range command.Signals() {
+			mu.Lock()
+			signals = append(signals, sig)
+			times = append(times, time.Now())
+			mu.Unlock()
+			if slices.Contains(exitOn, sig) {
+				command.Exited()
+				return
+			}
+		}
+	}()
+	received = func() []ssh.Signal {
+		mu.Lock()
+		defer mu.Unlock()
+		return slices.Clone(signals)
+	}
+	at = func() []time.Time {
+		mu.Lock()
+		defer mu.Unlock()
+		return slices.Clone(times)
+	}
+	return received, at
+}
+
+func TestCommandTerminate(t *testing.T) {
+	const gracePeriod = 200 * time.Millisecond
+
+	// the command ignores SIGTERM, so it is killed once the grace period has elapsed
+	command := resourcemanager.NewCommand()
+	received, at := guestCommand(command, ssh.SIGKILL)
+
+	start := time.Now()
+	assert.True(t, command.Terminate(context.Background(), gracePeriod))
+	assert.GreaterOrEqual(t, time.Since(start), gracePeriod)
+	assert.True(t, command.Terminating())
+
+	require.Equal(t, []ssh.Signal{ssh.SIGTERM, ssh.SIGKILL}, received())
+	times := at()
+	assert.GreaterOrEqual(t, times[1].Sub(times[0]), gracePeriod, "SIGKILL is sent only after the grace period")
+}
+
+func TestCommandTerminateExitsOnTerm(t *testing.T) {
+	command := resourcemanager.NewCommand()
+	received, _ := guestCommand(command, ssh.SIGTERM)
+
+	start := time.Now()
+	assert.True(t, command.Terminate(context.Background(), time.Minute))
+	assert.Less(t, time.Since(start), time.Minute)
+	assert.Equal(t, []ssh.Signal{ssh.SIGTERM}, received())
+}
+
+func TestCommandTerminateExited(t *testing.T) {
+	command := resourcemanager.NewCommand()
+	command.Exited()
+
+	assert.True(t, command.Terminate(context.Background(), time.Minute))
+	assert.Empty(t, command.Signals())
+}
@@ -31,6 +31,12 @@ type ContainerParams struct {
 	Stdin           bool
 	StdinOnce       bool
 	PostStartAction *resource.ExecAction
+	// ReadOnlyRootFilesystem mounts the root filesystem of the container read-only.
+	ReadOnlyRootFilesystem bool
+	// Hostname is the hostname of the container, the pod name if empty.
+	Hostname string
+	// Domainname is the domain of the container within the cluster, if any.
+	Domainname string
 }
 
 // ContainersClient is an interface that defines the methods that a ContainersClient implementation should provide.
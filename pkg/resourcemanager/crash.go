@@ -0,0 +1,46 @@
+package resourcemanager
+
+import (
+	"context"
+
+	vmdata "github.com/agoda-com/macOS-vz-kubelet/internal/data/vm"
+	"github.com/agoda-com/macOS-vz-kubelet/pkg/vm"
+
+	"github.com/virtual-kubelet/virtual-kubelet/log"
+)
+
+// watchVirtualMachineCrash waits for the started virtual machine to stop and fails it if it was stopped
+// by Virtualization.framework because of an error, so that its pod fails instead of waiting for the guest.
+func (c *MacOSClient) watchVirtualMachineCrash(ctx context.Context, params VirtualMachineParams) {
+	var instance *vm.VirtualMachineInstance
+	c.updateCreatedVirtualMachineInfo(params, func(i vmdata.VirtualMachineInfo) vmdata.VirtualMachineInfo {
+		instance = i.Resource.Instance()
+		return i
+	})
+	if instance == nil {
+		return
+	}
+
+	select {
+	case <-ctx.Done():
+		return
+	case <-instance.Done():
+	}
+	err := instance.Err()
+	if err == nil {
+		return
+	}
+
+	updated := c.updateCreatedVirtualMachineInfo(params, func(i vmdata.VirtualMachineInfo) vmdata.VirtualMachineInfo {
+		// keep the reason of virtual machines failed on purpose
+		if i.Resource.Error() == nil {
+			i.Resource.SetError(err)
+		}
+		return i
+	})
+	if !updated {
+		log.G(ctx).Debug("virtual machine info expired")
+		return
+	}
+	c.eventRecorder.VirtualMachineCrashed(ctx, params.ContainerName, err)
+}
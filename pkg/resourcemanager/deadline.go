@@ -0,0 +1,86 @@
+package resourcemanager
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	vmdata "github.com/agoda-com/macOS-vz-kubelet/internal/data/vm"
+	"github.com/agoda-com/macOS-vz-kubelet/pkg/resource"
+
+	"github.com/virtual-kubelet/virtual-kubelet/log"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// ActiveDeadlines fails virtual machines that have been running longer than the active deadline of their pods.
+//
+// Expired virtual machines are marked as failed with resource.ErrDeadlineExceeded. The provider then deletes
+// the failed pod, which stops the virtual machine and releases its slot.
+type ActiveDeadlines struct {
+	Data *vmdata.VirtualMachineData
+
+	mu     sync.Mutex
+	timers map[types.NamespacedName]*time.Timer
+}
+
+// Start starts the active deadline timer of the virtual machine, replacing the previous timer if any.
+func (d *ActiveDeadlines) Start(ctx context.Context, namespace, name string, deadline time.Duration) {
+	key := types.NamespacedName{Namespace: namespace, Name: name}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.timers == nil {
+		d.timers = make(map[types.NamespacedName]*time.Timer)
+	}
+	if timer, ok := d.timers[key]; ok {
+		timer.Stop()
+	}
+
+	var timer *time.Timer
+	timer = time.AfterFunc(deadline, func() {
+		d.mu.Lock()
+		if d.timers[key] != timer {
+			// stopped or replaced in the meantime
+			d.mu.Unlock()
+			return
+		}
+		delete(d.timers, key)
+		d.mu.Unlock()
+
+		d.expire(ctx, key, deadline)
+	})
+	d.timers[key] = timer
+}
+
+// Stop cancels the active deadline timer of the virtual machine, e.g. when its pod is deleted.
+func (d *ActiveDeadlines) Stop(namespace, name string) {
+	key := types.NamespacedName{Namespace: namespace, Name: name}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if timer, ok := d.timers[key]; ok {
+		timer.Stop()
+		delete(d.timers, key)
+	}
+}
+
+// expire marks the virtual machine as failed unless it has already stopped or failed.
+func (d *ActiveDeadlines) expire(ctx context.Context, key types.NamespacedName, deadline time.Duration) {
+	expired := false
+	d.Data.UpdateVirtualMachineInfo(key.Namespace, key.Name, func(i vmdata.VirtualMachineInfo) vmdata.VirtualMachineInfo {
+		if i.Resource.FinishedAt() == nil && i.Resource.Error() == nil {
+			i.Resource.SetError(resource.ErrDeadlineExceeded)
+			expired = true
+		}
+		return i
+	})
+	if !expired {
+		return
+	}
+
+	log.G(ctx).WithFields(log.Fields{
+		"namespace":             key.Namespace,
+		"name":                  key.Name,
+		"activeDeadlineSeconds": deadline.Seconds(),
+	}).Info("Virtual machine exceeded the active deadline of its pod, failing it")
+}
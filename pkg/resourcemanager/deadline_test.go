@@ -0,0 +1,61 @@
+package resourcemanager_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	vmdata "github.com/agoda-com/macOS-vz-kubelet/internal/data/vm"
+	"github.com/agoda-com/macOS-vz-kubelet/pkg/resource"
+	"github.com/agoda-com/macOS-vz-kubelet/pkg/resourcemanager"
+	"github.com/agoda-com/macOS-vz-kubelet/pkg/vm"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestActiveDeadlines(t *testing.T) {
+	startedAt := time.Now()
+
+	data := &vmdata.VirtualMachineData{}
+	expired := addVirtualMachine(t, data, "expired", &vm.VirtualMachineInstance{StartedAt: &startedAt})
+	deleted := addVirtualMachine(t, data, "deleted", &vm.VirtualMachineInstance{StartedAt: &startedAt})
+
+	deadlines := &resourcemanager.ActiveDeadlines{Data: data}
+	deadlines.Start(context.Background(), expired.Namespace, expired.Name, 50*time.Millisecond)
+	deadlines.Start(context.Background(), deleted.Namespace, deleted.Name, 50*time.Millisecond)
+	deadlines.Stop(deleted.Namespace, deleted.Name)
+
+	assert.Eventually(t, func() bool {
+		info, ok := data.GetVirtualMachineInfo(expired.Namespace, expired.Name)
+		return ok && info.Resource.Error() != nil
+	}, time.Second, 10*time.Millisecond)
+
+	info, ok := data.GetVirtualMachineInfo(expired.Namespace, expired.Name)
+	require.True(t, ok)
+	assert.ErrorIs(t, info.Resource.Error(), resource.ErrDeadlineExceeded)
+	assert.Equal(t, resource.VirtualMachineStateFailed, info.Resource.State())
+
+	// the timer of the deleted virtual machine was canceled
+	time.Sleep(100 * time.Millisecond)
+	info, ok = data.GetVirtualMachineInfo(deleted.Namespace, deleted.Name)
+	require.True(t, ok)
+	assert.NoError(t, info.Resource.Error())
+}
+
+func TestActiveDeadlinesStopped(t *testing.T) {
+	startedAt := time.Now()
+	finishedAt := startedAt.Add(time.Millisecond)
+
+	data := &vmdata.VirtualMachineData{}
+	key := addVirtualMachine(t, data, "stopped", &vm.VirtualMachineInstance{StartedAt: &startedAt, FinishedAt: &finishedAt})
+
+	deadlines := &resourcemanager.ActiveDeadlines{Data: data}
+	deadlines.Start(context.Background(), key.Namespace, key.Name, time.Millisecond)
+
+	// virtual machines that have already stopped are not failed
+	time.Sleep(50 * time.Millisecond)
+	info, ok := data.GetVirtualMachineInfo(key.Namespace, key.Name)
+	require.True(t, ok)
+	assert.NoError(t, info.Resource.Error())
+}
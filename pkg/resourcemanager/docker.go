@@ -1,7 +1,6 @@
 package resourcemanager
 
 import (
-	"bufio"
 	"context"
 	"encoding/json"
 	"errors"
@@ -12,8 +11,8 @@ import (
 	"time"
 
 	containerdata "github.com/agoda-com/macOS-vz-kubelet/internal/data/container"
+	vzio "github.com/agoda-com/macOS-vz-kubelet/internal/io"
 	"github.com/agoda-com/macOS-vz-kubelet/internal/node"
-	"github.com/agoda-com/macOS-vz-kubelet/internal/volumes"
 	"github.com/agoda-com/macOS-vz-kubelet/pkg/event"
 	"github.com/agoda-com/macOS-vz-kubelet/pkg/resource"
 
@@ -23,6 +22,7 @@ import (
 	"github.com/docker/docker/api/types/image"
 	dockercl "github.com/moby/moby/client"
 	"github.com/moby/moby/pkg/stdcopy"
+	"golang.org/x/sync/errgroup"
 
 	"github.com/virtual-kubelet/virtual-kubelet/errdefs"
 	"github.com/virtual-kubelet/virtual-kubelet/log"
@@ -45,6 +45,15 @@ const (
 	DefaultMaxAttempts   = 5                // Default maximum number of retry attempts.
 	DefaultFactor        = 1.6              // Default factor to increase the delay between retries.
 	DefaultJitter        = 0.2              // Default jitter to add to delays.
+
+	// InspectConcurrency is the maximum number of containers inspected concurrently when listing containers.
+	InspectConcurrency = 8
+	// InspectTimeout bounds the inspections of a container listing, containers not inspected in time report the error.
+	InspectTimeout = 10 * time.Second
+
+	// ContainerLogsBufferSize is the most log output of a container buffered ahead of its reader,
+	// reading from Docker pauses while a slow reader catches up.
+	ContainerLogsBufferSize = 256 * 1024
 )
 
 // DockerClient manages Docker containers for pods.
@@ -52,10 +61,21 @@ type DockerClient struct {
 	client        *dockercl.Client
 	eventRecorder event.EventRecorder
 	data          containerdata.ContainerData
+	inspections   *inspectCache
+}
+
+// DockerClientOption configures optional behavior of the DockerClient.
+type DockerClientOption func(*DockerClient)
+
+// WithInspectCacheTTL changes how long inspected container states are reused, zero disables reusing them.
+func WithInspectCacheTTL(ttl time.Duration) DockerClientOption {
+	return func(c *DockerClient) {
+		c.inspections = newInspectCache(ttl, DefaultInspectCacheSize)
+	}
 }
 
 // NewDockerClient initializes a new ContainerClient for docker containers.
-func NewDockerClient(ctx context.Context, client *dockercl.Client, eventRecorder event.EventRecorder) (c *DockerClient, err error) {
+func NewDockerClient(ctx context.Context, client *dockercl.Client, eventRecorder event.EventRecorder, opts ...DockerClientOption) (c *DockerClient, err error) {
 	ctx, span := trace.StartSpan(ctx, "dockerClient.NewDockerClient")
 	defer func() {
 		span.SetStatus(err)
@@ -69,6 +89,10 @@ func NewDockerClient(ctx context.Context, client *dockercl.Client, eventRecorder
 	dockerClient := &DockerClient{
 		client:        client,
 		eventRecorder: eventRecorder,
+		inspections:   newInspectCache(DefaultInspectCacheTTL, DefaultInspectCacheSize),
+	}
+	for _, opt := range opts {
+		opt(dockerClient)
 	}
 
 	containers, err := getActiveContainers(ctx, client)
@@ -143,7 +167,7 @@ func (c *DockerClient) handleDockerContainerCreation(ctx context.Context, params
 	}
 
 	config := createDockerContainerConfig(params)
-	hostConfig := createDockerHostConfigFromMounts(params.Mounts)
+	hostConfig := createDockerHostConfig(params)
 	containerName := getUnderlyingContainerName(params.PodNamespace, params.PodName, params.Name)
 	result, err := c.client.ContainerCreate(ctx, config, hostConfig, nil, nil, containerName)
 	if err != nil {
@@ -186,6 +210,7 @@ func (c *DockerClient) pullImage(ctx context.Context, ref string, containerName
 		span.End()
 	}()
 
+	var pullErr error // error of the last attempt
 	err = wait.ExponentialBackoffWithContext(ctx, wait.Backoff{
 		Duration: DefaultMinRetryDelay, // Base delay to start with
 		Factor:   DefaultFactor,        // Factor to increase the delay between retries
@@ -195,22 +220,26 @@ func (c *DockerClient) pullImage(ctx context.Context, ref string, containerName
 	}, func(ctx context.Context) (done bool, _ error) { // never use condition error
 		reader, err := c.client.ImagePull(ctx, ref, image.PullOptions{})
 		if err != nil {
+			pullErr = err
 			c.eventRecorder.FailedToPullImage(ctx, ref, containerName, err)
-			return err == nil, nil
+			return false, nil
 		}
+		defer reader.Close()
 
-		var line string
-		bufReader := bufio.NewReader(reader)
-		for {
-			line, err = bufReader.ReadString('\n')
-			log.G(ctx).Debug(line)
-			if err != nil {
-				break
-			}
+		err = ReadImagePullStream(ctx, reader, DefaultImagePullProgressInterval, func(progress ImagePullProgress) {
+			c.eventRecorder.PullingImageProgress(ctx, ref, containerName, progress.String())
+		})
+		if err != nil {
+			pullErr = err
+			c.eventRecorder.FailedToPullImage(ctx, ref, containerName, err)
+			return false, nil
 		}
-
-		return err == io.EOF, reader.Close()
+		return true, nil
 	})
+	if err != nil && pullErr != nil && ctx.Err() == nil {
+		// surface the error of the last attempt rather than the exhaustion of the attempts
+		return pullErr
+	}
 
 	return err
 }
@@ -282,6 +311,7 @@ func (c *DockerClient) RemoveContainers(ctx context.Context, podNs, podName stri
 			// Skip containers that were not created
 			continue
 		}
+		c.inspections.remove(containerInfo.ID)
 		// TODO: don't force and rather implement grace period
 		if err := c.client.ContainerRemove(ctx, containerInfo.ID, dockercontainer.RemoveOptions{Force: true, RemoveVolumes: true}); err != nil {
 			errs = append(errs, err)
@@ -304,49 +334,116 @@ func (c *DockerClient) GetContainers(ctx context.Context, podNs, podName string)
 		return nil, errdefs.NotFound("containers not found")
 	}
 
-	return c.getContainersWrapped(ctx, containerInfoMap), nil
+	return c.getContainersWrapped(ctx, k8stypes.NamespacedName{Namespace: podNs, Name: podName}, containerInfoMap), nil
 }
 
 // GetContainersListResult fetches the list of containers for all pods managed by the DockerClient.
 func (c *DockerClient) GetContainersListResult(ctx context.Context) (map[k8stypes.NamespacedName][]resource.Container, error) {
-	_, span := trace.StartSpan(ctx, "DockerClient.GetContainersListResult")
+	ctx, span := trace.StartSpan(ctx, "DockerClient.GetContainersListResult")
 	defer span.End()
 
 	containerData := c.data.GetAllData()
 	result := make(map[k8stypes.NamespacedName][]resource.Container, len(containerData))
+	var containers []podContainer
 	for key, containerInfoMap := range containerData {
-		result[key] = c.getContainersWrapped(ctx, containerInfoMap)
+		result[key] = wrapContainers(containerInfoMap)
+		for i := range result[key] {
+			containers = append(containers, podContainer{pod: key, container: &result[key][i]})
+		}
 	}
+	c.inspectContainers(ctx, containers)
 	return result, nil
 }
 
 // getContainersWrapped retrieves and wraps container details for provided container IDs.
-func (c *DockerClient) getContainersWrapped(ctx context.Context, containerInfoMap map[string]containerdata.ContainerInfo) []resource.Container {
-	logger := log.G(ctx)
-	var containers []resource.Container
+func (c *DockerClient) getContainersWrapped(ctx context.Context, pod k8stypes.NamespacedName, containerInfoMap map[string]containerdata.ContainerInfo) []resource.Container {
+	result := wrapContainers(containerInfoMap)
+	containers := make([]podContainer, len(result))
+	for i := range result {
+		containers[i] = podContainer{pod: pod, container: &result[i]}
+	}
+	c.inspectContainers(ctx, containers)
+	return result
+}
 
+// wrapContainers wraps the container details known without inspecting the containers.
+func wrapContainers(containerInfoMap map[string]containerdata.ContainerInfo) []resource.Container {
+	containers := make([]resource.Container, 0, len(containerInfoMap))
 	for containerName, containerInfo := range containerInfoMap {
 		container := resource.Container{
-			ID:   containerInfo.ID,
-			Name: containerName,
+			ID:           containerInfo.ID,
+			Name:         containerName,
+			RestartCount: containerInfo.RestartCount,
+			LastState:    containerInfo.LastState,
 		}
-
 		if containerInfo.Error != nil {
 			container.State.Error = containerInfo.Error.Error()
-		} else if container.ID != "" {
-			result, err := c.client.ContainerInspect(ctx, containerInfo.ID)
+		}
+		containers = append(containers, container)
+	}
+	return containers
+}
+
+// podContainer is a container along with the pod it belongs to.
+type podContainer struct {
+	pod       k8stypes.NamespacedName
+	container *resource.Container
+}
+
+// inspectContainers fills in the state of the created containers that have not failed, with at most
+// InspectConcurrency inspections in flight, all of them bounded by InspectTimeout.
+// The inspected states are recorded in the container data, so that the state of the previous run
+// of a restarted container is reported as its last state. Recent inspections are reused for the inspect cache TTL.
+func (c *DockerClient) inspectContainers(ctx context.Context, containers []podContainer) {
+	logger := log.G(ctx)
+	ctx, cancel := context.WithTimeout(ctx, InspectTimeout)
+	defer cancel()
+
+	g := errgroup.Group{}
+	g.SetLimit(InspectConcurrency)
+	for _, pc := range containers {
+		container := pc.container
+		if container.ID == "" || container.State.Error != "" {
+			continue
+		}
+		g.Go(func() error {
+			inspection, err := c.inspectContainer(ctx, container.ID)
 			if err != nil {
-				logger.WithError(err).Warnf("failed to inspect container %s", containerInfo.ID)
+				logger.WithError(err).Warnf("failed to inspect container %s", container.ID)
 				container.State.Error = err.Error()
-			} else {
-				container.State = containerStateFromDockerState(ctx, result.State)
+				return nil
 			}
-		}
+			container.State = inspection.state
 
-		containers = append(containers, container)
+			info, ok := c.data.UpdateContainerInfo(pc.pod.Namespace, pc.pod.Name, container.Name, func(info containerdata.ContainerInfo) containerdata.ContainerInfo {
+				return info.WithObservedState(inspection.state, inspection.restartCount)
+			})
+			if ok {
+				container.RestartCount = info.RestartCount
+				container.LastState = info.LastState
+			}
+			return nil
+		})
 	}
+	_ = g.Wait() // inspection errors are reported in the container states
+}
 
-	return containers
+// inspectContainer inspects the container unless it was inspected within the inspect cache TTL.
+func (c *DockerClient) inspectContainer(ctx context.Context, id string) (containerInspection, error) {
+	if inspection, ok := c.inspections.get(id); ok {
+		return inspection, nil
+	}
+
+	result, err := c.client.ContainerInspect(ctx, id)
+	if err != nil {
+		return containerInspection{}, err
+	}
+	inspection := containerInspection{state: containerStateFromDockerState(ctx, result.State)}
+	if result.ContainerJSONBase != nil {
+		inspection.restartCount = result.RestartCount
+	}
+	c.inspections.add(id, inspection)
+	return inspection, nil
 }
 
 // GetContainerLogs retrieves the logs for a specific docker container.
@@ -379,25 +476,51 @@ func (c *DockerClient) GetContainerLogs(ctx context.Context, namespace, podName,
 		Details:    false, // Assuming no details are needed
 	}
 
+	// The Docker stream lives as long as the request, or until the logs are closed
+	ctx, cancel := context.WithCancel(ctx)
 	stream, err := c.client.ContainerLogs(ctx, getUnderlyingContainerName(namespace, podName, containerName), dockerOpts)
 	if err != nil {
+		cancel()
 		return nil, err
 	}
 
-	// Create a pipe to handle the combined stdout and stderr streams
-	pr, pw := io.Pipe()
+	// Combine the stdout and stderr streams into a bounded pipe
+	pr, pw := vzio.NewBoundedPipe(ContainerLogsBufferSize)
+	logs := &containerLogs{PipeReader: pr, cancel: cancel, done: make(chan struct{})}
 
 	go func() {
+		defer close(logs.done)
 		_, err := stdcopy.StdCopy(pw, pw, stream)
-		if err != nil {
-			pw.CloseWithError(err)
+		_ = pw.CloseWithError(err)
+		if err := stream.Close(); err != nil {
+			log.G(ctx).WithError(err).Debug("Failed to close container logs stream")
 		}
-		if err := pw.Close(); err != nil {
-			log.G(ctx).WithError(err).Warn("failed to close pipe writer")
+	}()
+	go func() {
+		// a reader gone with the request would otherwise block the copy on the full buffer
+		select {
+		case <-ctx.Done():
+			_ = pr.CloseWithError(ctx.Err())
+		case <-logs.done:
 		}
 	}()
 
-	return pr, nil
+	return logs, nil
+}
+
+// containerLogs is the log stream of a container, which stops copying from Docker once closed.
+type containerLogs struct {
+	*vzio.PipeReader
+	cancel context.CancelFunc
+	done   chan struct{} // closed once the copy from Docker has returned
+}
+
+// Close closes the Docker stream and waits for the copy to return.
+func (l *containerLogs) Close() error {
+	l.cancel()
+	err := l.PipeReader.Close()
+	<-l.done
+	return err
 }
 
 // ExecInContainer executes a command in a specific container of a pod.
@@ -444,7 +567,7 @@ func (c *DockerClient) ExecInContainer(ctx context.Context, namespace, name, con
 
 	if isTTY {
 		// Handle I/O asynchronously if TTY is enabled
-		return c.handleContainerIO(hr, attach.Stdin(), attach.Stdout(), attach.Stderr())
+		return c.handleContainerIO(hr, isTTY, attach.Stdin(), attach.Stdout(), attach.Stderr())
 	} else {
 		// Handle I/O synchronously if TTY is not enabled
 		return c.handleContainerIOSync(hr, attach.Stdin(), attach.Stdout(), attach.Stderr())
@@ -469,8 +592,10 @@ func (c *DockerClient) AttachToContainer(ctx context.Context, namespace, name, c
 		})
 	}
 
+	// Replay the output logged so far and keep streaming the live output afterwards,
+	// regardless of whether the container has a TTY
 	hr, err := c.client.ContainerAttach(ctx, getUnderlyingContainerName(namespace, name, containerName), dockercontainer.AttachOptions{
-		Stream: attach.TTY(),
+		Stream: true,
 		Stdin:  attach.Stdin() != nil,
 		Stdout: attach.Stdout() != nil,
 		Stderr: attach.Stderr() != nil,
@@ -481,33 +606,55 @@ func (c *DockerClient) AttachToContainer(ctx context.Context, namespace, name, c
 	}
 	defer hr.Close()
 
-	return c.handleContainerIO(hr, attach.Stdin(), attach.Stdout(), attach.Stderr())
+	return c.handleContainerIO(hr, attach.TTY(), attach.Stdin(), attach.Stdout(), attach.Stderr())
 }
 
 // handleContainerIO manages the IO between attached streams and the container's streams.
-func (c *DockerClient) handleContainerIO(hr types.HijackedResponse, stdin io.Reader, stdout, stderr io.Writer) error {
-	doneCh := make(chan error, 1)
-
+// It returns once the container output ends or copying the input fails.
+func (c *DockerClient) handleContainerIO(hr types.HijackedResponse, tty bool, stdin io.Reader, stdout, stderr io.Writer) error {
+	var inputCh chan error // nil channel never receives, when there is no input
 	if stdin != nil {
+		inputCh = make(chan error, 1)
 		go func() {
 			_, err := io.Copy(hr.Conn, stdin)
-			doneCh <- err
-			doneCh <- hr.CloseWrite()
+			inputCh <- errors.Join(err, hr.CloseWrite())
 		}()
 	}
 
-	if stdout != nil {
-		errWriter := stdout
-		if stderr != nil {
-			errWriter = stderr
+	outputCh := make(chan error, 1)
+	go func() {
+		outputCh <- copyContainerOutput(hr.Reader, tty, stdout, stderr)
+	}()
+
+	for {
+		select {
+		case err := <-inputCh:
+			if err != nil {
+				return err
+			}
+			// input is closed, keep streaming the output
+			inputCh = nil
+		case err := <-outputCh:
+			return err
 		}
-		go func() {
-			_, err := stdcopy.StdCopy(stdout, errWriter, hr.Reader)
-			doneCh <- err
-		}()
 	}
+}
 
-	return <-doneCh
+// copyContainerOutput copies the container output to the writers until the output ends.
+// The output of a TTY container is raw, otherwise stdout and stderr are multiplexed in a single stream.
+func copyContainerOutput(r io.Reader, tty bool, stdout, stderr io.Writer) error {
+	if stdout == nil {
+		stdout = io.Discard
+	}
+	if tty {
+		_, err := io.Copy(stdout, r)
+		return err
+	}
+	if stderr == nil {
+		stderr = io.Discard
+	}
+	_, err := stdcopy.StdCopy(stdout, stderr, r)
+	return err
 }
 
 // handleContainerIOSync manages the IO between attached streams and the container's streams synchronously.
@@ -654,8 +801,14 @@ func createDockerContainerConfig(params ContainerParams) *dockercontainer.Config
 		volumes[m.ContainerPath] = struct{}{}
 	}
 
+	hostname := params.Hostname
+	if hostname == "" {
+		hostname = params.PodName
+	}
+
 	return &dockercontainer.Config{
-		Hostname:   params.PodName,
+		Hostname:   hostname,
+		Domainname: params.Domainname,
 		Env:        env,
 		Entrypoint: params.Command,
 		Cmd:        params.Args,
@@ -668,10 +821,10 @@ func createDockerContainerConfig(params ContainerParams) *dockercontainer.Config
 	}
 }
 
-// createDockerHostConfigFromMounts converts a list of Kubernetes volume mounts to Docker host configurations.
-func createDockerHostConfigFromMounts(mounts []volumes.Mount) *dockercontainer.HostConfig {
-	binds := make([]string, len(mounts))
-	for i, m := range mounts {
+// createDockerHostConfig creates a Docker host configuration from Kubernetes container parameters.
+func createDockerHostConfig(params ContainerParams) *dockercontainer.HostConfig {
+	binds := make([]string, len(params.Mounts))
+	for i, m := range params.Mounts {
 		readonly := "rw"
 		if m.ReadOnly {
 			readonly = "ro"
@@ -680,7 +833,8 @@ func createDockerHostConfigFromMounts(mounts []volumes.Mount) *dockercontainer.H
 	}
 
 	return &dockercontainer.HostConfig{
-		Binds: binds,
+		Binds:          binds,
+		ReadonlyRootfs: params.ReadOnlyRootFilesystem,
 	}
 }
 
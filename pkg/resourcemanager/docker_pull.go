@@ -0,0 +1,166 @@
+package resourcemanager
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/virtual-kubelet/virtual-kubelet/log"
+)
+
+// DefaultImagePullProgressInterval is the default interval between the progress reports of a Docker image pull.
+const DefaultImagePullProgressInterval = 10 * time.Second
+
+// pullMessage is a JSON message of the Docker image pull stream.
+type pullMessage struct {
+	ID             string `json:"id"`
+	Status         string `json:"status"`
+	ProgressDetail struct {
+		Current int64 `json:"current"`
+		Total   int64 `json:"total"`
+	} `json:"progressDetail"`
+	ErrorDetail *struct {
+		Message string `json:"message"`
+	} `json:"errorDetail"`
+	Error string `json:"error"`
+}
+
+// LayerPullProgress is the progress of a single layer of a Docker image pull.
+type LayerPullProgress struct {
+	ID      string
+	Status  string
+	Current int64 // bytes downloaded
+	Total   int64 // size of the layer, zero if unknown yet
+}
+
+// Percent returns the percent of the layer downloaded.
+func (l LayerPullProgress) Percent() int {
+	switch {
+	case l.Done():
+		return 100
+	case l.Total <= 0:
+		return 0
+	}
+	return int(min(l.Current*100/l.Total, 100))
+}
+
+// Done reports whether the layer is available locally.
+func (l LayerPullProgress) Done() bool {
+	return l.Status == "Pull complete" || l.Status == "Already exists"
+}
+
+// ImagePullProgress is the progress of a Docker image pull.
+type ImagePullProgress struct {
+	Layers []LayerPullProgress // in the order the pull stream mentions them
+}
+
+// Percent returns the percent of the image downloaded, counting the layers whose size is known.
+func (p ImagePullProgress) Percent() int {
+	var current, total int64
+	for _, l := range p.Layers {
+		if l.Total <= 0 {
+			continue
+		}
+		if l.Done() {
+			current += l.Total
+		} else {
+			current += min(l.Current, l.Total)
+		}
+		total += l.Total
+	}
+	if total == 0 {
+		return 0
+	}
+	return int(current * 100 / total)
+}
+
+// String returns a human-readable summary of the progress.
+func (p ImagePullProgress) String() string {
+	done := 0
+	for _, l := range p.Layers {
+		if l.Done() {
+			done++
+		}
+	}
+	return fmt.Sprintf("%d of %d layers complete, %d%% downloaded", done, len(p.Layers), p.Percent())
+}
+
+// update applies the layer message of the pull stream to the progress.
+func (p *ImagePullProgress) update(msg pullMessage) {
+	i := -1
+	for j := range p.Layers {
+		if p.Layers[j].ID == msg.ID {
+			i = j
+			break
+		}
+	}
+	if i < 0 {
+		p.Layers = append(p.Layers, LayerPullProgress{ID: msg.ID})
+		i = len(p.Layers) - 1
+	}
+
+	l := &p.Layers[i]
+	l.Status = msg.Status
+	// the progress details of the other statuses describe the extraction, not the download
+	if msg.Status == "Downloading" {
+		l.Current, l.Total = msg.ProgressDetail.Current, msg.ProgressDetail.Total
+	} else if msg.Status == "Download complete" && l.Total > 0 {
+		l.Current = l.Total
+	}
+}
+
+// ReadImagePullStream reads the JSON messages of the Docker image pull stream until its end.
+// The progress is passed to report on the first layer update and then at most once per interval.
+// It returns the error reported by the stream, if any.
+func ReadImagePullStream(ctx context.Context, r io.Reader, interval time.Duration, report func(ImagePullProgress)) error {
+	logger := log.G(ctx)
+
+	var (
+		progress ImagePullProgress
+		reported time.Time
+	)
+	decoder := json.NewDecoder(r)
+	for {
+		var msg pullMessage
+		if err := decoder.Decode(&msg); err != nil {
+			if errors.Is(err, io.EOF) {
+				return nil
+			}
+			return fmt.Errorf("failed to read image pull stream: %w", err)
+		}
+
+		switch {
+		case msg.ErrorDetail != nil && msg.ErrorDetail.Message != "":
+			return errors.New(msg.ErrorDetail.Message)
+		case msg.Error != "":
+			return errors.New(msg.Error)
+		case msg.ID == "" || strings.HasPrefix(msg.Status, "Pulling from"):
+			// not a layer message, e.g. the digest of the image
+			logger.Debug(msg.Status)
+			continue
+		}
+
+		progress.update(msg)
+		if !reported.IsZero() && time.Since(reported) < interval {
+			continue
+		}
+		reported = time.Now()
+
+		for _, l := range progress.Layers {
+			logger.WithFields(log.Fields{
+				"layer":   l.ID,
+				"status":  l.Status,
+				"percent": l.Percent(),
+			}).Debug("Image layer pull progress")
+		}
+		logger.WithFields(log.Fields{
+			"layers":  len(progress.Layers),
+			"percent": progress.Percent(),
+		}).Info("Image pull progress")
+		report(progress)
+	}
+}
@@ -0,0 +1,79 @@
+package resourcemanager_test
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/agoda-com/macOS-vz-kubelet/pkg/resourcemanager"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const cannedPullStream = `{"status":"Pulling from library/nginx","id":"latest"}
+{"status":"Already exists","progressDetail":{},"id":"aaa"}
+{"status":"Pulling fs layer","progressDetail":{},"id":"bbb"}
+{"status":"Pulling fs layer","progressDetail":{},"id":"ccc"}
+{"status":"Downloading","progressDetail":{"current":50,"total":100},"progress":"[=====>     ]","id":"bbb"}
+{"status":"Downloading","progressDetail":{"current":100,"total":300},"progress":"[==>        ]","id":"ccc"}
+{"status":"Download complete","progressDetail":{},"id":"bbb"}
+{"status":"Extracting","progressDetail":{"current":10,"total":100},"id":"bbb"}
+{"status":"Pull complete","progressDetail":{},"id":"bbb"}
+{"status":"Downloading","progressDetail":{"current":300,"total":300},"id":"ccc"}
+{"status":"Pull complete","progressDetail":{},"id":"ccc"}
+{"status":"Digest: sha256:0000000000000000000000000000000000000000000000000000000000000000"}
+{"status":"Status: Downloaded newer image for nginx:latest"}
+`
+
+func TestReadImagePullStream(t *testing.T) {
+	var reports []string
+	var last resourcemanager.ImagePullProgress
+	err := resourcemanager.ReadImagePullStream(context.Background(), strings.NewReader(cannedPullStream), 0, func(p resourcemanager.ImagePullProgress) {
+		reports = append(reports, p.String())
+		last = p
+	})
+	require.NoError(t, err)
+
+	assert.Equal(t, []string{
+		"1 of 1 layers complete, 0% downloaded",
+		"1 of 2 layers complete, 0% downloaded",
+		"1 of 3 layers complete, 0% downloaded",
+		"1 of 3 layers complete, 50% downloaded",
+		"1 of 3 layers complete, 37% downloaded",
+		"1 of 3 layers complete, 50% downloaded",
+		"1 of 3 layers complete, 50% downloaded",
+		"2 of 3 layers complete, 50% downloaded",
+		"2 of 3 layers complete, 100% downloaded",
+		"3 of 3 layers complete, 100% downloaded",
+	}, reports)
+
+	require.Len(t, last.Layers, 3)
+	for i, id := range []string{"aaa", "bbb", "ccc"} {
+		assert.Equal(t, id, last.Layers[i].ID)
+		assert.Equal(t, 100, last.Layers[i].Percent())
+	}
+}
+
+func TestReadImagePullStreamInterval(t *testing.T) {
+	reports := 0
+	err := resourcemanager.ReadImagePullStream(context.Background(), strings.NewReader(cannedPullStream), resourcemanager.DefaultImagePullProgressInterval,
+		func(resourcemanager.ImagePullProgress) { reports++ })
+	require.NoError(t, err)
+
+	// only the first update is reported within the interval
+	assert.Equal(t, 1, reports)
+}
+
+func TestReadImagePullStreamError(t *testing.T) {
+	stream := `{"status":"Pulling fs layer","progressDetail":{},"id":"bbb"}
+{"errorDetail":{"message":"manifest for nginx:missing not found: manifest unknown"},"error":"manifest for nginx:missing not found: manifest unknown"}
+{"status":"Pull complete","progressDetail":{},"id":"bbb"}
+`
+	reports := 0
+	err := resourcemanager.ReadImagePullStream(context.Background(), strings.NewReader(stream), 0,
+		func(resourcemanager.ImagePullProgress) { reports++ })
+
+	assert.EqualError(t, err, "manifest for nginx:missing not found: manifest unknown")
+	assert.Equal(t, 1, reports)
+}
@@ -1,6 +1,596 @@
 package resourcemanager_test
 
-import "github.com/agoda-com/macOS-vz-kubelet/pkg/resourcemanager"
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/agoda-com/macOS-vz-kubelet/internal/volumes"
+	"github.com/agoda-com/macOS-vz-kubelet/pkg/event"
+	"github.com/agoda-com/macOS-vz-kubelet/pkg/resource"
+	"github.com/agoda-com/macOS-vz-kubelet/pkg/resourcemanager"
+
+	dockercontainer "github.com/docker/docker/api/types/container"
+	dockercl "github.com/moby/moby/client"
+	"github.com/moby/moby/pkg/stdcopy"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/virtual-kubelet/virtual-kubelet/node/api"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+)
 
 // check that DockerClient implements the ContainersClient interface
 var _ resourcemanager.ContainersClient = &resourcemanager.DockerClient{}
+
+// notifyingWriter is a writer that signals once the written content contains the expected string.
+type notifyingWriter struct {
+	mu       sync.Mutex
+	buf      bytes.Buffer
+	expected string
+	once     sync.Once
+	notify   chan struct{}
+}
+
+func newNotifyingWriter(expected string) *notifyingWriter {
+	return &notifyingWriter{expected: expected, notify: make(chan struct{})}
+}
+
+func (w *notifyingWriter) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	n, err := w.buf.Write(p)
+	if strings.Contains(w.buf.String(), w.expected) {
+		w.once.Do(func() { close(w.notify) })
+	}
+	return n, err
+}
+
+func (w *notifyingWriter) Close() error { return nil }
+
+func (w *notifyingWriter) String() string {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.buf.String()
+}
+
+// fakeAttachIO is an api.AttachIO without stdin.
+type fakeAttachIO struct {
+	stdout, stderr io.WriteCloser
+	tty            bool
+}
+
+func (a *fakeAttachIO) Stdin() io.Reader            { return nil }
+func (a *fakeAttachIO) Stdout() io.WriteCloser      { return a.stdout }
+func (a *fakeAttachIO) Stderr() io.WriteCloser      { return a.stderr }
+func (a *fakeAttachIO) TTY() bool                   { return a.tty }
+func (a *fakeAttachIO) Resize() <-chan api.TermSize { return nil }
+
+func TestDockerClientAttachToContainer(t *testing.T) {
+	const (
+		historical = "historical output\n"
+		live       = "live output\n"
+		liveErr    = "live error\n"
+	)
+
+	tests := []struct {
+		name           string
+		tty            bool
+		expectedStdout string
+		expectedStderr string
+	}{
+		{
+			name:           "multiplexed output",
+			expectedStdout: historical + live,
+			expectedStderr: liveErr,
+		},
+		{
+			name:           "TTY output",
+			tty:            true,
+			expectedStdout: historical + live + liveErr,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+
+			stdout := newNotifyingWriter(historical)
+			stderr := newNotifyingWriter(liveErr)
+			queries := make(chan url.Values, 1)
+
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				switch {
+				case strings.HasSuffix(r.URL.Path, "/containers/json"):
+					w.Header().Set("Content-Type", "application/json")
+					_, _ = w.Write([]byte("[]"))
+				case strings.HasSuffix(r.URL.Path, "/containers/macos-vz_default_pod_sidecar/attach"):
+					queries <- r.URL.Query()
+					conn, _, err := w.(http.Hijacker).Hijack()
+					if !assert.NoError(t, err) {
+						return
+					}
+					defer conn.Close()
+					_, _ = conn.Write([]byte("HTTP/1.1 101 UPGRADED\r\nConnection: Upgrade\r\nUpgrade: tcp\r\n\r\n"))
+
+					outWriter, errWriter := io.Writer(conn), io.Writer(conn)
+					if !tt.tty {
+						outWriter, errWriter = stdcopy.NewStdWriter(conn, stdcopy.Stdout), stdcopy.NewStdWriter(conn, stdcopy.Stderr)
+					}
+
+					// replay the logs first, the live output is only produced once the logs were received
+					_, _ = outWriter.Write([]byte(historical))
+					select {
+					case <-stdout.notify:
+					case <-r.Context().Done():
+						return
+					}
+					_, _ = outWriter.Write([]byte(live))
+					_, _ = errWriter.Write([]byte(liveErr))
+				default:
+					http.NotFound(w, r)
+				}
+			}))
+			defer srv.Close()
+
+			cl, err := dockercl.NewClientWithOpts(dockercl.WithHost("tcp://" + srv.Listener.Addr().String()))
+			require.NoError(t, err)
+			dockerClient, err := resourcemanager.NewDockerClient(ctx, cl, nil)
+			require.NoError(t, err)
+
+			err = dockerClient.AttachToContainer(ctx, "default", "pod", "sidecar", &fakeAttachIO{stdout: stdout, stderr: stderr, tty: tt.tty})
+			require.NoError(t, err)
+
+			query := <-queries
+			assert.Equal(t, "1", query.Get("stream"))
+			assert.Equal(t, "1", query.Get("logs"))
+			assert.Empty(t, query.Get("stdin"))
+			assert.Equal(t, tt.expectedStdout, stdout.String())
+			assert.Equal(t, tt.expectedStderr, stderr.String())
+		})
+	}
+}
+
+func TestDockerClientReadOnlyRootFilesystem(t *testing.T) {
+	for _, readOnly := range []bool{false, true} {
+		t.Run(strconv.FormatBool(readOnly), func(t *testing.T) {
+			ctx := context.Background()
+			created := make(chan dockercontainer.HostConfig, 1)
+
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Type", "application/json")
+				switch {
+				case strings.HasSuffix(r.URL.Path, "/containers/json"):
+					_, _ = w.Write([]byte("[]"))
+				case strings.HasSuffix(r.URL.Path, "/containers/create"):
+					var body struct{ HostConfig dockercontainer.HostConfig }
+					assert.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+					created <- body.HostConfig
+					_, _ = w.Write([]byte(`{"Id":"abc"}`))
+				case strings.HasSuffix(r.URL.Path, "/containers/abc/start"):
+					w.WriteHeader(http.StatusNoContent)
+				default:
+					http.NotFound(w, r)
+				}
+			}))
+			defer srv.Close()
+
+			cl, err := dockercl.NewClientWithOpts(dockercl.WithHost("tcp://" + srv.Listener.Addr().String()))
+			require.NoError(t, err)
+			dockerClient, err := resourcemanager.NewDockerClient(ctx, cl, event.LogEventRecorder{})
+			require.NoError(t, err)
+
+			err = dockerClient.CreateContainer(ctx, resourcemanager.ContainerParams{
+				PodNamespace:    "default",
+				PodName:         "pod",
+				Name:            "sidecar",
+				Image:           "busybox",
+				ImagePullPolicy: corev1.PullNever,
+				Mounts:          []volumes.Mount{{HostPath: "/tmp/data", ContainerPath: "/data", ReadOnly: true}},
+
+				ReadOnlyRootFilesystem: readOnly,
+			})
+			require.NoError(t, err)
+
+			select {
+			case hostConfig := <-created:
+				assert.Equal(t, readOnly, hostConfig.ReadonlyRootfs)
+				assert.Equal(t, []string{"/tmp/data:/data:ro"}, hostConfig.Binds)
+			case <-time.After(5 * time.Second):
+				t.Fatal("container was not created")
+			}
+		})
+	}
+}
+
+func TestDockerClientHostname(t *testing.T) {
+	tests := []struct {
+		name                             string
+		hostname, domainname             string
+		expectedHostname, expectedDomain string
+	}{
+		{name: "Pod name", expectedHostname: "pod"},
+		{name: "Spec hostname", hostname: "builder-0", domainname: "builders.default.svc", expectedHostname: "builder-0", expectedDomain: "builders.default.svc"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			created := make(chan dockercontainer.Config, 1)
+
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Type", "application/json")
+				switch {
+				case strings.HasSuffix(r.URL.Path, "/containers/json"):
+					_, _ = w.Write([]byte("[]"))
+				case strings.HasSuffix(r.URL.Path, "/containers/create"):
+					var config dockercontainer.Config
+					assert.NoError(t, json.NewDecoder(r.Body).Decode(&config))
+					created <- config
+					_, _ = w.Write([]byte(`{"Id":"abc"}`))
+				case strings.HasSuffix(r.URL.Path, "/containers/abc/start"):
+					w.WriteHeader(http.StatusNoContent)
+				default:
+					http.NotFound(w, r)
+				}
+			}))
+			defer srv.Close()
+
+			cl, err := dockercl.NewClientWithOpts(dockercl.WithHost("tcp://" + srv.Listener.Addr().String()))
+			require.NoError(t, err)
+			dockerClient, err := resourcemanager.NewDockerClient(ctx, cl, event.LogEventRecorder{})
+			require.NoError(t, err)
+
+			require.NoError(t, dockerClient.CreateContainer(ctx, resourcemanager.ContainerParams{
+				PodNamespace:    "default",
+				PodName:         "pod",
+				Name:            "sidecar",
+				Image:           "busybox",
+				ImagePullPolicy: corev1.PullNever,
+				Hostname:        tt.hostname,
+				Domainname:      tt.domainname,
+			}))
+
+			select {
+			case config := <-created:
+				assert.Equal(t, tt.expectedHostname, config.Hostname)
+				assert.Equal(t, tt.expectedDomain, config.Domainname)
+			case <-time.After(5 * time.Second):
+				t.Fatal("container was not created")
+			}
+		})
+	}
+}
+
+func TestDockerClientGetContainersListResultConcurrency(t *testing.T) {
+	const (
+		pods         = 6
+		containers   = 4
+		inspectDelay = 100 * time.Millisecond
+	)
+	ctx := context.Background()
+
+	var (
+		started               sync.WaitGroup
+		mu                    sync.Mutex
+		inFlight, maxInFlight int
+	)
+	started.Add(pods * containers)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch {
+		case strings.HasSuffix(r.URL.Path, "/containers/json"):
+			_, _ = w.Write([]byte("[]"))
+		case strings.HasSuffix(r.URL.Path, "/containers/create"):
+			_, _ = fmt.Fprintf(w, `{"Id":%q}`, r.URL.Query().Get("name"))
+		case strings.HasSuffix(r.URL.Path, "/start"):
+			w.WriteHeader(http.StatusNoContent)
+			started.Done()
+		case strings.HasSuffix(r.URL.Path, "/json"):
+			// a slow inspect, tracking how many are served at once
+			mu.Lock()
+			inFlight++
+			maxInFlight = max(maxInFlight, inFlight)
+			mu.Unlock()
+			time.Sleep(inspectDelay)
+			mu.Lock()
+			inFlight--
+			mu.Unlock()
+			_, _ = w.Write([]byte(`{"State":{"Status":"running","Running":true}}`))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+
+	cl, err := dockercl.NewClientWithOpts(dockercl.WithHost("tcp://" + srv.Listener.Addr().String()))
+	require.NoError(t, err)
+	dockerClient, err := resourcemanager.NewDockerClient(ctx, cl, event.LogEventRecorder{})
+	require.NoError(t, err)
+
+	for pod := range pods {
+		for container := range containers {
+			require.NoError(t, dockerClient.CreateContainer(ctx, resourcemanager.ContainerParams{
+				PodNamespace:    "default",
+				PodName:         fmt.Sprintf("pod-%d", pod),
+				Name:            fmt.Sprintf("sidecar-%d", container),
+				Image:           "busybox",
+				ImagePullPolicy: corev1.PullNever,
+			}))
+		}
+	}
+	started.Wait()
+
+	start := time.Now()
+	result, err := dockerClient.GetContainersListResult(ctx)
+	elapsed := time.Since(start)
+	require.NoError(t, err)
+
+	require.Len(t, result, pods)
+	for pod := range pods {
+		podContainers := result[types.NamespacedName{Namespace: "default", Name: fmt.Sprintf("pod-%d", pod)}]
+		require.Len(t, podContainers, containers)
+		for _, container := range podContainers {
+			assert.Equal(t, resource.ContainerStatusRunning, container.State.Status, container.Name)
+		}
+	}
+
+	// serial inspections would take pods*containers*inspectDelay
+	assert.Less(t, elapsed, pods*containers*inspectDelay/2)
+	mu.Lock()
+	defer mu.Unlock()
+	assert.Greater(t, maxInFlight, 1)
+	assert.LessOrEqual(t, maxInFlight, resourcemanager.InspectConcurrency)
+}
+
+func TestDockerClientGetContainersLastStateAfterRestart(t *testing.T) {
+	ctx := context.Background()
+	startedAt := time.Date(2012, 12, 12, 12, 12, 12, 0, time.UTC)
+	exitedAt := startedAt.Add(time.Minute)
+	restartedAt := exitedAt.Add(time.Second)
+
+	// the inspections observe the container running, exiting and running again after a restart
+	inspections := []string{
+		fmt.Sprintf(`{"RestartCount":0,"State":{"Status":"running","Running":true,"StartedAt":%q,"FinishedAt":"0001-01-01T00:00:00Z"}}`, startedAt.Format(time.RFC3339)),
+		fmt.Sprintf(`{"RestartCount":0,"State":{"Status":"exited","ExitCode":2,"StartedAt":%q,"FinishedAt":%q}}`, startedAt.Format(time.RFC3339), exitedAt.Format(time.RFC3339)),
+		fmt.Sprintf(`{"RestartCount":1,"State":{"Status":"running","Running":true,"StartedAt":%q,"FinishedAt":%q}}`, restartedAt.Format(time.RFC3339), exitedAt.Format(time.RFC3339)),
+	}
+	var inspected atomic.Int32
+	started := make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch {
+		case strings.HasSuffix(r.URL.Path, "/containers/json"):
+			_, _ = w.Write([]byte("[]"))
+		case strings.HasSuffix(r.URL.Path, "/containers/create"):
+			_, _ = w.Write([]byte(`{"Id":"abc"}`))
+		case strings.HasSuffix(r.URL.Path, "/containers/abc/start"):
+			w.WriteHeader(http.StatusNoContent)
+			close(started)
+		case strings.HasSuffix(r.URL.Path, "/containers/abc/json"):
+			_, _ = w.Write([]byte(inspections[inspected.Add(1)-1]))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+
+	cl, err := dockercl.NewClientWithOpts(dockercl.WithHost("tcp://" + srv.Listener.Addr().String()))
+	require.NoError(t, err)
+	dockerClient, err := resourcemanager.NewDockerClient(ctx, cl, event.LogEventRecorder{}, resourcemanager.WithInspectCacheTTL(0)) // observe every inspection
+	require.NoError(t, err)
+	require.NoError(t, dockerClient.CreateContainer(ctx, resourcemanager.ContainerParams{
+		PodNamespace:    "default",
+		PodName:         "pod",
+		Name:            "sidecar",
+		Image:           "busybox",
+		ImagePullPolicy: corev1.PullNever,
+	}))
+	select {
+	case <-started:
+	case <-time.After(5 * time.Second):
+		t.Fatal("container was not started")
+	}
+	require.Eventually(t, func() bool {
+		containers, err := dockerClient.GetContainers(ctx, "default", "pod")
+		return err == nil && len(containers) == 1 && containers[0].ID == "abc"
+	}, 5*time.Second, 10*time.Millisecond)
+
+	// the first inspection was consumed while waiting for the container, then it exits and restarts
+	var containers []resource.Container
+	for range len(inspections) - int(inspected.Load()) {
+		containers, err = dockerClient.GetContainers(ctx, "default", "pod")
+		require.NoError(t, err)
+	}
+	require.Len(t, containers, 1)
+	container := containers[0]
+	assert.Equal(t, resource.ContainerStatusRunning, container.State.Status)
+	assert.Equal(t, restartedAt, container.State.StartedAt)
+	assert.Equal(t, 1, container.RestartCount)
+	require.NotNil(t, container.LastState)
+	assert.Equal(t, resource.ContainerState{Status: resource.ContainerStatusDead, StartedAt: startedAt, FinishedAt: exitedAt, ExitCode: 2}, *container.LastState)
+}
+
+func TestDockerClientGetContainersInspectCache(t *testing.T) {
+	ctx := context.Background()
+
+	// the TTL is long enough for the repeated calls to reuse the first inspection even on slow machines
+	dockerClient, inspected := newInspectedDockerClient(t, time.Minute)
+	for range 5 {
+		containers, err := dockerClient.GetContainers(ctx, "default", "pod")
+		require.NoError(t, err)
+		require.Len(t, containers, 1)
+		assert.Equal(t, resource.ContainerStatusRunning, containers[0].State.Status)
+	}
+	_, err := dockerClient.GetContainersListResult(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, int32(1), inspected.Load())
+
+	// the inspection expires after the TTL
+	const ttl = 50 * time.Millisecond
+	dockerClient, inspected = newInspectedDockerClient(t, ttl)
+	before := inspected.Load()
+	time.Sleep(ttl + 50*time.Millisecond)
+	containers, err := dockerClient.GetContainers(ctx, "default", "pod")
+	require.NoError(t, err)
+	require.Len(t, containers, 1)
+	assert.Greater(t, inspected.Load(), before)
+}
+
+// newInspectedDockerClient returns a client with the inspect cache TTL running a single container, which
+// has been listed already, along with the number of inspections of the container.
+func newInspectedDockerClient(t *testing.T, ttl time.Duration) (*resourcemanager.DockerClient, *atomic.Int32) {
+	t.Helper()
+	ctx := context.Background()
+
+	inspected := &atomic.Int32{}
+	started := make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch {
+		case strings.HasSuffix(r.URL.Path, "/containers/json"):
+			_, _ = w.Write([]byte("[]"))
+		case strings.HasSuffix(r.URL.Path, "/containers/create"):
+			_, _ = w.Write([]byte(`{"Id":"abc"}`))
+		case strings.HasSuffix(r.URL.Path, "/containers/abc/start"):
+			w.WriteHeader(http.StatusNoContent)
+			close(started)
+		case strings.HasSuffix(r.URL.Path, "/containers/abc/json"):
+			inspected.Add(1)
+			_, _ = w.Write([]byte(`{"State":{"Status":"running","Running":true}}`))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	t.Cleanup(srv.Close)
+
+	cl, err := dockercl.NewClientWithOpts(dockercl.WithHost("tcp://" + srv.Listener.Addr().String()))
+	require.NoError(t, err)
+	dockerClient, err := resourcemanager.NewDockerClient(ctx, cl, event.LogEventRecorder{}, resourcemanager.WithInspectCacheTTL(ttl))
+	require.NoError(t, err)
+	require.NoError(t, dockerClient.CreateContainer(ctx, resourcemanager.ContainerParams{
+		PodNamespace:    "default",
+		PodName:         "pod",
+		Name:            "sidecar",
+		Image:           "busybox",
+		ImagePullPolicy: corev1.PullNever,
+	}))
+	select {
+	case <-started:
+	case <-time.After(5 * time.Second):
+		t.Fatal("container was not started")
+	}
+	require.Eventually(t, func() bool {
+		containers, err := dockerClient.GetContainers(ctx, "default", "pod")
+		return err == nil && len(containers) == 1 && containers[0].ID == "abc"
+	}, 5*time.Second, 10*time.Millisecond)
+	return dockerClient, inspected
+}
+
+func TestDockerClientGetContainerLogs(t *testing.T) {
+	tests := []struct {
+		name string
+		stop func(cancel context.CancelFunc, logs io.ReadCloser) error
+	}{
+		{
+			name: "reader closes the logs",
+			stop: func(_ context.CancelFunc, logs io.ReadCloser) error {
+				return logs.Close()
+			},
+		},
+		{
+			name: "client disconnects without reading",
+			stop: func(cancel context.CancelFunc, logs io.ReadCloser) error {
+				cancel()
+				return nil
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+
+			streaming := make(chan struct{})
+			handlerDone := make(chan struct{})
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				switch {
+				case strings.HasSuffix(r.URL.Path, "/containers/json"):
+					w.Header().Set("Content-Type", "application/json")
+					_, _ = w.Write([]byte("[]"))
+				case strings.HasSuffix(r.URL.Path, "/containers/macos-vz_default_pod_sidecar/logs"):
+					defer close(handlerDone)
+					// an endless log stream, far larger than the buffer of the reader
+					stdout := stdcopy.NewStdWriter(w, stdcopy.Stdout)
+					line := []byte(strings.Repeat("x", 1023) + "\n")
+					for i := 0; ; i++ {
+						if _, err := stdout.Write(line); err != nil {
+							return
+						}
+						w.(http.Flusher).Flush()
+						if i == 0 {
+							close(streaming)
+						}
+						select {
+						case <-r.Context().Done():
+							return
+						default:
+						}
+					}
+				default:
+					http.NotFound(w, r)
+				}
+			}))
+			defer srv.Close()
+
+			cl, err := dockercl.NewClientWithOpts(dockercl.WithHost("tcp://" + srv.Listener.Addr().String()))
+			require.NoError(t, err)
+			dockerClient, err := resourcemanager.NewDockerClient(ctx, cl, nil)
+			require.NoError(t, err)
+
+			logs, err := dockerClient.GetContainerLogs(ctx, "default", "pod", "sidecar", api.ContainerLogOpts{Follow: true})
+			require.NoError(t, err)
+			<-streaming
+
+			// a slow reader
+			buf := make([]byte, 10)
+			_, err = io.ReadFull(logs, buf)
+			require.NoError(t, err)
+			assert.Equal(t, "xxxxxxxxxx", string(buf))
+
+			require.NoError(t, tt.stop(cancel, logs))
+
+			// the Docker stream is closed and the copy returns
+			select {
+			case <-handlerDone:
+			case <-time.After(5 * time.Second):
+				t.Fatal("Docker log stream was not closed")
+			}
+			closed := make(chan struct{})
+			go func() {
+				defer close(closed)
+				_ = logs.Close()
+			}()
+			select {
+			case <-closed:
+			case <-time.After(5 * time.Second):
+				t.Fatal("log copy did not return")
+			}
+			_, err = logs.Read(buf)
+			assert.Error(t, err)
+		})
+	}
+}
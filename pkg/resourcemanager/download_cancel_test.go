@@ -0,0 +1,60 @@
+package resourcemanager_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/agoda-com/macOS-vz-kubelet/pkg/event"
+	"github.com/agoda-com/macOS-vz-kubelet/pkg/resourcemanager"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/virtual-kubelet/virtual-kubelet/errdefs"
+)
+
+func TestCancelDownload(t *testing.T) {
+	ctx := context.Background()
+
+	// the registry never responds, so the downloads stay in progress until they are canceled
+	var inFlight, canceled atomic.Int32
+	registry := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		inFlight.Add(1)
+		<-r.Context().Done()
+		canceled.Add(1)
+	}))
+	t.Cleanup(registry.Close)
+
+	c := resourcemanager.NewMacOSClient(ctx, event.LogEventRecorder{}, "", t.TempDir())
+	params := resourcemanager.VirtualMachineParams{
+		UID:           "uid",
+		Image:         strings.TrimPrefix(registry.URL, "http://") + "/macos:latest",
+		Namespace:     "default",
+		Name:          "pod",
+		ContainerName: "macos",
+	}
+
+	err := c.CancelDownload(ctx, params.Namespace, params.Name)
+	assert.True(t, errdefs.IsNotFound(err), "expected not found error, got %v", err)
+
+	require.NoError(t, c.CreateVirtualMachine(ctx, params))
+	require.Eventually(t, func() bool { return inFlight.Load() == 1 }, 5*time.Second, 10*time.Millisecond)
+
+	require.NoError(t, c.CancelDownload(ctx, params.Namespace, params.Name))
+	require.Eventually(t, func() bool { return canceled.Load() == 1 }, 5*time.Second, 10*time.Millisecond)
+	_, err = c.GetVirtualMachine(ctx, params.Namespace, params.Name)
+	assert.True(t, errdefs.IsNotFound(err), "expected not found error, got %v", err)
+
+	// the virtual machine can be created again, and the canceled creation doesn't interfere with it
+	require.NoError(t, c.CreateVirtualMachine(ctx, params))
+	t.Cleanup(func() { _ = c.DeleteVirtualMachine(ctx, params.Namespace, params.Name, 0) })
+	require.Eventually(t, func() bool { return inFlight.Load() == 2 }, 5*time.Second, 10*time.Millisecond)
+	assert.Never(t, func() bool {
+		vm, err := c.GetVirtualMachine(ctx, params.Namespace, params.Name)
+		return err != nil || vm.Error() != nil
+	}, 200*time.Millisecond, 10*time.Millisecond)
+}
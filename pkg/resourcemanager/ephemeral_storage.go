@@ -0,0 +1,156 @@
+package resourcemanager
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	vmdata "github.com/agoda-com/macOS-vz-kubelet/internal/data/vm"
+	"github.com/agoda-com/macOS-vz-kubelet/internal/disk"
+	"github.com/agoda-com/macOS-vz-kubelet/internal/volumes"
+	"github.com/agoda-com/macOS-vz-kubelet/pkg/event"
+	"github.com/agoda-com/macOS-vz-kubelet/pkg/resource"
+	"github.com/agoda-com/macOS-vz-kubelet/pkg/vm"
+
+	"github.com/virtual-kubelet/virtual-kubelet/log"
+)
+
+// EphemeralStorageCheckInterval is the interval at which the ephemeral storage consumed by the virtual machines
+// is checked against the limit of their pods.
+const EphemeralStorageCheckInterval = 30 * time.Second
+
+// StorageUsageFunc returns the ephemeral storage in bytes consumed by a virtual machine.
+type StorageUsageFunc func(ctx context.Context) (int64, error)
+
+// EphemeralStorageMonitor checks the ephemeral storage consumed by a virtual machine against the ephemeral-storage
+// limit of its pod. A warning event is recorded every time the virtual machine goes over the limit.
+type EphemeralStorageMonitor struct {
+	ContainerName string
+	// Limit is the ephemeral storage in bytes the virtual machine may consume.
+	Limit int64
+	// Evict stops the monitoring with an error wrapping resource.ErrEphemeralStorageExceeded once the virtual
+	// machine exceeds the limit, which fails it so that its pod is evicted.
+	Evict bool
+
+	UsageFunc     StorageUsageFunc
+	EventRecorder event.EventRecorder
+}
+
+// Check returns the ephemeral storage consumed by the virtual machine, along with an error wrapping
+// resource.ErrEphemeralStorageExceeded if it exceeds the limit.
+func (m *EphemeralStorageMonitor) Check(ctx context.Context) (int64, error) {
+	usage, err := m.UsageFunc(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("error measuring ephemeral storage usage: %w", err)
+	}
+	if usage > m.Limit {
+		return usage, fmt.Errorf("%w: %d bytes used, the limit is %d bytes", resource.ErrEphemeralStorageExceeded, usage, m.Limit)
+	}
+	return usage, nil
+}
+
+// Run periodically checks the ephemeral storage consumed by the virtual machine until the context is done,
+// or until the virtual machine exceeds the limit if Evict is set.
+func (m *EphemeralStorageMonitor) Run(ctx context.Context, interval time.Duration) error {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	exceeded := false
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+
+		usage, err := m.Check(ctx)
+		if !errors.Is(err, resource.ErrEphemeralStorageExceeded) {
+			if err != nil {
+				log.G(ctx).WithError(err).Debug("Ephemeral storage check failed")
+			}
+			exceeded = false
+			continue
+		}
+		if !exceeded {
+			m.EventRecorder.EphemeralStorageExceeded(ctx, m.ContainerName, usage, m.Limit)
+			exceeded = true
+		}
+		if m.Evict {
+			return err
+		}
+	}
+}
+
+// ephemeralStorageUsage returns the ephemeral storage consumed by the virtual machine: the growth of its writable
+// storage over the storage of the image it was created from, its scratch disk, and the disk-backed emptyDir volumes
+// it mounts.
+func ephemeralStorageUsage(instance *vm.VirtualMachineInstance, imageStoragePath string, mounts []volumes.Mount) (int64, error) {
+	var usage int64
+	if blockStoragePath, _, ok := instance.WritableStorage(); ok {
+		written, err := disk.AllocatedSize(blockStoragePath)
+		if err != nil {
+			return 0, err
+		}
+		image, err := disk.AllocatedSize(imageStoragePath)
+		if err != nil {
+			return 0, err
+		}
+		usage += max(written-image, 0)
+	}
+	if scratchDiskPath, ok := instance.ScratchDisk(); ok {
+		size, err := disk.AllocatedSize(scratchDiskPath)
+		if err != nil {
+			return 0, err
+		}
+		usage += size
+	}
+	for _, mount := range mounts {
+		if !mount.EmptyDir || mount.Memory {
+			continue
+		}
+		size, err := disk.AllocatedSize(mount.HostPath)
+		if err != nil {
+			return 0, err
+		}
+		usage += size
+	}
+	return usage, nil
+}
+
+// monitorEphemeralStorage checks the ephemeral storage consumed by the started virtual machine against the limit
+// of its pod, and fails the virtual machine once it exceeds the limit if the eviction is enabled.
+func (c *MacOSClient) monitorEphemeralStorage(ctx context.Context, params VirtualMachineParams, imageStoragePath string) {
+	var instance *vm.VirtualMachineInstance
+	c.updateCreatedVirtualMachineInfo(params, func(i vmdata.VirtualMachineInfo) vmdata.VirtualMachineInfo {
+		instance = i.Resource.Instance()
+		return i
+	})
+	if instance == nil {
+		return
+	}
+
+	monitor := &EphemeralStorageMonitor{
+		ContainerName: params.ContainerName,
+		Limit:         params.EphemeralStorageLimit,
+		Evict:         c.ephemeralStorageEviction,
+		UsageFunc: func(context.Context) (int64, error) {
+			return ephemeralStorageUsage(instance, imageStoragePath, params.Mounts)
+		},
+		EventRecorder: c.eventRecorder,
+	}
+	err := monitor.Run(ctx, EphemeralStorageCheckInterval)
+	if !errors.Is(err, resource.ErrEphemeralStorageExceeded) {
+		return
+	}
+
+	updated := c.updateCreatedVirtualMachineInfo(params, func(i vmdata.VirtualMachineInfo) vmdata.VirtualMachineInfo {
+		// keep the reason of virtual machines failed otherwise
+		if i.Resource.Error() == nil {
+			i.Resource.SetError(err)
+		}
+		return i
+	})
+	if updated {
+		log.G(ctx).WithError(err).Info("Virtual machine exceeded the ephemeral storage limit of its pod, evicting it")
+	}
+}
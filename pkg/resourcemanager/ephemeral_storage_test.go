@@ -0,0 +1,110 @@
+package resourcemanager_test
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/agoda-com/macOS-vz-kubelet/pkg/event/mocks"
+	"github.com/agoda-com/macOS-vz-kubelet/pkg/resource"
+	"github.com/agoda-com/macOS-vz-kubelet/pkg/resourcemanager"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+// stubStorageUsage reports the stored usage.
+func stubStorageUsage(usage *atomic.Int64) resourcemanager.StorageUsageFunc {
+	return func(context.Context) (int64, error) {
+		return usage.Load(), nil
+	}
+}
+
+func TestEphemeralStorageMonitorCheck(t *testing.T) {
+	var usage atomic.Int64
+	monitor := &resourcemanager.EphemeralStorageMonitor{
+		ContainerName: "macos",
+		Limit:         10 << 30,
+		UsageFunc:     stubStorageUsage(&usage),
+		EventRecorder: mocks.NewEventRecorder(t),
+	}
+
+	usage.Store(10 << 30)
+	used, err := monitor.Check(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, int64(10<<30), used)
+
+	usage.Store(10<<30 + 1)
+	used, err = monitor.Check(context.Background())
+	require.ErrorIs(t, err, resource.ErrEphemeralStorageExceeded)
+	assert.Equal(t, int64(10<<30+1), used)
+
+	measureErr := errors.New("no such file or directory")
+	monitor.UsageFunc = func(context.Context) (int64, error) { return 0, measureErr }
+	_, err = monitor.Check(context.Background())
+	require.ErrorIs(t, err, measureErr)
+	assert.NotErrorIs(t, err, resource.ErrEphemeralStorageExceeded)
+}
+
+func TestEphemeralStorageMonitorWarns(t *testing.T) {
+	var usage atomic.Int64
+	usage.Store(12 << 30)
+	warned := make(chan struct{}, 2)
+	recorder := mocks.NewEventRecorder(t)
+	recorder.On("EphemeralStorageExceeded", mock.Anything, "macos", int64(12<<30), int64(10<<30)).
+		Run(func(mock.Arguments) { warned <- struct{}{} }).Return().Twice()
+
+	monitor := &resourcemanager.EphemeralStorageMonitor{
+		ContainerName: "macos",
+		Limit:         10 << 30,
+		UsageFunc:     stubStorageUsage(&usage),
+		EventRecorder: recorder,
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() {
+		done <- monitor.Run(ctx, 10*time.Millisecond)
+	}()
+
+	// the warning is recorded once while the virtual machine stays over the limit
+	<-warned
+	time.Sleep(50 * time.Millisecond)
+	assert.Empty(t, warned)
+
+	// and once again after it goes back over the limit
+	usage.Store(1 << 30)
+	time.Sleep(50 * time.Millisecond)
+	usage.Store(12 << 30)
+	select {
+	case <-warned:
+	case <-time.After(5 * time.Second):
+		t.Fatal("the limit exceeded again was not reported")
+	}
+
+	cancel()
+	assert.ErrorIs(t, <-done, context.Canceled)
+}
+
+func TestEphemeralStorageMonitorEvicts(t *testing.T) {
+	var usage atomic.Int64
+	usage.Store(1 << 30)
+	recorder := mocks.NewEventRecorder(t)
+	recorder.On("EphemeralStorageExceeded", mock.Anything, "macos", int64(12<<30), int64(10<<30)).Return().Once()
+
+	monitor := &resourcemanager.EphemeralStorageMonitor{
+		ContainerName: "macos",
+		Limit:         10 << 30,
+		Evict:         true,
+		UsageFunc:     stubStorageUsage(&usage),
+		EventRecorder: recorder,
+	}
+	time.AfterFunc(50*time.Millisecond, func() { usage.Store(12 << 30) })
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	err := monitor.Run(ctx, 10*time.Millisecond)
+	assert.ErrorIs(t, err, resource.ErrEphemeralStorageExceeded)
+}
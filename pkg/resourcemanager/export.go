@@ -0,0 +1,63 @@
+package resourcemanager
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/agoda-com/macOS-vz-kubelet/pkg/downloader"
+
+	"github.com/opencontainers/go-digest"
+	"github.com/virtual-kubelet/virtual-kubelet/errdefs"
+	"github.com/virtual-kubelet/virtual-kubelet/log"
+	"github.com/virtual-kubelet/virtual-kubelet/trace"
+)
+
+// ExportVirtualMachine pushes the storage of the virtual machine to the tag of the target image reference,
+// as an image that can run virtual machines in turn. The storage is snapshotted, a running virtual machine
+// is paused meanwhile and keeps running afterwards. It returns the digest of the pushed manifest.
+func (c *MacOSClient) ExportVirtualMachine(ctx context.Context, namespace, name, targetRef string) (d digest.Digest, err error) {
+	ctx, span := trace.StartSpan(ctx, "MacOSClient.ExportVirtualMachine")
+	ctx = span.WithFields(ctx, log.Fields{
+		"namespace": namespace,
+		"name":      name,
+		"targetRef": targetRef,
+	})
+	defer func() {
+		span.SetStatus(err)
+		span.End()
+	}()
+
+	info, err := c.getVirtualMachineInfo(ctx, namespace, name)
+	if err != nil {
+		return "", err
+	}
+	instance := info.Resource.Instance()
+	if instance == nil {
+		return "", errdefs.InvalidInput("virtual machine is not created yet")
+	}
+
+	// the virtual machine shares the platform configuration of its image
+	cfg, ok := c.downloadManager.Cached(ctx, info.Ref)
+	if !ok {
+		return "", fmt.Errorf("image %s of the virtual machine is not cached", info.Ref)
+	}
+
+	cfg.BlockStoragePath, cfg.AuxiliaryStoragePath, err = instance.SnapshotStorage(ctx, fmt.Sprintf("export-%d", time.Now().UnixNano()))
+	if err != nil {
+		return "", fmt.Errorf("failed to snapshot storage: %w", err)
+	}
+	defer func() {
+		err = errors.Join(err, os.Remove(cfg.BlockStoragePath), os.Remove(cfg.AuxiliaryStoragePath))
+	}()
+
+	desc, err := downloader.Push(ctx, targetRef, cfg, c.eventRecorder)
+	if err != nil {
+		return "", err
+	}
+
+	log.G(ctx).Infof("Exported virtual machine to %s", targetRef)
+	return desc.Digest, nil
+}
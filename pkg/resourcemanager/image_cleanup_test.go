@@ -0,0 +1,63 @@
+package resourcemanager_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/agoda-com/macOS-vz-kubelet/pkg/downloader"
+	"github.com/agoda-com/macOS-vz-kubelet/pkg/event"
+	"github.com/agoda-com/macOS-vz-kubelet/pkg/resourcemanager"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRemoveUnusedImage(t *testing.T) {
+	ctx := context.Background()
+
+	// the registry never responds, so the virtual machines keep referencing the image
+	registry := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-r.Context().Done()
+	}))
+	t.Cleanup(registry.Close)
+
+	cachePath := t.TempDir()
+	c := resourcemanager.NewMacOSClient(ctx, event.LogEventRecorder{}, "", cachePath, resourcemanager.WithImageCleanup(true))
+	assert.True(t, c.ImageCleanup())
+
+	image := strings.TrimPrefix(registry.URL, "http://") + "/macos:latest"
+	ref, err := downloader.ParseReference(image)
+	require.NoError(t, err)
+	blobs := filepath.Join(cachePath, "blobs", downloader.CachePath(ref))
+	require.NoError(t, os.MkdirAll(blobs, 0o755))
+	require.NoError(t, os.WriteFile(filepath.Join(blobs, "disk.img"), []byte("disk"), 0o600))
+
+	for _, name := range []string{"first", "second"} {
+		require.NoError(t, c.CreateVirtualMachine(ctx, resourcemanager.VirtualMachineParams{
+			UID:           name,
+			Image:         image,
+			Namespace:     "default",
+			Name:          name,
+			ContainerName: "macos",
+		}))
+	}
+
+	// the second pod still uses the image
+	require.NoError(t, c.DeleteVirtualMachine(ctx, "default", "first", 0))
+	removed, err := c.RemoveUnusedImage(ctx, image)
+	require.NoError(t, err)
+	assert.False(t, removed)
+	assert.DirExists(t, blobs)
+
+	// the last pod using the image is deleted, once its download is canceled the image is removed
+	require.NoError(t, c.DeleteVirtualMachine(ctx, "default", "second", 0))
+	removed, err = c.RemoveUnusedImage(ctx, strings.TrimSuffix(image, ":latest"))
+	require.NoError(t, err)
+	assert.True(t, removed)
+	assert.NoDirExists(t, blobs)
+}
@@ -0,0 +1,104 @@
+package resourcemanager
+
+import (
+	"container/list"
+	"sync"
+	"time"
+
+	"github.com/agoda-com/macOS-vz-kubelet/pkg/resource"
+)
+
+const (
+	// DefaultInspectCacheTTL is how long an inspected container state is reused before inspecting the container again.
+	DefaultInspectCacheTTL = 2 * time.Second
+	// DefaultInspectCacheSize is the maximum number of inspected container states kept, the least recently used are evicted.
+	DefaultInspectCacheSize = 256
+)
+
+// containerInspection is the part of a container inspection reported in the container status.
+type containerInspection struct {
+	state        resource.ContainerState
+	restartCount int
+}
+
+// inspectCacheEntry is an inspection of the container with the ID, valid until it expires.
+type inspectCacheEntry struct {
+	id         string
+	inspection containerInspection
+	expiresAt  time.Time
+}
+
+// inspectCache is a least recently used cache of container inspections keyed by container ID,
+// so that frequent status polling does not inspect every container every time.
+// A zero TTL disables the cache.
+type inspectCache struct {
+	mu      sync.Mutex
+	ttl     time.Duration
+	size    int
+	entries map[string]*list.Element
+	order   list.List // of *inspectCacheEntry, most recently used first
+}
+
+// newInspectCache creates a cache of at most size inspections reused for the TTL.
+func newInspectCache(ttl time.Duration, size int) *inspectCache {
+	return &inspectCache{
+		ttl:     ttl,
+		size:    size,
+		entries: make(map[string]*list.Element),
+	}
+}
+
+// get returns the inspection of the container unless it is missing or expired.
+func (c *inspectCache) get(id string) (containerInspection, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	elem, ok := c.entries[id]
+	if !ok {
+		return containerInspection{}, false
+	}
+	entry := elem.Value.(*inspectCacheEntry)
+	if time.Now().After(entry.expiresAt) {
+		c.order.Remove(elem)
+		delete(c.entries, id)
+		return containerInspection{}, false
+	}
+	c.order.MoveToFront(elem)
+	return entry.inspection, true
+}
+
+// add stores the inspection of the container, evicting the least recently used inspections beyond the size.
+func (c *inspectCache) add(id string, inspection containerInspection) {
+	if c.ttl <= 0 || c.size <= 0 {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	expiresAt := time.Now().Add(c.ttl)
+	if elem, ok := c.entries[id]; ok {
+		entry := elem.Value.(*inspectCacheEntry)
+		entry.inspection = inspection
+		entry.expiresAt = expiresAt
+		c.order.MoveToFront(elem)
+		return
+	}
+	c.entries[id] = c.order.PushFront(&inspectCacheEntry{id: id, inspection: inspection, expiresAt: expiresAt})
+	for c.order.Len() > c.size {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.entries, oldest.Value.(*inspectCacheEntry).id)
+	}
+}
+
+// remove forgets the inspection of the container.
+func (c *inspectCache) remove(id string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if elem, ok := c.entries[id]; ok {
+		c.order.Remove(elem)
+		delete(c.entries, id)
+	}
+}
@@ -0,0 +1,84 @@
+package resourcemanager
+
+import (
+	"context"
+	"time"
+
+	vmdata "github.com/agoda-com/macOS-vz-kubelet/internal/data/vm"
+	"github.com/agoda-com/macOS-vz-kubelet/pkg/resource"
+
+	"github.com/virtual-kubelet/virtual-kubelet/log"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// LifetimeCheckInterval is the interval at which virtual machines are checked against the maximum lifetime.
+const LifetimeCheckInterval = 10 * time.Second
+
+// LifetimeEnforcer recycles virtual machines that have been running longer than the maximum lifetime,
+// so that long-lived virtual machines do not accumulate state drift.
+//
+// Expired virtual machines are marked as failed with ErrMaxLifetimeExceeded. The provider then deletes
+// the failed pod, which gracefully stops the virtual machine and releases its slot.
+type LifetimeEnforcer struct {
+	MaxLifetime time.Duration
+	Data        *vmdata.VirtualMachineData
+}
+
+// expired reports whether the virtual machine has outlived the maximum lifetime at the given time.
+// Virtual machines that have not started yet, have already stopped or have failed are never expired.
+func (e *LifetimeEnforcer) expired(vm resource.MacOSVirtualMachine, now time.Time) bool {
+	startedAt := vm.StartedAt()
+	if startedAt == nil || vm.FinishedAt() != nil || vm.Error() != nil {
+		return false
+	}
+	return now.Sub(*startedAt) >= e.MaxLifetime
+}
+
+// Enforce marks every virtual machine that has outlived the maximum lifetime as failed
+// and returns the keys of the recycled virtual machines.
+func (e *LifetimeEnforcer) Enforce(ctx context.Context, now time.Time) []types.NamespacedName {
+	if e.MaxLifetime <= 0 {
+		return nil
+	}
+
+	var recycled []types.NamespacedName
+	for key, info := range e.Data.ListVirtualMachines() {
+		if !e.expired(info.Resource, now) {
+			continue
+		}
+
+		expired := false
+		e.Data.UpdateVirtualMachineInfo(key.Namespace, key.Name, func(i vmdata.VirtualMachineInfo) vmdata.VirtualMachineInfo {
+			// re-check, the virtual machine might have changed since it was listed
+			if expired = e.expired(i.Resource, now); expired {
+				i.Resource.SetError(resource.ErrMaxLifetimeExceeded)
+			}
+			return i
+		})
+		if !expired {
+			continue
+		}
+
+		log.G(ctx).WithFields(log.Fields{
+			"namespace":   key.Namespace,
+			"name":        key.Name,
+			"maxLifetime": e.MaxLifetime,
+		}).Info("Virtual machine exceeded its maximum lifetime, recycling it")
+		recycled = append(recycled, key)
+	}
+	return recycled
+}
+
+// Run periodically enforces the maximum lifetime until the context is done.
+func (e *LifetimeEnforcer) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			e.Enforce(ctx, now)
+		}
+	}
+}
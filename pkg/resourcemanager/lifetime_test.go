@@ -0,0 +1,74 @@
+package resourcemanager_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	vmdata "github.com/agoda-com/macOS-vz-kubelet/internal/data/vm"
+	"github.com/agoda-com/macOS-vz-kubelet/pkg/resource"
+	"github.com/agoda-com/macOS-vz-kubelet/pkg/resourcemanager"
+	"github.com/agoda-com/macOS-vz-kubelet/pkg/vm"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+func addVirtualMachine(t *testing.T, data *vmdata.VirtualMachineData, name string, instance *vm.VirtualMachineInstance) types.NamespacedName {
+	t.Helper()
+
+	r := resource.NewMacOSVirtualMachine(nil)
+	if instance != nil {
+		r.SetInstance(instance)
+	}
+	_, loaded := data.GetOrCreateVirtualMachineInfo("default", name, vmdata.VirtualMachineInfo{Resource: r})
+	require.False(t, loaded)
+	return types.NamespacedName{Namespace: "default", Name: name}
+}
+
+func TestLifetimeEnforcer(t *testing.T) {
+	now := time.Date(2012, 12, 12, 12, 12, 12, 0, time.UTC)
+	longAgo := now.Add(-2 * time.Hour)
+	recently := now.Add(-time.Minute)
+	stoppedAt := now.Add(-time.Hour)
+
+	data := &vmdata.VirtualMachineData{}
+	expired := addVirtualMachine(t, data, "expired", &vm.VirtualMachineInstance{StartedAt: &longAgo})
+	withinLifetime := addVirtualMachine(t, data, "within-lifetime", &vm.VirtualMachineInstance{StartedAt: &recently})
+	preparing := addVirtualMachine(t, data, "preparing", nil)
+	stopped := addVirtualMachine(t, data, "stopped", &vm.VirtualMachineInstance{StartedAt: &longAgo, FinishedAt: &stoppedAt})
+
+	enforcer := &resourcemanager.LifetimeEnforcer{MaxLifetime: time.Hour, Data: data}
+	recycled := enforcer.Enforce(context.Background(), now)
+	assert.Equal(t, []types.NamespacedName{expired}, recycled)
+
+	info, ok := data.GetVirtualMachineInfo(expired.Namespace, expired.Name)
+	require.True(t, ok)
+	assert.ErrorIs(t, info.Resource.Error(), resource.ErrMaxLifetimeExceeded)
+	assert.Equal(t, resource.VirtualMachineStateFailed, info.Resource.State())
+
+	for _, key := range []types.NamespacedName{withinLifetime, preparing, stopped} {
+		info, ok := data.GetVirtualMachineInfo(key.Namespace, key.Name)
+		require.True(t, ok)
+		assert.NoError(t, info.Resource.Error(), key.Name)
+	}
+
+	// Already recycled virtual machines are not recycled again
+	assert.Empty(t, enforcer.Enforce(context.Background(), now))
+}
+
+func TestLifetimeEnforcerDisabled(t *testing.T) {
+	now := time.Now()
+	longAgo := now.Add(-24 * time.Hour)
+
+	data := &vmdata.VirtualMachineData{}
+	key := addVirtualMachine(t, data, "long-running", &vm.VirtualMachineInstance{StartedAt: &longAgo})
+
+	enforcer := &resourcemanager.LifetimeEnforcer{Data: data}
+	assert.Empty(t, enforcer.Enforce(context.Background(), now))
+
+	info, ok := data.GetVirtualMachineInfo(key.Namespace, key.Name)
+	require.True(t, ok)
+	assert.NoError(t, info.Resource.Error())
+}
@@ -1,21 +1,25 @@
 package resourcemanager
 
 import (
-	"bytes"
 	"context"
-	"encoding/json"
 	"errors"
 	"fmt"
+	"net"
 	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"golang.org/x/crypto/ssh"
 
 	"github.com/Code-Hex/vz/v3"
 	vmdata "github.com/agoda-com/macOS-vz-kubelet/internal/data/vm"
-	vzio "github.com/agoda-com/macOS-vz-kubelet/internal/io"
 	"github.com/agoda-com/macOS-vz-kubelet/internal/node"
 	vzssh "github.com/agoda-com/macOS-vz-kubelet/internal/ssh"
+	"github.com/agoda-com/macOS-vz-kubelet/internal/utils"
 	"github.com/agoda-com/macOS-vz-kubelet/internal/volumes"
 	"github.com/agoda-com/macOS-vz-kubelet/pkg/downloader"
 	"github.com/agoda-com/macOS-vz-kubelet/pkg/event"
@@ -30,7 +34,6 @@ import (
 	stats "k8s.io/kubelet/pkg/apis/stats/v1alpha1"
 
 	corev1 "k8s.io/api/core/v1"
-	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/types"
 )
 
@@ -38,8 +41,31 @@ const (
 	// MaxVirtualMachines is the maximum number of virtual machines that can be created.
 	// This is a kernel level limitation by Apple and is enforced within Virtualization.framework.
 	MaxVirtualMachines = 2
+
+	// DefaultSSHPort is the default port of the SSH server inside the virtual machines.
+	DefaultSSHPort = 22
+
+	// GuestConfigurationTimeout is the timeout for applying the pod configuration inside the guest after the start.
+	GuestConfigurationTimeout = 30 * time.Second
+
+	// additionalVirtualMachineSeparator separates the pod and container names in the names of the additional
+	// virtual machines of a pod. Pod names are DNS subdomains, so the separator never appears in them.
+	additionalVirtualMachineSeparator = "/"
 )
 
+// AdditionalVirtualMachineName returns the name of the virtual machine of an additional macOS container of the pod.
+// The virtual machine of the first macOS container is named after the pod itself.
+func AdditionalVirtualMachineName(podName, containerName string) string {
+	return podName + additionalVirtualMachineSeparator + containerName
+}
+
+// SplitVirtualMachineName returns the name of the pod the virtual machine belongs to,
+// along with the container name if it is an additional virtual machine of the pod.
+func SplitVirtualMachineName(name string) (podName, containerName string) {
+	podName, containerName, _ = strings.Cut(name, additionalVirtualMachineSeparator)
+	return podName, containerName
+}
+
 // VirtualMachineParams encapsulates the parameters required for creating a virtual machine.
 type VirtualMachineParams struct {
 	UID              string
@@ -51,21 +77,340 @@ type VirtualMachineParams struct {
 	MemorySize       uint64
 	Mounts           []volumes.Mount
 	Env              []corev1.EnvVar
+	HostAliases      []corev1.HostAlias
 	PostStartAction  *resource.ExecAction
 	IgnoreImageCache bool
+	// ImagePullPolicy is the pull policy of the image, IfNotPresent skips the download of images already in the cache.
+	ImagePullPolicy corev1.PullPolicy
+	// Hostname is the hostname of the guest and Domain its domain within the cluster, if any.
+	Hostname, Domain string
+	// Timezone is the name of the timezone of the guest in the tz database, empty keeps the one of the image.
+	Timezone string
+	// Sysctls are the sysctls of the pod set inside the guest, those that are not allowlisted are rejected.
+	Sysctls []corev1.Sysctl
+	// Command is run inside the virtual machine once started, its exit terminates the virtual machine.
+	// Nil means the virtual machine runs until its pod is deleted.
+	Command []string
+	// ActiveDeadline is the duration the virtual machine may run before it is failed, zero means no deadline.
+	ActiveDeadline time.Duration
+	// Devices selects the optional devices attached to the virtual machine.
+	Devices config.DeviceOptions
+	// DiskMode selects how the storage of the image is made writable, empty selects the default of the client.
+	DiskMode config.DiskMode
+	// Priority is the priority of the pod, used to preempt lower priority virtual machines at capacity.
+	Priority int32
+	// Snapshot is the name of the snapshot the virtual machine is restored from, empty boots it from the image.
+	Snapshot string
+	// AgentHealth, if set, probes the guest agent of the virtual machine, which is ready only while the agent reports it healthy.
+	AgentHealth *AgentHealthProbe
+	// EphemeralStorageLimit is the ephemeral storage in bytes the virtual machine may consume, zero means unlimited.
+	EphemeralStorageLimit int64
+
+	generation uint64 // assigned on creation, see VirtualMachineInfo.Generation
 }
 
 // MacOSClient manages the lifecycle of macOS virtual machines.
 type MacOSClient struct {
 	downloadManager *downloader.Manager
 	data            vmdata.VirtualMachineData
+	slots           *SlotReservations
+	deadlines       *ActiveDeadlines
+	preemptor       *Preemptor // nil unless preemption is enabled
+	commands        sync.Map   // map[types.NamespacedName]*Command, the commands running inside the virtual machines
+	generations     atomic.Uint64
 
 	eventRecorder              event.EventRecorder
 	networkInterfaceIdentifier string
+	bridgeFallbackToNAT        bool
+	cpuQoS                     config.CPUQoS
+	extendedResourceNames      ExtendedResourceNames
+	snapshotsPath              string
+	shareCheckInterval         atomic.Int64 // time.Duration
+	maxLifetime                time.Duration
+	startRetry                 StartRetry
+	statsTimeout               time.Duration
+	defaultDevices             config.DeviceOptions
+	diskMode                   config.DiskMode
+	defaultImage               string
+	syncClock                  bool
+	readinessTimeout           time.Duration
+	sshCredentials             SSHCredentialsFunc
+	ipDiscovery                []string
+	ipResolverConfig           vm.IPResolverConfig
+	imageCleanup               bool
+	ephemeralStorageEviction   bool
+	stopConfirmationTimeout    time.Duration
+}
+
+// MacOSClientOption configures optional behavior of the MacOSClient.
+type MacOSClientOption func(*MacOSClient)
+
+// WithShareCheckInterval enables periodic verification that the shared directories
+// are still accessible inside the guest. Zero interval disables the verification.
+func WithShareCheckInterval(interval time.Duration) MacOSClientOption {
+	return func(c *MacOSClient) {
+		c.SetShareCheckInterval(interval)
+	}
+}
+
+// ShareCheckInterval returns the interval of the shared directories verification.
+func (c *MacOSClient) ShareCheckInterval() time.Duration {
+	return time.Duration(c.shareCheckInterval.Load())
+}
+
+// SetShareCheckInterval changes the interval of the shared directories verification.
+// The new interval applies to virtual machines started afterwards.
+func (c *MacOSClient) SetShareCheckInterval(interval time.Duration) {
+	c.shareCheckInterval.Store(int64(interval))
+}
+
+// WithNamespaceQuotas limits the number of virtual machines that can run concurrently within a namespace.
+// Virtual machines over the quota wait for a slot to be released before being created.
+func WithNamespaceQuotas(quotas NamespaceQuotas) MacOSClientOption {
+	return func(c *MacOSClient) {
+		c.slots = NewSlotReservations(quotas)
+	}
+}
+
+// WithMaxLifetime recycles the virtual machines that have been running longer than the given lifetime.
+// Their pods are failed with the MaxLifetimeExceeded reason. Zero lifetime disables the recycling.
+func WithMaxLifetime(lifetime time.Duration) MacOSClientOption {
+	return func(c *MacOSClient) {
+		c.maxLifetime = lifetime
+	}
+}
+
+// WithEphemeralStorageEviction fails the virtual machines consuming more ephemeral storage than the limit of their pods
+// when enabled, so that their pods are evicted. Otherwise only a warning event is recorded.
+func WithEphemeralStorageEviction(evict bool) MacOSClientOption {
+	return func(c *MacOSClient) {
+		c.ephemeralStorageEviction = evict
+	}
+}
+
+// WithPreemption preempts the virtual machines of lower priority pods when enabled and the node is at capacity.
+// The preempted pods are failed with the Preempting reason.
+func WithPreemption(enabled bool) MacOSClientOption {
+	return func(c *MacOSClient) {
+		if enabled {
+			c.preemptor = &Preemptor{Data: &c.data}
+		}
+	}
+}
+
+// WithImagePullBandwidthLimit limits the combined bandwidth of the image downloads to the given
+// number of bytes per second. Zero limit means unlimited.
+func WithImagePullBandwidthLimit(limit int64) MacOSClientOption {
+	return func(c *MacOSClient) {
+		c.downloadManager.SetBandwidthLimit(limit)
+	}
+}
+
+// WithImageDigestPinning pins the image tags to the digest they were first resolved to when enabled,
+// so that cached images do not change when the tags are moved in the registry.
+func WithImageDigestPinning(pin bool) MacOSClientOption {
+	return func(c *MacOSClient) {
+		c.downloadManager.SetPinDigests(pin)
+	}
+}
+
+// WithStreamingDecompression decompresses the compressed image layers while they are downloaded when enabled,
+// instead of saving them to temporary files first, so that the pulls need half the disk space.
+func WithStreamingDecompression(enabled bool) MacOSClientOption {
+	return func(c *MacOSClient) {
+		c.downloadManager.SetStreamDecompression(enabled)
+	}
+}
+
+// WithRegistryMirrors retries the failed image pulls against the mirrors of the registries,
+// keyed by the host of the registry they mirror.
+func WithRegistryMirrors(mirrors map[string]string) MacOSClientOption {
+	return func(c *MacOSClient) {
+		c.downloadManager.SetRegistryMirrors(mirrors)
+	}
+}
+
+// WithRegistryClients pulls the images from the registries with their clients, keyed by the host of the registry,
+// e.g. to send the custom headers or the client certificate a registry requires.
+func WithRegistryClients(clients downloader.RegistryClients) MacOSClientOption {
+	return func(c *MacOSClient) {
+		c.downloadManager.SetRegistryClients(clients)
+	}
+}
+
+// WithMaxConcurrentDownloads limits the number of image downloads running at once, zero means unlimited.
+func WithMaxConcurrentDownloads(limit int) MacOSClientOption {
+	return func(c *MacOSClient) {
+		c.downloadManager.SetMaxConcurrentDownloads(limit)
+	}
+}
+
+// WithMaxImageSize rejects the images declaring more than the given number of bytes of content, uncompressed
+// where annotated, before they are downloaded. Zero means unlimited.
+func WithMaxImageSize(size int64) MacOSClientOption {
+	return func(c *MacOSClient) {
+		c.downloadManager.SetMaxImageSize(size)
+	}
+}
+
+// WithImageStoreLayout selects how the cached content of the images is laid out, downloader.LayoutReference by default.
+func WithImageStoreLayout(layout downloader.Layout) MacOSClientOption {
+	return func(c *MacOSClient) {
+		c.downloadManager.SetLayout(layout)
+	}
+}
+
+// WithImageCleanup removes the cached content of the images when enabled, once the last virtual machine using them is deleted.
+func WithImageCleanup(enabled bool) MacOSClientOption {
+	return func(c *MacOSClient) {
+		c.imageCleanup = enabled
+	}
+}
+
+// ImageCleanup reports whether the cached content of the images is removed once they are no longer used.
+func (c *MacOSClient) ImageCleanup() bool {
+	return c.imageCleanup
+}
+
+// RemoveUnusedImage removes the cached content of the image, unless a virtual machine still uses it.
+// The returned flag reports whether the content was removed.
+func (c *MacOSClient) RemoveUnusedImage(ctx context.Context, ref string) (bool, error) {
+	ref = downloader.NormalizeReference(ref)
+	return c.downloadManager.RemoveImage(ctx, ref, func() bool {
+		for _, info := range c.data.ListVirtualMachines() {
+			if downloader.NormalizeReference(info.Ref) == ref {
+				return true
+			}
+		}
+		return false
+	})
+}
+
+// WithStartRetry retries virtual machine starts failing with transient errors up to the given number of
+// attempts, waiting the backoff before the first retry and doubling it after every retry.
+func WithStartRetry(attempts int, backoff time.Duration) MacOSClientOption {
+	return func(c *MacOSClient) {
+		c.startRetry = StartRetry{Attempts: attempts, Backoff: backoff}
+	}
+}
+
+// WithStatsTimeout bounds the collection of the stats inside the virtual machines, DefaultStatsTimeout if zero.
+func WithStatsTimeout(timeout time.Duration) MacOSClientOption {
+	return func(c *MacOSClient) {
+		c.statsTimeout = timeout
+	}
+}
+
+// WithDefaultDevices selects the optional devices attached to the virtual machines of pods
+// that do not select them themselves.
+func WithDefaultDevices(devices config.DeviceOptions) MacOSClientOption {
+	return func(c *MacOSClient) {
+		c.defaultDevices = devices
+	}
+}
+
+// ExtendedResourceNames are the extended resources the CPU and memory of the virtual machines are requested with,
+// when pods do not request the standard cpu and memory resources.
+type ExtendedResourceNames struct {
+	CPU    []corev1.ResourceName
+	Memory []corev1.ResourceName
+}
+
+// WithExtendedResourceNames consults the extended resources for the CPU and memory of the virtual machines
+// of pods that do not request the standard cpu and memory resources.
+func WithExtendedResourceNames(names ExtendedResourceNames) MacOSClientOption {
+	return func(c *MacOSClient) {
+		c.extendedResourceNames = names
+	}
+}
+
+// ExtendedResourceNames returns the extended resources consulted for the CPU and memory of the virtual machines.
+func (c *MacOSClient) ExtendedResourceNames() ExtendedResourceNames {
+	return c.extendedResourceNames
+}
+
+// DefaultDevices returns the optional devices attached to the virtual machines by default.
+func (c *MacOSClient) DefaultDevices() config.DeviceOptions {
+	return c.defaultDevices
+}
+
+// WithDiskMode selects how the storage of the image is made writable for the virtual machines of pods
+// that do not select it themselves.
+func WithDiskMode(mode config.DiskMode) MacOSClientOption {
+	return func(c *MacOSClient) {
+		c.diskMode = mode
+	}
+}
+
+// DiskMode returns how the storage of the image is made writable for the virtual machines by default.
+func (c *MacOSClient) DiskMode() config.DiskMode {
+	if c.diskMode == "" {
+		return config.DiskModeOverlay
+	}
+	return c.diskMode
+}
+
+// WithClockSync selects whether the clock of the virtual machines is synchronized once they have booted, enabled by default.
+func WithClockSync(enabled bool) MacOSClientOption {
+	return func(c *MacOSClient) {
+		c.syncClock = enabled
+	}
+}
+
+// WithBridgeFallbackToNAT attaches the virtual machines to NAT with a warning when the bridge interface
+// is missing on the host, instead of failing them.
+func WithBridgeFallbackToNAT(enabled bool) MacOSClientOption {
+	return func(c *MacOSClient) {
+		c.bridgeFallbackToNAT = enabled
+	}
+}
+
+// WithCPUQoS selects the quality of service class the virtual machines are started with, config.CPUQoSDefault by default.
+func WithCPUQoS(qos config.CPUQoS) MacOSClientOption {
+	return func(c *MacOSClient) {
+		c.cpuQoS = qos
+	}
+}
+
+// WithStopConfirmationTimeout bounds how long the deletion of a virtual machine waits for it to be reported stopped
+// and its overlays removed before its slot is released. Zero releases the slot as soon as the stop returns.
+func WithStopConfirmationTimeout(timeout time.Duration) MacOSClientOption {
+	return func(c *MacOSClient) {
+		c.stopConfirmationTimeout = timeout
+	}
+}
+
+// WithReadinessTimeout bounds how long a started virtual machine may take to accept SSH connections before
+// it is failed, DefaultReadinessTimeout by default. Zero disables the readiness check.
+func WithReadinessTimeout(timeout time.Duration) MacOSClientOption {
+	return func(c *MacOSClient) {
+		c.readinessTimeout = timeout
+	}
+}
+
+// WithDefaultImage selects the image of the macOS containers that do not set one.
+func WithDefaultImage(ref string) MacOSClientOption {
+	return func(c *MacOSClient) {
+		c.defaultImage = ref
+	}
+}
+
+// DefaultImage returns the image of the macOS containers that do not set one, empty if there is none.
+func (c *MacOSClient) DefaultImage() string {
+	return c.defaultImage
+}
+
+// WithIPDiscovery selects the methods discovering the IP addresses of the virtual machines, tried in order.
+// The network interface of the resolver configuration is the one the virtual machines are bridged to.
+func WithIPDiscovery(methods []string, cfg vm.IPResolverConfig) MacOSClientOption {
+	return func(c *MacOSClient) {
+		c.ipDiscovery = methods
+		c.ipResolverConfig = cfg
+	}
 }
 
 // NewMacOSClient initializes a new MacOSClient instance.
-func NewMacOSClient(ctx context.Context, eventRecorder event.EventRecorder, networkInterfaceIdentifier, cachePath string) *MacOSClient {
+func NewMacOSClient(ctx context.Context, eventRecorder event.EventRecorder, networkInterfaceIdentifier, cachePath string, opts ...MacOSClientOption) *MacOSClient {
 	ctx, span := trace.StartSpan(ctx, "MacOSClient.NewMacOSClient")
 	_ = span.WithFields(ctx, log.Fields{
 		"networkInterfaceIdentifier": networkInterfaceIdentifier,
@@ -73,11 +418,28 @@ func NewMacOSClient(ctx context.Context, eventRecorder event.EventRecorder, netw
 	})
 	defer span.End()
 
-	return &MacOSClient{
+	c := &MacOSClient{
 		eventRecorder:              eventRecorder,
 		networkInterfaceIdentifier: networkInterfaceIdentifier,
+		snapshotsPath:              filepath.Join(cachePath, SnapshotsDirName),
 		downloadManager:            downloader.NewManager(eventRecorder, cachePath),
+		slots:                      NewSlotReservations(NamespaceQuotas{}),
+		ipDiscovery:                vm.DefaultIPDiscovery,
+		syncClock:                  true,
+		readinessTimeout:           DefaultReadinessTimeout,
+		stopConfirmationTimeout:    DefaultStopConfirmationTimeout,
+	}
+	c.deadlines = &ActiveDeadlines{Data: &c.data}
+	for _, opt := range opts {
+		opt(c)
 	}
+
+	if c.maxLifetime > 0 {
+		enforcer := &LifetimeEnforcer{MaxLifetime: c.maxLifetime, Data: &c.data}
+		go enforcer.Run(ctx, LifetimeCheckInterval)
+	}
+
+	return c
 }
 
 // CreateVirtualMachine creates a new virtual machine with the specified parameters.
@@ -88,9 +450,19 @@ func (c *MacOSClient) CreateVirtualMachine(ctx context.Context, params VirtualMa
 		span.End()
 	}()
 
+	params.generation = c.generations.Add(1)
+	vmResource := resource.NewMacOSVirtualMachine(params.Env)
+	vmResource.SetStartupTimings(resource.StartupTimings{RequestedAt: time.Now()})
+	if params.AgentHealth != nil {
+		// unhealthy until the guest agent reports otherwise
+		vmResource.SetHealthy(false)
+	}
 	_, loaded := c.data.GetOrCreateVirtualMachineInfo(params.Namespace, params.Name, vmdata.VirtualMachineInfo{
-		Ref:      params.Image,
-		Resource: resource.NewMacOSVirtualMachine(params.Env),
+		Ref:           params.Image,
+		ContainerName: params.ContainerName,
+		Generation:    params.generation,
+		Priority:      params.Priority,
+		Resource:      vmResource,
 	})
 	if loaded {
 		return errdefs.AsInvalidInput(fmt.Errorf("virtual machine already exists"))
@@ -118,7 +490,7 @@ func (c *MacOSClient) handleVirtualMachineCreation(ctx context.Context, params V
 	// Manage download
 	downloadCtx, cancel := context.WithCancel(ctx) // create a new context to manage the download
 	defer cancel()
-	_, updated := c.data.UpdateVirtualMachineInfo(params.Namespace, params.Name, func(i vmdata.VirtualMachineInfo) vmdata.VirtualMachineInfo {
+	updated := c.updateCreatedVirtualMachineInfo(params, func(i vmdata.VirtualMachineInfo) vmdata.VirtualMachineInfo {
 		i.DownloadCancelFunc = cancel
 		return i
 	})
@@ -127,54 +499,171 @@ func (c *MacOSClient) handleVirtualMachineCreation(ctx context.Context, params V
 		return
 	}
 
-	cfg, duration, err := c.downloadManager.Download(downloadCtx, params.Image, params.IgnoreImageCache)
-	if err != nil {
-		if !errors.Is(err, context.Canceled) {
-			// Only log the error if it's not due to context cancellation
-			// to avoid spamming the cluster events with canceled downloads.
-			c.eventRecorder.BackOffPullImage(ctx, params.Image, params.ContainerName, err)
+	cfg, duration, cached := c.cachedImage(ctx, params)
+	if !cached {
+		cfg, duration, err = c.downloadManager.Download(downloadCtx, params.Image, params.IgnoreImageCache)
+		if err != nil {
+			if !errors.Is(err, context.Canceled) {
+				// Only log the error if it's not due to context cancellation
+				// to avoid spamming the cluster events with canceled downloads.
+				c.eventRecorder.BackOffPullImage(ctx, params.Image, params.ContainerName, err)
+			}
+			return
 		}
+	}
+
+	// The download can no longer be canceled, unless it was canceled right before completing
+	updated = c.updateCreatedVirtualMachineInfo(params, func(i vmdata.VirtualMachineInfo) vmdata.VirtualMachineInfo {
+		i.DownloadCancelFunc = nil
+		return i
+	})
+	if !updated || downloadCtx.Err() != nil {
+		logger.Debug("image download canceled")
 		return
 	}
 
 	// Log the successful image pull event
 	c.eventRecorder.PulledImage(ctx, params.Image, params.ContainerName, duration.String())
 	logger.Debug(cfg)
+	c.recordStartupPhase(params, func(t *resource.StartupTimings) { t.PulledAt = time.Now() })
 
 	// Wait until resources are available to proceed with the virtual machine creation
-	if err = c.waitForCreationProceed(ctx); err != nil {
+	if err = c.waitForCreationProceed(ctx, params); err != nil {
 		return
 	}
+	c.recordStartupPhase(params, func(t *resource.StartupTimings) { t.StartingAt = time.Now() })
 
-	// Create the virtual machine instance
-	vm, err := c.createVirtualMachineInstance(ctx, cfg, params)
-	if err != nil {
+	// Create and start the virtual machine instance, from its snapshot if any
+	statePath := c.snapshotState(ctx, params, &cfg)
+	if err = c.startVirtualMachineInstance(ctx, cfg, params, statePath); err != nil {
 		return
 	}
+	c.updateCreatedVirtualMachineInfo(params, func(i vmdata.VirtualMachineInfo) vmdata.VirtualMachineInfo {
+		timings := i.Resource.StartupTimings()
+		timings.IPAssignedAt = time.Now()
+		// the virtual machine is running before its IP address is discovered
+		timings.BootedAt = timings.IPAssignedAt
+		if startedAt := i.Resource.StartedAt(); startedAt != nil && startedAt.Before(timings.IPAssignedAt) {
+			timings.BootedAt = *startedAt
+		}
+		i.Resource.SetStartupTimings(timings)
+		return i
+	})
+	c.eventRecorder.StartedContainer(ctx, params.ContainerName)
+	go c.watchVirtualMachineCrash(ctx, params)
+	if params.AgentHealth != nil {
+		go c.probeAgentHealth(ctx, params)
+	}
+	if params.EphemeralStorageLimit > 0 {
+		go c.monitorEphemeralStorage(ctx, params, cfg.BlockStoragePath)
+	}
 
-	// Start the virtual machine
-	err = vm.Start(ctx)
-	if err != nil {
-		c.eventRecorder.FailedToStartContainer(ctx, params.ContainerName, err)
+	if params.ActiveDeadline > 0 {
+		c.deadlines.Start(ctx, params.Namespace, params.Name, params.ActiveDeadline)
+	}
+
+	readiness := &ReadinessCheck{
+		ContainerName: params.ContainerName,
+		Timeout:       c.readinessTimeout,
+		ExecFunc: func(ctx context.Context, cmd []string, attach api.AttachIO) error {
+			return c.ExecInVirtualMachine(ctx, params.Namespace, params.Name, cmd, attach)
+		},
+		EventRecorder: c.eventRecorder,
+	}
+	if err = readiness.Wait(ctx); err != nil {
 		return
 	}
-	c.eventRecorder.StartedContainer(ctx, params.ContainerName)
+	c.reportStartup(ctx, params)
+
+	if len(params.HostAliases) > 0 {
+		if err := c.configureHostAliases(ctx, params); err != nil {
+			logger.WithError(err).Warn("Failed to configure host aliases inside the virtual machine")
+		}
+	}
+
+	if params.Hostname != "" {
+		if err := c.configureHostname(ctx, params); err != nil {
+			c.eventRecorder.FailedToSetHostname(ctx, params.ContainerName, params.Hostname, err)
+		}
+	}
 
-	if params.PostStartAction == nil {
-		// No post-start action specified, return early
+	if params.Timezone != "" {
+		if err := c.configureTimezone(ctx, params); err != nil {
+			c.eventRecorder.FailedToSetTimezone(ctx, params.ContainerName, params.Timezone, err)
+		}
+	}
+
+	if len(params.Sysctls) > 0 {
+		c.configureSysctls(ctx, params)
+	}
+
+	clockSync := &ClockSync{
+		ContainerName: params.ContainerName,
+		Enabled:       c.syncClock,
+		ExecFunc: func(ctx context.Context, cmd []string, attach api.AttachIO) error {
+			return c.ExecInVirtualMachine(ctx, params.Namespace, params.Name, cmd, attach)
+		},
+		EventRecorder: c.eventRecorder,
+	}
+	if _, err := clockSync.Run(ctx); err != nil {
+		logger.WithError(err).Warn("Failed to synchronize the clock of the virtual machine")
+	}
+
+	if interval := c.ShareCheckInterval(); interval > 0 && len(params.Mounts) > 0 {
+		go c.verifySharedDirectories(ctx, params, interval)
+	}
+
+	if params.PostStartAction != nil {
+		// Execute the post-start action
+		err = c.execPostStartAction(ctx, params.Namespace, params.Name, *params.PostStartAction)
+		if err != nil {
+			c.eventRecorder.FailedPostStartHook(ctx, params.ContainerName, params.PostStartAction.Command, err)
+			return
+		}
+	}
+
+	if len(params.Command) > 0 {
+		go c.runCommand(ctx, params)
+	}
+}
+
+// recordStartupPhase records the time the virtual machine went through a phase of its creation, and returns the
+// times recorded so far. The returned times are zero if the virtual machine info expired.
+func (c *MacOSClient) recordStartupPhase(params VirtualMachineParams, record func(t *resource.StartupTimings)) (timings resource.StartupTimings) {
+	c.updateCreatedVirtualMachineInfo(params, func(i vmdata.VirtualMachineInfo) vmdata.VirtualMachineInfo {
+		timings = i.Resource.StartupTimings()
+		record(&timings)
+		i.Resource.SetStartupTimings(timings)
+		return i
+	})
+	return timings
+}
+
+// reportStartup logs the durations of the startup phases of the virtual machine once it is ready, and summarizes
+// them in an event.
+func (c *MacOSClient) reportStartup(ctx context.Context, params VirtualMachineParams) {
+	timings := c.recordStartupPhase(params, func(t *resource.StartupTimings) { t.ReadyAt = time.Now() })
+	if timings.ReadyAt.IsZero() {
 		return
 	}
+	log.G(ctx).WithFields(timings.Fields()).Info("Virtual machine is ready")
+	c.eventRecorder.VirtualMachineReady(ctx, params.ContainerName, timings.String())
+}
 
-	// Execute the post-start action
-	err = c.execPostStartAction(ctx, params.Namespace, params.Name, *params.PostStartAction)
-	if err != nil {
-		c.eventRecorder.FailedPostStartHook(ctx, params.ContainerName, params.PostStartAction.Command, err)
+// cachedImage returns the platform configuration options of the image if the pull policy allows using the
+// cached image and it is fully present in the cache, so that the download is skipped altogether.
+func (c *MacOSClient) cachedImage(ctx context.Context, params VirtualMachineParams) (cfg config.MacPlatformConfigurationOptions, duration time.Duration, ok bool) {
+	if params.ImagePullPolicy != corev1.PullIfNotPresent || params.IgnoreImageCache {
+		return cfg, 0, false
 	}
+	start := time.Now()
+	cfg, ok = c.downloadManager.Cached(ctx, params.Image)
+	return cfg, time.Since(start), ok
 }
 
 // finalizeVirtualMachineInfo updates the virtual machine info with the final result of the creation process.
 func (c *MacOSClient) finalizeVirtualMachineInfo(ctx context.Context, params VirtualMachineParams, err error) {
-	_, updated := c.data.UpdateVirtualMachineInfo(params.Namespace, params.Name, func(i vmdata.VirtualMachineInfo) vmdata.VirtualMachineInfo {
+	updated := c.updateCreatedVirtualMachineInfo(params, func(i vmdata.VirtualMachineInfo) vmdata.VirtualMachineInfo {
 		i.DownloadCancelFunc = nil // indicate that download is no longer in progress
 		if err != nil {
 			i.Resource.SetError(err)
@@ -186,16 +675,44 @@ func (c *MacOSClient) finalizeVirtualMachineInfo(ctx context.Context, params Vir
 	}
 }
 
-// waitForCreationProceed blocks until it's safe to proceed with the virtual machine creation.
-func (c *MacOSClient) waitForCreationProceed(ctx context.Context) error {
+// updateCreatedVirtualMachineInfo updates the virtual machine info only if it still belongs to the creation
+// described by params, so that a creation that was canceled never modifies the info of a later one.
+// It returns whether the info was updated.
+func (c *MacOSClient) updateCreatedVirtualMachineInfo(params VirtualMachineParams, updateFunc func(vmdata.VirtualMachineInfo) vmdata.VirtualMachineInfo) bool {
+	updated := false
+	c.data.UpdateVirtualMachineInfo(params.Namespace, params.Name, func(i vmdata.VirtualMachineInfo) vmdata.VirtualMachineInfo {
+		if i.Generation != params.generation {
+			return i
+		}
+		updated = true
+		return updateFunc(i)
+	})
+	return updated
+}
+
+// waitForCreationProceed blocks until it's safe to proceed with the virtual machine creation
+// and a slot within the namespace quota is reserved for the virtual machine.
+func (c *MacOSClient) waitForCreationProceed(ctx context.Context, params VirtualMachineParams) error {
 	// Since kubelet limits number of pods based on the Virtualization.framework limits already,
 	// it's safe to assume that the reason for not being able to create a new VM is that we have
 	// some of them in Terminating state (graceful shutdown) and we need to wait for them to finish.
+	key := types.NamespacedName{Namespace: params.Namespace, Name: params.Name}
+	quotaReported := false
 	ticker := time.NewTicker(time.Second)
 	defer ticker.Stop()
 	for {
 		if c.canProceedWithVirtualMachineCreation() {
-			return nil
+			ok, limit := c.slots.TryReserve(key)
+			if ok {
+				return nil
+			}
+			if !quotaReported {
+				// report only once to avoid spamming the cluster events while waiting
+				c.eventRecorder.NamespaceQuotaReached(ctx, params.ContainerName, params.Namespace, limit)
+				quotaReported = true
+			}
+		} else if c.preemptor != nil {
+			c.preemptor.Preempt(ctx, key, params.Priority)
 		}
 
 		log.G(ctx).Debug("waiting for resources to be available")
@@ -210,13 +727,24 @@ func (c *MacOSClient) waitForCreationProceed(ctx context.Context) error {
 
 // createVirtualMachineInstance creates a new virtual machine instance with the specified parameters.
 func (c *MacOSClient) createVirtualMachineInstance(ctx context.Context, cfg config.MacPlatformConfigurationOptions, params VirtualMachineParams) (*vm.VirtualMachineInstance, error) {
-	vm, err := setupVM(ctx, cfg, params.UID, params.CPU, params.MemorySize, c.networkInterfaceIdentifier, params.Mounts)
+	network := config.NetworkOptions{BridgeInterface: c.networkInterfaceIdentifier, BridgeFallbackToNAT: c.bridgeFallbackToNAT}
+	diskMode := params.DiskMode
+	if diskMode == "" {
+		diskMode = c.DiskMode()
+	}
+	vm, err := setupVM(ctx, cfg, diskMode, params.UID, params.CPU, params.MemorySize, network, params.Mounts, params.Devices, c.cpuQoS, c.ipDiscovery, c.ipResolverConfig)
 	if err != nil {
-		c.eventRecorder.FailedToCreateContainer(ctx, params.ContainerName, err)
+		reported := err
+		var invalid *config.ValidationError
+		if errors.As(err, &invalid) {
+			// report the validation reason alone, so that users can correct the pod
+			reported = invalid
+		}
+		c.eventRecorder.FailedToCreateContainer(ctx, params.ContainerName, reported)
 		return nil, err
 	}
 
-	c.data.UpdateVirtualMachineInfo(params.Namespace, params.Name, func(i vmdata.VirtualMachineInfo) vmdata.VirtualMachineInfo {
+	c.updateCreatedVirtualMachineInfo(params, func(i vmdata.VirtualMachineInfo) vmdata.VirtualMachineInfo {
 		i.Resource.SetInstance(vm)
 		return i
 	})
@@ -225,6 +753,50 @@ func (c *MacOSClient) createVirtualMachineInstance(ctx context.Context, cfg conf
 	return vm, nil
 }
 
+// startVirtualMachineInstance creates and starts the virtual machine instance, restoring the machine state saved
+// at statePath if set. Starts failing with transient errors are retried with a fresh instance, since the failed
+// one cannot be started again. A state that cannot be restored is reported and the instance is booted instead.
+func (c *MacOSClient) startVirtualMachineInstance(ctx context.Context, cfg config.MacPlatformConfigurationOptions, params VirtualMachineParams, statePath string) error {
+	var (
+		instance  *vm.VirtualMachineInstance
+		createErr error
+	)
+	err := c.startRetry.Run(ctx, func(ctx context.Context) error {
+		if instance != nil {
+			// clean up the instance of the failed attempt
+			if err := instance.Stop(ctx); err != nil {
+				log.G(ctx).WithError(err).Warn("Failed to clean up the virtual machine after a failed start")
+			}
+		}
+
+		instance, createErr = c.createVirtualMachineInstance(ctx, cfg, params)
+		if createErr != nil {
+			// configuration errors are not going to be resolved by retrying
+			return errdefs.AsInvalidInput(createErr)
+		}
+		if statePath != "" {
+			err := instance.Restore(ctx, statePath)
+			if !errors.Is(err, vm.ErrRestoreState) {
+				return err
+			}
+			c.eventRecorder.FailedToRestoreState(ctx, params.ContainerName, params.Snapshot, err)
+			// retries boot from scratch as well
+			statePath = ""
+		}
+		return instance.Start(ctx)
+	}, func(attempt int, err error) {
+		c.eventRecorder.BackOffStartContainer(ctx, params.ContainerName, attempt, err)
+	})
+	if createErr != nil {
+		// already reported as a creation failure
+		return createErr
+	}
+	if err != nil {
+		c.eventRecorder.FailedToStartContainer(ctx, params.ContainerName, err)
+	}
+	return err
+}
+
 // execPostStartAction executes the post-start action inside the virtual machine.
 func (c *MacOSClient) execPostStartAction(ctx context.Context, namespace, name string, action resource.ExecAction) (err error) {
 	ctx, span := trace.StartSpan(ctx, "MacOSClient.execPostStart")
@@ -251,7 +823,146 @@ func (c *MacOSClient) execPostStartAction(ctx context.Context, namespace, name s
 	return err
 }
 
-// DeleteVirtualMachine stops and deletes the specified virtual machine.
+// configureHostAliases adds the pod host aliases to the hosts file of the virtual machine.
+func (c *MacOSClient) configureHostAliases(ctx context.Context, params VirtualMachineParams) (err error) {
+	ctx, span := trace.StartSpan(ctx, "MacOSClient.configureHostAliases")
+	defer func() {
+		span.SetStatus(err)
+		span.End()
+	}()
+
+	ctx, cancel := context.WithTimeout(ctx, GuestConfigurationTimeout)
+	defer cancel()
+
+	return c.ExecInVirtualMachine(ctx, params.Namespace, params.Name, utils.BuildHostAliasesCommand(params.HostAliases), node.DiscardingExecIO())
+}
+
+// configureHostname sets the hostname of the virtual machine to the hostname of the pod.
+func (c *MacOSClient) configureHostname(ctx context.Context, params VirtualMachineParams) (err error) {
+	ctx, span := trace.StartSpan(ctx, "MacOSClient.configureHostname")
+	defer func() {
+		span.SetStatus(err)
+		span.End()
+	}()
+
+	ctx, cancel := context.WithTimeout(ctx, GuestConfigurationTimeout)
+	defer cancel()
+
+	return c.ExecInVirtualMachine(ctx, params.Namespace, params.Name, utils.BuildHostnameCommand(params.Hostname, params.Domain), node.DiscardingExecIO())
+}
+
+// configureSysctls sets the allowlisted sysctls of the pod inside the virtual machine,
+// recording events for the sysctls that are rejected or could not be set.
+func (c *MacOSClient) configureSysctls(ctx context.Context, params VirtualMachineParams) {
+	var err error
+	ctx, span := trace.StartSpan(ctx, "MacOSClient.configureSysctls")
+	defer func() {
+		span.SetStatus(err)
+		span.End()
+	}()
+
+	allowed := make([]corev1.Sysctl, 0, len(params.Sysctls))
+	for _, s := range params.Sysctls {
+		// the sysctls end up in a shell command, they are never run unless valid
+		if err := utils.ValidateSysctl(s); err != nil {
+			c.eventRecorder.FailedToSetSysctls(ctx, params.ContainerName, []string{s.Name}, err)
+			continue
+		}
+		allowed = append(allowed, s)
+	}
+	if len(allowed) == 0 {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, GuestConfigurationTimeout)
+	defer cancel()
+
+	err = c.ExecInVirtualMachine(ctx, params.Namespace, params.Name, utils.BuildSysctlCommand(allowed), node.DiscardingExecIO())
+	if err != nil {
+		names := make([]string, 0, len(allowed))
+		for _, s := range allowed {
+			names = append(names, s.Name)
+		}
+		c.eventRecorder.FailedToSetSysctls(ctx, params.ContainerName, names, err)
+	}
+}
+
+// configureTimezone sets the timezone of the virtual machine to the timezone of the pod.
+func (c *MacOSClient) configureTimezone(ctx context.Context, params VirtualMachineParams) (err error) {
+	ctx, span := trace.StartSpan(ctx, "MacOSClient.configureTimezone")
+	defer func() {
+		span.SetStatus(err)
+		span.End()
+	}()
+
+	// the timezone ends up in a shell command, it is never run unless valid
+	if err := utils.ValidateTimezone(params.Timezone); err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, GuestConfigurationTimeout)
+	defer cancel()
+
+	return c.ExecInVirtualMachine(ctx, params.Namespace, params.Name, utils.BuildTimezoneCommand(params.Timezone), node.DiscardingExecIO())
+}
+
+// verifySharedDirectories periodically verifies that the shared directories are accessible inside the virtual machine.
+func (c *MacOSClient) verifySharedDirectories(ctx context.Context, params VirtualMachineParams, interval time.Duration) {
+	automountTag, err := vz.MacOSGuestAutomountTag()
+	if err != nil {
+		log.G(ctx).WithError(err).Warn("Failed to get macOS guest automount tag, shared directories will not be verified")
+		return
+	}
+
+	verifier := &ShareVerifier{
+		ContainerName: params.ContainerName,
+		Mounts:        params.Mounts,
+		AutomountTag:  automountTag,
+		ExecFunc: func(ctx context.Context, cmd []string, attach api.AttachIO) error {
+			return c.ExecInVirtualMachine(ctx, params.Namespace, params.Name, cmd, attach)
+		},
+		EventRecorder: c.eventRecorder,
+	}
+	verifier.Run(ctx, interval)
+}
+
+// CancelDownload cancels the in-progress image download of the specified virtual machine and forgets
+// the virtual machine, so that creating it again triggers a new download.
+func (c *MacOSClient) CancelDownload(ctx context.Context, namespace string, name string) (err error) {
+	ctx, span := trace.StartSpan(ctx, "MacOSClient.CancelDownload")
+	ctx = span.WithFields(ctx, log.Fields{
+		"namespace": namespace,
+		"name":      name,
+	})
+	defer func() {
+		span.SetStatus(err)
+		span.End()
+	}()
+
+	// Take the cancel function atomically, so that it's never raced by the finalization of the creation
+	var cancel context.CancelFunc
+	_, ok := c.data.UpdateVirtualMachineInfo(namespace, name, func(i vmdata.VirtualMachineInfo) vmdata.VirtualMachineInfo {
+		cancel, i.DownloadCancelFunc = i.DownloadCancelFunc, nil
+		return i
+	})
+	if !ok {
+		return errdefs.NotFoundf("virtual machine %s/%s not found", namespace, name)
+	}
+	if cancel == nil {
+		return errdefs.InvalidInputf("no image download in progress for virtual machine %s/%s", namespace, name)
+	}
+
+	cancel()
+	c.data.RemoveVirtualMachineInfo(namespace, name)
+	c.slots.Release(types.NamespacedName{Namespace: namespace, Name: name})
+	c.deadlines.Stop(namespace, name)
+	log.G(ctx).Info("Image download canceled")
+
+	return nil
+}
+
+// DeleteVirtualMachine stops and deletes the specified virtual machine. It returns once the virtual machine
+// is confirmed stopped and its overlays removed, bounded by the stop confirmation timeout.
 func (c *MacOSClient) DeleteVirtualMachine(ctx context.Context, namespace string, name string, gracePeriod int64) (err error) {
 	ctx, span := trace.StartSpan(ctx, "MacOSClient.DeleteVirtualMachine")
 	ctx = span.WithFields(ctx, log.Fields{
@@ -270,20 +981,30 @@ func (c *MacOSClient) DeleteVirtualMachine(ctx context.Context, namespace string
 		return nil
 	}
 	defer c.data.RemoveVirtualMachineInfo(namespace, name)
+	defer c.slots.Release(types.NamespacedName{Namespace: namespace, Name: name})
+	c.deadlines.Stop(namespace, name)
 
 	if info.DownloadCancelFunc != nil {
 		info.DownloadCancelFunc()
 	}
 
 	if instance := info.Resource.Instance(); instance != nil {
-		err = c.stopVirtualMachine(ctx, instance, namespace, name, gracePeriod)
+		err = c.stopVirtualMachine(ctx, instance, namespace, name, info.ContainerName, gracePeriod)
+		// storage copies outlive the stopped virtual machine until its pod is deleted
+		err = errors.Join(err, instance.RemoveCopies(ctx))
+
+		// the slot is released once the virtual machine is, or once it failed to be confirmed in time
+		confirmation := &StopConfirmation{Timeout: c.stopConfirmationTimeout, Released: instance.Released}
+		if confirmErr := confirmation.Wait(ctx); confirmErr != nil {
+			log.G(ctx).WithError(confirmErr).Warn("Failed to confirm that the virtual machine stopped, releasing its slot anyway")
+		}
 	}
 
 	return err
 }
 
 // stopVirtualMachine stops the virtual machine instance.
-func (c *MacOSClient) stopVirtualMachine(ctx context.Context, instance *vm.VirtualMachineInstance, namespace, name string, gracePeriod int64) (err error) {
+func (c *MacOSClient) stopVirtualMachine(ctx context.Context, instance *vm.VirtualMachineInstance, namespace, name, containerName string, gracePeriod int64) (err error) {
 	ctx, span := trace.StartSpan(ctx, "MacOSClient.stopVirtualMachine")
 	ctx = span.WithFields(ctx, log.Fields{
 		"namespace":   namespace,
@@ -296,21 +1017,42 @@ func (c *MacOSClient) stopVirtualMachine(ctx context.Context, instance *vm.Virtu
 		span.End()
 	}()
 
+	// The command running inside the virtual machine is terminated first, within the grace period
+	stopCtx, cancel := context.WithTimeout(ctx, time.Duration(gracePeriod)*time.Second)
+	defer cancel()
+	if command, ok := c.commands.Load(types.NamespacedName{Namespace: namespace, Name: name}); ok && instance.State() == vz.VirtualMachineStateRunning {
+		logger.Info("Terminating the command of the virtual machine")
+		if !command.(*Command).Terminate(ctx, time.Duration(gracePeriod)*time.Second) {
+			logger.Warn("Command of the virtual machine did not exit, stopping the virtual machine anyway")
+		}
+	}
+
 	// Attempt to send a graceful shutdown request, which unfortunately
 	// is unsupported by Virtualization.framework as of today.
-	if instance.State() == vz.VirtualMachineStateRunning && gracePeriod > 0 {
+	if instance.State() == vz.VirtualMachineStateRunning && stopCtx.Err() == nil {
 		logger.Info("Stopping virtual machine gracefully")
 
-		stopCtx, cancel := context.WithTimeout(ctx, time.Duration(gracePeriod)*time.Second)
-		defer cancel()
-		if err := c.gracefulShutdown(stopCtx, instance, namespace, name); err != nil {
-			logger.WithError(err).Warn("Failed to gracefully shutdown VM, will force stop it instead")
-		}
+		return GracefulStop(ctx, c.eventRecorder, containerName, func() error {
+			return c.gracefulShutdown(stopCtx, instance, namespace, name)
+		}, func() error {
+			return instance.Stop(ctx)
+		})
 	}
 
 	return instance.Stop(ctx)
 }
 
+// GracefulStop shuts the virtual machine of the container down with shutdown, then stops it with stop.
+// If the graceful shutdown fails, stop force-stops the still running virtual machine, which may lose
+// the data not yet written to its disk, so a ForceStopped event is recorded beforehand.
+func GracefulStop(ctx context.Context, recorder event.EventRecorder, containerName string, shutdown, stop func() error) error {
+	if err := shutdown(); err != nil {
+		log.G(ctx).WithError(err).Warn("Failed to gracefully shutdown VM, will force stop it instead")
+		recorder.ForceStopped(ctx, containerName, err)
+	}
+	return stop()
+}
+
 // gracefulShutdown attempts to gracefully shutdown the virtual machine.
 func (c *MacOSClient) gracefulShutdown(ctx context.Context, instance *vm.VirtualMachineInstance, namespace, name string) (err error) {
 	ctx, span := trace.StartSpan(ctx, "MacOSClient.gracefulShutdown")
@@ -363,7 +1105,22 @@ func (c *MacOSClient) GetVirtualMachine(ctx context.Context, namespace string, n
 		return resource.MacOSVirtualMachine{}, err
 	}
 
-	return info.Resource, nil
+	return c.virtualMachineResource(info), nil
+}
+
+// virtualMachineResource returns the virtual machine resource of the info,
+// with the image it is created from, populated with the image download progress
+// while the download is in progress.
+func (c *MacOSClient) virtualMachineResource(info vmdata.VirtualMachineInfo) resource.MacOSVirtualMachine {
+	vm := info.Resource
+	vm.SetImage(info.Ref)
+	if info.DownloadCancelFunc == nil {
+		return vm
+	}
+	if completed, total, ok := c.downloadManager.Progress(info.Ref); ok {
+		vm.SetDownloadProgress(&resource.DownloadProgress{Completed: completed, Total: total})
+	}
+	return vm
 }
 
 // GetVirtualMachineListResult retrieves all virtual machines managed by the client.
@@ -376,7 +1133,7 @@ func (c *MacOSClient) GetVirtualMachineListResult(ctx context.Context) (map[type
 	infos := c.data.ListVirtualMachines()
 	// simplify the map down to just the resource
 	for key, info := range infos {
-		vms[key] = info.Resource
+		vms[key] = c.virtualMachineResource(info)
 	}
 
 	return vms, nil
@@ -395,154 +1152,54 @@ func (c *MacOSClient) ExecInVirtualMachine(ctx context.Context, namespace, name
 		return err
 	}
 
-	client, err := establishVirtualMachineSshConn(ctx, info.Resource)
+	creds, err := c.SSHCredentials(ctx, namespace, name)
 	if err != nil {
 		return err
 	}
-	defer func() {
-		if err := client.Close(); err != nil {
-			log.G(ctx).WithError(err).Warn("failed to close SSH client")
-		}
-	}()
-
-	go func() {
-		// Make sure connection is closed when context is done
-		<-ctx.Done()
-		_ = client.Close()
-	}()
 
-	session, err := client.NewSession()
-	if err != nil {
-		return err
+	err = c.execInVirtualMachine(ctx, info.Resource, creds, cmd, attach)
+	var lostErr *vzssh.ConnectionLostError
+	if errors.As(err, &lostErr) && !lostErr.Started && attach.Stdin() == nil && !attach.TTY() {
+		// the command never ran, so a non-interactive exec is safe to retry once
+		log.G(ctx).WithError(err).Warn("Lost connection to the virtual machine before executing the command, reconnecting")
+		err = c.execInVirtualMachine(ctx, info.Resource, creds, cmd, attach)
 	}
-	defer func() {
-		_ = session.Close()
-	}()
+	if errors.As(err, &lostErr) {
+		vzssh.ReportConnectionLost(attach)
+	}
+	return err
+}
 
-	// We establish stdinPipe here instead of directly assigning attach.Stdin() to the session
-	// because we need to monitor any interruptions to stdin in order to properly close the session.
-	// For example, if the interactive terminal is closed without exiting the session, the session
-	// would be left hanging.
-	stdinPipe, err := session.StdinPipe()
+// execInVirtualMachine executes a command inside the virtual machine over a new SSH connection.
+func (c *MacOSClient) execInVirtualMachine(ctx context.Context, vm resource.MacOSVirtualMachine, creds SSHCredentials, cmd []string, attach api.AttachIO) error {
+	conn, err := establishVirtualMachineSshConn(ctx, vm, creds)
 	if err != nil {
 		return err
 	}
 	defer func() {
-		_ = stdinPipe.Close()
+		if err := conn.Close(); err != nil && !errors.Is(err, net.ErrClosed) {
+			log.G(ctx).WithError(err).Warn("failed to close SSH client")
+		}
 	}()
 
-	macOSSession := vzssh.NewMacOSSession(session, attach, stdinPipe)
-	if err = macOSSession.SetupSessionIO(ctx); err != nil {
-		return fmt.Errorf("failed to setup session IO: %w", err)
-	}
+	go func() {
+		// Make sure connection is closed when context is done
+		select {
+		case <-ctx.Done():
+			_ = conn.Close()
+		case <-conn.Done():
+		}
+	}()
 
-	return macOSSession.ExecuteCommand(ctx, info.Resource.Env(), cmd)
+	return conn.Execute(ctx, attach, vm.Env(), cmd)
 }
 
 // GetVirtualMachineStats retrieves the stats of the specified virtual machine.
+// Stats collection is bounded by the stats timeout, resulting in empty stats if the guest does not respond in time.
 func (c *MacOSClient) GetVirtualMachineStats(ctx context.Context, namespace, name string) (stats.ContainerStats, error) {
-	// Combined script for collecting all required stats
-	cmd := []string{
-		`cpuUsageNanoCores=$(top -l 1 | awk '/CPU usage/ {print ($3+$5)*10000000}' | sed 's/%//g')`,
-		`cpuUsageNanoCores=$(printf "%.0f" "$cpuUsageNanoCores")`,
-
-		`cpuUsageCoreNanoSeconds=$(echo "$(sysctl -n hw.ncpu) * $(( $(date +%s) - $(sysctl -n kern.boottime | awk -F'[ ,]' '{print $4}') )) * 1000000000" | bc -l)`,
-		`cpuUsageCoreNanoSeconds=$(printf "%.0f" "$cpuUsageCoreNanoSeconds")`,
-
-		`memoryUsageBytes=$(vm_stat | awk '/Pages active/ {active=$3} /Pages wired down/ {wired=$4} END {print (active+wired)*4096}')`,
-		`memoryRssBytes=$(vm_stat | awk '/Pages active/ {print $3*4096}')`,
-		`memoryWorkingSetBytes=$(vm_stat | awk '/Pages active/ {active=$3} /Pages speculative/ {speculative=$4} END {print (active-speculative)*4096}')`,
-
-		`echo "{\"cpuUsageNanoCores\": $cpuUsageNanoCores, \"cpuUsageCoreNanoSeconds\": $cpuUsageCoreNanoSeconds, \"memoryUsageBytes\": $memoryUsageBytes, \"memoryRssBytes\": $memoryRssBytes, \"memoryWorkingSetBytes\": $memoryWorkingSetBytes}"`,
-	}
-
-	// Capture command output
-	stdout := &bytes.Buffer{}
-	buf := vzio.NewBufferWriteCloser(stdout)
-	attach := node.NewExecIO(false, nil, buf, buf, nil)
-
-	// Execute the script in the VM
-	if err := c.ExecInVirtualMachine(ctx, namespace, name, cmd, attach); err != nil {
-		return stats.ContainerStats{}, fmt.Errorf("error executing script: %w", err)
-	}
-
-	// Parse JSON output
-	statsData, err := parseStatsJSON(stdout.Bytes())
-	if err != nil {
-		return stats.ContainerStats{}, fmt.Errorf("error parsing JSON output: %w", err)
-	}
-
-	// Prepare stats.ContainerStats
-	time := metav1.NewTime(time.Now())
-	return stats.ContainerStats{
-		CPU: &stats.CPUStats{
-			Time:                 time,
-			UsageNanoCores:       statsData.CPUUsageNanoCores,
-			UsageCoreNanoSeconds: statsData.CPUUsageCoreNanoSeconds,
-		},
-		Memory: &stats.MemoryStats{
-			Time:            time,
-			UsageBytes:      statsData.MemoryUsageBytes,
-			WorkingSetBytes: statsData.MemoryWorkingSetBytes,
-			RSSBytes:        statsData.MemoryRSSBytes,
-		},
-	}, nil
-}
-
-type vmStatsData struct {
-	CPUUsageNanoCores       json.Number `json:"cpuUsageNanoCores"`
-	CPUUsageCoreNanoSeconds json.Number `json:"cpuUsageCoreNanoSeconds"`
-	MemoryUsageBytes        json.Number `json:"memoryUsageBytes"`
-	MemoryRSSBytes          json.Number `json:"memoryRssBytes"`
-	MemoryWorkingSetBytes   json.Number `json:"memoryWorkingSetBytes"`
-}
-
-type parsedVMStatsData struct {
-	CPUUsageNanoCores       *uint64 `json:"cpuUsageNanoCores"`
-	CPUUsageCoreNanoSeconds *uint64 `json:"cpuUsageCoreNanoSeconds"`
-	MemoryUsageBytes        *uint64 `json:"memoryUsageBytes"`
-	MemoryRSSBytes          *uint64 `json:"memoryRssBytes"`
-	MemoryWorkingSetBytes   *uint64 `json:"memoryWorkingSetBytes"`
-}
-
-func parseStatsJSON(data []byte) (*parsedVMStatsData, error) {
-	// Unmarshal into intermediate structure
-	var statsData vmStatsData
-	if err := json.Unmarshal(data, &statsData); err != nil {
-		return nil, err
-	}
-
-	// Conversion function for json.Number to *uint64
-	convert := func(num json.Number) (*uint64, error) {
-		val, err := num.Int64()
-		if err != nil {
-			return nil, err
-		}
-		uval := uint64(val)
-		return &uval, nil
-	}
-
-	// Populate the final ParsedVMStatsData struct
-	parsedData := &parsedVMStatsData{}
-	var err error
-
-	if parsedData.CPUUsageNanoCores, err = convert(statsData.CPUUsageNanoCores); err != nil {
-		return nil, fmt.Errorf("cpuUsageNanoCores: %w", err)
-	}
-	if parsedData.CPUUsageCoreNanoSeconds, err = convert(statsData.CPUUsageCoreNanoSeconds); err != nil {
-		return nil, fmt.Errorf("cpuUsageCoreNanoSeconds: %w", err)
-	}
-	if parsedData.MemoryUsageBytes, err = convert(statsData.MemoryUsageBytes); err != nil {
-		return nil, fmt.Errorf("memoryUsageBytes: %w", err)
-	}
-	if parsedData.MemoryRSSBytes, err = convert(statsData.MemoryRSSBytes); err != nil {
-		return nil, fmt.Errorf("memoryRssBytes: %w", err)
-	}
-	if parsedData.MemoryWorkingSetBytes, err = convert(statsData.MemoryWorkingSetBytes); err != nil {
-		return nil, fmt.Errorf("memoryWorkingSetBytes: %w", err)
-	}
-
-	return parsedData, nil
+	return CollectVirtualMachineStats(ctx, func(ctx context.Context, cmd []string, attach api.AttachIO) error {
+		return c.ExecInVirtualMachine(ctx, namespace, name, cmd, attach)
+	}, c.statsTimeout)
 }
 
 // getVirtualMachineInfo retrieves the virtual machine information.
@@ -562,20 +1219,40 @@ func (c *MacOSClient) canProceedWithVirtualMachineCreation() bool {
 	return c.data.Count() <= MaxVirtualMachines
 }
 
+// VirtualMachineSlots returns the number of virtual machines that can still be created, along with the maximum.
+// Virtual machines waiting for their image or for a slot count as created.
+func (c *MacOSClient) VirtualMachineSlots() (available, total int) {
+	return max(MaxVirtualMachines-int(c.data.Count()), 0), MaxVirtualMachines
+}
+
 // setupVM creates a new virtual machine instance with the given parameters.
-func setupVM(ctx context.Context, cfg config.MacPlatformConfigurationOptions, uid string, cpu uint, memorySize uint64, networkInterfaceIdentifier string, mounts []volumes.Mount) (*vm.VirtualMachineInstance, error) {
-	log.G(ctx).Debugf("Creating virtual machine with CPU: %d, memory: %d, network interface: %s, mounts: %+v", cpu, memorySize, networkInterfaceIdentifier, mounts)
-	platformConfig, err := config.NewPlatformConfiguration(ctx, cfg, true, uid)
+func setupVM(ctx context.Context, cfg config.MacPlatformConfigurationOptions, diskMode config.DiskMode, uid string, cpu uint, memorySize uint64, network config.NetworkOptions, mounts []volumes.Mount, devices config.DeviceOptions, cpuQoS config.CPUQoS, ipDiscovery []string, resolverCfg vm.IPResolverConfig) (*vm.VirtualMachineInstance, error) {
+	log.G(ctx).Debugf("Creating virtual machine with CPU: %d, memory: %d, network: %+v, mounts: %+v, devices: %+v, disk mode: %s, CPU QoS: %s", cpu, memorySize, network, mounts, devices, diskMode, cpuQoS)
+
+	// fail before the storage of the virtual machine is prepared
+	if err := config.ValidateResources(cpu, memorySize); err != nil {
+		return nil, err
+	}
+
+	platformConfig, err := config.NewPlatformConfiguration(ctx, cfg, diskMode, uid)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create platform configuration: %w", err)
 	}
 
-	vmConfig, err := config.NewVirtualMachineConfiguration(ctx, platformConfig, cpu, memorySize, networkInterfaceIdentifier, mounts)
+	vmConfig, err := config.NewVirtualMachineConfiguration(ctx, platformConfig, cpu, memorySize, network, mounts, devices)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create virtual machine configuration: %w", err)
 	}
+	vmConfig.CPUQoS = cpuQoS
+
+	// the VM may have fallen back to NAT, whose traffic does not go through the bridge interface
+	resolverCfg.NetworkInterface = vmConfig.NetworkInterface
+	resolver, err := vm.NewIPResolver(ipDiscovery, resolverCfg)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create IP resolver: %w", err)
+	}
 
-	vmInstance, err := vm.NewVirtualMachineInstance(ctx, vmConfig)
+	vmInstance, err := vm.NewVirtualMachineInstance(ctx, vmConfig, resolver)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create virtual machine instance: %w", err)
 	}
@@ -584,43 +1261,118 @@ func setupVM(ctx context.Context, cfg config.MacPlatformConfigurationOptions, ui
 }
 
 // establishVirtualMachineSshConn establishes an SSH connection to the specified virtual machine.
-func establishVirtualMachineSshConn(ctx context.Context, vm resource.MacOSVirtualMachine) (*ssh.Client, error) {
+func establishVirtualMachineSshConn(ctx context.Context, vm resource.MacOSVirtualMachine, creds SSHCredentials) (*vzssh.Connection, error) {
 	ipAddr := vm.IPAddress()
 	if ipAddr == "" {
 		return nil, errdefs.InvalidInputf("virtual machine does not have an IP address")
 	}
+	return DialSSH(ctx, ipAddr, creds)
+}
 
-	sshUser, sshPassword, err := getSSHCredentials()
+// DialSSH establishes an SSH connection with keepalive to the SSH server inside the virtual machine with the given IP address.
+func DialSSH(ctx context.Context, ipAddr string, creds SSHCredentials) (*vzssh.Connection, error) {
+	config, err := creds.clientConfig()
 	if err != nil {
 		return nil, err
 	}
 
-	// Setup SSH client configuration
-	config := &ssh.ClientConfig{
-		User: sshUser,
-		Auth: []ssh.AuthMethod{
-			ssh.Password(sshPassword),
-		},
-		HostKeyCallback: ssh.InsecureIgnoreHostKey(),
+	addr, err := SSHAddress(ipAddr)
+	if err != nil {
+		return nil, err
 	}
 
 	// Establish SSH connection with keepalive
-	conn, err := vzssh.DialContext(ctx, "tcp", vm.IPAddress()+":22", config)
+	client, err := vzssh.DialContext(ctx, "tcp", addr, config)
 	if err != nil {
 		return nil, err
 	}
 
-	go vzssh.SendKeepalive(ctx, conn)
+	return vzssh.NewConnection(ctx, client), nil
+}
+
+// SSHPort returns the port of the SSH server inside the virtual machines,
+// configured by the VZ_SSH_PORT env variable and DefaultSSHPort by default.
+func SSHPort() (int, error) {
+	value := os.Getenv("VZ_SSH_PORT")
+	if value == "" {
+		return DefaultSSHPort, nil
+	}
+	port, err := strconv.Atoi(value)
+	if err != nil || port < 1 || port > 65535 {
+		return 0, errdefs.InvalidInputf("VZ_SSH_PORT env variable must be a valid port number: %q", value)
+	}
+	return port, nil
+}
 
-	return conn, nil
+// SSHAddress returns the address of the SSH server inside the virtual machine with the given IP address.
+func SSHAddress(ipAddr string) (string, error) {
+	port, err := SSHPort()
+	if err != nil {
+		return "", err
+	}
+	return net.JoinHostPort(ipAddr, strconv.Itoa(port)), nil
+}
+
+// SSHCredentials are the credentials used to connect to the SSH server inside a virtual machine.
+type SSHCredentials struct {
+	Username   string
+	Password   string
+	PrivateKey []byte
+}
+
+// clientConfig returns the SSH client configuration authenticating with the credentials.
+func (c SSHCredentials) clientConfig() (*ssh.ClientConfig, error) {
+	var auth []ssh.AuthMethod
+	if len(c.PrivateKey) > 0 {
+		signer, err := ssh.ParsePrivateKey(c.PrivateKey)
+		if err != nil {
+			return nil, errdefs.InvalidInputf("failed to parse SSH private key: %v", err)
+		}
+		auth = append(auth, ssh.PublicKeys(signer))
+	}
+	if c.Password != "" {
+		auth = append(auth, ssh.Password(c.Password))
+	}
+
+	return &ssh.ClientConfig{
+		User:            c.Username,
+		Auth:            auth,
+		HostKeyCallback: ssh.InsecureIgnoreHostKey(),
+	}, nil
+}
+
+// SSHCredentialsFunc returns the SSH credentials of the virtual machine of the given pod,
+// or nil if the pod does not define any and the process-wide credentials should be used.
+type SSHCredentialsFunc func(ctx context.Context, namespace, name string) (*SSHCredentials, error)
+
+// WithSSHCredentials resolves the SSH credentials per pod, falling back to the VZ_SSH_USER
+// and VZ_SSH_PASSWORD env variables for pods that do not define any.
+func WithSSHCredentials(f SSHCredentialsFunc) MacOSClientOption {
+	return func(c *MacOSClient) {
+		c.sshCredentials = f
+	}
+}
+
+// SSHCredentials returns the SSH credentials of the virtual machine of the given pod.
+func (c *MacOSClient) SSHCredentials(ctx context.Context, namespace, name string) (SSHCredentials, error) {
+	if c.sshCredentials != nil {
+		creds, err := c.sshCredentials(ctx, namespace, name)
+		if err != nil {
+			return SSHCredentials{}, fmt.Errorf("failed to resolve SSH credentials: %w", err)
+		}
+		if creds != nil {
+			return *creds, nil
+		}
+	}
+	return getSSHCredentials()
 }
 
 // getSSHCredentials retrieves SSH credentials from environment variables.
-func getSSHCredentials() (string, string, error) {
+func getSSHCredentials() (SSHCredentials, error) {
 	sshUser := os.Getenv("VZ_SSH_USER")
 	sshPassword := os.Getenv("VZ_SSH_PASSWORD")
 	if sshUser == "" || sshPassword == "" {
-		return "", "", errdefs.InvalidInputf("VZ_SSH_USER and VZ_SSH_PASSWORD env variables are required")
+		return SSHCredentials{}, errdefs.InvalidInputf("VZ_SSH_USER and VZ_SSH_PASSWORD env variables are required")
 	}
-	return sshUser, sshPassword, nil
+	return SSHCredentials{Username: sshUser, Password: sshPassword}, nil
 }
@@ -0,0 +1,158 @@
+package resourcemanager_test
+
+import (
+	"context"
+	"errors"
+	"net"
+	"strconv"
+	"testing"
+
+	"github.com/agoda-com/macOS-vz-kubelet/pkg/event/mocks"
+	"github.com/agoda-com/macOS-vz-kubelet/pkg/resourcemanager"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"github.com/virtual-kubelet/virtual-kubelet/errdefs"
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/testdata"
+)
+
+func TestSSHAddress(t *testing.T) {
+	tests := []struct {
+		name        string
+		port        string
+		ipAddr      string
+		expected    string
+		expectError bool
+	}{
+		{name: "default port", ipAddr: "192.168.64.2", expected: "192.168.64.2:22"},
+		{name: "configured port", port: "2222", ipAddr: "192.168.64.2", expected: "192.168.64.2:2222"},
+		{name: "IPv6 address", port: "2222", ipAddr: "fd00::2", expected: "[fd00::2]:2222"},
+		{name: "not a number", port: "ssh", ipAddr: "192.168.64.2", expectError: true},
+		{name: "out of range", port: "65536", ipAddr: "192.168.64.2", expectError: true},
+		{name: "zero", port: "0", ipAddr: "192.168.64.2", expectError: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("VZ_SSH_PORT", tt.port)
+
+			addr, err := resourcemanager.SSHAddress(tt.ipAddr)
+			if tt.expectError {
+				assert.True(t, errdefs.IsInvalidInput(err), "expected invalid input error, got %v", err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, addr)
+		})
+	}
+}
+
+func TestSSHCredentialsOverride(t *testing.T) {
+	ctx := context.Background()
+	t.Setenv("VZ_SSH_USER", "env-user")
+	t.Setenv("VZ_SSH_PASSWORD", "env-password")
+
+	// the guest only accepts the credentials of the pod secret
+	port := startPasswordSSHServer(t, "pod-user", "pod-password")
+	t.Setenv("VZ_SSH_PORT", strconv.Itoa(port))
+
+	c := resourcemanager.NewMacOSClient(ctx, mocks.NewEventRecorder(t), "", t.TempDir(),
+		resourcemanager.WithSSHCredentials(func(_ context.Context, namespace, name string) (*resourcemanager.SSHCredentials, error) {
+			if name != "with-secret" {
+				return nil, nil
+			}
+			return &resourcemanager.SSHCredentials{Username: "pod-user", Password: "pod-password"}, nil
+		}),
+	)
+
+	creds, err := c.SSHCredentials(ctx, "default", "with-secret")
+	require.NoError(t, err)
+	assert.Equal(t, resourcemanager.SSHCredentials{Username: "pod-user", Password: "pod-password"}, creds)
+	client, err := resourcemanager.DialSSH(ctx, "127.0.0.1", creds)
+	require.NoError(t, err)
+	assert.NoError(t, client.Close())
+
+	// pods without a secret fall back to the env credentials
+	creds, err = c.SSHCredentials(ctx, "default", "without-secret")
+	require.NoError(t, err)
+	assert.Equal(t, resourcemanager.SSHCredentials{Username: "env-user", Password: "env-password"}, creds)
+	_, err = resourcemanager.DialSSH(ctx, "127.0.0.1", creds)
+	assert.Error(t, err)
+}
+
+// startPasswordSSHServer starts an SSH server accepting the given password credentials and returns its port.
+func startPasswordSSHServer(t *testing.T, user, password string) int {
+	t.Helper()
+
+	private, err := ssh.ParsePrivateKey(testdata.PEMBytes["rsa"])
+	require.NoError(t, err)
+	config := &ssh.ServerConfig{
+		PasswordCallback: func(conn ssh.ConnMetadata, pass []byte) (*ssh.Permissions, error) {
+			if conn.User() == user && string(pass) == password {
+				return nil, nil
+			}
+			return nil, errors.New("invalid credentials")
+		},
+	}
+	config.AddHostKey(private)
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = listener.Close() })
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				sconn, chans, reqs, err := ssh.NewServerConn(conn, config)
+				if err != nil {
+					return
+				}
+				go ssh.DiscardRequests(reqs)
+				for ch := range chans {
+					_ = ch.Reject(ssh.Prohibited, "no channels")
+				}
+				_ = sconn.Close()
+			}()
+		}
+	}()
+	return listener.Addr().(*net.TCPAddr).Port
+}
+
+func TestGracefulStop(t *testing.T) {
+	shutdownErr := errors.New("sudo: a password is required")
+
+	tests := []struct {
+		name        string
+		shutdownErr error
+	}{
+		{name: "graceful shutdown"},
+		{name: "force stop", shutdownErr: shutdownErr},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			recorder := mocks.NewEventRecorder(t)
+			if tt.shutdownErr != nil {
+				recorder.On("ForceStopped", mock.Anything, "macos", tt.shutdownErr).Return().Once()
+			}
+
+			var calls []string
+			err := resourcemanager.GracefulStop(context.Background(), recorder, "macos", func() error {
+				calls = append(calls, "shutdown")
+				return tt.shutdownErr
+			}, func() error {
+				calls = append(calls, "stop")
+				return nil
+			})
+			require.NoError(t, err)
+			assert.Equal(t, []string{"shutdown", "stop"}, calls)
+		})
+	}
+}
@@ -0,0 +1,90 @@
+package resourcemanager
+
+import (
+	"context"
+	"errors"
+
+	vmdata "github.com/agoda-com/macOS-vz-kubelet/internal/data/vm"
+	"github.com/agoda-com/macOS-vz-kubelet/pkg/resource"
+
+	"github.com/virtual-kubelet/virtual-kubelet/log"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// Preemptor makes room for the virtual machines of higher priority pods when the node is at capacity,
+// mirroring the kubelet preemption of critical pods.
+//
+// Preempted virtual machines are marked as failed with resource.ErrPreempted. The provider then deletes
+// the failed pod, which gracefully stops the virtual machine and releases its slot.
+type Preemptor struct {
+	Data *vmdata.VirtualMachineData
+}
+
+// Preempt marks the lowest priority virtual machine of another pod with a priority lower than the given one
+// as preempted, and returns its key. Nothing is preempted while a previously preempted virtual machine
+// is still holding its slot, so that a single pending virtual machine never preempts more than it needs.
+func (p *Preemptor) Preempt(ctx context.Context, key types.NamespacedName, priority int32) (types.NamespacedName, bool) {
+	podName, _ := SplitVirtualMachineName(key.Name)
+
+	var (
+		victim     types.NamespacedName
+		victimInfo vmdata.VirtualMachineInfo
+		found      bool
+	)
+	for k, info := range p.Data.ListVirtualMachines() {
+		err := info.Resource.Error()
+		if errors.Is(err, resource.ErrPreempted) {
+			// wait for the preempted virtual machine to be deleted
+			return types.NamespacedName{}, false
+		}
+		if err != nil || info.Priority >= priority {
+			continue
+		}
+		if victimPodName, _ := SplitVirtualMachineName(k.Name); k.Namespace == key.Namespace && victimPodName == podName {
+			continue
+		}
+		if !found || preemptsBefore(k, info, victim, victimInfo) {
+			victim, victimInfo, found = k, info, true
+		}
+	}
+	if !found {
+		return types.NamespacedName{}, false
+	}
+
+	preempted := false
+	p.Data.UpdateVirtualMachineInfo(victim.Namespace, victim.Name, func(i vmdata.VirtualMachineInfo) vmdata.VirtualMachineInfo {
+		// re-check, the virtual machine might have failed since it was listed
+		if preempted = i.Resource.Error() == nil; preempted {
+			i.Resource.SetError(resource.ErrPreempted)
+		}
+		return i
+	})
+	if !preempted {
+		return types.NamespacedName{}, false
+	}
+
+	log.G(ctx).WithFields(log.Fields{
+		"victim":         victim,
+		"victimPriority": victimInfo.Priority,
+		"priority":       priority,
+	}).Info("Node is at capacity, preempting virtual machine of a lower priority pod")
+	return victim, true
+}
+
+// preemptsBefore reports whether the virtual machine a is preempted before b: lower priorities go first,
+// then the most recently created virtual machines, which have done the least work so far.
+func preemptsBefore(aKey types.NamespacedName, a vmdata.VirtualMachineInfo, bKey types.NamespacedName, b vmdata.VirtualMachineInfo) bool {
+	if a.Priority != b.Priority {
+		return a.Priority < b.Priority
+	}
+	aCreated, bCreated := a.Resource.CreatedAt(), b.Resource.CreatedAt()
+	switch {
+	case aCreated == nil && bCreated != nil:
+		return true
+	case aCreated != nil && bCreated == nil:
+		return false
+	case aCreated != nil && !aCreated.Equal(*bCreated):
+		return aCreated.After(*bCreated)
+	}
+	return aKey.String() < bKey.String()
+}
@@ -0,0 +1,78 @@
+package resourcemanager_test
+
+import (
+	"context"
+	"testing"
+
+	vmdata "github.com/agoda-com/macOS-vz-kubelet/internal/data/vm"
+	"github.com/agoda-com/macOS-vz-kubelet/pkg/resource"
+	"github.com/agoda-com/macOS-vz-kubelet/pkg/resourcemanager"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+func addPrioritizedVirtualMachine(t *testing.T, data *vmdata.VirtualMachineData, name string, priority int32) types.NamespacedName {
+	t.Helper()
+
+	_, loaded := data.GetOrCreateVirtualMachineInfo("default", name, vmdata.VirtualMachineInfo{
+		Priority: priority,
+		Resource: resource.NewMacOSVirtualMachine(nil),
+	})
+	require.False(t, loaded)
+	return types.NamespacedName{Namespace: "default", Name: name}
+}
+
+func TestPreemptor(t *testing.T) {
+	ctx := context.Background()
+	data := &vmdata.VirtualMachineData{}
+	low := addPrioritizedVirtualMachine(t, data, "low", 0)
+	medium := addPrioritizedVirtualMachine(t, data, "medium", 100)
+	pending := addPrioritizedVirtualMachine(t, data, "high", 1000)
+
+	preemptor := &resourcemanager.Preemptor{Data: data}
+
+	// The lowest priority virtual machine is preempted
+	victim, ok := preemptor.Preempt(ctx, pending, 1000)
+	require.True(t, ok)
+	assert.Equal(t, low, victim)
+
+	info, ok := data.GetVirtualMachineInfo(low.Namespace, low.Name)
+	require.True(t, ok)
+	assert.ErrorIs(t, info.Resource.Error(), resource.ErrPreempted)
+	assert.Equal(t, resource.VirtualMachineStateFailed, info.Resource.State())
+
+	// Nothing else is preempted until the preempted virtual machine is deleted
+	_, ok = preemptor.Preempt(ctx, pending, 1000)
+	assert.False(t, ok)
+	info, ok = data.GetVirtualMachineInfo(medium.Namespace, medium.Name)
+	require.True(t, ok)
+	assert.NoError(t, info.Resource.Error())
+
+	// Once deleted, the next lowest priority virtual machine can be preempted
+	data.RemoveVirtualMachineInfo(low.Namespace, low.Name)
+	victim, ok = preemptor.Preempt(ctx, pending, 1000)
+	require.True(t, ok)
+	assert.Equal(t, medium, victim)
+}
+
+func TestPreemptorNoLowerPriority(t *testing.T) {
+	ctx := context.Background()
+	data := &vmdata.VirtualMachineData{}
+	same := addPrioritizedVirtualMachine(t, data, "same", 100)
+	higher := addPrioritizedVirtualMachine(t, data, "higher", 1000)
+	// virtual machines of the pending pod itself are never preempted
+	sibling := addPrioritizedVirtualMachine(t, data, resourcemanager.AdditionalVirtualMachineName("pending", "worker"), 0)
+	pending := addPrioritizedVirtualMachine(t, data, "pending", 100)
+
+	preemptor := &resourcemanager.Preemptor{Data: data}
+	_, ok := preemptor.Preempt(ctx, pending, 100)
+	assert.False(t, ok)
+
+	for _, key := range []types.NamespacedName{same, higher, sibling} {
+		info, ok := data.GetVirtualMachineInfo(key.Namespace, key.Name)
+		require.True(t, ok)
+		assert.NoError(t, info.Resource.Error(), key.Name)
+	}
+}
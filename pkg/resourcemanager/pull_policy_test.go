@@ -0,0 +1,98 @@
+package resourcemanager_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/agoda-com/macOS-vz-kubelet/internal/disk"
+	"github.com/agoda-com/macOS-vz-kubelet/pkg/downloader"
+	"github.com/agoda-com/macOS-vz-kubelet/pkg/event"
+	"github.com/agoda-com/macOS-vz-kubelet/pkg/resourcemanager"
+
+	"github.com/opencontainers/go-digest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+)
+
+// pulledImageRecorder signals the images reported as pulled.
+type pulledImageRecorder struct {
+	event.LogEventRecorder
+	pulled chan string
+}
+
+func (r pulledImageRecorder) PulledImage(_ context.Context, image, _, _ string) {
+	r.pulled <- image
+}
+
+// writeCachedImage writes the image fully present in the cache, verified against its digests.
+func writeCachedImage(t *testing.T, cachePath, image string) {
+	t.Helper()
+	ref, err := downloader.ParseReference(image)
+	require.NoError(t, err)
+	blobs := filepath.Join(cachePath, "blobs", downloader.CachePath(ref))
+	require.NoError(t, os.MkdirAll(blobs, 0o755))
+	verified := time.Now().Add(time.Second)
+	for name, content := range map[string]string{
+		// the invalid hardware model fails the creation of the virtual machine right after the image is resolved
+		"config.json": `{"os":"darwin","hardwareModelData":"!","machineIdData":"!"}`,
+		"disk.img":    "disk",
+		"aux.img":     "aux",
+	} {
+		path := filepath.Join(blobs, name)
+		require.NoError(t, os.WriteFile(path, []byte(content), 0o600))
+		require.NoError(t, os.WriteFile(path+disk.DigestFileSuffix, []byte(digest.FromString(content)), 0o600))
+		require.NoError(t, os.Chtimes(path+disk.DigestFileSuffix, verified, verified))
+	}
+}
+
+func TestCreateVirtualMachineIfNotPresentSkipsDownload(t *testing.T) {
+	ctx := context.Background()
+
+	var requests atomic.Int32
+	registry := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		<-r.Context().Done()
+	}))
+	t.Cleanup(registry.Close)
+
+	cachePath := t.TempDir()
+	image := strings.TrimPrefix(registry.URL, "http://") + "/macos:latest"
+	writeCachedImage(t, cachePath, image)
+
+	recorder := pulledImageRecorder{pulled: make(chan string, 2)}
+	c := resourcemanager.NewMacOSClient(ctx, recorder, "", cachePath)
+	params := resourcemanager.VirtualMachineParams{
+		UID:             "uid",
+		Image:           image,
+		Namespace:       "default",
+		Name:            "cached",
+		ContainerName:   "macos",
+		ImagePullPolicy: corev1.PullIfNotPresent,
+	}
+	require.NoError(t, c.CreateVirtualMachine(ctx, params))
+	t.Cleanup(func() { _ = c.DeleteVirtualMachine(ctx, params.Namespace, params.Name, 0) })
+
+	select {
+	case pulled := <-recorder.pulled:
+		assert.Equal(t, image, pulled)
+	case <-time.After(5 * time.Second):
+		t.Fatal("the cached image was not resolved")
+	}
+	assert.Zero(t, requests.Load(), "the registry is not contacted for a cached image")
+
+	// the Always pull policy downloads the image regardless
+	params.Name = "always"
+	params.ImagePullPolicy = corev1.PullAlways
+	params.IgnoreImageCache = true
+	require.NoError(t, c.CreateVirtualMachine(ctx, params))
+	t.Cleanup(func() { _ = c.DeleteVirtualMachine(ctx, params.Namespace, params.Name, 0) })
+	require.Eventually(t, func() bool { return requests.Load() > 0 }, 5*time.Second, 10*time.Millisecond)
+}
@@ -0,0 +1,74 @@
+package resourcemanager
+
+import (
+	"sync"
+
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// NamespaceQuotas limits the number of virtual machines that can run concurrently within a namespace.
+type NamespaceQuotas struct {
+	// Default is the quota of the namespaces without an explicit quota, zero means unlimited.
+	Default int
+	// Namespaces contains the explicit quotas keyed by namespace.
+	Namespaces map[string]int
+}
+
+// Limit returns the quota of the namespace, zero means unlimited.
+func (q NamespaceQuotas) Limit(namespace string) int {
+	if limit, ok := q.Namespaces[namespace]; ok {
+		return limit
+	}
+	return q.Default
+}
+
+// SlotReservations tracks the virtual machine slots reserved by every namespace and enforces the namespace quotas.
+type SlotReservations struct {
+	mu       sync.Mutex
+	quotas   NamespaceQuotas
+	reserved map[types.NamespacedName]struct{}
+	counts   map[string]int
+}
+
+// NewSlotReservations creates a new SlotReservations enforcing the given quotas.
+func NewSlotReservations(quotas NamespaceQuotas) *SlotReservations {
+	return &SlotReservations{
+		quotas:   quotas,
+		reserved: make(map[types.NamespacedName]struct{}),
+		counts:   make(map[string]int),
+	}
+}
+
+// TryReserve reserves a slot for the virtual machine unless its namespace has reached the quota.
+// Reserving a slot for a virtual machine that already holds one succeeds without reserving another one.
+// The quota of the namespace is returned alongside the result.
+func (s *SlotReservations) TryReserve(key types.NamespacedName) (ok bool, limit int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	limit = s.quotas.Limit(key.Namespace)
+	if _, ok := s.reserved[key]; ok {
+		return true, limit
+	}
+	if limit > 0 && s.counts[key.Namespace] >= limit {
+		return false, limit
+	}
+
+	s.reserved[key] = struct{}{}
+	s.counts[key.Namespace]++
+	return true, limit
+}
+
+// Release releases the slot reserved for the virtual machine, if any.
+func (s *SlotReservations) Release(key types.NamespacedName) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.reserved[key]; !ok {
+		return
+	}
+	delete(s.reserved, key)
+	if s.counts[key.Namespace]--; s.counts[key.Namespace] <= 0 {
+		delete(s.counts, key.Namespace)
+	}
+}
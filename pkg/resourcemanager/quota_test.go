@@ -0,0 +1,55 @@
+package resourcemanager_test
+
+import (
+	"testing"
+
+	"github.com/agoda-com/macOS-vz-kubelet/pkg/resourcemanager"
+
+	"github.com/stretchr/testify/assert"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+func TestNamespaceQuotasLimit(t *testing.T) {
+	quotas := resourcemanager.NamespaceQuotas{
+		Default:    2,
+		Namespaces: map[string]int{"ci": 1, "unlimited": 0},
+	}
+
+	assert.Equal(t, 1, quotas.Limit("ci"))
+	assert.Equal(t, 0, quotas.Limit("unlimited"))
+	assert.Equal(t, 2, quotas.Limit("default"))
+	assert.Equal(t, 0, resourcemanager.NamespaceQuotas{}.Limit("default"))
+}
+
+func TestSlotReservations(t *testing.T) {
+	slots := resourcemanager.NewSlotReservations(resourcemanager.NamespaceQuotas{
+		Namespaces: map[string]int{"team-a": 1},
+	})
+	first := types.NamespacedName{Namespace: "team-a", Name: "first"}
+	second := types.NamespacedName{Namespace: "team-a", Name: "second"}
+	other := types.NamespacedName{Namespace: "team-b", Name: "other"}
+
+	ok, limit := slots.TryReserve(first)
+	assert.True(t, ok)
+	assert.Equal(t, 1, limit)
+
+	// reserving again for the same virtual machine does not consume another slot
+	ok, _ = slots.TryReserve(first)
+	assert.True(t, ok)
+
+	// namespace at quota is blocked while another namespace proceeds
+	ok, limit = slots.TryReserve(second)
+	assert.False(t, ok)
+	assert.Equal(t, 1, limit)
+	ok, limit = slots.TryReserve(other)
+	assert.True(t, ok)
+	assert.Equal(t, 0, limit)
+
+	// releasing a slot unblocks the namespace
+	slots.Release(second) // no-op, nothing reserved
+	ok, _ = slots.TryReserve(second)
+	assert.False(t, ok)
+	slots.Release(first)
+	ok, _ = slots.TryReserve(second)
+	assert.True(t, ok)
+}
@@ -0,0 +1,79 @@
+package resourcemanager
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/agoda-com/macOS-vz-kubelet/internal/node"
+	"github.com/agoda-com/macOS-vz-kubelet/pkg/event"
+	"github.com/agoda-com/macOS-vz-kubelet/pkg/resource"
+
+	"github.com/virtual-kubelet/virtual-kubelet/log"
+	"github.com/virtual-kubelet/virtual-kubelet/trace"
+	"k8s.io/apimachinery/pkg/util/wait"
+)
+
+const (
+	// DefaultReadinessTimeout is how long a started virtual machine may take to accept SSH connections before it is failed.
+	DefaultReadinessTimeout = 5 * time.Minute
+
+	// ReadinessProbeInterval is the interval between the attempts to run a command inside a started virtual machine.
+	ReadinessProbeInterval = 2 * time.Second
+)
+
+// readinessProbeCommand is run inside the guest to check whether it accepts SSH connections.
+var readinessProbeCommand = []string{"true"}
+
+// ReadinessCheck waits for a started virtual machine to accept SSH connections. The guest configuration,
+// the post-start hook and the command of the virtual machine all run over SSH, so a virtual machine that
+// has an IP address but never accepts SSH connections would otherwise never get to do anything.
+type ReadinessCheck struct {
+	ContainerName string
+	// Timeout is how long the virtual machine may take to become ready, zero disables the check.
+	Timeout time.Duration
+	// Interval is the interval between the attempts, ReadinessProbeInterval if zero.
+	Interval time.Duration
+
+	ExecFunc      ExecFunc
+	EventRecorder event.EventRecorder
+}
+
+// Wait runs a no-op command inside the guest until it succeeds. If it does not succeed within the timeout,
+// it records an event and returns an error wrapping resource.ErrReadinessTimeout, which fails the pod.
+func (r *ReadinessCheck) Wait(ctx context.Context) (err error) {
+	if r.Timeout <= 0 {
+		return nil
+	}
+
+	ctx, span := trace.StartSpan(ctx, "ReadinessCheck.Wait")
+	defer func() {
+		span.SetStatus(err)
+		span.End()
+	}()
+
+	interval := r.Interval
+	if interval <= 0 {
+		interval = ReadinessProbeInterval
+	}
+
+	var lastErr error
+	err = wait.PollUntilContextTimeout(ctx, interval, r.Timeout, true, func(ctx context.Context) (bool, error) {
+		lastErr = r.ExecFunc(ctx, readinessProbeCommand, node.DiscardingExecIO())
+		return lastErr == nil, nil
+	})
+	if err == nil {
+		log.G(ctx).Debug("Virtual machine accepts SSH connections")
+		return nil
+	}
+	if ctx.Err() != nil {
+		// the creation was canceled, e.g. because the pod was deleted
+		return ctx.Err()
+	}
+	if lastErr == nil {
+		lastErr = err
+	}
+
+	r.EventRecorder.ReadinessTimeout(ctx, r.ContainerName, r.Timeout, lastErr)
+	return fmt.Errorf("%w: %w", resource.ErrReadinessTimeout, lastErr)
+}
@@ -0,0 +1,94 @@
+package resourcemanager_test
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/agoda-com/macOS-vz-kubelet/pkg/event/mocks"
+	"github.com/agoda-com/macOS-vz-kubelet/pkg/resource"
+	"github.com/agoda-com/macOS-vz-kubelet/pkg/resourcemanager"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"github.com/virtual-kubelet/virtual-kubelet/node/api"
+)
+
+func TestReadinessCheckNeverReady(t *testing.T) {
+	execErr := errors.New("dial tcp 10.0.0.3:22: connect: connection refused")
+	recorder := mocks.NewEventRecorder(t)
+	recorder.On("ReadinessTimeout", mock.Anything, "macos", 50*time.Millisecond, execErr).Return().Once()
+
+	var attempts atomic.Int32
+	check := &resourcemanager.ReadinessCheck{
+		ContainerName: "macos",
+		Timeout:       50 * time.Millisecond,
+		Interval:      10 * time.Millisecond,
+		ExecFunc: func(ctx context.Context, cmd []string, attach api.AttachIO) error {
+			attempts.Add(1)
+			return execErr
+		},
+		EventRecorder: recorder,
+	}
+
+	start := time.Now()
+	err := check.Wait(context.Background())
+	require.ErrorIs(t, err, resource.ErrReadinessTimeout)
+	assert.ErrorIs(t, err, execErr)
+	assert.GreaterOrEqual(t, time.Since(start), 50*time.Millisecond)
+	assert.Greater(t, attempts.Load(), int32(1))
+}
+
+func TestReadinessCheckBecomesReady(t *testing.T) {
+	var attempts atomic.Int32
+	check := &resourcemanager.ReadinessCheck{
+		ContainerName: "macos",
+		Timeout:       time.Second,
+		Interval:      10 * time.Millisecond,
+		ExecFunc: func(ctx context.Context, cmd []string, attach api.AttachIO) error {
+			if attempts.Add(1) < 3 {
+				return errors.New("connection refused")
+			}
+			return nil
+		},
+		EventRecorder: mocks.NewEventRecorder(t),
+	}
+
+	assert.NoError(t, check.Wait(context.Background()))
+	assert.Equal(t, int32(3), attempts.Load())
+}
+
+func TestReadinessCheckDisabled(t *testing.T) {
+	check := &resourcemanager.ReadinessCheck{
+		ContainerName: "macos",
+		ExecFunc: func(ctx context.Context, cmd []string, attach api.AttachIO) error {
+			t.Fatal("readiness is not checked when disabled")
+			return nil
+		},
+		EventRecorder: mocks.NewEventRecorder(t),
+	}
+
+	assert.NoError(t, check.Wait(context.Background()))
+}
+
+func TestReadinessCheckCanceled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	check := &resourcemanager.ReadinessCheck{
+		ContainerName: "macos",
+		Timeout:       time.Minute,
+		Interval:      10 * time.Millisecond,
+		ExecFunc: func(ctx context.Context, cmd []string, attach api.AttachIO) error {
+			cancel()
+			return errors.New("connection refused")
+		},
+		// no event is recorded for a canceled creation
+		EventRecorder: mocks.NewEventRecorder(t),
+	}
+
+	err := check.Wait(ctx)
+	assert.ErrorIs(t, err, context.Canceled)
+	assert.NotErrorIs(t, err, resource.ErrReadinessTimeout)
+}
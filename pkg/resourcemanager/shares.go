@@ -0,0 +1,120 @@
+package resourcemanager
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	vzio "github.com/agoda-com/macOS-vz-kubelet/internal/io"
+	"github.com/agoda-com/macOS-vz-kubelet/internal/node"
+	"github.com/agoda-com/macOS-vz-kubelet/internal/volumes"
+	"github.com/agoda-com/macOS-vz-kubelet/pkg/event"
+	"github.com/agoda-com/macOS-vz-kubelet/pkg/vm/config"
+
+	"github.com/virtual-kubelet/virtual-kubelet/log"
+	"github.com/virtual-kubelet/virtual-kubelet/node/api"
+	"github.com/virtual-kubelet/virtual-kubelet/trace"
+)
+
+// ExecFunc executes a command inside the guest.
+type ExecFunc func(ctx context.Context, cmd []string, attach api.AttachIO) error
+
+// ShareVerifier verifies that directories shared over virtiofs are still accessible inside the guest.
+// A share may become stale on long-running virtual machines, e.g. when the host directory is recreated.
+type ShareVerifier struct {
+	ContainerName string
+	Mounts        []volumes.Mount
+	AutomountTag  string
+
+	ExecFunc      ExecFunc
+	EventRecorder event.EventRecorder
+}
+
+// ShareVerificationCommand returns a shell command that lists every shared directory inside the guest
+// and prints the paths of the ones that are not accessible, one per line.
+func ShareVerificationCommand(mounts []volumes.Mount) []string {
+	paths := config.GuestSharedDirectoryPaths(mounts)
+	for i, path := range paths {
+		paths[i] = strconv.Quote(path)
+	}
+	script := fmt.Sprintf(`for d in %s; do ls "$d" > /dev/null 2>&1 || echo "$d"; done`, strings.Join(paths, " "))
+	return []string{"sh", "-c", script}
+}
+
+// ShareRemountCommand returns a shell command that remounts the virtiofs share with the given tag inside the guest.
+// This will not work if sudo requires a password.
+func ShareRemountCommand(automountTag string) []string {
+	sharedDir := strconv.Quote(config.MacOSSharedDirectoryPath)
+	script := fmt.Sprintf(
+		"sudo -n umount -f %[1]s > /dev/null 2>&1; sudo -n mkdir -p %[1]s && sudo -n mount_virtiofs %[2]s %[1]s",
+		sharedDir, strconv.Quote(automountTag),
+	)
+	return []string{"sh", "-c", script}
+}
+
+// Verify returns the guest paths of the shared directories that are not accessible.
+func (v *ShareVerifier) Verify(ctx context.Context) ([]string, error) {
+	stdout := &bytes.Buffer{}
+	buf := vzio.NewBufferWriteCloser(stdout)
+	attach := node.NewExecIO(false, nil, buf, buf, nil)
+
+	if err := v.ExecFunc(ctx, ShareVerificationCommand(v.Mounts), attach); err != nil {
+		return nil, fmt.Errorf("error executing share verification command: %w", err)
+	}
+
+	var inaccessible []string
+	for _, line := range strings.Split(stdout.String(), "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			inaccessible = append(inaccessible, line)
+		}
+	}
+	return inaccessible, nil
+}
+
+// Reconcile verifies the shared directories and attempts to remount them if any of them is not accessible.
+// A warning event is recorded if the shares are still inaccessible after the remount attempt.
+func (v *ShareVerifier) Reconcile(ctx context.Context) (err error) {
+	ctx, span := trace.StartSpan(ctx, "ShareVerifier.Reconcile")
+	defer func() {
+		span.SetStatus(err)
+		span.End()
+	}()
+	logger := log.G(ctx)
+
+	inaccessible, err := v.Verify(ctx)
+	if err != nil || len(inaccessible) == 0 {
+		return err
+	}
+
+	logger.Warnf("Shared directories are not accessible, attempting to remount: %v", inaccessible)
+	if err := v.ExecFunc(ctx, ShareRemountCommand(v.AutomountTag), node.DiscardingExecIO()); err != nil {
+		logger.WithError(err).Warn("Failed to remount shared directories")
+	}
+
+	inaccessible, err = v.Verify(ctx)
+	if err != nil || len(inaccessible) == 0 {
+		return err
+	}
+
+	v.EventRecorder.FailedToMountSharedDirectories(ctx, v.ContainerName, inaccessible)
+	return fmt.Errorf("shared directories are not accessible: %v", inaccessible)
+}
+
+// Run periodically reconciles the shared directories until the context is done.
+func (v *ShareVerifier) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := v.Reconcile(ctx); err != nil {
+				log.G(ctx).WithError(err).Debug("Shared directories reconciliation failed")
+			}
+		}
+	}
+}
@@ -0,0 +1,131 @@
+package resourcemanager_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/agoda-com/macOS-vz-kubelet/internal/volumes"
+	"github.com/agoda-com/macOS-vz-kubelet/pkg/event/mocks"
+	"github.com/agoda-com/macOS-vz-kubelet/pkg/resourcemanager"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"github.com/virtual-kubelet/virtual-kubelet/node/api"
+)
+
+const testAutomountTag = "com.apple.virtio-fs.automount"
+
+var testMounts = []volumes.Mount{
+	{Name: "data", HostPath: "/tmp/data", ContainerPath: "/mnt/data"},
+	{Name: "token", HostPath: "/tmp/token", ContainerPath: "/var/run/secrets/token", ReadOnly: true},
+}
+
+func TestShareVerificationCommand(t *testing.T) {
+	cmd := resourcemanager.ShareVerificationCommand(testMounts)
+
+	require.Len(t, cmd, 3)
+	assert.Equal(t, "sh", cmd[0])
+	assert.Equal(t, "-c", cmd[1])
+	assert.Equal(t,
+		`for d in "/Volumes/My Shared Files/data" "/Volumes/My Shared Files/token"; do ls "$d" > /dev/null 2>&1 || echo "$d"; done`,
+		cmd[2],
+	)
+}
+
+func TestShareVerificationCommandWithSameBaseName(t *testing.T) {
+	cmd := resourcemanager.ShareVerificationCommand([]volumes.Mount{
+		{Name: "data", HostPath: "/tmp/data", ContainerPath: "/mnt/data"},
+		{Name: "other", HostPath: "/tmp/other", ContainerPath: "/var/run/data", ReadOnly: true},
+	})
+
+	require.Len(t, cmd, 3)
+	assert.Equal(t,
+		`for d in "/Volumes/My Shared Files/data" "/Volumes/My Shared Files/data-2"; do ls "$d" > /dev/null 2>&1 || echo "$d"; done`,
+		cmd[2],
+	)
+}
+
+func TestShareRemountCommand(t *testing.T) {
+	cmd := resourcemanager.ShareRemountCommand(testAutomountTag)
+
+	require.Len(t, cmd, 3)
+	assert.Equal(t, []string{"sh", "-c"}, cmd[:2])
+	assert.Contains(t, cmd[2], `mount_virtiofs "com.apple.virtio-fs.automount" "/Volumes/My Shared Files"`)
+}
+
+func TestShareVerifierReconcile(t *testing.T) {
+	stalePath := "/Volumes/My Shared Files/data"
+	remountCmd := resourcemanager.ShareRemountCommand(testAutomountTag)
+
+	tests := []struct {
+		name string
+		// outputs of the consecutive verification commands
+		verifyOutputs []string
+		verifyErr     error
+		expectRemount bool
+		expectEvent   bool
+		expectError   bool
+	}{
+		{
+			name:          "All shares accessible",
+			verifyOutputs: []string{""},
+		},
+		{
+			name:          "Stale share recovered by remount",
+			verifyOutputs: []string{stalePath + "\n", ""},
+			expectRemount: true,
+		},
+		{
+			name:          "Stale share not recovered by remount",
+			verifyOutputs: []string{stalePath + "\n", stalePath + "\n"},
+			expectRemount: true,
+			expectEvent:   true,
+			expectError:   true,
+		},
+		{
+			name:        "Verification command failure",
+			verifyErr:   errors.New("ssh: connection refused"),
+			expectError: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			recorder := mocks.NewEventRecorder(t)
+			if tt.expectEvent {
+				recorder.On("FailedToMountSharedDirectories", mock.Anything, "macos", []string{stalePath}).Once()
+			}
+
+			verifications, remounts := 0, 0
+			verifier := &resourcemanager.ShareVerifier{
+				ContainerName: "macos",
+				Mounts:        testMounts,
+				AutomountTag:  testAutomountTag,
+				EventRecorder: recorder,
+				ExecFunc: func(_ context.Context, cmd []string, attach api.AttachIO) error {
+					if assert.ObjectsAreEqual(remountCmd, cmd) {
+						remounts++
+						return nil
+					}
+					if tt.verifyErr != nil {
+						return tt.verifyErr
+					}
+					require.Less(t, verifications, len(tt.verifyOutputs), "unexpected verification")
+					_, err := attach.Stdout().Write([]byte(tt.verifyOutputs[verifications]))
+					verifications++
+					return err
+				},
+			}
+
+			err := verifier.Reconcile(context.Background())
+			if tt.expectError {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+			}
+			assert.Equal(t, tt.expectRemount, remounts == 1)
+		})
+	}
+}
@@ -0,0 +1,82 @@
+package resourcemanager
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/agoda-com/macOS-vz-kubelet/pkg/vm"
+	"github.com/agoda-com/macOS-vz-kubelet/pkg/vm/config"
+
+	"github.com/virtual-kubelet/virtual-kubelet/errdefs"
+	"github.com/virtual-kubelet/virtual-kubelet/log"
+	"github.com/virtual-kubelet/virtual-kubelet/trace"
+)
+
+// SnapshotsDirName is the directory of the cache holding the snapshots virtual machines can be restored from.
+const SnapshotsDirName = "snapshots"
+
+// SnapshotPath returns the directory of the named snapshot, which pods select with an annotation.
+func (c *MacOSClient) SnapshotPath(snapshot string) string {
+	return filepath.Join(c.snapshotsPath, snapshot)
+}
+
+// SaveState saves the machine state and the storage of the running virtual machine into the directory at path,
+// e.g. SnapshotPath, so that virtual machines of the same image resume from it instead of booting.
+// The virtual machine is paused while it is saved and keeps running afterwards.
+func (c *MacOSClient) SaveState(ctx context.Context, namespace, name, path string) (err error) {
+	ctx, span := trace.StartSpan(ctx, "MacOSClient.SaveState")
+	ctx = span.WithFields(ctx, log.Fields{
+		"namespace": namespace,
+		"name":      name,
+		"path":      path,
+	})
+	defer func() {
+		span.SetStatus(err)
+		span.End()
+	}()
+
+	info, err := c.getVirtualMachineInfo(ctx, namespace, name)
+	if err != nil {
+		return err
+	}
+	instance := info.Resource.Instance()
+	if instance == nil {
+		return errdefs.InvalidInput("virtual machine is not created yet")
+	}
+
+	if err := os.MkdirAll(path, 0o755); err != nil {
+		return fmt.Errorf("failed to create snapshot directory: %w", err)
+	}
+	if err := instance.SaveState(ctx, path); err != nil {
+		return err
+	}
+
+	log.G(ctx).Infof("Saved virtual machine state to %s", path)
+	return nil
+}
+
+// snapshotState points the storage of the image at the snapshot of the virtual machine and returns the path
+// of its saved state, empty if the virtual machine has no snapshot or its snapshot is incomplete.
+func (c *MacOSClient) snapshotState(ctx context.Context, params VirtualMachineParams, cfg *config.MacPlatformConfigurationOptions) string {
+	if params.Snapshot == "" {
+		return ""
+	}
+
+	dir := c.SnapshotPath(params.Snapshot)
+	statePath := filepath.Join(dir, vm.SavedStateFileName)
+	blockStoragePath := filepath.Join(dir, vm.SnapshotBlockStorageFileName)
+	auxiliaryStoragePath := filepath.Join(dir, vm.SnapshotAuxiliaryStorageFileName)
+	for _, path := range []string{statePath, blockStoragePath, auxiliaryStoragePath} {
+		if _, err := os.Stat(path); err != nil {
+			// boot from the image instead
+			c.eventRecorder.FailedToRestoreState(ctx, params.ContainerName, params.Snapshot, err)
+			return ""
+		}
+	}
+
+	// the hardware model and machine identifier remain those of the image
+	cfg.BlockStoragePath, cfg.AuxiliaryStoragePath = blockStoragePath, auxiliaryStoragePath
+	return statePath
+}
@@ -0,0 +1,26 @@
+package resourcemanager_test
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+
+	"github.com/agoda-com/macOS-vz-kubelet/pkg/event"
+	"github.com/agoda-com/macOS-vz-kubelet/pkg/resourcemanager"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/virtual-kubelet/virtual-kubelet/errdefs"
+)
+
+func TestSaveState(t *testing.T) {
+	ctx := context.Background()
+	cachePath := t.TempDir()
+	c := resourcemanager.NewMacOSClient(ctx, event.LogEventRecorder{}, "", cachePath)
+
+	path := c.SnapshotPath("warm-xcode")
+	assert.Equal(t, filepath.Join(cachePath, resourcemanager.SnapshotsDirName, "warm-xcode"), path)
+
+	err := c.SaveState(ctx, "default", "unknown", path)
+	assert.True(t, errdefs.IsNotFound(err))
+	assert.NoDirExists(t, path)
+}
@@ -0,0 +1,87 @@
+package resourcemanager
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/Code-Hex/vz/v3"
+	"github.com/virtual-kubelet/virtual-kubelet/errdefs"
+	"k8s.io/apimachinery/pkg/util/wait"
+)
+
+const (
+	// DefaultStartAttempts is the default number of attempts to start a virtual machine.
+	DefaultStartAttempts = 3
+	// DefaultStartBackoff is the default delay before retrying a failed virtual machine start.
+	DefaultStartBackoff = 5 * time.Second
+
+	// startBackoffFactor is the factor the delay between start attempts grows by.
+	startBackoffFactor = 2.0
+
+	// vzErrorDomain is the NSError domain of the Virtualization framework errors.
+	vzErrorDomain = "VZErrorDomain"
+)
+
+// StartRetry retries virtual machine starts failing with transient errors.
+type StartRetry struct {
+	// Attempts is the maximum number of start attempts, DefaultStartAttempts if zero.
+	Attempts int
+	// Backoff is the delay before the first retry, doubled after every retry.
+	// DefaultStartBackoff if zero.
+	Backoff time.Duration
+}
+
+// Run calls start until it succeeds, fails with a fatal error or the attempts are exhausted,
+// and returns the error of the last attempt. onRetry is called with every error that is retried.
+func (r StartRetry) Run(ctx context.Context, start func(ctx context.Context) error, onRetry func(attempt int, err error)) error {
+	attempts := r.Attempts
+	if attempts <= 0 {
+		attempts = DefaultStartAttempts
+	}
+	backoff := r.Backoff
+	if backoff <= 0 {
+		backoff = DefaultStartBackoff
+	}
+
+	attempt := 0
+	return wait.ExponentialBackoffWithContext(ctx, wait.Backoff{
+		Duration: backoff,
+		Factor:   startBackoffFactor,
+		Steps:    attempts,
+	}, func(ctx context.Context) (bool, error) {
+		attempt++
+		err := start(ctx)
+		switch {
+		case err == nil:
+			return true, nil
+		case ctx.Err() != nil:
+			return false, ctx.Err()
+		case attempt >= attempts || !IsTransientStartError(err):
+			return false, err
+		}
+		onRetry(attempt, err)
+		return false, nil
+	})
+}
+
+// IsTransientStartError reports whether the virtual machine start failure may succeed when retried.
+// Invalid input and Virtualization framework errors caused by the configuration or the host
+// are fatal, anything else is considered transient.
+func IsTransientStartError(err error) bool {
+	if errdefs.IsInvalidInput(err) {
+		return false
+	}
+
+	var nsErr *vz.NSError
+	if errors.As(err, &nsErr) && nsErr.Domain == vzErrorDomain {
+		switch vz.ErrorCode(nsErr.Code) {
+		case vz.ErrorInvalidVirtualMachineConfiguration,
+			vz.ErrorInvalidDiskImage,
+			vz.ErrorOutOfDiskSpace,
+			vz.ErrorNotSupported:
+			return false
+		}
+	}
+	return true
+}
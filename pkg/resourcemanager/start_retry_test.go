@@ -0,0 +1,109 @@
+package resourcemanager_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/agoda-com/macOS-vz-kubelet/pkg/resourcemanager"
+
+	"github.com/Code-Hex/vz/v3"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/virtual-kubelet/virtual-kubelet/errdefs"
+)
+
+// flakyVirtualMachine fails to start until it has been started the given number of times.
+type flakyVirtualMachine struct {
+	failures int
+	err      error
+
+	starts int
+	state  vz.VirtualMachineState
+}
+
+func (m *flakyVirtualMachine) Start(context.Context) error {
+	m.starts++
+	if m.starts <= m.failures {
+		m.state = vz.VirtualMachineStateError
+		return m.err
+	}
+	m.state = vz.VirtualMachineStateRunning
+	return nil
+}
+
+func TestStartRetry(t *testing.T) {
+	vm := &flakyVirtualMachine{failures: 2, err: errors.New("internal virtualization error")}
+	var retried []int
+
+	retry := resourcemanager.StartRetry{Attempts: 3, Backoff: time.Millisecond}
+	err := retry.Run(context.Background(), vm.Start, func(attempt int, err error) {
+		assert.Equal(t, vm.err, err)
+		retried = append(retried, attempt)
+	})
+	require.NoError(t, err)
+
+	assert.Equal(t, 3, vm.starts)
+	assert.Equal(t, []int{1, 2}, retried)
+	assert.Equal(t, vz.VirtualMachineStateRunning, vm.state)
+}
+
+func TestStartRetryExhausted(t *testing.T) {
+	vm := &flakyVirtualMachine{failures: 3, err: errors.New("internal virtualization error")}
+	retries := 0
+
+	retry := resourcemanager.StartRetry{Attempts: 2, Backoff: time.Millisecond}
+	err := retry.Run(context.Background(), vm.Start, func(int, error) { retries++ })
+
+	assert.Equal(t, vm.err, err)
+	assert.Equal(t, 2, vm.starts)
+	assert.Equal(t, 1, retries)
+	assert.Equal(t, vz.VirtualMachineStateError, vm.state)
+}
+
+func TestStartRetryFatal(t *testing.T) {
+	vm := &flakyVirtualMachine{failures: 1, err: errdefs.InvalidInput("invalid configuration")}
+
+	retry := resourcemanager.StartRetry{Attempts: 3, Backoff: time.Millisecond}
+	err := retry.Run(context.Background(), vm.Start, func(int, error) {
+		t.Error("fatal errors must not be retried")
+	})
+
+	assert.Equal(t, vm.err, err)
+	assert.Equal(t, 1, vm.starts)
+}
+
+func TestStartRetryCanceled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	vm := &flakyVirtualMachine{failures: 3, err: errors.New("internal virtualization error")}
+
+	retry := resourcemanager.StartRetry{Attempts: 3, Backoff: time.Hour}
+	err := retry.Run(ctx, vm.Start, func(int, error) { cancel() })
+
+	assert.ErrorIs(t, err, context.Canceled)
+	assert.Equal(t, 1, vm.starts)
+}
+
+func TestIsTransientStartError(t *testing.T) {
+	tests := []struct {
+		name      string
+		err       error
+		transient bool
+	}{
+		{name: "generic", err: errors.New("failed to retrieve IP address"), transient: true},
+		{name: "internal", err: &vz.NSError{Domain: "VZErrorDomain", Code: int(vz.ErrorInternal)}, transient: true},
+		{name: "limit exceeded", err: &vz.NSError{Domain: "VZErrorDomain", Code: int(vz.ErrorVirtualMachineLimitExceeded)}, transient: true},
+		{name: "invalid configuration", err: &vz.NSError{Domain: "VZErrorDomain", Code: int(vz.ErrorInvalidVirtualMachineConfiguration)}},
+		{name: "wrapped invalid disk image", err: fmt.Errorf("start: %w", &vz.NSError{Domain: "VZErrorDomain", Code: int(vz.ErrorInvalidDiskImage)})},
+		{name: "not supported", err: &vz.NSError{Domain: "VZErrorDomain", Code: int(vz.ErrorNotSupported)}},
+		{name: "other domain", err: &vz.NSError{Domain: "NSPOSIXErrorDomain", Code: int(vz.ErrorNotSupported)}, transient: true},
+		{name: "invalid input", err: errdefs.InvalidInput("bad params")},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.transient, resourcemanager.IsTransientStartError(tt.err))
+		})
+	}
+}
@@ -0,0 +1,153 @@
+package resourcemanager
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	vzio "github.com/agoda-com/macOS-vz-kubelet/internal/io"
+	"github.com/agoda-com/macOS-vz-kubelet/internal/node"
+
+	"github.com/virtual-kubelet/virtual-kubelet/log"
+	stats "k8s.io/kubelet/pkg/apis/stats/v1alpha1"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// DefaultStatsTimeout is the default timeout for collecting the stats inside a virtual machine.
+const DefaultStatsTimeout = 5 * time.Second
+
+// vmStatsCommand is the combined script for collecting all required stats inside the guest.
+var vmStatsCommand = []string{
+	`cpuUsageNanoCores=$(top -l 1 | awk '/CPU usage/ {print ($3+$5)*10000000}' | sed 's/%//g')`,
+	`cpuUsageNanoCores=$(printf "%.0f" "$cpuUsageNanoCores")`,
+
+	`cpuUsageCoreNanoSeconds=$(echo "$(sysctl -n hw.ncpu) * $(( $(date +%s) - $(sysctl -n kern.boottime | awk -F'[ ,]' '{print $4}') )) * 1000000000" | bc -l)`,
+	`cpuUsageCoreNanoSeconds=$(printf "%.0f" "$cpuUsageCoreNanoSeconds")`,
+
+	`memoryUsageBytes=$(vm_stat | awk '/Pages active/ {active=$3} /Pages wired down/ {wired=$4} END {print (active+wired)*4096}')`,
+	`memoryRssBytes=$(vm_stat | awk '/Pages active/ {print $3*4096}')`,
+	`memoryWorkingSetBytes=$(vm_stat | awk '/Pages active/ {active=$3} /Pages speculative/ {speculative=$4} END {print (active-speculative)*4096}')`,
+
+	`echo "{\"cpuUsageNanoCores\": $cpuUsageNanoCores, \"cpuUsageCoreNanoSeconds\": $cpuUsageCoreNanoSeconds, \"memoryUsageBytes\": $memoryUsageBytes, \"memoryRssBytes\": $memoryRssBytes, \"memoryWorkingSetBytes\": $memoryWorkingSetBytes}"`,
+}
+
+// CollectVirtualMachineStats collects the stats of a virtual machine by executing the stats script inside the guest.
+// The collection is abandoned after the timeout, DefaultStatsTimeout if zero, returning empty stats rather than
+// blocking the caller on a wedged guest. The exec is canceled through its context on timeout.
+func CollectVirtualMachineStats(ctx context.Context, exec ExecFunc, timeout time.Duration) (stats.ContainerStats, error) {
+	if timeout <= 0 {
+		timeout = DefaultStatsTimeout
+	}
+	execCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	// Capture command output, keeping stdout apart from anything the guest writes to stderr
+	stdout, stderr := &bytes.Buffer{}, &bytes.Buffer{}
+	attach := node.NewExecIO(false, nil, vzio.NewBufferWriteCloser(stdout), vzio.NewBufferWriteCloser(stderr), nil)
+
+	// Execute the script in the VM, without waiting for an exec that does not honor the cancellation
+	done := make(chan error, 1)
+	go func() {
+		done <- exec(execCtx, vmStatsCommand, attach)
+	}()
+
+	var err error
+	select {
+	case err = <-done:
+	case <-execCtx.Done():
+		err = execCtx.Err()
+	}
+	if err != nil {
+		if ctx.Err() == nil && errors.Is(execCtx.Err(), context.DeadlineExceeded) {
+			log.G(ctx).WithError(err).Warnf("Timed out collecting virtual machine stats after %s", timeout)
+			return stats.ContainerStats{}, nil
+		}
+		return stats.ContainerStats{}, fmt.Errorf("error executing script: %w", err)
+	}
+
+	if stderr.Len() > 0 {
+		log.G(ctx).Debugf("Stats script wrote to stderr: %s", stderr.String())
+	}
+
+	// Parse JSON output
+	statsData, err := parseStatsJSON(stdout.Bytes())
+	if err != nil {
+		return stats.ContainerStats{}, fmt.Errorf("error parsing JSON output: %w", err)
+	}
+
+	// Prepare stats.ContainerStats
+	time := metav1.NewTime(time.Now())
+	return stats.ContainerStats{
+		CPU: &stats.CPUStats{
+			Time:                 time,
+			UsageNanoCores:       statsData.CPUUsageNanoCores,
+			UsageCoreNanoSeconds: statsData.CPUUsageCoreNanoSeconds,
+		},
+		Memory: &stats.MemoryStats{
+			Time:            time,
+			UsageBytes:      statsData.MemoryUsageBytes,
+			WorkingSetBytes: statsData.MemoryWorkingSetBytes,
+			RSSBytes:        statsData.MemoryRSSBytes,
+		},
+	}, nil
+}
+
+type vmStatsData struct {
+	CPUUsageNanoCores       json.Number `json:"cpuUsageNanoCores"`
+	CPUUsageCoreNanoSeconds json.Number `json:"cpuUsageCoreNanoSeconds"`
+	MemoryUsageBytes        json.Number `json:"memoryUsageBytes"`
+	MemoryRSSBytes          json.Number `json:"memoryRssBytes"`
+	MemoryWorkingSetBytes   json.Number `json:"memoryWorkingSetBytes"`
+}
+
+type parsedVMStatsData struct {
+	CPUUsageNanoCores       *uint64 `json:"cpuUsageNanoCores"`
+	CPUUsageCoreNanoSeconds *uint64 `json:"cpuUsageCoreNanoSeconds"`
+	MemoryUsageBytes        *uint64 `json:"memoryUsageBytes"`
+	MemoryRSSBytes          *uint64 `json:"memoryRssBytes"`
+	MemoryWorkingSetBytes   *uint64 `json:"memoryWorkingSetBytes"`
+}
+
+func parseStatsJSON(data []byte) (*parsedVMStatsData, error) {
+	// Unmarshal into intermediate structure
+	var statsData vmStatsData
+	if err := json.Unmarshal(data, &statsData); err != nil {
+		return nil, err
+	}
+
+	// Conversion function for json.Number to *uint64
+	convert := func(num json.Number) (*uint64, error) {
+		val, err := num.Int64()
+		if err != nil {
+			return nil, err
+		}
+		uval := uint64(val)
+		return &uval, nil
+	}
+
+	// Populate the final ParsedVMStatsData struct
+	parsedData := &parsedVMStatsData{}
+	var err error
+
+	if parsedData.CPUUsageNanoCores, err = convert(statsData.CPUUsageNanoCores); err != nil {
+		return nil, fmt.Errorf("cpuUsageNanoCores: %w", err)
+	}
+	if parsedData.CPUUsageCoreNanoSeconds, err = convert(statsData.CPUUsageCoreNanoSeconds); err != nil {
+		return nil, fmt.Errorf("cpuUsageCoreNanoSeconds: %w", err)
+	}
+	if parsedData.MemoryUsageBytes, err = convert(statsData.MemoryUsageBytes); err != nil {
+		return nil, fmt.Errorf("memoryUsageBytes: %w", err)
+	}
+	if parsedData.MemoryRSSBytes, err = convert(statsData.MemoryRSSBytes); err != nil {
+		return nil, fmt.Errorf("memoryRssBytes: %w", err)
+	}
+	if parsedData.MemoryWorkingSetBytes, err = convert(statsData.MemoryWorkingSetBytes); err != nil {
+		return nil, fmt.Errorf("memoryWorkingSetBytes: %w", err)
+	}
+
+	return parsedData, nil
+}
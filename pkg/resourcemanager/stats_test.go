@@ -0,0 +1,118 @@
+package resourcemanager_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/agoda-com/macOS-vz-kubelet/pkg/resourcemanager"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/virtual-kubelet/virtual-kubelet/node/api"
+	stats "k8s.io/kubelet/pkg/apis/stats/v1alpha1"
+)
+
+func TestCollectVirtualMachineStats(t *testing.T) {
+	exec := func(_ context.Context, _ []string, attach api.AttachIO) error {
+		_, err := fmt.Fprint(attach.Stdout(), `{"cpuUsageNanoCores": 1500, "cpuUsageCoreNanoSeconds": 3000000000, "memoryUsageBytes": 4096, "memoryRssBytes": 2048, "memoryWorkingSetBytes": 1024}`)
+		return err
+	}
+
+	cs, err := resourcemanager.CollectVirtualMachineStats(context.Background(), exec, time.Second)
+	require.NoError(t, err)
+	require.NotNil(t, cs.CPU)
+	require.NotNil(t, cs.Memory)
+	assert.Equal(t, uint64(1500), *cs.CPU.UsageNanoCores)
+	assert.Equal(t, uint64(3000000000), *cs.CPU.UsageCoreNanoSeconds)
+	assert.Equal(t, uint64(4096), *cs.Memory.UsageBytes)
+	assert.Equal(t, uint64(2048), *cs.Memory.RSSBytes)
+	assert.Equal(t, uint64(1024), *cs.Memory.WorkingSetBytes)
+}
+
+func TestCollectVirtualMachineStatsStderrNoise(t *testing.T) {
+	exec := func(_ context.Context, _ []string, attach api.AttachIO) error {
+		if _, err := fmt.Fprint(attach.Stderr(), "warning: vm_stat is deprecated\n"); err != nil {
+			return err
+		}
+		if _, err := fmt.Fprint(attach.Stdout(), `{"cpuUsageNanoCores": 1500, "cpuUsageCoreNanoSeconds": 3000000000,`); err != nil {
+			return err
+		}
+		if _, err := fmt.Fprint(attach.Stderr(), "top: unknown option\n"); err != nil {
+			return err
+		}
+		_, err := fmt.Fprint(attach.Stdout(), ` "memoryUsageBytes": 4096, "memoryRssBytes": 2048, "memoryWorkingSetBytes": 1024}`)
+		return err
+	}
+
+	cs, err := resourcemanager.CollectVirtualMachineStats(context.Background(), exec, time.Second)
+	require.NoError(t, err)
+	require.NotNil(t, cs.CPU)
+	require.NotNil(t, cs.Memory)
+	assert.Equal(t, uint64(1500), *cs.CPU.UsageNanoCores)
+	assert.Equal(t, uint64(4096), *cs.Memory.UsageBytes)
+	assert.Equal(t, uint64(1024), *cs.Memory.WorkingSetBytes)
+}
+
+func TestCollectVirtualMachineStatsTimeout(t *testing.T) {
+	// a wedged guest: the exec only returns once it is canceled
+	returned := make(chan struct{})
+	exec := func(ctx context.Context, _ []string, _ api.AttachIO) error {
+		defer close(returned)
+		<-ctx.Done()
+		return ctx.Err()
+	}
+
+	start := time.Now()
+	cs, err := resourcemanager.CollectVirtualMachineStats(context.Background(), exec, 50*time.Millisecond)
+	require.NoError(t, err)
+	assert.Less(t, time.Since(start), time.Second)
+	assert.Equal(t, stats.ContainerStats{}, cs)
+
+	// the exec is canceled rather than left running
+	select {
+	case <-returned:
+	case <-time.After(time.Second):
+		t.Fatal("exec was not canceled")
+	}
+}
+
+func TestCollectVirtualMachineStatsUnresponsiveExec(t *testing.T) {
+	// the exec does not honor the cancellation, the caller must not wait for it
+	release := make(chan struct{})
+	defer close(release)
+	exec := func(context.Context, []string, api.AttachIO) error {
+		<-release
+		return nil
+	}
+
+	start := time.Now()
+	cs, err := resourcemanager.CollectVirtualMachineStats(context.Background(), exec, 50*time.Millisecond)
+	require.NoError(t, err)
+	assert.Less(t, time.Since(start), time.Second)
+	assert.Equal(t, stats.ContainerStats{}, cs)
+}
+
+func TestCollectVirtualMachineStatsCanceled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	exec := func(ctx context.Context, _ []string, _ api.AttachIO) error {
+		cancel()
+		<-ctx.Done()
+		return ctx.Err()
+	}
+
+	_, err := resourcemanager.CollectVirtualMachineStats(ctx, exec, time.Minute)
+	assert.ErrorIs(t, err, context.Canceled)
+}
+
+func TestCollectVirtualMachineStatsError(t *testing.T) {
+	execErr := errors.New("virtual machine does not have an IP address")
+	exec := func(context.Context, []string, api.AttachIO) error {
+		return execErr
+	}
+
+	_, err := resourcemanager.CollectVirtualMachineStats(context.Background(), exec, time.Second)
+	assert.ErrorIs(t, err, execErr)
+}
@@ -0,0 +1,50 @@
+package resourcemanager
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"k8s.io/apimachinery/pkg/util/wait"
+)
+
+const (
+	// DefaultStopConfirmationTimeout is how long the deletion of a virtual machine waits for it to be confirmed stopped.
+	DefaultStopConfirmationTimeout = 10 * time.Second
+
+	// StopConfirmationInterval is the interval between the checks of a stopped virtual machine.
+	StopConfirmationInterval = 100 * time.Millisecond
+)
+
+// StopConfirmation waits for a stopped virtual machine to be released. Virtualization.framework may report the
+// virtual machine stopped a little after the stop returns and its overlays may still be being removed, so the
+// slot of the virtual machine is only released once confirmed, keeping the capacity accounting accurate.
+type StopConfirmation struct {
+	// Timeout is how long the virtual machine may take to be released, zero disables the confirmation.
+	Timeout time.Duration
+	// Interval is the interval between the checks, StopConfirmationInterval if zero.
+	Interval time.Duration
+
+	// Released reports whether the virtual machine has stopped and its storage is released.
+	Released func() bool
+}
+
+// Wait polls until the virtual machine is released, returning an error if it is not within the timeout.
+func (s *StopConfirmation) Wait(ctx context.Context) error {
+	if s.Timeout <= 0 {
+		return nil
+	}
+
+	interval := s.Interval
+	if interval <= 0 {
+		interval = StopConfirmationInterval
+	}
+
+	err := wait.PollUntilContextTimeout(ctx, interval, s.Timeout, true, func(context.Context) (bool, error) {
+		return s.Released(), nil
+	})
+	if err != nil && ctx.Err() == nil {
+		return fmt.Errorf("virtual machine was not released within %s: %w", s.Timeout, err)
+	}
+	return err
+}
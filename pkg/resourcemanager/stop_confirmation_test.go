@@ -0,0 +1,53 @@
+package resourcemanager_test
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/agoda-com/macOS-vz-kubelet/pkg/resourcemanager"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStopConfirmationWaitsForRelease(t *testing.T) {
+	var checks atomic.Int32
+	confirmation := &resourcemanager.StopConfirmation{
+		Timeout:  time.Second,
+		Interval: 10 * time.Millisecond,
+		Released: func() bool {
+			// the virtual machine lingers for a few checks after the stop returned
+			return checks.Add(1) >= 3
+		},
+	}
+
+	require.NoError(t, confirmation.Wait(context.Background()))
+	assert.Equal(t, int32(3), checks.Load())
+}
+
+func TestStopConfirmationTimesOut(t *testing.T) {
+	confirmation := &resourcemanager.StopConfirmation{
+		Timeout:  50 * time.Millisecond,
+		Interval: 10 * time.Millisecond,
+		Released: func() bool { return false },
+	}
+
+	start := time.Now()
+	err := confirmation.Wait(context.Background())
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "virtual machine was not released within 50ms")
+	assert.GreaterOrEqual(t, time.Since(start), 50*time.Millisecond)
+	assert.Less(t, time.Since(start), 5*time.Second)
+}
+
+func TestStopConfirmationDisabled(t *testing.T) {
+	confirmation := &resourcemanager.StopConfirmation{
+		Released: func() bool {
+			t.Fatal("the release is not checked without a timeout")
+			return false
+		},
+	}
+	assert.NoError(t, confirmation.Wait(context.Background()))
+}
@@ -0,0 +1,62 @@
+package resourcemanager_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/agoda-com/macOS-vz-kubelet/pkg/event"
+	"github.com/agoda-com/macOS-vz-kubelet/pkg/resourcemanager"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+)
+
+// failedContainerRecorder signals the errors the containers failed to be created with.
+type failedContainerRecorder struct {
+	event.LogEventRecorder
+	failed chan error
+}
+
+func (r failedContainerRecorder) FailedToCreateContainer(_ context.Context, _ string, err error) {
+	r.failed <- err
+}
+
+func TestCreateVirtualMachineReportsInvalidConfiguration(t *testing.T) {
+	ctx := context.Background()
+
+	registry := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-r.Context().Done()
+	}))
+	t.Cleanup(registry.Close)
+
+	cachePath := t.TempDir()
+	image := strings.TrimPrefix(registry.URL, "http://") + "/macos:latest"
+	writeCachedImage(t, cachePath, image)
+
+	recorder := failedContainerRecorder{failed: make(chan error, 1)}
+	c := resourcemanager.NewMacOSClient(ctx, recorder, "", cachePath)
+	params := resourcemanager.VirtualMachineParams{
+		UID:             "uid",
+		Image:           image,
+		Namespace:       "default",
+		Name:            "invalid",
+		ContainerName:   "macos",
+		CPU:             1024,
+		MemorySize:      4 << 30,
+		ImagePullPolicy: corev1.PullIfNotPresent,
+	}
+	require.NoError(t, c.CreateVirtualMachine(ctx, params))
+	t.Cleanup(func() { _ = c.DeleteVirtualMachine(ctx, params.Namespace, params.Name, 0) })
+
+	select {
+	case err := <-recorder.failed:
+		assert.True(t, strings.HasPrefix(err.Error(), "invalid virtual machine configuration: CPU count 1024 is out of the supported range"), err.Error())
+	case <-time.After(5 * time.Second):
+		t.Fatal("the invalid configuration was not reported")
+	}
+}
@@ -12,6 +12,52 @@ import (
 	"github.com/agoda-com/macOS-vz-kubelet/internal/utils"
 )
 
+// DiskMode selects how the storage of the image is made writable for a virtual machine.
+type DiskMode string
+
+const (
+	// DiskModeOverlay clones the storage copy-on-write, the clone is discarded when the virtual machine stops.
+	DiskModeOverlay DiskMode = "overlay"
+	// DiskModeCopy copies the storage in full, the copy is kept until the pod is deleted.
+	DiskModeCopy DiskMode = "copy"
+)
+
+// ParseDiskMode parses the disk mode, empty selects DiskModeOverlay.
+func ParseDiskMode(mode string) (DiskMode, error) {
+	switch DiskMode(mode) {
+	case "", DiskModeOverlay:
+		return DiskModeOverlay, nil
+	case DiskModeCopy:
+		return DiskModeCopy, nil
+	default:
+		return "", fmt.Errorf("unknown disk mode %q, expected %s or %s", mode, DiskModeOverlay, DiskModeCopy)
+	}
+}
+
+// CPUQoS selects the quality of service class the virtual machines are started with, a hint to the host scheduler
+// on which cores of Apple silicon their threads run.
+type CPUQoS string
+
+const (
+	// CPUQoSDefault leaves the scheduling of the virtual machines to the host.
+	CPUQoSDefault CPUQoS = "default"
+	// CPUQoSPerformance starts the virtual machines with the user-interactive QoS class, which the host
+	// schedules on the performance cores in preference to the efficiency cores.
+	CPUQoSPerformance CPUQoS = "performance"
+)
+
+// ParseCPUQoS parses the CPU QoS, empty selects CPUQoSDefault.
+func ParseCPUQoS(qos string) (CPUQoS, error) {
+	switch CPUQoS(qos) {
+	case "", CPUQoSDefault:
+		return CPUQoSDefault, nil
+	case CPUQoSPerformance:
+		return CPUQoSPerformance, nil
+	default:
+		return "", fmt.Errorf("unknown CPU QoS %q, expected %s or %s", qos, CPUQoSDefault, CPUQoSPerformance)
+	}
+}
+
 // MacPlatformConfigurationOptions holds the options for creating a new PlatformConfiguration.
 type MacPlatformConfigurationOptions struct {
 	BlockStoragePath      string
@@ -25,12 +71,14 @@ type PlatformConfiguration struct {
 	BlockStoragePath     string
 	AuxiliaryStoragePath string
 	IsOverlay            bool
+	// IsCopy reports whether the storage paths are full copies kept until the pod is deleted.
+	IsCopy bool
 
 	*vz.MacPlatformConfiguration
 }
 
 // NewPlatformConfiguration creates a new PlatformConfiguration.
-func NewPlatformConfiguration(ctx context.Context, opts MacPlatformConfigurationOptions, useOverlay bool, uid string) (p *PlatformConfiguration, err error) {
+func NewPlatformConfiguration(ctx context.Context, opts MacPlatformConfigurationOptions, mode DiskMode, uid string) (p *PlatformConfiguration, err error) {
 	ctx, span := trace.StartSpan(ctx, "platform.NewPlatformConfiguration")
 	defer func() {
 		span.SetStatus(err)
@@ -39,21 +87,26 @@ func NewPlatformConfiguration(ctx context.Context, opts MacPlatformConfiguration
 
 	blockStoragePath := opts.BlockStoragePath
 	auxiliaryStoragePath := opts.AuxiliaryStoragePath
-	if useOverlay {
-		fc := utils.NewFileCloner()
-
-		blockStoragePath, err = fc.Clonefile(blockStoragePath, uid)
-		if err != nil {
-			return nil, err
-		}
-		ctx = span.WithField(ctx, "blockStoragePath", blockStoragePath)
-
-		auxiliaryStoragePath, err = fc.Clonefile(auxiliaryStoragePath, uid)
-		if err != nil {
-			return nil, err
-		}
-		ctx = span.WithField(ctx, "auxiliaryStoragePath", auxiliaryStoragePath)
+	if mode == "" {
+		mode = DiskModeOverlay
+	}
+	fc := utils.NewFileCloner()
+	clone := fc.Clonefile
+	if mode == DiskModeCopy {
+		clone = fc.Copyfile
+	}
+
+	blockStoragePath, err = clone(blockStoragePath, uid)
+	if err != nil {
+		return nil, err
+	}
+	ctx = span.WithField(ctx, "blockStoragePath", blockStoragePath)
+
+	auxiliaryStoragePath, err = clone(auxiliaryStoragePath, uid)
+	if err != nil {
+		return nil, err
 	}
+	ctx = span.WithField(ctx, "auxiliaryStoragePath", auxiliaryStoragePath)
 
 	_ = span.WithFields(ctx, log.Fields{
 		"blockStoragePath":     blockStoragePath,
@@ -97,7 +150,8 @@ func NewPlatformConfiguration(ctx context.Context, opts MacPlatformConfiguration
 	return &PlatformConfiguration{
 		BlockStoragePath:         blockStoragePath,
 		AuxiliaryStoragePath:     auxiliaryStoragePath,
-		IsOverlay:                useOverlay,
+		IsOverlay:                mode == DiskModeOverlay,
+		IsCopy:                   mode == DiskModeCopy,
 		MacPlatformConfiguration: c,
 	}, nil
 }
@@ -0,0 +1,51 @@
+package config
+
+import (
+	"fmt"
+	"os"
+)
+
+// ScratchDiskSuffix is appended to the path of the boot disk of a virtual machine to name its scratch disk.
+const ScratchDiskSuffix = ".scratch"
+
+// BlockDevice is a disk image attached to the virtual machine as a block device.
+type BlockDevice struct {
+	Path     string
+	ReadOnly bool
+}
+
+// BlockDevices returns the block devices of the virtual machine in the order they are attached: the boot disk,
+// followed by the scratch disk if the path of one is set. The boot disk is attached read-only along with
+// a scratch disk, so that the guest writes to the scratch disk only.
+func BlockDevices(bootDiskPath, scratchDiskPath string) []BlockDevice {
+	if scratchDiskPath == "" {
+		return []BlockDevice{{Path: bootDiskPath}}
+	}
+	return []BlockDevice{
+		{Path: bootDiskPath, ReadOnly: true},
+		{Path: scratchDiskPath},
+	}
+}
+
+// CreateScratchDisk creates a blank disk image of the size at the path, replacing any existing one.
+// The image is sparse, so that it only takes the storage the guest writes to it.
+func CreateScratchDisk(path string, size uint64) (err error) {
+	_ = os.Remove(path)
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0o600)
+	if err != nil {
+		return fmt.Errorf("failed to create scratch disk: %w", err)
+	}
+	defer func() {
+		if closeErr := f.Close(); err == nil {
+			err = closeErr
+		}
+		if err != nil {
+			_ = os.Remove(path)
+		}
+	}()
+
+	if err = f.Truncate(int64(size)); err != nil {
+		return fmt.Errorf("failed to size scratch disk: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,54 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/Code-Hex/vz/v3"
+)
+
+// ValidationError is returned when the configuration of a virtual machine is rejected,
+// with a reason describing what to change in the pod, e.g. the requested CPU count being out of range.
+type ValidationError struct {
+	Reason string
+	Err    error
+}
+
+// Error implements the error interface.
+func (e *ValidationError) Error() string {
+	return "invalid virtual machine configuration: " + e.Reason
+}
+
+// Unwrap returns the error of Virtualization.framework the validation failed with, if any.
+func (e *ValidationError) Unwrap() error {
+	return e.Err
+}
+
+// InvalidInput marks the validation errors as invalid input, since retrying does not resolve them.
+func (e *ValidationError) InvalidInput() bool {
+	return true
+}
+
+// ValidateResources validates the CPU count and the memory size in bytes of a virtual machine
+// against the range Virtualization.framework supports on the host.
+func ValidateResources(cpuCount uint, memorySize uint64) error {
+	minCPU, maxCPU := vz.VirtualMachineConfigurationMinimumAllowedCPUCount(), vz.VirtualMachineConfigurationMaximumAllowedCPUCount()
+	if cpuCount < minCPU || cpuCount > maxCPU {
+		return &ValidationError{Reason: fmt.Sprintf("CPU count %d is out of the supported range %d-%d", cpuCount, minCPU, maxCPU)}
+	}
+	minMemory, maxMemory := vz.VirtualMachineConfigurationMinimumAllowedMemorySize(), vz.VirtualMachineConfigurationMaximumAllowedMemorySize()
+	if memorySize < minMemory || memorySize > maxMemory {
+		return &ValidationError{Reason: fmt.Sprintf("memory of %d MiB is out of the supported range %d-%d MiB", memorySize>>20, minMemory>>20, maxMemory>>20)}
+	}
+	return nil
+}
+
+// newValidationError creates the validation error of a configuration rejected by Virtualization.framework,
+// with the description of the framework error as the reason.
+func newValidationError(err error) *ValidationError {
+	var nsErr *vz.NSError
+	if errors.As(err, &nsErr) && nsErr.LocalizedDescription != "" {
+		return &ValidationError{Reason: nsErr.LocalizedDescription, Err: err}
+	}
+	return &ValidationError{Reason: err.Error(), Err: err}
+}
@@ -4,12 +4,14 @@ import (
 	"context"
 	"fmt"
 	"net"
+	"os"
 	"path/filepath"
 
 	"github.com/agoda-com/macOS-vz-kubelet/internal/netutil"
 	"github.com/agoda-com/macOS-vz-kubelet/internal/volumes"
 
 	"github.com/Code-Hex/vz/v3"
+	"github.com/virtual-kubelet/virtual-kubelet/log"
 	"github.com/virtual-kubelet/virtual-kubelet/trace"
 )
 
@@ -20,15 +22,67 @@ const MacOSSharedDirectoryPath = "/Volumes/My Shared Files"
 type VirtualMachineConfiguration struct {
 	MACAddress       net.HardwareAddr
 	NetworkInterface string
+	// CPUQoS is the quality of service class the virtual machine is started with.
+	CPUQoS CPUQoS
 
 	overlayBlockStoragePath     string
 	overlayAuxiliaryStoragePath string
+	copyBlockStoragePath        string
+	copyAuxiliaryStoragePath    string
+	scratchDiskPath             string
 
 	*vz.VirtualMachineConfiguration
 }
 
+// DeviceOptions selects the optional devices of the virtual machine.
+// The zero value attaches every device, headless virtual machines may skip the ones they do not need.
+type DeviceOptions struct {
+	// DisableAudio skips the audio device.
+	DisableAudio bool
+	// DisableInput skips the keyboard and pointing devices.
+	DisableInput bool
+	// EnableMemoryBalloon attaches the memory balloon device. The memory of the running guest
+	// is not resized through it.
+	EnableMemoryBalloon bool
+	// ScratchDiskSize is the size in bytes of a blank scratch disk attached along with the boot disk, which is then
+	// attached read-only. Zero attaches the boot disk writable and no scratch disk.
+	ScratchDiskSize uint64
+}
+
+// NetworkOptions selects the network the virtual machines are attached to.
+type NetworkOptions struct {
+	// BridgeInterface is the identifier of the host network interface the virtual machines are bridged to, NAT is used if empty.
+	BridgeInterface string
+	// BridgeFallbackToNAT attaches the virtual machines to NAT if the bridge interface is missing, instead of failing them.
+	BridgeFallbackToNAT bool
+}
+
+// FindBridgedNetwork returns the network of the bridge interface among the networks of the host, and false if
+// the virtual machines use NAT instead, either because no bridge interface is set or because it is missing and
+// the options fall back to NAT.
+func FindBridgedNetwork[N interface{ Identifier() string }](ctx context.Context, networks []N, opts NetworkOptions) (network N, bridged bool, err error) {
+	if opts.BridgeInterface == "" {
+		return network, false, nil
+	}
+	for _, n := range networks {
+		if n.Identifier() == opts.BridgeInterface {
+			return n, true, nil
+		}
+	}
+	if !opts.BridgeFallbackToNAT {
+		return network, false, fmt.Errorf("network interface %s not found", opts.BridgeInterface)
+	}
+	log.G(ctx).Warnf("Network interface %s not found, falling back to NAT", opts.BridgeInterface)
+	return network, false, nil
+}
+
+// MemoryBalloonDeviceConfigurer is the part of the virtual machine configuration the memory balloon device is attached to.
+type MemoryBalloonDeviceConfigurer interface {
+	SetMemoryBalloonDevicesVirtualMachineConfiguration([]vz.MemoryBalloonDeviceConfiguration)
+}
+
 // NewVirtualMachineConfiguration initializes a new virtual machine configuration with provided settings.
-func NewVirtualMachineConfiguration(ctx context.Context, platformConfig *PlatformConfiguration, cpuCount uint, memorySize uint64, networkInterfaceIdentifier string, mounts []volumes.Mount) (p *VirtualMachineConfiguration, err error) {
+func NewVirtualMachineConfiguration(ctx context.Context, platformConfig *PlatformConfiguration, cpuCount uint, memorySize uint64, network NetworkOptions, mounts []volumes.Mount, devices DeviceOptions) (p *VirtualMachineConfiguration, err error) {
 	ctx, span := trace.StartSpan(ctx, "vm.NewVirtualMachineConfiguration")
 	defer func() {
 		span.SetStatus(err)
@@ -57,8 +111,32 @@ func NewVirtualMachineConfiguration(ctx context.Context, platformConfig *Platfor
 		return nil, fmt.Errorf("failed to parse mac address: %w", err)
 	}
 
+	// Find the network interface to bridge the VM to, nil for NAT
+	bridge, bridged, err := FindBridgedNetwork(ctx, vz.NetworkInterfaces(), network)
+	if err != nil {
+		return nil, err
+	}
+	var networkInterfaceIdentifier string
+	if bridged {
+		networkInterfaceIdentifier = bridge.Identifier()
+	}
+
+	// Create the scratch disk the guest writes to instead of its read-only boot disk
+	var scratchDiskPath string
+	if devices.ScratchDiskSize > 0 {
+		scratchDiskPath = platformConfig.BlockStoragePath + ScratchDiskSuffix
+		if err = CreateScratchDisk(scratchDiskPath, devices.ScratchDiskSize); err != nil {
+			return nil, err
+		}
+		defer func() {
+			if err != nil {
+				_ = os.Remove(scratchDiskPath)
+			}
+		}()
+	}
+
 	// Attach device configurations
-	if err = attachDeviceConfigurations(ctx, config, platformConfig, networkInterfaceIdentifier, macAddr); err != nil {
+	if err = attachDeviceConfigurations(ctx, config, platformConfig, scratchDiskPath, bridge, macAddr, devices); err != nil {
 		return nil, fmt.Errorf("failed to attach device configurations: %w", err)
 	}
 
@@ -70,10 +148,10 @@ func NewVirtualMachineConfiguration(ctx context.Context, platformConfig *Platfor
 	// Validate the configuration
 	validated, err := config.Validate()
 	if err != nil {
-		return nil, fmt.Errorf("failed to validate configuration: %w", err)
+		return nil, newValidationError(err)
 	}
 	if !validated {
-		return nil, fmt.Errorf("invalid configuration")
+		return nil, &ValidationError{Reason: "rejected by Virtualization.framework"}
 	}
 
 	p = &VirtualMachineConfiguration{
@@ -83,21 +161,85 @@ func NewVirtualMachineConfiguration(ctx context.Context, platformConfig *Platfor
 		VirtualMachineConfiguration: config,
 	}
 
-	if platformConfig.IsOverlay {
-		p.overlayBlockStoragePath = platformConfig.BlockStoragePath
-		p.overlayAuxiliaryStoragePath = platformConfig.AuxiliaryStoragePath
-	}
+	p.SetStorage(platformConfig)
+	p.SetScratchDisk(scratchDiskPath)
 
 	return p, nil
 }
 
+// SetStorage records the storage paths of the platform configuration, so that they are removed
+// according to the disk mode they were created with.
+func (c *VirtualMachineConfiguration) SetStorage(platformConfig *PlatformConfiguration) {
+	switch {
+	case platformConfig.IsOverlay:
+		c.overlayBlockStoragePath = platformConfig.BlockStoragePath
+		c.overlayAuxiliaryStoragePath = platformConfig.AuxiliaryStoragePath
+	case platformConfig.IsCopy:
+		c.copyBlockStoragePath = platformConfig.BlockStoragePath
+		c.copyAuxiliaryStoragePath = platformConfig.AuxiliaryStoragePath
+	}
+}
+
+// SetScratchDisk records the path of the scratch disk, so that it is removed when the virtual machine stops.
+func (c *VirtualMachineConfiguration) SetScratchDisk(scratchDiskPath string) {
+	c.scratchDiskPath = scratchDiskPath
+}
+
+// SharedDirectoryNames returns the names under which the mounts are shared with the guest, in the order of the mounts.
+// Mounts are shared under the base name of their container path, a mount whose base name is already taken
+// by a previous mount gets a numeric suffix instead, e.g. data-2, so that no share clobbers another.
+func SharedDirectoryNames(mounts []volumes.Mount) []string {
+	names := make([]string, len(mounts))
+	taken := make(map[string]bool, len(mounts))
+	for i, m := range mounts {
+		base := filepath.Base(m.ContainerPath)
+		name := base
+		for n := 2; taken[name]; n++ {
+			name = fmt.Sprintf("%s-%d", base, n)
+		}
+		taken[name] = true
+		names[i] = name
+	}
+	return names
+}
+
+// SharedMounts returns the mounts keyed by the name under which they are shared with the guest.
+func SharedMounts(mounts []volumes.Mount) map[string]volumes.Mount {
+	shared := make(map[string]volumes.Mount, len(mounts))
+	for i, name := range SharedDirectoryNames(mounts) {
+		shared[name] = mounts[i]
+	}
+	return shared
+}
+
+// GuestSharedDirectoryPaths returns the locations of the shared mounts inside the macOS guest, in the order of the mounts.
+func GuestSharedDirectoryPaths(mounts []volumes.Mount) []string {
+	paths := SharedDirectoryNames(mounts)
+	for i, name := range paths {
+		paths[i] = filepath.Join(MacOSSharedDirectoryPath, name)
+	}
+	return paths
+}
+
 // GetOverlays returns the overlay paths if they are in use; otherwise, returns an empty string.
 func (c *VirtualMachineConfiguration) GetOverlays() (overlayBlockStoragePath string, overlayAuxiliaryStoragePath string, ok bool) {
 	return c.overlayBlockStoragePath, c.overlayAuxiliaryStoragePath, c.overlayBlockStoragePath != "" && c.overlayAuxiliaryStoragePath != ""
 }
 
+// GetCopies returns the paths of the storage copies if they are in use; otherwise, returns an empty string.
+func (c *VirtualMachineConfiguration) GetCopies() (copyBlockStoragePath string, copyAuxiliaryStoragePath string, ok bool) {
+	return c.copyBlockStoragePath, c.copyAuxiliaryStoragePath, c.copyBlockStoragePath != "" && c.copyAuxiliaryStoragePath != ""
+}
+
+// GetScratchDisk returns the path of the scratch disk if the virtual machine has one; otherwise, returns an empty string.
+func (c *VirtualMachineConfiguration) GetScratchDisk() (scratchDiskPath string, ok bool) {
+	return c.scratchDiskPath, c.scratchDiskPath != ""
+}
+
 // attachDeviceConfigurations encapsulates various device and configuration attachments to the VM.
-func attachDeviceConfigurations(ctx context.Context, config *vz.VirtualMachineConfiguration, platformConfig *PlatformConfiguration, networkInterfaceIdentifier string, mac net.HardwareAddr) (err error) {
+// The VM is bridged to the network if any, attached to NAT otherwise. The boot disk is attached read-only
+// along with the scratch disk if its path is set.
+func attachDeviceConfigurations(ctx context.Context, config *vz.VirtualMachineConfiguration, platformConfig *PlatformConfiguration, scratchDiskPath string, bridge vz.BridgedNetwork, mac net.HardwareAddr, devices DeviceOptions) (err error) {
 	_, span := trace.StartSpan(ctx, "vm.attachDeviceConfigurations")
 	defer func() {
 		span.SetStatus(err)
@@ -116,22 +258,24 @@ func attachDeviceConfigurations(ctx context.Context, config *vz.VirtualMachineCo
 		graphicsDeviceConfig,
 	})
 
-	// Attach the disk image to the virtual machine
-	diskImageAttachment, err := vz.NewDiskImageStorageDeviceAttachment(
-		platformConfig.BlockStoragePath,
-		false,
-	)
-	if err != nil {
-		return fmt.Errorf("failed to create disk image storage device attachment: %w", err)
-	}
-	blockDeviceConfig, err := vz.NewVirtioBlockDeviceConfiguration(diskImageAttachment)
-	if err != nil {
-		return fmt.Errorf("failed to create block device configuration: %w", err)
+	// Attach the disk images to the virtual machine
+	blockDevices := BlockDevices(platformConfig.BlockStoragePath, scratchDiskPath)
+	storageDeviceConfigs := make([]vz.StorageDeviceConfiguration, 0, len(blockDevices))
+	for _, device := range blockDevices {
+		diskImageAttachment, err := vz.NewDiskImageStorageDeviceAttachment(device.Path, device.ReadOnly)
+		if err != nil {
+			return fmt.Errorf("failed to create disk image storage device attachment: %w", err)
+		}
+		blockDeviceConfig, err := vz.NewVirtioBlockDeviceConfiguration(diskImageAttachment)
+		if err != nil {
+			return fmt.Errorf("failed to create block device configuration: %w", err)
+		}
+		storageDeviceConfigs = append(storageDeviceConfigs, blockDeviceConfig)
 	}
-	config.SetStorageDevicesVirtualMachineConfiguration([]vz.StorageDeviceConfiguration{blockDeviceConfig})
+	config.SetStorageDevicesVirtualMachineConfiguration(storageDeviceConfigs)
 
 	// Create a network device configuration
-	networkDeviceConfig, err := createNetworkDeviceConfiguration(networkInterfaceIdentifier)
+	networkDeviceConfig, err := createNetworkDeviceConfiguration(bridge)
 	if err != nil {
 		return fmt.Errorf("failed to create network device configuration: %w", err)
 	}
@@ -145,6 +289,44 @@ func attachDeviceConfigurations(ctx context.Context, config *vz.VirtualMachineCo
 		networkDeviceConfig,
 	})
 
+	if !devices.DisableInput {
+		if err = attachInputDeviceConfigurations(config); err != nil {
+			return err
+		}
+	}
+
+	if !devices.DisableAudio {
+		// Create audio device configuration
+		audioDeviceConfig, err := createAudioDeviceConfiguration()
+		if err != nil {
+			return fmt.Errorf("failed to create audio device configuration: %w", err)
+		}
+		config.SetAudioDevicesVirtualMachineConfiguration([]vz.AudioDeviceConfiguration{
+			audioDeviceConfig,
+		})
+	}
+
+	return AttachMemoryBalloonDevice(config, devices)
+}
+
+// AttachMemoryBalloonDevice attaches the memory balloon device to the VM if the devices enable it.
+func AttachMemoryBalloonDevice(config MemoryBalloonDeviceConfigurer, devices DeviceOptions) error {
+	if !devices.EnableMemoryBalloon {
+		return nil
+	}
+
+	balloonDeviceConfig, err := vz.NewVirtioTraditionalMemoryBalloonDeviceConfiguration()
+	if err != nil {
+		return fmt.Errorf("failed to create memory balloon device configuration: %w", err)
+	}
+	config.SetMemoryBalloonDevicesVirtualMachineConfiguration([]vz.MemoryBalloonDeviceConfiguration{
+		balloonDeviceConfig,
+	})
+	return nil
+}
+
+// attachInputDeviceConfigurations attaches the pointing and keyboard devices to the VM.
+func attachInputDeviceConfigurations(config *vz.VirtualMachineConfiguration) error {
 	// Create a pointing device configuration
 	usbScreenPointingDevice, err := vz.NewUSBScreenCoordinatePointingDeviceConfiguration()
 	if err != nil {
@@ -166,15 +348,6 @@ func attachDeviceConfigurations(ctx context.Context, config *vz.VirtualMachineCo
 		keyboardDeviceConfig,
 	})
 
-	// Create audio device configuration
-	audioDeviceConfig, err := createAudioDeviceConfiguration()
-	if err != nil {
-		return fmt.Errorf("failed to create audio device configuration: %w", err)
-	}
-	config.SetAudioDevicesVirtualMachineConfiguration([]vz.AudioDeviceConfiguration{
-		audioDeviceConfig,
-	})
-
 	return nil
 }
 
@@ -195,24 +368,12 @@ func createGraphicsDeviceConfiguration() (*vz.MacGraphicsDeviceConfiguration, er
 	return graphicDeviceConfig, nil
 }
 
-// createNetworkDeviceConfiguration creates a network attachment using provided identifier if not empty; otherwise, uses NAT.
-func createNetworkDeviceConfiguration(networkInterfaceIdentifier string) (*vz.VirtioNetworkDeviceConfiguration, error) {
+// createNetworkDeviceConfiguration creates a network attachment bridged to the network if not nil; otherwise, uses NAT.
+func createNetworkDeviceConfiguration(bridge vz.BridgedNetwork) (*vz.VirtioNetworkDeviceConfiguration, error) {
 	var attachment vz.NetworkDeviceAttachment
 	var err error
-	if networkInterfaceIdentifier != "" {
-		var networkInterface vz.BridgedNetwork
-		networkInterfaces := vz.NetworkInterfaces()
-		for _, b := range networkInterfaces {
-			if b.Identifier() == networkInterfaceIdentifier {
-				networkInterface = b
-				break
-			}
-		}
-		if networkInterface == nil {
-			return nil, fmt.Errorf("network interface %s not found", networkInterfaceIdentifier)
-		}
-
-		attachment, err = vz.NewBridgedNetworkDeviceAttachment(networkInterface)
+	if bridge != nil {
+		attachment, err = vz.NewBridgedNetworkDeviceAttachment(bridge)
 		if err != nil {
 			return nil, err
 		}
@@ -266,12 +427,12 @@ func attachDirectorySharingDevicesConfiguration(ctx context.Context, config *vz.
 	}
 
 	sharedDirs := make(map[string]*vz.SharedDirectory, len(mounts))
-	for _, v := range mounts {
+	for name, v := range SharedMounts(mounts) {
 		sharedDir, err := vz.NewSharedDirectory(v.HostPath, v.ReadOnly)
 		if err != nil {
-			return fmt.Errorf("failed to create shared directory: %w", err)
+			return fmt.Errorf("failed to create shared directory %s: %w", name, err)
 		}
-		sharedDirs[filepath.Base(v.ContainerPath)] = sharedDir
+		sharedDirs[name] = sharedDir
 	}
 
 	directoryShare, err := vz.NewMultipleDirectoryShare(sharedDirs)
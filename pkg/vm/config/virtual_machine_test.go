@@ -0,0 +1,145 @@
+package config_test
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/agoda-com/macOS-vz-kubelet/internal/disk"
+	"github.com/agoda-com/macOS-vz-kubelet/internal/volumes"
+	"github.com/agoda-com/macOS-vz-kubelet/pkg/vm/config"
+
+	"github.com/Code-Hex/vz/v3"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/virtual-kubelet/virtual-kubelet/errdefs"
+)
+
+type fakeMemoryBalloonConfigurer struct {
+	devices []vz.MemoryBalloonDeviceConfiguration
+}
+
+func (f *fakeMemoryBalloonConfigurer) SetMemoryBalloonDevicesVirtualMachineConfiguration(devices []vz.MemoryBalloonDeviceConfiguration) {
+	f.devices = devices
+}
+
+func TestAttachMemoryBalloonDevice(t *testing.T) {
+	disabled := &fakeMemoryBalloonConfigurer{}
+	require.NoError(t, config.AttachMemoryBalloonDevice(disabled, config.DeviceOptions{}))
+	assert.Empty(t, disabled.devices)
+
+	enabled := &fakeMemoryBalloonConfigurer{}
+	require.NoError(t, config.AttachMemoryBalloonDevice(enabled, config.DeviceOptions{EnableMemoryBalloon: true}))
+	require.Len(t, enabled.devices, 1)
+	assert.IsType(t, &vz.VirtioTraditionalMemoryBalloonDeviceConfiguration{}, enabled.devices[0])
+}
+
+func TestSharedMountsWithSameBaseName(t *testing.T) {
+	mounts := []volumes.Mount{
+		{Name: "cache", HostPath: "/tmp/cache", ContainerPath: "/Users/admin/data"},
+		{Name: "token", HostPath: "/tmp/token", ContainerPath: "/var/run/data", ReadOnly: true},
+		{Name: "suffixed", HostPath: "/tmp/suffixed", ContainerPath: "/mnt/data-2"},
+		{Name: "logs", HostPath: "/tmp/logs", ContainerPath: "/mnt/logs", ReadOnly: true},
+	}
+
+	assert.Equal(t, []string{"data", "data-2", "data-2-2", "logs"}, config.SharedDirectoryNames(mounts))
+	assert.Equal(t, map[string]volumes.Mount{
+		"data":     mounts[0],
+		"data-2":   mounts[1],
+		"data-2-2": mounts[2],
+		"logs":     mounts[3],
+	}, config.SharedMounts(mounts))
+	assert.Equal(t, []string{
+		"/Volumes/My Shared Files/data",
+		"/Volumes/My Shared Files/data-2",
+		"/Volumes/My Shared Files/data-2-2",
+		"/Volumes/My Shared Files/logs",
+	}, config.GuestSharedDirectoryPaths(mounts))
+}
+
+type fakeNetwork string
+
+func (n fakeNetwork) Identifier() string {
+	return string(n)
+}
+
+func TestFindBridgedNetwork(t *testing.T) {
+	networks := []fakeNetwork{"en0", "en1"}
+
+	tests := []struct {
+		name        string
+		opts        config.NetworkOptions
+		wantNetwork fakeNetwork
+		wantBridged bool
+		wantErr     bool
+	}{
+		{name: "no bridge interface uses NAT", opts: config.NetworkOptions{}},
+		{name: "bridge interface found", opts: config.NetworkOptions{BridgeInterface: "en1"}, wantNetwork: "en1", wantBridged: true},
+		{name: "missing bridge interface fails", opts: config.NetworkOptions{BridgeInterface: "en7"}, wantErr: true},
+		{name: "missing bridge interface falls back to NAT", opts: config.NetworkOptions{BridgeInterface: "en7", BridgeFallbackToNAT: true}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			network, bridged, err := config.FindBridgedNetwork(context.Background(), networks, tt.opts)
+			if tt.wantErr {
+				assert.ErrorContains(t, err, "network interface en7 not found")
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.wantBridged, bridged)
+			assert.Equal(t, tt.wantNetwork, network)
+		})
+	}
+}
+
+func TestValidateResources(t *testing.T) {
+	minMemory := vz.VirtualMachineConfigurationMinimumAllowedMemorySize()
+	maxCPU := vz.VirtualMachineConfigurationMaximumAllowedCPUCount()
+	assert.NoError(t, config.ValidateResources(maxCPU, minMemory))
+
+	tests := map[string]struct {
+		cpu    uint
+		memory uint64
+		reason string
+	}{
+		"No CPU":         {cpu: 0, memory: minMemory, reason: "CPU count 0 is out of the supported range"},
+		"Too many CPUs":  {cpu: maxCPU + 1, memory: minMemory, reason: fmt.Sprintf("CPU count %d is out of the supported range", maxCPU+1)},
+		"Too little RAM": {cpu: 1, memory: minMemory - 1<<20, reason: fmt.Sprintf("memory of %d MiB is out of the supported range", minMemory>>20-1)},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			err := config.ValidateResources(tt.cpu, tt.memory)
+			var invalid *config.ValidationError
+			require.ErrorAs(t, err, &invalid)
+			assert.Contains(t, invalid.Reason, tt.reason)
+			assert.True(t, errdefs.IsInvalidInput(err), err)
+		})
+	}
+}
+
+func TestBlockDevices(t *testing.T) {
+	assert.Equal(t, []config.BlockDevice{
+		{Path: "/cache/disk.img"},
+	}, config.BlockDevices("/cache/disk.img", ""), "the boot disk is writable without a scratch disk")
+
+	assert.Equal(t, []config.BlockDevice{
+		{Path: "/cache/disk.img", ReadOnly: true},
+		{Path: "/cache/disk.img.scratch"},
+	}, config.BlockDevices("/cache/disk.img", "/cache/disk.img.scratch"), "the boot disk is read-only along with a writable scratch disk")
+}
+
+func TestCreateScratchDisk(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "disk.img"+config.ScratchDiskSuffix)
+	require.NoError(t, os.WriteFile(path, []byte("stale"), 0o600))
+
+	require.NoError(t, config.CreateScratchDisk(path, 20<<30))
+	info, err := os.Stat(path)
+	require.NoError(t, err)
+	assert.Equal(t, int64(20<<30), info.Size())
+	usage, err := disk.AllocatedSize(path)
+	require.NoError(t, err)
+	assert.Less(t, usage, int64(1<<20), "the scratch disk is sparse")
+}
@@ -0,0 +1,110 @@
+package vm
+
+import (
+	"context"
+	"os"
+
+	"github.com/agoda-com/macOS-vz-kubelet/pkg/vm/config"
+
+	"github.com/Code-Hex/vz/v3"
+)
+
+// FakeMachine is a virtual machine recording whether it was started and stopped.
+type FakeMachine struct {
+	StartErr         error
+	Started, Stopped bool
+
+	// Paused and Resumed count the pauses and resumes.
+	Paused, Resumed int
+	// SavedStatePath and RestoredStatePath are the paths the state was saved to and restored from.
+	SavedStatePath, RestoredStatePath string
+	RestoreErr                        error
+
+	// ThreadQoS is the QoS class of the thread, StartQoS the class the machine was started with.
+	ThreadQoS, StartQoS config.CPUQoS
+}
+
+func (m *FakeMachine) Start(...vz.VirtualMachineStartOption) error {
+	m.Started = true
+	m.StartQoS = m.ThreadQoS
+	return m.StartErr
+}
+
+func (m *FakeMachine) setThreadQoS(qos config.CPUQoS) (func(), error) {
+	previous := m.ThreadQoS
+	m.ThreadQoS = qos
+	return func() { m.ThreadQoS = previous }, nil
+}
+
+func (m *FakeMachine) Stop() error {
+	m.Stopped = true
+	return nil
+}
+
+func (m *FakeMachine) Pause() error {
+	m.Paused++
+	return nil
+}
+
+func (m *FakeMachine) Resume() error {
+	m.Resumed++
+	return nil
+}
+
+func (m *FakeMachine) SaveMachineStateToPath(saveFilePath string) error {
+	m.SavedStatePath = saveFilePath
+	return os.WriteFile(saveFilePath, []byte("state"), 0o600)
+}
+
+func (m *FakeMachine) RestoreMachineStateFromURL(saveFilePath string) error {
+	if m.RestoreErr != nil {
+		return m.RestoreErr
+	}
+	if _, err := os.Stat(saveFilePath); err != nil {
+		return err
+	}
+	m.RestoredStatePath = saveFilePath
+	return nil
+}
+
+// NewTestVirtualMachineInstance creates an instance driving the fake machine instead of a vz virtual machine.
+// Storage is copied instead of cloned.
+func NewTestVirtualMachineInstance(m *FakeMachine, resolver IPResolver, macAddr string) *VirtualMachineInstance {
+	return &VirtualMachineInstance{macAddr: macAddr, resolver: resolver, machine: m, done: make(chan struct{}), cloneFile: copyFile, setThreadQoS: m.setThreadQoS}
+}
+
+func copyFile(src, dst string, _ int) error {
+	data, err := os.ReadFile(src)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(dst, data, 0o600)
+}
+
+// NewTestVirtualMachineInstanceWithConfig creates an instance driving the fake machine with the configuration.
+func NewTestVirtualMachineInstanceWithConfig(m *FakeMachine, cfg *config.VirtualMachineConfiguration) *VirtualMachineInstance {
+	i := NewTestVirtualMachineInstance(m, nil, "")
+	i.config = cfg
+	return i
+}
+
+// WithConfig sets the configuration of the instance.
+func (i *VirtualMachineInstance) WithConfig(cfg *config.VirtualMachineConfiguration) *VirtualMachineInstance {
+	i.config = cfg
+	return i
+}
+
+// RemoveOverlays removes the overlay files as Stop does once the virtual machine is stopped.
+func (i *VirtualMachineInstance) RemoveOverlays(ctx context.Context) error {
+	return i.removeOverlays(ctx)
+}
+
+// RemoveScratchDisk removes the scratch disk as Stop does once the virtual machine is stopped.
+func (i *VirtualMachineInstance) RemoveScratchDisk(ctx context.Context) error {
+	return i.removeScratchDisk(ctx)
+}
+
+// HandleStateChanges handles the states delivered on the channel as if they were delivered by Virtualization.framework.
+func (i *VirtualMachineInstance) HandleStateChanges(ctx context.Context, states <-chan vz.VirtualMachineState) {
+	i.handleStateChanges(ctx, states)
+}
@@ -5,9 +5,12 @@ import (
 	"errors"
 	"fmt"
 	"os"
+	"path/filepath"
+	"runtime"
 	"time"
 
 	"github.com/agoda-com/macOS-vz-kubelet/internal/netutil"
+	"github.com/agoda-com/macOS-vz-kubelet/internal/utils"
 	"github.com/agoda-com/macOS-vz-kubelet/pkg/vm/config"
 
 	"github.com/Code-Hex/vz/v3"
@@ -15,8 +18,28 @@ import (
 	"github.com/virtual-kubelet/virtual-kubelet/trace"
 )
 
+// ErrCrashed is the error of a virtual machine instance stopped by Virtualization.framework because of an error.
+var ErrCrashed = errors.New("virtual machine stopped with an error")
+
+// ErrRestoreState is the error of a virtual machine instance whose saved machine state could not be restored,
+// e.g. because it was saved by another macOS version or for another hardware model. The instance is left
+// stopped and can still be started from scratch.
+var ErrRestoreState = errors.New("failed to restore virtual machine state")
+
+const (
+	// SavedStateFileName is the name of the machine state file within a snapshot directory.
+	SavedStateFileName = "state.vzvmsave"
+	// SnapshotBlockStorageFileName is the name of the block storage within a snapshot directory.
+	SnapshotBlockStorageFileName = "disk.img"
+	// SnapshotAuxiliaryStorageFileName is the name of the auxiliary storage within a snapshot directory.
+	SnapshotAuxiliaryStorageFileName = "aux.img"
+)
+
 const (
 	IPAddressLookupTimeout = 60 * time.Second
+
+	// IPAddressLookupInterval is the interval between IP address lookup attempts.
+	IPAddressLookupInterval = 2 * time.Second
 )
 
 // VirtualMachineInstance represents a virtual machine instance.
@@ -27,16 +50,35 @@ type VirtualMachineInstance struct {
 	StartedAt  *time.Time
 	FinishedAt *time.Time
 
-	macAddr string
-	config  *config.VirtualMachineConfiguration
+	macAddr  string
+	config   *config.VirtualMachineConfiguration
+	resolver IPResolver
+	machine  machine // the embedded virtual machine, replaceable in tests
+
+	cloneFile func(src, dst string, flags int) error
+
+	setThreadQoS func(qos config.CPUQoS) (restore func(), err error) // replaceable in tests
 
 	ipRetrievalCancelFunc context.CancelFunc
 
+	done chan struct{} // closed once the virtual machine instance has stopped
+	err  error         // error the virtual machine instance stopped with, set before done is closed
+
 	*vz.VirtualMachine
 }
 
-// NewVirtualMachineInstance creates a new virtual machine instance.
-func NewVirtualMachineInstance(ctx context.Context, config *config.VirtualMachineConfiguration) (i *VirtualMachineInstance, err error) {
+// machine is the part of the virtual machine driven by Start, Restore and SaveState.
+type machine interface {
+	Start(opts ...vz.VirtualMachineStartOption) error
+	Stop() error
+	Pause() error
+	Resume() error
+	SaveMachineStateToPath(saveFilePath string) error
+	RestoreMachineStateFromURL(saveFilePath string) error
+}
+
+// NewVirtualMachineInstance creates a new virtual machine instance, whose IP address is discovered with the resolver once started.
+func NewVirtualMachineInstance(ctx context.Context, config *config.VirtualMachineConfiguration, resolver IPResolver) (i *VirtualMachineInstance, err error) {
 	ctx, span := trace.StartSpan(ctx, "VirtualMachineInstance.NewVirtualMachineInstance")
 	defer func() {
 		span.SetStatus(err)
@@ -51,25 +93,32 @@ func NewVirtualMachineInstance(ctx context.Context, config *config.VirtualMachin
 	instance := &VirtualMachineInstance{
 		CreatedAt: time.Now(),
 
-		macAddr: netutil.NormalizeMACAddress(config.MACAddress.String()),
-		config:  config,
+		macAddr:  netutil.NormalizeMACAddress(config.MACAddress.String()),
+		config:   config,
+		resolver: resolver,
+		machine:  vm,
+		done:     make(chan struct{}),
+
+		cloneFile: utils.NewFileCloner().SysClonefileFunc,
+
+		setThreadQoS: setThreadQoS,
 
 		VirtualMachine: vm,
 	}
 
 	// Start listening to state changes
-	go instance.handleStateChanges(ctx)
+	go instance.handleStateChanges(ctx, vm.StateChangedNotify())
 
 	return instance, nil
 }
 
 // handleStateChanges handles state changes for the virtual machine instance.
-func (i *VirtualMachineInstance) handleStateChanges(ctx context.Context) {
+func (i *VirtualMachineInstance) handleStateChanges(ctx context.Context, states <-chan vz.VirtualMachineState) {
 	logger := log.G(ctx)
 
 	for {
 		select {
-		case state, ok := <-i.StateChangedNotify():
+		case state, ok := <-states:
 			if !ok {
 				return
 			}
@@ -84,6 +133,15 @@ func (i *VirtualMachineInstance) handleStateChanges(ctx context.Context) {
 				currentTime := time.Now()
 				i.FinishedAt = &currentTime
 				logger.Debug("Virtual machine instance has finished")
+				close(i.done)
+				return
+			case vz.VirtualMachineStateError:
+				// The virtual machine instance has crashed, it never reaches the stopped state
+				currentTime := time.Now()
+				i.FinishedAt = &currentTime
+				i.err = ErrCrashed
+				logger.Warn("Virtual machine instance has stopped with an error")
+				close(i.done)
 				return
 			}
 		case <-ctx.Done():
@@ -92,6 +150,21 @@ func (i *VirtualMachineInstance) handleStateChanges(ctx context.Context) {
 	}
 }
 
+// Done returns a channel closed once the virtual machine instance has stopped, either gracefully or because of an error.
+func (i *VirtualMachineInstance) Done() <-chan struct{} {
+	return i.done
+}
+
+// Err returns ErrCrashed if the virtual machine instance has stopped because of an error, nil otherwise.
+func (i *VirtualMachineInstance) Err() error {
+	select {
+	case <-i.done:
+		return i.err
+	default:
+		return nil
+	}
+}
+
 // Start starts the virtual machine instance and retrieves the IP address.
 func (i *VirtualMachineInstance) Start(ctx context.Context, opts ...vz.VirtualMachineStartOption) (err error) {
 	ctx, span := trace.StartSpan(ctx, "VirtualMachineInstance.Start")
@@ -100,16 +173,69 @@ func (i *VirtualMachineInstance) Start(ctx context.Context, opts ...vz.VirtualMa
 		span.End()
 	}()
 
-	if err := i.VirtualMachine.Start(opts...); err != nil {
+	restoreQoS := i.preferCPUQoS(ctx)
+	err = i.machine.Start(opts...)
+	restoreQoS()
+	if err != nil {
 		return err
 	}
 
+	return i.waitForIPAddress(ctx)
+}
+
+// preferCPUQoS raises the QoS class of the calling thread to the CPU QoS of the configuration while the virtual
+// machine is started, so that the work Virtualization.framework dispatches for it inherits the class. The class
+// is only a hint to the host scheduler, failing to set it does not fail the start.
+func (i *VirtualMachineInstance) preferCPUQoS(ctx context.Context) (restore func()) {
+	if i.config == nil || i.config.CPUQoS == "" || i.config.CPUQoS == config.CPUQoSDefault {
+		return func() {}
+	}
+
+	runtime.LockOSThread()
+	reset, err := i.setThreadQoS(i.config.CPUQoS)
+	if err != nil {
+		runtime.UnlockOSThread()
+		log.G(ctx).WithError(err).Warnf("Failed to start virtual machine with %s CPU QoS", i.config.CPUQoS)
+		return func() {}
+	}
+	log.G(ctx).Debugf("Starting virtual machine with %s CPU QoS", i.config.CPUQoS)
+	return func() {
+		reset()
+		runtime.UnlockOSThread()
+	}
+}
+
+// Restore resumes the virtual machine instance from the machine state saved at the path, instead of booting it,
+// and retrieves the IP address. The instance must be configured like the saved one, with a clone of the storage
+// saved along with the state. A failure to restore the state is reported as ErrRestoreState.
+func (i *VirtualMachineInstance) Restore(ctx context.Context, saveFilePath string) (err error) {
+	ctx, span := trace.StartSpan(ctx, "VirtualMachineInstance.Restore")
+	defer func() {
+		span.SetStatus(err)
+		span.End()
+	}()
+
+	if err := i.machine.RestoreMachineStateFromURL(saveFilePath); err != nil {
+		return fmt.Errorf("%w: %w", ErrRestoreState, err)
+	}
+	// a restored virtual machine is paused
+	if err := i.machine.Resume(); err != nil {
+		_ = i.machine.Stop()
+		return fmt.Errorf("failed to resume restored virtual machine: %w", err)
+	}
+	log.G(ctx).Debugf("Restored virtual machine state from %s", saveFilePath)
+
+	return i.waitForIPAddress(ctx)
+}
+
+// waitForIPAddress retrieves the IP address of the started virtual machine instance, which is killed if it has none.
+func (i *VirtualMachineInstance) waitForIPAddress(ctx context.Context) (err error) {
 	ctx, i.ipRetrievalCancelFunc = context.WithTimeout(ctx, IPAddressLookupTimeout)
 	defer i.ipRetrievalCancelFunc()
 	err = i.retrieveIPAddress(ctx)
 	if err != nil {
 		// kill the virtual machine instance if we failed to retrieve the IP address
-		_ = i.VirtualMachine.Stop()
+		_ = i.machine.Stop()
 		return fmt.Errorf("failed to retrieve IP address: %w", err)
 	}
 
@@ -124,19 +250,15 @@ func (i *VirtualMachineInstance) retrieveIPAddress(ctx context.Context) (err err
 		span.End()
 	}()
 
-	if i.config.NetworkInterface != "" {
-		// Try to capture the IP using TCP dump method
-		ip, err := netutil.CaptureIPWithTcpDump(ctx, i.config.NetworkInterface, i.macAddr)
-		if err == nil {
-			i.IPAddress = ip
-			return nil
-		}
-
-		log.G(ctx).WithError(err).Warn("Unable to capture IP using tcpdump")
+	// resolvers capturing in the background run until the lookup deadline,
+	// so that no packet of the virtual machine is missed between the attempts
+	resolveCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	resolver := StartIPResolver(resolveCtx, i.resolver, i.macAddr)
+	lookup := func(ctx context.Context) (string, error) {
+		return resolver.ResolveIP(ctx, i.macAddr)
 	}
-
-	// Attempt to retrieve the IP address from the ARP table
-	ip, err := netutil.RetrieveIPFromARPTable(ctx, i.macAddr)
+	ip, err := netutil.PollIPAddress(ctx, IPAddressLookupInterval, lookup)
 	if err != nil {
 		return fmt.Errorf("failed to retrieve IP address: %w", err)
 	}
@@ -144,7 +266,8 @@ func (i *VirtualMachineInstance) retrieveIPAddress(ctx context.Context) (err err
 	return nil
 }
 
-// Stop stops the virtual machine instance and removes the overlay files if they exist.
+// Stop stops the virtual machine instance and removes the overlay files and the scratch disk if they exist.
+// Storage copies are kept, see RemoveCopies.
 func (i *VirtualMachineInstance) Stop(ctx context.Context) (err error) {
 	ctx, span := trace.StartSpan(ctx, "VirtualMachineInstance.Stop")
 	logger := log.G(ctx)
@@ -158,12 +281,39 @@ func (i *VirtualMachineInstance) Stop(ctx context.Context) (err error) {
 		i.ipRetrievalCancelFunc()
 	}
 
-	if i.State() != vz.VirtualMachineStateStopped {
-		// force stop VM if it is not stopped already
+	if state := i.State(); state != vz.VirtualMachineStateStopped && state != vz.VirtualMachineStateError {
+		// force stop VM if it is not stopped already, a crashed VM cannot be stopped
 		logger.Debug("Force stopping VM")
 		err = i.VirtualMachine.Stop()
 	}
 
+	return errors.Join(err, i.removeOverlays(ctx), i.removeScratchDisk(ctx))
+}
+
+// Released reports whether the virtual machine instance has stopped and its overlay files and scratch disk are removed.
+func (i *VirtualMachineInstance) Released() bool {
+	if state := i.State(); state != vz.VirtualMachineStateStopped && state != vz.VirtualMachineStateError {
+		return false
+	}
+	var paths []string
+	if overlayBlockStoragePath, overlayAuxiliaryStoragePath, ok := i.config.GetOverlays(); ok {
+		paths = append(paths, overlayBlockStoragePath, overlayAuxiliaryStoragePath)
+	}
+	if scratchDiskPath, ok := i.config.GetScratchDisk(); ok {
+		paths = append(paths, scratchDiskPath)
+	}
+	for _, path := range paths {
+		if _, err := os.Stat(path); !errors.Is(err, os.ErrNotExist) {
+			return false
+		}
+	}
+	return true
+}
+
+// removeOverlays removes the overlay files if they exist.
+func (i *VirtualMachineInstance) removeOverlays(ctx context.Context) (err error) {
+	logger := log.G(ctx)
+
 	overlayBlockStoragePath, overlayAuxiliaryStoragePath, ok := i.config.GetOverlays()
 	logger.Debugf("Overlay block storage path: %s, overlay auxiliary storage path: %s", overlayBlockStoragePath, overlayAuxiliaryStoragePath)
 	if ok {
@@ -178,3 +328,141 @@ func (i *VirtualMachineInstance) Stop(ctx context.Context) (err error) {
 
 	return err
 }
+
+// removeScratchDisk removes the scratch disk if it exists.
+func (i *VirtualMachineInstance) removeScratchDisk(ctx context.Context) error {
+	scratchDiskPath, ok := i.config.GetScratchDisk()
+	if !ok {
+		return nil
+	}
+
+	log.G(ctx).Debugf("Removing scratch disk: %s", scratchDiskPath)
+	if err := os.Remove(scratchDiskPath); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	return nil
+}
+
+// ScratchDisk returns the path of the scratch disk of the virtual machine instance, if it has one.
+func (i *VirtualMachineInstance) ScratchDisk() (scratchDiskPath string, ok bool) {
+	return i.config.GetScratchDisk()
+}
+
+// WritableStorage returns the paths of the writable storage of the virtual machine instance, its overlays or its copies.
+func (i *VirtualMachineInstance) WritableStorage() (blockStoragePath, auxiliaryStoragePath string, ok bool) {
+	if blockStoragePath, auxiliaryStoragePath, ok = i.config.GetOverlays(); ok {
+		return blockStoragePath, auxiliaryStoragePath, true
+	}
+	return i.config.GetCopies()
+}
+
+// RemoveCopies removes the storage copies of the virtual machine instance if they exist.
+// Unlike overlays, copies outlive Stop and are removed once the pod is deleted.
+func (i *VirtualMachineInstance) RemoveCopies(ctx context.Context) (err error) {
+	copyBlockStoragePath, copyAuxiliaryStoragePath, ok := i.config.GetCopies()
+	if !ok {
+		return nil
+	}
+
+	log.G(ctx).Debugf("Removing storage copies: %s, %s", copyBlockStoragePath, copyAuxiliaryStoragePath)
+	if rmErr := os.Remove(copyBlockStoragePath); rmErr != nil && !errors.Is(rmErr, os.ErrNotExist) {
+		err = errors.Join(err, rmErr)
+	}
+	if rmErr := os.Remove(copyAuxiliaryStoragePath); rmErr != nil && !errors.Is(rmErr, os.ErrNotExist) {
+		err = errors.Join(err, rmErr)
+	}
+	return err
+}
+
+// SaveState saves the machine state and clones the storage of the running virtual machine instance into the directory,
+// from which virtual machines configured alike can be restored. The virtual machine is paused meanwhile, so that the
+// state and the storage match, and keeps running afterwards.
+func (i *VirtualMachineInstance) SaveState(ctx context.Context, dir string) (err error) {
+	ctx, span := trace.StartSpan(ctx, "VirtualMachineInstance.SaveState")
+	defer func() {
+		span.SetStatus(err)
+		span.End()
+	}()
+
+	block, aux, ok := i.config.GetOverlays()
+	if !ok {
+		block, aux, ok = i.config.GetCopies()
+	}
+	if !ok {
+		return errors.New("virtual machine has no storage to save")
+	}
+
+	log.G(ctx).Debug("Pausing VM to save its state")
+	if err := i.machine.Pause(); err != nil {
+		return fmt.Errorf("failed to pause virtual machine: %w", err)
+	}
+	defer func() {
+		if resumeErr := i.machine.Resume(); resumeErr != nil {
+			err = errors.Join(err, fmt.Errorf("failed to resume virtual machine: %w", resumeErr))
+		}
+	}()
+
+	statePath := filepath.Join(dir, SavedStateFileName)
+	blockPath := filepath.Join(dir, SnapshotBlockStorageFileName)
+	auxPath := filepath.Join(dir, SnapshotAuxiliaryStorageFileName)
+	for _, path := range []string{statePath, blockPath, auxPath} {
+		// the state and the clones are not overwritten in place
+		if rmErr := os.Remove(path); rmErr != nil && !errors.Is(rmErr, os.ErrNotExist) {
+			return rmErr
+		}
+	}
+
+	if err := i.machine.SaveMachineStateToPath(statePath); err != nil {
+		return fmt.Errorf("failed to save virtual machine state: %w", err)
+	}
+	if err := i.cloneFile(block, blockPath, 0); err != nil {
+		return fmt.Errorf("failed to clone block storage: %w", err)
+	}
+	if err := i.cloneFile(aux, auxPath, 0); err != nil {
+		return fmt.Errorf("failed to clone auxiliary storage: %w", err)
+	}
+	return nil
+}
+
+// SnapshotStorage clones the storage of the virtual machine instance, the clones are named after the pattern
+// and are to be removed by the caller. A running virtual machine is paused while its storage is cloned,
+// so that the clones are crash-consistent. The storage of overlays is gone once the virtual machine stops.
+func (i *VirtualMachineInstance) SnapshotStorage(ctx context.Context, pattern string) (blockStoragePath, auxiliaryStoragePath string, err error) {
+	ctx, span := trace.StartSpan(ctx, "VirtualMachineInstance.SnapshotStorage")
+	defer func() {
+		span.SetStatus(err)
+		span.End()
+	}()
+
+	block, aux, ok := i.config.GetOverlays()
+	if !ok {
+		block, aux, ok = i.config.GetCopies()
+	}
+	if !ok {
+		return "", "", errors.New("virtual machine has no storage to snapshot")
+	}
+
+	if i.CanPause() {
+		log.G(ctx).Debug("Pausing VM to snapshot its storage")
+		if err := i.Pause(); err != nil {
+			return "", "", fmt.Errorf("failed to pause virtual machine: %w", err)
+		}
+		defer func() {
+			if resumeErr := i.Resume(); resumeErr != nil {
+				err = errors.Join(err, fmt.Errorf("failed to resume virtual machine: %w", resumeErr))
+			}
+		}()
+	}
+
+	fc := utils.NewFileCloner()
+	blockStoragePath, err = fc.Clonefile(block, pattern)
+	if err != nil {
+		return "", "", err
+	}
+	auxiliaryStoragePath, err = fc.Clonefile(aux, pattern)
+	if err != nil {
+		_ = os.Remove(blockStoragePath)
+		return "", "", err
+	}
+	return blockStoragePath, auxiliaryStoragePath, nil
+}
@@ -0,0 +1,198 @@
+package vm_test
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/agoda-com/macOS-vz-kubelet/pkg/vm"
+	"github.com/agoda-com/macOS-vz-kubelet/pkg/vm/config"
+
+	"github.com/Code-Hex/vz/v3"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestVirtualMachineInstanceStateChanges(t *testing.T) {
+	tests := []struct {
+		name        string
+		final       vz.VirtualMachineState
+		expectedErr error
+	}{
+		{
+			name:  "stopped",
+			final: vz.VirtualMachineStateStopped,
+		},
+		{
+			name:        "crashed",
+			final:       vz.VirtualMachineStateError,
+			expectedErr: vm.ErrCrashed,
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			instance := vm.NewTestVirtualMachineInstance(&vm.FakeMachine{}, nil, "")
+			states := make(chan vz.VirtualMachineState, 2)
+			states <- vz.VirtualMachineStateRunning
+			states <- tc.final
+
+			handled := make(chan struct{})
+			go func() {
+				defer close(handled)
+				instance.HandleStateChanges(context.Background(), states)
+			}()
+
+			select {
+			case <-instance.Done():
+			case <-time.After(5 * time.Second):
+				t.Fatal("virtual machine instance did not stop")
+			}
+			<-handled
+			require.NotNil(t, instance.StartedAt)
+			require.NotNil(t, instance.FinishedAt)
+			assert.False(t, instance.FinishedAt.Before(*instance.StartedAt))
+			assert.Equal(t, tc.expectedErr, instance.Err())
+		})
+	}
+}
+
+func TestVirtualMachineInstanceErrBeforeStop(t *testing.T) {
+	instance := vm.NewTestVirtualMachineInstance(&vm.FakeMachine{}, nil, "")
+	assert.NoError(t, instance.Err())
+	assert.Nil(t, instance.FinishedAt)
+}
+
+func TestVirtualMachineInstanceStorageCleanup(t *testing.T) {
+	tests := []struct {
+		name                 string
+		platformConfig       config.PlatformConfiguration
+		removedOnStop        bool
+		removedOnPodDeletion bool
+	}{
+		{
+			name:                 "Overlay",
+			platformConfig:       config.PlatformConfiguration{IsOverlay: true},
+			removedOnStop:        true,
+			removedOnPodDeletion: true,
+		},
+		{
+			name:                 "Copy",
+			platformConfig:       config.PlatformConfiguration{IsCopy: true},
+			removedOnStop:        false,
+			removedOnPodDeletion: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			platformConfig := tt.platformConfig
+			platformConfig.BlockStoragePath = filepath.Join(dir, "disk.img")
+			platformConfig.AuxiliaryStoragePath = filepath.Join(dir, "aux.img")
+			for _, path := range []string{platformConfig.BlockStoragePath, platformConfig.AuxiliaryStoragePath} {
+				require.NoError(t, os.WriteFile(path, nil, 0644))
+			}
+
+			cfg := &config.VirtualMachineConfiguration{}
+			cfg.SetStorage(&platformConfig)
+			_, _, isOverlay := cfg.GetOverlays()
+			_, _, isCopy := cfg.GetCopies()
+			assert.Equal(t, platformConfig.IsOverlay, isOverlay)
+			assert.Equal(t, platformConfig.IsCopy, isCopy)
+
+			instance := vm.NewTestVirtualMachineInstanceWithConfig(&vm.FakeMachine{}, cfg)
+			require.NoError(t, instance.RemoveOverlays(context.Background()))
+			assertStorageRemoved(t, platformConfig, tt.removedOnStop)
+
+			require.NoError(t, instance.RemoveCopies(context.Background()))
+			assertStorageRemoved(t, platformConfig, tt.removedOnPodDeletion)
+		})
+	}
+}
+
+func TestVirtualMachineInstanceScratchDiskCleanup(t *testing.T) {
+	scratchDiskPath := filepath.Join(t.TempDir(), "disk.img"+config.ScratchDiskSuffix)
+	require.NoError(t, config.CreateScratchDisk(scratchDiskPath, 1<<30))
+
+	cfg := &config.VirtualMachineConfiguration{}
+	cfg.SetScratchDisk(scratchDiskPath)
+	instance := vm.NewTestVirtualMachineInstanceWithConfig(&vm.FakeMachine{}, cfg)
+	path, ok := instance.ScratchDisk()
+	require.True(t, ok)
+	assert.Equal(t, scratchDiskPath, path)
+
+	require.NoError(t, instance.RemoveScratchDisk(context.Background()))
+	_, err := os.Stat(scratchDiskPath)
+	assert.ErrorIs(t, err, os.ErrNotExist)
+	// removing it again is a no-op
+	assert.NoError(t, instance.RemoveScratchDisk(context.Background()))
+}
+
+func assertStorageRemoved(t *testing.T, platformConfig config.PlatformConfiguration, removed bool) {
+	t.Helper()
+	for _, path := range []string{platformConfig.BlockStoragePath, platformConfig.AuxiliaryStoragePath} {
+		_, err := os.Stat(path)
+		if removed {
+			assert.ErrorIs(t, err, os.ErrNotExist, path)
+		} else {
+			assert.NoError(t, err, path)
+		}
+	}
+}
+
+func TestVirtualMachineInstanceSaveAndRestoreState(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	platformConfig := config.PlatformConfiguration{IsOverlay: true}
+	platformConfig.BlockStoragePath = filepath.Join(dir, "disk.img.overlay")
+	platformConfig.AuxiliaryStoragePath = filepath.Join(dir, "aux.img.overlay")
+	require.NoError(t, os.WriteFile(platformConfig.BlockStoragePath, []byte("disk"), 0644))
+	require.NoError(t, os.WriteFile(platformConfig.AuxiliaryStoragePath, []byte("aux"), 0644))
+	cfg := &config.VirtualMachineConfiguration{}
+	cfg.SetStorage(&platformConfig)
+
+	saved := &vm.FakeMachine{}
+	snapshotDir := t.TempDir()
+	require.NoError(t, vm.NewTestVirtualMachineInstanceWithConfig(saved, cfg).SaveState(ctx, snapshotDir))
+	statePath := filepath.Join(snapshotDir, vm.SavedStateFileName)
+	assert.Equal(t, statePath, saved.SavedStatePath)
+	assert.Equal(t, 1, saved.Paused)
+	assert.Equal(t, 1, saved.Resumed, "virtual machine not resumed after saving its state")
+	for name, content := range map[string]string{vm.SnapshotBlockStorageFileName: "disk", vm.SnapshotAuxiliaryStorageFileName: "aux"} {
+		data, err := os.ReadFile(filepath.Join(snapshotDir, name))
+		require.NoError(t, err)
+		assert.Equal(t, content, string(data))
+	}
+
+	t.Run("Restored", func(t *testing.T) {
+		restored := &vm.FakeMachine{}
+		instance := vm.NewTestVirtualMachineInstance(restored, vm.StaticResolver{IP: "192.168.64.5"}, "0:1a:2b:3c:4d:5e")
+
+		require.NoError(t, instance.Restore(ctx, statePath))
+		assert.Equal(t, statePath, restored.RestoredStatePath)
+		assert.False(t, restored.Started, "restored virtual machine booted")
+		assert.Equal(t, 1, restored.Resumed)
+		assert.Equal(t, "192.168.64.5", instance.IPAddress)
+	})
+
+	t.Run("Mismatching state", func(t *testing.T) {
+		restored := &vm.FakeMachine{RestoreErr: errors.New("hardware model mismatch")}
+		instance := vm.NewTestVirtualMachineInstance(restored, vm.StaticResolver{IP: "192.168.64.5"}, "0:1a:2b:3c:4d:5e")
+
+		require.ErrorIs(t, instance.Restore(ctx, statePath), vm.ErrRestoreState)
+		assert.Zero(t, restored.Resumed)
+		assert.Empty(t, instance.IPAddress)
+
+		// the instance can still boot from scratch
+		require.NoError(t, instance.Start(ctx))
+		assert.True(t, restored.Started)
+	})
+
+	t.Run("Missing state", func(t *testing.T) {
+		instance := vm.NewTestVirtualMachineInstance(&vm.FakeMachine{}, vm.StaticResolver{IP: "192.168.64.5"}, "0:1a:2b:3c:4d:5e")
+		assert.ErrorIs(t, instance.Restore(ctx, filepath.Join(t.TempDir(), vm.SavedStateFileName)), vm.ErrRestoreState)
+	})
+}
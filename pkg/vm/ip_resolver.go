@@ -0,0 +1,199 @@
+package vm
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+
+	"github.com/agoda-com/macOS-vz-kubelet/internal/netutil"
+)
+
+// IP discovery methods of the virtual machines, see NewIPResolver.
+const (
+	// IPDiscoveryARP looks up the MAC address of the virtual machine in the ARP table of the host.
+	IPDiscoveryARP = "arp"
+	// IPDiscoveryTCPDump captures the first packet sent by the virtual machine on the bridged interface.
+	IPDiscoveryTCPDump = "tcpdump"
+	// IPDiscoveryDHCPLease looks up the lease granted to the virtual machine by the DHCP server of the host.
+	IPDiscoveryDHCPLease = "dhcp-lease"
+	// IPDiscoveryStatic uses a fixed IP address.
+	IPDiscoveryStatic = "static"
+)
+
+// DefaultIPDiscovery are the IP discovery methods used unless configured otherwise.
+var DefaultIPDiscovery = []string{IPDiscoveryTCPDump, IPDiscoveryARP}
+
+// IPResolver discovers the IP address of a virtual machine.
+type IPResolver interface {
+	// ResolveIP performs a single attempt to resolve the IP address of the virtual machine with the given MAC address.
+	// It returns an empty string and no error if the IP address is not known yet.
+	ResolveIP(ctx context.Context, macAddr string) (string, error)
+}
+
+// BackgroundIPResolver is an IPResolver whose attempts are better kept running in the background
+// than restarted, e.g. a packet capture that would miss the packets sent between the attempts.
+type BackgroundIPResolver interface {
+	IPResolver
+	// Start starts resolving the IP address of the virtual machine in the background until the context is done.
+	// The returned resolver reports an empty string and no error until the IP address is resolved.
+	Start(ctx context.Context, macAddr string) IPResolver
+}
+
+// StartIPResolver starts the resolver in the background if it is a BackgroundIPResolver,
+// otherwise it returns the resolver as is.
+func StartIPResolver(ctx context.Context, resolver IPResolver, macAddr string) IPResolver {
+	if r, ok := resolver.(BackgroundIPResolver); ok {
+		return r.Start(ctx, macAddr)
+	}
+	return resolver
+}
+
+// IPResolverConfig configures the IP resolvers created by NewIPResolver.
+type IPResolverConfig struct {
+	// NetworkInterface is the bridged interface of the virtual machine, empty for NAT.
+	NetworkInterface string
+	// DHCPLeasesPath is the leases file of the DHCP server, netutil.DefaultDHCPLeasesPath if empty.
+	DHCPLeasesPath string
+	// StaticIP is the IP address of the static method.
+	StaticIP string
+}
+
+// ValidateIPDiscovery checks that the IP discovery methods are known and configured.
+func ValidateIPDiscovery(methods []string, cfg IPResolverConfig) error {
+	if len(methods) == 0 {
+		return errors.New("no IP discovery method")
+	}
+	for _, method := range methods {
+		switch method {
+		case IPDiscoveryARP, IPDiscoveryTCPDump, IPDiscoveryDHCPLease:
+		case IPDiscoveryStatic:
+			if net.ParseIP(cfg.StaticIP) == nil {
+				return fmt.Errorf("invalid static IP address %q", cfg.StaticIP)
+			}
+		default:
+			return fmt.Errorf("unknown IP discovery method %q", method)
+		}
+	}
+	return nil
+}
+
+// NewIPResolver creates a resolver trying the IP discovery methods in order.
+// The tcpdump method is skipped without a bridged interface, as there is no interface to capture on.
+func NewIPResolver(methods []string, cfg IPResolverConfig) (IPResolver, error) {
+	if err := ValidateIPDiscovery(methods, cfg); err != nil {
+		return nil, err
+	}
+
+	var chain IPResolverChain
+	for _, method := range methods {
+		switch method {
+		case IPDiscoveryARP:
+			chain = append(chain, ARPResolver{})
+		case IPDiscoveryTCPDump:
+			if cfg.NetworkInterface != "" {
+				chain = append(chain, TCPDumpResolver{Interface: cfg.NetworkInterface})
+			}
+		case IPDiscoveryDHCPLease:
+			chain = append(chain, DHCPLeaseResolver{Path: cfg.DHCPLeasesPath})
+		case IPDiscoveryStatic:
+			chain = append(chain, StaticResolver{IP: cfg.StaticIP})
+		}
+	}
+	if len(chain) == 0 {
+		return nil, errors.New("no IP discovery method applies to the virtual machine")
+	}
+	return chain, nil
+}
+
+// IPResolverChain tries the resolvers in order until one of them returns an IP address.
+type IPResolverChain []IPResolver
+
+// ResolveIP implements IPResolver. The errors of the resolvers are only returned if none of them found the IP address.
+func (c IPResolverChain) ResolveIP(ctx context.Context, macAddr string) (string, error) {
+	var errs []error
+	for _, resolver := range c {
+		ip, err := resolver.ResolveIP(ctx, macAddr)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		if ip != "" {
+			return ip, nil
+		}
+	}
+	return "", errors.Join(errs...)
+}
+
+// Start implements BackgroundIPResolver by starting the resolvers of the chain that run in the background.
+func (c IPResolverChain) Start(ctx context.Context, macAddr string) IPResolver {
+	started := make(IPResolverChain, len(c))
+	for i, resolver := range c {
+		started[i] = StartIPResolver(ctx, resolver, macAddr)
+	}
+	return started
+}
+
+// ARPResolver looks up the IP address in the ARP table of the host.
+type ARPResolver struct{}
+
+// ResolveIP implements IPResolver.
+func (ARPResolver) ResolveIP(ctx context.Context, macAddr string) (string, error) {
+	return netutil.LookupIPInARPTable(ctx, macAddr)
+}
+
+// TCPDumpResolver captures the IP address from the packets sent by the virtual machine on the interface.
+type TCPDumpResolver struct {
+	Interface string
+}
+
+// ResolveIP implements IPResolver. It blocks until a packet of the virtual machine is captured or the context is done,
+// Start keeps a single capture running in the background instead.
+func (r TCPDumpResolver) ResolveIP(ctx context.Context, macAddr string) (string, error) {
+	ip, err := netutil.CaptureIPWithTcpDump(ctx, r.Interface, macAddr)
+	if errors.Is(err, context.DeadlineExceeded) || errors.Is(err, context.Canceled) {
+		// nothing captured before the context was done
+		return "", nil
+	}
+	return ip, err
+}
+
+// Start implements BackgroundIPResolver.
+func (r TCPDumpResolver) Start(ctx context.Context, macAddr string) IPResolver {
+	return lookupResolver(netutil.BackgroundLookup(ctx, func(ctx context.Context) (string, error) {
+		return r.ResolveIP(ctx, macAddr)
+	}))
+}
+
+// lookupResolver resolves the IP address with a lookup bound to the MAC address of the virtual machine.
+type lookupResolver netutil.IPLookupFunc
+
+// ResolveIP implements IPResolver.
+func (l lookupResolver) ResolveIP(ctx context.Context, _ string) (string, error) {
+	return l(ctx)
+}
+
+// DHCPLeaseResolver looks up the IP address in the leases of the DHCP server of the host, used by NAT virtual machines.
+type DHCPLeaseResolver struct {
+	// Path is the leases file, netutil.DefaultDHCPLeasesPath if empty.
+	Path string
+}
+
+// ResolveIP implements IPResolver.
+func (r DHCPLeaseResolver) ResolveIP(_ context.Context, macAddr string) (string, error) {
+	path := r.Path
+	if path == "" {
+		path = netutil.DefaultDHCPLeasesPath
+	}
+	return netutil.LookupIPInDHCPLeases(path, macAddr)
+}
+
+// StaticResolver always resolves to the same IP address, e.g. when the virtual machine has a DHCP reservation.
+type StaticResolver struct {
+	IP string
+}
+
+// ResolveIP implements IPResolver.
+func (r StaticResolver) ResolveIP(context.Context, string) (string, error) {
+	return r.IP, nil
+}
@@ -0,0 +1,163 @@
+package vm_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/agoda-com/macOS-vz-kubelet/pkg/vm"
+	"github.com/agoda-com/macOS-vz-kubelet/pkg/vm/config"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeResolver returns the queued results of its attempts, then misses.
+type fakeResolver struct {
+	results []fakeResult
+	macAddr string
+}
+
+type fakeResult struct {
+	ip  string
+	err error
+}
+
+func (r *fakeResolver) ResolveIP(_ context.Context, macAddr string) (string, error) {
+	r.macAddr = macAddr
+	if len(r.results) == 0 {
+		return "", nil
+	}
+	result := r.results[0]
+	r.results = r.results[1:]
+	return result.ip, result.err
+}
+
+func TestVirtualMachineInstanceStart(t *testing.T) {
+	t.Run("IP address resolved", func(t *testing.T) {
+		machine := &vm.FakeMachine{}
+		resolver := &fakeResolver{results: []fakeResult{{ip: "192.168.64.5"}}}
+		instance := vm.NewTestVirtualMachineInstance(machine, resolver, "0:1a:2b:3c:4d:5e")
+
+		require.NoError(t, instance.Start(context.Background()))
+		assert.True(t, machine.Started)
+		assert.False(t, machine.Stopped)
+		assert.Equal(t, "192.168.64.5", instance.IPAddress)
+		assert.Equal(t, "0:1a:2b:3c:4d:5e", resolver.macAddr)
+	})
+
+	t.Run("Resolver errors stop the virtual machine", func(t *testing.T) {
+		ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+		defer cancel()
+
+		resolverErr := errors.New("lookup failed")
+		machine := &vm.FakeMachine{}
+		resolver := &fakeResolver{results: []fakeResult{{err: resolverErr}}}
+		instance := vm.NewTestVirtualMachineInstance(machine, resolver, "0:1a:2b:3c:4d:5e")
+
+		err := instance.Start(ctx)
+		require.ErrorIs(t, err, resolverErr)
+		assert.ErrorIs(t, err, context.DeadlineExceeded)
+		assert.True(t, machine.Stopped)
+		assert.Empty(t, instance.IPAddress)
+	})
+
+	t.Run("Virtual machine fails to start", func(t *testing.T) {
+		startErr := errors.New("start failed")
+		machine := &vm.FakeMachine{StartErr: startErr}
+		resolver := &fakeResolver{results: []fakeResult{{ip: "192.168.64.5"}}}
+		instance := vm.NewTestVirtualMachineInstance(machine, resolver, "0:1a:2b:3c:4d:5e")
+
+		require.ErrorIs(t, instance.Start(context.Background()), startErr)
+		assert.Empty(t, resolver.macAddr, "resolver used before the virtual machine started")
+	})
+
+	for flag, expected := range map[string]config.CPUQoS{"": "", "default": "", "performance": config.CPUQoSPerformance} {
+		t.Run("CPU QoS "+flag, func(t *testing.T) {
+			qos, err := config.ParseCPUQoS(flag)
+			require.NoError(t, err)
+
+			machine := &vm.FakeMachine{}
+			resolver := &fakeResolver{results: []fakeResult{{ip: "192.168.64.5"}}}
+			instance := vm.NewTestVirtualMachineInstance(machine, resolver, "0:1a:2b:3c:4d:5e").
+				WithConfig(&config.VirtualMachineConfiguration{CPUQoS: qos})
+
+			require.NoError(t, instance.Start(context.Background()))
+			assert.Equal(t, expected, machine.StartQoS)
+			assert.Empty(t, machine.ThreadQoS, "QoS class of the thread not restored")
+		})
+	}
+
+	_, err := config.ParseCPUQoS("efficiency")
+	assert.Error(t, err)
+}
+
+func TestIPResolverChain(t *testing.T) {
+	failing := &fakeResolver{results: []fakeResult{{err: errors.New("tcpdump unavailable")}, {err: errors.New("tcpdump unavailable")}}}
+	chain := vm.IPResolverChain{failing, vm.StaticResolver{IP: "10.0.0.2"}}
+
+	ip, err := chain.ResolveIP(context.Background(), "0:1a:2b:3c:4d:5e")
+	require.NoError(t, err)
+	assert.Equal(t, "10.0.0.2", ip)
+
+	ip, err = vm.IPResolverChain{failing, &fakeResolver{}}.ResolveIP(context.Background(), "0:1a:2b:3c:4d:5e")
+	assert.ErrorContains(t, err, "tcpdump unavailable")
+	assert.Empty(t, ip)
+}
+
+// fakeBackgroundResolver records the MAC address it was started with, and only resolves once started.
+type fakeBackgroundResolver struct {
+	started string
+}
+
+func (r *fakeBackgroundResolver) ResolveIP(context.Context, string) (string, error) {
+	return "", nil
+}
+
+func (r *fakeBackgroundResolver) Start(_ context.Context, macAddr string) vm.IPResolver {
+	r.started = macAddr
+	return vm.StaticResolver{IP: "192.168.64.5"}
+}
+
+func TestStartIPResolver(t *testing.T) {
+	ctx := context.Background()
+	static := vm.StaticResolver{IP: "10.0.0.2"}
+	assert.Equal(t, static, vm.StartIPResolver(ctx, static, "0:1a:2b:3c:4d:5e"))
+
+	// the background resolvers of a chain are started
+	background := &fakeBackgroundResolver{}
+	resolver := vm.StartIPResolver(ctx, vm.IPResolverChain{&fakeResolver{}, background}, "0:1a:2b:3c:4d:5e")
+	assert.Equal(t, "0:1a:2b:3c:4d:5e", background.started)
+
+	ip, err := resolver.ResolveIP(ctx, "0:1a:2b:3c:4d:5e")
+	require.NoError(t, err)
+	assert.Equal(t, "192.168.64.5", ip)
+}
+
+func TestNewIPResolver(t *testing.T) {
+	resolver, err := vm.NewIPResolver(vm.DefaultIPDiscovery, vm.IPResolverConfig{NetworkInterface: "en0"})
+	require.NoError(t, err)
+	assert.Equal(t, vm.IPResolverChain{
+		vm.TCPDumpResolver{Interface: "en0"},
+		vm.ARPResolver{},
+	}, resolver)
+
+	// NAT virtual machines have no interface to capture on
+	resolver, err = vm.NewIPResolver(vm.DefaultIPDiscovery, vm.IPResolverConfig{})
+	require.NoError(t, err)
+	assert.Equal(t, vm.IPResolverChain{vm.ARPResolver{}}, resolver)
+
+	resolver, err = vm.NewIPResolver([]string{vm.IPDiscoveryDHCPLease, vm.IPDiscoveryStatic}, vm.IPResolverConfig{StaticIP: "10.0.0.2"})
+	require.NoError(t, err)
+	assert.Equal(t, vm.IPResolverChain{vm.DHCPLeaseResolver{}, vm.StaticResolver{IP: "10.0.0.2"}}, resolver)
+
+	_, err = vm.NewIPResolver([]string{vm.IPDiscoveryTCPDump}, vm.IPResolverConfig{})
+	assert.ErrorContains(t, err, "no IP discovery method applies")
+	_, err = vm.NewIPResolver([]string{"mdns"}, vm.IPResolverConfig{})
+	assert.ErrorContains(t, err, "unknown IP discovery method")
+	_, err = vm.NewIPResolver([]string{vm.IPDiscoveryStatic}, vm.IPResolverConfig{StaticIP: "nope"})
+	assert.ErrorContains(t, err, "invalid static IP address")
+	_, err = vm.NewIPResolver(nil, vm.IPResolverConfig{})
+	assert.Error(t, err)
+}
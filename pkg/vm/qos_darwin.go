@@ -0,0 +1,33 @@
+//go:build darwin && cgo
+
+package vm
+
+/*
+#include <pthread.h>
+#include <pthread/qos.h>
+*/
+import "C"
+
+import (
+	"fmt"
+	"syscall"
+
+	"github.com/agoda-com/macOS-vz-kubelet/pkg/vm/config"
+)
+
+// setThreadQoS sets the QoS class of the calling thread, which must be locked to its goroutine, and returns
+// a function restoring the previous class.
+func setThreadQoS(qos config.CPUQoS) (restore func(), err error) {
+	class := C.QOS_CLASS_DEFAULT
+	if qos == config.CPUQoSPerformance {
+		class = C.QOS_CLASS_USER_INTERACTIVE
+	}
+
+	previous := C.qos_class_self()
+	if rc := C.pthread_set_qos_class_self_np(C.qos_class_t(class), 0); rc != 0 {
+		return nil, fmt.Errorf("failed to set the QoS class of the thread: %w", syscall.Errno(rc))
+	}
+	return func() {
+		_ = C.pthread_set_qos_class_self_np(previous, 0)
+	}, nil
+}
@@ -0,0 +1,10 @@
+//go:build !darwin || !cgo
+
+package vm
+
+import "github.com/agoda-com/macOS-vz-kubelet/pkg/vm/config"
+
+// setThreadQoS leaves the QoS class of the calling thread as is, QoS classes are only exposed by macOS.
+func setThreadQoS(config.CPUQoS) (restore func(), err error) {
+	return func() {}, nil
+}